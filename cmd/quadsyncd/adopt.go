@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// exitCodeAdoptConflicts is returned by `adopt` when an existing file at a
+// repository path has different content and was not adopted.
+const exitCodeAdoptConflicts = 2
+
+// Adopt command flags
+var (
+	adoptDryRun bool
+	adoptOutput string
+)
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt",
+	Short: "Record existing files that match the repositories as managed",
+	Long: `Adopt prepares a host with hand-written quadlets for its first sync. It fetches
+the configured repositories and, for every file that already exists at its
+destination with exactly the content quadsyncd would install, records it in
+the sync state as managed. Nothing in the quadlet directory is changed and no
+units are restarted.
+
+Files at a repository path whose content differs are reported as conflicts
+and left unmanaged; the next sync would overwrite them. Quadlets in the
+quadlet directory that no repository provides are reported as unmatched, so
+hand-written copies of repository units under another name can be removed.
+
+Exit codes:
+  0  no conflicts
+  1  an error occurred
+  2  at least one existing file differs from the repository`,
+	Args: cobra.NoArgs,
+	RunE: runAdopt,
+}
+
+func init() {
+	adoptCmd.Flags().BoolVar(&adoptDryRun, "dry-run", false, "report what would be adopted without writing the state")
+	adoptCmd.Flags().StringVarP(&adoptOutput, "output", "o", outputText, "output format: text or json")
+	rootCmd.AddCommand(adoptCmd)
+}
+
+func runAdopt(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(adoptOutput); err != nil {
+		return err
+	}
+	// The report goes to stdout; keep logs out of it.
+	logsToStderr = true
+
+	ctx, cancel := setupSignalHandler()
+	defer cancel()
+
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	engine := sync.New(cfg, sync.WithLogger(logger), sync.WithDryRun(adoptDryRun))
+	report, err := engine.Adopt(ctx)
+	if err != nil {
+		return fmt.Errorf("adopt failed: %w", err)
+	}
+
+	if adoptOutput == outputJSON {
+		err = writeAdoptReport(os.Stdout, report)
+	} else {
+		err = printAdoptReport(os.Stdout, report, cfg.Paths.QuadletDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Count(sync.AdoptConflict) > 0 {
+		cmd.SilenceErrors = true
+		return &exitError{code: exitCodeAdoptConflicts}
+	}
+	return nil
+}
+
+// printAdoptReport writes the considered files as a table followed by a
+// summary.
+func printAdoptReport(w io.Writer, report *sync.AdoptReport, quadletDir string) error {
+	if len(report.Results) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "STATUS\tPATH\tDETAIL")
+		for _, r := range report.Results {
+			detail := ""
+			if r.Status == sync.AdoptConflict {
+				detail = fmt.Sprintf("repository %s, on disk %s", shortSHA(r.Expected), shortSHA(r.Actual))
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Status, displayPath(quadletDir, r.Path), detail)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, report.String())
+	return err
+}
+
+// writeAdoptReport encodes report as indented JSON followed by a newline.
+func writeAdoptReport(w io.Writer, report *sync.AdoptReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write adopt report: %w", err)
+	}
+	return nil
+}
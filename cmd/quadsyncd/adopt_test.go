@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestPrintAdoptReport(t *testing.T) {
+	var buf bytes.Buffer
+	report := &sync.AdoptReport{
+		Managed: 1,
+		Missing: 2,
+		Results: []sync.AdoptResult{
+			{Path: "/q/db.container", Status: sync.AdoptConflict, Expected: "1111111111111111", Actual: "2222222222222222"},
+			{Path: "/q/old.container", Status: sync.AdoptUnmatched},
+			{Path: "/q/web.container", Status: sync.AdoptAdopted, Expected: "3333333333333333"},
+		},
+	}
+	if err := printAdoptReport(&buf, report, "/q"); err != nil {
+		t.Fatalf("printAdoptReport: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "STATUS") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	if f := strings.Fields(lines[1]); f[0] != "conflict" || f[1] != "db.container" || !strings.Contains(lines[1], "222222222222") {
+		t.Errorf("conflict row = %q", lines[1])
+	}
+	if f := strings.Fields(lines[3]); f[0] != "adopted" || f[1] != "web.container" || len(f) != 2 {
+		t.Errorf("adopted row = %q", lines[3])
+	}
+	if lines[4] != "1 adopted, 1 conflicts, 1 unmatched, 1 already managed, 2 missing" {
+		t.Errorf("summary = %q", lines[4])
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/bench"
+	"github.com/spf13/cobra"
+)
+
+// Bench command flags
+var (
+	benchSizes      []int
+	benchIterations int
+	benchOutput     string
+	benchCheck      bool
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark planning and applying against synthetic repositories",
+	Long: `Bench generates synthetic quadlet repositories in a temporary directory and
+times the plan and apply phases of a sync for three scenarios: an initial
+sync, a no-op re-sync and an update touching 10% of the files. Git and
+systemd are not involved, and no configuration file is needed.
+
+With --check, the command fails when any scenario exceeds its per-file time
+budget, so it can guard CI against performance regressions.`,
+	Args: cobra.NoArgs,
+	RunE: runBench,
+}
+
+func init() {
+	benchCmd.Flags().IntSliceVar(&benchSizes, "sizes", []int{1000, 10000}, "repository sizes (file counts) to benchmark")
+	benchCmd.Flags().IntVar(&benchIterations, "iterations", 3, "iterations per size; the median is reported")
+	benchCmd.Flags().StringVar(&benchOutput, "output", outputText, "result output format (text, json)")
+	benchCmd.Flags().BoolVar(&benchCheck, "check", false, "exit non-zero if any scenario exceeds its time budget")
+	rootCmd.AddCommand(benchCmd)
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(benchOutput); err != nil {
+		return err
+	}
+	logsToStderr = benchOutput == outputJSON
+
+	ctx, cancel := setupSignalHandler()
+	defer cancel()
+
+	logger := setupLogger()
+
+	workDir, err := os.MkdirTemp("", "quadsyncd-bench-")
+	if err != nil {
+		return fmt.Errorf("failed to create work dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(workDir) }()
+
+	results, err := bench.Run(ctx, bench.Options{
+		Sizes:      benchSizes,
+		Iterations: benchIterations,
+		WorkDir:    workDir,
+	}, logger)
+	if err != nil {
+		return err
+	}
+
+	if benchOutput == outputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else if err := printBenchResults(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if benchCheck {
+		over := 0
+		for _, r := range results {
+			if r.OverBudget() {
+				over++
+			}
+		}
+		if over > 0 {
+			return fmt.Errorf("%d benchmark scenario(s) exceeded their time budget", over)
+		}
+	}
+	return nil
+}
+
+// printBenchResults writes a table of benchmark results.
+func printBenchResults(w io.Writer, results []bench.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SCENARIO\tFILES\tPLAN\tAPPLY\tTOTAL\tPER FILE\tBUDGET\tSTATUS")
+	for _, r := range results {
+		status := "ok"
+		if r.OverBudget() {
+			status = "OVER"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Scenario, r.Files,
+			r.Plan.Round(time.Microsecond),
+			r.Apply.Round(time.Microsecond),
+			r.Total().Round(time.Microsecond),
+			(r.Total() / time.Duration(r.Files)).Round(time.Microsecond),
+			r.Budget.Round(time.Millisecond),
+			status)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/bench"
+)
+
+func TestPrintBenchResults(t *testing.T) {
+	var buf bytes.Buffer
+	results := []bench.Result{
+		{Scenario: bench.ScenarioInitial, Files: 1000, Plan: 20 * time.Millisecond, Apply: 80 * time.Millisecond, Budget: time.Second},
+		{Scenario: bench.ScenarioNoop, Files: 1000, Plan: 300 * time.Millisecond, Budget: 250 * time.Millisecond},
+	}
+	if err := printBenchResults(&buf, results); err != nil {
+		t.Fatalf("printBenchResults: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "SCENARIO") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+	if f := strings.Fields(lines[1]); f[0] != "initial" || f[4] != "100ms" || f[5] != "100µs" || f[7] != "ok" {
+		t.Errorf("initial row = %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "OVER") {
+		t.Errorf("noop row should be over budget: %q", lines[2])
+	}
+}
+
+func TestCLI_Bench_JSON(t *testing.T) {
+	origSizes, origIter, origOut, origCheck := benchSizes, benchIterations, benchOutput, benchCheck
+	origLogsToStderr := logsToStderr
+	t.Cleanup(func() {
+		benchSizes, benchIterations, benchOutput, benchCheck = origSizes, origIter, origOut, origCheck
+		logsToStderr = origLogsToStderr
+	})
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	os.Stdout = w
+
+	rootCmd.SetArgs([]string{"bench", "--sizes", "10", "--iterations", "1", "--output", "json"})
+	execErr := rootCmd.Execute()
+
+	_ = w.Close()
+	os.Stdout = origStdout
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+
+	if execErr != nil {
+		t.Fatalf("bench: %v", execErr)
+	}
+	out := buf.String()
+	for _, want := range []string{`"scenario": "initial"`, `"scenario": "noop"`, `"scenario": "update"`, `"files": 10`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+}
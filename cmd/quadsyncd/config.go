@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// exitCodeConfigInvalid is returned by `config validate` when the
+// configuration does not load or an environment check fails.
+const exitCodeConfigInvalid = 2
+
+// Config validate command flags
+var configValidateOutput string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and check the configuration file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration and the files it refers to without syncing",
+	Long: `Validate loads the configuration with the same rules as sync and serve, then
+checks the environment it depends on: key, token and secret files exist, are
+readable and not empty, the quadlet and state directories are writable (or can
+be created), and every repository URL uses a scheme quadsyncd can authenticate.
+It fetches nothing and changes nothing.
+
+Use it as a unit's ExecStartPre=quadsyncd config validate to fail early with a
+readable report.
+
+Exit codes:
+  0  the configuration is valid; warnings may have been reported
+  1  an error occurred
+  2  the configuration does not load or a check failed`,
+	Args: cobra.NoArgs,
+	RunE: runConfigValidate,
+}
+
+func init() {
+	configValidateCmd.Flags().StringVarP(&configValidateOutput, "output", "o", outputText, "output format: text or json")
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// configReport is the document printed by `config validate --output json`.
+// Field names are part of the CLI contract; only add fields, never rename.
+type configReport struct {
+	Path   string               `json:"path"`
+	Valid  bool                 `json:"valid"`
+	Checks []config.CheckResult `json:"checks"`
+}
+
+// problems returns the number of failed checks and warnings.
+func (r *configReport) problems() (errs, warnings int) {
+	for _, c := range r.Checks {
+		switch c.Status {
+		case config.CheckError:
+			errs++
+		case config.CheckWarning:
+			warnings++
+		}
+	}
+	return errs, warnings
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(configValidateOutput); err != nil {
+		return err
+	}
+	if configValidateOutput == outputJSON {
+		logsToStderr = true
+	}
+	logger := setupLogger()
+
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	report := validateConfigFile(path)
+
+	if configValidateOutput == outputJSON {
+		err = writeConfigReport(os.Stdout, report)
+	} else {
+		err = printConfigReport(os.Stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !report.Valid {
+		errs, _ := report.problems()
+		logger.Warn("configuration is invalid", "path", path, "errors", errs)
+		cmd.SilenceErrors = true
+		return &exitError{code: exitCodeConfigInvalid}
+	}
+	return nil
+}
+
+// validateConfigFile loads path and runs the environment checks. A load
+// failure is reported as a single failed "config" check.
+func validateConfigFile(path string) *configReport {
+	report := &configReport{Path: path}
+	cfg, err := config.Load(path)
+	if err != nil {
+		report.Checks = []config.CheckResult{{Field: "config", Path: path, Status: config.CheckError, Detail: err.Error()}}
+		return report
+	}
+	report.Checks = cfg.CheckEnvironment()
+	errs, _ := report.problems()
+	report.Valid = errs == 0
+	return report
+}
+
+// printConfigReport writes the checks as a table followed by a summary.
+func printConfigReport(w io.Writer, report *configReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STATUS\tFIELD\tPATH\tDETAIL")
+	for _, c := range report.Checks {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Status, c.Field, c.Path, c.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	errs, warnings := report.problems()
+	verdict := "valid"
+	if !report.Valid {
+		verdict = "invalid"
+	}
+	_, err := fmt.Fprintf(w, "%s is %s: %d error(s), %d warning(s)\n", report.Path, verdict, errs, warnings)
+	return err
+}
+
+// writeConfigReport encodes report as indented JSON followed by a newline.
+func writeConfigReport(w io.Writer, report *configReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write config report: %w", err)
+	}
+	return nil
+}
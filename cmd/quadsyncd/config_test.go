@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+)
+
+func TestPrintConfigReport(t *testing.T) {
+	var buf bytes.Buffer
+	report := &configReport{
+		Path: "/c/config.yaml",
+		Checks: []config.CheckResult{
+			{Field: "paths.quadlet_dir", Path: "/q", Status: config.CheckOK},
+			{Field: "auth.ssh_key_file", Path: "/k", Status: config.CheckError, Detail: "permission denied"},
+		},
+	}
+	if err := printConfigReport(&buf, report); err != nil {
+		t.Fatalf("printConfigReport: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "STATUS") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	if f := strings.Fields(lines[2]); f[0] != "error" || f[1] != "auth.ssh_key_file" || !strings.Contains(lines[2], "permission denied") {
+		t.Errorf("error row = %q", lines[2])
+	}
+	if lines[3] != "/c/config.yaml is invalid: 1 error(s), 0 warning(s)" {
+		t.Errorf("summary = %q", lines[3])
+	}
+}
+
+func TestCLI_ConfigValidate(t *testing.T) {
+	origCfg, origOut, origLogsToStderr := cfgFile, configValidateOutput, logsToStderr
+	t.Cleanup(func() {
+		cfgFile, configValidateOutput, logsToStderr = origCfg, origOut, origLogsToStderr
+	})
+
+	run := func(args ...string) (string, error) {
+		origStdout := os.Stdout
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("os.Pipe: %v", err)
+		}
+		os.Stdout = w
+		configValidateOutput = outputText
+		rootCmd.SetArgs(append([]string{"config", "validate"}, args...))
+		execErr := rootCmd.Execute()
+		_ = w.Close()
+		os.Stdout = origStdout
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(r)
+		return buf.String(), execErr
+	}
+
+	tmpDir := t.TempDir()
+	cfgFile = writeTempConfig(t, tmpDir)
+
+	out, err := run()
+	if err != nil {
+		t.Fatalf("config validate: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "is valid: 0 error(s)") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	// A missing values file fails the check with exit code 2.
+	data, _ := os.ReadFile(cfgFile)
+	data = append(data, []byte("values:\n  files: [\""+filepath.Join(tmpDir, "missing.yaml")+"\"]\n")...)
+	if err := os.WriteFile(cfgFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err = run("--output", "json")
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != exitCodeConfigInvalid {
+		t.Fatalf("expected exit code %d, got %v", exitCodeConfigInvalid, err)
+	}
+	var report configReport
+	if jerr := json.Unmarshal([]byte(out), &report); jerr != nil {
+		t.Fatalf("invalid JSON: %v\n%s", jerr, out)
+	}
+	if report.Valid || report.Checks[len(report.Checks)-1].Field != "values.files[0]" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// A config that does not load is reported, not returned as an error.
+	cfgFile = filepath.Join(tmpDir, "nope.yaml")
+	out, err = run()
+	if !errors.As(err, &exitErr) || exitErr.code != exitCodeConfigInvalid {
+		t.Fatalf("expected exit code %d, got %v", exitCodeConfigInvalid, err)
+	}
+	if !strings.Contains(out, "failed to read config file") {
+		t.Errorf("output missing load error:\n%s", out)
+	}
+}
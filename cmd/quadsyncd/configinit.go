@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/install"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Config init command flags
+var (
+	initNonInteractive bool
+	initAnswersFlags   initAnswers
+	initForce          bool
+)
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a starter configuration file",
+	Long: `Init asks for the repository to sync and writes a starter configuration to
+--config (default ~/.config/quadsyncd/config.yaml) with user-mode defaults:
+quadlets go to the rootless quadlet directory ($XDG_CONFIG_HOME/containers/systemd)
+and state to $XDG_STATE_HOME/quadsyncd. It can also create both directories and
+write a systemd user timer and service that sync every five minutes.
+
+Flags answer the matching questions; with --non-interactive nothing is asked
+and --repo-url is required. An existing file is only replaced with --force.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigInit,
+}
+
+func init() {
+	f := configInitCmd.Flags()
+	f.BoolVar(&initNonInteractive, "non-interactive", false, "do not prompt; take every answer from flags and defaults")
+	f.StringVar(&initAnswersFlags.RepoURL, "repo-url", "", "repository URL (git@..., ssh://... or https://...)")
+	f.StringVar(&initAnswersFlags.Ref, "ref", "refs/heads/main", "git ref to track")
+	f.StringVar(&initAnswersFlags.Subdir, "subdir", "", "repository subdirectory holding the quadlet files")
+	f.StringVar(&initAnswersFlags.SSHKeyFile, "ssh-key-file", "", "SSH deploy key for an SSH repository URL")
+	f.StringVar(&initAnswersFlags.TokenFile, "https-token-file", "", "file holding an access token for an HTTPS repository URL")
+	f.StringVar(&initAnswersFlags.QuadletDir, "quadlet-dir", config.DefaultQuadletDir(), "directory to sync quadlet files into")
+	f.StringVar(&initAnswersFlags.StateDir, "state-dir", config.DefaultStateDir(), "directory for checkouts and sync state")
+	f.BoolVar(&initAnswersFlags.CreateDirs, "create-dirs", false, "create the quadlet and state directories")
+	f.BoolVar(&initAnswersFlags.Units, "units", false, "write "+install.SyncServiceName+" and "+install.SyncTimerName+" to the systemd user unit directory")
+	f.BoolVar(&initForce, "force", false, "replace an existing configuration file and units")
+	configCmd.AddCommand(configInitCmd)
+}
+
+// initAnswers holds everything config init asks for.
+type initAnswers struct {
+	RepoURL    string
+	Ref        string
+	Subdir     string
+	SSHKeyFile string
+	TokenFile  string
+	QuadletDir string
+	StateDir   string
+	CreateDirs bool
+	Units      bool
+}
+
+// isSSH reports whether the repository URL uses SSH.
+func (a initAnswers) isSSH() bool {
+	return strings.HasPrefix(a.RepoURL, "git@") || strings.HasPrefix(a.RepoURL, "ssh://")
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	answers := initAnswersFlags
+	if !initNonInteractive {
+		p := &prompter{in: bufio.NewReader(cmd.InOrStdin()), out: os.Stdout}
+		if answers, err = promptInit(p, answers, cmd.Flags().Changed); err != nil {
+			return err
+		}
+	}
+	if answers.RepoURL == "" {
+		return fmt.Errorf("a repository URL is required (--repo-url)")
+	}
+	for _, p := range []*string{&answers.SSHKeyFile, &answers.TokenFile, &answers.QuadletDir, &answers.StateDir} {
+		*p = expandHome(*p)
+	}
+
+	data, err := renderStarterConfig(answers)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !initForce {
+		return fmt.Errorf("%s already exists; use --force to replace it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	fmt.Printf("Wrote %s\n", path)
+
+	if answers.CreateDirs {
+		if err := os.MkdirAll(answers.QuadletDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create quadlet directory: %w", err)
+		}
+		if err := os.MkdirAll(answers.StateDir, 0o700); err != nil {
+			return fmt.Errorf("failed to create state directory: %w", err)
+		}
+		fmt.Printf("Created %s and %s\n", answers.QuadletDir, answers.StateDir)
+	}
+
+	next := []string{
+		fmt.Sprintf("quadsyncd config validate --config %s", path),
+		fmt.Sprintf("quadsyncd sync --dry-run --config %s", path),
+	}
+	if answers.Units {
+		written, err := writeSyncUnits(path)
+		if err != nil {
+			return err
+		}
+		for _, p := range written {
+			fmt.Printf("Wrote %s\n", p)
+		}
+		next = append(next, "systemctl --user daemon-reload", "systemctl --user enable --now "+install.SyncTimerName)
+	}
+
+	fmt.Println("\nNext steps:")
+	for _, step := range next {
+		fmt.Printf("  %s\n", step)
+	}
+	return nil
+}
+
+// expandHome replaces a leading ~/ with the home directory, since answers
+// typed at a prompt do not go through the shell.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// writeSyncUnits renders the sync service and timer for the running binary
+// and configPath into the systemd user unit directory.
+func writeSyncUnits(configPath string) ([]string, error) {
+	binary, err := executablePath()
+	if err != nil {
+		return nil, err
+	}
+	units, err := install.SyncUnits(install.Options{Binary: binary, ConfigPath: configPath})
+	if err != nil {
+		return nil, err
+	}
+	dir, err := install.UserUnitDir()
+	if err != nil {
+		return nil, err
+	}
+	return install.WriteUnits(dir, units, initForce)
+}
+
+// prompter asks questions on out and reads one line answers from in.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints question with def as the default and returns the trimmed answer,
+// or def when the answer is empty or input has ended.
+func (p *prompter) ask(question, def string) (string, error) {
+	if def != "" {
+		_, _ = fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		_, _ = fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// confirm asks a yes/no question.
+func (p *prompter) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer, err := p.ask(question+" ("+hint+")", "")
+	if err != nil || answer == "" {
+		return def, err
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// promptInit asks for every answer whose flag was not set explicitly.
+func promptInit(p *prompter, a initAnswers, changed func(flag string) bool) (initAnswers, error) {
+	var err error
+	askString := func(flag, question string, v *string) {
+		if err == nil && !changed(flag) {
+			*v, err = p.ask(question, *v)
+		}
+	}
+	askBool := func(flag, question string, v *bool, def bool) {
+		if err == nil && !changed(flag) {
+			*v, err = p.confirm(question, def)
+		}
+	}
+
+	askString("repo-url", "Repository URL", &a.RepoURL)
+	askString("ref", "Git ref to track", &a.Ref)
+	askString("subdir", "Subdirectory with quadlet files (empty for the repository root)", &a.Subdir)
+	if a.isSSH() {
+		askString("ssh-key-file", "SSH deploy key file (empty for your default keys)", &a.SSHKeyFile)
+	} else if strings.HasPrefix(a.RepoURL, "https://") {
+		askString("https-token-file", "File holding an access token (empty for a public repository)", &a.TokenFile)
+	}
+	askString("quadlet-dir", "Quadlet directory", &a.QuadletDir)
+	askString("state-dir", "State directory", &a.StateDir)
+	askBool("create-dirs", "Create the quadlet and state directories now?", &a.CreateDirs, true)
+	askBool("units", "Write a systemd timer that syncs every five minutes?", &a.Units, false)
+	return a, err
+}
+
+var starterConfigTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"quote": func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	},
+}).Parse(`# quadsyncd configuration written by ` + "`quadsyncd config init`" + `.
+# See config.example.yaml or the Configuration wiki page for every option.
+
+repository:
+  url: {{quote .RepoURL}}
+  ref: {{quote .Ref}}
+{{- if .Subdir}}
+  subdir: {{quote .Subdir}}
+{{- end}}
+
+paths:
+  # Rootless quadlet directory read by podman's systemd generator
+  quadlet_dir: {{quote .QuadletDir}}
+  # Repository checkouts and the record of files quadsyncd manages
+  state_dir: {{quote .StateDir}}
+{{- if or .SSHKeyFile .TokenFile}}
+
+auth:
+{{- if .SSHKeyFile}}
+  ssh_key_file: {{quote .SSHKeyFile}}
+{{- end}}
+{{- if .TokenFile}}
+  https_token_file: {{quote .TokenFile}}
+{{- end}}
+{{- end}}
+
+sync:
+  # Remove files that quadsyncd installed once they leave the repository
+  prune: true
+  # Restart the units whose quadlet files changed
+  restart: changed
+`))
+
+// renderStarterConfig renders the starter configuration and checks it with
+// the same validation rules as a loaded configuration.
+func renderStarterConfig(a initAnswers) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := starterConfigTemplate.Execute(&buf, a); err != nil {
+		return nil, fmt.Errorf("failed to render config: %w", err)
+	}
+
+	var cfg config.Config
+	if err := yaml.Unmarshal(buf.Bytes(), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse generated config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := cfg.CheckQuadletDir(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/install"
+)
+
+func TestRenderStarterConfig(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", root)
+	base := initAnswers{
+		RepoURL:    "git@github.com:o/r.git",
+		Ref:        "refs/heads/main",
+		QuadletDir: filepath.Join(root, "containers", "systemd"),
+		StateDir:   filepath.Join(root, "state"),
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(a *initAnswers)
+		want    []string
+		wantErr string
+	}{
+		{"ssh with key", func(a *initAnswers) { a.SSHKeyFile = "/k/deploy"; a.Subdir = "quadlets" },
+			[]string{`ssh_key_file: "/k/deploy"`, `subdir: "quadlets"`}, ""},
+		{"public https", func(a *initAnswers) { a.RepoURL = "https://github.com/o/r.git" },
+			[]string{`url: "https://github.com/o/r.git"`}, ""},
+		{"token for ssh url", func(a *initAnswers) { a.TokenFile = "/t" }, nil, "https_token_file"},
+		{"quadlet dir outside roots", func(a *initAnswers) { a.QuadletDir = "/srv/q" }, nil, "extra_quadlet_roots"},
+		{"relative state dir", func(a *initAnswers) { a.StateDir = "state" }, nil, "state_dir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := base
+			tt.mutate(&a)
+			data, err := renderStarterConfig(a)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("renderStarterConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderStarterConfig() error = %v", err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(string(data), w) {
+					t.Errorf("config missing %q:\n%s", w, data)
+				}
+			}
+		})
+	}
+}
+
+func TestPromptInit(t *testing.T) {
+	input := strings.Join([]string{
+		"https://github.com/o/r.git", // repository URL
+		"",                           // ref: keep default
+		"quadlets",                   // subdir
+		"/t/token",                   // token file
+		"",                           // quadlet dir: keep default
+		"/s",                         // state dir
+		"n",                          // create dirs
+		"yes",                        // units
+	}, "\n") + "\n"
+	p := &prompter{in: bufio.NewReader(strings.NewReader(input)), out: io.Discard}
+	defaults := initAnswers{Ref: "refs/heads/main", QuadletDir: "/q"}
+
+	got, err := promptInit(p, defaults, func(string) bool { return false })
+	if err != nil {
+		t.Fatalf("promptInit() error = %v", err)
+	}
+	want := initAnswers{
+		RepoURL: "https://github.com/o/r.git", Ref: "refs/heads/main", Subdir: "quadlets",
+		TokenFile: "/t/token", QuadletDir: "/q", StateDir: "/s", CreateDirs: false, Units: true,
+	}
+	if got != want {
+		t.Errorf("promptInit() = %+v, want %+v", got, want)
+	}
+
+	// Answers given as flags are not asked again; ended input keeps defaults.
+	p = &prompter{in: bufio.NewReader(strings.NewReader("")), out: io.Discard}
+	got, err = promptInit(p, initAnswers{RepoURL: "git@h:o/r.git"}, func(flag string) bool { return flag == "repo-url" })
+	if err != nil || got.RepoURL != "git@h:o/r.git" || !got.CreateDirs || got.Units {
+		t.Errorf("promptInit() = %+v, %v", got, err)
+	}
+}
+
+func TestCLI_ConfigInit(t *testing.T) {
+	origCfg, origAnswers, origNonInteractive, origForce := cfgFile, initAnswersFlags, initNonInteractive, initForce
+	t.Cleanup(func() {
+		cfgFile, initAnswersFlags, initNonInteractive, initForce = origCfg, origAnswers, origNonInteractive, origForce
+		for _, name := range []string{"non-interactive", "repo-url", "quadlet-dir", "state-dir", "create-dirs", "units", "force"} {
+			configInitCmd.Flags().Lookup(name).Changed = false
+		}
+	})
+
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", root)
+	quadletDir := filepath.Join(root, "containers", "systemd")
+	stateDir := filepath.Join(root, "state")
+	cfgFile = filepath.Join(root, "quadsyncd", "config.yaml")
+
+	run := func(extra ...string) error {
+		args := append([]string{"config", "init", "--non-interactive",
+			"--repo-url", "https://github.com/o/r.git",
+			"--quadlet-dir", quadletDir, "--state-dir", stateDir}, extra...)
+		origStdout := os.Stdout
+		os.Stdout, _ = os.Open(os.DevNull)
+		defer func() { os.Stdout = origStdout }()
+		rootCmd.SetArgs(args)
+		return rootCmd.Execute()
+	}
+
+	if err := run("--create-dirs", "--units"); err != nil {
+		t.Fatalf("config init: %v", err)
+	}
+	for _, path := range []string{cfgFile, quadletDir, stateDir,
+		filepath.Join(root, "systemd", "user", install.SyncServiceName),
+		filepath.Join(root, "systemd", "user", install.SyncTimerName)} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s: %v", path, err)
+		}
+	}
+
+	// The generated file passes the same validation as config validate.
+	report := validateConfigFile(cfgFile)
+	if !report.Valid {
+		t.Errorf("generated config is invalid: %+v", report.Checks)
+	}
+
+	if err := run(); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second init error = %v, want already exists", err)
+	}
+	if err := run("--force", "--units"); err != nil {
+		t.Errorf("init --force: %v", err)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	for in, want := range map[string]string{
+		"~/.ssh/key": "/home/u/.ssh/key",
+		"/abs/key":   "/abs/key",
+		"~other/key": "~other/key",
+	} {
+		if got := expandHome(in); got != want {
+			t.Errorf("expandHome(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+	"github.com/spf13/cobra"
+)
+
+// Doctor command flags
+var doctorOutput string
+
+// newDoctorSystemd builds the client doctor asks the user manager through;
+// replaced in tests.
+var newDoctorSystemd = func(cfg *config.Config, logger *slog.Logger) systemduser.Systemd {
+	return sync.NewSystemdClient(cfg, logger)
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the configuration and the systemd user session",
+	Long: `Doctor runs the checks of config validate and then checks the systemd user
+session quadsyncd depends on: XDG_RUNTIME_DIR is set and exists, the user bus
+socket exists, lingering is enabled so the user manager keeps running without
+a login, and the user manager answers. Each failed check says how to fix it.
+It changes nothing.
+
+Run it when a sync fails with "systemd user session not available", typically
+after switching users with su or sudo, or after logging out on a host without
+lingering.
+
+Exit codes:
+  0  all checks passed; warnings may have been reported
+  1  an error occurred
+  2  the configuration does not load or a check failed`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func init() {
+	doctorCmd.Flags().StringVarP(&doctorOutput, "output", "o", outputText, "output format: text or json")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(doctorOutput); err != nil {
+		return err
+	}
+	if doctorOutput == outputJSON {
+		logsToStderr = true
+	}
+	logger := setupLogger()
+
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	report := validateConfigFile(path)
+
+	// The session checks do not depend on the configuration; run them with
+	// the defaults when it does not load.
+	cfg, err := config.Load(path)
+	if err != nil {
+		cfg = &config.Config{}
+	}
+	systemd := newDoctorSystemd(cfg, logger)
+	report.Checks = append(report.Checks, sync.CheckSession(context.Background(), systemd, cfg.Timeouts.Systemctl)...)
+	report.Checks = append(report.Checks, sync.CheckPodman(context.Background(), systemd)...)
+	errs, _ := report.problems()
+	report.Valid = errs == 0
+
+	if doctorOutput == outputJSON {
+		err = writeConfigReport(os.Stdout, report)
+	} else {
+		err = printConfigReport(os.Stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !report.Valid {
+		logger.Warn("doctor found problems", "path", path, "errors", errs)
+		cmd.SilenceErrors = true
+		return &exitError{code: exitCodeConfigInvalid}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestCLI_Doctor(t *testing.T) {
+	origCfg, origOut, origLogsToStderr, origSystemd := cfgFile, doctorOutput, logsToStderr, newDoctorSystemd
+	t.Cleanup(func() {
+		cfgFile, doctorOutput, logsToStderr, newDoctorSystemd = origCfg, origOut, origLogsToStderr, origSystemd
+	})
+	newDoctorSystemd = func(*config.Config, *slog.Logger) systemduser.Systemd {
+		return &testutil.MockSystemd{}
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+
+	tmpDir := t.TempDir()
+	cfgFile = writeTempConfig(t, tmpDir)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	origStdout := os.Stdout
+	os.Stdout = w
+	doctorOutput = outputText
+	rootCmd.SetArgs([]string{"doctor", "--output", "json"})
+	execErr := rootCmd.Execute()
+	_ = w.Close()
+	os.Stdout = origStdout
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+
+	var exitErr *exitError
+	if !errors.As(execErr, &exitErr) || exitErr.code != exitCodeConfigInvalid {
+		t.Fatalf("expected exit code %d, got %v", exitCodeConfigInvalid, execErr)
+	}
+	var report configReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	statuses := make(map[string]config.CheckStatus)
+	for _, c := range report.Checks {
+		statuses[c.Field] = c.Status
+	}
+	want := map[string]config.CheckStatus{
+		"paths.quadlet_dir":       config.CheckOK,
+		"session.xdg_runtime_dir": config.CheckError,
+		"session.user_manager":    config.CheckError,
+	}
+	for field, status := range want {
+		if statuses[field] != status {
+			t.Errorf("%s = %q, want %q; report: %+v", field, statuses[field], status, report)
+		}
+	}
+	if report.Valid {
+		t.Error("report is valid without a user session")
+	}
+}
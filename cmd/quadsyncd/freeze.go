@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// Freeze command flags
+var freezeReason string
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "Stop syncs from applying changes until unfreeze",
+	Long: `Freeze pauses applying changes. Syncs from the timer, webhooks or the
+command line still fetch the repositories and compute the plan, so pending
+changes show up as a sync_frozen warning in the run and history, but no
+files, secrets or units are touched. The freeze is stored in the state
+directory and survives restarts; "quadsyncd unfreeze" lifts it.
+Freezing again keeps the original start time and replaces the reason.`,
+	Args: cobra.NoArgs,
+	RunE: runFreeze,
+}
+
+var unfreezeCmd = &cobra.Command{
+	Use:   "unfreeze",
+	Short: "Let syncs apply changes again after freeze",
+	Long: `Unfreeze lifts a freeze. The next sync applies every change that was held
+back.`,
+	Args: cobra.NoArgs,
+	RunE: runUnfreeze,
+}
+
+func init() {
+	freezeCmd.Flags().StringVar(&freezeReason, "reason", "", "why syncs are frozen, shown in logs and the overview API")
+	rootCmd.AddCommand(freezeCmd)
+	rootCmd.AddCommand(unfreezeCmd)
+}
+
+func runFreeze(cmd *cobra.Command, args []string) error {
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	freeze, err := sync.SetFreeze(cfg.Paths.StateDir, freezeReason, time.Now())
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Syncs frozen since %s\n", freeze.Since.Local().Format(time.RFC3339))
+	return nil
+}
+
+func runUnfreeze(cmd *cobra.Command, args []string) error {
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	cleared, err := sync.ClearFreeze(cfg.Paths.StateDir)
+	if err != nil {
+		return err
+	}
+	if !cleared {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Syncs were not frozen.")
+		return nil
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Syncs unfrozen; the next sync applies pending changes.")
+	return nil
+}
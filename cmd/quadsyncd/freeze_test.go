@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestCLI_FreezeUnfreeze(t *testing.T) {
+	origCfg, origReason := cfgFile, freezeReason
+	t.Cleanup(func() {
+		cfgFile, freezeReason = origCfg, origReason
+		freezeCmd.Flags().Lookup("reason").Changed = false
+	})
+	tmpDir := t.TempDir()
+	cfgFile = writeTempConfig(t, tmpDir)
+	stateDir := filepath.Join(tmpDir, "state")
+
+	t.Cleanup(func() { rootCmd.SetOut(nil) })
+
+	run := func(args ...string) string {
+		t.Helper()
+		origStdout := os.Stdout
+		os.Stdout, _ = os.Open(os.DevNull)
+		defer func() { os.Stdout = origStdout }()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	out := run("freeze", "--reason", "release")
+	f, err := sync.ReadFreeze(stateDir)
+	if err != nil || f == nil || f.Reason != "release" {
+		t.Fatalf("after freeze: %+v, %v", f, err)
+	}
+	if want := "Syncs frozen since " + f.Since.Local().Format(time.RFC3339) + "\n"; out != want {
+		t.Errorf("freeze output = %q, want %q", out, want)
+	}
+	if out := run("unfreeze"); out != "Syncs unfrozen; the next sync applies pending changes.\n" {
+		t.Errorf("unfreeze output = %q", out)
+	}
+	if f, err := sync.ReadFreeze(stateDir); err != nil || f != nil {
+		t.Errorf("after unfreeze: %+v, %v", f, err)
+	}
+	if out := run("unfreeze"); out != "Syncs were not frozen.\n" {
+		t.Errorf("second unfreeze output = %q", out)
+	}
+}
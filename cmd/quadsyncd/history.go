@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// History command flags
+var historyLimit int
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent sync runs",
+	Long: `History lists recent applied sync runs from the state directory, newest
+first, with the synced commit, change counts, result and duration.`,
+	Args: cobra.NoArgs,
+	RunE: runHistory,
+}
+
+func init() {
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "number of runs to show (0 for all)")
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	entries, err := sync.ReadHistory(cfg.Paths.StateDir, historyLimit)
+	if err != nil {
+		return err
+	}
+	return printHistory(os.Stdout, entries)
+}
+
+// printHistory writes a table of sync runs in the given order.
+func printHistory(w io.Writer, entries []sync.HistoryEntry) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(w, "No sync runs recorded.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STARTED\tCOMMIT\tADD\tUPDATE\tDELETE\tRESTARTED\tRESULT\tDURATION")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			e.StartedAt.Local().Format(time.RFC3339),
+			historyCommit(e),
+			e.Added, e.Updated, e.Deleted, e.Restarted,
+			e.Result,
+			e.Duration().Round(time.Millisecond))
+		if e.Error != "" {
+			_, _ = fmt.Fprintf(tw, "\t  error: %s\n", e.Error)
+		} else if len(e.Unhealthy) > 0 {
+			_, _ = fmt.Fprintf(tw, "\t  unhealthy: %s\n", strings.Join(e.Unhealthy, ", "))
+		}
+		if len(e.RestartFailed) > 0 {
+			_, _ = fmt.Fprintf(tw, "\t  restart failed: %s\n", strings.Join(e.RestartFailed, ", "))
+		}
+		if len(e.AutoUpdated) > 0 {
+			_, _ = fmt.Fprintf(tw, "\t  auto-updated: %s\n", strings.Join(e.AutoUpdated, ", "))
+		}
+	}
+	return tw.Flush()
+}
+
+// historyCommit returns a short commit label for a history entry, followed by
+// the ref given to sync --ref for runs that overrode the configured ref.
+func historyCommit(e sync.HistoryEntry) string {
+	label := "-"
+	switch {
+	case e.Commit != "":
+		label = shortSHA(e.Commit)
+	case len(e.Revisions) > 0:
+		label = fmt.Sprintf("%d repos", len(e.Revisions))
+	}
+	if e.Ref != "" {
+		label += " (" + e.Ref + ")"
+	}
+	return label
+}
+
+// shortSHA abbreviates a commit SHA to 12 characters.
+func shortSHA(sha string) string {
+	sha = strings.TrimSpace(sha)
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestPrintHistory(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printHistory(&buf, nil); err != nil {
+		t.Fatalf("printHistory: %v", err)
+	}
+	if !strings.Contains(buf.String(), "No sync runs recorded.") {
+		t.Errorf("empty output = %q", buf.String())
+	}
+
+	buf.Reset()
+	entries := []sync.HistoryEntry{
+		{
+			StartedAt:  time.Date(2026, 1, 2, 3, 5, 0, 0, time.UTC),
+			Revisions:  map[string]string{"a": "1", "b": "2"},
+			Result:     sync.HistoryResultError,
+			Error:      "fetch failed",
+			DurationMS: 20,
+		},
+		{
+			StartedAt:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			Commit:        "0123456789abcdef",
+			Ref:           "v1.2.0",
+			Added:         2,
+			Updated:       1,
+			Restarted:     3,
+			Unhealthy:     []string{"web.service"},
+			RestartFailed: []string{"db.service"},
+			Result:        sync.HistoryResultSuccess,
+			DurationMS:    1500,
+		},
+	}
+	if err := printHistory(&buf, entries); err != nil {
+		t.Fatalf("printHistory: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"STARTED", "2 repos", "fetch failed", "0123456789ab (v1.2.0)", "success", "1.5s", "unhealthy: web.service", "restart failed: db.service"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "0123456789abcdef") {
+		t.Errorf("commit should be abbreviated:\n%s", out)
+	}
+}
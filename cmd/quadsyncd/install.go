@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/schaermu/quadsyncd/internal/install"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+	"github.com/spf13/cobra"
+)
+
+// Install command flags
+var (
+	installWebhook  bool
+	installSocket   bool
+	installNoEnable bool
+	installForce    bool
+)
+
+var installCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Write and enable the systemd user units that run quadsyncd",
+	Long: `Install writes systemd user units for this quadsyncd binary and configuration
+file to $XDG_CONFIG_HOME/systemd/user, reloads the user manager and enables
+them:
+
+  default      quadsyncd-sync.service and quadsyncd-sync.timer; the timer is
+               enabled and syncs every five minutes
+  --webhook    quadsyncd-webhook.service, enabled and started
+  --socket     with --webhook, also quadsyncd-webhook.socket listening on
+               serve.listen_addr; the socket is enabled instead of the service
+
+The configuration is validated first. Existing unit files are only replaced
+with --force. Run "quadsyncd uninstall" to remove the units again.`,
+	Args: cobra.NoArgs,
+	RunE: runInstall,
+}
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop, disable and remove the systemd user units written by install",
+	Long: `Uninstall stops and disables every quadsyncd unit found in
+$XDG_CONFIG_HOME/systemd/user (the sync service and timer and the webhook
+service and socket), removes their files and reloads the user manager. The
+configuration, state and synced quadlet files are left in place.`,
+	Args: cobra.NoArgs,
+	RunE: runUninstall,
+}
+
+func init() {
+	installCmd.Flags().BoolVar(&installWebhook, "webhook", false, "install the webhook server instead of the sync timer")
+	installCmd.Flags().BoolVar(&installSocket, "socket", false, "with --webhook, start the server through socket activation on serve.listen_addr")
+	installCmd.Flags().BoolVar(&installNoEnable, "no-enable", false, "write the units and reload systemd without enabling them")
+	installCmd.Flags().BoolVar(&installForce, "force", false, "replace existing unit files")
+	rootCmd.AddCommand(installCmd)
+	rootCmd.AddCommand(uninstallCmd)
+}
+
+func runInstall(cmd *cobra.Command, args []string) error {
+	if installSocket && !installWebhook {
+		return fmt.Errorf("--socket requires --webhook")
+	}
+
+	ctx, cancel := setupSignalHandler()
+	defer cancel()
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if installWebhook && !cfg.Serve.Enabled {
+		return fmt.Errorf("--webhook requires serve.enabled: true in the configuration")
+	}
+
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	binary, err := executablePath()
+	if err != nil {
+		return err
+	}
+	opts := install.Options{Binary: binary, ConfigPath: path, ListenAddr: cfg.Serve.ListenAddr}
+
+	var units []install.Unit
+	var enable []string
+	switch {
+	case installSocket:
+		units, err = install.WebhookUnits(opts, true)
+		enable = []string{install.WebhookSocketName}
+	case installWebhook:
+		units, err = install.WebhookUnits(opts, false)
+		enable = []string{install.WebhookServiceName}
+	default:
+		units, err = install.SyncUnits(opts)
+		enable = []string{install.SyncTimerName}
+	}
+	if err != nil {
+		return err
+	}
+	if installNoEnable {
+		enable = nil
+	}
+
+	dir, err := install.UserUnitDir()
+	if err != nil {
+		return err
+	}
+	client := systemduser.NewClientWithTimeouts(logger, cfg.Timeouts.Systemctl, cfg.Timeouts.Podman)
+	paths, err := install.Install(ctx, client, dir, units, enable, installForce)
+	for _, p := range paths {
+		fmt.Printf("Wrote %s\n", p)
+	}
+	if err != nil {
+		return err
+	}
+	for _, u := range enable {
+		fmt.Printf("Enabled and started %s\n", u)
+	}
+	fmt.Println("\nTo keep the units running after you log out, run: loginctl enable-linger $USER")
+	return nil
+}
+
+func runUninstall(cmd *cobra.Command, args []string) error {
+	ctx, cancel := setupSignalHandler()
+	defer cancel()
+	logger := setupLogger()
+
+	dir, err := install.UserUnitDir()
+	if err != nil {
+		return err
+	}
+	removed, err := install.Uninstall(ctx, systemduser.NewClient(logger), dir)
+	if err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		fmt.Printf("No quadsyncd units installed in %s\n", dir)
+		return nil
+	}
+	for _, p := range removed {
+		fmt.Printf("Removed %s\n", p)
+	}
+	return nil
+}
+
+// executablePath returns the absolute path of the running quadsyncd binary
+// with symlinks resolved, for use in unit files.
+func executablePath() (string, error) {
+	binary, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the quadsyncd binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+	return binary, nil
+}
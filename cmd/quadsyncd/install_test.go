@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCLI_Install_FlagChecks(t *testing.T) {
+	origCfg, origWebhook, origSocket := cfgFile, installWebhook, installSocket
+	t.Cleanup(func() {
+		cfgFile, installWebhook, installSocket = origCfg, origWebhook, origSocket
+		installCmd.Flags().Lookup("webhook").Changed = false
+		installCmd.Flags().Lookup("socket").Changed = false
+	})
+	cfgFile = writeTempConfig(t, t.TempDir())
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"socket without webhook", []string{"install", "--socket"}, "--socket requires --webhook"},
+		{"webhook without serve", []string{"install", "--webhook"}, "serve.enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installWebhook, installSocket = false, false
+			rootCmd.SetArgs(tt.args)
+			err := rootCmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
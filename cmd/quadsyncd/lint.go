@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+	"github.com/spf13/cobra"
+)
+
+// exitCodeLintFailed is returned by `lint` when at least one file has an
+// error-level issue.
+const exitCodeLintFailed = 2
+
+// Lint command flags
+var lintOutput string
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [path...]",
+	Short: "Check quadlet files for mistakes before they are synced",
+	Long: `Lint parses quadlet files and reports problems that would otherwise only
+show up when systemd generates the units: syntax errors, a missing Image=,
+EnvironmentFile= or Yaml= references to files that do not exist, and keys
+the quadlet section does not know.
+
+Each path is a quadlet file or a directory searched recursively, skipping
+hidden directories. Without arguments the current directory is checked.
+Lint does not read the configuration and can run in CI on the repository.
+
+Exit codes:
+  0  no errors (warnings may have been printed)
+  1  an error occurred
+  2  at least one file has an error`,
+	RunE: runLint,
+}
+
+func init() {
+	lintCmd.Flags().StringVarP(&lintOutput, "output", "o", outputText, "output format: text or json")
+	rootCmd.AddCommand(lintCmd)
+}
+
+// lintReport is the result of a lint run.
+type lintReport struct {
+	Files  int             `json:"files"`
+	Issues []quadlet.Issue `json:"issues"`
+}
+
+// errors returns the number of error-level issues.
+func (r lintReport) errors() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == quadlet.SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(lintOutput); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	report, err := lintPaths(args)
+	if err != nil {
+		return err
+	}
+	if lintOutput == outputJSON {
+		err = writeLintReport(os.Stdout, report)
+	} else {
+		err = printLintReport(os.Stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.errors() > 0 {
+		cmd.SilenceErrors = true
+		return &exitError{code: exitCodeLintFailed}
+	}
+	return nil
+}
+
+// lintPaths lints every quadlet file in paths.
+func lintPaths(paths []string) (lintReport, error) {
+	report := lintReport{Issues: []quadlet.Issue{}}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return report, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			all, err := quadlet.DiscoverAllFiles(path)
+			if err != nil {
+				return report, fmt.Errorf("failed to scan %s: %w", path, err)
+			}
+			files = files[:0]
+			for _, f := range all {
+				if quadlet.IsQuadletFile(f) {
+					files = append(files, f)
+				}
+			}
+		}
+		for _, f := range files {
+			report.Files++
+			report.Issues = append(report.Issues, quadlet.LintFile(f)...)
+		}
+	}
+	return report, nil
+}
+
+// printLintReport writes one line per issue followed by a summary.
+func printLintReport(w io.Writer, report lintReport) error {
+	for _, issue := range report.Issues {
+		if _, err := fmt.Fprintln(w, issue); err != nil {
+			return err
+		}
+	}
+	errs := report.errors()
+	_, err := fmt.Fprintf(w, "%d file(s) checked, %d error(s), %d warning(s)\n", report.Files, errs, len(report.Issues)-errs)
+	return err
+}
+
+// writeLintReport encodes report as indented JSON followed by a newline.
+func writeLintReport(w io.Writer, report lintReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write lint report: %w", err)
+	}
+	return nil
+}
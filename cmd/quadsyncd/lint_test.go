@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_Lint(t *testing.T) {
+	origOut := lintOutput
+	t.Cleanup(func() { lintOutput = origOut })
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"web.container":     "[Container]\nImage=nginx\nPublishPorts=80:80\n",
+		"sub/db.container":  "[Container]\nImage=postgres\n",
+		".hidden/x.network": "[Network]\nBogus=1\n",
+		"README.md":         "not a quadlet\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(args ...string) (string, error) {
+		origStdout := os.Stdout
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("os.Pipe: %v", err)
+		}
+		os.Stdout = w
+		lintOutput = outputText
+		rootCmd.SetArgs(append([]string{"lint"}, args...))
+		execErr := rootCmd.Execute()
+		_ = w.Close()
+		os.Stdout = origStdout
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(r)
+		return buf.String(), execErr
+	}
+
+	// Warnings alone do not fail.
+	out, err := run(dir)
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+	if !strings.Contains(out, "unknown key PublishPorts") || !strings.Contains(out, "2 file(s) checked, 0 error(s), 1 warning(s)") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	// An error fails with exit code 2, also in JSON mode.
+	broken := filepath.Join(dir, "broken.container")
+	if err := os.WriteFile(broken, []byte("[Container]\nExec=true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = run("--output", "json", broken)
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != exitCodeLintFailed {
+		t.Fatalf("expected exit code %d, got %v", exitCodeLintFailed, err)
+	}
+	var report lintReport
+	if jerr := json.Unmarshal([]byte(out), &report); jerr != nil {
+		t.Fatalf("invalid JSON: %v\n%s", jerr, out)
+	}
+	if report.Files != 1 || report.errors() != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
@@ -2,23 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
 	"time"
 
-	"github.com/schaermu/quadsyncd/internal/activation"
 	"github.com/schaermu/quadsyncd/internal/config"
-	"github.com/schaermu/quadsyncd/internal/git"
 	"github.com/schaermu/quadsyncd/internal/logging"
 	"github.com/schaermu/quadsyncd/internal/runstore"
 	"github.com/schaermu/quadsyncd/internal/server"
 	"github.com/schaermu/quadsyncd/internal/service"
 	"github.com/schaermu/quadsyncd/internal/sync"
-	"github.com/schaermu/quadsyncd/internal/systemduser"
+	"github.com/schaermu/quadsyncd/internal/systemdproto"
 	"github.com/spf13/cobra"
 )
 
@@ -34,12 +34,31 @@ var (
 	logFormat string
 	dryRun    bool
 
+	// allowUnmanagedDelete confirms sync.prune_scope all for sync, plan and
+	// serve; it is copied into the loaded config.
+	allowUnmanagedDelete bool
+
+	// Sync command flags
+	outputFormat  string
+	failOnWarning bool
+	syncRef       string
+	syncRepo      string
+	syncForce     bool
+
+	// logsToStderr moves log output off stdout for commands whose stdout is a
+	// machine- or human-readable result (sync --output json, plan).
+	logsToStderr bool
+
 	// Serve command flags
 	skipInitialSync bool
 )
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
@@ -55,6 +74,14 @@ daemon that responds to GitHub push events.`,
 	SilenceUsage: true,
 }
 
+// exitCodeSyncWarnings is returned by `sync --fail-on-warning` when the run
+// succeeded but recorded at least one warning.
+const exitCodeSyncWarnings = 3
+
+// exitCodeUnitsUnhealthy is returned by `sync` when sync.health_check.fail is
+// set and a restarted or started unit failed after the sync.
+const exitCodeUnitsUnhealthy = 4
+
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Perform a one-time sync from repository to quadlet directory",
@@ -62,7 +89,12 @@ var syncCmd = &cobra.Command{
 local state, and applies changes to the systemd user quadlet directory.
 
 After syncing files, it reloads the systemd daemon and optionally restarts
-affected units based on the configured restart policy.`,
+affected units based on the configured restart policy.
+
+--ref syncs a branch, tag or commit instead of the configured ref for this
+run only. The ref that was applied is recorded in the state and history; the
+next sync without --ref returns to the configured ref. To pin a commit, set
+repo.ref instead.`,
 	RunE: runSync,
 }
 
@@ -86,17 +118,27 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+// allowUnmanagedDeleteUsage is the help text of --allow-unmanaged-delete.
+const allowUnmanagedDeleteUsage = "let sync.prune_scope all delete files in the quadlet directory that quadsyncd never wrote"
+
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/quadsyncd/config.yaml)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
-	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format (text, json)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format (text, json, journald)")
 
 	// Sync command flags
 	syncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would be done without making changes")
+	syncCmd.Flags().StringVar(&outputFormat, "output", outputText, "result output format (text, json); json prints a result document to stdout and moves logs to stderr")
+	syncCmd.Flags().BoolVar(&failOnWarning, "fail-on-warning", false, fmt.Sprintf("exit with status %d when the sync succeeds but records warnings", exitCodeSyncWarnings))
+	syncCmd.Flags().BoolVar(&allowUnmanagedDelete, "allow-unmanaged-delete", false, allowUnmanagedDeleteUsage)
+	syncCmd.Flags().StringVar(&syncRef, "ref", "", "check out this branch, tag or commit instead of the configured ref for this run")
+	syncCmd.Flags().StringVar(&syncRepo, "repo", "", "repository URL --ref applies to (required with several repositories)")
+	syncCmd.Flags().BoolVar(&syncForce, "force", false, "build and apply a plan even when nothing changed since the last sync")
 
 	// Serve command flags
 	serveCmd.Flags().BoolVar(&skipInitialSync, "skip-initial-sync", false, "skip the initial sync on startup (useful for local testing)")
+	serveCmd.Flags().BoolVar(&allowUnmanagedDelete, "allow-unmanaged-delete", false, allowUnmanagedDeleteUsage)
 
 	// Add commands
 	rootCmd.AddCommand(syncCmd)
@@ -105,6 +147,11 @@ func init() {
 }
 
 func runSync(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(outputFormat); err != nil {
+		return err
+	}
+	logsToStderr = outputFormat == outputJSON
+
 	ctx, cancel := setupSignalHandler()
 	defer cancel()
 
@@ -117,6 +164,11 @@ func runSync(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	overrides, err := syncRefOverrides(cfg, syncRef, syncRepo)
+	if err != nil {
+		return err
+	}
+
 	// Initialize runstore
 	store := runstore.NewStore(cfg.Paths.StateDir, consoleLogger)
 
@@ -143,7 +195,7 @@ func runSync(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create run record: %w", err)
 	}
 
-	consoleLogger.Info("created run record", "run_id", meta.ID)
+	consoleLogger.Info("created run record", logging.Event(logging.EventRunCreated), logging.KeyRunID, meta.ID)
 
 	// Parse log level for ndjson handler
 	var ndjsonLevel slog.Level
@@ -170,18 +222,13 @@ func runSync(cmd *cobra.Command, args []string) error {
 	teeHandler := logging.NewTeeHandler(consoleLogger.Handler(), ndjsonHandler)
 	logger := slog.New(teeHandler)
 
-	// Create dependencies
-	factory := func(auth config.AuthConfig) git.Client {
-		return git.NewShellClient(auth.SSHKeyFile, auth.HTTPSTokenFile, logger)
-	}
-	systemdClient := systemduser.NewClient(logger)
-
 	// Create sync engine with tee logger
-	engine := sync.NewEngineWithFactory(cfg, factory, systemdClient, logger, dryRun)
+	engine := sync.New(cfg, sync.WithLogger(logger), sync.WithDryRun(dryRun), sync.WithSpecOverrides(overrides), sync.WithForce(syncForce))
 
 	// Run sync
 	logger.Info("starting sync operation")
-	result, syncErr := engine.Run(ctx)
+	ctx = sync.WithActor(ctx, sync.Actor{Trigger: string(trigger), Command: "sync", RunID: meta.ID})
+	result, syncErr := sync.RunWithTimeout(ctx, engine, cfg.Sync.Timeout)
 
 	// Finalize run metadata
 	endedAt := time.Now().UTC()
@@ -190,7 +237,8 @@ func runSync(cmd *cobra.Command, args []string) error {
 	if syncErr != nil {
 		meta.Status = runstore.RunStatusError
 		meta.Error = syncErr.Error()
-		logger.Error("sync failed", "error", syncErr)
+		meta.ErrorKind = sync.ErrorKind(syncErr)
+		logger.Error("sync failed", logging.Event(logging.EventSyncFailed), "error", syncErr, logging.KeyErrorKind, meta.ErrorKind)
 	} else {
 		meta.Status = runstore.RunStatusSuccess
 		logger.Info("sync completed successfully")
@@ -203,6 +251,7 @@ func runSync(cmd *cobra.Command, args []string) error {
 		for i, c := range result.Conflicts {
 			meta.Conflicts[i] = service.ConflictSummaryFromSync(c)
 		}
+		meta.Warnings = service.WarningSummariesFromSync(result.Warnings)
 	}
 
 	// Update run metadata with final state
@@ -210,7 +259,49 @@ func runSync(cmd *cobra.Command, args []string) error {
 		logger.Error("failed to update run record", "error", err)
 	}
 
-	return syncErr
+	if outputFormat == outputJSON {
+		report := newSyncReport(meta, result, cfg.Paths.QuadletDir)
+		if err := writeSyncReport(os.Stdout, report); err != nil {
+			logger.Error("failed to print sync report", "error", err)
+		}
+	}
+
+	if errors.Is(syncErr, sync.ErrUnitsUnhealthy) {
+		cmd.SilenceErrors = true
+		return &exitError{code: exitCodeUnitsUnhealthy}
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+	if failOnWarning && len(meta.Warnings) > 0 {
+		cmd.SilenceErrors = true
+		return &exitError{code: exitCodeSyncWarnings}
+	}
+	return nil
+}
+
+// syncRefOverrides returns the override that makes sync check out ref in the
+// repository with URL repoURL, which may be omitted with a single repository.
+func syncRefOverrides(cfg *config.Config, ref, repoURL string) (map[string]sync.SpecOverride, error) {
+	if ref == "" {
+		if repoURL != "" {
+			return nil, fmt.Errorf("--repo requires --ref")
+		}
+		return nil, nil
+	}
+	repos := cfg.EffectiveRepositories()
+	if repoURL == "" {
+		if len(repos) != 1 {
+			return nil, fmt.Errorf("--ref requires --repo when %d repositories are configured", len(repos))
+		}
+		repoURL = repos[0].URL
+	}
+	for _, r := range repos {
+		if r.URL == repoURL {
+			return map[string]sync.SpecOverride{repoURL: {Ref: ref}}, nil
+		}
+	}
+	return nil, fmt.Errorf("no configured repository has URL %q", repoURL)
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
@@ -235,11 +326,8 @@ func runServe(cmd *cobra.Command, args []string) error {
 	store := runstore.NewStore(cfg.Paths.StateDir, logger)
 
 	// Create dependencies
-	gitFactory := func(auth config.AuthConfig) git.Client {
-		return git.NewShellClient(auth.SSHKeyFile, auth.HTTPSTokenFile, logger)
-	}
-	systemdClient := systemduser.NewClient(logger)
-	runnerFactory := sync.NewRunnerFactory(gitFactory, systemdClient)
+	systemdClient := sync.NewSystemdClient(cfg, logger)
+	runnerFactory := sync.NewRunnerFactory(sync.NewGitClientFactory(cfg, logger), systemdClient)
 
 	// Create webhook server
 	server, err := server.NewServer(cfg, runnerFactory, systemdClient, store, logger)
@@ -250,20 +338,16 @@ func runServe(cmd *cobra.Command, args []string) error {
 	if skipInitialSync {
 		server.SetSkipInitialSync(true)
 	}
+	server.SetConfigLoader(func() (*config.Config, error) { return loadConfig(logger) })
+	go reloadOnSIGHUP(ctx, server.Reload)
 
 	// Check for systemd socket activation
-	listeners, err := activation.Listeners()
+	listeners, err := systemdproto.Listeners()
 	if err != nil {
 		return fmt.Errorf("failed to check for socket activation: %w", err)
 	}
 
-	if len(listeners) > 0 {
-		// Socket activation mode
-		if len(listeners) > 1 {
-			return fmt.Errorf("received %d socket-activated listeners, expected exactly 1", len(listeners))
-		}
-
-		listener := listeners[0]
+	if listener := activationListener(listeners, logger); listener != nil {
 		logger.Info("using systemd socket activation", "addr", listener.Addr().String(), "mode", "socket-activated")
 		if err := server.StartWithListener(ctx, listener); err != nil {
 			logger.Error("webhook server failed", "error", err)
@@ -282,6 +366,24 @@ func runServe(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// activationListener returns the socket-activated listener to serve on, or
+// nil when the server should bind serve.listen_addr itself: when systemd
+// passed no socket, or more than one. Several sockets are closed, since it is
+// not clear which one the webhook server is meant to take.
+func activationListener(listeners []net.Listener, logger *slog.Logger) net.Listener {
+	if len(listeners) == 1 {
+		return listeners[0]
+	}
+	if len(listeners) > 1 {
+		logger.Warn("ignoring socket-activated listeners, expected exactly 1; binding serve.listen_addr instead",
+			"count", len(listeners), "mode", "bind")
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}
+	return nil
+}
+
 func setupLogger() *slog.Logger {
 	// Parse log level
 	var level slog.Level
@@ -302,24 +404,58 @@ func setupLogger() *slog.Logger {
 	var handler slog.Handler
 	opts := &slog.HandlerOptions{Level: level}
 
-	if logFormat == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
-	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+	// Keep stdout clean for result documents and plan listings.
+	out := os.Stdout
+	if logsToStderr {
+		out = os.Stderr
 	}
 
-	return slog.New(handler)
+	var journalErr error
+	switch logFormat {
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	case "journald":
+		// Every attribute becomes a SYNC_ journal field. Without a journal,
+		// fall back to text so logs are not lost.
+		handler = slog.NewTextHandler(out, opts)
+		var jh *logging.JournalHandler
+		if jh, journalErr = logging.NewJournalHandler(logging.JournalSocket, level, handler); journalErr == nil {
+			handler = jh.WithStructuredFields()
+		}
+	default:
+		handler = slog.NewTextHandler(out, opts)
+		// Under systemd, send text logs to journald natively so entries about
+		// a unit can carry its journal fields.
+		if logging.JournalStreamConnected(out) {
+			if jh, err := logging.NewJournalHandler(logging.JournalSocket, level, handler); err == nil {
+				handler = jh
+			}
+		}
+	}
+
+	logger := slog.New(handler)
+	if journalErr != nil {
+		logger.Warn("journald log format unavailable, logging text instead", "error", journalErr)
+	}
+	return logger
+}
+
+// configPath returns the --config path, or the default under ~/.config.
+func configPath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "quadsyncd", "config.yaml"), nil
 }
 
 func loadConfig(logger *slog.Logger) (*config.Config, error) {
-	// Determine config file path
-	configPath := cfgFile
-	if configPath == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get user home directory: %w", err)
-		}
-		configPath = filepath.Join(home, ".config", "quadsyncd", "config.yaml")
+	configPath, err := configPath()
+	if err != nil {
+		return nil, err
 	}
 
 	logger.Info("loading configuration", "path", configPath)
@@ -328,6 +464,7 @@ func loadConfig(logger *slog.Logger) (*config.Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	cfg.Sync.AllowUnmanagedDelete = allowUnmanagedDelete
 
 	logger.Debug("configuration loaded",
 		"repositories", len(cfg.EffectiveRepositories()),
@@ -337,6 +474,22 @@ func loadConfig(logger *slog.Logger) (*config.Config, error) {
 	return cfg, nil
 }
 
+// reloadOnSIGHUP calls reload for every SIGHUP until ctx is done. Failures are
+// logged by the server, which keeps its running configuration.
+func reloadOnSIGHUP(ctx context.Context, reload func() (*server.ReloadResult, error)) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			_, _ = reload()
+		}
+	}
+}
+
 func setupSignalHandler() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -3,11 +3,17 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/sync"
 )
 
 func TestSetupLogger(t *testing.T) {
@@ -26,6 +32,7 @@ func TestSetupLogger(t *testing.T) {
 	}{
 		{name: "debug/text", logLevel: "debug", logFormat: "text"},
 		{name: "info/json", logLevel: "info", logFormat: "json"},
+		{name: "info/journald", logLevel: "info", logFormat: "journald"},
 		{name: "warn/text", logLevel: "warn", logFormat: "text"},
 		{name: "error/text", logLevel: "error", logFormat: "text"},
 		{name: "unknown/text", logLevel: "unknown", logFormat: "text"},
@@ -57,6 +64,7 @@ func TestLoadConfig_WithExplicitPath(t *testing.T) {
 paths:
   quadlet_dir: "` + quadletDir + `"
   state_dir: "` + stateDir + `"
+  extra_quadlet_roots: ["` + tmpDir + `"]
 sync:
   prune: true
   restart: "changed"
@@ -143,6 +151,7 @@ func writeTempConfig(t *testing.T, tmpDir string) string {
 paths:
   quadlet_dir: "` + quadletDir + `"
   state_dir: "` + stateDir + `"
+  extra_quadlet_roots: ["` + tmpDir + `"]
 sync:
   prune: false
   restart: "none"
@@ -299,3 +308,77 @@ func TestCLI_LogLevelFlag(t *testing.T) {
 		t.Error("expected logger to be enabled at Debug level when --log-level debug")
 	}
 }
+
+func TestSyncRefOverrides(t *testing.T) {
+	single := &config.Config{Repository: &config.RepoSpec{URL: "https://h/a.git", Ref: "refs/heads/main"}}
+	multi := &config.Config{Repositories: []config.RepoSpec{{URL: "https://h/a.git"}, {URL: "https://h/b.git"}}}
+
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		ref     string
+		repo    string
+		want    map[string]sync.SpecOverride
+		wantErr string
+	}{
+		{"no ref", single, "", "", nil, ""},
+		{"single repository", single, "v1.2.0", "", map[string]sync.SpecOverride{"https://h/a.git": {Ref: "v1.2.0"}}, ""},
+		{"chosen repository", multi, "abc123", "https://h/b.git", map[string]sync.SpecOverride{"https://h/b.git": {Ref: "abc123"}}, ""},
+		{"several repositories", multi, "abc123", "", nil, "--ref requires --repo"},
+		{"unknown repository", multi, "abc123", "https://h/c.git", nil, "no configured repository"},
+		{"repo without ref", single, "", "https://h/a.git", nil, "--repo requires --ref"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := syncRefOverrides(tt.cfg, tt.ref, tt.repo)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("syncRefOverrides() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("syncRefOverrides() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActivationListener(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	listen := func(n int) []net.Listener {
+		t.Helper()
+		var ls []net.Listener
+		for i := 0; i < n; i++ {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen: %v", err)
+			}
+			t.Cleanup(func() { _ = l.Close() })
+			ls = append(ls, l)
+		}
+		return ls
+	}
+
+	if got := activationListener(nil, logger); got != nil {
+		t.Errorf("no sockets: got %v, want nil to bind serve.listen_addr", got.Addr())
+	}
+
+	one := listen(1)
+	if got := activationListener(one, logger); got != one[0] {
+		t.Errorf("one socket: got %v, want the passed listener", got)
+	}
+
+	several := listen(2)
+	if got := activationListener(several, logger); got != nil {
+		t.Errorf("two sockets: got %v, want nil to bind serve.listen_addr", got.Addr())
+	}
+	for _, l := range several {
+		if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("listener %s: Accept() error = %v, want it closed", l.Addr(), err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+	"github.com/schaermu/quadsyncd/internal/runstore"
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+// Output formats accepted by --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// syncReport is the machine-readable document printed by `sync --output json`.
+// Field names are part of the CLI contract; only add fields, never rename.
+type syncReport struct {
+	RunID          string                     `json:"run_id,omitempty"`
+	Status         runstore.RunStatus         `json:"status"`
+	DryRun         bool                       `json:"dry_run"`
+	StartedAt      time.Time                  `json:"started_at"`
+	EndedAt        time.Time                  `json:"ended_at"`
+	DurationMS     int64                      `json:"duration_ms"`
+	PhasesMS       reportPhases               `json:"phases_ms"`
+	Revisions      map[string]string          `json:"revisions"`
+	Refs           map[string]string          `json:"refs,omitempty"`
+	Plan           reportPlan                 `json:"plan"`
+	Applied        bool                       `json:"applied"`
+	Frozen         bool                       `json:"frozen,omitempty"`
+	Skipped        bool                       `json:"skipped,omitempty"`
+	Paused         bool                       `json:"paused,omitempty"`
+	RestartedUnits []string                   `json:"restarted_units"`
+	RestartFailed  []string                   `json:"restart_failed_units,omitempty"`
+	DeferredUnits  []string                   `json:"deferred_units,omitempty"`
+	StartedUnits   []string                   `json:"started_units,omitempty"`
+	UnhealthyUnits []string                   `json:"unhealthy_units,omitempty"`
+	AutoUpdated    []string                   `json:"auto_updated_units,omitempty"`
+	Conflicts      []runstore.ConflictSummary `json:"conflicts"`
+	Warnings       []runstore.WarningSummary  `json:"warnings"`
+	Error          string                     `json:"error,omitempty"`
+	ErrorKind      string                     `json:"error_kind,omitempty"`
+}
+
+// reportPhases holds per-phase wall-clock durations in milliseconds.
+type reportPhases struct {
+	Fetch   int64 `json:"fetch"`
+	Plan    int64 `json:"plan"`
+	Apply   int64 `json:"apply"`
+	Reload  int64 `json:"reload"`
+	Restart int64 `json:"restart"`
+}
+
+// reportPlan lists planned file operations grouped by kind.
+type reportPlan struct {
+	Add    []reportOp `json:"add"`
+	Update []reportOp `json:"update"`
+	Delete []reportOp `json:"delete"`
+	// Secrets lists podman secret operations; omitted when there are none.
+	Secrets []reportSecretOp `json:"secrets,omitempty"`
+	// UnmanagedFiles lists files in the quadlet dir that quadsyncd did not
+	// write; omitted when there are none.
+	UnmanagedFiles []string `json:"unmanaged_files,omitempty"`
+}
+
+// reportSecretOp describes a podman secret operation. Secret values are
+// never included.
+type reportSecretOp struct {
+	Name       string `json:"name"`
+	Action     string `json:"action"`
+	SourceRepo string `json:"source_repo,omitempty"`
+	SourceSHA  string `json:"source_sha,omitempty"`
+}
+
+// reportOp describes a single file operation. Path is relative to the
+// quadlet directory, matching the plan API.
+type reportOp struct {
+	Path       string `json:"path"`
+	Unit       string `json:"unit,omitempty"`
+	Hash       string `json:"hash,omitempty"`
+	SourceRepo string `json:"source_repo,omitempty"`
+	SourceRef  string `json:"source_ref,omitempty"`
+	SourceSHA  string `json:"source_sha,omitempty"`
+}
+
+// validateOutputFormat rejects unknown --output values.
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid --output %q (must be text or json)", format)
+	}
+}
+
+// newSyncReport assembles a report from the finalized run metadata and the
+// (possibly nil) engine result.
+func newSyncReport(meta *runstore.RunMeta, result *sync.Result, quadletDir string) syncReport {
+	report := syncReport{
+		RunID:          meta.ID,
+		Status:         meta.Status,
+		DryRun:         meta.DryRun,
+		StartedAt:      meta.StartedAt,
+		Revisions:      meta.Revisions,
+		Plan:           reportPlan{Add: []reportOp{}, Update: []reportOp{}, Delete: []reportOp{}},
+		RestartedUnits: []string{},
+		Conflicts:      meta.Conflicts,
+		Warnings:       meta.Warnings,
+		Error:          meta.Error,
+		ErrorKind:      meta.ErrorKind,
+	}
+	if meta.EndedAt != nil {
+		report.EndedAt = *meta.EndedAt
+		report.DurationMS = meta.EndedAt.Sub(meta.StartedAt).Milliseconds()
+	}
+	if report.Revisions == nil {
+		report.Revisions = map[string]string{}
+	}
+	if report.Conflicts == nil {
+		report.Conflicts = []runstore.ConflictSummary{}
+	}
+	if report.Warnings == nil {
+		report.Warnings = []runstore.WarningSummary{}
+	}
+	if result == nil {
+		return report
+	}
+
+	report.Applied = result.Applied
+	report.Frozen = result.Frozen
+	report.Skipped = result.Skipped
+	report.Paused = result.Paused
+	report.DeferredUnits = result.DeferredUnits
+	report.Refs = result.Refs
+	if result.RestartedUnits != nil {
+		report.RestartedUnits = result.RestartedUnits
+	}
+	report.RestartFailed = result.RestartFailed
+	report.StartedUnits = result.StartedUnits
+	report.UnhealthyUnits = result.UnhealthyUnits
+	report.AutoUpdated = result.AutoUpdated
+	report.PhasesMS = reportPhases{
+		Fetch:   result.Durations.Fetch.Milliseconds(),
+		Plan:    result.Durations.Plan.Milliseconds(),
+		Apply:   result.Durations.Apply.Milliseconds(),
+		Reload:  result.Durations.Reload.Milliseconds(),
+		Restart: result.Durations.Restart.Milliseconds(),
+	}
+	if result.Plan != nil {
+		report.Plan.Add = reportOps(result.Plan.Add, quadletDir)
+		report.Plan.Update = reportOps(result.Plan.Update, quadletDir)
+		report.Plan.Delete = reportOps(result.Plan.Delete, quadletDir)
+		for _, path := range result.Plan.Unmanaged {
+			report.Plan.UnmanagedFiles = append(report.Plan.UnmanagedFiles, displayPath(quadletDir, path))
+		}
+		for _, op := range result.Plan.Secrets {
+			report.Plan.Secrets = append(report.Plan.Secrets, reportSecretOp{
+				Name:       op.Name,
+				Action:     string(op.Action),
+				SourceRepo: op.SourceRepo,
+				SourceSHA:  op.SourceSHA,
+			})
+		}
+	}
+	return report
+}
+
+// reportOps converts engine file operations into report entries.
+func reportOps(ops []sync.FileOp, quadletDir string) []reportOp {
+	out := make([]reportOp, 0, len(ops))
+	for _, op := range ops {
+		rop := reportOp{
+			Path:       displayPath(quadletDir, op.DestPath),
+			Hash:       op.Hash,
+			SourceRepo: op.SourceRepo,
+			SourceRef:  op.SourceRef,
+			SourceSHA:  op.SourceSHA,
+		}
+		if quadlet.IsQuadletFile(op.DestPath) {
+			rop.Unit = quadlet.UnitNameFromQuadlet(op.DestPath)
+		} else if name, ok := quadlet.DropInQuadlet(op.DestPath); ok {
+			rop.Unit = quadlet.UnitNameFromQuadlet(name)
+		}
+		out = append(out, rop)
+	}
+	return out
+}
+
+// displayPath returns dest relative to quadletDir, or the absolute path for
+// manifest-declared files placed outside it.
+func displayPath(quadletDir, dest string) string {
+	rel, err := filepath.Rel(quadletDir, dest)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(dest)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// writeSyncReport encodes report as indented JSON followed by a newline.
+func writeSyncReport(w io.Writer, report syncReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write sync report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/runstore"
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, tc := range []struct {
+		format  string
+		wantErr bool
+	}{
+		{format: "text"},
+		{format: "json"},
+		{format: "yaml", wantErr: true},
+		{format: "", wantErr: true},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			err := validateOutputFormat(tc.format)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateOutputFormat(%q) error = %v, wantErr %v", tc.format, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSyncReport(t *testing.T) {
+	started := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ended := started.Add(1500 * time.Millisecond)
+	meta := &runstore.RunMeta{
+		ID:        "20260101-120000-abcdef",
+		Status:    runstore.RunStatusSuccess,
+		StartedAt: started,
+		EndedAt:   &ended,
+		Revisions: map[string]string{"https://example.com/r.git": "abc123"},
+		Warnings:  []runstore.WarningSummary{{Code: "drift_ignored", Message: "drift", Subject: "/q/web.container"}},
+	}
+	result := &sync.Result{
+		Plan: &sync.Plan{
+			Add:    []sync.FileOp{{DestPath: "/q/web.container", Hash: "h1", SourceRepo: "https://example.com/r.git"}},
+			Update: []sync.FileOp{{DestPath: "/q/app.env", Hash: "h2"}},
+			Delete: []sync.FileOp{{DestPath: "/q/old.volume"}},
+		},
+		Applied:        true,
+		RestartedUnits: []string{"web.service"},
+		Durations:      sync.PhaseDurations{Fetch: 200 * time.Millisecond, Apply: 10 * time.Millisecond},
+	}
+
+	report := newSyncReport(meta, result, "/q")
+
+	if report.DurationMS != 1500 {
+		t.Errorf("DurationMS = %d, want 1500", report.DurationMS)
+	}
+	if report.PhasesMS.Fetch != 200 || report.PhasesMS.Apply != 10 {
+		t.Errorf("unexpected phases: %+v", report.PhasesMS)
+	}
+	if !report.Applied {
+		t.Error("expected Applied to be true")
+	}
+	if len(report.Plan.Add) != 1 || report.Plan.Add[0].Path != "web.container" || report.Plan.Add[0].Unit != "web.service" {
+		t.Errorf("unexpected add ops: %+v", report.Plan.Add)
+	}
+	if len(report.Plan.Update) != 1 || report.Plan.Update[0].Unit != "" {
+		t.Errorf("companion file should have no unit: %+v", report.Plan.Update)
+	}
+	if len(report.Plan.Delete) != 1 || report.Plan.Delete[0].Unit != "old-volume.service" {
+		t.Errorf("unexpected delete ops: %+v", report.Plan.Delete)
+	}
+	if len(report.RestartedUnits) != 1 || report.RestartedUnits[0] != "web.service" {
+		t.Errorf("unexpected restarted units: %v", report.RestartedUnits)
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0].Code != "drift_ignored" {
+		t.Errorf("unexpected warnings: %+v", report.Warnings)
+	}
+}
+
+func TestNewSyncReport_NilResult(t *testing.T) {
+	meta := &runstore.RunMeta{
+		Status:    runstore.RunStatusError,
+		Error:     "git fetch failed",
+		ErrorKind: "network",
+	}
+
+	var buf bytes.Buffer
+	if err := writeSyncReport(&buf, newSyncReport(meta, nil, "/q")); err != nil {
+		t.Fatalf("writeSyncReport: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if doc["status"] != "error" || doc["error_kind"] != "network" {
+		t.Errorf("unexpected status/error_kind: %v / %v", doc["status"], doc["error_kind"])
+	}
+	// Collections must serialize as empty arrays/objects, never null.
+	for _, key := range []string{"restarted_units", "conflicts", "warnings", "revisions"} {
+		if doc[key] == nil {
+			t.Errorf("expected %s to be non-null", key)
+		}
+	}
+}
+
+// TestCLI_Sync_OutputJSON verifies that --output json prints a single JSON
+// document to stdout (logs go to stderr) even when the sync fails.
+func TestCLI_Sync_OutputJSON(t *testing.T) {
+	origCfg := cfgFile
+	origOutput := outputFormat
+	t.Cleanup(func() {
+		cfgFile = origCfg
+		outputFormat = origOutput
+	})
+
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "config.yaml")
+	content := `repository:
+  url: "file://` + filepath.Join(tmpDir, "missing-repo") + `"
+  ref: "main"
+paths:
+  quadlet_dir: "` + filepath.Join(tmpDir, "quadlets") + `"
+  state_dir: "` + filepath.Join(tmpDir, "state") + `"
+  extra_quadlet_roots: ["` + tmpDir + `"]
+sync:
+  restart: "none"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfgFile = cfgPath
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	os.Stdout = w
+
+	rootCmd.SetArgs([]string{"sync", "--output", "json"})
+	execErr := rootCmd.Execute()
+
+	_ = w.Close()
+	os.Stdout = origStdout
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+
+	if execErr == nil {
+		t.Fatal("expected sync against a missing repo to fail")
+	}
+	var doc syncReport
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("stdout is not a single JSON document: %v\n%s", err, buf.String())
+	}
+	if doc.Status != runstore.RunStatusError {
+		t.Errorf("status = %q, want error", doc.Status)
+	}
+	if !strings.Contains(doc.Error, "clone") {
+		t.Errorf("expected clone error in report, got %q", doc.Error)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// Pause command flags
+var pauseReason string
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Make syncs do nothing until resume",
+	Long: `Pause turns syncs from the timer, webhooks and the command line into no-ops:
+they neither fetch nor plan, and are recorded with the result "paused" in
+the history. Dry runs and plans still work. A sync already running in the
+webhook server finishes. Unlike freeze, which still fetches and reports
+pending changes, pause keeps quadsyncd quiet, e.g. during incident response.
+The pause is stored in the state directory and survives restarts;
+"quadsyncd resume" lifts it. The webhook server offers the same as
+POST /-/pause and POST /-/resume.`,
+	Args: cobra.NoArgs,
+	RunE: runPause,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Let syncs run again after pause",
+	Long:  `Resume lifts a pause. The next sync from any trigger runs normally.`,
+	Args:  cobra.NoArgs,
+	RunE:  runResume,
+}
+
+func init() {
+	pauseCmd.Flags().StringVar(&pauseReason, "reason", "", "why syncs are paused, shown in logs and the status API")
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
+
+func runPause(cmd *cobra.Command, args []string) error {
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	pause, err := sync.SetPause(cfg.Paths.StateDir, pauseReason, time.Now())
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Syncs paused since %s\n", pause.Since.Local().Format(time.RFC3339))
+	return nil
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	resumed, err := sync.ClearPause(cfg.Paths.StateDir)
+	if err != nil {
+		return err
+	}
+	if !resumed {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Syncs were not paused.")
+		return nil
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Syncs resumed.")
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestCLI_PauseResume(t *testing.T) {
+	origCfg, origReason := cfgFile, pauseReason
+	t.Cleanup(func() {
+		cfgFile, pauseReason = origCfg, origReason
+		pauseCmd.Flags().Lookup("reason").Changed = false
+	})
+	tmpDir := t.TempDir()
+	cfgFile = writeTempConfig(t, tmpDir)
+	stateDir := filepath.Join(tmpDir, "state")
+
+	t.Cleanup(func() { rootCmd.SetOut(nil) })
+
+	run := func(args ...string) string {
+		t.Helper()
+		origStdout := os.Stdout
+		os.Stdout, _ = os.Open(os.DevNull)
+		defer func() { os.Stdout = origStdout }()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	out := run("pause", "--reason", "incident")
+	p, err := sync.ReadPause(stateDir)
+	if err != nil || p == nil || p.Reason != "incident" {
+		t.Fatalf("after pause: %+v, %v", p, err)
+	}
+	if want := "Syncs paused since " + p.Since.Local().Format(time.RFC3339) + "\n"; out != want {
+		t.Errorf("pause output = %q, want %q", out, want)
+	}
+	if out := run("resume"); out != "Syncs resumed.\n" {
+		t.Errorf("resume output = %q", out)
+	}
+	if p, err := sync.ReadPause(stateDir); err != nil || p != nil {
+		t.Errorf("after resume: %+v, %v", p, err)
+	}
+	if out := run("resume"); out != "Syncs were not paused.\n" {
+		t.Errorf("second resume output = %q", out)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/schaermu/quadsyncd/internal/diff"
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// exitCodeChangesPending is returned by `plan` when the quadlet directory
+// differs from the repository, so CI jobs can gate on drift.
+const exitCodeChangesPending = 2
+
+// Plan command flags
+var planShowDiff bool
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show pending changes without applying them",
+	Long: `Plan fetches the configured repositories, computes the changes a sync would
+make to the quadlet directory and prints them without touching any files or
+systemd units.
+
+Exit codes:
+  0  no changes pending
+  1  an error occurred
+  2  changes are pending`,
+	RunE: runPlan,
+}
+
+func init() {
+	planCmd.Flags().BoolVar(&planShowDiff, "diff", false, "show unified content diffs for each changed file")
+	planCmd.Flags().BoolVar(&allowUnmanagedDelete, "allow-unmanaged-delete", false, allowUnmanagedDeleteUsage)
+	rootCmd.AddCommand(planCmd)
+}
+
+// exitError requests a specific process exit code without an error message.
+type exitError struct {
+	code int
+}
+
+// Error implements error.
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	// The plan goes to stdout; keep logs out of it.
+	logsToStderr = true
+
+	ctx, cancel := setupSignalHandler()
+	defer cancel()
+
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	engine := sync.New(cfg, sync.WithLogger(logger), sync.WithDryRun(true))
+	result, err := engine.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("plan failed: %w", err)
+	}
+
+	if err := printPlan(os.Stdout, result.Plan, cfg.Paths.QuadletDir, planShowDiff); err != nil {
+		return err
+	}
+
+	if planHasChanges(result.Plan) {
+		cmd.SilenceErrors = true
+		return &exitError{code: exitCodeChangesPending}
+	}
+	return nil
+}
+
+// planHasChanges reports whether plan contains any file or secret operation.
+func planHasChanges(plan *sync.Plan) bool {
+	return plan != nil && len(plan.Add)+len(plan.Update)+len(plan.Delete)+len(plan.Secrets) > 0
+}
+
+// secretStatus maps secret actions to name-status letters.
+var secretStatus = map[sync.SecretAction]string{
+	sync.SecretCreate: "A",
+	sync.SecretUpdate: "M",
+	sync.SecretDelete: "D",
+}
+
+// printPlan writes a git-style name-status listing of plan to w, optionally
+// followed by unified diffs of each file, and a one-line summary.
+// planContent returns the content op installs: the substituted content when
+// substitution changed the source, the source file otherwise.
+func planContent(op sync.FileOp) ([]byte, error) {
+	if op.Rendered != nil {
+		return op.Rendered, nil
+	}
+	after, err := os.ReadFile(op.SourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", op.SourcePath, err)
+	}
+	return after, nil
+}
+
+func printPlan(w io.Writer, plan *sync.Plan, quadletDir string, showDiff bool) error {
+	if !planHasChanges(plan) {
+		if _, err := fmt.Fprintln(w, "No changes. Quadlet directory is up to date."); err != nil {
+			return err
+		}
+		return printUnmanaged(w, plan, quadletDir)
+	}
+
+	rel := func(abs string) string {
+		return displayPath(quadletDir, abs)
+	}
+
+	for _, op := range plan.Add {
+		_, _ = fmt.Fprintf(w, "A\t%s\n", rel(op.DestPath))
+	}
+	for _, op := range plan.Update {
+		_, _ = fmt.Fprintf(w, "M\t%s\n", rel(op.DestPath))
+	}
+	for _, op := range plan.Delete {
+		_, _ = fmt.Fprintf(w, "D\t%s\n", rel(op.DestPath))
+	}
+	// Secrets are listed by name only; their content is never shown.
+	for _, op := range plan.Secrets {
+		_, _ = fmt.Fprintf(w, "%s\tsecret:%s\n", secretStatus[op.Action], op.Name)
+	}
+
+	if showDiff {
+		for _, op := range plan.Add {
+			if op.Encrypted {
+				writeEncryptedNote(w, rel(op.DestPath))
+				continue
+			}
+			after, err := planContent(op)
+			if err != nil {
+				return err
+			}
+			writeFileDiff(w, rel(op.DestPath), nil, after, false, true)
+		}
+		for _, op := range plan.Update {
+			if op.Encrypted {
+				writeEncryptedNote(w, rel(op.DestPath))
+				continue
+			}
+			before, err := os.ReadFile(op.DestPath)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", op.DestPath, err)
+			}
+			after, err := planContent(op)
+			if err != nil {
+				return err
+			}
+			writeFileDiff(w, rel(op.DestPath), before, after, true, true)
+		}
+		for _, op := range plan.Delete {
+			before, err := os.ReadFile(op.DestPath)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", op.DestPath, err)
+			}
+			writeFileDiff(w, rel(op.DestPath), before, nil, true, false)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\nPlan: %d to add, %d to update, %d to delete.\n",
+		len(plan.Add), len(plan.Update), len(plan.Delete))
+	if err == nil && len(plan.Secrets) > 0 {
+		_, err = fmt.Fprintf(w, "Secrets: %d to change.\n", len(plan.Secrets))
+	}
+	if err != nil {
+		return err
+	}
+	return printUnmanaged(w, plan, quadletDir)
+}
+
+// printUnmanaged lists the files in the quadlet dir that quadsyncd did not
+// write and that the plan keeps, marked with "?" like untracked files in git.
+func printUnmanaged(w io.Writer, plan *sync.Plan, quadletDir string) error {
+	if plan == nil {
+		return nil
+	}
+	deleted := make(map[string]bool, len(plan.Delete))
+	for _, op := range plan.Delete {
+		deleted[op.DestPath] = true
+	}
+	var kept []string
+	for _, path := range plan.Unmanaged {
+		if !deleted[path] {
+			kept = append(kept, path)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "\nUnmanaged files, not written by quadsyncd and kept: %d\n", len(kept)); err != nil {
+		return err
+	}
+	for _, path := range kept {
+		if _, err := fmt.Fprintf(w, "?\t%s\n", displayPath(quadletDir, path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFileDiff writes a "diff --git" header and unified diff for one file.
+// A missing side is labelled /dev/null as git does for added/deleted files.
+func writeFileDiff(w io.Writer, path string, before, after []byte, hasBefore, hasAfter bool) {
+	from, to := "a/"+path, "b/"+path
+	if !hasBefore {
+		from = "/dev/null"
+	}
+	if !hasAfter {
+		to = "/dev/null"
+	}
+	_, _ = fmt.Fprintf(w, "\ndiff --git a/%s b/%s\n", path, path)
+	_, _ = io.WriteString(w, diff.Unified(from, to, before, after))
+}
+
+// writeEncryptedNote stands in for the diff of a sops-encrypted file, whose
+// decrypted content is not printed.
+func writeEncryptedNote(w io.Writer, path string) {
+	_, _ = fmt.Fprintf(w, "diff --git a/%s b/%s\n(sops-encrypted, diff not shown)\n", path, path)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestPrintPlan(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	write := func(path, content string) string {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	plan := &sync.Plan{
+		Add: []sync.FileOp{{
+			SourcePath: write(filepath.Join(src, "web.container"), "[Container]\nImage=nginx\n"),
+			DestPath:   filepath.Join(dst, "web.container"),
+		}},
+		Update: []sync.FileOp{{
+			SourcePath: write(filepath.Join(src, "app.env"), "A=1\nB=3\n"),
+			DestPath:   write(filepath.Join(dst, "app.env"), "A=1\nB=2\n"),
+		}},
+		Delete: []sync.FileOp{{
+			DestPath: write(filepath.Join(dst, "old.volume"), "[Volume]\n"),
+		}},
+		Secrets: []sync.SecretOp{{
+			Name:       "db-password",
+			Action:     sync.SecretUpdate,
+			SourcePath: write(filepath.Join(src, "db.age"), "ciphertext\n"),
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		showDiff bool
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "name status only",
+			showDiff: false,
+			want:     []string{"A\tweb.container\n", "M\tapp.env\n", "D\told.volume\n", "M\tsecret:db-password\n", "Plan: 1 to add, 1 to update, 1 to delete.", "Secrets: 1 to change."},
+			notWant:  []string{"diff --git"},
+		},
+		{
+			name:     "with diff",
+			showDiff: true,
+			want: []string{
+				"diff --git a/web.container b/web.container\n--- /dev/null\n+++ b/web.container\n",
+				"+Image=nginx\n",
+				"--- a/app.env\n+++ b/app.env\n",
+				"-B=2\n+B=3\n",
+				"--- a/old.volume\n+++ /dev/null\n",
+				"-[Volume]\n",
+			},
+			notWant: []string{"ciphertext"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printPlan(&buf, plan, dst, tt.showDiff); err != nil {
+				t.Fatalf("printPlan: %v", err)
+			}
+			out := buf.String()
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("output missing %q:\n%s", w, out)
+				}
+			}
+			for _, nw := range tt.notWant {
+				if strings.Contains(out, nw) {
+					t.Errorf("output unexpectedly contains %q:\n%s", nw, out)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintPlan_EncryptedNotDiffed(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "app.env")
+	if err := os.WriteFile(src, []byte("A=ENC[AES256_GCM,data:x]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	plan := &sync.Plan{Add: []sync.FileOp{{SourcePath: src, DestPath: "/q/app.env", Encrypted: true}}}
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, plan, "/q", true); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "(sops-encrypted, diff not shown)") || strings.Contains(out, "ENC[") {
+		t.Errorf("output = %q, want encrypted note without content", out)
+	}
+}
+
+func TestPrintPlan_NoChanges(t *testing.T) {
+	for _, plan := range []*sync.Plan{nil, {}} {
+		var buf bytes.Buffer
+		if err := printPlan(&buf, plan, "/q", true); err != nil {
+			t.Fatalf("printPlan: %v", err)
+		}
+		if !strings.Contains(buf.String(), "No changes.") {
+			t.Errorf("output = %q, want no-changes message", buf.String())
+		}
+	}
+}
+
+func TestPrintPlan_Unmanaged(t *testing.T) {
+	plan := &sync.Plan{
+		Delete:    []sync.FileOp{{DestPath: "/q/stale.container"}},
+		Unmanaged: []string{"/q/hand.container", "/q/stale.container"},
+	}
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, &sync.Plan{Unmanaged: plan.Unmanaged}, "/q", false); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "No changes.") || !strings.Contains(out, "kept: 2\n?\thand.container\n?\tstale.container\n") {
+		t.Errorf("output without deletes = %q", out)
+	}
+
+	buf.Reset()
+	if err := printPlan(&buf, plan, "/q", false); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "D\tstale.container\n") || !strings.Contains(out, "kept: 1\n?\thand.container\n") {
+		t.Errorf("output with deletes = %q", out)
+	}
+}
+
+func TestPrintPlan_MissingSource(t *testing.T) {
+	plan := &sync.Plan{Add: []sync.FileOp{{
+		SourcePath: filepath.Join(t.TempDir(), "missing.container"),
+		DestPath:   "/q/missing.container",
+	}}}
+	if err := printPlan(&bytes.Buffer{}, plan, "/q", true); err == nil {
+		t.Fatal("expected error for unreadable source file")
+	}
+}
+
+func TestExitError(t *testing.T) {
+	var err error = &exitError{code: exitCodeChangesPending}
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != 2 {
+		t.Fatalf("errors.As did not recover exit code: %v", err)
+	}
+	if err.Error() != "exit status 2" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
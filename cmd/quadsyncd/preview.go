@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+	"github.com/spf13/cobra"
+)
+
+var previewCmd = &cobra.Command{
+	Use:   "preview <file>",
+	Short: "Print the systemd unit generated from a quadlet file",
+	Long: `Preview runs podman's quadlet generator in dry-run mode over the directory of
+a quadlet file and prints the systemd unit it generates from that file,
+e.g. to see which podman run options a .container file turns into. The
+other quadlet files in the directory are read too, so references to
+.network, .volume or .pod files in the same directory resolve.
+
+Preview does not read the configuration and changes nothing. It needs
+podman-system-generator, which is installed with podman on Linux.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPreview,
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+}
+
+// unitGenerator produces the units of a quadlet directory.
+type unitGenerator interface {
+	GenerateUnits(ctx context.Context, quadletDir string) ([]systemduser.GeneratedUnit, error)
+}
+
+func runPreview(cmd *cobra.Command, args []string) error {
+	logger := setupLogger()
+	gen := systemduser.NewClientWithTimeouts(logger, config.DefaultSystemctlTimeout, config.DefaultPodmanTimeout)
+
+	units, err := previewUnits(cmd.Context(), gen, args[0])
+	if err != nil {
+		return err
+	}
+	return printPreview(os.Stdout, units)
+}
+
+// previewUnits returns the units the generator produces from the quadlet
+// file at path.
+func previewUnits(ctx context.Context, gen unitGenerator, path string) ([]systemduser.GeneratedUnit, error) {
+	if !quadlet.IsQuadletFile(path) {
+		return nil, fmt.Errorf("%s is not a quadlet file (expected one of %v)", path, quadlet.ValidExtensions)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, err
+	}
+
+	units, err := gen.GenerateUnits(ctx, filepath.Dir(abs))
+	if err != nil {
+		if errors.Is(err, systemduser.ErrValidationSkipped) {
+			return nil, fmt.Errorf("cannot preview without podman's quadlet generator: %w", err)
+		}
+		return nil, err
+	}
+
+	// The generator records the source file in SourcePath=; the unit name
+	// is the fallback for generators that do not.
+	var matched []systemduser.GeneratedUnit
+	for _, u := range units {
+		if u.SourcePath() == abs {
+			matched = append(matched, u)
+		}
+	}
+	if len(matched) == 0 {
+		name := quadlet.UnitNameFromQuadlet(abs)
+		for _, u := range units {
+			if u.Name == name && u.SourcePath() == "" {
+				matched = append(matched, u)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("the quadlet generator produced no unit for %s; run `quadsyncd lint %s` to check it", path, path)
+	}
+	return matched, nil
+}
+
+// printPreview writes each unit under a comment naming it.
+func printPreview(w io.Writer, units []systemduser.GeneratedUnit) error {
+	for i, u := range units {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "# %s\n%s", u.Name, u.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
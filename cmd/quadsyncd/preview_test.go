@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+)
+
+// fakeGenerator returns fixed units and records the directory it was asked
+// to generate.
+type fakeGenerator struct {
+	units []systemduser.GeneratedUnit
+	err   error
+	dir   string
+}
+
+func (g *fakeGenerator) GenerateUnits(_ context.Context, quadletDir string) ([]systemduser.GeneratedUnit, error) {
+	g.dir = quadletDir
+	return g.units, g.err
+}
+
+func TestPreviewUnits(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"web.container", "app.network", "README.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("[Unit]\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	web := systemduser.GeneratedUnit{Name: "web.service", Content: "[Unit]\nSourcePath=" + filepath.Join(dir, "web.container") + "\n"}
+	network := systemduser.GeneratedUnit{Name: "app-network.service", Content: "[Unit]\nSourcePath=" + filepath.Join(dir, "app.network") + "\n"}
+	legacy := systemduser.GeneratedUnit{Name: "web.service", Content: "[Service]\nExecStart=podman run nginx\n"}
+
+	tests := []struct {
+		name     string
+		path     string
+		gen      *fakeGenerator
+		wantUnit string
+		wantErr  string
+	}{
+		{name: "by source path", path: "web.container", gen: &fakeGenerator{units: []systemduser.GeneratedUnit{network, web}}, wantUnit: "web.service"},
+		{name: "network", path: "app.network", gen: &fakeGenerator{units: []systemduser.GeneratedUnit{network, web}}, wantUnit: "app-network.service"},
+		{name: "by unit name", path: "web.container", gen: &fakeGenerator{units: []systemduser.GeneratedUnit{legacy}}, wantUnit: "web.service"},
+		{name: "no unit", path: "web.container", gen: &fakeGenerator{units: []systemduser.GeneratedUnit{network}}, wantErr: "produced no unit"},
+		{name: "generator missing", path: "web.container", gen: &fakeGenerator{err: fmt.Errorf("%w: not found", systemduser.ErrValidationSkipped)}, wantErr: "quadlet generator"},
+		{name: "not a quadlet", path: "README.md", gen: &fakeGenerator{}, wantErr: "not a quadlet file"},
+		{name: "missing file", path: "db.container", gen: &fakeGenerator{}, wantErr: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			units, err := previewUnits(context.Background(), tt.gen, filepath.Join(dir, tt.path))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("previewUnits() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("previewUnits() error = %v", err)
+			}
+			if len(units) != 1 || units[0].Name != tt.wantUnit {
+				t.Errorf("previewUnits() = %+v, want %s", units, tt.wantUnit)
+			}
+			if tt.gen.dir != dir {
+				t.Errorf("generator ran over %s, want %s", tt.gen.dir, dir)
+			}
+		})
+	}
+}
+
+func TestPrintPreview(t *testing.T) {
+	var buf bytes.Buffer
+	err := printPreview(&buf, []systemduser.GeneratedUnit{
+		{Name: "web.service", Content: "[Service]\nExecStart=podman run nginx\n"},
+		{Name: "web-build.service", Content: "[Service]\n"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# web.service\n[Service]\nExecStart=podman run nginx\n\n# web-build.service\n[Service]\n"
+	if buf.String() != want {
+		t.Errorf("printPreview() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/runstore"
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// Restore command flags
+var (
+	restoreList   bool
+	restoreDryRun bool
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [commit]",
+	Short: "Roll the quadlet directory back to an earlier synced commit",
+	Long: `Restore replaces the managed files with the snapshot taken before they were
+last replaced at the given commit (a full SHA or unambiguous prefix), reloads
+systemd and restarts affected units per the restart policy.
+
+Snapshots are only taken when sync.backup_retention is greater than zero.
+Use --list to show available snapshots.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRestore,
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreList, "list", false, "list available backups and exit")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "show what would be restored without making changes")
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	if !restoreList && len(args) == 0 {
+		return fmt.Errorf("a commit is required (or use --list)")
+	}
+
+	ctx, cancel := setupSignalHandler()
+	defer cancel()
+
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if restoreList {
+		backups, err := sync.ListBackups(cfg.Paths.StateDir)
+		if err != nil {
+			return err
+		}
+		return printBackups(os.Stdout, backups)
+	}
+
+	engine := sync.New(cfg, sync.WithLogger(logger), sync.WithDryRun(restoreDryRun))
+	ctx = sync.WithActor(ctx, sync.Actor{Trigger: string(runstore.TriggerCLI), Command: "restore"})
+	if _, err := engine.Restore(ctx, args[0]); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+	return nil
+}
+
+// printBackups writes a table of backups, newest first.
+func printBackups(w io.Writer, backups []sync.Backup) error {
+	if len(backups) == 0 {
+		_, err := fmt.Fprintln(w, "No backups available.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tCREATED\tFILES")
+	for _, b := range backups {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\n", b.ID, b.CreatedAt.Local().Format(time.RFC3339), len(b.State.ManagedFiles))
+	}
+	return tw.Flush()
+}
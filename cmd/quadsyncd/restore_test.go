@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestPrintBackups(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printBackups(&buf, nil); err != nil {
+		t.Fatalf("printBackups: %v", err)
+	}
+	if !strings.Contains(buf.String(), "No backups available.") {
+		t.Errorf("empty output = %q", buf.String())
+	}
+
+	buf.Reset()
+	backups := []sync.Backup{{
+		ID:        "abc123",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		State:     &sync.State{ManagedFiles: map[string]sync.ManagedFile{"/q/a.container": {}, "/q/b.env": {}}},
+	}}
+	if err := printBackups(&buf, backups); err != nil {
+		t.Fatalf("printBackups: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+	if fields := strings.Fields(lines[1]); len(fields) != 3 || fields[0] != "abc123" || fields[2] != "2" {
+		t.Errorf("row = %q, want id, timestamp and file count", lines[1])
+	}
+}
+
+func TestCLI_Restore_RequiresCommit(t *testing.T) {
+	origCfg := cfgFile
+	t.Cleanup(func() { cfgFile = origCfg })
+
+	cfgFile = writeTempConfig(t, t.TempDir())
+	rootCmd.SetArgs([]string{"restore"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "commit is required") {
+		t.Errorf("expected missing commit error, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/schaermu/quadsyncd/internal/runstore"
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// Rollback command flags
+var (
+	rollbackTo     string
+	rollbackDryRun bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Sync the commit of the previous successful run again",
+	Long: `Rollback looks up the newest successful sync in the history whose commits
+differ from the current ones, checks those commits out, rebuilds the plan
+against them, applies it and restarts affected units per the restart policy.
+Use --to to pick the run that synced a given commit (a full SHA or
+unambiguous prefix) instead; "quadsyncd history" lists them.
+
+Unlike restore, rollback needs no backups: it syncs from the repository. It
+only affects this run. The next sync, timer or webhook returns to the
+configured ref, so revert the change in the repository or set repo.ref to
+the commit to keep it.`,
+	Args: cobra.NoArgs,
+	RunE: runRollback,
+}
+
+func init() {
+	rollbackCmd.Flags().StringVar(&rollbackTo, "to", "", "commit to roll back to (default: the previous successfully synced commit)")
+	rollbackCmd.Flags().BoolVar(&rollbackDryRun, "dry-run", false, "show what would be done without making changes")
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	ctx, cancel := setupSignalHandler()
+	defer cancel()
+
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	engine := sync.New(cfg, sync.WithLogger(logger), sync.WithDryRun(rollbackDryRun))
+	ctx = sync.WithActor(ctx, sync.Actor{Trigger: string(runstore.TriggerCLI), Command: "rollback"})
+	result, err := engine.Rollback(ctx, rollbackTo)
+	if err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+
+	urls := make([]string, 0, len(result.Revisions))
+	for url := range result.Revisions {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	verb := "Rolled back"
+	if rollbackDryRun {
+		verb = "Would roll back"
+	}
+	for _, url := range urls {
+		fmt.Printf("%s %s to %s\n", verb, url, shortSHA(result.Revisions[url]))
+	}
+	if !rollbackDryRun {
+		fmt.Println("The next sync returns to the configured ref; revert the change in the repository to keep this state.")
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCLI_Rollback_EmptyHistory(t *testing.T) {
+	origCfg := cfgFile
+	t.Cleanup(func() { cfgFile = origCfg })
+
+	cfgFile = writeTempConfig(t, t.TempDir())
+	rootCmd.SetArgs([]string{"rollback"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "no earlier successful sync") {
+		t.Errorf("expected empty history error, got %v", err)
+	}
+}
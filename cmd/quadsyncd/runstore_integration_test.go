@@ -25,6 +25,7 @@ repository:
 paths:
   quadlet_dir: ` + filepath.Join(tmpDir, "quadlets") + `
   state_dir: ` + filepath.Join(tmpDir, "state") + `
+  extra_quadlet_roots: [` + tmpDir + `]
 
 sync:
   prune: false
@@ -95,6 +96,7 @@ repository:
 paths:
   quadlet_dir: ` + filepath.Join(tmpDir, "quadlets") + `
   state_dir: ` + filepath.Join(tmpDir, "state") + `
+  extra_quadlet_roots: [` + tmpDir + `]
 
 sync:
   prune: false
@@ -179,6 +181,7 @@ repository:
 paths:
   quadlet_dir: ` + filepath.Join(tmpDir, "quadlets") + `
   state_dir: ` + filepath.Join(tmpDir, "state") + `
+  extra_quadlet_roots: [` + tmpDir + `]
 
 sync:
   prune: false
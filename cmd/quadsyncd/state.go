@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/statebundle"
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the files inside a state bundle.
+const (
+	bundleStateFile   = "state.json"
+	bundleHistoryFile = "history.jsonl"
+	bundleConfigFile  = "config.yaml"
+)
+
+// State command flags
+var (
+	stateExportOutput string
+	stateExportSign   bool
+	stateExportKey    string
+	stateImportVerify bool
+	stateImportPubKey string
+	stateImportForce  bool
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Export and import sync state bundles",
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write state, history and the effective config to a bundle",
+	Long: `Export writes a gzip-compressed tarball holding state.json, the sync history
+and the effective configuration with secrets redacted, plus a manifest with
+the SHA-256 of every file.
+
+With --sign the manifest is signed with an Ed25519 private key (PKCS #8 PEM,
+e.g. from "openssl genpkey -algorithm ed25519"), so auditors and "state import
+--verify" can prove the bundle is unmodified.`,
+	Args: cobra.NoArgs,
+	RunE: runStateExport,
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Restore state and history from a bundle",
+	Long: `Import restores state.json and the sync history from a bundle written by
+"state export", e.g. onto replacement hardware. The bundled configuration is
+for audits only and is never applied.
+
+With --verify the bundle must carry a signature that matches --public-key
+(PKIX PEM, e.g. from "openssl pkey -pubout"). An existing state file is only
+replaced with --force.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStateImport,
+}
+
+func init() {
+	stateExportCmd.Flags().StringVarP(&stateExportOutput, "output", "o", "", "bundle file to write, - for stdout (default: quadsyncd-state-<timestamp>.tar.gz)")
+	stateExportCmd.Flags().BoolVar(&stateExportSign, "sign", false, "sign the bundle manifest with --key")
+	stateExportCmd.Flags().StringVar(&stateExportKey, "key", "", "Ed25519 private key (PEM) used by --sign")
+	stateImportCmd.Flags().BoolVar(&stateImportVerify, "verify", false, "require a valid signature from --public-key")
+	stateImportCmd.Flags().StringVar(&stateImportPubKey, "public-key", "", "Ed25519 public key (PEM) used by --verify")
+	stateImportCmd.Flags().BoolVar(&stateImportForce, "force", false, "replace an existing state file")
+	stateCmd.AddCommand(stateExportCmd)
+	stateCmd.AddCommand(stateImportCmd)
+	rootCmd.AddCommand(stateCmd)
+}
+
+func runStateExport(cmd *cobra.Command, args []string) error {
+	if stateExportSign && stateExportKey == "" {
+		return fmt.Errorf("--sign requires --key")
+	}
+	if stateExportOutput == "-" {
+		// The bundle goes to stdout; keep logs out of it.
+		logsToStderr = true
+	}
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var key ed25519.PrivateKey
+	if stateExportSign {
+		if key, err = statebundle.LoadPrivateKey(stateExportKey); err != nil {
+			return err
+		}
+	}
+
+	files, err := stateBundleFiles(cfg)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	meta := statebundle.Manifest{CreatedAt: now, Version: version}
+	if host, err := os.Hostname(); err == nil {
+		meta.Hostname = host
+	}
+
+	var buf bytes.Buffer
+	if err := statebundle.Write(&buf, files, meta, key); err != nil {
+		return err
+	}
+
+	if stateExportOutput == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	output := stateExportOutput
+	if output == "" {
+		output = fmt.Sprintf("quadsyncd-state-%s.tar.gz", now.Format("20060102T150405Z"))
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	logger.Info("state exported", "path", output, "files", len(files), "signed", key != nil)
+	return nil
+}
+
+// stateBundleFiles collects the files of a state bundle: the state and
+// history files that exist, and cfg with secrets redacted.
+func stateBundleFiles(cfg *config.Config) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for name, path := range map[string]string{
+		bundleStateFile:   cfg.StateFilePath(),
+		bundleHistoryFile: sync.HistoryFilePath(cfg.Paths.StateDir),
+	} {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		files[name] = data
+	}
+	if _, ok := files[bundleStateFile]; !ok {
+		return nil, fmt.Errorf("no state file at %s; run a sync first", cfg.StateFilePath())
+	}
+
+	redacted := cfg.Redacted()
+	data, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	files[bundleConfigFile] = data
+	return files, nil
+}
+
+func runStateImport(cmd *cobra.Command, args []string) error {
+	if stateImportVerify && stateImportPubKey == "" {
+		return fmt.Errorf("--verify requires --public-key")
+	}
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	bundle, err := statebundle.Read(f)
+	if err != nil {
+		return err
+	}
+	if stateImportVerify {
+		pub, err := statebundle.LoadPublicKey(stateImportPubKey)
+		if err != nil {
+			return err
+		}
+		if err := bundle.Verify(pub); err != nil {
+			return err
+		}
+	} else if !bundle.Signed() {
+		logger.Warn("importing an unsigned state bundle", "path", args[0])
+	}
+
+	stateData, ok := bundle.Files[bundleStateFile]
+	if !ok {
+		return fmt.Errorf("bundle has no %s", bundleStateFile)
+	}
+	state, err := sync.ParseState(stateData)
+	if err != nil {
+		return fmt.Errorf("invalid %s in bundle: %w", bundleStateFile, err)
+	}
+	if _, err := os.Stat(cfg.StateFilePath()); err == nil && !stateImportForce {
+		return fmt.Errorf("state file %s already exists; use --force to replace it", cfg.StateFilePath())
+	}
+
+	if err := os.MkdirAll(cfg.Paths.StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if history, ok := bundle.Files[bundleHistoryFile]; ok {
+		if err := writeFileAtomic(sync.HistoryFilePath(cfg.Paths.StateDir), history); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(cfg.StateFilePath(), stateData); err != nil {
+		return err
+	}
+
+	logger.Info("state imported",
+		"path", args[0],
+		"created_at", bundle.Manifest.CreatedAt,
+		"hostname", bundle.Manifest.Hostname,
+		"managed_files", len(state.ManagedFiles),
+		"verified", stateImportVerify)
+	return nil
+}
+
+// writeFileAtomic replaces path with data via a temporary file in the same
+// directory, so a failed import never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resetStateFlags restores the state command flags after a test.
+func resetStateFlags(t *testing.T) {
+	t.Helper()
+	origCfg := cfgFile
+	t.Cleanup(func() {
+		cfgFile = origCfg
+		stateExportOutput = ""
+		stateExportSign = false
+		stateExportKey = ""
+		stateImportVerify = false
+		stateImportPubKey = ""
+		stateImportForce = false
+	})
+}
+
+// writeKeyPair writes a PEM Ed25519 key pair to dir and returns the paths.
+func writeKeyPair(t *testing.T, dir string) (privPath, pubPath string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	privPath = filepath.Join(dir, "key.pem")
+	pubPath = filepath.Join(dir, "pub.pem")
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privPath, pubPath
+}
+
+func TestCLI_StateExportImport(t *testing.T) {
+	resetStateFlags(t)
+	srcDir := t.TempDir()
+	cfgFile = writeTempConfig(t, srcDir)
+	stateDir := filepath.Join(srcDir, "state")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stateJSON := `{"commit":"abc123","managed_files":{}}`
+	if err := os.WriteFile(filepath.Join(stateDir, "state.json"), []byte(stateJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "history.jsonl"), []byte(`{"commit":"abc123"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	privPath, pubPath := writeKeyPair(t, t.TempDir())
+	bundlePath := filepath.Join(t.TempDir(), "state.tar.gz")
+
+	rootCmd.SetArgs([]string{"state", "export", "--sign", "--key", privPath, "-o", bundlePath})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dstDir := t.TempDir()
+	cfgFile = writeTempConfig(t, dstDir)
+	rootCmd.SetArgs([]string{"state", "import", "--verify", "--public-key", pubPath, bundlePath})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dstDir, "state", "state.json"))
+	if err != nil || string(got) != stateJSON {
+		t.Errorf("imported state = %q, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "state", "history.jsonl")); err != nil {
+		t.Errorf("history not imported: %v", err)
+	}
+
+	// A second import must not clobber the state without --force.
+	rootCmd.SetArgs([]string{"state", "import", bundlePath})
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("re-import error = %v, want --force hint", err)
+	}
+	rootCmd.SetArgs([]string{"state", "import", "--force", bundlePath})
+	if err := rootCmd.Execute(); err != nil {
+		t.Errorf("forced re-import: %v", err)
+	}
+}
+
+func TestCLI_StateImport_VerifyRejectsUnsigned(t *testing.T) {
+	resetStateFlags(t)
+	dir := t.TempDir()
+	cfgFile = writeTempConfig(t, dir)
+	stateDir := filepath.Join(dir, "state")
+	_ = os.MkdirAll(stateDir, 0755)
+	_ = os.WriteFile(filepath.Join(stateDir, "state.json"), []byte(`{}`), 0644)
+	_, pubPath := writeKeyPair(t, t.TempDir())
+	bundlePath := filepath.Join(t.TempDir(), "state.tar.gz")
+
+	rootCmd.SetArgs([]string{"state", "export", "-o", bundlePath})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	rootCmd.SetArgs([]string{"state", "import", "--verify", "--public-key", pubPath, "--force", bundlePath})
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("import error = %v, want unsigned bundle error", err)
+	}
+}
+
+func TestCLI_StateExport_FlagErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"sign without key", []string{"state", "export", "--sign"}, "--sign requires --key"},
+		{"verify without public key", []string{"state", "import", "--verify", "b.tar.gz"}, "--verify requires --public-key"},
+		{"no state yet", []string{"state", "export", "-o", "out.tar.gz"}, "no state file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStateFlags(t)
+			cfgFile = writeTempConfig(t, t.TempDir())
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStateBundleFiles_RedactsConfig(t *testing.T) {
+	resetStateFlags(t)
+	dir := t.TempDir()
+	cfgFile = writeTempConfig(t, dir)
+	cfg, err := loadConfig(setupLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Auth.HTTPSTokenCommand = "pass show git-token"
+	_ = os.MkdirAll(cfg.Paths.StateDir, 0755)
+	_ = os.WriteFile(cfg.StateFilePath(), []byte(`{}`), 0644)
+
+	files, err := stateBundleFiles(cfg)
+	if err != nil {
+		t.Fatalf("stateBundleFiles: %v", err)
+	}
+	if _, ok := files["history.jsonl"]; ok {
+		t.Error("missing history file should be skipped")
+	}
+	conf := string(files["config.yaml"])
+	if strings.Contains(conf, "pass show") || !strings.Contains(conf, "[REDACTED]") {
+		t.Errorf("config not redacted:\n%s", conf)
+	}
+}
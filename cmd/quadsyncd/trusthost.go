@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	trustHostKnownHosts  string
+	trustHostFingerprint string
+)
+
+var trustHostCmd = &cobra.Command{
+	Use:   "trust-host [host[:port] | ssh-url]...",
+	Short: "Fetch SSH host keys and record them in the known hosts file",
+	Long: `Trust-host fetches the public keys of SSH git hosts with ssh-keyscan, prints
+their fingerprints and appends keys not yet present to the known hosts file.
+Without arguments it trusts the host of every configured SSH repository.
+
+Keys go to --known-hosts, else auth.ssh_known_hosts_file (per repository when
+set there), else ~/.ssh/known_hosts. Compare the printed fingerprints with the
+ones your git host publishes, or pass --fingerprint to record only a key you
+have verified.`,
+	RunE: runTrustHost,
+}
+
+func init() {
+	trustHostCmd.Flags().StringVar(&trustHostKnownHosts, "known-hosts", "", "known hosts file to update (default: auth.ssh_known_hosts_file or ~/.ssh/known_hosts)")
+	trustHostCmd.Flags().StringVar(&trustHostFingerprint, "fingerprint", "", "only record the key with this SHA256 fingerprint; fail if the host does not offer it")
+	rootCmd.AddCommand(trustHostCmd)
+}
+
+// trustTarget is a host whose keys are recorded in knownHosts.
+type trustTarget struct {
+	host       string
+	port       int
+	knownHosts string
+}
+
+func runTrustHost(cmd *cobra.Command, args []string) error {
+	// The fingerprint listing goes to stdout; keep logs out of it.
+	logsToStderr = true
+	logger := setupLogger()
+
+	var cfg *config.Config
+	if len(args) == 0 || trustHostKnownHosts == "" {
+		var err error
+		if cfg, err = loadConfig(logger); err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+
+	targets, err := trustTargets(cfg, args, trustHostKnownHosts)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		if err := trustHost(cmd, os.Stdout, t, trustHostFingerprint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trustTargets resolves the hosts to trust: the hosts named in args, or
+// every SSH repository in cfg, each paired with the known hosts file to use.
+func trustTargets(cfg *config.Config, args []string, knownHosts string) ([]trustTarget, error) {
+	fileFor := func(auth config.AuthConfig) (string, error) {
+		if knownHosts != "" {
+			return knownHosts, nil
+		}
+		if auth.SSHKnownHostsFile != "" {
+			return auth.SSHKnownHostsFile, nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		return filepath.Join(home, ".ssh", "known_hosts"), nil
+	}
+
+	var targets []trustTarget
+	seen := make(map[trustTarget]bool)
+	add := func(host string, port int, auth config.AuthConfig) error {
+		file, err := fileFor(auth)
+		if err != nil {
+			return err
+		}
+		t := trustTarget{host: host, port: port, knownHosts: file}
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+		return nil
+	}
+
+	if len(args) > 0 {
+		var auth config.AuthConfig
+		if cfg != nil {
+			auth = cfg.Auth
+		}
+		for _, arg := range args {
+			host, port, err := parseTrustHostArg(arg)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(host, port, auth); err != nil {
+				return nil, err
+			}
+		}
+		return targets, nil
+	}
+
+	for _, spec := range cfg.EffectiveRepositories() {
+		host, port, err := git.SSHHost(spec.URL)
+		if err != nil {
+			continue // not an SSH repository
+		}
+		if err := add(host, port, cfg.AuthForSpec(spec)); err != nil {
+			return nil, err
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no SSH repositories configured; name a host to trust")
+	}
+	return targets, nil
+}
+
+// parseTrustHostArg accepts "host", "host:port", "[ipv6]:port" or an SSH
+// repository URL.
+func parseTrustHostArg(arg string) (string, int, error) {
+	if strings.HasPrefix(arg, "git@") || strings.HasPrefix(arg, "ssh://") {
+		return git.SSHHost(arg)
+	}
+	if host, p, err := net.SplitHostPort(arg); err == nil {
+		port, err := strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return "", 0, fmt.Errorf("invalid port in %q", arg)
+		}
+		return host, port, nil
+	}
+	if strings.ContainsAny(arg, "/@") || arg == "" {
+		return "", 0, fmt.Errorf("invalid host %q", arg)
+	}
+	return arg, 22, nil
+}
+
+// trustHost scans t's host keys, prints their fingerprints to w and records
+// them in t.knownHosts. With fingerprint set only the matching key is
+// recorded, and a host that does not offer it is an error.
+func trustHost(cmd *cobra.Command, w io.Writer, t trustTarget, fingerprint string) error {
+	keys, err := git.ScanHostKeys(cmd.Context(), t.host, t.port)
+	if err != nil {
+		return err
+	}
+
+	var trusted []git.HostKey
+	for _, k := range keys {
+		fp, err := k.Fingerprint()
+		if err != nil {
+			return err
+		}
+		if fingerprint != "" && fp != fingerprint {
+			continue
+		}
+		trusted = append(trusted, k)
+	}
+	if len(trusted) == 0 {
+		return fmt.Errorf("%s did not offer a host key with fingerprint %s", t.host, fingerprint)
+	}
+
+	added, err := git.AppendKnownHosts(t.knownHosts, trusted)
+	if err != nil {
+		return err
+	}
+	isAdded := make(map[git.HostKey]bool)
+	for _, k := range added {
+		isAdded[k] = true
+	}
+	for _, k := range trusted {
+		fp, _ := k.Fingerprint()
+		status := "already trusted"
+		if isAdded[k] {
+			status = "added"
+		}
+		_, _ = fmt.Fprintf(w, "%s %s %s (%s)\n", k.Host, k.Type, fp, status)
+	}
+	_, _ = fmt.Fprintf(w, "%d key(s) added to %s\n", len(added), t.knownHosts)
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	testHostKey         = "AAAAC3NzaC1lZDI1NTE5AAAAIKvlfekQ2ioBawsrOG9QtSYvIKY1gnjvB7wOaP9VwUeK"
+	testHostFingerprint = "SHA256:kqIpBKOlnFhnILOA9nCJWnBAH1wKvD7GdLGpk26k0qo"
+)
+
+// fakeKeyscan puts an ssh-keyscan on PATH that prints testHostKey for the
+// scanned host, formatted like the real tool.
+func fakeKeyscan(t *testing.T) {
+	t.Helper()
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+port=22
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -p) port=$2; shift 2 ;;
+    --) shift ;;
+    *) host=$1; shift ;;
+  esac
+done
+if [ "$port" = 22 ]; then name=$host; else name="[$host]:$port"; fi
+echo "# $host:$port SSH-2.0-fake" >&2
+echo "$name ssh-ed25519 ` + testHostKey + `"
+`
+	if err := os.WriteFile(filepath.Join(binDir, "ssh-keyscan"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestParseTrustHostArg(t *testing.T) {
+	tests := []struct {
+		arg      string
+		wantHost string
+		wantPort int
+		wantErr  bool
+	}{
+		{arg: "github.com", wantHost: "github.com", wantPort: 22},
+		{arg: "git.example.com:2222", wantHost: "git.example.com", wantPort: 2222},
+		{arg: "[2001:db8::1]:2222", wantHost: "2001:db8::1", wantPort: 2222},
+		{arg: "git@github.com:org/repo.git", wantHost: "github.com", wantPort: 22},
+		{arg: "ssh://git@git.example.com:7999/org/repo.git", wantHost: "git.example.com", wantPort: 7999},
+		{arg: "host:0", wantErr: true},
+		{arg: "https://github.com/org/repo", wantErr: true},
+		{arg: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		host, port, err := parseTrustHostArg(tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTrustHostArg(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (host != tt.wantHost || port != tt.wantPort) {
+			t.Errorf("parseTrustHostArg(%q) = %s, %d; want %s, %d", tt.arg, host, port, tt.wantHost, tt.wantPort)
+		}
+	}
+}
+
+func TestTrustTargets_FromConfig(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{SSHKnownHostsFile: "/global/known_hosts"},
+		Repositories: []config.RepoSpec{
+			{URL: "git@github.com:org/a.git"},
+			{URL: "git@github.com:org/b.git"},
+			{URL: "https://github.com/org/c.git"},
+			{URL: "ssh://git@git.example.com:2222/d.git", Auth: &config.AuthConfig{SSHKnownHostsFile: "/repo/known_hosts"}},
+		},
+	}
+	got, err := trustTargets(cfg, nil, "")
+	if err != nil {
+		t.Fatalf("trustTargets: %v", err)
+	}
+	want := []trustTarget{
+		{host: "github.com", port: 22, knownHosts: "/global/known_hosts"},
+		{host: "git.example.com", port: 2222, knownHosts: "/repo/known_hosts"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("trustTargets() = %+v, want %+v", got, want)
+	}
+
+	// An explicit file overrides the configured ones.
+	got, err = trustTargets(cfg, nil, "/flag/known_hosts")
+	if err != nil {
+		t.Fatalf("trustTargets: %v", err)
+	}
+	for _, target := range got {
+		if target.knownHosts != "/flag/known_hosts" {
+			t.Errorf("target %+v should use the --known-hosts file", target)
+		}
+	}
+
+	if _, err := trustTargets(&config.Config{Repository: &config.RepoSpec{URL: "https://github.com/org/c.git"}}, nil, ""); err == nil {
+		t.Error("expected an error when no SSH repository is configured")
+	}
+}
+
+func TestTrustHost(t *testing.T) {
+	fakeKeyscan(t)
+	knownHosts := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	target := trustTarget{host: "git.example.com", port: 2222, knownHosts: knownHosts}
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	var out bytes.Buffer
+	if err := trustHost(cmd, &out, target, ""); err != nil {
+		t.Fatalf("trustHost: %v", err)
+	}
+	wantLine := "[git.example.com]:2222 ssh-ed25519 " + testHostKey + "\n"
+	data, err := os.ReadFile(knownHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != wantLine {
+		t.Errorf("known_hosts = %q, want %q", data, wantLine)
+	}
+	if !strings.Contains(out.String(), testHostFingerprint+" (added)") {
+		t.Errorf("output should list the fingerprint as added:\n%s", out.String())
+	}
+
+	// Trusting again does not duplicate the key.
+	out.Reset()
+	if err := trustHost(cmd, &out, target, testHostFingerprint); err != nil {
+		t.Fatalf("trustHost again: %v", err)
+	}
+	if data, _ := os.ReadFile(knownHosts); string(data) != wantLine {
+		t.Errorf("known_hosts after second run = %q, want %q", data, wantLine)
+	}
+	if !strings.Contains(out.String(), "already trusted") {
+		t.Errorf("output should report the key as already trusted:\n%s", out.String())
+	}
+
+	// A fingerprint the host does not offer is refused.
+	other := trustTarget{host: "other.example.com", port: 22, knownHosts: knownHosts}
+	if err := trustHost(cmd, &out, other, "SHA256:doesnotmatch"); err == nil {
+		t.Error("expected an error for a mismatching fingerprint")
+	}
+	if data, _ := os.ReadFile(knownHosts); string(data) != wantLine {
+		t.Errorf("a refused key must not be recorded, known_hosts = %q", data)
+	}
+}
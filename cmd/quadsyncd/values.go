@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/schaermu/quadsyncd/internal/values"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var valuesCmd = &cobra.Command{
+	Use:   "values",
+	Short: "Inspect layered template values",
+}
+
+var valuesShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the resolved values after layering all values files",
+	Long: `Show loads every file listed under values.files in order, deep-merges them
+(later files override earlier ones; maps merge, lists and scalars replace,
+null removes a key) and prints the result as YAML.`,
+	RunE: runValuesShow,
+}
+
+func init() {
+	valuesCmd.AddCommand(valuesShowCmd)
+	rootCmd.AddCommand(valuesCmd)
+}
+
+func runValuesShow(cmd *cobra.Command, args []string) error {
+	// The resolved values go to stdout; keep logs out of it.
+	logsToStderr = true
+
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	logger.Debug("layering values files", "files", cfg.Values.Files)
+	vals, err := values.Load(cfg.Values.Files)
+	if err != nil {
+		return err
+	}
+
+	return writeValues(os.Stdout, vals)
+}
+
+// writeValues encodes vals as YAML.
+func writeValues(w io.Writer, vals values.Values) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(vals); err != nil {
+		return fmt.Errorf("failed to write values: %w", err)
+	}
+	return enc.Close()
+}
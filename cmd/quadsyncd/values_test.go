@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/values"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteValues(t *testing.T) {
+	var buf bytes.Buffer
+	in := values.Values{"env": "prod", "web": map[string]any{"port": 8080}}
+	if err := writeValues(&buf, in); err != nil {
+		t.Fatalf("writeValues: %v", err)
+	}
+	want := "env: prod\nweb:\n  port: 8080\n"
+	if buf.String() != want {
+		t.Errorf("writeValues() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCLI_ValuesShow(t *testing.T) {
+	origCfg := cfgFile
+	origStderr := logsToStderr
+	t.Cleanup(func() {
+		cfgFile = origCfg
+		logsToStderr = origStderr
+	})
+
+	tmpDir := t.TempDir()
+	cfgPath := writeTempConfig(t, tmpDir)
+	f, err := os.OpenFile(cfgPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("values:\n  files: [\"common.yaml\", \"host.yaml\"]\n")
+	_ = f.Close()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "common.yaml"), []byte("web:\n  image: nginx\n  port: 80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "host.yaml"), []byte("web:\n  port: 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfgFile = cfgPath
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	os.Stdout = w
+
+	rootCmd.SetArgs([]string{"values", "show"})
+	execErr := rootCmd.Execute()
+
+	_ = w.Close()
+	os.Stdout = origStdout
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+
+	if execErr != nil {
+		t.Fatalf("values show: %v", execErr)
+	}
+
+	var got map[string]any
+	if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not YAML: %v\n%s", err, buf.String())
+	}
+	web, _ := got["web"].(map[string]any)
+	if web["image"] != "nginx" || web["port"] != 8080 {
+		t.Errorf("resolved values = %v, want image=nginx port=8080", got)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// exitCodeVerifyFailed is returned by `verify` when a managed file is missing
+// or no longer matches the hash recorded at sync time.
+const exitCodeVerifyFailed = 2
+
+// Verify command flags
+var (
+	verifyUnits  []string
+	verifyOutput string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check managed files against the hashes recorded at sync time",
+	Long: `Verify re-hashes every file recorded in the sync state and reports files that
+are missing or whose content no longer matches what quadsyncd wrote. It does
+not fetch repositories or change anything.
+
+Use --unit to check only the files of specific units, e.g. from a service's
+ExecStartPre=quadsyncd verify --unit %n.
+
+Exit codes:
+  0  all checked files match
+  1  an error occurred
+  2  at least one file is missing, modified or unreadable`,
+	Args: cobra.NoArgs,
+	RunE: runVerify,
+}
+
+func init() {
+	verifyCmd.Flags().StringSliceVar(&verifyUnits, "unit", nil, "only verify files belonging to this unit (repeatable)")
+	verifyCmd.Flags().StringVarP(&verifyOutput, "output", "o", outputText, "output format: text or json")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(verifyOutput); err != nil {
+		return err
+	}
+	if verifyOutput == outputJSON {
+		logsToStderr = true
+	}
+
+	logger := setupLogger()
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	state, err := sync.ReadStateFile(cfg.StateFilePath())
+	if err != nil {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+
+	report := sync.VerifyState(state, verifyUnits)
+	if verifyOutput == outputJSON {
+		err = writeVerifyReport(os.Stdout, report)
+	} else {
+		err = printVerifyReport(os.Stdout, report, cfg.Paths.QuadletDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if failures := report.Failures(); len(failures) > 0 {
+		logger.Warn("managed files failed verification", "failed", len(failures), "checked", report.Checked)
+		cmd.SilenceErrors = true
+		return &exitError{code: exitCodeVerifyFailed}
+	}
+	return nil
+}
+
+// printVerifyReport writes the failed files as a table followed by a summary.
+func printVerifyReport(w io.Writer, report *sync.VerifyReport, quadletDir string) error {
+	failures := report.Failures()
+	if len(failures) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "STATUS\tPATH\tDETAIL")
+		for _, f := range failures {
+			detail := ""
+			switch f.Status {
+			case sync.VerifyModified:
+				detail = fmt.Sprintf("expected %s, got %s", shortSHA(f.Expected), shortSHA(f.Actual))
+			case sync.VerifyUnreadable:
+				detail = f.Error
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Status, displayPath(quadletDir, f.Path), detail)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, report.String())
+	return err
+}
+
+// writeVerifyReport encodes report as indented JSON followed by a newline.
+func writeVerifyReport(w io.Writer, report *sync.VerifyReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write verify report: %w", err)
+	}
+	return nil
+}
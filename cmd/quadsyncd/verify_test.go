@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestPrintVerifyReport(t *testing.T) {
+	var buf bytes.Buffer
+	report := &sync.VerifyReport{
+		Checked: 3,
+		Results: []sync.VerifyResult{
+			{Path: "/q/a.container", Status: sync.VerifyOK},
+			{Path: "/q/b.container", Status: sync.VerifyModified, Expected: "1111111111111111", Actual: "2222222222222222"},
+			{Path: "/etc/caddy/Caddyfile", Status: sync.VerifyMissing},
+		},
+	}
+	if err := printVerifyReport(&buf, report, "/q"); err != nil {
+		t.Fatalf("printVerifyReport: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "STATUS") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	if f := strings.Fields(lines[1]); f[0] != "modified" || f[1] != "b.container" || !strings.Contains(lines[1], "111111111111") {
+		t.Errorf("modified row = %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); f[0] != "missing" || f[1] != "/etc/caddy/Caddyfile" {
+		t.Errorf("missing row = %q", lines[2])
+	}
+	if lines[3] != "3 files checked, 2 failed" {
+		t.Errorf("summary = %q", lines[3])
+	}
+}
+
+func TestCLI_Verify(t *testing.T) {
+	origCfg, origUnits, origOut := cfgFile, verifyUnits, verifyOutput
+	origLogsToStderr := logsToStderr
+	t.Cleanup(func() {
+		cfgFile, verifyUnits, verifyOutput = origCfg, origUnits, origOut
+		logsToStderr = origLogsToStderr
+	})
+
+	tmpDir := t.TempDir()
+	cfgFile = writeTempConfig(t, tmpDir)
+	quadletDir := filepath.Join(tmpDir, "quadlets")
+	stateDir := filepath.Join(tmpDir, "state")
+	for _, d := range []string{quadletDir, stateDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	appPath := filepath.Join(quadletDir, "app.container")
+	if err := os.WriteFile(appPath, []byte("[Container]\nImage=a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Record the hash via a verify pass over a provisional state.
+	probe := sync.VerifyState(&sync.State{ManagedFiles: map[string]sync.ManagedFile{appPath: {}}}, nil)
+	state := sync.State{ManagedFiles: map[string]sync.ManagedFile{
+		appPath: {SourcePath: "app.container", Hash: probe.Results[0].Actual},
+		filepath.Join(quadletDir, "db.container"): {SourcePath: "db.container", Hash: "deadbeef"},
+	}}
+	data, _ := json.Marshal(state)
+	if err := os.WriteFile(filepath.Join(stateDir, "state.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (string, error) {
+		origStdout := os.Stdout
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("os.Pipe: %v", err)
+		}
+		os.Stdout = w
+		verifyUnits, verifyOutput = nil, outputText
+		rootCmd.SetArgs(append([]string{"verify"}, args...))
+		execErr := rootCmd.Execute()
+		_ = w.Close()
+		os.Stdout = origStdout
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(r)
+		return buf.String(), execErr
+	}
+
+	// The missing db.container fails the full check with exit code 2.
+	out, err := run()
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != exitCodeVerifyFailed {
+		t.Fatalf("expected exit code %d, got %v", exitCodeVerifyFailed, err)
+	}
+	if !strings.Contains(out, "missing") || !strings.Contains(out, "db.container") {
+		t.Errorf("output missing failure row:\n%s", out)
+	}
+
+	// Restricting to the intact unit succeeds.
+	out, err = run("--unit", "app.service")
+	if err != nil {
+		t.Fatalf("verify --unit app.service: %v", err)
+	}
+	if !strings.Contains(out, "1 files checked, 0 failed") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	// JSON output carries per-file results.
+	out, err = run("--output", "json")
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected exit error, got %v", err)
+	}
+	var report sync.VerifyReport
+	if jerr := json.Unmarshal([]byte(out), &report); jerr != nil {
+		t.Fatalf("invalid JSON: %v\n%s", jerr, out)
+	}
+	if report.Checked != 2 || len(report.Failures()) != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
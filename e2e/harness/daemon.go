@@ -0,0 +1,130 @@
+//go:build e2e_discovery
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// HostAddr returns the host address ("127.0.0.1:<port>") that a published
+// container port is reachable on.
+func (s *Suite) HostAddr(ctx context.Context, containerPort int) (string, error) {
+	if s.ContainerID == "" {
+		return "", fmt.Errorf("container not started")
+	}
+
+	out, err := exec.CommandContext(ctx,
+		s.Runtime, "port", s.ContainerID, fmt.Sprintf("%d/tcp", containerPort),
+	).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s port: %w", s.Runtime, err)
+	}
+
+	// The port command may print one line per address family; prefer IPv4.
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "127.0.0.1:") || strings.HasPrefix(line, "0.0.0.0:") {
+			return strings.Replace(line, "0.0.0.0:", "127.0.0.1:", 1), nil
+		}
+	}
+	return "", fmt.Errorf("port %d is not published (%s port output: %q)", containerPort, s.Runtime, out)
+}
+
+// StartUserDaemon runs cmd as a transient systemd user service named unit,
+// so the daemon is supervised by the user manager, logs to the user journal
+// and is included in diagnostics.
+func (s *Suite) StartUserDaemon(ctx context.Context, unit string, cmd ...string) error {
+	s.Logf("Starting user daemon %s: %s", unit, strings.Join(cmd, " "))
+
+	args := []string{"systemd-run", "--user", "--unit=" + unit, "--collect", "--quiet"}
+	for k, v := range s.UserEnv {
+		args = append(args, "--setenv="+k+"="+v)
+	}
+	args = append(args, cmd...)
+
+	if _, err := s.MustExecUser(ctx, args...); err != nil {
+		return fmt.Errorf("systemd-run %s: %w", unit, err)
+	}
+	s.daemons = append(s.daemons, unit)
+
+	if err := s.WaitFor(ctx, 30*time.Second, func(ctx context.Context) (bool, error) {
+		res, err := s.ExecUser(ctx, "systemctl", "--user", "is-active", unit)
+		if err != nil {
+			return false, err
+		}
+		state := strings.TrimSpace(res.Stdout)
+		if state == "failed" || state == "inactive" {
+			return false, fmt.Errorf("daemon %s is %s", unit, state)
+		}
+		return state == "active", nil
+	}); err != nil {
+		s.Logf("%s", s.UserDaemonLogs(ctx, unit))
+		return err
+	}
+
+	s.Logf("User daemon %s is active", unit)
+	return nil
+}
+
+// StopUserDaemon stops a daemon started with StartUserDaemon.
+func (s *Suite) StopUserDaemon(ctx context.Context, unit string) error {
+	s.Logf("Stopping user daemon %s", unit)
+	if _, err := s.MustExecUser(ctx, "systemctl", "--user", "stop", unit); err != nil {
+		return fmt.Errorf("stop %s: %w", unit, err)
+	}
+	return nil
+}
+
+// UserDaemonLogs returns the user journal of a supervised daemon.
+func (s *Suite) UserDaemonLogs(ctx context.Context, unit string) string {
+	res, _ := s.ExecUser(ctx, "journalctl", "--user", "-u", unit, "--no-pager", "-n", "300")
+	return res.Stdout + res.Stderr
+}
+
+// WaitForHTTP polls url from the host until the server answers with any HTTP
+// status, or timeout elapses.
+func (s *Suite) WaitForHTTP(ctx context.Context, url string, timeout time.Duration) error {
+	client := &http.Client{Timeout: 2 * time.Second}
+	return s.WaitFor(ctx, timeout, func(ctx context.Context) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return false, nil
+		}
+		_ = resp.Body.Close()
+		return true, nil
+	})
+}
+
+// WaitFor polls cond every second until it reports true, returns an error,
+// or timeout elapses.
+func (s *Suite) WaitFor(ctx context.Context, timeout time.Duration, cond func(context.Context) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		ok, err := cond(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s", timeout)
+		case <-ticker.C:
+		}
+	}
+}
@@ -40,16 +40,16 @@ func (s *Suite) CollectDiagnostics(ctx context.Context) (*Diagnostics, error) {
 		{"systemctl-user-service", []string{"systemctl", "status", fmt.Sprintf("user@%d.service", s.UID), "--no-pager"}},
 		{"loginctl-user-status", []string{"loginctl", "user-status", s.User, "--no-pager"}},
 		{"journalctl-boot", []string{"journalctl", "-b", "--no-pager", "-n", "300"}},
-		{"docker-logs", nil}, // special case, handled separately
+		{"container-logs", nil}, // special case, handled separately
 	}
 
 	for _, item := range rootCommands {
-		if item.name == "docker-logs" {
-			// Special case: get docker logs from outside the container
-			output, exitCode := s.getDockerLogs(ctx)
+		if item.name == "container-logs" {
+			// Special case: get container logs from outside the container
+			output, exitCode := s.getContainerLogs(ctx)
 			diag.Items = append(diag.Items, DiagItem{
 				Name:     item.name,
-				Cmd:      []string{"docker", "logs", s.ContainerID},
+				Cmd:      []string{s.Runtime, "logs", s.ContainerID},
 				ExitCode: exitCode,
 				Output:   output,
 			})
@@ -78,6 +78,13 @@ func (s *Suite) CollectDiagnostics(ctx context.Context) (*Diagnostics, error) {
 		{"cat-state-json", []string{"cat", s.Home + "/.local/state/quadsyncd/state.json"}},
 	}
 
+	for _, unit := range s.daemons {
+		userCommands = append(userCommands, struct {
+			name string
+			cmd  []string
+		}{"journalctl-user-" + unit, []string{"journalctl", "--user", "-u", unit, "--no-pager", "-n", "300"}})
+	}
+
 	for _, item := range userCommands {
 		res, _ := s.ExecUser(ctx, item.cmd...)
 		diag.Items = append(diag.Items, DiagItem{
@@ -91,13 +98,13 @@ func (s *Suite) CollectDiagnostics(ctx context.Context) (*Diagnostics, error) {
 	return diag, nil
 }
 
-// getDockerLogs gets docker logs from outside the container
-func (s *Suite) getDockerLogs(ctx context.Context) (string, int) {
+// getContainerLogs gets the container logs from outside the container
+func (s *Suite) getContainerLogs(ctx context.Context) (string, int) {
 	if s.ContainerID == "" {
 		return "", 0
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", "logs", s.ContainerID)
+	cmd := exec.CommandContext(ctx, s.Runtime, "logs", s.ContainerID)
 	output, err := cmd.CombinedOutput()
 	exitCode := 0
 	if err != nil {
@@ -0,0 +1,134 @@
+//go:build e2e_discovery
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// packagedUnitsDir is the repo directory holding the shipped user units.
+const packagedUnitsDir = "packaging/systemd/user"
+
+// InstallBinaryUser copies the image's quadsyncd binary to ~/.local/bin, the
+// path the packaged user units execute.
+func (s *Suite) InstallBinaryUser(ctx context.Context) error {
+	binDir := s.Home + "/.local/bin"
+	if err := s.MkdirUser(ctx, binDir, 0755); err != nil {
+		return fmt.Errorf("mkdir %s: %w", binDir, err)
+	}
+	if _, err := s.MustExecUser(ctx, "install", "-m", "0755", "/usr/local/bin/quadsyncd", binDir+"/quadsyncd"); err != nil {
+		return fmt.Errorf("install binary: %w", err)
+	}
+	return nil
+}
+
+// InstallPackagedUnits copies the named units from packaging/systemd/user
+// into ~/.config/systemd/user and reloads the user manager, mirroring the
+// documented installation steps.
+func (s *Suite) InstallPackagedUnits(ctx context.Context, names ...string) error {
+	projectRoot, err := testutil.FindProjectRoot()
+	if err != nil {
+		return fmt.Errorf("get project root: %w", err)
+	}
+
+	units := make(map[string][]byte, len(names))
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(projectRoot, packagedUnitsDir, name))
+		if err != nil {
+			return fmt.Errorf("read packaged unit: %w", err)
+		}
+		units[name] = content
+	}
+	return s.InstallUserUnits(ctx, units)
+}
+
+// InstallUserUnits writes unit files (name -> content) into
+// ~/.config/systemd/user and runs daemon-reload.
+func (s *Suite) InstallUserUnits(ctx context.Context, units map[string][]byte) error {
+	unitDir := s.Home + "/.config/systemd/user"
+	for name, content := range units {
+		s.Logf("Installing user unit %s", name)
+		if err := s.WriteFileUser(ctx, unitDir+"/"+name, content, 0644); err != nil {
+			return fmt.Errorf("write unit %s: %w", name, err)
+		}
+	}
+	if _, err := s.MustExecUser(ctx, "systemctl", "--user", "daemon-reload"); err != nil {
+		return fmt.Errorf("daemon-reload: %w", err)
+	}
+	return nil
+}
+
+// UserUnitState returns the ActiveState of a user unit (e.g. "active",
+// "inactive", "failed").
+func (s *Suite) UserUnitState(ctx context.Context, unit string) (string, error) {
+	res, err := s.ExecUser(ctx, "systemctl", "--user", "show", "-p", "ActiveState", "--value", unit)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(res.Stdout), nil
+}
+
+// UserJournal returns the user journal for unit across all boots. Pass an
+// empty unit to read the whole user journal.
+func (s *Suite) UserJournal(ctx context.Context, unit string) (string, error) {
+	args := []string{"journalctl", "--user", "--no-pager", "-o", "cat"}
+	if unit != "" {
+		args = append(args, "-u", unit)
+	}
+	res, err := s.ExecUser(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+	return res.Stdout, nil
+}
+
+// AssertJournalContains returns an error listing the missing entries if the
+// user journal of unit does not contain every substring in want.
+func (s *Suite) AssertJournalContains(ctx context.Context, unit string, want ...string) error {
+	journal, err := s.UserJournal(ctx, unit)
+	if err != nil {
+		return fmt.Errorf("read journal: %w", err)
+	}
+	var missing []string
+	for _, w := range want {
+		if !strings.Contains(journal, w) {
+			missing = append(missing, w)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("journal for %s is missing %q\n--- journal ---\n%s", unit, missing, journal)
+	}
+	return nil
+}
+
+// Reboot simulates a host reboot by restarting the container (which also
+// clears the /run and /tmp tmpfs mounts) and re-running the readiness probe.
+// Persistent state under the user's home directory survives. The suite must
+// be created with WithRebootable.
+func (s *Suite) Reboot(ctx context.Context) error {
+	if s.ContainerID == "" {
+		return fmt.Errorf("container not started")
+	}
+	if !s.Rebootable {
+		return fmt.Errorf("suite %s is not rebootable (use WithRebootable)", s.Name)
+	}
+
+	s.Logf("Rebooting container %s", s.ContainerID)
+	cmd := exec.CommandContext(ctx, s.Runtime, "restart", s.ContainerID)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s restart: %w: %s", s.Runtime, err, strings.TrimSpace(string(out)))
+	}
+
+	// Transient daemons do not survive a reboot.
+	s.daemons = nil
+	s.UserEnv = make(map[string]string)
+
+	return s.Ready(ctx)
+}
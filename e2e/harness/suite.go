@@ -25,6 +25,12 @@ const (
 	defaultHome          = "/home/quadsync"
 )
 
+// Container runtimes supported by the harness
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
 // Suite orchestrates E2E tests in a systemd container
 type Suite struct {
 	// immutable config
@@ -33,6 +39,15 @@ type Suite struct {
 	DockerfileDir string
 	Timeout       time.Duration
 	KeepContainer bool
+	// Runtime is the container CLI used to build and run the SUT
+	// (RuntimeDocker or RuntimePodman).
+	Runtime string
+	// PublishPorts are container TCP ports published on a random host port
+	// bound to 127.0.0.1; resolve them with HostAddr.
+	PublishPorts []int
+	// Rebootable starts the container without --rm so Reboot can restart it;
+	// StopAndRemove then removes it explicitly.
+	Rebootable bool
 
 	// runtime state
 	ContainerID string
@@ -43,6 +58,9 @@ type Suite struct {
 	// computed env for user exec
 	UserEnv map[string]string
 
+	// transient user units started via StartUserDaemon
+	daemons []string
+
 	// optional logger hook
 	Logf func(format string, args ...any)
 
@@ -73,6 +91,21 @@ func WithKeepContainer(v bool) SuiteOption {
 	return func(s *Suite) { s.KeepContainer = v }
 }
 
+// WithRuntime sets the container runtime (RuntimeDocker or RuntimePodman)
+func WithRuntime(runtime string) SuiteOption {
+	return func(s *Suite) { s.Runtime = runtime }
+}
+
+// WithPublishedPort publishes a container TCP port to the host
+func WithPublishedPort(port int) SuiteOption {
+	return func(s *Suite) { s.PublishPorts = append(s.PublishPorts, port) }
+}
+
+// WithRebootable keeps the container across restarts so Reboot can be used
+func WithRebootable() SuiteOption {
+	return func(s *Suite) { s.Rebootable = true }
+}
+
 // WithUser sets custom user details
 func WithUser(user string, uid int, home string) SuiteOption {
 	return func(s *Suite) {
@@ -95,6 +128,7 @@ func NewSuite(name string, t *testing.T, opts ...SuiteOption) *Suite {
 		DockerfileDir: defaultDockerfileDir,
 		Timeout:       defaultTimeout,
 		KeepContainer: os.Getenv("E2E_KEEP_CONTAINER") == "1",
+		Runtime:       RuntimeDocker,
 		UID:           defaultUID,
 		User:          defaultUser,
 		Home:          defaultHome,
@@ -116,6 +150,12 @@ func NewSuite(name string, t *testing.T, opts ...SuiteOption) *Suite {
 			s.Timeout = d
 		}
 	}
+	if runtime := os.Getenv("E2E_RUNTIME"); runtime != "" {
+		s.Runtime = runtime
+	}
+	if s.Runtime != RuntimeDocker && s.Runtime != RuntimePodman {
+		t.Fatalf("unsupported container runtime %q (want %s or %s)", s.Runtime, RuntimeDocker, RuntimePodman)
+	}
 
 	return s
 }
@@ -131,7 +171,7 @@ func (s *Suite) BuildImage(ctx context.Context) error {
 	}
 
 	cmd := exec.CommandContext(ctx,
-		"docker", "build",
+		s.Runtime, "build",
 		"-t", s.ImageTag,
 		"-f", filepath.Join(s.DockerfileDir, "Dockerfile"),
 		projectRoot, // build context is project root
@@ -144,7 +184,7 @@ func (s *Suite) BuildImage(ctx context.Context) error {
 	if err := cmd.Run(); err != nil {
 		s.Logf("build stdout: %s", stdout.String())
 		s.Logf("build stderr: %s", stderr.String())
-		return fmt.Errorf("docker build: %w", err)
+		return fmt.Errorf("%s build: %w", s.Runtime, err)
 	}
 
 	s.Logf("Image %s built successfully", s.ImageTag)
@@ -155,22 +195,21 @@ func (s *Suite) BuildImage(ctx context.Context) error {
 func (s *Suite) StartContainer(ctx context.Context) error {
 	s.Logf("Starting container")
 
-	cmd := exec.CommandContext(ctx,
-		"docker", "run",
-		"-d",
-		"--rm",
-		"--privileged",
-		"--cgroupns=host",
-		"-v", "/sys/fs/cgroup:/sys/fs/cgroup:rw",
-		"--tmpfs", "/run",
-		"--tmpfs", "/tmp",
-		"-e", "container=docker",
-		s.ImageTag,
-	)
+	args := []string{"run", "-d"}
+	if !s.Rebootable {
+		args = append(args, "--rm")
+	}
+	args = append(args, s.systemdRunArgs()...)
+	for _, port := range s.PublishPorts {
+		args = append(args, "-p", fmt.Sprintf("127.0.0.1::%d", port))
+	}
+	args = append(args, s.ImageTag)
+
+	cmd := exec.CommandContext(ctx, s.Runtime, args...)
 
 	out, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("docker run: %w", err)
+		return fmt.Errorf("%s run: %w", s.Runtime, err)
 	}
 
 	s.ContainerID = strings.TrimSpace(string(out))
@@ -178,6 +217,28 @@ func (s *Suite) StartContainer(ctx context.Context) error {
 	return nil
 }
 
+// systemdRunArgs returns the run flags needed to boot systemd as PID 1
+func (s *Suite) systemdRunArgs() []string {
+	if s.Runtime == RuntimePodman {
+		// Rootless podman cannot share the host cgroup tree; --systemd=always
+		// mounts a private cgroup hierarchy plus tmpfs on /run and /tmp and
+		// sets container=podman.
+		return []string{
+			"--privileged",
+			"--systemd=always",
+			"--cgroupns=private",
+		}
+	}
+	return []string{
+		"--privileged",
+		"--cgroupns=host",
+		"-v", "/sys/fs/cgroup:/sys/fs/cgroup:rw",
+		"--tmpfs", "/run",
+		"--tmpfs", "/tmp",
+		"-e", "container=docker",
+	}
+}
+
 // StopAndRemove stops and removes the container
 func (s *Suite) StopAndRemove(ctx context.Context) error {
 	if s.ContainerID == "" {
@@ -186,15 +247,21 @@ func (s *Suite) StopAndRemove(ctx context.Context) error {
 
 	if s.KeepContainer && s.t.Failed() {
 		s.Logf("Test failed and E2E_KEEP_CONTAINER=1, keeping container %s", s.ContainerID)
-		s.Logf("To inspect: docker exec -it %s /bin/bash", s.ContainerID)
-		s.Logf("To cleanup: docker stop %s", s.ContainerID)
+		s.Logf("To inspect: %s exec -it %s /bin/bash", s.Runtime, s.ContainerID)
+		s.Logf("To cleanup: %s stop %s", s.Runtime, s.ContainerID)
 		return nil
 	}
 
 	s.Logf("Stopping container %s", s.ContainerID)
-	cmd := exec.CommandContext(ctx, "docker", "stop", s.ContainerID)
+	cmd := exec.CommandContext(ctx, s.Runtime, "stop", s.ContainerID)
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker stop: %w", err)
+		return fmt.Errorf("%s stop: %w", s.Runtime, err)
+	}
+
+	if s.Rebootable {
+		if err := exec.CommandContext(ctx, s.Runtime, "rm", s.ContainerID).Run(); err != nil {
+			return fmt.Errorf("%s rm: %w", s.Runtime, err)
+		}
 	}
 
 	return nil
@@ -303,7 +370,7 @@ func (s *Suite) ExecRoot(ctx context.Context, cmd ...string) (ExecResult, error)
 	}
 
 	args := append([]string{"exec", s.ContainerID}, cmd...)
-	execCmd := exec.CommandContext(ctx, "docker", args...)
+	execCmd := exec.CommandContext(ctx, s.Runtime, args...)
 
 	var stdout, stderr bytes.Buffer
 	execCmd.Stdout = &stdout
@@ -347,7 +414,7 @@ func (s *Suite) ExecUserEnv(ctx context.Context, env map[string]string, cmd ...s
 		finalEnv[k] = v
 	}
 
-	// Build docker exec command
+	// Build exec command
 	args := []string{"exec"}
 	for k, v := range finalEnv {
 		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
@@ -356,7 +423,7 @@ func (s *Suite) ExecUserEnv(ctx context.Context, env map[string]string, cmd ...s
 	args = append(args, s.ContainerID)
 	args = append(args, cmd...)
 
-	execCmd := exec.CommandContext(ctx, "docker", args...)
+	execCmd := exec.CommandContext(ctx, s.Runtime, args...)
 
 	var stdout, stderr bytes.Buffer
 	execCmd.Stdout = &stdout
@@ -418,7 +485,7 @@ func (s *Suite) WriteFileRoot(ctx context.Context, path string, content []byte,
 
 	// Write file
 	cmd := exec.CommandContext(ctx,
-		"docker", "exec", "-i", s.ContainerID,
+		s.Runtime, "exec", "-i", s.ContainerID,
 		"sh", "-c", fmt.Sprintf("cat > %s", path),
 	)
 	cmd.Stdin = bytes.NewReader(content)
@@ -453,7 +520,7 @@ func (s *Suite) WriteFileUser(ctx context.Context, path string, content []byte,
 	args = append(args, s.ContainerID)
 	args = append(args, "sh", "-c", fmt.Sprintf("cat > %s", path))
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := exec.CommandContext(ctx, s.Runtime, args...)
 	cmd.Stdin = bytes.NewReader(content)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("write file: %w", err)
@@ -0,0 +1,48 @@
+//go:build e2e_discovery
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/e2e/harness"
+)
+
+// initHelloRepo creates the config dir and a local git repo at repoPath with
+// a single committed hello.container whose description is desc.
+func initHelloRepo(t *testing.T, s *harness.Suite, ctx context.Context, desc string) {
+	t.Helper()
+
+	if err := s.MkdirUser(ctx, "/home/quadsync/.config/quadsyncd", 0755); err != nil {
+		t.Fatalf("mkdir config dir: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"git", "init", "-b", "main", repoPath},
+		{"git", "-C", repoPath, "config", "user.email", "test@example.com"},
+		{"git", "-C", repoPath, "config", "user.name", "Test User"},
+	} {
+		if _, err := s.MustExecUser(ctx, args...); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	quadletContent := []byte(`[Unit]
+Description=` + desc + `
+
+[Container]
+ContainerName=quadsyncd-e2e-hello
+Image=alpine:3.20
+Exec=/bin/sleep 3600
+`)
+	if err := s.WriteFileUser(ctx, repoPath+"/hello.container", quadletContent, 0644); err != nil {
+		t.Fatalf("write quadlet: %v", err)
+	}
+	if _, err := s.MustExecUser(ctx, "git", "-C", repoPath, "add", "hello.container"); err != nil {
+		t.Fatalf("git add: %v", err)
+	}
+	if _, err := s.MustExecUser(ctx, "git", "-C", repoPath, "commit", "-m", "Initial commit"); err != nil {
+		t.Fatalf("git commit: %v", err)
+	}
+}
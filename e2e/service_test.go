@@ -0,0 +1,126 @@
+//go:build e2e_discovery
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/e2e/harness"
+)
+
+const (
+	syncServiceUnit = "quadsyncd-sync.service"
+	syncTimerUnit   = "quadsyncd-sync.timer"
+)
+
+// TestUserService runs quadsyncd the way it is deployed: as the packaged
+// oneshot user service driven by a timer, and verifies that synced quadlets,
+// state and the enabled timer survive a reboot.
+func TestUserService(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+
+	suite := harness.NewSuite("service", t, harness.WithRebootable())
+
+	if err := suite.BuildImage(ctx); err != nil {
+		t.Fatalf("build image: %v", err)
+	}
+	if err := suite.StartContainer(ctx); err != nil {
+		t.Fatalf("start container: %v", err)
+	}
+	defer func() {
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cleanupCancel()
+
+		if t.Failed() {
+			suite.DumpDiagnostics(cleanupCtx)
+		}
+		if err := suite.StopAndRemove(cleanupCtx); err != nil {
+			t.Logf("cleanup: stop and remove container: %v", err)
+		}
+	}()
+
+	if err := suite.Ready(ctx); err != nil {
+		t.Fatalf("readiness probe failed: %v", err)
+	}
+
+	initHelloRepo(t, suite, ctx, "quadsyncd e2e hello (service)")
+	config := fmt.Sprintf(`repository:
+  url: %s
+  ref: refs/heads/main
+
+paths:
+  quadlet_dir: %s
+  state_dir: %s
+
+sync:
+  prune: true
+  restart: none
+`, repoPath, quadletDir, stateDir)
+	if err := suite.WriteFileUser(ctx, configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := suite.InstallBinaryUser(ctx); err != nil {
+		t.Fatalf("install binary: %v", err)
+	}
+	if err := suite.InstallPackagedUnits(ctx, syncServiceUnit, syncTimerUnit); err != nil {
+		t.Fatalf("install units: %v", err)
+	}
+
+	t.Run("A_ServiceSyncs", func(t *testing.T) {
+		// start blocks until the oneshot service has finished.
+		if _, err := suite.MustExecUser(ctx, "systemctl", "--user", "start", syncServiceUnit); err != nil {
+			t.Fatalf("start %s: %v", syncServiceUnit, err)
+		}
+		if res, _ := suite.ExecUser(ctx, "test", "-f", quadletFile); res.ExitCode != 0 {
+			t.Error("quadlet file does not exist after service run")
+		}
+		if res, _ := suite.ExecUser(ctx, "systemctl", "--user", "cat", "hello.service"); res.ExitCode != 0 {
+			t.Errorf("hello.service not generated\nstdout: %s\nstderr: %s", res.Stdout, res.Stderr)
+		}
+		if err := suite.AssertJournalContains(ctx, syncServiceUnit, "starting sync operation", "sync completed successfully"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("B_TimerEnabled", func(t *testing.T) {
+		if _, err := suite.MustExecUser(ctx, "systemctl", "--user", "enable", "--now", syncTimerUnit); err != nil {
+			t.Fatalf("enable timer: %v", err)
+		}
+		state, err := suite.UserUnitState(ctx, syncTimerUnit)
+		if err != nil || state != "active" {
+			t.Errorf("%s state = %q (%v), want active", syncTimerUnit, state, err)
+		}
+	})
+
+	t.Run("C_StateSurvivesReboot", func(t *testing.T) {
+		if err := suite.Reboot(ctx); err != nil {
+			t.Fatalf("reboot: %v", err)
+		}
+
+		if res, _ := suite.ExecUser(ctx, "test", "-f", quadletFile); res.ExitCode != 0 {
+			t.Error("quadlet file missing after reboot")
+		}
+		stateRes, _ := suite.ExecUser(ctx, "cat", statePath)
+		if !strings.Contains(stateRes.Stdout, "hello.container") {
+			t.Errorf("state lost after reboot:\n%s", stateRes.Stdout)
+		}
+		// The generator runs again when the user manager starts.
+		if res, _ := suite.ExecUser(ctx, "systemctl", "--user", "cat", "hello.service"); res.ExitCode != 0 {
+			t.Errorf("hello.service not regenerated after reboot\nstdout: %s\nstderr: %s", res.Stdout, res.Stderr)
+		}
+		state, err := suite.UserUnitState(ctx, syncTimerUnit)
+		if err != nil || state != "active" {
+			t.Errorf("%s state after reboot = %q (%v), want active", syncTimerUnit, state, err)
+		}
+		// Journal from before the reboot is still readable.
+		if err := suite.AssertJournalContains(ctx, syncServiceUnit, "sync completed successfully"); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+//go:build e2e_discovery
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/e2e/harness"
+)
+
+const (
+	webhookPort       = 8787
+	webhookSecret     = "e2e-webhook-secret"
+	webhookSecretPath = "/home/quadsync/.config/quadsyncd/webhook_secret"
+	webhookUnit       = "quadsyncd-e2e-serve"
+)
+
+func TestWebhook(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	suite := harness.NewSuite("webhook", t, harness.WithPublishedPort(webhookPort))
+
+	if err := suite.BuildImage(ctx); err != nil {
+		t.Fatalf("build image: %v", err)
+	}
+	if err := suite.StartContainer(ctx); err != nil {
+		t.Fatalf("start container: %v", err)
+	}
+	defer func() {
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cleanupCancel()
+
+		if t.Failed() {
+			suite.DumpDiagnostics(cleanupCtx)
+		}
+		if err := suite.StopAndRemove(cleanupCtx); err != nil {
+			t.Logf("cleanup: stop and remove container: %v", err)
+		}
+	}()
+
+	if err := suite.Ready(ctx); err != nil {
+		t.Fatalf("readiness probe failed: %v", err)
+	}
+
+	provisionWebhookSuite(t, suite, ctx)
+
+	// Skip the initial sync so the quadlet can only arrive via the webhook.
+	if err := suite.StartUserDaemon(ctx, webhookUnit,
+		"/usr/local/bin/quadsyncd", "serve", "--config", configPath, "--skip-initial-sync"); err != nil {
+		t.Fatalf("start quadsyncd serve: %v", err)
+	}
+
+	addr, err := suite.HostAddr(ctx, webhookPort)
+	if err != nil {
+		t.Fatalf("resolve published port: %v", err)
+	}
+	webhookURL := "http://" + addr + "/webhook"
+	if err := suite.WaitForHTTP(ctx, "http://"+addr+"/", time.Minute); err != nil {
+		t.Fatalf("webhook server not reachable at %s: %v", addr, err)
+	}
+
+	t.Run("A_InvalidSignatureRejected", func(t *testing.T) {
+		status := postWebhook(t, ctx, webhookURL, pushPayload(t), "wrong-secret")
+		if status != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", status, http.StatusForbidden)
+		}
+		if res, _ := suite.ExecUser(ctx, "test", "-f", quadletFile); res.ExitCode == 0 {
+			t.Error("quadlet file must not be synced for a rejected webhook")
+		}
+	})
+
+	t.Run("B_SignedPushSyncsQuadlet", func(t *testing.T) {
+		status := postWebhook(t, ctx, webhookURL, pushPayload(t), webhookSecret)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d, want %d", status, http.StatusOK)
+		}
+
+		// Debounce plus sync; poll rather than sleep.
+		err := suite.WaitFor(ctx, 2*time.Minute, func(ctx context.Context) (bool, error) {
+			res, err := suite.ExecUser(ctx, "test", "-f", quadletFile)
+			return err == nil && res.ExitCode == 0, err
+		})
+		if err != nil {
+			t.Fatalf("quadlet file not synced after webhook: %v\n%s", err, suite.UserDaemonLogs(ctx, webhookUnit))
+		}
+
+		err = suite.WaitFor(ctx, time.Minute, func(ctx context.Context) (bool, error) {
+			res, err := suite.ExecUser(ctx, "systemctl", "--user", "cat", "hello.service")
+			return err == nil && res.ExitCode == 0, err
+		})
+		if err != nil {
+			t.Errorf("hello.service not generated after webhook sync: %v", err)
+		}
+
+		logs := suite.UserDaemonLogs(ctx, webhookUnit)
+		if !strings.Contains(logs, "webhook accepted") {
+			t.Errorf("expected 'webhook accepted' in daemon journal:\n%s", logs)
+		}
+	})
+
+	if err := suite.StopUserDaemon(ctx, webhookUnit); err != nil {
+		t.Errorf("stop daemon: %v", err)
+	}
+}
+
+// provisionWebhookSuite creates the repo, webhook secret and a serve-enabled
+// config listening on all interfaces so the published port reaches it.
+func provisionWebhookSuite(t *testing.T, s *harness.Suite, ctx context.Context) {
+	t.Helper()
+	s.Logf("Provisioning webhook suite")
+
+	initHelloRepo(t, s, ctx, "quadsyncd e2e hello (webhook)")
+
+	if err := s.WriteFileUser(ctx, webhookSecretPath, []byte(webhookSecret), 0600); err != nil {
+		t.Fatalf("write webhook secret: %v", err)
+	}
+
+	config := fmt.Sprintf(`repository:
+  url: %s
+  ref: refs/heads/main
+
+paths:
+  quadlet_dir: %s
+  state_dir: %s
+
+sync:
+  prune: true
+  restart: none
+
+serve:
+  enabled: true
+  listen_addr: "0.0.0.0:%d"
+  github_webhook_secret_file: %s
+  allowed_event_types: ["push"]
+  allowed_refs: ["refs/heads/main"]
+`, repoPath, quadletDir, stateDir, webhookPort, webhookSecretPath)
+
+	if err := s.WriteFileUser(ctx, configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	s.Logf("Webhook suite provisioned")
+}
+
+// pushPayload builds a GitHub push event for the local repo. The repository
+// full name is derived from the clone URL the same way the server derives it
+// from the configured URL.
+func pushPayload(t *testing.T) []byte {
+	t.Helper()
+	payload := map[string]any{
+		"ref":   "refs/heads/main",
+		"after": "0000000000000000000000000000000000000000",
+		"repository": map[string]string{
+			"full_name": strings.TrimPrefix(repoPath, "/"),
+			"clone_url": "file://" + repoPath,
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	return body
+}
+
+// postWebhook sends body as a push event signed with secret and returns the
+// HTTP status code.
+func postWebhook(t *testing.T, ctx context.Context, url string, body []byte, secret string) int {
+	t.Helper()
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", signature)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post webhook: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	return resp.StatusCode
+}
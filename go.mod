@@ -3,6 +3,8 @@ module github.com/schaermu/quadsyncd
 go 1.26.0
 
 require (
+	github.com/fsnotify/fsnotify v1.5.4
+	github.com/godbus/dbus/v5 v5.2.2
 	github.com/golangci/golangci-lint v1.64.8
 	github.com/spf13/cobra v1.10.2
 	golang.org/x/vuln v1.1.4
@@ -52,7 +54,6 @@ require (
 	github.com/fatih/color v1.18.0 // indirect
 	github.com/fatih/structtag v1.2.0 // indirect
 	github.com/firefart/nonamedreturns v1.0.5 // indirect
-	github.com/fsnotify/fsnotify v1.5.4 // indirect
 	github.com/fzipp/gocyclo v0.6.0 // indirect
 	github.com/ghostiam/protogetter v0.3.9 // indirect
 	github.com/go-critic/go-critic v0.12.0 // indirect
@@ -0,0 +1,68 @@
+# Fault injection helpers for Tier 1 shims (sourced, not executed)
+#
+# The control file holds one rule per line:
+#
+#   <tool> <subcommand> fail <count>
+#   <tool> <subcommand> hang <count> <seconds>
+#
+# A rule applies to the first <count> matching invocations (0 = every
+# invocation). "fail" exits non-zero immediately; "hang" sleeps before failing,
+# simulating a command that times out. Per-rule hit counters live in
+# FAULTS_STATE_DIR so "fail once" style rules work across invocations.
+
+FAULTS_FILE="${FAULTS_FILE:-/tmp/faults.conf}"
+FAULTS_STATE_DIR="${FAULTS_STATE_DIR:-/tmp/faults.state}"
+
+# first_subcommand prints the first non-option argument, skipping the value
+# of options that take one (git -C <dir>, git -c <key=value>).
+first_subcommand() {
+  skip=0
+  for a in "$@"; do
+    if [ "$skip" = 1 ]; then
+      skip=0
+      continue
+    fi
+    case "$a" in
+      -C|-c) skip=1 ;;
+      -*) ;;
+      *) echo "$a"; return ;;
+    esac
+  done
+}
+
+# inject_fault <tool> <subcommand> <exit-code> <message>
+# Exits the calling shim with <exit-code> after printing <message> to stderr
+# when an active rule matches; returns otherwise.
+inject_fault() {
+  tool="$1"
+  sub="$2"
+  code="$3"
+  msg="$4"
+  [ -n "$sub" ] && [ -f "$FAULTS_FILE" ] || return 0
+
+  while read -r f_tool f_sub f_mode f_count f_arg; do
+    case "$f_tool" in ''|'#'*) continue ;; esac
+    [ "$f_tool" = "$tool" ] && [ "$f_sub" = "$sub" ] || continue
+
+    counter="$FAULTS_STATE_DIR/$tool-$sub"
+    hits=$(cat "$counter" 2>/dev/null || echo 0)
+    if [ "${f_count:-0}" -gt 0 ] && [ "$hits" -ge "$f_count" ]; then
+      return 0
+    fi
+    mkdir -p "$FAULTS_STATE_DIR"
+    echo $((hits + 1)) > "$counter"
+
+    case "$f_mode" in
+      fail)
+        echo "$msg" >&2
+        exit "$code"
+        ;;
+      hang)
+        sleep "${f_arg:-30}"
+        echo "$msg (timed out)" >&2
+        exit "$code"
+        ;;
+    esac
+  done < "$FAULTS_FILE"
+  return 0
+}
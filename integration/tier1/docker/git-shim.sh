@@ -0,0 +1,11 @@
+#!/bin/sh
+# git shim for Tier 1 integration tests
+# Injects faults from the control file, then runs the real git
+
+. /usr/local/lib/fault-inject.sh
+
+sub=$(first_subcommand "$@")
+inject_fault git "$sub" 128 \
+  "fatal: unable to access 'origin': Could not resolve host: injected-fault.invalid"
+
+exec /usr/bin/git "$@"
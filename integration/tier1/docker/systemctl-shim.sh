@@ -1,12 +1,18 @@
 #!/bin/sh
 # systemctl shim for Tier 1 integration tests
-# Records all invocations and supports configurable exit codes
+# Records all invocations and supports configurable exit codes and faults
+
+. /usr/local/lib/fault-inject.sh
 
 LOG_FILE="${SYSTEMCTL_LOG_FILE:-/tmp/systemctl.log}"
 
 # Log the invocation with timestamp
 echo "$(date -Iseconds) $*" >> "$LOG_FILE"
 
+# Faults from the control file take precedence over exit code overrides
+sub=$(first_subcommand "$@")
+inject_fault systemctl "$sub" 1 "Failed to $sub: injected fault"
+
 # Support exit code overrides for specific commands
 case "$*" in
   *daemon-reload*)
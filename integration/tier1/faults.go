@@ -0,0 +1,94 @@
+//go:build integration
+
+package tier1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	faultsFilePath = "/tmp/faults.conf"
+	faultsStateDir = "/tmp/faults.state"
+)
+
+// Fault modes understood by the shims (see docker/fault-inject.sh)
+const (
+	// FaultFail makes the command exit non-zero immediately.
+	FaultFail = "fail"
+	// FaultHang makes the command sleep for Delay and then fail, simulating
+	// a timeout.
+	FaultHang = "hang"
+)
+
+// Fault describes a failure injected into a shimmed command
+type Fault struct {
+	Tool       string // "systemctl" or "git"
+	Subcommand string // e.g. "daemon-reload", "try-restart", "fetch"
+	Mode       string // FaultFail or FaultHang
+	// Count limits the fault to the first Count matching invocations;
+	// 0 injects it on every invocation.
+	Count int
+	// Delay is how long a FaultHang command sleeps before failing.
+	Delay time.Duration
+}
+
+// line renders the fault as a control file rule
+func (f Fault) line() string {
+	rule := fmt.Sprintf("%s %s %s %d", f.Tool, f.Subcommand, f.Mode, f.Count)
+	if f.Mode == FaultHang {
+		rule += fmt.Sprintf(" %d", int(f.Delay.Round(time.Second)/time.Second))
+	}
+	return rule
+}
+
+// InjectFaults replaces the active fault rules and resets hit counters
+func (h *Harness) InjectFaults(ctx context.Context, faults ...Fault) error {
+	h.t.Helper()
+	if err := h.ClearFaults(ctx); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, f := range faults {
+		if f.Mode != FaultFail && f.Mode != FaultHang {
+			return fmt.Errorf("unknown fault mode %q", f.Mode)
+		}
+		b.WriteString(f.line())
+		b.WriteString("\n")
+	}
+	if err := h.WriteFile(ctx, faultsFilePath, b.String()); err != nil {
+		return fmt.Errorf("write fault control file: %w", err)
+	}
+	return nil
+}
+
+// ClearFaults removes all fault rules and hit counters
+func (h *Harness) ClearFaults(ctx context.Context) error {
+	h.t.Helper()
+	_, stderr, exitCode, err := h.Exec(ctx, "rm", "-rf", faultsFilePath, faultsStateDir)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("clear faults: exit %d: %s", exitCode, stderr)
+	}
+	return nil
+}
+
+// FaultHits returns how many times a fault was injected for tool/subcommand
+// since the last InjectFaults
+func (h *Harness) FaultHits(ctx context.Context, tool, subcommand string) (int, error) {
+	h.t.Helper()
+	stdout, _, exitCode, err := h.Exec(ctx, "cat", fmt.Sprintf("%s/%s-%s", faultsStateDir, tool, subcommand))
+	if err != nil {
+		return 0, err
+	}
+	if exitCode != 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(strings.TrimSpace(stdout))
+}
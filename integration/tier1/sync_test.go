@@ -75,6 +75,11 @@ func TestTier1Sync(t *testing.T) {
 		resetState(t, h, ctx)
 		testDryRunMode(t, h, ctx)
 	})
+
+	t.Run("G_FaultInjection", func(t *testing.T) {
+		resetState(t, h, ctx)
+		testFaultInjection(t, h, ctx)
+	})
 }
 
 // setupGitRepo initializes a git repo in the container with a sample quadlet
@@ -126,11 +131,12 @@ func writeConfig(t *testing.T, h *Harness, ctx context.Context, restart string,
 paths:
   quadlet_dir: %s
   state_dir: %s
+  extra_quadlet_roots: [%s]
 
 sync:
   prune: %t
   restart: %s
-`, testRepoPath, testQuadletDir, testStateDir, prune, restart)
+`, testRepoPath, testQuadletDir, testStateDir, testQuadletDir, prune, restart)
 
 	if err := h.WriteFile(ctx, testConfigPath, config); err != nil {
 		t.Fatalf("write config: %v", err)
@@ -474,3 +480,123 @@ func testDryRunMode(t *testing.T, h *Harness, ctx context.Context) {
 		t.Error("stdout does not indicate dry-run mode")
 	}
 }
+
+// testFaultInjection drives transient systemctl and git failures through the
+// shims and checks that a failed run leaves nothing half-applied and the next
+// run recovers
+func testFaultInjection(t *testing.T, h *Harness, ctx context.Context) {
+	// The repo was emptied by the prune scenario; give it a quadlet again.
+	quadletContent := `[Unit]
+Description=quadsyncd tier1 hello FAULTS
+
+[Container]
+Image=alpine:3.20
+`
+	if err := h.WriteFile(ctx, testRepoPath+"/hello.container", quadletContent); err != nil {
+		t.Fatalf("write quadlet: %v", err)
+	}
+	h.MustExec(ctx, "git", "-C", testRepoPath, "add", "hello.container")
+	h.MustExec(ctx, "git", "-C", testRepoPath, "commit", "-m", "Re-add hello.container")
+
+	writeConfig(t, h, ctx, "changed", true)
+	defer func() {
+		if err := h.ClearFaults(ctx); err != nil {
+			t.Errorf("clear faults: %v", err)
+		}
+	}()
+
+	t.Run("daemon-reload fails once", func(t *testing.T) {
+		if err := h.InjectFaults(ctx, Fault{Tool: "systemctl", Subcommand: "daemon-reload", Mode: FaultFail, Count: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		stdout, stderr, exitCode, err := h.Exec(ctx, "quadsyncd", "sync", "--config", testConfigPath)
+		if err != nil {
+			t.Fatalf("exec: %v", err)
+		}
+		if exitCode == 0 {
+			t.Fatalf("expected sync to fail when daemon-reload fails\nstdout: %s\nstderr: %s", stdout, stderr)
+		}
+
+		// The retry succeeds and reloads again even though files are in place.
+		if err := h.ClearShimLog(ctx); err != nil {
+			t.Fatalf("clear shim log: %v", err)
+		}
+		h.MustExec(ctx, "quadsyncd", "sync", "--config", testConfigPath)
+		if !h.FileExists(ctx, testQuadletFile) {
+			t.Error("quadlet file does not exist after recovery")
+		}
+		entries, err := h.ReadShimLog(ctx)
+		if err != nil {
+			t.Fatalf("read shim log: %v", err)
+		}
+		foundReload := false
+		for _, entry := range entries {
+			if entry.ContainsArg("daemon-reload") {
+				foundReload = true
+			}
+		}
+		if !foundReload {
+			t.Error("daemon-reload not called on recovery run")
+		}
+		if hits, err := h.FaultHits(ctx, "systemctl", "daemon-reload"); err != nil || hits != 1 {
+			t.Errorf("daemon-reload fault hits = %d (%v), want 1", hits, err)
+		}
+	})
+
+	t.Run("git fetch transient error", func(t *testing.T) {
+		stateBefore, err := h.ReadFile(ctx, testStatePath)
+		if err != nil {
+			t.Fatalf("read state: %v", err)
+		}
+		if err := h.InjectFaults(ctx, Fault{Tool: "git", Subcommand: "fetch", Mode: FaultFail, Count: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		stdout, stderr, exitCode, err := h.Exec(ctx, "quadsyncd", "sync", "--config", testConfigPath)
+		if err != nil {
+			t.Fatalf("exec: %v", err)
+		}
+		if exitCode == 0 {
+			t.Fatalf("expected sync to fail on fetch error\nstdout: %s\nstderr: %s", stdout, stderr)
+		}
+		if !strings.Contains(stdout+stderr, "Could not resolve host") {
+			t.Errorf("fetch error not surfaced\nstdout: %s\nstderr: %s", stdout, stderr)
+		}
+		stateAfter, err := h.ReadFile(ctx, testStatePath)
+		if err != nil {
+			t.Fatalf("read state: %v", err)
+		}
+		if stateBefore != stateAfter {
+			t.Error("state changed by a run that failed to fetch")
+		}
+
+		h.MustExec(ctx, "quadsyncd", "sync", "--config", testConfigPath)
+	})
+
+	t.Run("try-restart times out", func(t *testing.T) {
+		updated := strings.Replace(quadletContent, "FAULTS", "FAULTS UPDATED", 1)
+		if err := h.WriteFile(ctx, testRepoPath+"/hello.container", updated); err != nil {
+			t.Fatalf("write quadlet: %v", err)
+		}
+		h.MustExec(ctx, "git", "-C", testRepoPath, "commit", "-am", "Update hello.container")
+
+		if err := h.InjectFaults(ctx, Fault{Tool: "systemctl", Subcommand: "try-restart", Mode: FaultHang, Delay: 2 * time.Second}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Restart failures are reported but do not fail the sync; the new
+		// content is applied.
+		h.MustExec(ctx, "quadsyncd", "sync", "--config", testConfigPath)
+		content, err := h.ReadFile(ctx, testQuadletFile)
+		if err != nil {
+			t.Fatalf("read quadlet: %v", err)
+		}
+		if !strings.Contains(content, "FAULTS UPDATED") {
+			t.Error("quadlet not updated when try-restart timed out")
+		}
+		if hits, err := h.FaultHits(ctx, "systemctl", "try-restart"); err != nil || hits == 0 {
+			t.Errorf("try-restart fault not hit (hits=%d, err=%v)", hits, err)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+// Package bench measures sync engine performance against synthetic
+// repositories and checks the results against a per-file time budget.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/git"
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/schaermu/quadsyncd/internal/synthrepo"
+)
+
+// Scenarios measured for every repository size, in run order.
+const (
+	// ScenarioInitial syncs the full repository into an empty quadlet dir.
+	ScenarioInitial = "initial"
+	// ScenarioNoop re-syncs an unchanged repository with a forced plan
+	// (hashing-bound).
+	ScenarioNoop = "noop"
+	// ScenarioUpdate syncs a revision that changes 10% of the files.
+	ScenarioUpdate = "update"
+)
+
+// Budgets is the per-file time allowed for plan+apply in each scenario. They
+// are deliberately generous so CI machines with slow disks pass; a breach
+// indicates an algorithmic regression rather than noise. A no-op sync still
+// stages a full mirror of the quadlet dir, so its budget is close to update.
+var Budgets = map[string]time.Duration{
+	ScenarioInitial: 5 * time.Millisecond,
+	ScenarioNoop:    2 * time.Millisecond,
+	ScenarioUpdate:  2 * time.Millisecond,
+}
+
+// Options configures a benchmark run.
+type Options struct {
+	// Sizes are the repository sizes (file counts) to benchmark.
+	Sizes []int
+	// Iterations per size; the median of each scenario is reported.
+	Iterations int
+	// WorkDir holds generated repositories and quadlet/state dirs.
+	WorkDir string
+}
+
+// Result is the median timing of one scenario at one repository size.
+type Result struct {
+	Scenario string        `json:"scenario"`
+	Files    int           `json:"files"`
+	Plan     time.Duration `json:"plan_ns"`
+	Apply    time.Duration `json:"apply_ns"`
+	Budget   time.Duration `json:"budget_ns"`
+}
+
+// Total returns the plan plus apply time.
+func (r Result) Total() time.Duration {
+	return r.Plan + r.Apply
+}
+
+// OverBudget reports whether the result exceeds its budget.
+func (r Result) OverBudget() bool {
+	return r.Budget > 0 && r.Total() > r.Budget
+}
+
+// Run benchmarks every size in opts and returns one result per scenario and
+// size. Fetch, reload and restart phases are excluded: the git client
+// generates the repository in place and systemd calls are no-ops.
+func Run(ctx context.Context, opts Options, logger *slog.Logger) ([]Result, error) {
+	if opts.Iterations < 1 {
+		return nil, fmt.Errorf("iterations must be at least 1")
+	}
+
+	var results []Result
+	for _, size := range opts.Sizes {
+		if size < 1 {
+			return nil, fmt.Errorf("invalid size %d: must be positive", size)
+		}
+		samples := map[string][]quadsyncd.PhaseDurations{}
+		for i := 0; i < opts.Iterations; i++ {
+			logger.Info("running benchmark iteration", "files", size, "iteration", i+1)
+			dir := filepath.Join(opts.WorkDir, fmt.Sprintf("files-%d-iter-%d", size, i))
+			got, err := runIteration(ctx, dir, size)
+			if removeErr := os.RemoveAll(dir); removeErr != nil {
+				logger.Warn("failed to clean up benchmark dir", "dir", dir, "error", removeErr)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("benchmark with %d files: %w", size, err)
+			}
+			for scenario, d := range got {
+				samples[scenario] = append(samples[scenario], d)
+			}
+		}
+		for _, scenario := range []string{ScenarioInitial, ScenarioNoop, ScenarioUpdate} {
+			plan, apply := median(samples[scenario])
+			results = append(results, Result{
+				Scenario: scenario,
+				Files:    size,
+				Plan:     plan,
+				Apply:    apply,
+				Budget:   Budgets[scenario] * time.Duration(size),
+			})
+		}
+	}
+	return results, nil
+}
+
+// runIteration runs the initial, noop and update scenarios in sequence
+// against a fresh directory tree.
+func runIteration(ctx context.Context, dir string, files int) (map[string]quadsyncd.PhaseDurations, error) {
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///quadsyncd-bench", Ref: "main"},
+		Paths: config.PathsConfig{
+			QuadletDir: filepath.Join(dir, "quadlet"),
+			StateDir:   filepath.Join(dir, "state"),
+		},
+		Sync: config.SyncConfig{Prune: true, Restart: config.RestartNone},
+	}
+	if err := os.MkdirAll(cfg.Paths.QuadletDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create quadlet dir: %w", err)
+	}
+
+	client := &synthClient{files: files}
+	// Force a full plan on the noop scenario, which a live sync would skip.
+	engine := quadsyncd.New(cfg,
+		quadsyncd.WithGitClient(client),
+		quadsyncd.WithSystemd(noopSystemd{}),
+		quadsyncd.WithForce(true))
+
+	got := make(map[string]quadsyncd.PhaseDurations, 3)
+	for _, step := range []struct {
+		scenario string
+		revision int
+	}{
+		{ScenarioInitial, 0},
+		{ScenarioNoop, 0},
+		{ScenarioUpdate, 1},
+	} {
+		client.revision = step.revision
+		result, err := engine.Run(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", step.scenario, err)
+		}
+		got[step.scenario] = result.Durations
+	}
+	return got, nil
+}
+
+// median returns the median plan and apply durations of samples.
+func median(samples []quadsyncd.PhaseDurations) (plan, apply time.Duration) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	plans := make([]time.Duration, len(samples))
+	applies := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		plans[i] = s.Plan
+		applies[i] = s.Apply
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i] < plans[j] })
+	sort.Slice(applies, func(i, j int) bool { return applies[i] < applies[j] })
+	mid := len(samples) / 2
+	return plans[mid], applies[mid]
+}
+
+// synthClient is a git.Client that generates the synthetic repository at the
+// current revision instead of fetching it.
+type synthClient struct {
+	files    int
+	revision int
+}
+
+func (c *synthClient) EnsureCheckout(_ context.Context, _, _, destDir string, _ git.CheckoutOptions) (string, error) {
+	if err := synthrepo.Generate(destDir, synthrepo.Options{Files: c.files, Revision: c.revision}); err != nil {
+		return "", err
+	}
+	return synthrepo.Commit(c.revision), nil
+}
+
+// noopSystemd is a systemduser.Systemd that succeeds without doing anything.
+type noopSystemd struct{}
+
+func (noopSystemd) DaemonReload(context.Context) error                    { return nil }
+func (noopSystemd) TryRestartUnits(context.Context, []string) error       { return nil }
+func (noopSystemd) StartUnits(context.Context, []string) error            { return nil }
+func (noopSystemd) IsAvailable(context.Context) (bool, error)             { return true, nil }
+func (noopSystemd) ValidateQuadlets(context.Context, string) error        { return nil }
+func (noopSystemd) GetUnitStatus(context.Context, string) (string, error) { return "inactive", nil }
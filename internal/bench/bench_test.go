@@ -0,0 +1,68 @@
+package bench
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+)
+
+func TestRun(t *testing.T) {
+	results, err := Run(context.Background(), Options{
+		Sizes:      []int{20, 40},
+		Iterations: 2,
+		WorkDir:    t.TempDir(),
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(results) != 6 {
+		t.Fatalf("expected 6 results (3 scenarios x 2 sizes), got %d", len(results))
+	}
+	for i, want := range []string{ScenarioInitial, ScenarioNoop, ScenarioUpdate} {
+		if results[i].Scenario != want || results[i].Files != 20 {
+			t.Errorf("results[%d] = %s/%d, want %s/20", i, results[i].Scenario, results[i].Files, want)
+		}
+	}
+	for _, r := range results {
+		if r.Plan <= 0 {
+			t.Errorf("%s/%d: plan duration not recorded", r.Scenario, r.Files)
+		}
+		if r.Budget != Budgets[r.Scenario]*time.Duration(r.Files) {
+			t.Errorf("%s/%d: budget = %v", r.Scenario, r.Files, r.Budget)
+		}
+	}
+}
+
+func TestRun_InvalidOptions(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	if _, err := Run(context.Background(), Options{Sizes: []int{10}, WorkDir: t.TempDir()}, logger); err == nil {
+		t.Error("expected error for zero iterations")
+	}
+	if _, err := Run(context.Background(), Options{Sizes: []int{0}, Iterations: 1, WorkDir: t.TempDir()}, logger); err == nil {
+		t.Error("expected error for non-positive size")
+	}
+}
+
+func TestMedianAndBudget(t *testing.T) {
+	plan, apply := median([]quadsyncd.PhaseDurations{
+		{Plan: 3 * time.Millisecond, Apply: 30 * time.Millisecond},
+		{Plan: 1 * time.Millisecond, Apply: 10 * time.Millisecond},
+		{Plan: 2 * time.Millisecond, Apply: 50 * time.Millisecond},
+	})
+	if plan != 2*time.Millisecond || apply != 30*time.Millisecond {
+		t.Errorf("median = %v/%v, want 2ms/30ms", plan, apply)
+	}
+
+	r := Result{Plan: plan, Apply: apply, Budget: 31 * time.Millisecond}
+	if !r.OverBudget() {
+		t.Error("32ms should exceed a 31ms budget")
+	}
+	r.Budget = 0
+	if r.OverBudget() {
+		t.Error("zero budget should never be exceeded")
+	}
+}
@@ -0,0 +1,94 @@
+// Package cmdexec runs external tools (git, systemctl, podman) so that a
+// hung invocation can always be stopped: each command gets its own process
+// group, and the whole group is killed when the command's context ends.
+package cmdexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// waitDelay bounds how long Wait keeps reading output after the process
+// group was killed, in case a grandchild escaped the group and holds a pipe.
+const waitDelay = 5 * time.Second
+
+// ErrTimeout is wrapped by errors from commands that ran into their timeout.
+var ErrTimeout = errors.New("command timed out")
+
+// Command returns an exec.Cmd for name that runs in a new process group.
+// When ctx is done, the entire group is sent SIGKILL, so helpers spawned by
+// the command (ssh for git, credential helpers) do not outlive it. On
+// platforms without process groups only the command itself is killed.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+// WithTimeout returns a child of ctx that expires after timeout. A timeout of
+// zero or less adds no limit.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Err annotates err from a command run under ctx (as returned by
+// WithTimeout): if ctx's deadline expired, the result wraps ErrTimeout and
+// names the limit. Other errors are returned unchanged.
+func Err(ctx context.Context, timeout time.Duration, err error) error {
+	if err != nil && timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %w", ErrTimeout, timeout, err)
+	}
+	return err
+}
+
+// Output collects a command's stdout on its own and, for error messages,
+// stdout and stderr interleaved as they were written. os/exec copies the two
+// streams in separate goroutines, so both writers share a lock.
+type Output struct {
+	mu       sync.Mutex
+	stdout   bytes.Buffer
+	combined bytes.Buffer
+}
+
+// Capture sets cmd's stdout and stderr to a new Output and returns it. Read
+// it only after the command has finished.
+func Capture(cmd *exec.Cmd) *Output {
+	o := &Output{}
+	cmd.Stdout = outputWriter{o, &o.stdout}
+	cmd.Stderr = outputWriter{o, nil}
+	return o
+}
+
+// Stdout returns what the command wrote to stdout.
+func (o *Output) Stdout() []byte {
+	return o.stdout.Bytes()
+}
+
+// Combined returns what the command wrote to stdout and stderr.
+func (o *Output) Combined() []byte {
+	return o.combined.Bytes()
+}
+
+// outputWriter appends to o's combined output and, unless nil, to own.
+type outputWriter struct {
+	o   *Output
+	own *bytes.Buffer
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.o.mu.Lock()
+	defer w.o.mu.Unlock()
+	if w.own != nil {
+		_, _ = w.own.Write(p)
+	}
+	return w.o.combined.Write(p)
+}
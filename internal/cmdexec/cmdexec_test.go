@@ -0,0 +1,119 @@
+//go:build unix
+
+package cmdexec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCommand_KillsProcessGroupOnTimeout(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	timeout := 200 * time.Millisecond
+	ctx, cancel := WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The shell starts a grandchild that would keep running if only the
+	// direct child were killed.
+	cmd := Command(ctx, "sh", "-c", "sleep 30 & echo $! > "+pidFile+"; wait")
+	start := time.Now()
+	err := Err(ctx, timeout, cmd.Run())
+
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	if !strings.Contains(err.Error(), "200ms") {
+		t.Errorf("error should name the timeout: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command took %s to stop", elapsed)
+	}
+
+	data, readErr := os.ReadFile(pidFile)
+	if readErr != nil {
+		t.Fatalf("reading child pid: %v", readErr)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	deadline := time.Now().Add(2 * time.Second)
+	for processRunning(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("grandchild %d still running after timeout", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestErr(t *testing.T) {
+	boom := errors.New("exit status 1")
+
+	ctx, cancel := WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if got := Err(ctx, time.Minute, boom); got != boom {
+		t.Errorf("Err() before the deadline = %v, want the original error", got)
+	}
+	if got := Err(ctx, time.Minute, nil); got != nil {
+		t.Errorf("Err(nil) = %v, want nil", got)
+	}
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancelExpired()
+	<-expired.Done()
+	if got := Err(expired, time.Nanosecond, boom); !errors.Is(got, ErrTimeout) || !errors.Is(got, boom) {
+		t.Errorf("Err() after the deadline = %v, want ErrTimeout wrapping the original", got)
+	}
+	if got := Err(expired, 0, boom); got != boom {
+		t.Errorf("Err() without a timeout = %v, want the original error", got)
+	}
+}
+
+func TestWithTimeout_NoLimit(t *testing.T) {
+	ctx, cancel := WithTimeout(context.Background(), 0)
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("a zero timeout should not set a deadline")
+	}
+	cancel()
+	if ctx.Err() == nil {
+		t.Error("cancel should still cancel the context")
+	}
+}
+
+// processRunning reports whether pid exists and is not a zombie waiting to
+// be reaped by whichever process inherited it.
+func processRunning(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return true
+	}
+	// The state follows the parenthesised command name.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func TestCapture(t *testing.T) {
+	cmd := Command(context.Background(), "sh", "-c", "for i in 1 2 3 4 5; do echo out$i; echo err$i >&2; done")
+	out := Capture(cmd)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got, want := string(out.Stdout()), "out1\nout2\nout3\nout4\nout5\n"; got != want {
+		t.Errorf("Stdout() = %q, want %q", got, want)
+	}
+	combined := string(out.Combined())
+	for i := 1; i <= 5; i++ {
+		for _, line := range []string{"out", "err"} {
+			if want := line + strconv.Itoa(i) + "\n"; !strings.Contains(combined, want) {
+				t.Errorf("Combined() = %q, want it to contain %q", combined, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package cmdexec
+
+import "os/exec"
+
+// setProcessGroup leaves cmd unchanged: without process groups,
+// cancellation kills only the command, as exec.CommandContext does.
+func setProcessGroup(*exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package cmdexec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in its own process group and makes
+// cancellation kill the whole group.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// A negative pid signals the process group led by the command.
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
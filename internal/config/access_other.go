@@ -0,0 +1,17 @@
+//go:build !unix
+
+package config
+
+import "os"
+
+// checkWriteAccess reports whether the current user may write to the
+// directory path by creating and removing a temporary file in it.
+func checkWriteAccess(path string) error {
+	f, err := os.CreateTemp(path, ".quadsyncd-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
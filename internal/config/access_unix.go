@@ -0,0 +1,14 @@
+//go:build unix
+
+package config
+
+import "syscall"
+
+// accessWriteOK is W_OK for access(2); the value is the same on Linux and
+// macOS but syscall does not export it.
+const accessWriteOK = 0x2
+
+// checkWriteAccess reports whether the current user may write to path.
+func checkWriteAccess(path string) error {
+	return syscall.Access(path, accessWriteOK)
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckStatus is the outcome of a single environment check.
+type CheckStatus string
+
+const (
+	CheckOK      CheckStatus = "ok"
+	CheckWarning CheckStatus = "warning"
+	CheckError   CheckStatus = "error"
+)
+
+// CheckResult describes one check made by CheckEnvironment. Field names are
+// part of the `config validate --output json` contract.
+type CheckResult struct {
+	// Field is the configuration field checked, e.g. "auth.ssh_key_file".
+	Field  string      `json:"field"`
+	Path   string      `json:"path,omitempty"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+// CheckEnvironment verifies what Validate cannot see from the file alone:
+// that the key, token and secret files the configuration names are readable,
+// that the quadlet and state directories are writable, and that every
+// repository URL uses a scheme quadsyncd can authenticate. It changes
+// nothing on disk.
+func (c *Config) CheckEnvironment() []CheckResult {
+	var results []CheckResult
+	add := func(r CheckResult) { results = append(results, r) }
+
+	add(checkWritableDir("paths.quadlet_dir", c.Paths.QuadletDir))
+	add(checkWritableDir("paths.state_dir", c.Paths.StateDir))
+	if len(c.Sync.UnitExtensions) > 0 {
+		add(checkWritableDir("paths.unit_dir", c.Paths.UnitDir))
+	}
+	for i, r := range c.Paths.Routes {
+		add(checkWritableDir(fmt.Sprintf("paths.routes[%d].dest", i), r.Dest))
+	}
+
+	// The global auth section is shared by every repository without its own;
+	// check its files once.
+	seen := make(map[string]bool)
+	specs := c.EffectiveRepositories()
+	for i, spec := range specs {
+		label := "repository"
+		if c.Repository == nil {
+			label = fmt.Sprintf("repositories[%d]", i)
+		}
+		if spec.Checksums.CosignKey != "" {
+			add(checkReadableFile(label+".checksums.cosign_key", spec.Checksums.CosignKey, true))
+		}
+		if spec.IsOCI() {
+			add(CheckResult{Field: label + ".url", Path: spec.URL, Status: CheckOK, Detail: "oci artifact pulled with oras"})
+			if spec.Source.CosignKey != "" {
+				add(checkReadableFile(label+".source.cosign_key", spec.Source.CosignKey, true))
+			}
+			continue
+		}
+		if spec.IsDir() {
+			add(checkSourceDir(label+".url", spec.URL))
+			continue
+		}
+		add(checkRepoURL(label+".url", spec.URL, c.AuthForSpec(spec)))
+
+		authLabel := "auth"
+		if spec.Auth != nil {
+			authLabel = label + ".auth"
+		}
+		if seen[authLabel] {
+			continue
+		}
+		seen[authLabel] = true
+		results = append(results, checkAuthFiles(authLabel, c.AuthForSpec(spec))...)
+	}
+
+	if c.Serve.Enabled {
+		if c.Serve.GitHubWebhookSecretFile != "" {
+			add(checkReadableFile("serve.github_webhook_secret_file", c.Serve.GitHubWebhookSecretFile, true))
+		}
+		for i, l := range c.Serve.Listeners {
+			add(checkReadableFile(fmt.Sprintf("serve.listeners[%d].secret_file", i), l.SecretFile, true))
+		}
+		if c.Serve.APITokenFile != "" {
+			add(checkReadableFile("serve.api_token_file", c.Serve.APITokenFile, true))
+		}
+	}
+	if c.Secrets.Enabled && c.Secrets.AgeIdentityFile != "" {
+		add(checkReadableFile("secrets.age_identity_file", c.Secrets.AgeIdentityFile, true))
+	}
+	if c.Sync.AgeIdentityFile != "" {
+		add(checkReadableFile("sync.age_identity_file", c.Sync.AgeIdentityFile, true))
+	}
+	if c.ImageSignatures.Enabled() && c.ImageSignatures.CosignKey != "" {
+		add(checkReadableFile("image_signatures.cosign_key", c.ImageSignatures.CosignKey, true))
+	}
+	for i, f := range c.Values.Files {
+		add(checkReadableFile(fmt.Sprintf("values.files[%d]", i), f, false))
+	}
+	return results
+}
+
+// checkAuthFiles checks the files referenced by one auth section.
+func checkAuthFiles(label string, auth AuthConfig) []CheckResult {
+	var results []CheckResult
+	if auth.SSHKeyFile != "" {
+		r := checkReadableFile(label+".ssh_key_file", auth.SSHKeyFile, true)
+		if r.Status == CheckOK {
+			if info, err := os.Stat(auth.SSHKeyFile); err == nil && info.Mode().Perm()&0o077 != 0 {
+				r.Status = CheckWarning
+				r.Detail = fmt.Sprintf("mode %04o is accessible by other users; ssh refuses such keys, run chmod 600 %s", info.Mode().Perm(), auth.SSHKeyFile)
+			}
+		}
+		results = append(results, r)
+	}
+	if f := auth.TokenFile(); f != "" {
+		field := label + ".https_token_file"
+		if auth.HTTPSTokenFile == "" {
+			field = label + ".https_password_file"
+		}
+		results = append(results, checkReadableFile(field, f, true))
+	}
+	if auth.SSHKnownHostsFile != "" {
+		r := checkReadableFile(label+".ssh_known_hosts_file", auth.SSHKnownHostsFile, false)
+		_, err := os.Stat(auth.SSHKnownHostsFile)
+		if os.IsNotExist(err) && auth.SSHStrictHostKeyChecking != SSHStrictHostKeyYes {
+			r.Status = CheckWarning
+			r.Detail = "does not exist yet; host keys are recorded on first connect (or run quadsyncd trust-host)"
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// checkRepoURL reports whether url uses a scheme matching auth. Validate
+// already rejects credentials configured for the wrong scheme; this adds the
+// schemes quadsyncd cannot authenticate at all.
+func checkRepoURL(field, url string, auth AuthConfig) CheckResult {
+	r := CheckResult{Field: field, Path: url, Status: CheckOK}
+	switch {
+	case strings.HasPrefix(url, "git@"), strings.HasPrefix(url, "ssh://"):
+		if auth.SSHKeyFile == "" {
+			r.Detail = "ssh without auth.ssh_key_file uses the user's default keys and agent"
+		}
+	case strings.HasPrefix(url, "https://"):
+		if auth.TokenFile() == "" && auth.HTTPSTokenCommand == "" {
+			r.Detail = "https without credentials only works for public repositories"
+		}
+	case strings.HasPrefix(url, "file://"), filepath.IsAbs(url):
+	case strings.HasPrefix(url, "http://"):
+		r.Status = CheckWarning
+		r.Detail = "plain http is unencrypted and cannot carry credentials; use https"
+	default:
+		r.Status = CheckWarning
+		r.Detail = "unrecognised URL scheme; expected git@, ssh://, https:// or file://"
+	}
+	return r
+}
+
+// checkReadableFile checks that path is a regular file the current user can
+// read and, with nonEmpty, that it has content.
+func checkReadableFile(field, path string, nonEmpty bool) CheckResult {
+	r := CheckResult{Field: field, Path: path, Status: CheckOK}
+	f, err := os.Open(path)
+	if err != nil {
+		r.Status = CheckError
+		r.Detail = err.Error()
+		return r
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	switch {
+	case err != nil:
+		r.Status, r.Detail = CheckError, err.Error()
+	case !info.Mode().IsRegular():
+		r.Status, r.Detail = CheckError, "not a regular file"
+	case nonEmpty:
+		if _, err := f.Read(make([]byte, 1)); err == io.EOF {
+			r.Status, r.Detail = CheckError, "file is empty"
+		} else if err != nil {
+			r.Status, r.Detail = CheckError, err.Error()
+		}
+	}
+	return r
+}
+
+// checkSourceDir checks that dir is a directory the current user can list.
+func checkSourceDir(field, dir string) CheckResult {
+	r := CheckResult{Field: field, Path: dir, Status: CheckOK}
+	info, err := os.Stat(dir)
+	switch {
+	case err != nil:
+		r.Status, r.Detail = CheckError, err.Error()
+	case !info.IsDir():
+		r.Status, r.Detail = CheckError, "not a directory"
+	default:
+		if _, err := os.ReadDir(dir); err != nil {
+			r.Status, r.Detail = CheckError, err.Error()
+		}
+	}
+	return r
+}
+
+// checkWritableDir checks that dir, or the closest existing ancestor it would
+// be created in, is a directory the current user can write to.
+func checkWritableDir(field, dir string) CheckResult {
+	r := CheckResult{Field: field, Path: dir, Status: CheckOK}
+	target := dir
+	for {
+		if _, err := os.Stat(target); err == nil {
+			break
+		}
+		parent := filepath.Dir(target)
+		if parent == target {
+			break
+		}
+		target = parent
+	}
+	if target != dir {
+		r.Detail = fmt.Sprintf("does not exist yet; will be created in %s", target)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		r.Status, r.Detail = CheckError, err.Error()
+		return r
+	}
+	if !info.IsDir() {
+		r.Status, r.Detail = CheckError, fmt.Sprintf("%s is not a directory", target)
+		return r
+	}
+	if err := checkWriteAccess(target); err != nil {
+		r.Status, r.Detail = CheckError, fmt.Sprintf("%s is not writable: %v", target, err)
+	}
+	return r
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	key := write("key", "PRIVATE KEY", 0o600)
+	openKey := write("open-key", "PRIVATE KEY", 0o644)
+	empty := write("empty", "", 0o600)
+	token := write("token", "ghp_x", 0o600)
+
+	tests := []struct {
+		name   string
+		mutate func(c *Config)
+		field  string
+		want   CheckStatus
+	}{
+		{"quadlet dir created later", func(c *Config) {}, "paths.quadlet_dir", CheckOK},
+		{"quadlet dir below a file", func(c *Config) { c.Paths.QuadletDir = filepath.Join(key, "q") }, "paths.quadlet_dir", CheckError},
+		{"ssh key readable", func(c *Config) {
+			c.Repository.URL = "git@github.com:o/r.git"
+			c.Auth.SSHKeyFile = key
+		}, "auth.ssh_key_file", CheckOK},
+		{"ssh key too open", func(c *Config) {
+			c.Repository.URL = "git@github.com:o/r.git"
+			c.Auth.SSHKeyFile = openKey
+		}, "auth.ssh_key_file", CheckWarning},
+		{"ssh key missing", func(c *Config) {
+			c.Repository.URL = "git@github.com:o/r.git"
+			c.Auth.SSHKeyFile = filepath.Join(dir, "nope")
+		}, "auth.ssh_key_file", CheckError},
+		{"per-repo token", func(c *Config) {
+			c.Repository.Auth = &AuthConfig{HTTPSTokenFile: token}
+		}, "repository.auth.https_token_file", CheckOK},
+		{"empty password file", func(c *Config) { c.Auth.HTTPSPasswordFile = empty }, "auth.https_password_file", CheckError},
+		{"known hosts recorded on first use", func(c *Config) {
+			c.Auth.SSHKnownHostsFile = filepath.Join(dir, "known_hosts")
+		}, "auth.ssh_known_hosts_file", CheckWarning},
+		{"known hosts required", func(c *Config) {
+			c.Auth.SSHKnownHostsFile = filepath.Join(dir, "known_hosts")
+			c.Auth.SSHStrictHostKeyChecking = SSHStrictHostKeyYes
+		}, "auth.ssh_known_hosts_file", CheckError},
+		{"http url", func(c *Config) { c.Repository.URL = "http://git.local/r.git" }, "repository.url", CheckWarning},
+		{"dir source", func(c *Config) {
+			c.Repository = &RepoSpec{URL: dir, Source: SourceConfig{Type: SourceDir}}
+		}, "repository.url", CheckOK},
+		{"dir source is a file", func(c *Config) {
+			c.Repository = &RepoSpec{URL: key, Source: SourceConfig{Type: SourceDir}}
+		}, "repository.url", CheckError},
+		{"webhook secret empty", func(c *Config) {
+			c.Serve.Enabled = true
+			c.Serve.GitHubWebhookSecretFile = empty
+		}, "serve.github_webhook_secret_file", CheckError},
+		{"api token empty", func(c *Config) {
+			c.Serve.Enabled = true
+			c.Serve.GitHubWebhookSecretFile = key
+			c.Serve.APITokenFile = empty
+		}, "serve.api_token_file", CheckError},
+		{"values file is a directory", func(c *Config) { c.Values.Files = []string{dir} }, "values.files[0]", CheckError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Repository: &RepoSpec{URL: "https://github.com/o/r.git", Ref: "main"},
+				Paths: PathsConfig{
+					QuadletDir: filepath.Join(dir, "quadlets"),
+					StateDir:   filepath.Join(dir, "state"),
+				},
+			}
+			tt.mutate(cfg)
+
+			var found *CheckResult
+			for _, r := range cfg.CheckEnvironment() {
+				if r.Field == tt.field {
+					found = &r
+				}
+			}
+			if found == nil {
+				t.Fatalf("no result for %s", tt.field)
+			}
+			if found.Status != tt.want {
+				t.Errorf("%s status = %s (%s), want %s", tt.field, found.Status, found.Detail, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ChecksumConfig configures verification of a repository's checksum
+// manifest, MANIFEST.sha256 at the root of its checkout.
+type ChecksumConfig struct {
+	// Verify requires the manifest and fails the sync when a file the
+	// repository provides is not listed in it or does not match its hash.
+	Verify bool `yaml:"verify"`
+	// CosignKey is a cosign public key file; when set, MANIFEST.sha256.sig
+	// must be a signature of the manifest made with the matching private
+	// key.
+	CosignKey string `yaml:"cosign_key,omitempty"`
+}
+
+// validateChecksums checks spec.Checksums.
+func validateChecksums(spec RepoSpec, label string) error {
+	c := spec.Checksums
+	if c.CosignKey == "" {
+		return nil
+	}
+	if !c.Verify {
+		return fmt.Errorf("%s.checksums.cosign_key requires %s.checksums.verify", label, label)
+	}
+	if !filepath.IsAbs(c.CosignKey) {
+		return fmt.Errorf("%s.checksums.cosign_key must be an absolute path: %s", label, c.CosignKey)
+	}
+	return nil
+}
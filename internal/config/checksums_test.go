@@ -0,0 +1,34 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateChecksums(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       ChecksumConfig
+		wantErr string
+	}{
+		{name: "off"},
+		{name: "unsigned", c: ChecksumConfig{Verify: true}},
+		{name: "signed", c: ChecksumConfig{Verify: true, CosignKey: "/etc/quadsyncd/cosign.pub"}},
+		{name: "key without verify", c: ChecksumConfig{CosignKey: "/etc/quadsyncd/cosign.pub"}, wantErr: "requires repository.checksums.verify"},
+		{name: "relative key", c: ChecksumConfig{Verify: true, CosignKey: "cosign.pub"}, wantErr: "absolute path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateChecksums(RepoSpec{Checksums: tt.c}, "repository")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateChecksums() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateChecksums() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
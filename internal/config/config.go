@@ -3,9 +3,16 @@ package config
 import (
 	"crypto/sha256"
 	"fmt"
+	"net"
+	"net/netip"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -32,12 +39,22 @@ const (
 // Config represents the complete quadsyncd configuration.
 // Exactly one of Repository or Repositories must be set.
 type Config struct {
-	Repository   *RepoSpec   `yaml:"repository"`
-	Repositories []RepoSpec  `yaml:"repositories"`
-	Paths        PathsConfig `yaml:"paths"`
-	Sync         SyncConfig  `yaml:"sync"`
-	Auth         AuthConfig  `yaml:"auth"`
-	Serve        ServeConfig `yaml:"serve"`
+	Repository      *RepoSpec             `yaml:"repository"`
+	Repositories    []RepoSpec            `yaml:"repositories"`
+	Paths           PathsConfig           `yaml:"paths"`
+	Sync            SyncConfig            `yaml:"sync"`
+	Auth            AuthConfig            `yaml:"auth"`
+	Serve           ServeConfig           `yaml:"serve"`
+	Values          ValuesConfig          `yaml:"values"`
+	Timeouts        TimeoutsConfig        `yaml:"timeouts"`
+	Secrets         SecretsConfig         `yaml:"secrets"`
+	ImageWatch      ImageWatchConfig      `yaml:"image_watch"`
+	ImageSignatures ImageSignaturesConfig `yaml:"image_signatures"`
+	GitRetry        GitRetryConfig        `yaml:"git_retry"`
+	GitMaintenance  GitMaintenanceConfig  `yaml:"git_maintenance"`
+	Systemd         SystemdConfig         `yaml:"systemd"`
+	Substitution    SubstitutionConfig    `yaml:"substitution"`
+	Audit           AuditConfig           `yaml:"audit"`
 }
 
 // RepoSpec describes a repository to sync quadlet files from.
@@ -47,34 +64,666 @@ type RepoSpec struct {
 	Priority int         `yaml:"priority"`
 	Subdir   string      `yaml:"subdir"`
 	Auth     *AuthConfig `yaml:"auth,omitempty"`
+	// CloneDepth limits fetched history to this many commits per branch.
+	// 0 fetches full history.
+	CloneDepth int `yaml:"clone_depth,omitempty"`
+	// Filter is a partial clone filter; only "blob:none" is supported. It
+	// applies when the repository is first cloned.
+	Filter string `yaml:"filter,omitempty"`
+	// Submodules checks out git submodules recursively, using the same
+	// credentials as the repository.
+	Submodules bool `yaml:"submodules,omitempty"`
+	// Layers are checkout-relative directories merged in order, later ones
+	// overriding files at the same relative path; set by listing them in
+	// subdir. Mutually exclusive with Subdir.
+	Layers []string `yaml:"layers,omitempty"`
+	// Source selects the fetch backend; git by default.
+	Source SourceConfig `yaml:"source,omitempty"`
+	// Checksums verifies the fetched files against a checksum manifest in
+	// the repository.
+	Checksums ChecksumConfig `yaml:"checksums,omitempty"`
 }
 
+// CloneFilterBlobNone defers downloading file contents until checkout.
+const CloneFilterBlobNone = "blob:none"
+
 // PathsConfig configures local filesystem paths
 type PathsConfig struct {
 	QuadletDir string `yaml:"quadlet_dir"`
 	StateDir   string `yaml:"state_dir"`
+	// ExtraQuadletRoots lists directories besides the standard quadlet
+	// locations that quadlet_dir may lie in; see QuadletRoots.
+	ExtraQuadletRoots []string `yaml:"extra_quadlet_roots,omitempty"`
+	// UnitDir is where files with one of sync.unit_extensions are
+	// installed. Defaults to DefaultUnitDir when extensions are configured.
+	UnitDir string `yaml:"unit_dir,omitempty"`
+	// Routes send repository files matching a pattern to another directory
+	// than quadlet_dir; see Config.RouteDest.
+	Routes []RouteConfig `yaml:"routes,omitempty"`
+}
+
+// RouteConfig installs the repository files matching Pattern below Dest.
+type RouteConfig struct {
+	// Pattern is a path.Match glob matched against a file's path relative
+	// to the repository subdirectory, or against its base name when the
+	// pattern contains no "/".
+	Pattern string `yaml:"pattern"`
+	// Dest is the absolute directory matching files are installed to.
+	Dest string `yaml:"dest"`
 }
 
+// UnitExtensions lists the plain systemd unit types sync.unit_extensions
+// may name.
+var UnitExtensions = []string{".service", ".timer", ".socket", ".path", ".target"}
+
+// PruneScope defines which files sync.prune may delete.
+type PruneScope string
+
+const (
+	// PruneManaged only deletes files recorded in the state, i.e. files
+	// quadsyncd wrote itself.
+	PruneManaged PruneScope = "managed"
+	// PruneAll also deletes files in the quadlet dir that quadsyncd never
+	// wrote, when AllowUnmanagedDelete is set.
+	PruneAll PruneScope = "all"
+)
+
+// ValidationScope defines which quadlets the generator validates before a
+// sync applies its plan.
+type ValidationScope string
+
+const (
+	// ValidateChanged validates only the quadlets the plan adds or updates,
+	// the quadlets referencing one the plan deletes, and the quadlets and
+	// drop-ins they reference, so an unrelated broken file does not block
+	// syncs.
+	ValidateChanged ValidationScope = "changed"
+	// ValidateAll validates the whole quadlet dir as it will be after the
+	// sync.
+	ValidateAll ValidationScope = "all"
+)
+
+// PodmanCompatMode defines how a sync treats quadlets that use features the
+// installed Podman does not support.
+type PodmanCompatMode string
+
+const (
+	// PodmanCompatWarn records a warning per unsupported feature.
+	PodmanCompatWarn PodmanCompatMode = "warn"
+	// PodmanCompatFail fails the sync before anything is applied.
+	PodmanCompatFail PodmanCompatMode = "fail"
+	// PodmanCompatOff skips the check and the version detection.
+	PodmanCompatOff PodmanCompatMode = "off"
+)
+
+// SELinuxMode defines when installed files are relabeled with restorecon.
+type SELinuxMode string
+
+const (
+	// SELinuxAuto relabels when SELinux is enforcing.
+	SELinuxAuto SELinuxMode = "auto"
+	// SELinuxAlways relabels whenever SELinux is enabled, also in
+	// permissive mode.
+	SELinuxAlways SELinuxMode = "always"
+	// SELinuxNever leaves the labels files get when they are written.
+	SELinuxNever SELinuxMode = "never"
+)
+
 // SyncConfig configures sync behavior
 type SyncConfig struct {
 	Prune            bool          `yaml:"prune"`
 	Restart          RestartPolicy `yaml:"restart"`
 	ConflictHandling ConflictMode  `yaml:"conflict_handling"`
+	// PruneScope is PruneManaged (default) or PruneAll.
+	PruneScope PruneScope `yaml:"prune_scope,omitempty"`
+	// ValidationScope is ValidateChanged (default) or ValidateAll.
+	ValidationScope ValidationScope `yaml:"validation_scope,omitempty"`
+	// PodmanCompat is PodmanCompatWarn (default), PodmanCompatFail or
+	// PodmanCompatOff.
+	PodmanCompat PodmanCompatMode `yaml:"podman_compat,omitempty"`
+	// AllowUnmanagedDelete confirms that PruneAll may delete files quadsyncd
+	// never wrote. It is set by --allow-unmanaged-delete and deliberately
+	// not read from the config file.
+	AllowUnmanagedDelete bool `yaml:"-"`
+	// AllowedDestRoots lists absolute directory prefixes under which files
+	// declared in a repo manifest may be placed outside the quadlet dir.
+	AllowedDestRoots []string `yaml:"allowed_dest_roots"`
+	// BackupRetention is how many pre-sync snapshots of the managed files to
+	// keep under <state_dir>/backups for `quadsyncd restore`. 0 disables.
+	BackupRetention int `yaml:"backup_retention"`
+	// MaxParallel caps how many repositories are fetched and loaded at the
+	// same time. 0 or 1 loads them one after another.
+	MaxParallel int `yaml:"max_parallel"`
+	// PreflightWriteProbe creates and removes a probe file in every
+	// destination directory before applying a plan, in addition to the
+	// permission check that always runs.
+	PreflightWriteProbe bool `yaml:"preflight_write_probe"`
+	// AgeIdentityFile is passed to sops as SOPS_AGE_KEY_FILE when decrypting
+	// sops-encrypted files before they are written to their destination.
+	AgeIdentityFile string `yaml:"age_identity_file,omitempty"`
+	// FileMode is applied to every installed file. Unset keeps the mode of
+	// the checkout, which depends on the umask of the clone.
+	FileMode Mode `yaml:"file_mode,omitempty"`
+	// DirMode is applied to directories quadsyncd creates. Unset uses 0755
+	// minus the umask.
+	DirMode Mode `yaml:"dir_mode,omitempty"`
+	// Owner is "user[:group]", by name or numeric ID, for installed files and
+	// created directories. Changing to another user requires root.
+	Owner string `yaml:"owner,omitempty"`
+	// StrictPermissions rejects group- or world-writable file modes.
+	StrictPermissions bool `yaml:"strict_permissions,omitempty"`
+	// RestartBatchSize caps how many units one try-restart call restarts;
+	// the rest follow in later batches. 0 restarts each dependency tier at
+	// once.
+	RestartBatchSize int `yaml:"restart_batch_size,omitempty"`
+	// RestartBatchDelay is the pause between restart batches.
+	RestartBatchDelay time.Duration `yaml:"restart_batch_delay,omitempty"`
+	// RestartBatchHealthCheck polls each batch for failed units during
+	// RestartBatchDelay and stops restarting when one failed.
+	RestartBatchHealthCheck bool `yaml:"restart_batch_health_check,omitempty"`
+	// StartNew starts the units of newly added quadlets after daemon-reload,
+	// since try-restart leaves units that are not running stopped.
+	StartNew bool `yaml:"start_new,omitempty"`
+	// AlwaysReload runs daemon-reload after every applied sync, also when no
+	// file changed.
+	AlwaysReload bool `yaml:"always_reload,omitempty"`
+	// UnitExtensions lists the extensions (from UnitExtensions) of plain
+	// systemd units in the repository that are installed to
+	// paths.unit_dir instead of the quadlet dir.
+	UnitExtensions []string `yaml:"unit_extensions,omitempty"`
+	// EnsureWantedBy, when set, adds WantedBy=<unit> to the [Install]
+	// section of managed .container quadlets that declare no install target,
+	// so their units start on boot.
+	EnsureWantedBy string `yaml:"ensure_wantedby,omitempty"`
+	// RunAutoUpdate runs `podman auto-update` after every applied sync, so
+	// containers labelled io.containers.autoupdate pick up new images too.
+	RunAutoUpdate bool `yaml:"run_auto_update,omitempty"`
+	// SELinuxRestorecon is SELinuxAuto (default), SELinuxAlways or
+	// SELinuxNever.
+	SELinuxRestorecon SELinuxMode `yaml:"selinux_restorecon,omitempty"`
+	// HealthCheck watches restarted and started units after a sync.
+	HealthCheck HealthCheckConfig `yaml:"health_check,omitempty"`
+	// Timeout bounds a whole sync run. 0 means no limit.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Windows are deploy-freeze periods: a sync inside one applies file
+	// changes but defers restarts to the first sync outside all of them.
+	Windows []Window `yaml:"windows,omitempty"`
+}
+
+// HealthCheckConfig configures the post-restart unit health check.
+type HealthCheckConfig struct {
+	// Window is how long restarted and started units are polled for the
+	// failed state after a sync. 0 disables the check.
+	Window time.Duration `yaml:"window"`
+	// Fail makes units that fail within the window fail the sync instead of
+	// recording a warning.
+	Fail bool `yaml:"fail"`
 }
 
+// SSH host key checking modes for auth.ssh_strict_host_key_checking.
+const (
+	// SSHStrictHostKeyAcceptNew records unknown host keys on first use and
+	// rejects changed ones. This is the default.
+	SSHStrictHostKeyAcceptNew = "accept-new"
+	// SSHStrictHostKeyYes only connects to hosts whose key is already in
+	// the known hosts file.
+	SSHStrictHostKeyYes = "yes"
+)
+
 // AuthConfig configures Git authentication
 type AuthConfig struct {
-	SSHKeyFile     string `yaml:"ssh_key_file"`
-	HTTPSTokenFile string `yaml:"https_token_file"`
+	SSHKeyFile string `yaml:"ssh_key_file"`
+	// SSHKeyCredential names a systemd credential holding the SSH key, used
+	// instead of SSHKeyFile.
+	SSHKeyCredential string `yaml:"ssh_key_credential,omitempty"`
+	// SSHKnownHostsFile is used instead of ~/.ssh/known_hosts for SSH
+	// remotes, e.g. a file populated by `quadsyncd trust-host`.
+	SSHKnownHostsFile string `yaml:"ssh_known_hosts_file,omitempty"`
+	// SSHStrictHostKeyChecking is SSHStrictHostKeyAcceptNew (default) or
+	// SSHStrictHostKeyYes.
+	SSHStrictHostKeyChecking string `yaml:"ssh_strict_host_key_checking,omitempty"`
+	HTTPSTokenFile           string `yaml:"https_token_file"`
+	// HTTPSTokenCredential names a systemd credential holding the HTTPS
+	// token or password, used instead of HTTPSTokenFile.
+	HTTPSTokenCredential string `yaml:"https_token_credential,omitempty"`
+	// HTTPSPasswordFile is an alias for HTTPSTokenFile that reads better
+	// next to HTTPSUsername.
+	HTTPSPasswordFile string `yaml:"https_password_file,omitempty"`
+	// HTTPSUsername is sent with the HTTPS token or password; empty means
+	// "x-access-token", which GitHub, Gitea and GitLab accept for tokens.
+	HTTPSUsername string `yaml:"https_username,omitempty"`
+	// HTTPSTokenCommand is a shell command whose stdout is used as the HTTPS
+	// token; it is run at every fetch (e.g. "gh auth token"). Not env-expanded
+	// so that the shell sees any $VAR references itself.
+	HTTPSTokenCommand string `yaml:"https_token_command,omitempty"`
+	// HTTPSTokenExpiresAt optionally records when the token in HTTPSTokenFile
+	// expires (YYYY-MM-DD or RFC 3339). Syncs warn as the date approaches.
+	HTTPSTokenExpiresAt string `yaml:"https_token_expires_at,omitempty"`
+}
+
+// TokenFile returns the file holding the HTTPS token or password, set via
+// https_token_file or its alias https_password_file.
+func (a AuthConfig) TokenFile() string {
+	if a.HTTPSTokenFile != "" {
+		return a.HTTPSTokenFile
+	}
+	return a.HTTPSPasswordFile
+}
+
+// TokenExpiryWarningWindow is how far ahead of a recorded token expiry
+// quadsyncd starts emitting warnings.
+const TokenExpiryWarningWindow = 14 * 24 * time.Hour
+
+// TokenExpiry returns the parsed HTTPS token expiry and whether one is set.
+// Invalid values are rejected by Validate, so they are reported as unset here.
+func (a AuthConfig) TokenExpiry() (time.Time, bool) {
+	if a.HTTPSTokenExpiresAt == "" {
+		return time.Time{}, false
+	}
+	t, err := parseExpiry(a.HTTPSTokenExpiresAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parseExpiry accepts a plain date (interpreted as midnight UTC) or an
+// RFC 3339 timestamp.
+func parseExpiry(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// ValuesConfig configures layered values files for quadlet templating.
+type ValuesConfig struct {
+	// Files are deep-merged in order; later files override earlier ones.
+	// Relative paths are resolved against the config file's directory.
+	Files []string `yaml:"files"`
+}
+
+// SecretsConfig configures decrypting the secrets declared in repository
+// manifests into podman secrets.
+type SecretsConfig struct {
+	// Enabled turns on secret syncing. When off, declared secrets are
+	// skipped with a warning.
+	Enabled bool `yaml:"enabled"`
+	// AgeIdentityFile is the age private key used to decrypt age files and,
+	// as SOPS_AGE_KEY_FILE, sops files.
+	AgeIdentityFile string `yaml:"age_identity_file"`
+	// AgeIdentityCredential names a systemd credential holding the age
+	// identity, used instead of AgeIdentityFile.
+	AgeIdentityCredential string `yaml:"age_identity_credential,omitempty"`
 }
 
 // ServeConfig configures the webhook server
 type ServeConfig struct {
-	Enabled                 bool     `yaml:"enabled"`
-	ListenAddr              string   `yaml:"listen_addr"`
-	GitHubWebhookSecretFile string   `yaml:"github_webhook_secret_file"`
-	AllowedEventTypes       []string `yaml:"allowed_event_types"`
-	AllowedRefs             []string `yaml:"allowed_refs"`
+	Enabled                 bool   `yaml:"enabled"`
+	ListenAddr              string `yaml:"listen_addr"`
+	GitHubWebhookSecretFile string `yaml:"github_webhook_secret_file"`
+	// GitHubWebhookSecretCredential names a systemd credential holding the
+	// webhook secret, used instead of GitHubWebhookSecretFile.
+	GitHubWebhookSecretCredential string   `yaml:"github_webhook_secret_credential,omitempty"`
+	AllowedEventTypes             []string `yaml:"allowed_event_types"`
+	AllowedRefs                   []string `yaml:"allowed_refs"`
+	// AllowedCIDRs restricts which client addresses may call /webhook.
+	// Entries are CIDRs, bare IPs or "github" for GitHub's hook ranges.
+	// Empty allows all clients.
+	AllowedCIDRs []string `yaml:"allowed_cidrs"`
+	// TrustedProxies lists proxy addresses whose X-Forwarded-For header is
+	// used to determine the client address. Same syntax as AllowedCIDRs.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// RateLimit throttles /webhook requests per client address.
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	// MaxInFlight caps concurrently processed /webhook requests. 0 disables.
+	MaxInFlight int `yaml:"max_in_flight"`
+	// SyncTimeout bounds each sync run of the server. Defaults to
+	// sync.timeout.
+	SyncTimeout time.Duration `yaml:"sync_timeout,omitempty"`
+	// Debounce is how long the server waits after a webhook for further
+	// webhooks before syncing.
+	Debounce time.Duration `yaml:"debounce,omitempty"`
+	// DebounceMaxWait bounds how long a stream of webhooks can postpone the
+	// sync, counted from the first one.
+	DebounceMaxWait time.Duration `yaml:"debounce_max_wait,omitempty"`
+	// ShutdownGrace is how long shutdown waits for a running sync before
+	// cancelling it.
+	ShutdownGrace time.Duration `yaml:"shutdown_grace,omitempty"`
+	// RequireRepoMatch rejects signed webhooks whose repository.full_name
+	// is not one of the configured repositories, instead of ignoring them.
+	RequireRepoMatch bool `yaml:"require_repo_match,omitempty"`
+	// ReplayHistory is how many recent webhook deliveries are remembered to
+	// reject replays.
+	ReplayHistory int `yaml:"replay_history,omitempty"`
+	// MaxPayloadAge rejects push deliveries whose head commit timestamp is
+	// older than this. 0 disables the check.
+	MaxPayloadAge time.Duration `yaml:"max_payload_age,omitempty"`
+	// WatchSource syncs when files change in a local directory source or a
+	// git checkout, e.g. after a manual `git pull` on the host.
+	WatchSource bool `yaml:"watch_source,omitempty"`
+	// APITokenFile holds a bearer token required by the JSON API under
+	// /api/. Unset leaves the API open to anyone who can reach the server.
+	APITokenFile string `yaml:"api_token_file,omitempty"`
+	// APITokenCredential names a systemd credential holding the API token,
+	// used instead of APITokenFile.
+	APITokenCredential string `yaml:"api_token_credential,omitempty"`
+	// DisableUI stops serving the Web UI under /ui/. The JSON API stays
+	// available.
+	DisableUI bool `yaml:"disable_ui,omitempty"`
+	// Listeners serve webhooks of other providers, or with other secrets,
+	// on their own paths, in addition to GitHub's on /webhook.
+	Listeners []WebhookListener `yaml:"listeners,omitempty"`
+}
+
+// DefaultWebhookPath is where GitHub deliveries signed with
+// serve.github_webhook_secret_file are received.
+const DefaultWebhookPath = "/webhook"
+
+// WebhookListener receives the deliveries of one webhook provider on a
+// path of the server.
+type WebhookListener struct {
+	// Path is the URL path deliveries are posted to, e.g. /hooks/gitlab.
+	Path string `yaml:"path"`
+	// Provider names the service sending the deliveries, e.g. github,
+	// gitea or gitlab.
+	Provider string `yaml:"provider"`
+	// SecretFile holds the secret deliveries are verified with.
+	SecretFile string `yaml:"secret_file"`
+	// SecretCredential names a systemd credential holding the secret, used
+	// instead of SecretFile.
+	SecretCredential string `yaml:"secret_credential,omitempty"`
+	// ListenAddr serves the listener on an address of its own, which
+	// serves nothing but the webhook paths bound to it. Empty serves it on
+	// serve.listen_addr.
+	ListenAddr string `yaml:"listen_addr,omitempty"`
+	// Repository binds the listener to the configured repository with this
+	// URL; deliveries for any other repository are rejected.
+	Repository string `yaml:"repository,omitempty"`
+	// Generic maps the payload for the generic provider.
+	Generic GenericWebhook `yaml:"generic,omitempty"`
+}
+
+// GenericWebhook configures the generic provider, which accepts any JSON
+// payload signed with an HMAC-SHA256 of the body. The field expressions are
+// JSONPath-style, such as $.build.ref or repo["full name"].
+type GenericWebhook struct {
+	// SignatureHeader names the header carrying the hex HMAC, optionally
+	// prefixed with "sha256=".
+	SignatureHeader string `yaml:"signature_header"`
+	// DeliveryHeader names the header carrying a unique delivery ID, used
+	// for replay protection in addition to the body.
+	DeliveryHeader string `yaml:"delivery_header,omitempty"`
+	// Ref locates the pushed ref; a value without refs/ is a branch name.
+	Ref string `yaml:"ref"`
+	// Commit locates the pushed commit, which is only logged.
+	Commit string `yaml:"commit,omitempty"`
+	// Repository locates the owner/name path or a clone URL of the
+	// repository, matched against the configured repositories.
+	Repository string `yaml:"repository"`
+}
+
+// reservedServePrefixes are served by quadsyncd itself and cannot take
+// webhooks, nor can the root.
+var reservedServePrefixes = []string{"/-/", "/api/", "/assets/", "/ui/"}
+
+// WebhookListeners returns every webhook listener of the server: GitHub on
+// DefaultWebhookPath when serve.github_webhook_secret_file is set, followed
+// by serve.listeners.
+func (c *Config) WebhookListeners() []WebhookListener {
+	var listeners []WebhookListener
+	if c.Serve.GitHubWebhookSecretFile != "" {
+		listeners = append(listeners, WebhookListener{
+			Path:       DefaultWebhookPath,
+			Provider:   "github",
+			SecretFile: c.Serve.GitHubWebhookSecretFile,
+		})
+	}
+	return append(listeners, c.Serve.Listeners...)
+}
+
+// validateWebhookListeners checks serve.listeners. Provider names are
+// checked when the server starts, against the registered providers.
+func (c *Config) validateWebhookListeners() error {
+	seen := make(map[string]bool)
+	if c.Serve.GitHubWebhookSecretFile != "" {
+		seen[DefaultWebhookPath] = true
+	}
+	for i, l := range c.Serve.Listeners {
+		label := fmt.Sprintf("serve.listeners[%d]", i)
+		if !strings.HasPrefix(l.Path, "/") || path.Clean(l.Path) != l.Path {
+			return fmt.Errorf("%s.path must be a clean absolute URL path: %q", label, l.Path)
+		}
+		if l.Path == "/" {
+			return fmt.Errorf("%s.path %s is reserved", label, l.Path)
+		}
+		for _, prefix := range reservedServePrefixes {
+			if strings.HasPrefix(l.Path+"/", prefix) {
+				return fmt.Errorf("%s.path %s is reserved", label, l.Path)
+			}
+		}
+		if seen[l.Path] {
+			return fmt.Errorf("%s.path %s is used by another listener", label, l.Path)
+		}
+		seen[l.Path] = true
+		if l.Provider == "" {
+			return fmt.Errorf("%s.provider is required", label)
+		}
+		if l.SecretFile == "" {
+			return fmt.Errorf("%s.secret_file or %s.secret_credential is required", label, label)
+		}
+		if l.ListenAddr != "" {
+			if _, _, err := net.SplitHostPort(l.ListenAddr); err != nil {
+				return fmt.Errorf("%s.listen_addr must be host:port: %w", label, err)
+			}
+		}
+		if l.Repository != "" && !c.hasGitRepository(l.Repository) {
+			return fmt.Errorf("%s.repository %s is not the url of a configured git repository", label, l.Repository)
+		}
+		if l.Provider == "generic" {
+			g := l.Generic
+			if g.SignatureHeader == "" || g.Ref == "" || g.Repository == "" {
+				return fmt.Errorf("%s.generic.signature_header, ref and repository are required for the generic provider", label)
+			}
+		} else if l.Generic != (GenericWebhook{}) {
+			return fmt.Errorf("%s.generic is only valid for the generic provider", label)
+		}
+	}
+	return nil
+}
+
+// hasGitRepository reports whether url is the URL of a configured
+// repository synced with git.
+func (c *Config) hasGitRepository(url string) bool {
+	for _, spec := range c.EffectiveRepositories() {
+		if spec.UsesGit() && spec.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+// Default webhook debounce applied when serve.debounce* is unset.
+const (
+	DefaultDebounce        = 2 * time.Second
+	DefaultDebounceMaxWait = 30 * time.Second
+)
+
+// DefaultShutdownGrace is applied when serve.shutdown_grace is unset.
+const DefaultShutdownGrace = 30 * time.Second
+
+// DefaultReplayHistory is applied when serve.replay_history is unset.
+const DefaultReplayHistory = 1000
+
+// ImageWatchAction defines how the image watcher refreshes containers whose
+// image has a new digest.
+type ImageWatchAction string
+
+const (
+	// ImageWatchRestart pulls the new image and try-restarts the units using it.
+	ImageWatchRestart ImageWatchAction = "restart"
+	// ImageWatchAutoUpdate runs `podman auto-update`.
+	ImageWatchAutoUpdate ImageWatchAction = "auto-update"
+)
+
+// DefaultImageWatchInterval is how often images are checked when
+// image_watch.interval is unset.
+const DefaultImageWatchInterval = time.Hour
+
+// MinImageWatchInterval keeps the watcher from hammering registries, which
+// rate-limit manifest requests.
+const MinImageWatchInterval = time.Minute
+
+// ImageWatchConfig configures checking the images of managed container
+// quadlets for new digests while serving.
+type ImageWatchConfig struct {
+	// Enabled starts the image watcher in `quadsyncd serve`.
+	Enabled bool `yaml:"enabled"`
+	// Interval is the time between checks.
+	Interval time.Duration `yaml:"interval"`
+	// Action is ImageWatchRestart (default) or ImageWatchAutoUpdate.
+	Action ImageWatchAction `yaml:"action"`
+}
+
+// Default per-command timeouts applied when timeouts.* is unset.
+const (
+	DefaultGitTimeout       = 10 * time.Minute
+	DefaultSystemctlTimeout = 5 * time.Minute
+	DefaultPodmanTimeout    = 2 * time.Minute
+)
+
+// TimeoutsConfig bounds how long a single invocation of each external tool
+// may run. When a limit expires the tool and all processes it spawned are
+// killed and the command fails.
+type TimeoutsConfig struct {
+	// Git limits each git command (clone, fetch, checkout, ...).
+	Git time.Duration `yaml:"git"`
+	// Systemctl limits each systemctl --user command.
+	Systemctl time.Duration `yaml:"systemctl"`
+	// Podman limits each run of the podman quadlet generator.
+	Podman time.Duration `yaml:"podman"`
+}
+
+// SystemdBackend selects how quadsyncd talks to the systemd user manager.
+type SystemdBackend string
+
+const (
+	// SystemdShell runs systemctl --user.
+	SystemdShell SystemdBackend = "shell"
+	// SystemdDBus calls org.freedesktop.systemd1 on the user's session bus
+	// and waits for restart jobs to finish.
+	SystemdDBus SystemdBackend = "dbus"
+)
+
+// SystemdConfig configures access to the systemd user manager.
+type SystemdConfig struct {
+	// Backend is SystemdShell (default) or SystemdDBus.
+	Backend SystemdBackend `yaml:"backend"`
+}
+
+// SubstitutionConfig configures replacing ${NAME} tokens in synced files with
+// host facts and user-defined variables.
+type SubstitutionConfig struct {
+	// Enabled turns the substitution pass on; it is off by default.
+	Enabled bool `yaml:"enabled"`
+	// Vars are user-defined variables; names must not use the reserved
+	// HostFactPrefix.
+	Vars map[string]string `yaml:"vars"`
+}
+
+// AuditConfig configures the audit log of applied changes.
+type AuditConfig struct {
+	// Enabled appends every applied file and secret change and every
+	// restart outcome to AuditLogPath.
+	Enabled bool `yaml:"enabled"`
+	// Journal also sends each audit record to the systemd journal with
+	// structured fields.
+	Journal bool `yaml:"journal,omitempty"`
+}
+
+// HostFactPrefix is reserved for the host facts quadsyncd provides, such as
+// QS_HOSTNAME.
+const HostFactPrefix = "QS_"
+
+// varNamePattern matches a substitution variable name.
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Default retry policy for git checkouts applied when git_retry.* is unset.
+const (
+	DefaultGitRetryAttempts  = 3
+	DefaultGitRetryBaseDelay = 2 * time.Second
+	DefaultGitRetryMaxDelay  = 30 * time.Second
+)
+
+// GitRetryConfig configures retrying repository fetches that fail with a
+// network error, with exponential backoff between attempts.
+type GitRetryConfig struct {
+	// Attempts is the total number of tries per fetch; 1 disables retries.
+	Attempts int `yaml:"attempts"`
+	// BaseDelay is the wait before the first retry; it doubles per retry.
+	BaseDelay time.Duration `yaml:"base_delay"`
+	// MaxDelay caps the wait between two tries.
+	MaxDelay time.Duration `yaml:"max_delay"`
+}
+
+// GitMaintenanceConfig configures upkeep of the git checkouts in the state
+// directory.
+type GitMaintenanceConfig struct {
+	// Interval is how often a checkout is pruned (git remote prune origin)
+	// and compacted (git gc --auto). 0 disables maintenance.
+	Interval time.Duration `yaml:"interval"`
+	// MaxSizeMB clones a checkout again from scratch once its .git directory
+	// exceeds this many MiB. 0 disables the limit.
+	MaxSizeMB int `yaml:"max_size_mb"`
+	// KeepCorrupt fails the sync when a checkout is damaged instead of
+	// removing it and cloning it again, e.g. to inspect it.
+	KeepCorrupt bool `yaml:"keep_corrupt,omitempty"`
+}
+
+// RateLimitConfig configures a per-client token bucket.
+type RateLimitConfig struct {
+	// RequestsPerMinute is the sustained rate per client. 0 disables limiting.
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	// Burst is how many requests a client may send at once. Defaults to
+	// RequestsPerMinute when unset.
+	Burst int `yaml:"burst"`
+}
+
+// GitHubHookCIDRs are the source ranges GitHub delivers webhooks from, as
+// published in the "hooks" list of https://api.github.com/meta. They are
+// selected by the "github" keyword in serve.allowed_cidrs.
+var GitHubHookCIDRs = []string{
+	"192.30.252.0/22",
+	"185.199.108.0/22",
+	"140.82.112.0/20",
+	"143.55.64.0/20",
+	"2a0a:a440::/29",
+	"2606:50c0::/32",
+}
+
+// ParseCIDRList parses CIDR entries into prefixes. Bare IP addresses are
+// treated as single-host prefixes and "github" expands to GitHubHookCIDRs.
+func ParseCIDRList(entries []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "github" {
+			for _, c := range GitHubHookCIDRs {
+				prefixes = append(prefixes, netip.MustParsePrefix(c))
+			}
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
 }
 
 // Load reads and parses the configuration file
@@ -92,11 +741,21 @@ func Load(path string) (*Config, error) {
 	}
 
 	cfg.expandEnv()
+	if err := cfg.expandLayers(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	cfg.resolveValuesFiles(filepath.Dir(path))
+	if err := cfg.resolveCredentials(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
 	cfg.applyDefaults()
 
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	if err := cfg.CheckQuadletDir(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
 
 	return &cfg, nil
 }
@@ -107,24 +766,88 @@ func (c *Config) expandEnv() {
 		c.Repository.URL = os.ExpandEnv(c.Repository.URL)
 		c.Repository.Ref = os.ExpandEnv(c.Repository.Ref)
 		c.Repository.Subdir = os.ExpandEnv(c.Repository.Subdir)
+		c.Repository.Source.CosignKey = os.ExpandEnv(c.Repository.Source.CosignKey)
+		c.Repository.Checksums.CosignKey = os.ExpandEnv(c.Repository.Checksums.CosignKey)
+		for i := range c.Repository.Layers {
+			c.Repository.Layers[i] = os.ExpandEnv(c.Repository.Layers[i])
+		}
 		if c.Repository.Auth != nil {
 			c.Repository.Auth.SSHKeyFile = os.ExpandEnv(c.Repository.Auth.SSHKeyFile)
+			c.Repository.Auth.SSHKnownHostsFile = os.ExpandEnv(c.Repository.Auth.SSHKnownHostsFile)
 			c.Repository.Auth.HTTPSTokenFile = os.ExpandEnv(c.Repository.Auth.HTTPSTokenFile)
+			c.Repository.Auth.HTTPSPasswordFile = os.ExpandEnv(c.Repository.Auth.HTTPSPasswordFile)
 		}
 	}
 	c.Paths.QuadletDir = os.ExpandEnv(c.Paths.QuadletDir)
 	c.Paths.StateDir = os.ExpandEnv(c.Paths.StateDir)
+	c.Paths.UnitDir = os.ExpandEnv(c.Paths.UnitDir)
+	for i := range c.Paths.Routes {
+		c.Paths.Routes[i].Dest = os.ExpandEnv(c.Paths.Routes[i].Dest)
+	}
+	for i := range c.Paths.ExtraQuadletRoots {
+		c.Paths.ExtraQuadletRoots[i] = os.ExpandEnv(c.Paths.ExtraQuadletRoots[i])
+	}
 	c.Auth.SSHKeyFile = os.ExpandEnv(c.Auth.SSHKeyFile)
+	c.Auth.SSHKnownHostsFile = os.ExpandEnv(c.Auth.SSHKnownHostsFile)
 	c.Auth.HTTPSTokenFile = os.ExpandEnv(c.Auth.HTTPSTokenFile)
+	c.Auth.HTTPSPasswordFile = os.ExpandEnv(c.Auth.HTTPSPasswordFile)
 	c.Serve.ListenAddr = os.ExpandEnv(c.Serve.ListenAddr)
 	c.Serve.GitHubWebhookSecretFile = os.ExpandEnv(c.Serve.GitHubWebhookSecretFile)
+	for i := range c.Serve.Listeners {
+		c.Serve.Listeners[i].SecretFile = os.ExpandEnv(c.Serve.Listeners[i].SecretFile)
+		c.Serve.Listeners[i].ListenAddr = os.ExpandEnv(c.Serve.Listeners[i].ListenAddr)
+		c.Serve.Listeners[i].Repository = os.ExpandEnv(c.Serve.Listeners[i].Repository)
+	}
+	c.Serve.APITokenFile = os.ExpandEnv(c.Serve.APITokenFile)
+	c.Secrets.AgeIdentityFile = os.ExpandEnv(c.Secrets.AgeIdentityFile)
+	c.Sync.AgeIdentityFile = os.ExpandEnv(c.Sync.AgeIdentityFile)
+	c.ImageSignatures.CosignKey = os.ExpandEnv(c.ImageSignatures.CosignKey)
+	for i := range c.Sync.AllowedDestRoots {
+		c.Sync.AllowedDestRoots[i] = os.ExpandEnv(c.Sync.AllowedDestRoots[i])
+	}
+	for i := range c.Values.Files {
+		c.Values.Files[i] = os.ExpandEnv(c.Values.Files[i])
+	}
 	for i := range c.Repositories {
 		c.Repositories[i].URL = os.ExpandEnv(c.Repositories[i].URL)
 		c.Repositories[i].Ref = os.ExpandEnv(c.Repositories[i].Ref)
 		c.Repositories[i].Subdir = os.ExpandEnv(c.Repositories[i].Subdir)
+		c.Repositories[i].Source.CosignKey = os.ExpandEnv(c.Repositories[i].Source.CosignKey)
+		c.Repositories[i].Checksums.CosignKey = os.ExpandEnv(c.Repositories[i].Checksums.CosignKey)
+		for j := range c.Repositories[i].Layers {
+			c.Repositories[i].Layers[j] = os.ExpandEnv(c.Repositories[i].Layers[j])
+		}
 		if c.Repositories[i].Auth != nil {
 			c.Repositories[i].Auth.SSHKeyFile = os.ExpandEnv(c.Repositories[i].Auth.SSHKeyFile)
+			c.Repositories[i].Auth.SSHKnownHostsFile = os.ExpandEnv(c.Repositories[i].Auth.SSHKnownHostsFile)
 			c.Repositories[i].Auth.HTTPSTokenFile = os.ExpandEnv(c.Repositories[i].Auth.HTTPSTokenFile)
+			c.Repositories[i].Auth.HTTPSPasswordFile = os.ExpandEnv(c.Repositories[i].Auth.HTTPSPasswordFile)
+		}
+	}
+}
+
+// DestAllowed reports whether path lies under one of sync.allowed_dest_roots.
+func (c *Config) DestAllowed(path string) bool {
+	path = filepath.Clean(path)
+	for _, root := range c.Sync.AllowedDestRoots {
+		rel, err := filepath.Rel(filepath.Clean(root), path)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveValuesFiles makes relative values file paths absolute against baseDir.
+func (c *Config) resolveValuesFiles(baseDir string) {
+	if !filepath.IsAbs(baseDir) {
+		if abs, err := filepath.Abs(baseDir); err == nil {
+			baseDir = abs
+		}
+	}
+	for i, f := range c.Values.Files {
+		if f != "" && !filepath.IsAbs(f) {
+			c.Values.Files[i] = filepath.Join(baseDir, f)
 		}
 	}
 }
@@ -137,6 +860,63 @@ func (c *Config) applyDefaults() {
 	if c.Sync.ConflictHandling == "" {
 		c.Sync.ConflictHandling = ConflictPreferHighestPriority
 	}
+	if c.Sync.PruneScope == "" {
+		c.Sync.PruneScope = PruneManaged
+	}
+	if c.Sync.ValidationScope == "" {
+		c.Sync.ValidationScope = ValidateChanged
+	}
+	if c.Sync.PodmanCompat == "" {
+		c.Sync.PodmanCompat = PodmanCompatWarn
+	}
+	if c.Sync.SELinuxRestorecon == "" {
+		c.Sync.SELinuxRestorecon = SELinuxAuto
+	}
+	if len(c.Sync.UnitExtensions) > 0 && c.Paths.UnitDir == "" {
+		c.Paths.UnitDir = DefaultUnitDir()
+	}
+	if c.Serve.Debounce == 0 {
+		c.Serve.Debounce = DefaultDebounce
+	}
+	if c.Serve.DebounceMaxWait == 0 {
+		c.Serve.DebounceMaxWait = max(DefaultDebounceMaxWait, c.Serve.Debounce)
+	}
+	if c.Serve.ShutdownGrace == 0 {
+		c.Serve.ShutdownGrace = DefaultShutdownGrace
+	}
+	if c.Serve.ReplayHistory == 0 {
+		c.Serve.ReplayHistory = DefaultReplayHistory
+	}
+	if c.Serve.SyncTimeout == 0 {
+		c.Serve.SyncTimeout = c.Sync.Timeout
+	}
+	if c.Timeouts.Git == 0 {
+		c.Timeouts.Git = DefaultGitTimeout
+	}
+	if c.Timeouts.Systemctl == 0 {
+		c.Timeouts.Systemctl = DefaultSystemctlTimeout
+	}
+	if c.Timeouts.Podman == 0 {
+		c.Timeouts.Podman = DefaultPodmanTimeout
+	}
+	if c.GitRetry.Attempts == 0 {
+		c.GitRetry.Attempts = DefaultGitRetryAttempts
+	}
+	if c.GitRetry.BaseDelay == 0 {
+		c.GitRetry.BaseDelay = DefaultGitRetryBaseDelay
+	}
+	if c.GitRetry.MaxDelay == 0 {
+		c.GitRetry.MaxDelay = DefaultGitRetryMaxDelay
+	}
+	if c.ImageWatch.Interval == 0 {
+		c.ImageWatch.Interval = DefaultImageWatchInterval
+	}
+	if c.ImageWatch.Action == "" {
+		c.ImageWatch.Action = ImageWatchRestart
+	}
+	if c.Systemd.Backend == "" {
+		c.Systemd.Backend = SystemdShell
+	}
 }
 
 // Validate checks the configuration for errors
@@ -156,7 +936,7 @@ func (c *Config) Validate() error {
 			return err
 		}
 		auth := c.AuthForSpec(*c.Repository)
-		if err := validateAuth(&auth, c.Repository.URL); err != nil {
+		if err := validateAuth(&auth, c.Repository.URL); err != nil && c.Repository.UsesGit() {
 			return fmt.Errorf("repository: %w", err)
 		}
 	} else {
@@ -165,8 +945,9 @@ func (c *Config) Validate() error {
 			if err := validateRepoSpec(spec, label); err != nil {
 				return err
 			}
+			// The auth section only applies to git repositories.
 			auth := c.AuthForSpec(spec)
-			if err := validateAuth(&auth, spec.URL); err != nil {
+			if err := validateAuth(&auth, spec.URL); err != nil && spec.UsesGit() {
 				return fmt.Errorf("%s: %w", label, err)
 			}
 		}
@@ -185,6 +966,27 @@ func (c *Config) Validate() error {
 	if !filepath.IsAbs(c.Paths.StateDir) {
 		return fmt.Errorf("paths.state_dir must be an absolute path: %s", c.Paths.StateDir)
 	}
+	for i, root := range c.Paths.ExtraQuadletRoots {
+		if !filepath.IsAbs(root) || filepath.Clean(root) == "/" {
+			return fmt.Errorf("paths.extra_quadlet_roots[%d] must be an absolute path other than /: %q", i, root)
+		}
+	}
+	if c.Paths.UnitDir != "" && !filepath.IsAbs(c.Paths.UnitDir) {
+		return fmt.Errorf("paths.unit_dir must be an absolute path: %s", c.Paths.UnitDir)
+	}
+	for _, ext := range c.Sync.UnitExtensions {
+		if !slices.Contains(UnitExtensions, ext) {
+			return fmt.Errorf("invalid sync.unit_extensions entry: %q (must be one of %s)", ext, strings.Join(UnitExtensions, ", "))
+		}
+	}
+	if len(c.Sync.UnitExtensions) > 0 && c.Paths.UnitDir == "" {
+		return fmt.Errorf("paths.unit_dir is required with sync.unit_extensions")
+	}
+	for i, r := range c.Paths.Routes {
+		if err := validateRoute(r); err != nil {
+			return fmt.Errorf("invalid paths.routes[%d]: %w", i, err)
+		}
+	}
 
 	// Validate restart policy
 	switch c.Sync.Restart {
@@ -202,15 +1004,210 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid sync.conflict_handling: %s (must be prefer_highest_priority or fail)", c.Sync.ConflictHandling)
 	}
 
+	switch c.Sync.PruneScope {
+	case PruneManaged, "":
+	case PruneAll:
+		if !c.Sync.Prune {
+			return fmt.Errorf("sync.prune_scope all requires sync.prune")
+		}
+	default:
+		return fmt.Errorf("invalid sync.prune_scope: %s (must be managed or all)", c.Sync.PruneScope)
+	}
+
+	switch c.Sync.ValidationScope {
+	case ValidateChanged, ValidateAll, "":
+	default:
+		return fmt.Errorf("invalid sync.validation_scope: %s (must be changed or all)", c.Sync.ValidationScope)
+	}
+
+	switch c.Sync.PodmanCompat {
+	case PodmanCompatWarn, PodmanCompatFail, PodmanCompatOff, "":
+	default:
+		return fmt.Errorf("invalid sync.podman_compat: %s (must be warn, fail or off)", c.Sync.PodmanCompat)
+	}
+	switch c.Sync.SELinuxRestorecon {
+	case SELinuxAuto, SELinuxAlways, SELinuxNever, "":
+	default:
+		return fmt.Errorf("invalid sync.selinux_restorecon: %s (must be auto, always or never)", c.Sync.SELinuxRestorecon)
+	}
+	if t := c.Sync.EnsureWantedBy; t != "" && (strings.ContainsAny(t, "/ \t") || !strings.Contains(t, ".")) {
+		return fmt.Errorf("invalid sync.ensure_wantedby: %q (must be a unit name such as default.target)", t)
+	}
+
+	if c.Sync.BackupRetention < 0 {
+		return fmt.Errorf("sync.backup_retention must not be negative: %d", c.Sync.BackupRetention)
+	}
+
+	if c.Sync.MaxParallel < 0 {
+		return fmt.Errorf("sync.max_parallel must not be negative: %d", c.Sync.MaxParallel)
+	}
+
+	if c.Sync.RestartBatchSize < 0 {
+		return fmt.Errorf("sync.restart_batch_size must not be negative: %d", c.Sync.RestartBatchSize)
+	}
+	if c.Sync.RestartBatchDelay < 0 {
+		return fmt.Errorf("sync.restart_batch_delay must not be negative: %s", c.Sync.RestartBatchDelay)
+	}
+	if c.Sync.RestartBatchHealthCheck && c.Sync.RestartBatchDelay == 0 {
+		return fmt.Errorf("sync.restart_batch_health_check requires sync.restart_batch_delay")
+	}
+
+	if c.Sync.HealthCheck.Window < 0 {
+		return fmt.Errorf("sync.health_check.window must not be negative: %s", c.Sync.HealthCheck.Window)
+	}
+	if c.Sync.HealthCheck.Fail && c.Sync.HealthCheck.Window == 0 {
+		return fmt.Errorf("sync.health_check.fail requires sync.health_check.window")
+	}
+
+	for i, root := range c.Sync.AllowedDestRoots {
+		if !filepath.IsAbs(root) || filepath.Clean(root) == "/" {
+			return fmt.Errorf("sync.allowed_dest_roots[%d] must be an absolute path other than /: %q", i, root)
+		}
+	}
+
+	if err := validateMode(c.Sync.FileMode, "sync.file_mode", 0600, c.Sync.StrictPermissions); err != nil {
+		return err
+	}
+	if err := validateMode(c.Sync.DirMode, "sync.dir_mode", 0700, c.Sync.StrictPermissions); err != nil {
+		return err
+	}
+	if c.Sync.Owner != "" {
+		if _, _, err := SplitOwner(c.Sync.Owner); err != nil {
+			return err
+		}
+	}
+
+	if c.Sync.AgeIdentityFile != "" && !filepath.IsAbs(c.Sync.AgeIdentityFile) {
+		return fmt.Errorf("sync.age_identity_file must be an absolute path: %s", c.Sync.AgeIdentityFile)
+	}
+
+	if c.Sync.Timeout < 0 {
+		return fmt.Errorf("sync.timeout must not be negative: %s", c.Sync.Timeout)
+	}
+	if c.Serve.SyncTimeout < 0 {
+		return fmt.Errorf("serve.sync_timeout must not be negative: %s", c.Serve.SyncTimeout)
+	}
+	if c.Serve.ShutdownGrace < 0 {
+		return fmt.Errorf("serve.shutdown_grace must not be negative: %s", c.Serve.ShutdownGrace)
+	}
+	if c.Serve.Debounce < 0 || c.Serve.DebounceMaxWait < 0 {
+		return fmt.Errorf("serve.debounce and serve.debounce_max_wait must not be negative")
+	}
+	if c.Serve.DebounceMaxWait > 0 && c.Serve.DebounceMaxWait < c.Serve.Debounce {
+		return fmt.Errorf("serve.debounce_max_wait (%s) must not be less than serve.debounce (%s)", c.Serve.DebounceMaxWait, c.Serve.Debounce)
+	}
+	if c.Timeouts.Git < 0 {
+		return fmt.Errorf("timeouts.git must not be negative: %s", c.Timeouts.Git)
+	}
+	if c.Timeouts.Systemctl < 0 {
+		return fmt.Errorf("timeouts.systemctl must not be negative: %s", c.Timeouts.Systemctl)
+	}
+	if c.Timeouts.Podman < 0 {
+		return fmt.Errorf("timeouts.podman must not be negative: %s", c.Timeouts.Podman)
+	}
+	if c.GitRetry.Attempts < 0 {
+		return fmt.Errorf("git_retry.attempts must not be negative: %d", c.GitRetry.Attempts)
+	}
+	if c.GitRetry.BaseDelay < 0 || c.GitRetry.MaxDelay < 0 {
+		return fmt.Errorf("git_retry.base_delay and git_retry.max_delay must not be negative")
+	}
+	if c.GitRetry.MaxDelay > 0 && c.GitRetry.MaxDelay < c.GitRetry.BaseDelay {
+		return fmt.Errorf("git_retry.max_delay (%s) must not be less than git_retry.base_delay (%s)", c.GitRetry.MaxDelay, c.GitRetry.BaseDelay)
+	}
+
+	if c.GitMaintenance.Interval < 0 {
+		return fmt.Errorf("git_maintenance.interval must not be negative: %s", c.GitMaintenance.Interval)
+	}
+	if c.GitMaintenance.MaxSizeMB < 0 {
+		return fmt.Errorf("git_maintenance.max_size_mb must not be negative: %d", c.GitMaintenance.MaxSizeMB)
+	}
+
+	switch c.Systemd.Backend {
+	case SystemdShell, SystemdDBus, "":
+	// valid
+	default:
+		return fmt.Errorf("invalid systemd.backend: %s (must be shell or dbus)", c.Systemd.Backend)
+	}
+
+	if c.Audit.Journal && !c.Audit.Enabled {
+		return fmt.Errorf("audit.journal requires audit.enabled")
+	}
+
+	for name := range c.Substitution.Vars {
+		if !varNamePattern.MatchString(name) {
+			return fmt.Errorf("substitution.vars: invalid name %q (letters, digits and underscores, not starting with a digit)", name)
+		}
+		if strings.HasPrefix(name, HostFactPrefix) {
+			return fmt.Errorf("substitution.vars: %s uses the reserved prefix %s", name, HostFactPrefix)
+		}
+	}
+
+	// Validate values files
+	for i, f := range c.Values.Files {
+		if f == "" {
+			return fmt.Errorf("values.files[%d] must not be empty", i)
+		}
+		if !filepath.IsAbs(f) {
+			return fmt.Errorf("values.files[%d] must be an absolute path: %s", i, f)
+		}
+	}
+
+	if c.Secrets.AgeIdentityFile != "" && !filepath.IsAbs(c.Secrets.AgeIdentityFile) {
+		return fmt.Errorf("secrets.age_identity_file must be an absolute path: %s", c.Secrets.AgeIdentityFile)
+	}
+
+	switch c.ImageWatch.Action {
+	case ImageWatchRestart, ImageWatchAutoUpdate, "":
+	// valid
+	default:
+		return fmt.Errorf("invalid image_watch.action: %s (must be restart or auto-update)", c.ImageWatch.Action)
+	}
+	if c.ImageWatch.Interval != 0 && c.ImageWatch.Interval < MinImageWatchInterval {
+		return fmt.Errorf("image_watch.interval must be at least %s: %s", MinImageWatchInterval, c.ImageWatch.Interval)
+	}
+	if err := c.validateImageSignatures(); err != nil {
+		return err
+	}
+
 	// Validate serve config if enabled
 	if c.Serve.Enabled {
 		if c.Serve.ListenAddr == "" {
 			return fmt.Errorf("serve.listen_addr is required when serve is enabled")
 		}
-		if c.Serve.GitHubWebhookSecretFile == "" {
-			return fmt.Errorf("serve.github_webhook_secret_file is required when serve is enabled")
+		if c.Serve.GitHubWebhookSecretFile == "" && len(c.Serve.Listeners) == 0 {
+			return fmt.Errorf("serve.github_webhook_secret_file, serve.github_webhook_secret_credential or serve.listeners is required when serve is enabled")
 		}
 	}
+	if err := c.validateWebhookListeners(); err != nil {
+		return err
+	}
+	if c.Serve.APITokenFile != "" && !filepath.IsAbs(c.Serve.APITokenFile) {
+		return fmt.Errorf("serve.api_token_file must be an absolute path: %s", c.Serve.APITokenFile)
+	}
+	if _, err := ParseCIDRList(c.Serve.AllowedCIDRs); err != nil {
+		return fmt.Errorf("serve.allowed_cidrs: %w", err)
+	}
+	if _, err := ParseCIDRList(c.Serve.TrustedProxies); err != nil {
+		return fmt.Errorf("serve.trusted_proxies: %w", err)
+	}
+	if c.Serve.RateLimit.RequestsPerMinute < 0 {
+		return fmt.Errorf("serve.rate_limit.requests_per_minute must not be negative: %d", c.Serve.RateLimit.RequestsPerMinute)
+	}
+	if c.Serve.RateLimit.Burst < 0 {
+		return fmt.Errorf("serve.rate_limit.burst must not be negative: %d", c.Serve.RateLimit.Burst)
+	}
+	if c.Serve.MaxInFlight < 0 {
+		return fmt.Errorf("serve.max_in_flight must not be negative: %d", c.Serve.MaxInFlight)
+	}
+	if c.Serve.ReplayHistory < 0 {
+		return fmt.Errorf("serve.replay_history must not be negative: %d", c.Serve.ReplayHistory)
+	}
+	if c.Serve.MaxPayloadAge < 0 {
+		return fmt.Errorf("serve.max_payload_age must not be negative: %s", c.Serve.MaxPayloadAge)
+	}
+	if c.Serve.WatchSource && len(c.WatchedSourceDirs()) == 0 {
+		return fmt.Errorf("serve.watch_source requires a repository with source.type %s or %s", SourceGit, SourceDir)
+	}
 
 	return nil
 }
@@ -220,7 +1217,7 @@ func validateRepoSpec(spec RepoSpec, label string) error {
 	if spec.URL == "" {
 		return fmt.Errorf("%s.url is required", label)
 	}
-	if spec.Ref == "" {
+	if spec.Ref == "" && !spec.IsDir() {
 		return fmt.Errorf("%s.ref is required", label)
 	}
 	if spec.Subdir != "" {
@@ -232,6 +1229,21 @@ func validateRepoSpec(spec RepoSpec, label string) error {
 			return fmt.Errorf("%s.subdir must not contain path traversal: %s", label, spec.Subdir)
 		}
 	}
+	if err := validateLayers(spec, label); err != nil {
+		return err
+	}
+	if err := validateSource(spec, label); err != nil {
+		return err
+	}
+	if err := validateChecksums(spec, label); err != nil {
+		return err
+	}
+	if spec.CloneDepth < 0 {
+		return fmt.Errorf("%s.clone_depth must not be negative: %d", label, spec.CloneDepth)
+	}
+	if spec.Filter != "" && spec.Filter != CloneFilterBlobNone {
+		return fmt.Errorf("%s.filter must be empty or %q: %s", label, CloneFilterBlobNone, spec.Filter)
+	}
 	if spec.Auth != nil {
 		if err := validateAuth(spec.Auth, spec.URL); err != nil {
 			return fmt.Errorf("%s: %w", label, err)
@@ -242,8 +1254,14 @@ func validateRepoSpec(spec RepoSpec, label string) error {
 
 // validateAuth checks that an AuthConfig is consistent with the given repo URL.
 func validateAuth(auth *AuthConfig, repoURL string) error {
-	if auth.SSHKeyFile != "" && auth.HTTPSTokenFile != "" {
-		return fmt.Errorf("auth: only one of ssh_key_file or https_token_file may be set")
+	methods := 0
+	for _, v := range []string{auth.SSHKeyFile, auth.HTTPSTokenFile, auth.HTTPSPasswordFile, auth.HTTPSTokenCommand} {
+		if v != "" {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return fmt.Errorf("auth: only one of ssh_key_file, https_token_file, https_password_file or https_token_command may be set")
 	}
 	isSSH := strings.HasPrefix(repoURL, "git@") || strings.HasPrefix(repoURL, "ssh://")
 	isHTTPS := strings.HasPrefix(repoURL, "https://")
@@ -253,9 +1271,76 @@ func validateAuth(auth *AuthConfig, repoURL string) error {
 	if auth.HTTPSTokenFile != "" && !isHTTPS {
 		return fmt.Errorf("auth.https_token_file is set but repo.url does not use HTTPS scheme")
 	}
+	if auth.HTTPSPasswordFile != "" && !isHTTPS {
+		return fmt.Errorf("auth.https_password_file is set but repo.url does not use HTTPS scheme")
+	}
+	if auth.HTTPSTokenCommand != "" && !isHTTPS {
+		return fmt.Errorf("auth.https_token_command is set but repo.url does not use HTTPS scheme")
+	}
+	if auth.HTTPSUsername != "" {
+		if auth.TokenFile() == "" && auth.HTTPSTokenCommand == "" {
+			return fmt.Errorf("auth.https_username requires https_password_file, https_token_file or https_token_command")
+		}
+		if strings.ContainsAny(auth.HTTPSUsername, "\r\n") {
+			return fmt.Errorf("auth.https_username must not contain line breaks")
+		}
+	}
+	switch auth.SSHStrictHostKeyChecking {
+	case "", SSHStrictHostKeyAcceptNew, SSHStrictHostKeyYes:
+	default:
+		return fmt.Errorf("auth.ssh_strict_host_key_checking must be %s or %s: %s", SSHStrictHostKeyAcceptNew, SSHStrictHostKeyYes, auth.SSHStrictHostKeyChecking)
+	}
+	if auth.SSHKnownHostsFile != "" && !filepath.IsAbs(auth.SSHKnownHostsFile) {
+		return fmt.Errorf("auth.ssh_known_hosts_file must be an absolute path: %s", auth.SSHKnownHostsFile)
+	}
+	if auth.HTTPSTokenExpiresAt != "" {
+		if auth.TokenFile() == "" {
+			return fmt.Errorf("auth.https_token_expires_at requires auth.https_token_file or auth.https_password_file")
+		}
+		if _, err := parseExpiry(auth.HTTPSTokenExpiresAt); err != nil {
+			return fmt.Errorf("auth.https_token_expires_at must be YYYY-MM-DD or RFC 3339: %s", auth.HTTPSTokenExpiresAt)
+		}
+	}
 	return nil
 }
 
+// RedactedValue replaces secret values in Redacted.
+const RedactedValue = "[REDACTED]"
+
+// Redacted returns a copy of c that is safe to share: HTTPS token commands,
+// which may embed credentials, are replaced with RedactedValue. Other auth
+// fields only name files and are kept.
+func (c *Config) Redacted() Config {
+	redactAuth := func(a AuthConfig) AuthConfig {
+		if a.HTTPSTokenCommand != "" {
+			a.HTTPSTokenCommand = RedactedValue
+		}
+		return a
+	}
+
+	out := *c
+	out.Auth = redactAuth(c.Auth)
+	if c.Repository != nil {
+		spec := *c.Repository
+		if spec.Auth != nil {
+			auth := redactAuth(*spec.Auth)
+			spec.Auth = &auth
+		}
+		out.Repository = &spec
+	}
+	if c.Repositories != nil {
+		out.Repositories = make([]RepoSpec, len(c.Repositories))
+		for i, spec := range c.Repositories {
+			if spec.Auth != nil {
+				auth := redactAuth(*spec.Auth)
+				spec.Auth = &auth
+			}
+			out.Repositories[i] = spec
+		}
+	}
+	return out
+}
+
 // RepoID returns a stable, collision-resistant directory-safe identifier for
 // the given repository URL, derived from the first 8 bytes of SHA-256.
 func RepoID(url string) string {
@@ -263,6 +1348,17 @@ func RepoID(url string) string {
 	return fmt.Sprintf("%x", h[:8])
 }
 
+// DeliveryLogPath returns the path of the recent webhook deliveries kept
+// for replay protection.
+func (c *Config) DeliveryLogPath() string {
+	return filepath.Join(c.Paths.StateDir, "webhook-deliveries.json")
+}
+
+// AuditLogPath returns the path of the append-only audit log.
+func (c *Config) AuditLogPath() string {
+	return filepath.Join(c.Paths.StateDir, "audit.jsonl")
+}
+
 // StateFilePath returns the path to the state tracking file
 func (c *Config) StateFilePath() string {
 	return filepath.Join(c.Paths.StateDir, "state.json")
@@ -274,6 +1370,7 @@ func (c *Config) RepoDirForSpec(spec RepoSpec) string {
 }
 
 // QuadletSourceDirForSpec returns the quadlet source directory for a RepoSpec.
+// For a spec with layers it is the checkout root the layers are relative to.
 func (c *Config) QuadletSourceDirForSpec(spec RepoSpec) string {
 	repoDir := c.RepoDirForSpec(spec)
 	if spec.Subdir == "" {
@@ -282,6 +1379,32 @@ func (c *Config) QuadletSourceDirForSpec(spec RepoSpec) string {
 	return filepath.Join(repoDir, spec.Subdir)
 }
 
+// SparseDirsForSpec returns the directories to materialize in the checkout
+// used by spec: the slash-separated subdirs of every configured repository
+// sharing that checkout, sorted and de-duplicated. It returns nil, meaning the
+// whole tree, when any of them syncs from the repository root.
+func (c *Config) SparseDirsForSpec(spec RepoSpec) []string {
+	id := RepoID(spec.URL)
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, other := range c.EffectiveRepositories() {
+		if RepoID(other.URL) != id {
+			continue
+		}
+		for _, dir := range other.SourceDirs() {
+			if dir == "." {
+				return nil
+			}
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
 // EffectiveRepositories returns the list of repositories to sync.
 // If Repository is set, it is returned as a single-element list;
 // otherwise Repositories is returned for multi-repo mode.
@@ -292,6 +1415,15 @@ func (c *Config) EffectiveRepositories() []RepoSpec {
 	return c.Repositories
 }
 
+// SyncParallelism returns how many repositories may be loaded concurrently
+// (always at least 1).
+func (c *Config) SyncParallelism() int {
+	if c.Sync.MaxParallel < 1 {
+		return 1
+	}
+	return c.Sync.MaxParallel
+}
+
 // AuthForSpec returns the effective AuthConfig for a repo spec.
 // A per-spec auth override takes precedence over the global auth.
 func (c *Config) AuthForSpec(spec RepoSpec) AuthConfig {
@@ -3,10 +3,14 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/home/user/.config")
 	tmpDir := t.TempDir()
 	path := filepath.Join(tmpDir, "config.yaml")
 	content := `
@@ -14,6 +18,7 @@ repository:
   url: "git@github.com:test/repo.git"
   ref: "refs/heads/main"
   subdir: "quadlets"
+  submodules: true
 
 paths:
   quadlet_dir: "/home/user/.config/containers/systemd"
@@ -22,6 +27,12 @@ paths:
 sync:
   prune: true
   restart: "changed"
+  preflight_write_probe: true
+  always_reload: true
+  run_auto_update: true
+
+timeouts:
+  git: 30s
 
 auth:
   ssh_key_file: "/home/user/.ssh/key"
@@ -44,9 +55,27 @@ serve:
 	if cfg.Repository.URL != "git@github.com:test/repo.git" {
 		t.Errorf("expected URL git@github.com:test/repo.git, got %s", cfg.Repository.URL)
 	}
+	if !cfg.Repository.Submodules {
+		t.Error("expected submodules to be true")
+	}
 	if cfg.Sync.Restart != RestartChanged {
 		t.Errorf("expected restart policy changed, got %s", cfg.Sync.Restart)
 	}
+	if !cfg.Sync.PreflightWriteProbe {
+		t.Error("expected preflight_write_probe to be true")
+	}
+	if !cfg.Sync.AlwaysReload {
+		t.Error("expected always_reload to be true")
+	}
+	if !cfg.Sync.RunAutoUpdate {
+		t.Error("expected run_auto_update to be true")
+	}
+	if cfg.Timeouts.Git != 30*time.Second {
+		t.Errorf("expected git timeout 30s, got %s", cfg.Timeouts.Git)
+	}
+	if cfg.Timeouts.Systemctl != DefaultSystemctlTimeout {
+		t.Errorf("expected default systemctl timeout, got %s", cfg.Timeouts.Systemctl)
+	}
 }
 
 func TestValidate(t *testing.T) {
@@ -206,172 +235,1004 @@ func TestValidate(t *testing.T) {
 					Restart: RestartChanged,
 				},
 			},
-			wantErr: true,
+			wantErr: true,
+		},
+		{
+			name: "missing repo ref",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL: "git@github.com:test/repo.git",
+				},
+				Paths: PathsConfig{
+					QuadletDir: "/absolute/path",
+					StateDir:   "/absolute/state",
+				},
+				Auth: AuthConfig{
+					SSHKeyFile: "/key",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing state_dir",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL: "git@github.com:test/repo.git",
+					Ref: "main",
+				},
+				Paths: PathsConfig{
+					QuadletDir: "/absolute/path",
+				},
+				Auth: AuthConfig{
+					SSHKeyFile: "/key",
+				},
+				Sync: SyncConfig{
+					Restart: RestartChanged,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative state_dir",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL: "git@github.com:test/repo.git",
+					Ref: "main",
+				},
+				Paths: PathsConfig{
+					QuadletDir: "/absolute/path",
+					StateDir:   "relative/state",
+				},
+				Auth: AuthConfig{
+					SSHKeyFile: "/key",
+				},
+				Sync: SyncConfig{
+					Restart: RestartChanged,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid restart policy",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL: "git@github.com:test/repo.git",
+					Ref: "main",
+				},
+				Paths: PathsConfig{
+					QuadletDir: "/absolute/path",
+					StateDir:   "/absolute/state",
+				},
+				Auth: AuthConfig{
+					SSHKeyFile: "/key",
+				},
+				Sync: SyncConfig{
+					Restart: "bogus",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "serve enabled missing listen_addr",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL: "git@github.com:test/repo.git",
+					Ref: "main",
+				},
+				Paths: PathsConfig{
+					QuadletDir: "/absolute/path",
+					StateDir:   "/absolute/state",
+				},
+				Auth: AuthConfig{
+					SSHKeyFile: "/key",
+				},
+				Sync: SyncConfig{
+					Restart: RestartChanged,
+				},
+				Serve: ServeConfig{
+					Enabled:                 true,
+					GitHubWebhookSecretFile: "/secret",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "serve enabled missing webhook secret file",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL: "git@github.com:test/repo.git",
+					Ref: "main",
+				},
+				Paths: PathsConfig{
+					QuadletDir: "/absolute/path",
+					StateDir:   "/absolute/state",
+				},
+				Auth: AuthConfig{
+					SSHKeyFile: "/key",
+				},
+				Sync: SyncConfig{
+					Restart: RestartChanged,
+				},
+				Serve: ServeConfig{
+					Enabled:    true,
+					ListenAddr: "127.0.0.1:8080",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "repository.subdir traversal rejected",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL:    "git@github.com:test/repo.git",
+					Ref:    "main",
+					Subdir: "../etc",
+				},
+				Paths: PathsConfig{QuadletDir: "/absolute/path", StateDir: "/absolute/state"},
+				Auth:  AuthConfig{SSHKeyFile: "/key"},
+				Sync:  SyncConfig{Restart: RestartChanged},
+			},
+			wantErr: true,
+		},
+		{
+			name: "repository.subdir absolute path rejected",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL:    "git@github.com:test/repo.git",
+					Ref:    "main",
+					Subdir: "/absolute/subdir",
+				},
+				Paths: PathsConfig{QuadletDir: "/absolute/path", StateDir: "/absolute/state"},
+				Auth:  AuthConfig{SSHKeyFile: "/key"},
+				Sync:  SyncConfig{Restart: RestartChanged},
+			},
+			wantErr: true,
+		},
+		{
+			name: "repository.clone_depth and filter accepted",
+			cfg: Config{
+				Repository: &RepoSpec{
+					URL:        "git@github.com:test/repo.git",
+					Ref:        "main",
+					CloneDepth: 1,
+					Filter:     "blob:none",
+				},
+				Paths: PathsConfig{QuadletDir: "/absolute/path", StateDir: "/absolute/state"},
+				Auth:  AuthConfig{SSHKeyFile: "/key"},
+				Sync:  SyncConfig{Restart: RestartChanged},
+			},
+			wantErr: false,
+		},
+		{
+			name: "repository.clone_depth negative rejected",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:test/repo.git", Ref: "main", CloneDepth: -1},
+				Paths:      PathsConfig{QuadletDir: "/absolute/path", StateDir: "/absolute/state"},
+				Auth:       AuthConfig{SSHKeyFile: "/key"},
+				Sync:       SyncConfig{Restart: RestartChanged},
+			},
+			wantErr: true,
+		},
+		{
+			name: "repository.filter unsupported rejected",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:test/repo.git", Ref: "main", Filter: "tree:0"},
+				Paths:      PathsConfig{QuadletDir: "/absolute/path", StateDir: "/absolute/state"},
+				Auth:       AuthConfig{SSHKeyFile: "/key"},
+				Sync:       SyncConfig{Restart: RestartChanged},
+			},
+			wantErr: true,
+		},
+		{
+			name: "no repository configured",
+			cfg: Config{
+				Paths: PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "repository and repositories mutually exclusive",
+			cfg: Config{
+				Repository:   &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Repositories: []RepoSpec{{URL: "git@github.com:org/r2.git", Ref: "main"}},
+				Paths:        PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "https token command",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://github.com/org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSTokenCommand: "gh auth token"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "https token command with ssh url",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSTokenCommand: "gh auth token"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "https token command and token file",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://github.com/org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSTokenFile: "/token", HTTPSTokenCommand: "gh auth token"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "https username with password file",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://git.example.com/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSUsername: "deploy", HTTPSPasswordFile: "/password"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "https username with token command",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://git.example.com/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSUsername: "deploy", HTTPSTokenCommand: "pass show git"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "https username without password",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://git.example.com/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSUsername: "deploy"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "https username with line break",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://git.example.com/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSUsername: "deploy\npassword=x", HTTPSPasswordFile: "/password"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "https password file and token file",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://git.example.com/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSTokenFile: "/token", HTTPSPasswordFile: "/password"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "https password file with ssh url",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSPasswordFile: "/password"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "https token expiry with password file",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://git.example.com/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSPasswordFile: "/password", HTTPSTokenExpiresAt: "2030-01-31"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "https token expiry as date",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://github.com/org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSTokenFile: "/token", HTTPSTokenExpiresAt: "2030-01-31"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "https token expiry as RFC 3339",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://github.com/org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSTokenFile: "/token", HTTPSTokenExpiresAt: "2030-01-31T12:00:00Z"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "https token expiry malformed",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://github.com/org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSTokenFile: "/token", HTTPSTokenExpiresAt: "next tuesday"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "https token expiry without token file",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "https://github.com/org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{HTTPSTokenExpiresAt: "2030-01-31"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative backup retention",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{BackupRetention: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max parallel",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{MaxParallel: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "prune scope all",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{Prune: true, PruneScope: PruneAll},
+			},
+			wantErr: false,
+		},
+		{
+			name: "prune scope all without prune",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{PruneScope: PruneAll},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid prune scope",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{Prune: true, PruneScope: "everything"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validation scope all",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{ValidationScope: ValidateAll},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid validation scope",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{ValidationScope: "some"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "podman compat fail",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{PodmanCompat: PodmanCompatFail},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid podman compat",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{PodmanCompat: "error"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid selinux restorecon",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{SELinuxRestorecon: "enforcing"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unit extensions",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s", UnitDir: "/u"},
+				Sync:       SyncConfig{UnitExtensions: []string{".service", ".timer"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid unit extension",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s", UnitDir: "/u"},
+				Sync:       SyncConfig{UnitExtensions: []string{"timer"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative unit dir",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s", UnitDir: "units"},
+				Sync:       SyncConfig{UnitExtensions: []string{".timer"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "routes",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s", Routes: []RouteConfig{{Pattern: "nginx/*.conf", Dest: "/etc/nginx/conf.d"}}},
+			},
+		},
+		{
+			name: "route with parent pattern",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s", Routes: []RouteConfig{{Pattern: "../*.conf", Dest: "/etc/nginx"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "route with relative dest",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s", Routes: []RouteConfig{{Pattern: "*.conf", Dest: "nginx"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ensure wantedby",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{EnsureWantedBy: "default.target"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid ensure wantedby",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{EnsureWantedBy: "default target"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative sync timeout",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{Timeout: -time.Minute},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative serve sync timeout",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{SyncTimeout: -time.Minute},
+			},
+			wantErr: true,
+		},
+		{
+			name: "debounce max wait below debounce",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{Debounce: 10 * time.Second, DebounceMaxWait: 5 * time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative shutdown grace",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{ShutdownGrace: -time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative debounce",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{Debounce: -time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "git retry",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				GitRetry:   GitRetryConfig{Attempts: 5, BaseDelay: time.Second, MaxDelay: time.Minute},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative git retry attempts",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				GitRetry:   GitRetryConfig{Attempts: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "git retry max delay below base delay",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				GitRetry:   GitRetryConfig{BaseDelay: time.Minute, MaxDelay: time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "git maintenance",
+			cfg: Config{
+				Repository:     &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:          PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				GitMaintenance: GitMaintenanceConfig{Interval: 24 * time.Hour, MaxSizeMB: 500, KeepCorrupt: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative git maintenance interval",
+			cfg: Config{
+				Repository:     &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:          PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				GitMaintenance: GitMaintenanceConfig{Interval: -time.Hour},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative git maintenance size limit",
+			cfg: Config{
+				Repository:     &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:          PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				GitMaintenance: GitMaintenanceConfig{MaxSizeMB: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative health check window",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{HealthCheck: HealthCheckConfig{Window: -time.Second}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health check fail without window",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{HealthCheck: HealthCheckConfig{Fail: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "restart batches",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{RestartBatchSize: 2, RestartBatchDelay: time.Minute, RestartBatchHealthCheck: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative restart batch size",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{RestartBatchSize: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "restart batch health check without delay",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{RestartBatchSize: 2, RestartBatchHealthCheck: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "image watch auto-update",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				ImageWatch: ImageWatchConfig{Enabled: true, Interval: 6 * time.Hour, Action: ImageWatchAutoUpdate},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid image watch action",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				ImageWatch: ImageWatchConfig{Action: "pull"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "image watch interval too short",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				ImageWatch: ImageWatchConfig{Enabled: true, Interval: 10 * time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "systemd dbus backend",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Systemd:    SystemdConfig{Backend: SystemdDBus},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid systemd backend",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Systemd:    SystemdConfig{Backend: "varlink"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "substitution vars",
+			cfg: Config{
+				Repository:   &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:        PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Substitution: SubstitutionConfig{Enabled: true, Vars: map[string]string{"IMAGE_TAG": "1.2", "_port": "80"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "substitution var with invalid name",
+			cfg: Config{
+				Repository:   &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:        PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Substitution: SubstitutionConfig{Vars: map[string]string{"1TAG": "x"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "substitution var with reserved prefix",
+			cfg: Config{
+				Repository:   &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:        PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Substitution: SubstitutionConfig{Vars: map[string]string{"QS_HOSTNAME": "x"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "strict host key checking yes",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{SSHKnownHostsFile: "/etc/quadsyncd/known_hosts", SSHStrictHostKeyChecking: SSHStrictHostKeyYes},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid strict host key checking",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{SSHStrictHostKeyChecking: "no"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative known hosts file",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Auth:       AuthConfig{SSHKnownHostsFile: "known_hosts"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative git timeout",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Timeouts:   TimeoutsConfig{Git: -time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative podman timeout",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Timeouts:   TimeoutsConfig{Podman: -time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid serve allowed cidr",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{AllowedCIDRs: []string{"github", "10.0.0.0/33"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid serve trusted proxy",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{TrustedProxies: []string{"localhost"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative rate limit",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{RateLimit: RateLimitConfig{RequestsPerMinute: -1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative rate limit burst",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{RateLimit: RateLimitConfig{RequestsPerMinute: 10, Burst: -1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max in flight",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{MaxInFlight: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max payload age",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{MaxPayloadAge: -time.Minute},
+			},
+			wantErr: true,
+		},
+		{
+			name: "audit journal without audit log",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Audit:      AuditConfig{Journal: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "audit log with journal",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Audit:      AuditConfig{Enabled: true, Journal: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative api token file",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{APITokenFile: "api-token"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "watch source of a git checkout",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{WatchSource: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "watch source without a watchable repository",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "registry.example.com/org/q", Ref: "v1", Source: SourceConfig{Type: SourceOCI}},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{WatchSource: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid serve allowlist",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Serve:      ServeConfig{AllowedCIDRs: []string{"github", "192.0.2.10"}, TrustedProxies: []string{"127.0.0.1", "::1"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative allowed dest root",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{AllowedDestRoots: []string{"etc/caddy"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "filesystem root as allowed dest root",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{AllowedDestRoots: []string{"/"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "absolute values files",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Values:     ValuesConfig{Files: []string{"/v/common.yaml", "/v/prod.yaml"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative values file",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Values:     ValuesConfig{Files: []string{"common.yaml"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty values file entry",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Values:     ValuesConfig{Files: []string{""}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "secrets with absolute age identity",
+			cfg: Config{
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Secrets:    SecretsConfig{Enabled: true, AgeIdentityFile: "/keys/age.txt"},
+			},
+			wantErr: false,
 		},
 		{
-			name: "missing repo ref",
+			name: "file and dir mode",
 			cfg: Config{
-				Repository: &RepoSpec{
-					URL: "git@github.com:test/repo.git",
-				},
-				Paths: PathsConfig{
-					QuadletDir: "/absolute/path",
-					StateDir:   "/absolute/state",
-				},
-				Auth: AuthConfig{
-					SSHKeyFile: "/key",
-				},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{FileMode: 0640, DirMode: 0750, Owner: "quadsyncd:users"},
 			},
-			wantErr: true,
+			wantErr: false,
 		},
 		{
-			name: "missing state_dir",
+			name: "file mode with special bits",
 			cfg: Config{
-				Repository: &RepoSpec{
-					URL: "git@github.com:test/repo.git",
-					Ref: "main",
-				},
-				Paths: PathsConfig{
-					QuadletDir: "/absolute/path",
-				},
-				Auth: AuthConfig{
-					SSHKeyFile: "/key",
-				},
-				Sync: SyncConfig{
-					Restart: RestartChanged,
-				},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{FileMode: 04755},
 			},
 			wantErr: true,
 		},
 		{
-			name: "relative state_dir",
+			name: "file mode without owner write",
 			cfg: Config{
-				Repository: &RepoSpec{
-					URL: "git@github.com:test/repo.git",
-					Ref: "main",
-				},
-				Paths: PathsConfig{
-					QuadletDir: "/absolute/path",
-					StateDir:   "relative/state",
-				},
-				Auth: AuthConfig{
-					SSHKeyFile: "/key",
-				},
-				Sync: SyncConfig{
-					Restart: RestartChanged,
-				},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{FileMode: 0444},
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid restart policy",
+			name: "dir mode without owner execute",
 			cfg: Config{
-				Repository: &RepoSpec{
-					URL: "git@github.com:test/repo.git",
-					Ref: "main",
-				},
-				Paths: PathsConfig{
-					QuadletDir: "/absolute/path",
-					StateDir:   "/absolute/state",
-				},
-				Auth: AuthConfig{
-					SSHKeyFile: "/key",
-				},
-				Sync: SyncConfig{
-					Restart: "bogus",
-				},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{DirMode: 0644},
 			},
 			wantErr: true,
 		},
 		{
-			name: "serve enabled missing listen_addr",
+			name: "strict rejects group-writable file mode",
 			cfg: Config{
-				Repository: &RepoSpec{
-					URL: "git@github.com:test/repo.git",
-					Ref: "main",
-				},
-				Paths: PathsConfig{
-					QuadletDir: "/absolute/path",
-					StateDir:   "/absolute/state",
-				},
-				Auth: AuthConfig{
-					SSHKeyFile: "/key",
-				},
-				Sync: SyncConfig{
-					Restart: RestartChanged,
-				},
-				Serve: ServeConfig{
-					Enabled:                 true,
-					GitHubWebhookSecretFile: "/secret",
-				},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{FileMode: 0664, StrictPermissions: true},
 			},
 			wantErr: true,
 		},
 		{
-			name: "serve enabled missing webhook secret file",
+			name: "strict rejects world-writable dir mode",
 			cfg: Config{
-				Repository: &RepoSpec{
-					URL: "git@github.com:test/repo.git",
-					Ref: "main",
-				},
-				Paths: PathsConfig{
-					QuadletDir: "/absolute/path",
-					StateDir:   "/absolute/state",
-				},
-				Auth: AuthConfig{
-					SSHKeyFile: "/key",
-				},
-				Sync: SyncConfig{
-					Restart: RestartChanged,
-				},
-				Serve: ServeConfig{
-					Enabled:    true,
-					ListenAddr: "127.0.0.1:8080",
-				},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{DirMode: 0777, StrictPermissions: true},
 			},
 			wantErr: true,
 		},
 		{
-			name: "repository.subdir traversal rejected",
+			name: "invalid owner",
 			cfg: Config{
-				Repository: &RepoSpec{
-					URL:    "git@github.com:test/repo.git",
-					Ref:    "main",
-					Subdir: "../etc",
-				},
-				Paths: PathsConfig{QuadletDir: "/absolute/path", StateDir: "/absolute/state"},
-				Auth:  AuthConfig{SSHKeyFile: "/key"},
-				Sync:  SyncConfig{Restart: RestartChanged},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{Owner: "a:b:c"},
 			},
 			wantErr: true,
 		},
 		{
-			name: "repository.subdir absolute path rejected",
+			name: "owner with empty group",
 			cfg: Config{
-				Repository: &RepoSpec{
-					URL:    "git@github.com:test/repo.git",
-					Ref:    "main",
-					Subdir: "/absolute/subdir",
-				},
-				Paths: PathsConfig{QuadletDir: "/absolute/path", StateDir: "/absolute/state"},
-				Auth:  AuthConfig{SSHKeyFile: "/key"},
-				Sync:  SyncConfig{Restart: RestartChanged},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{Owner: "quadsyncd:"},
 			},
 			wantErr: true,
 		},
 		{
-			name: "no repository configured",
+			name: "relative sync age identity file",
 			cfg: Config{
-				Paths: PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Sync:       SyncConfig{AgeIdentityFile: "keys/age.txt"},
 			},
 			wantErr: true,
 		},
 		{
-			name: "repository and repositories mutually exclusive",
+			name: "relative age identity file",
 			cfg: Config{
-				Repository:   &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
-				Repositories: []RepoSpec{{URL: "git@github.com:org/r2.git", Ref: "main"}},
-				Paths:        PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+				Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+				Secrets:    SecretsConfig{Enabled: true, AgeIdentityFile: "age.txt"},
 			},
 			wantErr: true,
 		},
@@ -399,6 +1260,99 @@ func TestConfigHelpers(t *testing.T) {
 	}
 }
 
+func TestSyncParallelism(t *testing.T) {
+	tests := []struct {
+		maxParallel int
+		want        int
+	}{
+		{0, 1},
+		{1, 1},
+		{4, 4},
+	}
+	for _, tt := range tests {
+		cfg := Config{Sync: SyncConfig{MaxParallel: tt.maxParallel}}
+		if got := cfg.SyncParallelism(); got != tt.want {
+			t.Errorf("SyncParallelism() with max_parallel=%d = %d, want %d", tt.maxParallel, got, tt.want)
+		}
+	}
+}
+
+func TestParseCIDRList(t *testing.T) {
+	prefixes, err := ParseCIDRList([]string{"github", "192.0.2.10", " 10.1.2.3/8 ", "::1"})
+	if err != nil {
+		t.Fatalf("ParseCIDRList: %v", err)
+	}
+	if want := len(GitHubHookCIDRs) + 3; len(prefixes) != want {
+		t.Fatalf("got %d prefixes, want %d", len(prefixes), want)
+	}
+	tail := prefixes[len(GitHubHookCIDRs):]
+	for i, want := range []string{"192.0.2.10/32", "10.0.0.0/8", "::1/128"} {
+		if tail[i].String() != want {
+			t.Errorf("prefix %d = %s, want %s", i, tail[i], want)
+		}
+	}
+
+	if _, err := ParseCIDRList([]string{"not-an-ip"}); err == nil {
+		t.Error("expected error for invalid entry")
+	}
+}
+
+func TestDestAllowed(t *testing.T) {
+	cfg := Config{Sync: SyncConfig{AllowedDestRoots: []string{"/home/u/.config/caddy", "/srv/app/"}}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/home/u/.config/caddy/Caddyfile", true},
+		{"/home/u/.config/caddy/sites/a.conf", true},
+		{"/srv/app/config.toml", true},
+		{"/home/u/.config/caddy", false},
+		{"/home/u/.config/caddy-evil/x", false},
+		{"/home/u/.config/caddy/../systemd/x", false},
+		{"/etc/passwd", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.DestAllowed(tt.path); got != tt.want {
+			t.Errorf("DestAllowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if (&Config{}).DestAllowed("/srv/app/x") {
+		t.Error("no roots configured should allow nothing")
+	}
+}
+
+func TestAuthConfig_TokenExpiry(t *testing.T) {
+	if _, ok := (AuthConfig{}).TokenExpiry(); ok {
+		t.Error("expected no expiry when unset")
+	}
+	got, ok := AuthConfig{HTTPSTokenExpiresAt: "2030-01-31"}.TokenExpiry()
+	if !ok {
+		t.Fatal("expected expiry to be set")
+	}
+	if want := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("TokenExpiry() = %v, want %v", got, want)
+	}
+	if _, ok := (AuthConfig{HTTPSTokenExpiresAt: "garbage"}).TokenExpiry(); ok {
+		t.Error("expected invalid expiry to be reported as unset")
+	}
+}
+
+func TestAuthConfig_TokenFile(t *testing.T) {
+	tests := []struct {
+		auth AuthConfig
+		want string
+	}{
+		{AuthConfig{}, ""},
+		{AuthConfig{HTTPSTokenFile: "/token"}, "/token"},
+		{AuthConfig{HTTPSPasswordFile: "/password"}, "/password"},
+	}
+	for _, tt := range tests {
+		if got := tt.auth.TokenFile(); got != tt.want {
+			t.Errorf("%+v.TokenFile() = %q, want %q", tt.auth, got, tt.want)
+		}
+	}
+}
+
 func TestApplyDefaults(t *testing.T) {
 	cfg := Config{}
 	cfg.applyDefaults()
@@ -406,6 +1360,38 @@ func TestApplyDefaults(t *testing.T) {
 	if cfg.Sync.Restart != RestartChanged {
 		t.Errorf("applyDefaults() did not set restart policy, got %q, want %q", cfg.Sync.Restart, RestartChanged)
 	}
+	want := TimeoutsConfig{Git: DefaultGitTimeout, Systemctl: DefaultSystemctlTimeout, Podman: DefaultPodmanTimeout}
+	if cfg.Timeouts != want {
+		t.Errorf("applyDefaults() timeouts = %+v, want %+v", cfg.Timeouts, want)
+	}
+	wantWatch := ImageWatchConfig{Interval: DefaultImageWatchInterval, Action: ImageWatchRestart}
+	if cfg.ImageWatch != wantWatch {
+		t.Errorf("applyDefaults() image_watch = %+v, want %+v", cfg.ImageWatch, wantWatch)
+	}
+	if cfg.Systemd.Backend != SystemdShell {
+		t.Errorf("applyDefaults() systemd.backend = %q, want %q", cfg.Systemd.Backend, SystemdShell)
+	}
+	if cfg.Serve.SyncTimeout != 0 {
+		t.Errorf("applyDefaults() serve.sync_timeout = %s, want 0 without sync.timeout", cfg.Serve.SyncTimeout)
+	}
+	if cfg.Serve.ShutdownGrace != DefaultShutdownGrace {
+		t.Errorf("applyDefaults() shutdown grace = %s, want %s", cfg.Serve.ShutdownGrace, DefaultShutdownGrace)
+	}
+	if cfg.Serve.ReplayHistory != DefaultReplayHistory {
+		t.Errorf("applyDefaults() replay history = %d, want %d", cfg.Serve.ReplayHistory, DefaultReplayHistory)
+	}
+	if cfg.Serve.Debounce != DefaultDebounce || cfg.Serve.DebounceMaxWait != DefaultDebounceMaxWait {
+		t.Errorf("applyDefaults() debounce = %s, max wait = %s", cfg.Serve.Debounce, cfg.Serve.DebounceMaxWait)
+	}
+	inherited := Config{Sync: SyncConfig{Timeout: 15 * time.Minute}}
+	inherited.applyDefaults()
+	if inherited.Serve.SyncTimeout != 15*time.Minute {
+		t.Errorf("applyDefaults() serve.sync_timeout = %s, want sync.timeout", inherited.Serve.SyncTimeout)
+	}
+	wantRetry := GitRetryConfig{Attempts: DefaultGitRetryAttempts, BaseDelay: DefaultGitRetryBaseDelay, MaxDelay: DefaultGitRetryMaxDelay}
+	if cfg.GitRetry != wantRetry {
+		t.Errorf("applyDefaults() git_retry = %+v, want %+v", cfg.GitRetry, wantRetry)
+	}
 
 	// Explicit value must not be overwritten
 	cfg2 := Config{Sync: SyncConfig{Restart: RestartNone}}
@@ -514,6 +1500,7 @@ repository:
 paths:
   quadlet_dir: "/absolute/quadlets"
   state_dir: "/absolute/state"
+  extra_quadlet_roots: ["/absolute"]
 
 auth:
   ssh_key_file: "/key"
@@ -540,6 +1527,49 @@ auth:
 	}
 }
 
+func TestLoad_ValuesFilesRelativeToConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.yaml")
+	t.Setenv("QUADSYNCD_TEST_ENV", "prod")
+	content := `
+repository:
+  url: "git@github.com:org/repo.git"
+  ref: "refs/heads/main"
+
+paths:
+  quadlet_dir: "/absolute/quadlets"
+  state_dir: "/absolute/state"
+  extra_quadlet_roots: ["/absolute"]
+
+values:
+  files:
+    - "values/common.yaml"
+    - "values/env/${QUADSYNCD_TEST_ENV}.yaml"
+    - "/etc/quadsyncd/host.yaml"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []string{
+		filepath.Join(tmpDir, "values", "common.yaml"),
+		filepath.Join(tmpDir, "values", "env", "prod.yaml"),
+		"/etc/quadsyncd/host.yaml",
+	}
+	if len(cfg.Values.Files) != len(want) {
+		t.Fatalf("Values.Files = %v, want %v", cfg.Values.Files, want)
+	}
+	for i := range want {
+		if cfg.Values.Files[i] != want[i] {
+			t.Errorf("Values.Files[%d] = %q, want %q", i, cfg.Values.Files[i], want[i])
+		}
+	}
+}
+
 func TestLoad_MultiRepo(t *testing.T) {
 	tmpDir := t.TempDir()
 	path := filepath.Join(tmpDir, "multi.yaml")
@@ -558,6 +1588,7 @@ repositories:
 paths:
   quadlet_dir: "/absolute/quadlets"
   state_dir: "/absolute/state"
+  extra_quadlet_roots: ["/absolute"]
 
 sync:
   restart: "changed"
@@ -586,6 +1617,15 @@ auth:
 	if cfg.Sync.ConflictHandling != ConflictPreferHighestPriority {
 		t.Errorf("conflict_handling = %q, want prefer_highest_priority", cfg.Sync.ConflictHandling)
 	}
+	if cfg.Sync.ValidationScope != ValidateChanged {
+		t.Errorf("validation_scope = %q, want changed", cfg.Sync.ValidationScope)
+	}
+	if cfg.Sync.PodmanCompat != PodmanCompatWarn {
+		t.Errorf("podman_compat = %q, want warn", cfg.Sync.PodmanCompat)
+	}
+	if cfg.Sync.SELinuxRestorecon != SELinuxAuto {
+		t.Errorf("selinux_restorecon = %q, want auto", cfg.Sync.SELinuxRestorecon)
+	}
 }
 
 func TestValidate_MultiRepo(t *testing.T) {
@@ -704,6 +1744,97 @@ func TestValidate_MultiRepo(t *testing.T) {
 	}
 }
 
+func TestValidate_WebhookListeners(t *testing.T) {
+	base := func(secret string, listeners ...WebhookListener) Config {
+		return Config{
+			Repository: &RepoSpec{URL: "git@github.com:org/r.git", Ref: "main"},
+			Paths:      PathsConfig{QuadletDir: "/q", StateDir: "/s"},
+			Sync:       SyncConfig{Restart: RestartChanged},
+			Serve: ServeConfig{
+				Enabled:                 true,
+				ListenAddr:              "127.0.0.1:8080",
+				GitHubWebhookSecretFile: secret,
+				Listeners:               listeners,
+			},
+		}
+	}
+	gitlab := WebhookListener{Path: "/hooks/gitlab", Provider: "gitlab", SecretFile: "/gitlab-secret"}
+	with := func(mod func(*WebhookListener)) WebhookListener {
+		l := gitlab
+		mod(&l)
+		return l
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "listeners without github secret", cfg: base("", gitlab)},
+		{name: "github secret and listeners", cfg: base("/secret", gitlab)},
+		{name: "relative path", cfg: base("", with(func(l *WebhookListener) { l.Path = "hooks/gitlab" })), wantErr: "clean absolute URL path"},
+		{name: "unclean path", cfg: base("", with(func(l *WebhookListener) { l.Path = "/hooks/gitlab/" })), wantErr: "clean absolute URL path"},
+		{name: "root path", cfg: base("", with(func(l *WebhookListener) { l.Path = "/" })), wantErr: "reserved"},
+		{name: "api path", cfg: base("", with(func(l *WebhookListener) { l.Path = "/api/hook" })), wantErr: "reserved"},
+		{name: "ui path", cfg: base("", with(func(l *WebhookListener) { l.Path = "/ui" })), wantErr: "reserved"},
+		{name: "duplicate path", cfg: base("", gitlab, gitlab), wantErr: "used by another listener"},
+		{name: "default path taken by github", cfg: base("/secret", with(func(l *WebhookListener) { l.Path = DefaultWebhookPath })), wantErr: "used by another listener"},
+		{name: "default path without github", cfg: base("", with(func(l *WebhookListener) { l.Path = DefaultWebhookPath }))},
+		{name: "missing provider", cfg: base("", with(func(l *WebhookListener) { l.Provider = "" })), wantErr: "provider is required"},
+		{name: "missing secret", cfg: base("", with(func(l *WebhookListener) { l.SecretFile = "" })), wantErr: "secret_file or"},
+		{name: "no webhook at all", cfg: base(""), wantErr: "serve.listeners is required"},
+		{name: "generic", cfg: base("", with(func(l *WebhookListener) {
+			l.Provider = "generic"
+			l.Generic = GenericWebhook{SignatureHeader: "X-Signature", Ref: "$.ref", Repository: "$.repo"}
+		}))},
+		{name: "generic without mapping", cfg: base("", with(func(l *WebhookListener) {
+			l.Provider = "generic"
+			l.Generic = GenericWebhook{SignatureHeader: "X-Signature", Ref: "$.ref"}
+		})), wantErr: "required for the generic provider"},
+		{name: "mapping for another provider", cfg: base("", with(func(l *WebhookListener) {
+			l.Generic.Ref = "$.ref"
+		})), wantErr: "only valid for the generic provider"},
+		{name: "own listen address", cfg: base("", with(func(l *WebhookListener) { l.ListenAddr = "0.0.0.0:9443" }))},
+		{name: "listen address without port", cfg: base("", with(func(l *WebhookListener) { l.ListenAddr = "0.0.0.0" })), wantErr: "listen_addr must be host:port"},
+		{name: "bound to repository", cfg: base("", with(func(l *WebhookListener) { l.Repository = "git@github.com:org/r.git" }))},
+		{name: "bound to unknown repository", cfg: base("", with(func(l *WebhookListener) { l.Repository = "git@github.com:org/other.git" })), wantErr: "not the url of a configured git repository"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookListeners(t *testing.T) {
+	gitlab := WebhookListener{Path: "/hooks/gitlab", Provider: "gitlab", SecretFile: "/gitlab-secret"}
+	cfg := Config{Serve: ServeConfig{GitHubWebhookSecretFile: "/secret", Listeners: []WebhookListener{gitlab}}}
+
+	got := cfg.WebhookListeners()
+	want := []WebhookListener{
+		{Path: DefaultWebhookPath, Provider: "github", SecretFile: "/secret"},
+		gitlab,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WebhookListeners() = %+v, want %+v", got, want)
+	}
+
+	cfg.Serve.GitHubWebhookSecretFile = ""
+	if got := cfg.WebhookListeners(); !reflect.DeepEqual(got, []WebhookListener{gitlab}) {
+		t.Errorf("WebhookListeners() without github secret = %+v, want only serve.listeners", got)
+	}
+}
+
 func TestEffectiveRepositories_SingleRepo(t *testing.T) {
 	spec := RepoSpec{URL: "git@github.com:org/r.git", Ref: "main", Subdir: "q"}
 	cfg := Config{Repository: &spec}
@@ -757,6 +1888,80 @@ func TestRepoDirForSpec(t *testing.T) {
 	}
 }
 
+func TestSparseDirsForSpec(t *testing.T) {
+	const shared = "git@github.com:org/mono.git"
+	tests := []struct {
+		name  string
+		repos []RepoSpec
+		want  []string
+	}{
+		{
+			name:  "no subdir",
+			repos: []RepoSpec{{URL: shared}},
+			want:  nil,
+		},
+		{
+			name:  "single subdir is cleaned",
+			repos: []RepoSpec{{URL: shared, Subdir: "./deploy/quadlets/"}},
+			want:  []string{"deploy/quadlets"},
+		},
+		{
+			name: "union of subdirs sharing a checkout",
+			repos: []RepoSpec{
+				{URL: shared, Subdir: "web"},
+				{URL: "git@github.com:org/other.git", Subdir: "other"},
+				{URL: shared, Subdir: "db"},
+				{URL: shared, Subdir: "web"},
+			},
+			want: []string{"db", "web"},
+		},
+		{
+			name:  "layers are included",
+			repos: []RepoSpec{{URL: shared, Layers: []string{"base", "hosts/web1/"}}},
+			want:  []string{"base", "hosts/web1"},
+		},
+		{
+			name: "root subdir disables sparse checkout",
+			repos: []RepoSpec{
+				{URL: shared, Subdir: "web"},
+				{URL: shared},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Repositories: tt.repos}
+			got := cfg.SparseDirsForSpec(tt.repos[0])
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SparseDirsForSpec() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		Auth: AuthConfig{HTTPSTokenCommand: "vault read -field=token secret/git"},
+		Repositories: []RepoSpec{
+			{URL: "https://example.com/a.git", Auth: &AuthConfig{HTTPSTokenCommand: "echo s3cret"}},
+			{URL: "git@example.com:b.git", Auth: &AuthConfig{SSHKeyFile: "/keys/b"}},
+		},
+	}
+	got := cfg.Redacted()
+
+	if got.Auth.HTTPSTokenCommand != RedactedValue || got.Repositories[0].Auth.HTTPSTokenCommand != RedactedValue {
+		t.Errorf("token commands should be redacted: %+v", got)
+	}
+	if got.Repositories[1].Auth.SSHKeyFile != "/keys/b" {
+		t.Errorf("key file paths should be kept, got %q", got.Repositories[1].Auth.SSHKeyFile)
+	}
+	// The original is unchanged.
+	if cfg.Repositories[0].Auth.HTTPSTokenCommand != "echo s3cret" || cfg.Auth.HTTPSTokenCommand == RedactedValue {
+		t.Error("Redacted must not modify the original config")
+	}
+}
+
 func TestAuthForSpec(t *testing.T) {
 	globalAuth := AuthConfig{SSHKeyFile: "/global-key"}
 	perRepoAuth := AuthConfig{HTTPSTokenFile: "/repo-token"}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CredentialsDirEnv names the environment variable systemd sets to the
+// directory holding a service's LoadCredential= and SetCredential= files.
+const CredentialsDirEnv = "CREDENTIALS_DIRECTORY"
+
+// resolveCredentials points each *_file field that has a *_credential
+// counterpart set at the credential file in $CREDENTIALS_DIRECTORY, so the
+// rest of quadsyncd only deals with file paths.
+func (c *Config) resolveCredentials() error {
+	if err := resolveAuthCredentials(&c.Auth, "auth"); err != nil {
+		return err
+	}
+	if c.Repository != nil && c.Repository.Auth != nil {
+		if err := resolveAuthCredentials(c.Repository.Auth, "repository.auth"); err != nil {
+			return err
+		}
+	}
+	for i := range c.Repositories {
+		if c.Repositories[i].Auth != nil {
+			if err := resolveAuthCredentials(c.Repositories[i].Auth, fmt.Sprintf("repositories[%d].auth", i)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := resolveCredential(&c.Secrets.AgeIdentityFile, c.Secrets.AgeIdentityCredential,
+		"secrets.age_identity_file", "secrets.age_identity_credential"); err != nil {
+		return err
+	}
+	if err := resolveCredential(&c.Serve.APITokenFile, c.Serve.APITokenCredential,
+		"serve.api_token_file", "serve.api_token_credential"); err != nil {
+		return err
+	}
+	for i := range c.Serve.Listeners {
+		label := fmt.Sprintf("serve.listeners[%d]", i)
+		if err := resolveCredential(&c.Serve.Listeners[i].SecretFile, c.Serve.Listeners[i].SecretCredential,
+			label+".secret_file", label+".secret_credential"); err != nil {
+			return err
+		}
+	}
+	return resolveCredential(&c.Serve.GitHubWebhookSecretFile, c.Serve.GitHubWebhookSecretCredential,
+		"serve.github_webhook_secret_file", "serve.github_webhook_secret_credential")
+}
+
+// resolveAuthCredentials resolves the credential fields of one AuthConfig.
+func resolveAuthCredentials(a *AuthConfig, label string) error {
+	if err := resolveCredential(&a.SSHKeyFile, a.SSHKeyCredential, label+".ssh_key_file", label+".ssh_key_credential"); err != nil {
+		return err
+	}
+	if a.HTTPSTokenCredential != "" && a.HTTPSPasswordFile != "" {
+		return fmt.Errorf("%s.https_token_credential and %s.https_password_file are mutually exclusive", label, label)
+	}
+	return resolveCredential(&a.HTTPSTokenFile, a.HTTPSTokenCredential, label+".https_token_file", label+".https_token_credential")
+}
+
+// resolveCredential sets *file to the path of credential name. It fails when
+// both are configured, when quadsyncd was not started with credentials, or
+// when the named credential was not passed.
+func resolveCredential(file *string, name, fileKey, credKey string) error {
+	if name == "" {
+		return nil
+	}
+	if *file != "" {
+		return fmt.Errorf("%s and %s are mutually exclusive", fileKey, credKey)
+	}
+	if name == "." || name == ".." || strings.ContainsRune(name, '/') {
+		return fmt.Errorf("%s must be a credential name, not a path: %q", credKey, name)
+	}
+	dir := os.Getenv(CredentialsDirEnv)
+	if dir == "" {
+		return fmt.Errorf("%s is set but $%s is not; pass the credential with LoadCredential=%s:<path> in the service unit", credKey, CredentialsDirEnv, name)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s: credential %q not found in $%s: %w", credKey, name, CredentialsDirEnv, err)
+	}
+	*file = path
+	return nil
+}
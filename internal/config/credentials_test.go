@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad_Credentials(t *testing.T) {
+	credDir := t.TempDir()
+	for _, name := range []string{"gitkey", "webhook", "gitlab", "age", "apitoken"} {
+		if err := os.WriteFile(filepath.Join(credDir, name), []byte("secret"), 0400); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv(CredentialsDirEnv, credDir)
+	t.Setenv("XDG_CONFIG_HOME", "/home/user/.config")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+repository:
+  url: "git@github.com:org/repo.git"
+  ref: "main"
+paths:
+  quadlet_dir: "/home/user/.config/containers/systemd"
+  state_dir: "/home/user/.local/state/quadsyncd"
+auth:
+  ssh_key_credential: gitkey
+serve:
+  enabled: true
+  listen_addr: "127.0.0.1:8787"
+  github_webhook_secret_credential: webhook
+  api_token_credential: apitoken
+  listeners:
+    - path: /hooks/gitlab
+      provider: gitlab
+      secret_credential: gitlab
+secrets:
+  enabled: true
+  age_identity_credential: age
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := filepath.Join(credDir, "gitkey"); cfg.Auth.SSHKeyFile != want {
+		t.Errorf("auth.ssh_key_file = %q, want %q", cfg.Auth.SSHKeyFile, want)
+	}
+	if want := filepath.Join(credDir, "webhook"); cfg.Serve.GitHubWebhookSecretFile != want {
+		t.Errorf("serve.github_webhook_secret_file = %q, want %q", cfg.Serve.GitHubWebhookSecretFile, want)
+	}
+	if want := filepath.Join(credDir, "gitlab"); cfg.Serve.Listeners[0].SecretFile != want {
+		t.Errorf("serve.listeners[0].secret_file = %q, want %q", cfg.Serve.Listeners[0].SecretFile, want)
+	}
+	if want := filepath.Join(credDir, "apitoken"); cfg.Serve.APITokenFile != want {
+		t.Errorf("serve.api_token_file = %q, want %q", cfg.Serve.APITokenFile, want)
+	}
+	if want := filepath.Join(credDir, "age"); cfg.Secrets.AgeIdentityFile != want {
+		t.Errorf("secrets.age_identity_file = %q, want %q", cfg.Secrets.AgeIdentityFile, want)
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	credDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(credDir, "token"), []byte("t"), 0400); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		credDir  string
+		cfg      Config
+		wantFile string
+		wantErr  string
+	}{
+		{
+			name:     "per-repo token credential",
+			credDir:  credDir,
+			cfg:      Config{Repositories: []RepoSpec{{Auth: &AuthConfig{HTTPSTokenCredential: "token"}}}},
+			wantFile: filepath.Join(credDir, "token"),
+		},
+		{
+			name:    "credential and file",
+			credDir: credDir,
+			cfg:     Config{Auth: AuthConfig{HTTPSTokenFile: "/token", HTTPSTokenCredential: "token"}},
+			wantErr: "mutually exclusive",
+		},
+		{
+			name:    "credential and password file",
+			credDir: credDir,
+			cfg:     Config{Auth: AuthConfig{HTTPSPasswordFile: "/pw", HTTPSTokenCredential: "token"}},
+			wantErr: "mutually exclusive",
+		},
+		{
+			name:    "no credentials directory",
+			cfg:     Config{Auth: AuthConfig{HTTPSTokenCredential: "token"}},
+			wantErr: "LoadCredential=token",
+		},
+		{
+			name:    "missing credential",
+			credDir: credDir,
+			cfg:     Config{Auth: AuthConfig{SSHKeyCredential: "gitkey"}},
+			wantErr: `credential "gitkey" not found`,
+		},
+		{
+			name:    "path instead of name",
+			credDir: credDir,
+			cfg:     Config{Auth: AuthConfig{SSHKeyCredential: "../token"}},
+			wantErr: "not a path",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(CredentialsDirEnv, tt.credDir)
+			err := tt.cfg.resolveCredentials()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveCredentials() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveCredentials() error = %v", err)
+			}
+			if got := tt.cfg.Repositories[0].Auth.HTTPSTokenFile; got != tt.wantFile {
+				t.Errorf("https_token_file = %q, want %q", got, tt.wantFile)
+			}
+		})
+	}
+}
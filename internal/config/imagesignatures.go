@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+// ImageSignatureMode controls what happens when an image referenced by a
+// changed quadlet has no valid cosign signature.
+type ImageSignatureMode string
+
+const (
+	// ImageSignaturesOff verifies nothing.
+	ImageSignaturesOff ImageSignatureMode = "off"
+	// ImageSignaturesWarn records a warning and installs the quadlet anyway.
+	ImageSignaturesWarn ImageSignatureMode = "warn"
+	// ImageSignaturesEnforce fails the sync before any file is changed.
+	ImageSignaturesEnforce ImageSignatureMode = "enforce"
+)
+
+// ImageSignaturesConfig configures cosign verification of the images
+// referenced by Image= in added or updated quadlets.
+type ImageSignaturesConfig struct {
+	// Mode is ImageSignaturesOff (default), ImageSignaturesWarn or
+	// ImageSignaturesEnforce.
+	Mode ImageSignatureMode `yaml:"mode,omitempty"`
+	// CosignKey is a cosign public key file images must be signed with.
+	CosignKey string `yaml:"cosign_key,omitempty"`
+	// Identities are the keyless (Fulcio certificate) signers accepted
+	// instead of a key; an image signed by any of them passes.
+	Identities []SignerIdentity `yaml:"identities,omitempty"`
+	// Exclude lists path.Match patterns of image references that are not
+	// verified, e.g. "localhost/*".
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// SignerIdentity is a keyless signer: the OIDC issuer of its certificate and
+// the certificate identity, given literally or as a regular expression.
+type SignerIdentity struct {
+	Issuer        string `yaml:"issuer"`
+	Subject       string `yaml:"subject,omitempty"`
+	SubjectRegexp string `yaml:"subject_regexp,omitempty"`
+}
+
+// Enabled reports whether images are verified.
+func (c ImageSignaturesConfig) Enabled() bool {
+	return c.Mode == ImageSignaturesWarn || c.Mode == ImageSignaturesEnforce
+}
+
+// Excluded reports whether image matches one of the exclude patterns.
+func (c ImageSignaturesConfig) Excluded(image string) bool {
+	for _, pattern := range c.Exclude {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateImageSignatures checks c.ImageSignatures.
+func (c *Config) validateImageSignatures() error {
+	s := c.ImageSignatures
+	switch s.Mode {
+	case ImageSignaturesOff, "":
+		return nil
+	case ImageSignaturesWarn, ImageSignaturesEnforce:
+	default:
+		return fmt.Errorf("invalid image_signatures.mode: %s (must be off, warn or enforce)", s.Mode)
+	}
+	if (s.CosignKey == "") == (len(s.Identities) == 0) {
+		return fmt.Errorf("image_signatures requires exactly one of cosign_key or identities")
+	}
+	if s.CosignKey != "" && !filepath.IsAbs(s.CosignKey) {
+		return fmt.Errorf("image_signatures.cosign_key must be an absolute path: %s", s.CosignKey)
+	}
+	for i, id := range s.Identities {
+		if id.Issuer == "" {
+			return fmt.Errorf("image_signatures.identities[%d].issuer is required", i)
+		}
+		if (id.Subject == "") == (id.SubjectRegexp == "") {
+			return fmt.Errorf("image_signatures.identities[%d] requires exactly one of subject or subject_regexp", i)
+		}
+		if id.SubjectRegexp != "" {
+			if _, err := regexp.Compile(id.SubjectRegexp); err != nil {
+				return fmt.Errorf("image_signatures.identities[%d].subject_regexp: %w", i, err)
+			}
+		}
+	}
+	for i, pattern := range s.Exclude {
+		if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+			return fmt.Errorf("image_signatures.exclude[%d] is not a valid pattern: %q", i, pattern)
+		}
+	}
+	return nil
+}
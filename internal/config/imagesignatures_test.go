@@ -0,0 +1,60 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateImageSignatures(t *testing.T) {
+	keyless := []SignerIdentity{{Issuer: "https://token.actions.githubusercontent.com", SubjectRegexp: "^https://github.com/acme/"}}
+	tests := []struct {
+		name    string
+		s       ImageSignaturesConfig
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "off ignores the rest", s: ImageSignaturesConfig{Mode: ImageSignaturesOff, CosignKey: "cosign.pub"}},
+		{name: "keyed", s: ImageSignaturesConfig{Mode: ImageSignaturesEnforce, CosignKey: "/etc/quadsyncd/cosign.pub"}},
+		{name: "keyless", s: ImageSignaturesConfig{Mode: ImageSignaturesWarn, Identities: keyless, Exclude: []string{"localhost/*"}}},
+		{name: "invalid mode", s: ImageSignaturesConfig{Mode: "strict"}, wantErr: "invalid image_signatures.mode"},
+		{name: "no signer", s: ImageSignaturesConfig{Mode: ImageSignaturesEnforce}, wantErr: "exactly one of cosign_key or identities"},
+		{name: "key and identities", s: ImageSignaturesConfig{Mode: ImageSignaturesEnforce, CosignKey: "/k.pub", Identities: keyless}, wantErr: "exactly one of cosign_key or identities"},
+		{name: "relative key", s: ImageSignaturesConfig{Mode: ImageSignaturesEnforce, CosignKey: "cosign.pub"}, wantErr: "absolute path"},
+		{name: "identity without issuer", s: ImageSignaturesConfig{Mode: ImageSignaturesEnforce, Identities: []SignerIdentity{{Subject: "ci@acme.dev"}}}, wantErr: "identities[0].issuer is required"},
+		{name: "identity without subject", s: ImageSignaturesConfig{Mode: ImageSignaturesEnforce, Identities: []SignerIdentity{{Issuer: "https://accounts.google.com"}}}, wantErr: "exactly one of subject or subject_regexp"},
+		{name: "invalid subject regexp", s: ImageSignaturesConfig{Mode: ImageSignaturesEnforce, Identities: []SignerIdentity{{Issuer: "https://accounts.google.com", SubjectRegexp: "("}}}, wantErr: "subject_regexp"},
+		{name: "invalid exclude", s: ImageSignaturesConfig{Mode: ImageSignaturesWarn, CosignKey: "/k.pub", Exclude: []string{"["}}, wantErr: "image_signatures.exclude[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Config{ImageSignatures: tt.s}).validateImageSignatures()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateImageSignatures() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateImageSignatures() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImageSignaturesExcluded(t *testing.T) {
+	s := ImageSignaturesConfig{Exclude: []string{"localhost/*", "docker.io/library/*"}}
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{"localhost/app:dev", true},
+		{"docker.io/library/nginx:1.27", true},
+		{"ghcr.io/acme/app:1.0", false},
+		{"localhost/team/app:dev", false}, // * does not match /
+	}
+	for _, tt := range tests {
+		if got := s.Excluded(tt.image); got != tt.want {
+			t.Errorf("Excluded(%q) = %v, want %v", tt.image, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HostnameToken in a layer path is replaced with the host name, so one
+// configuration can select hosts/$(hostname) on every machine.
+const HostnameToken = "$(hostname)"
+
+// hostname returns the host name layer paths are expanded with; replaced in
+// tests.
+var hostname = os.Hostname
+
+// UnmarshalYAML accepts subdir as a list of layers besides a single path:
+//
+//	subdir: [base, hosts/$(hostname)]
+//
+// is read into Layers.
+func (s *RepoSpec) UnmarshalYAML(node *yaml.Node) error {
+	type plain RepoSpec
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value != "subdir" || value.Kind != yaml.SequenceNode {
+				continue
+			}
+			var layers []string
+			if err := value.Decode(&layers); err != nil {
+				return fmt.Errorf("line %d: subdir must be a path or a list of paths: %w", value.Line, err)
+			}
+			rest := *node
+			rest.Content = append(append([]*yaml.Node{}, node.Content[:i]...), node.Content[i+2:]...)
+			if err := rest.Decode((*plain)(s)); err != nil {
+				return err
+			}
+			if len(s.Layers) > 0 {
+				return fmt.Errorf("line %d: subdir lists layers and layers is set too; use one of them", value.Line)
+			}
+			s.Layers = layers
+			return nil
+		}
+	}
+	return node.Decode((*plain)(s))
+}
+
+// SourceDirs returns the slash-separated, checkout-relative directories spec
+// reads files from: its layers in order, or its subdir. The repository root
+// is ".".
+func (s RepoSpec) SourceDirs() []string {
+	if len(s.Layers) == 0 {
+		return []string{filepath.ToSlash(filepath.Clean(s.Subdir))}
+	}
+	dirs := make([]string, len(s.Layers))
+	for i, layer := range s.Layers {
+		dirs[i] = filepath.ToSlash(filepath.Clean(layer))
+	}
+	return dirs
+}
+
+// expandLayers replaces HostnameToken in the layers of every repository.
+func (c *Config) expandLayers() error {
+	specs := []*RepoSpec{c.Repository}
+	for i := range c.Repositories {
+		specs = append(specs, &c.Repositories[i])
+	}
+	var host string
+	for _, spec := range specs {
+		if spec == nil {
+			continue
+		}
+		for i, layer := range spec.Layers {
+			if !strings.Contains(layer, HostnameToken) {
+				continue
+			}
+			if host == "" {
+				h, err := hostname()
+				if err != nil {
+					return fmt.Errorf("failed to expand %s in layer %s: %w", HostnameToken, layer, err)
+				}
+				host = h
+			}
+			spec.Layers[i] = strings.ReplaceAll(layer, HostnameToken, host)
+		}
+	}
+	return nil
+}
+
+// validateLayers checks the layers of spec like a subdir and rejects
+// duplicates, which would make the override order ambiguous.
+func validateLayers(spec RepoSpec, label string) error {
+	if len(spec.Layers) == 0 {
+		return nil
+	}
+	if spec.Subdir != "" {
+		return fmt.Errorf("%s.subdir and %s.layers are mutually exclusive", label, label)
+	}
+	seen := make(map[string]bool, len(spec.Layers))
+	for i, layer := range spec.Layers {
+		if layer == "" {
+			return fmt.Errorf("%s.subdir[%d] must not be empty", label, i)
+		}
+		if filepath.IsAbs(layer) {
+			return fmt.Errorf("%s.subdir[%d] must be a relative path: %s", label, i, layer)
+		}
+		cleaned := filepath.ToSlash(filepath.Clean(layer))
+		if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return fmt.Errorf("%s.subdir[%d] must not contain path traversal: %s", label, i, layer)
+		}
+		if seen[cleaned] {
+			return fmt.Errorf("%s.subdir[%d] repeats layer %s", label, i, layer)
+		}
+		seen[cleaned] = true
+	}
+	return nil
+}
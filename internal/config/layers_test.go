@@ -0,0 +1,113 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRepoSpec_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name       string
+		yaml       string
+		wantSubdir string
+		wantLayers []string
+		wantErr    string
+	}{
+		{name: "single subdir", yaml: "url: u\nsubdir: quadlets\n", wantSubdir: "quadlets"},
+		{name: "subdir list", yaml: "url: u\nsubdir: [base, hosts/web1]\n", wantLayers: []string{"base", "hosts/web1"}},
+		{name: "layers key", yaml: "url: u\nlayers: [base]\n", wantLayers: []string{"base"}},
+		{name: "both lists", yaml: "url: u\nsubdir: [base]\nlayers: [other]\n", wantErr: "layers is set too"},
+		{name: "list of maps", yaml: "url: u\nsubdir: [{a: b}]\n", wantErr: "list of paths"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var spec RepoSpec
+			err := yaml.Unmarshal([]byte(tt.yaml), &spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Unmarshal() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if spec.URL != "u" || spec.Subdir != tt.wantSubdir || !reflect.DeepEqual(spec.Layers, tt.wantLayers) {
+				t.Errorf("Unmarshal() = %+v, want subdir %q and layers %v", spec, tt.wantSubdir, tt.wantLayers)
+			}
+		})
+	}
+}
+
+func TestLoad_LayersExpandHostname(t *testing.T) {
+	orig := hostname
+	t.Cleanup(func() { hostname = orig })
+	hostname = func() (string, error) { return "web1", nil }
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+repository:
+  url: "git@github.com:org/repo.git"
+  ref: "refs/heads/main"
+  subdir:
+    - base
+    - hosts/$(hostname)
+paths:
+  quadlet_dir: "/absolute/quadlets"
+  state_dir: "/absolute/state"
+  extra_quadlet_roots: ["/absolute"]
+auth:
+  ssh_key_file: "/key"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := []string{"base", "hosts/web1"}; !reflect.DeepEqual(cfg.Repository.Layers, want) {
+		t.Errorf("Layers = %v, want %v", cfg.Repository.Layers, want)
+	}
+
+	hostname = func() (string, error) { return "", errors.New("no hostname") }
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "$(hostname)") {
+		t.Errorf("Load() without a hostname error = %v, want it to name the token", err)
+	}
+}
+
+func TestValidateLayers(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    RepoSpec
+		wantErr string
+	}{
+		{name: "no layers", spec: RepoSpec{Subdir: "quadlets"}},
+		{name: "valid layers", spec: RepoSpec{Layers: []string{"base", "hosts/web1"}}},
+		{name: "subdir and layers", spec: RepoSpec{Subdir: "x", Layers: []string{"base"}}, wantErr: "mutually exclusive"},
+		{name: "empty layer", spec: RepoSpec{Layers: []string{"base", ""}}, wantErr: "subdir[1] must not be empty"},
+		{name: "absolute layer", spec: RepoSpec{Layers: []string{"/base"}}, wantErr: "relative path"},
+		{name: "traversal", spec: RepoSpec{Layers: []string{"base", "../x"}}, wantErr: "path traversal"},
+		{name: "duplicate", spec: RepoSpec{Layers: []string{"base", "./base/"}}, wantErr: "repeats layer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLayers(tt.spec, "repository")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateLayers() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateLayers() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
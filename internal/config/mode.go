@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mode is a permission mode written in octal, e.g. "0644". The zero value
+// means unset.
+type Mode uint32
+
+// ParseMode parses an octal permission mode such as "0644", "644" or "0o644".
+func ParseMode(s string) (Mode, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0o"), "0O")
+	v, err := strconv.ParseUint(digits, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid octal mode %q", s)
+	}
+	return Mode(v), nil
+}
+
+// UnmarshalYAML reads the scalar as octal whether or not it is quoted, so
+// file_mode: 0644 and file_mode: "0644" mean the same.
+func (m *Mode) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: mode must be an octal number such as \"0644\"", node.Line)
+	}
+	v, err := ParseMode(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*m = v
+	return nil
+}
+
+// MarshalYAML writes the mode as a quoted octal string.
+func (m Mode) MarshalYAML() (any, error) {
+	return m.String(), nil
+}
+
+// String returns the mode in four-digit octal notation.
+func (m Mode) String() string {
+	return fmt.Sprintf("%04o", uint32(m))
+}
+
+// Perm returns the mode as os.FileMode permission bits.
+func (m Mode) Perm() os.FileMode {
+	return os.FileMode(m) & os.ModePerm
+}
+
+// validateMode checks a file_mode or dir_mode value: only permission bits,
+// the owner bits quadsyncd itself needs, and no group or other write bits
+// in strict mode.
+func validateMode(m Mode, key string, ownerBits Mode, strict bool) error {
+	if m == 0 {
+		return nil
+	}
+	if m&^0777 != 0 {
+		return fmt.Errorf("%s must only contain permission bits (at most 0777): %s", key, m)
+	}
+	if m&ownerBits != ownerBits {
+		return fmt.Errorf("%s must grant the owner at least %s: %s", key, ownerBits, m)
+	}
+	if strict && m&0022 != 0 {
+		return fmt.Errorf("%s must not be group- or world-writable with sync.strict_permissions: %s", key, m)
+	}
+	return nil
+}
+
+// SplitOwner splits a "user[:group]" owner spec. Either part may be a name
+// or a numeric ID.
+func SplitOwner(owner string) (user, group string, err error) {
+	user, group, hasGroup := strings.Cut(owner, ":")
+	if user == "" || (hasGroup && group == "") || strings.ContainsAny(owner, " \t\n") || strings.Count(owner, ":") > 1 {
+		return "", "", fmt.Errorf("sync.owner must be user or user:group: %q", owner)
+	}
+	return user, group, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMode_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Mode
+		wantErr bool
+	}{
+		{in: `file_mode: 0644`, want: 0644},
+		{in: `file_mode: "0640"`, want: 0640},
+		{in: `file_mode: 600`, want: 0600},
+		{in: `file_mode: 0o750`, want: 0750},
+		{in: `file_mode: "0698"`, wantErr: true},
+		{in: `file_mode: [1]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var got SyncConfig
+			err := yaml.Unmarshal([]byte(tt.in), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.FileMode != tt.want {
+				t.Errorf("file_mode = %s, want %s", got.FileMode, tt.want)
+			}
+		})
+	}
+
+	out, err := yaml.Marshal(SyncConfig{FileMode: 0640})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `file_mode: "0640"`) {
+		t.Errorf("Marshal = %s, want quoted octal mode", out)
+	}
+}
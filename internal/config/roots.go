@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SystemQuadletDir is where quadlet reads system units. It is only an allowed
+// quadlet_dir root when quadsyncd runs as root.
+const SystemQuadletDir = "/etc/containers/systemd"
+
+// QuadletRoots returns the directories paths.quadlet_dir may lie in: the
+// rootless quadlet directory of the current user
+// ($XDG_CONFIG_HOME/containers/systemd), SystemQuadletDir when running as
+// root, and paths.extra_quadlet_roots.
+func (c *Config) QuadletRoots() []string {
+	var roots []string
+	if dir := userConfigDir(); dir != "" {
+		roots = append(roots, filepath.Join(dir, "containers", "systemd"))
+	}
+	if os.Geteuid() == 0 {
+		roots = append(roots, SystemQuadletDir)
+	}
+	return append(roots, c.Paths.ExtraQuadletRoots...)
+}
+
+// CheckQuadletDir verifies that paths.quadlet_dir, with symlinks resolved,
+// lies inside one of QuadletRoots. Prune deletes files below quadlet_dir, so
+// a typo such as quadlet_dir: /home/user must not be accepted.
+func (c *Config) CheckQuadletDir() error {
+	dir, err := ResolvePath(c.Paths.QuadletDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve paths.quadlet_dir: %w", err)
+	}
+	roots := c.QuadletRoots()
+	for _, root := range roots {
+		resolved, err := ResolvePath(root)
+		if err != nil {
+			continue
+		}
+		if PathWithin(dir, resolved) {
+			return nil
+		}
+	}
+	return fmt.Errorf("paths.quadlet_dir %s is not inside an allowed quadlet root (%s); add its parent to paths.extra_quadlet_roots if this is intended",
+		c.Paths.QuadletDir, strings.Join(roots, ", "))
+}
+
+// PruneAllowed reports whether path may be deleted by prune: its directory,
+// with symlinks resolved, must be paths.quadlet_dir or lie below it or one
+// of sync.allowed_dest_roots or a paths.routes destination, or be
+// paths.unit_dir when sync.unit_extensions is set. A symlinked subdirectory
+// pointing elsewhere therefore cannot make prune delete files outside those
+// trees.
+func (c *Config) PruneAllowed(path string) bool {
+	dir, err := ResolvePath(filepath.Dir(filepath.Clean(path)))
+	if err != nil {
+		return false
+	}
+	if quadletDir, err := ResolvePath(c.Paths.QuadletDir); err == nil && PathWithin(dir, quadletDir) {
+		return true
+	}
+	for _, root := range c.Sync.AllowedDestRoots {
+		if resolved, err := ResolvePath(root); err == nil && PathWithin(dir, resolved) {
+			return true
+		}
+	}
+	for _, r := range c.Paths.Routes {
+		if resolved, err := ResolvePath(r.Dest); err == nil && PathWithin(dir, resolved) {
+			return true
+		}
+	}
+	if len(c.Sync.UnitExtensions) > 0 && c.Paths.UnitDir != "" {
+		if unitDir, err := ResolvePath(c.Paths.UnitDir); err == nil && dir == unitDir {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolvePath returns path with symlinks resolved. Components that do not
+// exist yet are appended to the resolved existing prefix unchanged.
+func ResolvePath(path string) (string, error) {
+	path = filepath.Clean(path)
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+}
+
+// PathWithin reports whether path is root or lies below it. Both must be
+// clean absolute paths.
+func PathWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// DefaultQuadletDir returns the rootless quadlet directory of the current
+// user, $XDG_CONFIG_HOME/containers/systemd.
+func DefaultQuadletDir() string {
+	if dir := userConfigDir(); dir != "" {
+		return filepath.Join(dir, "containers", "systemd")
+	}
+	return ""
+}
+
+// DefaultUnitDir returns the directory systemd reads the current user's
+// units from, $XDG_CONFIG_HOME/systemd/user.
+func DefaultUnitDir() string {
+	if dir := userConfigDir(); dir != "" {
+		return filepath.Join(dir, "systemd", "user")
+	}
+	return ""
+}
+
+// DefaultStateDir returns $XDG_STATE_HOME/quadsyncd, falling back to
+// ~/.local/state/quadsyncd.
+func DefaultStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "quadsyncd")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "quadsyncd")
+}
+
+// userConfigDir returns $XDG_CONFIG_HOME, falling back to ~/.config, which is
+// where quadlet looks for rootless units on every platform.
+func userConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config")
+}
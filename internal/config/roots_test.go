@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckQuadletDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	extra := t.TempDir()
+	outside := t.TempDir()
+
+	// A symlink inside the standard root that points elsewhere.
+	standard := filepath.Join(home, ".config", "containers", "systemd")
+	if err := os.MkdirAll(standard, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(standard, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		quadletDir string
+		extraRoots []string
+		wantErr    bool
+	}{
+		{name: "standard root", quadletDir: standard},
+		{name: "below standard root, not yet created", quadletDir: filepath.Join(standard, "apps", "web")},
+		{name: "extra root", quadletDir: filepath.Join(extra, "quadlets"), extraRoots: []string{extra}},
+		{name: "home directory", quadletDir: home, wantErr: true},
+		{name: "sibling with common prefix", quadletDir: standard + "-old", wantErr: true},
+		{name: "symlink out of standard root", quadletDir: filepath.Join(standard, "escape"), wantErr: true},
+		{name: "parent traversal", quadletDir: filepath.Join(standard, "..", ".."), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Paths: PathsConfig{QuadletDir: tt.quadletDir, ExtraQuadletRoots: tt.extraRoots}}
+			err := cfg.CheckQuadletDir()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckQuadletDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "extra_quadlet_roots") {
+				t.Errorf("error %q should point at paths.extra_quadlet_roots", err)
+			}
+		})
+	}
+}
+
+func TestQuadletRoots(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	cfg := &Config{Paths: PathsConfig{ExtraQuadletRoots: []string{"/srv/quadlets"}}}
+	roots := cfg.QuadletRoots()
+	if roots[0] != "/xdg/containers/systemd" || roots[len(roots)-1] != "/srv/quadlets" {
+		t.Errorf("QuadletRoots() = %v", roots)
+	}
+	hasSystem := false
+	for _, r := range roots {
+		hasSystem = hasSystem || r == SystemQuadletDir
+	}
+	if hasSystem != (os.Geteuid() == 0) {
+		t.Errorf("QuadletRoots() = %v, %s expected only as root", roots, SystemQuadletDir)
+	}
+}
+
+func TestPruneAllowed(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlets")
+	destRoot := filepath.Join(tmpDir, "etc-app")
+	outside := filepath.Join(tmpDir, "outside")
+	unitDir := filepath.Join(tmpDir, "units")
+	routeDir := filepath.Join(tmpDir, "nginx")
+	for _, dir := range []string{quadletDir, destRoot, outside, unitDir, routeDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(quadletDir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		Paths: PathsConfig{QuadletDir: quadletDir, UnitDir: unitDir, Routes: []RouteConfig{{Pattern: "*.conf", Dest: routeDir}}},
+		Sync:  SyncConfig{AllowedDestRoots: []string{destRoot}, UnitExtensions: []string{".timer"}},
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(quadletDir, "app.container"), true},
+		{filepath.Join(quadletDir, "sub", "app.container"), true},
+		{filepath.Join(destRoot, "app.conf"), true},
+		{filepath.Join(unitDir, "backup.timer"), true},
+		{filepath.Join(unitDir, "sub", "backup.timer"), false},
+		{filepath.Join(routeDir, "sites", "app.conf"), true},
+		{filepath.Join(quadletDir, "linked", "file"), false},
+		{filepath.Join(outside, "file"), false},
+		{filepath.Join(quadletDir, "..", "outside", "file"), false},
+	}
+	for _, tt := range tests {
+		if got := cfg.PruneAllowed(tt.path); got != tt.want {
+			t.Errorf("PruneAllowed(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_QuadletDirOutsideRoots(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(t.TempDir(), ".config"))
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+repository:
+  url: "https://github.com/org/repo.git"
+  ref: "main"
+paths:
+  quadlet_dir: "/home/user"
+  state_dir: "/var/lib/quadsyncd"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "not inside an allowed quadlet root") {
+		t.Errorf("Load() error = %v, want quadlet root error", err)
+	}
+}
+
+func TestDefaultDirs(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+	if got := DefaultQuadletDir(); got != "/xdg/config/containers/systemd" {
+		t.Errorf("DefaultQuadletDir() = %q", got)
+	}
+	if got := DefaultStateDir(); got != "/xdg/state/quadsyncd" {
+		t.Errorf("DefaultStateDir() = %q", got)
+	}
+	if got := DefaultUnitDir(); got != "/xdg/config/systemd/user" {
+		t.Errorf("DefaultUnitDir() = %q", got)
+	}
+
+	t.Setenv("HOME", "/home/u")
+	t.Setenv("XDG_STATE_HOME", "relative")
+	if got := DefaultStateDir(); got != "/home/u/.local/state/quadsyncd" {
+		t.Errorf("DefaultStateDir() with relative XDG_STATE_HOME = %q", got)
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// RouteDest returns where the repository file key, a slash-separated path
+// relative to the repository subdirectory, is installed when a paths.routes
+// entry matches it. The first matching route wins.
+//
+// A pattern without "/" matches the base name, and the file is installed
+// directly in the route's dest. Otherwise the file keeps its path below the
+// leading pattern directories that contain no glob characters, so
+// "nginx/*/*.conf" installs nginx/sites/app.conf as <dest>/sites/app.conf.
+func (c *Config) RouteDest(key string) (string, bool) {
+	for _, r := range c.Paths.Routes {
+		if !strings.Contains(r.Pattern, "/") {
+			if ok, _ := path.Match(r.Pattern, path.Base(key)); ok {
+				return filepath.Join(r.Dest, path.Base(key)), true
+			}
+			continue
+		}
+		if ok, _ := path.Match(r.Pattern, key); !ok {
+			continue
+		}
+		rel := key
+		for _, dir := range strings.Split(path.Dir(r.Pattern), "/") {
+			if strings.ContainsAny(dir, "*?[") {
+				break
+			}
+			rel = strings.TrimPrefix(rel, dir+"/")
+		}
+		return filepath.Join(r.Dest, filepath.FromSlash(rel)), true
+	}
+	return "", false
+}
+
+// validateRoute checks a paths.routes entry.
+func validateRoute(r RouteConfig) error {
+	if r.Pattern == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return fmt.Errorf("pattern %q: %w", r.Pattern, err)
+	}
+	if path.IsAbs(r.Pattern) || strings.HasSuffix(r.Pattern, "/") || strings.Contains(r.Pattern, `\`) {
+		return fmt.Errorf("pattern %q must be a relative path without a trailing slash or backslashes", r.Pattern)
+	}
+	for _, dir := range strings.Split(r.Pattern, "/") {
+		if dir == "" || dir == "." || dir == ".." {
+			return fmt.Errorf("pattern %q must not contain empty, . or .. elements", r.Pattern)
+		}
+	}
+	if !filepath.IsAbs(r.Dest) || filepath.Clean(r.Dest) == "/" {
+		return fmt.Errorf("dest must be an absolute path other than /: %q", r.Dest)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouteDest(t *testing.T) {
+	cfg := &Config{Paths: PathsConfig{Routes: []RouteConfig{
+		{Pattern: "nginx/*/*.conf", Dest: "/etc/nginx"},
+		{Pattern: "*.conf", Dest: "/srv/conf"},
+		{Pattern: "units/*.timer", Dest: "/home/u/.config/systemd/user"},
+		{Pattern: "*/drop-ins/*", Dest: "/srv/drop-ins"},
+	}}}
+	tests := []struct {
+		key      string
+		wantDest string
+		wantOK   bool
+	}{
+		{"nginx/sites/app.conf", "/etc/nginx/sites/app.conf", true},
+		{"nginx/app.conf", "/srv/conf/app.conf", true},
+		{"app/deep/app.conf", "/srv/conf/app.conf", true},
+		{"units/backup.timer", "/home/u/.config/systemd/user/backup.timer", true},
+		{"app/drop-ins/10-env.conf", "/srv/conf/10-env.conf", true},
+		{"app/drop-ins/10-env", "/srv/drop-ins/app/drop-ins/10-env", true},
+		{"units/sub/backup.timer", "", false},
+		{"web.container", "", false},
+	}
+	for _, tt := range tests {
+		dest, ok := cfg.RouteDest(tt.key)
+		if dest != tt.wantDest || ok != tt.wantOK {
+			t.Errorf("RouteDest(%q) = %q, %v, want %q, %v", tt.key, dest, ok, tt.wantDest, tt.wantOK)
+		}
+	}
+}
+
+func TestValidateRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		wantErr string
+	}{
+		{name: "valid", route: RouteConfig{Pattern: "nginx/*.conf", Dest: "/etc/nginx"}},
+		{name: "missing pattern", route: RouteConfig{Dest: "/etc/nginx"}, wantErr: "pattern is required"},
+		{name: "bad glob", route: RouteConfig{Pattern: "[a", Dest: "/etc/nginx"}, wantErr: "syntax error"},
+		{name: "absolute pattern", route: RouteConfig{Pattern: "/etc/*.conf", Dest: "/etc/nginx"}, wantErr: "relative path"},
+		{name: "dot element", route: RouteConfig{Pattern: "./*.conf", Dest: "/etc/nginx"}, wantErr: ". or .."},
+		{name: "root dest", route: RouteConfig{Pattern: "*.conf", Dest: "/"}, wantErr: "dest must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRoute(tt.route)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateRoute() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateRoute() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SourceType selects where a repository's files are fetched from.
+type SourceType string
+
+const (
+	// SourceGit checks out url at ref with git; the default.
+	SourceGit SourceType = "git"
+	// SourceOCI pulls the OCI artifact url:ref from a registry with oras.
+	SourceOCI SourceType = "oci"
+	// SourceDir copies the local directory url; there is no ref.
+	SourceDir SourceType = "dir"
+)
+
+// SourceConfig configures the backend a repository is fetched with.
+type SourceConfig struct {
+	// Type is SourceGit (default), SourceOCI or SourceDir.
+	Type SourceType `yaml:"type"`
+	// Digest pins an OCI artifact: the tag must resolve to this manifest
+	// digest ("sha256:<hex>") or the sync fails.
+	Digest string `yaml:"digest,omitempty"`
+	// CosignKey is a cosign public key file; when set, the artifact's
+	// signature is verified with `cosign verify` before it is used.
+	CosignKey string `yaml:"cosign_key,omitempty"`
+}
+
+// IsOCI reports whether spec is fetched from an OCI registry.
+func (s RepoSpec) IsOCI() bool {
+	return s.Source.Type == SourceOCI
+}
+
+// IsDir reports whether spec is a local directory.
+func (s RepoSpec) IsDir() bool {
+	return s.Source.Type == SourceDir
+}
+
+// UsesGit reports whether spec is fetched with git, the only backend the
+// auth section applies to.
+func (s RepoSpec) UsesGit() bool {
+	return s.Source.Type == "" || s.Source.Type == SourceGit
+}
+
+// WatchedSourceDirs returns the directories serve.watch_source watches: the
+// path of every local directory source and the checkout of every git
+// repository. OCI artifacts are not edited in place and are left out.
+func (c *Config) WatchedSourceDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, spec := range c.EffectiveRepositories() {
+		var dir string
+		switch {
+		case spec.IsDir():
+			dir = spec.URL
+		case spec.UsesGit():
+			dir = c.RepoDirForSpec(spec)
+		default:
+			continue
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// digestPattern matches an OCI sha256 content digest.
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// validateSource checks spec.Source and the repository fields that only
+// apply to one backend.
+func validateSource(spec RepoSpec, label string) error {
+	src := spec.Source
+	switch src.Type {
+	case "", SourceGit:
+		if src.Digest != "" || src.CosignKey != "" {
+			return fmt.Errorf("%s.source.digest and %s.source.cosign_key require source.type %s", label, label, SourceOCI)
+		}
+		return nil
+	case SourceOCI, SourceDir:
+	default:
+		return fmt.Errorf("%s.source.type must be %s, %s or %s: %s", label, SourceGit, SourceOCI, SourceDir, src.Type)
+	}
+
+	if spec.CloneDepth != 0 || spec.Filter != "" || spec.Submodules {
+		return fmt.Errorf("%s: clone_depth, filter and submodules do not apply to source.type %s", label, src.Type)
+	}
+	if src.Type == SourceDir {
+		if !filepath.IsAbs(spec.URL) {
+			return fmt.Errorf("%s.url must be an absolute directory path with source.type %s: %s", label, SourceDir, spec.URL)
+		}
+		if spec.Ref != "" {
+			return fmt.Errorf("%s.ref does not apply to source.type %s", label, SourceDir)
+		}
+		if spec.Auth != nil {
+			return fmt.Errorf("%s.auth does not apply to source.type %s", label, SourceDir)
+		}
+		if src.Digest != "" || src.CosignKey != "" {
+			return fmt.Errorf("%s.source.digest and %s.source.cosign_key require source.type %s", label, label, SourceOCI)
+		}
+		return nil
+	}
+
+	if !validOCIRepository(spec.URL) {
+		return fmt.Errorf("%s.url must be an OCI repository such as registry.example.com/org/quadlets without scheme, tag or digest: %s", label, spec.URL)
+	}
+	if spec.Auth != nil {
+		return fmt.Errorf("%s.auth does not apply to source.type %s; log in with `oras login` instead", label, SourceOCI)
+	}
+	if src.Digest != "" && !digestPattern.MatchString(src.Digest) {
+		return fmt.Errorf("%s.source.digest must be sha256:<64 hex digits>: %s", label, src.Digest)
+	}
+	if src.CosignKey != "" && !filepath.IsAbs(src.CosignKey) {
+		return fmt.Errorf("%s.source.cosign_key must be an absolute path: %s", label, src.CosignKey)
+	}
+	return nil
+}
+
+// validOCIRepository reports whether ref names a repository on a registry,
+// such as registry.example.com/org/quadlets or localhost:5000/quadlets,
+// without a scheme, tag or digest.
+func validOCIRepository(ref string) bool {
+	if strings.Contains(ref, "://") || strings.ContainsAny(ref, "@ \t") {
+		return false
+	}
+	host, path, ok := strings.Cut(ref, "/")
+	if !ok || host == "" || path == "" || strings.Contains(path, ":") {
+		return false
+	}
+	return strings.Count(host, ":") <= 1
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSource(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	oci := func(mut func(*RepoSpec)) RepoSpec {
+		spec := RepoSpec{URL: "registry.example.com/ops/quadlets", Ref: "v1", Source: SourceConfig{Type: SourceOCI}}
+		if mut != nil {
+			mut(&spec)
+		}
+		return spec
+	}
+
+	tests := []struct {
+		name    string
+		spec    RepoSpec
+		wantErr string
+	}{
+		{name: "git default", spec: RepoSpec{URL: "https://github.com/o/r"}},
+		{name: "git explicit", spec: RepoSpec{URL: "https://github.com/o/r", Source: SourceConfig{Type: SourceGit}}},
+		{name: "git with digest", spec: RepoSpec{Source: SourceConfig{Digest: digest}}, wantErr: "require source.type oci"},
+		{name: "unknown type", spec: RepoSpec{Source: SourceConfig{Type: "svn"}}, wantErr: "must be git, oci or dir"},
+		{name: "dir", spec: RepoSpec{URL: "/srv/quadlets", Source: SourceConfig{Type: SourceDir}}},
+		{name: "dir relative", spec: RepoSpec{URL: "quadlets", Source: SourceConfig{Type: SourceDir}}, wantErr: "absolute directory path"},
+		{name: "dir with ref", spec: RepoSpec{URL: "/srv/quadlets", Ref: "main", Source: SourceConfig{Type: SourceDir}}, wantErr: "ref does not apply"},
+		{name: "dir with submodules", spec: RepoSpec{URL: "/srv/quadlets", Submodules: true, Source: SourceConfig{Type: SourceDir}}, wantErr: "do not apply"},
+		{name: "dir with digest", spec: RepoSpec{URL: "/srv/quadlets", Source: SourceConfig{Type: SourceDir, Digest: digest}}, wantErr: "require source.type oci"},
+		{name: "oci", spec: oci(nil)},
+		{name: "oci registry with port", spec: oci(func(s *RepoSpec) { s.URL = "localhost:5000/quadlets" })},
+		{name: "oci pinned and signed", spec: oci(func(s *RepoSpec) {
+			s.Source.Digest = digest
+			s.Source.CosignKey = "/etc/quadsyncd/cosign.pub"
+		})},
+		{name: "oci with scheme", spec: oci(func(s *RepoSpec) { s.URL = "https://registry.example.com/q" }), wantErr: "must be an OCI repository"},
+		{name: "oci with tag", spec: oci(func(s *RepoSpec) { s.URL = "registry.example.com/q:v1" }), wantErr: "must be an OCI repository"},
+		{name: "oci with digest in url", spec: oci(func(s *RepoSpec) { s.URL = "registry.example.com/q@" + digest }), wantErr: "must be an OCI repository"},
+		{name: "oci without path", spec: oci(func(s *RepoSpec) { s.URL = "registry.example.com" }), wantErr: "must be an OCI repository"},
+		{name: "oci with clone_depth", spec: oci(func(s *RepoSpec) { s.CloneDepth = 1 }), wantErr: "do not apply"},
+		{name: "oci with auth", spec: oci(func(s *RepoSpec) { s.Auth = &AuthConfig{} }), wantErr: "oras login"},
+		{name: "oci bad digest", spec: oci(func(s *RepoSpec) { s.Source.Digest = "sha256:abc" }), wantErr: "source.digest must be"},
+		{name: "oci relative key", spec: oci(func(s *RepoSpec) { s.Source.CosignKey = "cosign.pub" }), wantErr: "absolute path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSource(tt.spec, "repository")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSource() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSource() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
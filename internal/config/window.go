@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Window is a weekly recurring time range such as "mon-fri 09:00-17:00",
+// evaluated in local time. The zero value never matches.
+type Window struct {
+	days       [7]bool // indexed by time.Weekday
+	start, end int     // minutes after midnight; end <= start crosses midnight
+	spec       string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWindow parses "<days> <HH:MM>-<HH:MM>". Days are "*" for every day, or
+// a comma-separated list of three-letter weekday names and ranges such as
+// "mon-fri,sun". The end may be "24:00"; an end at or before the start makes
+// the range run past midnight into the next day.
+func ParseWindow(s string) (Window, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Window{}, fmt.Errorf("invalid window %q: want \"<days> <HH:MM>-<HH:MM>\"", s)
+	}
+	w := Window{spec: strings.Join(fields, " ")}
+
+	if fields[0] == "*" {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+	} else {
+		for _, part := range strings.Split(strings.ToLower(fields[0]), ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			first, ok1 := weekdays[from]
+			last, ok2 := weekdays[to]
+			if !isRange {
+				last, ok2 = first, ok1
+			}
+			if !ok1 || !ok2 {
+				return Window{}, fmt.Errorf("invalid window %q: unknown days %q", s, part)
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: want a time range such as 09:00-17:00", s)
+	}
+	var err error
+	if w.start, err = parseClock(from, false); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.end, err = parseClock(to, true); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	return w, nil
+}
+
+// parseClock parses HH:MM into minutes after midnight; 24:00 only as an end.
+func parseClock(s string, end bool) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || len(m) != 2 || err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	if hours > 23 && !(end && hours == 24 && minutes == 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// Contains reports whether t falls inside the window. A range past midnight
+// belongs to the day it starts on.
+func (w Window) Contains(t time.Time) bool {
+	t = t.Local()
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+	return (w.days[t.Weekday()] && minute >= w.start) ||
+		(w.days[(t.Weekday()+6)%7] && minute < w.end)
+}
+
+// String returns the window as written in the configuration.
+func (w Window) String() string {
+	return w.spec
+}
+
+// UnmarshalYAML parses a window string.
+func (w *Window) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: window must be a string such as \"mon-fri 09:00-17:00\"", node.Line)
+	}
+	v, err := ParseWindow(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*w = v
+	return nil
+}
+
+// MarshalYAML writes the window as its string form.
+func (w Window) MarshalYAML() (any, error) {
+	return w.spec, nil
+}
+
+// ActiveWindow returns the first of sync.windows that contains t, or false
+// when restarts are not deferred at t.
+func (s SyncConfig) ActiveWindow(t time.Time) (Window, bool) {
+	for _, w := range s.Windows {
+		if w.Contains(t) {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
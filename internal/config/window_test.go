@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWindow_Contains(t *testing.T) {
+	// 2026-03-02 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{"mon-fri 09:00-17:00", at(2, 9, 0), true},
+		{"mon-fri 09:00-17:00", at(2, 17, 0), false},
+		{"mon-fri 09:00-17:00", at(7, 12, 0), false}, // Saturday
+		{"sat,sun 00:00-24:00", at(8, 23, 59), true},
+		{"fri-mon 12:00-13:00", at(1, 12, 30), true}, // Sunday, range wraps the week
+		{"fri-mon 12:00-13:00", at(3, 12, 30), false},
+		{"fri 22:00-02:00", at(7, 1, 30), true}, // Saturday morning belongs to Friday
+		{"fri 22:00-02:00", at(6, 1, 30), false},
+		{"* 02:00-04:00", at(4, 3, 0), true},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q) error = %v", tt.spec, err)
+		}
+		if got := w.Contains(tt.t); got != tt.want {
+			t.Errorf("%q.Contains(%s) = %v, want %v", tt.spec, tt.t.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestParseWindow_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"", "mon-fri", "mon-fri 09:00", "weekdays 09:00-17:00", "mon 9-17",
+		"mon 24:00-01:00", "mon 09:60-10:00", "mon 09:00-24:30", "mon 09:00-17:00 extra",
+	} {
+		if _, err := ParseWindow(spec); err == nil {
+			t.Errorf("ParseWindow(%q) accepted an invalid window", spec)
+		}
+	}
+}
+
+func TestSyncConfig_Windows_YAML(t *testing.T) {
+	var got SyncConfig
+	if err := yaml.Unmarshal([]byte("windows: [\"mon-fri 09:00-17:00\"]"), &got); err != nil {
+		t.Fatalf("Unmarshal error = %v", err)
+	}
+	monday := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	if w, ok := got.ActiveWindow(monday); !ok || w.String() != "mon-fri 09:00-17:00" {
+		t.Errorf("ActiveWindow() = %q, %v", w, ok)
+	}
+	if _, ok := got.ActiveWindow(monday.Add(8 * time.Hour)); ok {
+		t.Error("ActiveWindow() matched outside the window")
+	}
+
+	out, err := yaml.Marshal(got)
+	if err != nil || !strings.Contains(string(out), "mon-fri 09:00-17:00") {
+		t.Errorf("Marshal() = %s, %v", out, err)
+	}
+
+	if err := yaml.Unmarshal([]byte("windows: [\"mon 9-17\"]"), &got); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Unmarshal error = %v, want line number", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Package cosign verifies Sigstore signatures with the cosign CLI.
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+)
+
+// CLI runs cosign, bounding each invocation by a timeout.
+type CLI struct {
+	timeout time.Duration
+}
+
+// NewCLI returns a CLI whose cosign runs are killed after timeout (0 means
+// no limit).
+func NewCLI(timeout time.Duration) *CLI {
+	return &CLI{timeout: timeout}
+}
+
+// VerifyBlob checks that signature, a file holding a base64 signature as
+// written by `cosign sign-blob`, signs the file at path with the private key
+// matching the public key file key.
+func (c *CLI) VerifyBlob(ctx context.Context, key, signature, path string) error {
+	return c.run(ctx, "verify-blob", "--key", key, "--signature", signature, path)
+}
+
+// Signer is who an image signature must come from: the public key file Key,
+// or, for keyless signatures, a Fulcio certificate issued by the OIDC
+// provider Issuer to Subject or to an identity matching SubjectRegexp.
+type Signer struct {
+	Key           string
+	Issuer        string
+	Subject       string
+	SubjectRegexp string
+}
+
+// VerifyImage checks that image carries a signature by signer in its
+// registry. Registry credentials are those of `cosign login` or the
+// container auth file.
+func (c *CLI) VerifyImage(ctx context.Context, image string, signer Signer) error {
+	args := []string{"verify"}
+	switch {
+	case signer.Key != "":
+		args = append(args, "--key", signer.Key)
+	case signer.SubjectRegexp != "":
+		args = append(args, "--certificate-identity-regexp", signer.SubjectRegexp, "--certificate-oidc-issuer", signer.Issuer)
+	default:
+		args = append(args, "--certificate-identity", signer.Subject, "--certificate-oidc-issuer", signer.Issuer)
+	}
+	return c.run(ctx, append(args, image)...)
+}
+
+// run runs cosign with args and returns an error including its output when
+// it fails.
+func (c *CLI) run(ctx context.Context, args ...string) error {
+	ctx, cancel := cmdexec.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	cmd := cmdexec.Command(ctx, "cosign", args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmdexec.Err(ctx, c.timeout, cmd.Run()); err != nil {
+		return fmt.Errorf("cosign %s: %w: %s", args[0], err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cosign
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_VerifyBlob(t *testing.T) {
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := "#!/bin/sh\necho \"$*\" > " + argsFile + "\n" +
+		"[ \"$5\" = bad.sig ] && { echo 'Error: invalid signature when validating ASN.1 encoded signature' >&2; exit 1; }\n" +
+		"echo 'Verified OK' >&2\n"
+	if err := os.WriteFile(filepath.Join(binDir, "cosign"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	c := NewCLI(0)
+	if err := c.VerifyBlob(context.Background(), "/k/cosign.pub", "good.sig", "/repo/MANIFEST.sha256"); err != nil {
+		t.Fatalf("VerifyBlob() error = %v", err)
+	}
+	if data, _ := os.ReadFile(argsFile); strings.TrimSpace(string(data)) != "verify-blob --key /k/cosign.pub --signature good.sig /repo/MANIFEST.sha256" {
+		t.Errorf("cosign args = %q", data)
+	}
+	err := c.VerifyBlob(context.Background(), "/k/cosign.pub", "bad.sig", "/repo/MANIFEST.sha256")
+	if err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Errorf("VerifyBlob() error = %v, want cosign's output", err)
+	}
+}
+
+func TestCLI_VerifyImage(t *testing.T) {
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := "#!/bin/sh\necho \"$*\" > " + argsFile + "\n" +
+		"case \"$*\" in *unsigned*) echo 'Error: no matching signatures' >&2; exit 1;; esac\n"
+	if err := os.WriteFile(filepath.Join(binDir, "cosign"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name     string
+		signer   Signer
+		wantArgs string
+	}{
+		{"key", Signer{Key: "/k/cosign.pub"}, "verify --key /k/cosign.pub ghcr.io/acme/app:1.0"},
+		{"subject", Signer{Issuer: "https://accounts.google.com", Subject: "ci@acme.dev"},
+			"verify --certificate-identity ci@acme.dev --certificate-oidc-issuer https://accounts.google.com ghcr.io/acme/app:1.0"},
+		{"subject regexp", Signer{Issuer: "https://token.actions.githubusercontent.com", SubjectRegexp: "^https://github.com/acme/"},
+			"verify --certificate-identity-regexp ^https://github.com/acme/ --certificate-oidc-issuer https://token.actions.githubusercontent.com ghcr.io/acme/app:1.0"},
+	}
+	c := NewCLI(0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.VerifyImage(context.Background(), "ghcr.io/acme/app:1.0", tt.signer); err != nil {
+				t.Fatalf("VerifyImage() error = %v", err)
+			}
+			if data, _ := os.ReadFile(argsFile); strings.TrimSpace(string(data)) != tt.wantArgs {
+				t.Errorf("cosign args = %q, want %q", data, tt.wantArgs)
+			}
+		})
+	}
+	err := c.VerifyImage(context.Background(), "ghcr.io/acme/unsigned:1.0", Signer{Key: "/k/cosign.pub"})
+	if err == nil || !strings.Contains(err.Error(), "no matching signatures") {
+		t.Errorf("VerifyImage() error = %v, want cosign's output", err)
+	}
+}
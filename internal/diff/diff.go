@@ -0,0 +1,181 @@
+// Package diff renders unified line diffs for plan previews.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change,
+// matching the git default.
+const contextLines = 3
+
+// opKind identifies an edit script operation.
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// edit is a single line in the edit script.
+type edit struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff between a and b using the given file labels
+// (e.g. "a/web.container", "/dev/null"). It returns an empty string when the
+// contents are identical.
+func Unified(fromLabel, toLabel string, a, b []byte) string {
+	aLines := splitLines(string(a))
+	bLines := splitLines(string(b))
+	script := lcsEdits(aLines, bLines)
+
+	changed := false
+	for _, e := range script {
+		if e.kind != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for _, h := range hunks(script) {
+		writeHunk(&sb, script, h)
+	}
+	return sb.String()
+}
+
+// splitLines splits s into lines without their trailing newline. An empty
+// input yields no lines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lcsEdits computes a minimal edit script via the longest common subsequence.
+// Quadlet files are small, so the O(n*m) table is acceptable.
+func lcsEdits(a, b []string) []edit {
+	n, m := len(a), len(b)
+	table := make([][]int, n+1)
+	for i := range table {
+		table[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	script := make([]edit, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			script = append(script, edit{opEqual, a[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			script = append(script, edit{opDelete, a[i]})
+			i++
+		default:
+			script = append(script, edit{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		script = append(script, edit{opDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		script = append(script, edit{opInsert, b[j]})
+	}
+	return script
+}
+
+// hunk is a half-open range [start, end) of the edit script.
+type hunk struct {
+	start, end int
+}
+
+// hunks groups changed regions of the script, each padded with up to
+// contextLines of surrounding equal lines; nearby groups are merged.
+func hunks(script []edit) []hunk {
+	var out []hunk
+	for i, e := range script {
+		if e.kind == opEqual {
+			continue
+		}
+		start := max(i-contextLines, 0)
+		end := min(i+contextLines+1, len(script))
+		if len(out) > 0 && start <= out[len(out)-1].end {
+			out[len(out)-1].end = max(out[len(out)-1].end, end)
+			continue
+		}
+		out = append(out, hunk{start: start, end: end})
+	}
+	return out
+}
+
+// writeHunk renders a single hunk with its @@ header.
+func writeHunk(sb *strings.Builder, script []edit, h hunk) {
+	// Line numbers (1-based) of the hunk start in each file.
+	aLine, bLine := 1, 1
+	for _, e := range script[:h.start] {
+		if e.kind != opInsert {
+			aLine++
+		}
+		if e.kind != opDelete {
+			bLine++
+		}
+	}
+	aCount, bCount := 0, 0
+	for _, e := range script[h.start:h.end] {
+		if e.kind != opInsert {
+			aCount++
+		}
+		if e.kind != opDelete {
+			bCount++
+		}
+	}
+	// Per the unified format, an empty range starts at the line before it.
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+	for _, e := range script[h.start:h.end] {
+		switch e.kind {
+		case opEqual:
+			sb.WriteString(" ")
+		case opDelete:
+			sb.WriteString("-")
+		case opInsert:
+			sb.WriteString("+")
+		}
+		sb.WriteString(e.line)
+		sb.WriteString("\n")
+	}
+}
+
+// hunkRange formats a start/count pair, omitting the count when it is 1.
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,49 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    "x\ny\n",
+			b:    "x\ny\n",
+			want: "",
+		},
+		{
+			name: "added file",
+			a:    "",
+			b:    "[Container]\nImage=nginx\n",
+			want: "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+[Container]\n+Image=nginx\n",
+		},
+		{
+			name: "deleted file",
+			a:    "[Volume]\n",
+			b:    "",
+			want: "--- from\n+++ to\n@@ -1 +0,0 @@\n-[Volume]\n",
+		},
+		{
+			name: "single line change with context",
+			a:    "[Container]\nImage=nginx:1.0\nPublishPort=80:80\n",
+			b:    "[Container]\nImage=nginx:1.1\nPublishPort=80:80\n",
+			want: "--- from\n+++ to\n@@ -1,3 +1,3 @@\n [Container]\n-Image=nginx:1.0\n+Image=nginx:1.1\n PublishPort=80:80\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- from\n+++ to\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Unified("from", "to", []byte(tc.a), []byte(tc.b))
+			if got != tc.want {
+				t.Errorf("Unified() mismatch\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+// Package fsutil holds file system helpers shared by the checkout clients
+// that build a source tree in a temporary directory before installing it.
+package fsutil
+
+import "os"
+
+// ReplaceDir moves src to dst, replacing whatever dst held. src and dst must
+// be on the same file system. If the move fails, the previous contents of
+// dst are put back so the next run starts from them.
+func ReplaceDir(src, dst string) error {
+	old := dst + ".old"
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		_ = os.Rename(old, dst)
+		return err
+	}
+	return os.RemoveAll(old)
+}
@@ -0,0 +1,53 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceDir(t *testing.T) {
+	tmp := t.TempDir()
+	dst := filepath.Join(tmp, "checkout")
+
+	for _, content := range []string{"v1", "v2"} {
+		src := filepath.Join(tmp, "new-"+content)
+		if err := os.MkdirAll(src, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, "app.container"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ReplaceDir(src, dst); err != nil {
+			t.Fatalf("ReplaceDir() error = %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(dst, "app.container"))
+		if err != nil || string(data) != content {
+			t.Fatalf("app.container = %q, %v; want %q", data, err, content)
+		}
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Errorf("source %s still exists: %v", src, err)
+		}
+	}
+	if _, err := os.Stat(dst + ".old"); !os.IsNotExist(err) {
+		t.Errorf("previous contents were not removed: %v", err)
+	}
+}
+
+func TestReplaceDir_RestoresOnFailure(t *testing.T) {
+	tmp := t.TempDir()
+	dst := filepath.Join(tmp, "checkout")
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "app.container"), []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReplaceDir(filepath.Join(tmp, "missing"), dst); err == nil {
+		t.Fatal("ReplaceDir() error = nil, want the rename error")
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "app.container")); err != nil || string(data) != "v1" {
+		t.Errorf("app.container = %q, %v; want the previous contents back", data, err)
+	}
+}
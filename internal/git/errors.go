@@ -0,0 +1,140 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+)
+
+// FailureKind classifies why a git network operation failed.
+type FailureKind string
+
+const (
+	// FailureAuth indicates the remote rejected the configured credentials
+	// (HTTP 401/403, SSH publickey rejection, missing username prompt).
+	FailureAuth FailureKind = "auth"
+	// FailureNetwork indicates the remote could not be reached (DNS, TCP
+	// connect, timeouts).
+	FailureNetwork FailureKind = "network"
+	// FailureCorrupt indicates the local checkout is damaged (missing or
+	// corrupt objects, an interrupted clone).
+	FailureCorrupt FailureKind = "corrupt"
+	// FailureUnknown is used for git failures that match no known pattern.
+	FailureUnknown FailureKind = "unknown"
+)
+
+// authFailurePatterns are lower-cased substrings of git/ssh/curl output that
+// indicate rejected or missing credentials.
+var authFailurePatterns = []string{
+	"authentication failed",
+	"http basic: access denied",
+	"invalid username or password",
+	"the requested url returned error: 401",
+	"the requested url returned error: 403",
+	"permission denied (publickey",
+	"could not read username",
+	"could not read password",
+	"terminal prompts disabled",
+	"bad credentials",
+}
+
+// networkFailurePatterns are lower-cased substrings of git/ssh/curl output that
+// indicate the remote was unreachable.
+var networkFailurePatterns = []string{
+	"could not resolve host",
+	"could not resolve hostname",
+	"temporary failure in name resolution",
+	"connection refused",
+	"connection timed out",
+	"operation timed out",
+	"network is unreachable",
+	"no route to host",
+	"failed to connect to",
+	"connection reset by peer",
+}
+
+// corruptFailurePatterns are lower-cased substrings of git output that
+// indicate a damaged local repository rather than a problem with the remote.
+// A held index.lock or an existing clone directory is left out: another git
+// process working on the same checkout causes both, and re-cloning would
+// delete the checkout underneath it.
+var corruptFailurePatterns = []string{
+	"not a git repository",
+	"is corrupt",
+	"object file",
+	"bad object",
+	"invalid object",
+	"index file corrupt",
+	"unable to read tree",
+	"unable to read sha1 file",
+	"inflate: data stream error",
+	"broken link from",
+	"did not send all necessary objects",
+	"'origin' does not appear to be a git repository",
+}
+
+// ClassifyOutput inspects the combined output of a failed git command and
+// returns the most likely failure kind. Auth patterns are checked first because
+// SSH auth failures are usually followed by a generic "could not read from
+// remote repository" line.
+func ClassifyOutput(output string) FailureKind {
+	lower := strings.ToLower(output)
+	for _, p := range authFailurePatterns {
+		if strings.Contains(lower, p) {
+			return FailureAuth
+		}
+	}
+	for _, p := range networkFailurePatterns {
+		if strings.Contains(lower, p) {
+			return FailureNetwork
+		}
+	}
+	for _, p := range corruptFailurePatterns {
+		if strings.Contains(lower, p) {
+			return FailureCorrupt
+		}
+	}
+	return FailureUnknown
+}
+
+// CommandError is returned by ShellClient when a clone or fetch fails. It
+// carries the classified failure kind so callers can tell expired or revoked
+// credentials apart from transient network problems.
+type CommandError struct {
+	Op   string // git subcommand, e.g. "clone" or "fetch"
+	Kind FailureKind
+	Err  error
+}
+
+// Error implements error.
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("git %s failed: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the underlying command error.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// newCommandError wraps err (as returned by ShellClient.git, including
+// output) into a classified CommandError. A command killed by its timeout is
+// treated as a network failure: a stalled remote is the usual cause.
+func newCommandError(op string, err error) *CommandError {
+	kind := ClassifyOutput(err.Error())
+	if kind == FailureUnknown && errors.Is(err, cmdexec.ErrTimeout) {
+		kind = FailureNetwork
+	}
+	return &CommandError{Op: op, Kind: kind, Err: err}
+}
+
+// KindOf returns the failure kind of the first CommandError in err's chain,
+// or the empty string if err did not originate from a git network operation.
+func KindOf(err error) FailureKind {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Kind
+	}
+	return ""
+}
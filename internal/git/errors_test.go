@@ -0,0 +1,130 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyOutput(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		output string
+		want   FailureKind
+	}{
+		{
+			name:   "https 401",
+			output: "fatal: unable to access 'https://github.com/o/r.git/': The requested URL returned error: 401",
+			want:   FailureAuth,
+		},
+		{
+			name:   "https 403",
+			output: "remote: Write access to repository not granted.\nfatal: unable to access 'https://github.com/o/r.git/': The requested URL returned error: 403",
+			want:   FailureAuth,
+		},
+		{
+			name:   "https authentication failed",
+			output: "remote: Invalid username or token.\nfatal: Authentication failed for 'https://github.com/o/r.git/'",
+			want:   FailureAuth,
+		},
+		{
+			name:   "ssh publickey",
+			output: "git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.",
+			want:   FailureAuth,
+		},
+		{
+			name:   "prompt disabled",
+			output: "fatal: could not read Username for 'https://github.com': terminal prompts disabled",
+			want:   FailureAuth,
+		},
+		{
+			name:   "dns failure",
+			output: "fatal: unable to access 'https://github.com/o/r.git/': Could not resolve host: github.com",
+			want:   FailureNetwork,
+		},
+		{
+			name:   "ssh dns failure",
+			output: "ssh: Could not resolve hostname github.com: Temporary failure in name resolution",
+			want:   FailureNetwork,
+		},
+		{
+			name:   "connection refused",
+			output: "ssh: connect to host github.com port 22: Connection refused",
+			want:   FailureNetwork,
+		},
+		{
+			name:   "corrupt loose object",
+			output: "error: object file .git/objects/e8/2d3b is empty\nfatal: loose object e82d3b (stored in .git/objects/e8/2d3b) is corrupt",
+			want:   FailureCorrupt,
+		},
+		{
+			name:   "interrupted clone",
+			output: "fatal: not a git repository (or any of the parent directories): .git",
+			want:   FailureCorrupt,
+		},
+		{
+			name:   "existing clone directory",
+			output: "fatal: destination path '/state/repos/a408a9afe07d5288' already exists and is not an empty directory.",
+			want:   FailureUnknown,
+		},
+		{
+			name:   "index lock held by another git",
+			output: "fatal: Unable to create '/state/repos/a408a9afe07d5288/.git/index.lock': File exists.",
+			want:   FailureUnknown,
+		},
+		{
+			name:   "unknown",
+			output: "fatal: repository 'https://github.com/o/missing.git/' not found",
+			want:   FailureUnknown,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ClassifyOutput(tc.output); got != tc.want {
+				t.Errorf("ClassifyOutput() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	base := errors.New("exit status 128: fatal: Authentication failed for 'https://example.com/r.git/'")
+	cmdErr := newCommandError("fetch", base)
+
+	if cmdErr.Kind != FailureAuth {
+		t.Fatalf("expected auth kind, got %q", cmdErr.Kind)
+	}
+	if cmdErr.Error() != "git fetch failed: "+base.Error() {
+		t.Errorf("unexpected error message: %s", cmdErr.Error())
+	}
+	if !errors.Is(cmdErr, base) {
+		t.Error("CommandError should unwrap to the underlying error")
+	}
+
+	wrapped := fmt.Errorf("repo https://example.com/r.git: checkout failed: %w", cmdErr)
+	if got := KindOf(wrapped); got != FailureAuth {
+		t.Errorf("KindOf(wrapped) = %q, want %q", got, FailureAuth)
+	}
+	if got := KindOf(errors.New("plain")); got != "" {
+		t.Errorf("KindOf(plain) = %q, want empty", got)
+	}
+	if got := KindOf(nil); got != "" {
+		t.Errorf("KindOf(nil) = %q, want empty", got)
+	}
+}
+
+func TestEnsureCheckout_ClassifiesCloneFailure(t *testing.T) {
+	client := NewShellClient("", "", testLogger())
+	missing := t.TempDir() + "/does-not-exist"
+
+	_, err := client.EnsureCheckout(t.Context(), missing, "main", t.TempDir()+"/clone", CheckoutOptions{})
+	if err == nil {
+		t.Fatal("expected clone of missing repo to fail")
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected *CommandError, got %T: %v", err, err)
+	}
+	if cmdErr.Op != "clone" {
+		t.Errorf("expected op clone, got %q", cmdErr.Op)
+	}
+}
@@ -3,37 +3,194 @@ package git
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+	"github.com/schaermu/quadsyncd/internal/logging"
 )
 
 // Client provides git operations for repository management
 type Client interface {
 	// EnsureCheckout clones or updates a repository to the specified ref
-	EnsureCheckout(ctx context.Context, url, ref, destDir string) (string, error)
+	EnsureCheckout(ctx context.Context, url, ref, destDir string, opts CheckoutOptions) (string, error)
+}
+
+// Differ is implemented by clients that can list the files changed between
+// two commits of a checkout made by EnsureCheckout.
+type Differ interface {
+	// ChangedFiles returns the slash-separated, repository-relative paths
+	// added, modified, deleted or changed in type between the commits from
+	// and to of the checkout in destDir.
+	ChangedFiles(ctx context.Context, destDir, from, to string) ([]string, error)
+}
+
+// CheckoutOptions limits how much of a repository is downloaded. The zero
+// value clones full history with all file contents.
+type CheckoutOptions struct {
+	// Depth truncates history to this many commits per branch; 0 is unlimited.
+	Depth int
+	// Filter is a partial clone filter such as "blob:none". File contents
+	// are then fetched on demand at checkout. It only applies to new clones.
+	Filter string
+	// SparseDirs limits the working tree to these slash-separated
+	// directories (plus files in the repository root) using cone-mode
+	// sparse checkout. Empty materializes the whole tree.
+	SparseDirs []string
+	// Submodules initializes and updates submodules recursively after
+	// checkout, using the same credentials. With SparseDirs set, only
+	// submodules inside those directories are updated.
+	Submodules bool
+	// SubmoduleJobs is how many submodules are fetched in parallel; 0 or 1
+	// leaves it to git's submodule.fetchJobs setting.
+	SubmoduleJobs int
+	// MaintenanceInterval runs git remote prune origin and git gc --auto
+	// after a checkout when they last ran longer ago than this; 0 never
+	// runs them.
+	MaintenanceInterval time.Duration
+	// MaxSize re-clones an existing checkout whose .git directory is larger
+	// than this many bytes; 0 disables the limit.
+	MaxSize int64
+	// KeepCorrupt fails a fetch or checkout whose output indicates a damaged
+	// repository instead of removing the checkout and cloning it again once.
+	KeepCorrupt bool
+}
+
+// cloneFlags returns the git clone flags for o. A shallow clone still fetches
+// every branch tip so that switching the tracked branch needs no re-clone.
+func (o CheckoutOptions) cloneFlags() []string {
+	var flags []string
+	if o.Depth > 0 {
+		flags = append(flags, "--depth", strconv.Itoa(o.Depth), "--no-single-branch")
+	}
+	if o.Filter != "" {
+		flags = append(flags, "--filter="+o.Filter)
+	}
+	return flags
+}
+
+// fetchFlags returns the git fetch flags for o.
+func (o CheckoutOptions) fetchFlags() []string {
+	if o.Depth > 0 {
+		return []string{"--depth", strconv.Itoa(o.Depth)}
+	}
+	return nil
 }
 
 // ShellClient implements Client by shelling out to the git command
 type ShellClient struct {
-	sshKeyFile     string
-	httpsTokenFile string
-	logger         *slog.Logger
+	sshKeyFile        string
+	sshKnownHostsFile string
+	sshStrictHostKey  string
+	httpsTokenFile    string
+	httpsTokenCommand string
+	httpsUsername     string
+	timeout           time.Duration
+	logger            *slog.Logger
+}
+
+// AuthOptions bundles the credential sources a ShellClient may use.
+// At most one of them is expected to be set.
+type AuthOptions struct {
+	SSHKeyFile string
+	// SSHKnownHostsFile replaces the user's known_hosts file for SSH remotes.
+	SSHKnownHostsFile string
+	// SSHStrictHostKeyChecking is passed to ssh as StrictHostKeyChecking;
+	// empty means "accept-new".
+	SSHStrictHostKeyChecking string
+	HTTPSTokenFile           string
+	// HTTPSTokenCommand is run via "sh -c" before every clone/fetch; its
+	// trimmed stdout is used as the HTTPS token.
+	HTTPSTokenCommand string
+	// HTTPSUsername is sent with the HTTPS token; empty means
+	// defaultHTTPSUsername.
+	HTTPSUsername string
 }
 
+// defaultStrictHostKeyChecking trusts a host key on first use and rejects
+// changed keys afterwards.
+const defaultStrictHostKeyChecking = "accept-new"
+
+// defaultHTTPSUsername is the username sent with an HTTPS token when none is
+// configured. Token-based hosts ignore it, but git needs one.
+const defaultHTTPSUsername = "x-access-token"
+
+// tokenCommandTimeout bounds how long an https_token_command may run.
+const tokenCommandTimeout = 30 * time.Second
+
 // NewShellClient creates a new git client that uses the git command
 func NewShellClient(sshKeyFile, httpsTokenFile string, logger *slog.Logger) *ShellClient {
+	return NewShellClientWithAuth(AuthOptions{
+		SSHKeyFile:     sshKeyFile,
+		HTTPSTokenFile: httpsTokenFile,
+	}, logger)
+}
+
+// NewShellClientWithAuth creates a new git client using the given credential
+// sources. Git commands are only bounded by the caller's context.
+func NewShellClientWithAuth(opts AuthOptions, logger *slog.Logger) *ShellClient {
+	return NewShellClientWithTimeout(opts, 0, logger)
+}
+
+// NewShellClientWithTimeout creates a new git client using the given
+// credential sources whose git invocations are each killed after timeout
+// (0 means no per-command limit).
+func NewShellClientWithTimeout(opts AuthOptions, timeout time.Duration, logger *slog.Logger) *ShellClient {
 	return &ShellClient{
-		sshKeyFile:     sshKeyFile,
-		httpsTokenFile: httpsTokenFile,
-		logger:         logger,
+		sshKeyFile:        opts.SSHKeyFile,
+		sshKnownHostsFile: opts.SSHKnownHostsFile,
+		sshStrictHostKey:  opts.SSHStrictHostKeyChecking,
+		httpsTokenFile:    opts.HTTPSTokenFile,
+		httpsTokenCommand: opts.HTTPSTokenCommand,
+		httpsUsername:     opts.HTTPSUsername,
+		timeout:           timeout,
+		logger:            logger,
+	}
+}
+
+// EnsureCheckout clones or fetches and checks out the specified ref. Per
+// opts, an oversized or corrupt checkout is cloned again from scratch, and
+// a successful checkout is pruned and garbage collected now and then.
+func (c *ShellClient) EnsureCheckout(ctx context.Context, url, ref, destDir string, opts CheckoutOptions) (string, error) {
+	if opts.MaxSize > 0 {
+		if size, err := dirSize(filepath.Join(destDir, ".git")); err == nil && size > opts.MaxSize {
+			c.logger.Info("checkout exceeds its size limit, cloning again", "dest", destDir, "size", size, "limit", opts.MaxSize)
+			if err := os.RemoveAll(destDir); err != nil {
+				return "", fmt.Errorf("failed to remove oversized checkout: %w", err)
+			}
+		}
+	}
+
+	commit, err := c.checkout(ctx, url, ref, destDir, opts)
+	if err != nil && !opts.KeepCorrupt && ClassifyOutput(err.Error()) == FailureCorrupt {
+		if _, statErr := os.Stat(destDir); statErr == nil {
+			c.logger.Warn("checkout is damaged, cloning again", logging.Event(logging.EventRepoRecloned),
+				logging.KeyRepo, url, "dest", destDir, logging.KeyError, err)
+			if err := os.RemoveAll(destDir); err != nil {
+				return "", fmt.Errorf("failed to remove damaged checkout: %w", err)
+			}
+			commit, err = c.checkout(ctx, url, ref, destDir, opts)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if opts.MaintenanceInterval > 0 {
+		c.maintain(ctx, url, destDir, opts.MaintenanceInterval)
 	}
+	return commit, nil
 }
 
-// EnsureCheckout clones or fetches and checks out the specified ref
-func (c *ShellClient) EnsureCheckout(ctx context.Context, url, ref, destDir string) (string, error) {
+// checkout clones or fetches and checks out ref in destDir.
+func (c *ShellClient) checkout(ctx context.Context, url, ref, destDir string, opts CheckoutOptions) (string, error) {
 	// Check if repo already exists
 	gitDir := filepath.Join(destDir, ".git")
 	exists := false
@@ -41,32 +198,23 @@ func (c *ShellClient) EnsureCheckout(ctx context.Context, url, ref, destDir stri
 		exists = true
 	}
 
-	var cmd *exec.Cmd
 	if !exists {
 		// Clone the repository
 		if err := os.MkdirAll(filepath.Dir(destDir), 0755); err != nil {
 			return "", fmt.Errorf("failed to create parent directory: %w", err)
 		}
 
-		c.logger.Debug("cloning repository", "url", url, "dest", destDir)
-		cmd = exec.CommandContext(ctx, "git", "clone", "--no-checkout", url, destDir)
-		if err := c.configureAuth(cmd, url); err != nil {
-			return "", err
-		}
-
-		if err := c.runCommand(cmd); err != nil {
-			return "", fmt.Errorf("git clone failed: %w", err)
+		c.logger.Debug("cloning repository", "url", url, "dest", destDir, "depth", opts.Depth, "filter", opts.Filter)
+		args := append([]string{"clone", "--no-checkout"}, opts.cloneFlags()...)
+		if _, err := c.git(ctx, url, true, append(args, url, destDir)...); err != nil {
+			return "", newCommandError("clone", err)
 		}
 	} else {
 		// Fetch updates
 		c.logger.Debug("fetching updates", "url", url, "dest", destDir)
-		cmd = exec.CommandContext(ctx, "git", "-C", destDir, "fetch", "origin")
-		if err := c.configureAuth(cmd, url); err != nil {
-			return "", err
-		}
-
-		if err := c.runCommand(cmd); err != nil {
-			return "", fmt.Errorf("git fetch failed: %w", err)
+		args := append([]string{"-C", destDir, "fetch"}, opts.fetchFlags()...)
+		if _, err := c.git(ctx, url, true, append(args, "origin")...); err != nil {
+			return "", newCommandError("fetch", err)
 		}
 	}
 
@@ -75,14 +223,27 @@ func (c *ShellClient) EnsureCheckout(ctx context.Context, url, ref, destDir stri
 	// 1. Try direct checkout (works for local branches, tags, commit hashes)
 	// 2. If that fails, try as a remote branch (origin/ref)
 	// This handles tags and commit hashes correctly, and prefers local refs when they exist
+	// 3. In a shallow clone, a tag or commit older than the fetched depth is
+	//    missing locally, so fetch that ref on its own and check it out.
+	// In a partial clone, checkout downloads file contents and needs auth.
+	lazy := opts.Filter != ""
+	if err := c.configureSparse(ctx, url, destDir, opts.SparseDirs, exists, lazy); err != nil {
+		return "", err
+	}
+
 	c.logger.Debug("checking out ref", "ref", ref, "dest", destDir)
-	cmd = exec.CommandContext(ctx, "git", "-C", destDir, "checkout", "-f", ref)
-	if err := c.runCommand(cmd); err != nil {
+	if _, err := c.git(ctx, url, lazy, "-C", destDir, "checkout", "-f", ref); err != nil {
 		// If direct checkout failed, try as a remote branch
-		remoteRef := "origin/" + ref
-		cmd = exec.CommandContext(ctx, "git", "-C", destDir, "checkout", "-f", remoteRef)
-		if err := c.runCommand(cmd); err != nil {
-			return "", fmt.Errorf("git checkout failed for ref %q (tried both direct and remote): %w", ref, err)
+		if _, err := c.git(ctx, url, lazy, "-C", destDir, "checkout", "-f", "origin/"+ref); err != nil {
+			if opts.Depth <= 0 {
+				return "", fmt.Errorf("git checkout failed for ref %q (tried both direct and remote): %w", ref, err)
+			}
+			if err := c.fetchRef(ctx, url, destDir, ref, opts); err != nil {
+				return "", err
+			}
+			if _, err := c.git(ctx, url, lazy, "-C", destDir, "checkout", "-f", "FETCH_HEAD"); err != nil {
+				return "", fmt.Errorf("git checkout failed for ref %q (tried direct, remote and fetched): %w", ref, err)
+			}
 		}
 	}
 
@@ -90,15 +251,27 @@ func (c *ShellClient) EnsureCheckout(ctx context.Context, url, ref, destDir stri
 	// Reset to the remote tracking branch to pick up new commits.
 	// This is a no-op for fresh clones and silently ignored for tags/hashes.
 	if exists {
-		resetCmd := exec.CommandContext(ctx, "git", "-C", destDir, "reset", "--hard", "origin/"+ref)
-		if err := c.runCommand(resetCmd); err != nil {
+		if _, err := c.git(ctx, url, lazy, "-C", destDir, "reset", "--hard", "origin/"+ref); err != nil {
 			c.logger.Debug("reset to remote ref failed (expected for tags/hashes)", "ref", ref, "error", err)
 		}
 	}
 
+	if opts.Submodules {
+		c.logger.Debug("updating submodules", "dest", destDir)
+		args := []string{"-C", destDir, "submodule", "update", "--init", "--recursive", "--force"}
+		if opts.SubmoduleJobs > 1 {
+			args = append(args, "--jobs", strconv.Itoa(opts.SubmoduleJobs))
+		}
+		if len(opts.SparseDirs) > 0 {
+			args = append(append(args, "--"), opts.SparseDirs...)
+		}
+		if _, err := c.git(ctx, url, true, args...); err != nil {
+			return "", newCommandError("submodule update", err)
+		}
+	}
+
 	// Get the commit hash
-	cmd = exec.CommandContext(ctx, "git", "-C", destDir, "rev-parse", "HEAD")
-	output, err := cmd.Output()
+	output, err := c.git(ctx, url, false, "-C", destDir, "rev-parse", "HEAD")
 	if err != nil {
 		return "", fmt.Errorf("git rev-parse failed: %w", err)
 	}
@@ -107,37 +280,183 @@ func (c *ShellClient) EnsureCheckout(ctx context.Context, url, ref, destDir stri
 	return commit, nil
 }
 
+// ChangedFiles runs git diff --name-status between from and to. Renames are
+// reported as a deletion and an addition. Commits missing from a shallow
+// clone make it fail; callers then have to consider every file changed.
+func (c *ShellClient) ChangedFiles(ctx context.Context, destDir, from, to string) ([]string, error) {
+	out, err := c.git(ctx, "", false, "-C", destDir, "diff", "--name-status", "--no-renames", "-z", from, to, "--")
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %w", err)
+	}
+	return parseNameStatus(out)
+}
+
+// parseNameStatus parses the NUL-separated output of git diff --name-status
+// -z --no-renames: a status field followed by one path per change.
+func parseNameStatus(out []byte) ([]string, error) {
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	if len(fields) == 1 && fields[0] == "" {
+		return nil, nil
+	}
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("unexpected git diff output: %q", out)
+	}
+	paths := make([]string, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		if fields[i] == "" || fields[i+1] == "" {
+			return nil, fmt.Errorf("unexpected git diff output: %q", out)
+		}
+		paths = append(paths, fields[i+1])
+	}
+	return paths, nil
+}
+
+// maintenanceStamp is the file in a checkout's .git directory whose
+// modification time records the last maintenance run.
+const maintenanceStamp = "quadsyncd-maintenance"
+
+// maintain prunes remote-tracking branches deleted upstream and lets git
+// pack loose objects, unless that already happened within interval. Failures
+// are logged and leave the checkout usable.
+func (c *ShellClient) maintain(ctx context.Context, url, destDir string, interval time.Duration) {
+	stamp := filepath.Join(destDir, ".git", maintenanceStamp)
+	if info, err := os.Stat(stamp); err == nil && time.Since(info.ModTime()) < interval {
+		return
+	}
+	c.logger.Debug("running git maintenance", "dest", destDir)
+	if _, err := c.git(ctx, url, true, "-C", destDir, "remote", "prune", "origin"); err != nil {
+		c.logger.Warn("git remote prune failed", "dest", destDir, "error", err)
+		return
+	}
+	if _, err := c.git(ctx, url, false, "-C", destDir, "gc", "--auto", "--quiet"); err != nil {
+		c.logger.Warn("git gc failed", "dest", destDir, "error", err)
+		return
+	}
+	if err := os.WriteFile(stamp, nil, 0644); err != nil {
+		c.logger.Warn("failed to record git maintenance", "dest", destDir, "error", err)
+	}
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// configureSparse applies dirs as the sparse-checkout cone of the checkout
+// in destDir, or turns sparse checkout off again for an existing checkout
+// when dirs is empty. It runs before checkout so files outside the cone are
+// never written (and, in a partial clone, never downloaded).
+func (c *ShellClient) configureSparse(ctx context.Context, url, destDir string, dirs []string, exists, withAuth bool) error {
+	if len(dirs) > 0 {
+		c.logger.Debug("configuring sparse checkout", "dest", destDir, "dirs", dirs)
+		args := append([]string{"-C", destDir, "sparse-checkout", "set", "--cone"}, dirs...)
+		if _, err := c.git(ctx, url, withAuth, args...); err != nil {
+			return fmt.Errorf("git sparse-checkout set failed: %w", err)
+		}
+		return nil
+	}
+	if !exists {
+		return nil
+	}
+	out, err := c.git(ctx, url, false, "-C", destDir, "config", "--get", "core.sparseCheckout")
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		// Unset (exit status 1) or false: the checkout is not sparse.
+		return nil
+	}
+	c.logger.Debug("disabling sparse checkout", "dest", destDir)
+	if _, err := c.git(ctx, url, withAuth, "-C", destDir, "sparse-checkout", "disable"); err != nil {
+		return fmt.Errorf("git sparse-checkout disable failed: %w", err)
+	}
+	return nil
+}
+
+// fetchRef fetches a single ref (branch, tag or commit) into FETCH_HEAD at
+// the configured depth.
+func (c *ShellClient) fetchRef(ctx context.Context, url, destDir, ref string, opts CheckoutOptions) error {
+	c.logger.Debug("fetching ref outside shallow history", "ref", ref, "dest", destDir)
+	args := append([]string{"-C", destDir, "fetch"}, opts.fetchFlags()...)
+	if _, err := c.git(ctx, url, true, append(args, "origin", ref)...); err != nil {
+		return newCommandError("fetch", err)
+	}
+	return nil
+}
+
+// git runs git with args under the client's per-command timeout and returns
+// its stdout. withAuth configures credentials for url. On failure the error
+// includes the combined output, and wraps cmdexec.ErrTimeout if the command
+// was killed for running too long.
+func (c *ShellClient) git(ctx context.Context, url string, withAuth bool, args ...string) ([]byte, error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	cmd := cmdexec.Command(ctx, "git", args...)
+	if withAuth {
+		if err := c.configureAuth(ctx, cmd, url); err != nil {
+			return nil, err
+		}
+	}
+	out := cmdexec.Capture(cmd)
+	if err := cmd.Run(); err != nil {
+		return nil, cmdexec.Err(ctx, c.timeout, fmt.Errorf("%w: %s", err, out.Combined()))
+	}
+	return out.Stdout(), nil
+}
+
 // configureAuth sets up authentication for git operations
-func (c *ShellClient) configureAuth(cmd *exec.Cmd, url string) error {
+func (c *ShellClient) configureAuth(ctx context.Context, cmd *exec.Cmd, url string) error {
 	if cmd.Env == nil {
 		cmd.Env = os.Environ()
 	}
 
-	// SSH authentication
-	if c.sshKeyFile != "" && (strings.HasPrefix(url, "git@") || strings.HasPrefix(url, "ssh://")) {
-		// Use GIT_SSH_COMMAND to specify the SSH key.
-		// The path is shell-quoted to prevent injection via crafted filenames.
-		sshCmd := fmt.Sprintf("ssh -i %s -o StrictHostKeyChecking=accept-new -F /dev/null", shellQuote(c.sshKeyFile))
-		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+sshCmd)
+	// SSH authentication and host key policy
+	if isSSHURL(url) && (c.sshKeyFile != "" || c.sshKnownHostsFile != "" || c.sshStrictHostKey != "") {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+c.sshCommand())
 		return nil
 	}
 
 	// HTTPS authentication with token
-	if c.httpsTokenFile != "" && strings.HasPrefix(url, "https://") {
-		token, err := os.ReadFile(c.httpsTokenFile)
+	if (c.httpsTokenFile != "" || c.httpsTokenCommand != "") && strings.HasPrefix(url, "https://") {
+		tokenStr, err := c.httpsToken(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to read HTTPS token file: %w", err)
+			return err
 		}
 
-		tokenStr := strings.TrimSpace(string(token))
+		username := c.httpsUsername
+		if username == "" {
+			username = defaultHTTPSUsername
+		}
+
+		origin, err := credentialScope(url)
+		if err != nil {
+			return err
+		}
 
-		// Pass the token via environment variable and configure a git
-		// credential helper that reads it. This avoids embedding the
-		// token directly in a shell expression.
+		// Pass the credentials via environment variables and configure a
+		// git credential helper that reads them. This avoids embedding
+		// them directly in a shell expression. The helper only answers for
+		// the repository's origin, so submodules on other hosts, named by
+		// the repository's .gitmodules, never see the token; the empty
+		// helper drops the user's own helpers.
 		cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0")
+		cmd.Env = append(cmd.Env, "QUADSYNCD_GIT_USERNAME="+username)
 		cmd.Env = append(cmd.Env, "QUADSYNCD_GIT_TOKEN="+tokenStr)
 		cmd.Args = insertGitFlags(cmd.Args,
-			"-c", `credential.helper=!f() { echo "username=x-access-token"; echo "password=$QUADSYNCD_GIT_TOKEN"; }; f`,
+			"-c", "credential.helper=",
+			"-c", "credential."+origin+`.helper=!f() { echo "username=$QUADSYNCD_GIT_USERNAME"; echo "password=$QUADSYNCD_GIT_TOKEN"; }; f`,
 		)
 
 		return nil
@@ -146,6 +465,72 @@ func (c *ShellClient) configureAuth(cmd *exec.Cmd, url string) error {
 	return nil
 }
 
+// credentialScope returns the scheme and host of the HTTPS URL url, the
+// part a credential.<url>.helper setting is matched against.
+func credentialScope(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid repository URL %q", rawURL)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// sshCommand builds the GIT_SSH_COMMAND for SSH remotes. Paths are
+// shell-quoted to prevent injection via crafted filenames. With a key file the
+// user's ssh config is ignored so that no other identity is tried.
+func (c *ShellClient) sshCommand() string {
+	strict := c.sshStrictHostKey
+	if strict == "" {
+		strict = defaultStrictHostKeyChecking
+	}
+	parts := []string{"ssh"}
+	if c.sshKeyFile != "" {
+		parts = append(parts, "-i", shellQuote(c.sshKeyFile))
+	}
+	parts = append(parts, "-o", "StrictHostKeyChecking="+strict)
+	if c.sshKnownHostsFile != "" {
+		parts = append(parts, "-o", shellQuote("UserKnownHostsFile="+c.sshKnownHostsFile))
+	}
+	if c.sshKeyFile != "" {
+		parts = append(parts, "-F", "/dev/null")
+	}
+	return strings.Join(parts, " ")
+}
+
+// httpsToken returns the HTTPS token, either by running the configured token
+// command or by reading the token file.
+func (c *ShellClient) httpsToken(ctx context.Context) (string, error) {
+	if c.httpsTokenCommand != "" {
+		return runTokenCommand(ctx, c.httpsTokenCommand)
+	}
+	token, err := os.ReadFile(c.httpsTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read HTTPS token file: %w", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// runTokenCommand executes command through the shell and returns its trimmed
+// stdout. Stdout is never included in errors so a partially printed token
+// cannot leak into logs.
+func runTokenCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tokenCommandTimeout)
+	defer cancel()
+
+	cmd := cmdexec.Command(ctx, "sh", "-c", command)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("https_token_command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("https_token_command produced an empty token")
+	}
+	return token, nil
+}
+
 // insertGitFlags inserts flags immediately after the "git" command name,
 // before the subcommand (e.g. "clone", "fetch").
 func insertGitFlags(args []string, flags ...string) []string {
@@ -163,12 +548,3 @@ func insertGitFlags(args []string, flags ...string) []string {
 func shellQuote(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
-
-// runCommand executes a command and returns an error with stderr on failure
-func (c *ShellClient) runCommand(cmd *exec.Cmd) error {
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%w: %s", err, string(output))
-	}
-	return nil
-}
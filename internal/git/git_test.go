@@ -2,12 +2,17 @@ package git
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
 )
 
 // testLogger returns a discard logger suitable for tests.
@@ -58,7 +63,7 @@ func TestEnsureCheckout_UpdatesLocalBranch(t *testing.T) {
 	// First checkout: clones the repo.
 	cloneDir := filepath.Join(t.TempDir(), "repo")
 	client := NewShellClient("", "", testLogger())
-	commit1, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir)
+	commit1, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{})
 	if err != nil {
 		t.Fatalf("first checkout: %v", err)
 	}
@@ -75,7 +80,7 @@ func TestEnsureCheckout_UpdatesLocalBranch(t *testing.T) {
 	commitFile(t, remoteDir, "version2\n", "Update")
 
 	// Second checkout: must pick up the new commit.
-	commit2, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir)
+	commit2, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{})
 	if err != nil {
 		t.Fatalf("second checkout: %v", err)
 	}
@@ -92,6 +97,94 @@ func TestEnsureCheckout_UpdatesLocalBranch(t *testing.T) {
 	}
 }
 
+func TestChangedFiles(t *testing.T) {
+	ctx := context.Background()
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	if err := os.WriteFile(filepath.Join(remoteDir, "old.container"), []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, remoteDir, "version1\n", "Initial commit")
+	for _, args := range [][]string{
+		{"git", "-C", remoteDir, "add", "old.container"},
+		{"git", "-C", remoteDir, "commit", "-m", "Add old"},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %s", err, out)
+		}
+	}
+
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	client := NewShellClient("", "", testLogger())
+	commit1, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{})
+	if err != nil {
+		t.Fatalf("first checkout: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(remoteDir, "sub dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remoteDir, "sub dir", "new.container"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"git", "-C", remoteDir, "rm", "-q", "old.container"},
+		{"git", "-C", remoteDir, "add", "sub dir/new.container"},
+		{"git", "-C", remoteDir, "commit", "-m", "Add new, remove old"},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %s", err, out)
+		}
+	}
+	commitFile(t, remoteDir, "version2\n", "Update")
+	commit2, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{})
+	if err != nil {
+		t.Fatalf("second checkout: %v", err)
+	}
+
+	got, err := client.ChangedFiles(ctx, cloneDir, commit1, commit2)
+	if err != nil {
+		t.Fatalf("ChangedFiles() error = %v", err)
+	}
+	want := []string{"hello.container", "old.container", "sub dir/new.container"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFiles() = %q, want %q", got, want)
+	}
+
+	if got, err := client.ChangedFiles(ctx, cloneDir, commit2, commit2); err != nil || len(got) != 0 {
+		t.Errorf("ChangedFiles(same commit) = %q, %v; want no changes", got, err)
+	}
+	if _, err := client.ChangedFiles(ctx, cloneDir, strings.Repeat("0", 40), commit2); err == nil {
+		t.Error("ChangedFiles() with an unknown commit should fail")
+	}
+}
+
+func TestParseNameStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", out: ""},
+		{name: "changes", out: "M\x00a.container\x00D\x00b c.container\x00A\x00d/e.volume\x00", want: []string{"a.container", "b c.container", "d/e.volume"}},
+		{name: "newline in path", out: "A\x00x\ny\x00", want: []string{"x\ny"}},
+		{name: "truncated", out: "M\x00", wantErr: true},
+		{name: "empty path", out: "M\x00\x00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNameStatus([]byte(tt.out))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNameStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseNameStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestEnsureCheckout_TagsStillWork(t *testing.T) {
 	ctx := context.Background()
 
@@ -109,7 +202,7 @@ func TestEnsureCheckout_TagsStillWork(t *testing.T) {
 	// Checkout the tag.
 	cloneDir := filepath.Join(t.TempDir(), "repo")
 	client := NewShellClient("", "", testLogger())
-	_, err := client.EnsureCheckout(ctx, remoteDir, "v1.0", cloneDir)
+	_, err := client.EnsureCheckout(ctx, remoteDir, "v1.0", cloneDir, CheckoutOptions{})
 	if err != nil {
 		t.Fatalf("tag checkout: %v", err)
 	}
@@ -212,7 +305,7 @@ func TestConfigureAuth_SSH(t *testing.T) {
 	client := &ShellClient{sshKeyFile: "/tmp/test-key", logger: testLogger()}
 	cmd := exec.Command("git", "clone", "git@github.com:user/repo.git", "/dest")
 
-	if err := client.configureAuth(cmd, "git@github.com:user/repo.git"); err != nil {
+	if err := client.configureAuth(context.Background(), cmd, "git@github.com:user/repo.git"); err != nil {
 		t.Fatalf("configureAuth() error = %v", err)
 	}
 
@@ -234,7 +327,7 @@ func TestConfigureAuth_HTTPS(t *testing.T) {
 	client := &ShellClient{httpsTokenFile: tokenFile, logger: testLogger()}
 	cmd := exec.Command("git", "clone", "https://github.com/user/repo.git", "/dest")
 
-	if err := client.configureAuth(cmd, "https://github.com/user/repo.git"); err != nil {
+	if err := client.configureAuth(context.Background(), cmd, "https://github.com/user/repo.git"); err != nil {
 		t.Fatalf("configureAuth() error = %v", err)
 	}
 
@@ -250,16 +343,88 @@ func TestConfigureAuth_HTTPS(t *testing.T) {
 		t.Errorf("QUADSYNCD_GIT_TOKEN = %q, want %q", tokenVal, "example-token-value")
 	}
 
-	// Verify git flags were inserted: cmd.Args should contain "-c" and a credential.helper value.
-	foundCredHelper := false
-	for i, a := range cmd.Args {
-		if a == "-c" && i+1 < len(cmd.Args) && strings.HasPrefix(cmd.Args[i+1], "credential.helper=") {
-			foundCredHelper = true
-			break
-		}
+	// The user's helpers are reset and ours is scoped to the origin.
+	if len(cmd.Args) < 5 || cmd.Args[1] != "-c" || cmd.Args[2] != "credential.helper=" || cmd.Args[3] != "-c" ||
+		!strings.HasPrefix(cmd.Args[4], "credential.https://github.com.helper=!") {
+		t.Errorf("expected -c credential.helper= -c credential.https://github.com.helper=... in cmd.Args, got %v", cmd.Args)
 	}
-	if !foundCredHelper {
-		t.Errorf("expected -c credential.helper=... in cmd.Args, got %v", cmd.Args)
+}
+
+func TestConfigureAuth_HTTPSScopedToOrigin(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("example-token-value\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client := &ShellClient{httpsTokenFile: tokenFile, logger: testLogger()}
+
+	tests := []struct {
+		host      string
+		wantToken bool
+	}{
+		{host: "git.example.com", wantToken: true},
+		{host: "evil.example.net"}, // e.g. a submodule URL from .gitmodules
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			cmd := exec.Command("git", "credential", "fill")
+			if err := client.configureAuth(context.Background(), cmd, "https://git.example.com/org/repo.git"); err != nil {
+				t.Fatalf("configureAuth() error = %v", err)
+			}
+			cmd.Env = append(cmd.Env, "HOME="+t.TempDir(), "GIT_CONFIG_NOSYSTEM=1", "GIT_ASKPASS=", "SSH_ASKPASS=")
+			cmd.Stdin = strings.NewReader("protocol=https\nhost=" + tt.host + "\npath=org/repo.git\n\n")
+			out, _ := cmd.Output()
+			if got := strings.Contains(string(out), "password=example-token-value"); got != tt.wantToken {
+				t.Errorf("token handed to %s = %v, want %v (output %q)", tt.host, got, tt.wantToken, out)
+			}
+		})
+	}
+}
+
+func TestConfigureAuth_HTTPSUsername(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		want     string
+	}{
+		{name: "default", want: "username=x-access-token\npassword=s3cret\n"},
+		{name: "configured", username: "deploy bot", want: "username=deploy bot\npassword=s3cret\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &ShellClient{httpsTokenFile: tokenFile, httpsUsername: tt.username, logger: testLogger()}
+			cmd := exec.Command("git", "fetch", "origin")
+			if err := client.configureAuth(context.Background(), cmd, "https://git.example.com/repo.git"); err != nil {
+				t.Fatalf("configureAuth() error = %v", err)
+			}
+
+			// Run the credential helper the way git would.
+			var helper string
+			for i, a := range cmd.Args {
+				if a == "-c" && i+1 < len(cmd.Args) && strings.HasPrefix(cmd.Args[i+1], "credential.https://git.example.com.helper=!") {
+					helper = strings.TrimPrefix(cmd.Args[i+1], "credential.https://git.example.com.helper=!")
+				}
+			}
+			if helper == "" {
+				t.Fatalf("no credential helper in %v", cmd.Args)
+			}
+			sh := exec.Command("sh", "-c", helper)
+			sh.Env = cmd.Env
+			out, err := sh.Output()
+			if err != nil {
+				t.Fatalf("credential helper: %v", err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("credential helper output = %q, want %q", out, tt.want)
+			}
+		})
 	}
 }
 
@@ -267,7 +432,7 @@ func TestConfigureAuth_NoAuth(t *testing.T) {
 	client := &ShellClient{logger: testLogger()}
 	cmd := exec.Command("git", "clone", "https://github.com/user/repo.git", "/dest")
 
-	if err := client.configureAuth(cmd, "https://github.com/user/repo.git"); err != nil {
+	if err := client.configureAuth(context.Background(), cmd, "https://github.com/user/repo.git"); err != nil {
 		t.Fatalf("configureAuth() error = %v", err)
 	}
 
@@ -287,7 +452,7 @@ func TestConfigureAuth_HTTPSTokenReadError(t *testing.T) {
 	client := &ShellClient{httpsTokenFile: filepath.Join(t.TempDir(), "nonexistent"), logger: testLogger()}
 	cmd := exec.Command("git", "clone", "https://github.com/user/repo.git", "/dest")
 
-	err := client.configureAuth(cmd, "https://github.com/user/repo.git")
+	err := client.configureAuth(context.Background(), cmd, "https://github.com/user/repo.git")
 	if err == nil {
 		t.Fatal("expected error when token file does not exist")
 	}
@@ -300,7 +465,7 @@ func TestConfigureAuth_SSHWithHTTPSURL(t *testing.T) {
 	client := &ShellClient{sshKeyFile: "/tmp/test-key", logger: testLogger()}
 	cmd := exec.Command("git", "clone", "https://github.com/user/repo.git", "/dest")
 
-	if err := client.configureAuth(cmd, "https://github.com/user/repo.git"); err != nil {
+	if err := client.configureAuth(context.Background(), cmd, "https://github.com/user/repo.git"); err != nil {
 		t.Fatalf("configureAuth() error = %v", err)
 	}
 
@@ -318,7 +483,7 @@ func TestConfigureAuth_HTTPSWithSSHURL(t *testing.T) {
 	client := &ShellClient{httpsTokenFile: tokenFile, logger: testLogger()}
 	cmd := exec.Command("git", "clone", "git@github.com:user/repo.git", "/dest")
 
-	if err := client.configureAuth(cmd, "git@github.com:user/repo.git"); err != nil {
+	if err := client.configureAuth(context.Background(), cmd, "git@github.com:user/repo.git"); err != nil {
 		t.Fatalf("configureAuth() error = %v", err)
 	}
 
@@ -329,3 +494,453 @@ func TestConfigureAuth_HTTPSWithSSHURL(t *testing.T) {
 		t.Error("GIT_TERMINAL_PROMPT should not be set for SSH URL with HTTPS-only auth")
 	}
 }
+
+func TestConfigureAuth_HTTPSTokenCommand(t *testing.T) {
+	client := NewShellClientWithAuth(AuthOptions{HTTPSTokenCommand: "printf 'fresh-token\\n'"}, testLogger())
+	cmd := exec.Command("git", "fetch", "origin")
+
+	if err := client.configureAuth(context.Background(), cmd, "https://github.com/user/repo.git"); err != nil {
+		t.Fatalf("configureAuth() error = %v", err)
+	}
+
+	tokenVal, ok := envValue(cmd.Env, "QUADSYNCD_GIT_TOKEN")
+	if !ok {
+		t.Fatal("expected QUADSYNCD_GIT_TOKEN in env")
+	}
+	if tokenVal != "fresh-token" {
+		t.Errorf("QUADSYNCD_GIT_TOKEN = %q, want %q", tokenVal, "fresh-token")
+	}
+}
+
+func TestConfigureAuth_HTTPSTokenCommandErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		command string
+		wantErr string
+	}{
+		{name: "non-zero exit", command: "echo secret-ish; echo boom >&2; exit 3", wantErr: "boom"},
+		{name: "empty output", command: "true", wantErr: "empty token"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := NewShellClientWithAuth(AuthOptions{HTTPSTokenCommand: tc.command}, testLogger())
+			cmd := exec.Command("git", "fetch", "origin")
+
+			err := client.configureAuth(context.Background(), cmd, "https://github.com/user/repo.git")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tc.wantErr)
+			}
+			if strings.Contains(err.Error(), "secret-ish") {
+				t.Errorf("error must not include command stdout: %q", err)
+			}
+		})
+	}
+}
+
+// gitOutput runs git with args and returns its trimmed stdout.
+func gitOutput(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestEnsureCheckout_Shallow(t *testing.T) {
+	ctx := context.Background()
+
+	// History: v1.0 tag, two more commits on main, and a stable branch.
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	commitFile(t, remoteDir, "tagged\n", "Tagged commit")
+	gitOutput(t, "-C", remoteDir, "tag", "v1.0")
+	commitFile(t, remoteDir, "stable\n", "Stable commit")
+	gitOutput(t, "-C", remoteDir, "branch", "stable")
+	commitFile(t, remoteDir, "main\n", "Main commit")
+
+	// file:// makes git honour --depth for a local remote.
+	url := "file://" + remoteDir
+	opts := CheckoutOptions{Depth: 1}
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	client := NewShellClient("", "", testLogger())
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{ref: "main", want: "main\n"},
+		{ref: "stable", want: "stable\n"}, // branch switch
+		{ref: "v1.0", want: "tagged\n"},   // tag older than the fetched depth
+		{ref: "main", want: "main\n"},
+	}
+	for _, tt := range tests {
+		if _, err := client.EnsureCheckout(ctx, url, tt.ref, cloneDir, opts); err != nil {
+			t.Fatalf("checkout %s: %v", tt.ref, err)
+		}
+		got, err := os.ReadFile(filepath.Join(cloneDir, "hello.container"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("checkout %s: got %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+
+	if got := gitOutput(t, "-C", cloneDir, "rev-parse", "--is-shallow-repository"); got != "true" {
+		t.Errorf("expected a shallow clone, is-shallow-repository = %s", got)
+	}
+	if got := gitOutput(t, "-C", cloneDir, "rev-list", "--count", "origin/main"); got != "1" {
+		t.Errorf("expected 1 commit of main history, got %s", got)
+	}
+}
+
+func TestEnsureCheckout_Blobless(t *testing.T) {
+	ctx := context.Background()
+
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	gitOutput(t, "-C", remoteDir, "config", "uploadpack.allowFilter", "true")
+	commitFile(t, remoteDir, "version1\n", "Initial commit")
+
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	client := NewShellClient("", "", testLogger())
+	opts := CheckoutOptions{Filter: "blob:none"}
+	if _, err := client.EnsureCheckout(ctx, "file://"+remoteDir, "main", cloneDir, opts); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+
+	if got := gitOutput(t, "-C", cloneDir, "config", "remote.origin.partialclonefilter"); got != "blob:none" {
+		t.Errorf("partialclonefilter = %q, want blob:none", got)
+	}
+
+	commitFile(t, remoteDir, "version2\n", "Update")
+	if _, err := client.EnsureCheckout(ctx, "file://"+remoteDir, "main", cloneDir, opts); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(cloneDir, "hello.container"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "version2\n" {
+		t.Errorf("expected version2 after update, got %q", got)
+	}
+}
+
+func TestEnsureCheckout_Sparse(t *testing.T) {
+	ctx := context.Background()
+
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	for _, dir := range []string{"quadlets", "app"} {
+		if err := os.MkdirAll(filepath.Join(remoteDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(remoteDir, "quadlets", "web.container"), []byte("[Container]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remoteDir, "app", "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, "-C", remoteDir, "add", "quadlets", "app")
+	commitFile(t, remoteDir, "root\n", "Initial commit")
+
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	client := NewShellClient("", "", testLogger())
+	exists := func(rel string) bool {
+		_, err := os.Stat(filepath.Join(cloneDir, rel))
+		return err == nil
+	}
+
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{SparseDirs: []string{"quadlets"}}); err != nil {
+		t.Fatalf("sparse checkout: %v", err)
+	}
+	if !exists("quadlets/web.container") || !exists("hello.container") {
+		t.Error("sparse checkout should contain the subdir and root files")
+	}
+	if exists("app") {
+		t.Error("sparse checkout should not materialize directories outside the subdir")
+	}
+
+	// Dropping the subdir restores the full tree.
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{}); err != nil {
+		t.Fatalf("full checkout: %v", err)
+	}
+	if !exists("app/main.go") {
+		t.Error("disabling sparse checkout should materialize the whole tree")
+	}
+}
+
+func TestEnsureCheckout_Submodules(t *testing.T) {
+	ctx := context.Background()
+	// Local submodule URLs use the file transport, which git disables for
+	// submodules by default.
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	fragDir := t.TempDir()
+	initBareRepo(t, fragDir, "main")
+	commitFile(t, fragDir, "fragment v1\n", "Fragment")
+
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	gitOutput(t, "-C", remoteDir, "submodule", "add", fragDir, "quadlets/fragments")
+	gitOutput(t, "-C", remoteDir, "submodule", "add", fragDir, "app/vendor")
+	commitFile(t, remoteDir, "root\n", "Add submodules")
+
+	client := NewShellClient("", "", testLogger())
+	fragment := func(cloneDir string) string {
+		data, err := os.ReadFile(filepath.Join(cloneDir, "quadlets", "fragments", "hello.container"))
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	plainDir := filepath.Join(t.TempDir(), "plain")
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", plainDir, CheckoutOptions{}); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if got := fragment(plainDir); got != "" {
+		t.Errorf("submodules should not be checked out by default, got %q", got)
+	}
+
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	opts := CheckoutOptions{SparseDirs: []string{"quadlets"}, Submodules: true, SubmoduleJobs: 2}
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, opts); err != nil {
+		t.Fatalf("checkout with submodules: %v", err)
+	}
+	if got := fragment(cloneDir); got != "fragment v1\n" {
+		t.Errorf("fragment = %q, want v1", got)
+	}
+	if _, err := os.Stat(filepath.Join(cloneDir, "app", "vendor", "hello.container")); err == nil {
+		t.Error("submodules outside the sparse directories should not be checked out")
+	}
+
+	// Bumping the submodule in the parent repo updates the checkout.
+	commitFile(t, fragDir, "fragment v2\n", "Fragment v2")
+	gitOutput(t, "-C", filepath.Join(remoteDir, "quadlets", "fragments"), "pull", "-q", "origin", "main")
+	gitOutput(t, "-C", remoteDir, "add", "quadlets/fragments")
+	gitOutput(t, "-C", remoteDir, "commit", "-q", "-m", "Bump fragments")
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, opts); err != nil {
+		t.Fatalf("update with submodules: %v", err)
+	}
+	if got := fragment(cloneDir); got != "fragment v2\n" {
+		t.Errorf("fragment = %q, want v2", got)
+	}
+}
+
+func TestEnsureCheckout_RecloneOnCorruption(t *testing.T) {
+	ctx := context.Background()
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	commitFile(t, remoteDir, "v1\n", "Initial")
+
+	client := NewShellClient("", "", testLogger())
+	for _, tc := range []struct {
+		name   string
+		damage func(t *testing.T, cloneDir string)
+		keep   bool
+	}{
+		{
+			// An emptied HEAD makes git no longer recognize the checkout.
+			name: "broken HEAD",
+			damage: func(t *testing.T, cloneDir string) {
+				if err := os.WriteFile(filepath.Join(cloneDir, ".git", "HEAD"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "kept when disabled",
+			damage: func(t *testing.T, cloneDir string) {
+				if err := os.WriteFile(filepath.Join(cloneDir, ".git", "HEAD"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			keep: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cloneDir := filepath.Join(t.TempDir(), "repo")
+			opts := CheckoutOptions{KeepCorrupt: tc.keep}
+			if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, opts); err != nil {
+				t.Fatalf("initial checkout: %v", err)
+			}
+			tc.damage(t, cloneDir)
+
+			commit, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, opts)
+			if tc.keep {
+				if err == nil || ClassifyOutput(err.Error()) != FailureCorrupt {
+					t.Fatalf("EnsureCheckout() error = %v, want a corrupt checkout failure", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EnsureCheckout() error = %v, want the checkout cloned again", err)
+			}
+			if want := gitOutput(t, "-C", remoteDir, "rev-parse", "HEAD"); commit != want {
+				t.Errorf("commit = %s, want %s", commit, want)
+			}
+		})
+	}
+}
+
+func TestEnsureCheckout_KeepsLockedCheckout(t *testing.T) {
+	ctx := context.Background()
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	commitFile(t, remoteDir, "v1\n", "Initial")
+
+	client := NewShellClient("", "", testLogger())
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{}); err != nil {
+		t.Fatalf("initial checkout: %v", err)
+	}
+	// The lock of a git process still working on the checkout, e.g. a
+	// timer sync overlapping the server's.
+	lock := filepath.Join(cloneDir, ".git", "index.lock")
+	if err := os.WriteFile(lock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, remoteDir, "v2\n", "Second")
+
+	_, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{})
+	if err == nil || KindOf(err) == FailureCorrupt {
+		t.Fatalf("EnsureCheckout() error = %v, want a failure that is not corrupt", err)
+	}
+	if _, err := os.Stat(lock); err != nil {
+		t.Errorf("checkout was removed: %v", err)
+	}
+}
+
+func TestEnsureCheckout_MaxSize(t *testing.T) {
+	ctx := context.Background()
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	commitFile(t, remoteDir, "v1\n", "Initial")
+
+	client := NewShellClient("", "", testLogger())
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{}); err != nil {
+		t.Fatalf("initial checkout: %v", err)
+	}
+	marker := filepath.Join(cloneDir, ".git", "leftover")
+	if err := os.WriteFile(marker, make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Within the limit the checkout is kept.
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{MaxSize: 1 << 30}); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("checkout within the size limit was replaced: %v", err)
+	}
+	// Beyond it the checkout is cloned again.
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, CheckoutOptions{MaxSize: 1024}); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("oversized checkout was kept, stat err = %v", err)
+	}
+}
+
+func TestEnsureCheckout_Maintenance(t *testing.T) {
+	ctx := context.Background()
+	remoteDir := t.TempDir()
+	initBareRepo(t, remoteDir, "main")
+	commitFile(t, remoteDir, "v1\n", "Initial")
+	gitOutput(t, "-C", remoteDir, "branch", "feature")
+
+	client := NewShellClient("", "", testLogger())
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	opts := CheckoutOptions{MaintenanceInterval: time.Hour}
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, opts); err != nil {
+		t.Fatalf("initial checkout: %v", err)
+	}
+	stamp := filepath.Join(cloneDir, ".git", maintenanceStamp)
+	if _, err := os.Stat(stamp); err != nil {
+		t.Fatalf("maintenance not recorded: %v", err)
+	}
+
+	// A branch deleted upstream stays until the next due maintenance.
+	gitOutput(t, "-C", remoteDir, "branch", "-D", "feature")
+	hasFeature := func() bool {
+		return gitOutput(t, "-C", cloneDir, "branch", "-r", "--list", "origin/feature") != ""
+	}
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, opts); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if !hasFeature() {
+		t.Fatal("maintenance ran again within its interval")
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stamp, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.EnsureCheckout(ctx, remoteDir, "main", cloneDir, opts); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if hasFeature() {
+		t.Error("due maintenance did not prune the deleted branch")
+	}
+}
+
+func TestCheckoutOptionsFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      CheckoutOptions
+		wantClone []string
+		wantFetch []string
+	}{
+		{name: "full", opts: CheckoutOptions{}},
+		{
+			name:      "shallow",
+			opts:      CheckoutOptions{Depth: 5},
+			wantClone: []string{"--depth", "5", "--no-single-branch"},
+			wantFetch: []string{"--depth", "5"},
+		},
+		{
+			name:      "blobless",
+			opts:      CheckoutOptions{Filter: "blob:none"},
+			wantClone: []string{"--filter=blob:none"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.cloneFlags(); !reflect.DeepEqual(got, tt.wantClone) {
+				t.Errorf("cloneFlags() = %v, want %v", got, tt.wantClone)
+			}
+			if got := tt.opts.fetchFlags(); !reflect.DeepEqual(got, tt.wantFetch) {
+				t.Errorf("fetchFlags() = %v, want %v", got, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestEnsureCheckout_TimeoutKillsHungGit(t *testing.T) {
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nsleep 30\n"
+	if err := os.WriteFile(filepath.Join(binDir, "git"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	client := NewShellClientWithTimeout(AuthOptions{}, 100*time.Millisecond, testLogger())
+	start := time.Now()
+	_, err := client.EnsureCheckout(context.Background(), "https://example.invalid/repo.git", "main", filepath.Join(t.TempDir(), "repo"), CheckoutOptions{})
+	if !errors.Is(err, cmdexec.ErrTimeout) {
+		t.Fatalf("error = %v, want ErrTimeout", err)
+	}
+	if KindOf(err) != FailureNetwork {
+		t.Errorf("KindOf = %q, want %q", KindOf(err), FailureNetwork)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("EnsureCheckout took %s, the timeout did not kill git", elapsed)
+	}
+}
@@ -0,0 +1,163 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+)
+
+// keyscanTimeout bounds how long ssh-keyscan may wait for a host.
+const keyscanTimeout = 30 * time.Second
+
+// HostKey is one public host key as recorded in a known_hosts file.
+type HostKey struct {
+	// Host is the known_hosts host pattern: "host" or "[host]:port".
+	Host string
+	// Type is the key algorithm, e.g. "ssh-ed25519".
+	Type string
+	// Key is the base64-encoded public key.
+	Key string
+}
+
+// Line returns the key as a known_hosts line.
+func (k HostKey) Line() string {
+	return k.Host + " " + k.Type + " " + k.Key
+}
+
+// Fingerprint returns the SHA256 fingerprint in the format printed by
+// ssh-keygen -l, e.g. "SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU".
+func (k HostKey) Fingerprint() (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(k.Key)
+	if err != nil {
+		return "", fmt.Errorf("invalid host key for %s: %w", k.Host, err)
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// isSSHURL reports whether url uses the scp-like git@host:path syntax or the
+// ssh:// scheme.
+func isSSHURL(url string) bool {
+	return strings.HasPrefix(url, "git@") || strings.HasPrefix(url, "ssh://")
+}
+
+// SSHHost returns the host and port of an SSH repository URL. Port is 22
+// unless an ssh:// URL names another one.
+func SSHHost(repoURL string) (host string, port int, err error) {
+	switch {
+	case strings.HasPrefix(repoURL, "ssh://"):
+		u, err := url.Parse(repoURL)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid SSH URL %q: %w", repoURL, err)
+		}
+		port = 22
+		if p := u.Port(); p != "" {
+			if port, err = strconv.Atoi(p); err != nil {
+				return "", 0, fmt.Errorf("invalid port in %q: %w", repoURL, err)
+			}
+		}
+		host = u.Hostname()
+	case strings.HasPrefix(repoURL, "git@"):
+		rest := strings.TrimPrefix(repoURL, "git@")
+		host, _, _ = strings.Cut(rest, ":")
+		port = 22
+	default:
+		return "", 0, fmt.Errorf("not an SSH URL: %s", repoURL)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("no host in SSH URL: %s", repoURL)
+	}
+	return host, port, nil
+}
+
+// ScanHostKeys fetches the public host keys of host with ssh-keyscan.
+func ScanHostKeys(ctx context.Context, host string, port int) ([]HostKey, error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, keyscanTimeout)
+	defer cancel()
+
+	cmd := cmdexec.Command(ctx, "ssh-keyscan", "-p", strconv.Itoa(port), "--", host)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, cmdexec.Err(ctx, keyscanTimeout, fmt.Errorf("ssh-keyscan %s failed: %w: %s", host, err, strings.TrimSpace(stderr.String())))
+	}
+	keys := parseKnownHosts(out)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("ssh-keyscan returned no host keys for %s: %s", host, strings.TrimSpace(stderr.String()))
+	}
+	return keys, nil
+}
+
+// parseKnownHosts parses known_hosts formatted lines, skipping comments,
+// blank lines and markers such as @cert-authority.
+func parseKnownHosts(data []byte) []HostKey {
+	var keys []HostKey
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "@") {
+			continue
+		}
+		keys = append(keys, HostKey{Host: fields[0], Type: fields[1], Key: fields[2]})
+	}
+	return keys
+}
+
+// AppendKnownHosts adds keys that path does not already contain, creating
+// the file (mode 0600) and its directory if needed. It returns the keys that
+// were added.
+func AppendKnownHosts(path string, keys []HostKey) ([]HostKey, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read known hosts file: %w", err)
+	}
+	have := make(map[HostKey]bool)
+	for _, k := range parseKnownHosts(existing) {
+		have[k] = true
+	}
+
+	var added []HostKey
+	var buf bytes.Buffer
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		buf.WriteString("\n")
+	}
+	for _, k := range keys {
+		if have[k] {
+			continue
+		}
+		have[k] = true
+		added = append(added, k)
+		buf.WriteString(k.Line() + "\n")
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create known hosts directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known hosts file: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to write known hosts file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write known hosts file: %w", err)
+	}
+	return added, nil
+}
@@ -0,0 +1,121 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSSHHost(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantHost string
+		wantPort int
+		wantErr  bool
+	}{
+		{url: "git@github.com:org/repo.git", wantHost: "github.com", wantPort: 22},
+		{url: "ssh://git@github.com/org/repo.git", wantHost: "github.com", wantPort: 22},
+		{url: "ssh://git@git.example.com:2222/org/repo.git", wantHost: "git.example.com", wantPort: 2222},
+		{url: "ssh://git@[2001:db8::1]:2222/repo.git", wantHost: "2001:db8::1", wantPort: 2222},
+		{url: "https://github.com/org/repo.git", wantErr: true},
+		{url: "git@:repo.git", wantErr: true},
+	}
+	for _, tt := range tests {
+		host, port, err := SSHHost(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SSHHost(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (host != tt.wantHost || port != tt.wantPort) {
+			t.Errorf("SSHHost(%q) = %s, %d; want %s, %d", tt.url, host, port, tt.wantHost, tt.wantPort)
+		}
+	}
+}
+
+func TestHostKeyFingerprint(t *testing.T) {
+	// Fingerprint as printed by ssh-keygen -lf for this key.
+	k := HostKey{Host: "example.com", Type: "ssh-ed25519", Key: "AAAAC3NzaC1lZDI1NTE5AAAAIKvlfekQ2ioBawsrOG9QtSYvIKY1gnjvB7wOaP9VwUeK"}
+	got, err := k.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+	if want := "SHA256:kqIpBKOlnFhnILOA9nCJWnBAH1wKvD7GdLGpk26k0qo"; got != want {
+		t.Errorf("Fingerprint() = %s, want %s", got, want)
+	}
+	if _, err := (HostKey{Key: "not base64!"}).Fingerprint(); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+}
+
+func TestParseKnownHosts(t *testing.T) {
+	data := []byte("# github.com:22 SSH-2.0-babeld\n\ngithub.com ssh-ed25519 AAAA1\n@cert-authority *.example.com ssh-rsa AAAA2\n[git.example.com]:2222 ssh-rsa AAAA3 comment\n")
+	want := []HostKey{
+		{Host: "github.com", Type: "ssh-ed25519", Key: "AAAA1"},
+		{Host: "[git.example.com]:2222", Type: "ssh-rsa", Key: "AAAA3"},
+	}
+	if got := parseKnownHosts(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseKnownHosts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAppendKnownHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ssh", "known_hosts")
+	a := HostKey{Host: "github.com", Type: "ssh-ed25519", Key: "AAAA1"}
+	b := HostKey{Host: "github.com", Type: "ssh-rsa", Key: "AAAA2"}
+
+	added, err := AppendKnownHosts(path, []HostKey{a})
+	if err != nil || len(added) != 1 {
+		t.Fatalf("AppendKnownHosts() = %v, %v; want one key added", added, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("known_hosts mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	added, err = AppendKnownHosts(path, []HostKey{a, b, b})
+	if err != nil {
+		t.Fatalf("AppendKnownHosts: %v", err)
+	}
+	if !reflect.DeepEqual(added, []HostKey{b}) {
+		t.Errorf("added = %+v, want only the new key", added)
+	}
+	data, _ := os.ReadFile(path)
+	if want := a.Line() + "\n" + b.Line() + "\n"; string(data) != want {
+		t.Errorf("known_hosts = %q, want %q", data, want)
+	}
+}
+
+func TestSSHCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		client ShellClient
+		want   string
+	}{
+		{
+			name:   "key only",
+			client: ShellClient{sshKeyFile: "/k"},
+			want:   "ssh -i '/k' -o StrictHostKeyChecking=accept-new -F /dev/null",
+		},
+		{
+			name:   "pinned host keys",
+			client: ShellClient{sshKeyFile: "/k", sshKnownHostsFile: "/etc/quadsyncd/known_hosts", sshStrictHostKey: "yes"},
+			want:   "ssh -i '/k' -o StrictHostKeyChecking=yes -o 'UserKnownHostsFile=/etc/quadsyncd/known_hosts' -F /dev/null",
+		},
+		{
+			name:   "strict checking without key keeps ssh config",
+			client: ShellClient{sshStrictHostKey: "yes"},
+			want:   "ssh -o StrictHostKeyChecking=yes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.client.sshCommand(); got != tt.want {
+				t.Errorf("sshCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+)
+
+// RetryPolicy configures how often a failed checkout is retried.
+type RetryPolicy struct {
+	// Attempts is the total number of tries; 1 or less disables retries.
+	Attempts int
+	// BaseDelay is the wait before the second try. It doubles for every
+	// further try.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between two tries; 0 means no cap.
+	MaxDelay time.Duration
+}
+
+// delay returns the wait after the given failed attempt (1-based): half of
+// the exponential delay plus up to the same amount of random jitter, so
+// several hosts failing at once do not retry in lockstep.
+func (p RetryPolicy) delay(attempt int, jitter func() float64) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(jitter()*float64(d-half))
+}
+
+// RetryClient retries the checkouts of another Client that fail with a
+// network error. Authentication and other failures are returned at once:
+// retrying them only delays the error.
+type RetryClient struct {
+	inner  Client
+	policy RetryPolicy
+	logger *slog.Logger
+	jitter func() float64
+}
+
+// NewRetryClient wraps inner so that failed checkouts are retried according
+// to policy.
+func NewRetryClient(inner Client, policy RetryPolicy, logger *slog.Logger) *RetryClient {
+	return &RetryClient{inner: inner, policy: policy, logger: logger, jitter: rand.Float64}
+}
+
+// ChangedFiles lists changes with the wrapped client when it is a Differ.
+// The diff only reads the local checkout, so it is not retried.
+func (c *RetryClient) ChangedFiles(ctx context.Context, destDir, from, to string) ([]string, error) {
+	d, ok := c.inner.(Differ)
+	if !ok {
+		return nil, errors.New("git client cannot list changed files")
+	}
+	return d.ChangedFiles(ctx, destDir, from, to)
+}
+
+// EnsureCheckout calls the wrapped client until it succeeds, fails with an
+// error that is not a network failure, runs out of attempts or ctx is done.
+// The last error is returned.
+func (c *RetryClient) EnsureCheckout(ctx context.Context, url, ref, destDir string, opts CheckoutOptions) (string, error) {
+	for attempt := 1; ; attempt++ {
+		commit, err := c.inner.EnsureCheckout(ctx, url, ref, destDir, opts)
+		if err == nil || attempt >= c.policy.Attempts || KindOf(err) != FailureNetwork || ctx.Err() != nil {
+			return commit, err
+		}
+
+		delay := c.policy.delay(attempt, c.jitter)
+		c.logger.Warn("git checkout failed, retrying", logging.Event(logging.EventRepoFetchRetry),
+			logging.KeyRepo, url, logging.KeyRef, ref, "attempt", attempt, "max_attempts", c.policy.Attempts,
+			"delay", delay, logging.KeyError, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", err
+		case <-timer.C:
+		}
+	}
+}
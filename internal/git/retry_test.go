@@ -0,0 +1,121 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyClient fails with the queued errors before succeeding.
+type flakyClient struct {
+	errs  []error
+	calls int
+}
+
+func (c *flakyClient) EnsureCheckout(context.Context, string, string, string, CheckoutOptions) (string, error) {
+	c.calls++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return "", err
+	}
+	return "abc123", nil
+}
+
+func networkError() error {
+	return newCommandError("fetch", errors.New("fatal: Could not resolve host: github.com"))
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	tests := []struct {
+		attempt int
+		jitter  float64
+		want    time.Duration
+	}{
+		{1, 0, 500 * time.Millisecond},
+		{1, 1, time.Second},
+		{2, 1, 2 * time.Second},
+		{3, 0.5, 3 * time.Second},
+		{4, 1, 5 * time.Second},
+		{50, 1, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.delay(tt.attempt, func() float64 { return tt.jitter }); got != tt.want {
+			t.Errorf("delay(%d, jitter %v) = %s, want %s", tt.attempt, tt.jitter, got, tt.want)
+		}
+	}
+}
+
+func TestRetryClient(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	authErr := newCommandError("fetch", errors.New("fatal: Authentication failed"))
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", nil, 1, false},
+		{"recovers from network errors", []error{networkError(), networkError()}, 3, false},
+		{"gives up after attempts", []error{networkError(), networkError(), networkError()}, 3, true},
+		{"auth errors are not retried", []error{authErr}, 1, true},
+		{"other errors are not retried", []error{errors.New("checkout failed")}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &flakyClient{errs: tt.errs}
+			c := NewRetryClient(inner, policy, testLogger())
+			commit, err := c.EnsureCheckout(context.Background(), "https://github.com/o/r.git", "main", t.TempDir(), CheckoutOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EnsureCheckout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && commit != "abc123" {
+				t.Errorf("commit = %q, want abc123", commit)
+			}
+			if inner.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", inner.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryClient_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inner := &flakyClient{errs: []error{networkError(), networkError()}}
+	c := NewRetryClient(inner, RetryPolicy{Attempts: 3, BaseDelay: time.Hour}, testLogger())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := c.EnsureCheckout(ctx, "https://github.com/o/r.git", "main", t.TempDir(), CheckoutOptions{})
+	if KindOf(err) != FailureNetwork {
+		t.Errorf("error = %v, want the network failure", err)
+	}
+	if inner.calls != 1 || time.Since(start) > time.Minute {
+		t.Errorf("calls = %d after %s; want the backoff to end with the context", inner.calls, time.Since(start))
+	}
+}
+
+// differClient is a flakyClient that also lists changed files.
+type differClient struct {
+	flakyClient
+	changed []string
+}
+
+func (c *differClient) ChangedFiles(context.Context, string, string, string) ([]string, error) {
+	return c.changed, nil
+}
+
+func TestRetryClient_ChangedFiles(t *testing.T) {
+	c := NewRetryClient(&differClient{changed: []string{"a.container"}}, RetryPolicy{Attempts: 3}, testLogger())
+	got, err := c.ChangedFiles(context.Background(), t.TempDir(), "a", "b")
+	if err != nil || len(got) != 1 || got[0] != "a.container" {
+		t.Errorf("ChangedFiles() = %q, %v; want the inner client's changes", got, err)
+	}
+
+	c = NewRetryClient(&flakyClient{}, RetryPolicy{Attempts: 3}, testLogger())
+	if _, err := c.ChangedFiles(context.Background(), t.TempDir(), "a", "b"); err == nil {
+		t.Error("ChangedFiles() should fail when the inner client cannot diff")
+	}
+}
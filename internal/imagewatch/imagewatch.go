@@ -0,0 +1,303 @@
+// Package imagewatch restarts containers whose image tag has moved.
+//
+// Quadlets that reference an image by a tag such as ":latest" or ":1" keep
+// running the image that was pulled when the container was first started,
+// even after the registry has published a new image under that tag. The
+// watcher compares the digest the registry serves for each such tag with the
+// digests of the local image and refreshes the containers that are behind,
+// either by pulling the image and restarting their units or by running
+// `podman auto-update`.
+package imagewatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+)
+
+// Registry resolves and fetches image digests.
+type Registry interface {
+	// LocalDigests returns the repository digests of the local image, or
+	// nil when the image has not been pulled.
+	LocalDigests(ctx context.Context, image string) ([]string, error)
+	// RemoteDigest returns the digest the registry currently serves for image.
+	RemoteDigest(ctx context.Context, image string) (string, error)
+	// Pull fetches image from its registry.
+	Pull(ctx context.Context, image string) error
+	// AutoUpdate runs `podman auto-update`.
+	AutoUpdate(ctx context.Context) error
+}
+
+// Target is a managed container quadlet whose image uses a moving tag.
+type Target struct {
+	Path  string `json:"path"`
+	Unit  string `json:"unit"`
+	Image string `json:"image"`
+}
+
+// Update is a target whose registry digest is not among the local digests.
+type Update struct {
+	Target
+	Digest string `json:"digest"`
+}
+
+// Targets returns the container quadlets among paths whose Image= can move,
+// sorted by unit. Files that cannot be parsed are skipped; they are reported
+// by the sync that installed them.
+func Targets(paths []string) []Target {
+	var targets []Target
+	for _, path := range paths {
+		if filepath.Ext(path) != ".container" {
+			continue
+		}
+		u, err := quadlet.ParseFile(path)
+		if err != nil {
+			continue
+		}
+		image, ok := u.Lookup("Container", "Image")
+		if !ok || !MovingTag(image) {
+			continue
+		}
+		targets = append(targets, Target{Path: path, Unit: quadlet.UnitNameFromQuadlet(path), Image: image})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Unit < targets[j].Unit })
+	return targets
+}
+
+// MovingTag reports whether image names a registry image by tag rather than
+// by digest. References to .image and .build quadlets, images under
+// localhost/ and references containing systemd specifiers are not watched.
+func MovingTag(image string) bool {
+	switch {
+	case image == "":
+		return false
+	case strings.Contains(image, "@"):
+		return false
+	case strings.HasSuffix(image, ".image"), strings.HasSuffix(image, ".build"):
+		return false
+	case strings.HasPrefix(image, "localhost/"):
+		return false
+	case strings.Contains(image, "%"):
+		return false
+	}
+	return true
+}
+
+// Watcher checks targets for new images and refreshes the ones behind.
+type Watcher struct {
+	registry Registry
+	systemd  systemduser.Systemd
+	action   config.ImageWatchAction
+	logger   *slog.Logger
+}
+
+// NewWatcher creates a watcher applying action to outdated images.
+func NewWatcher(registry Registry, systemd systemduser.Systemd, action config.ImageWatchAction, logger *slog.Logger) *Watcher {
+	return &Watcher{registry: registry, systemd: systemd, action: action, logger: logger}
+}
+
+// Check resolves the local and remote digest of every target image and
+// returns the targets whose remote digest is not present locally. Images
+// that were never pulled are skipped, since their units have not run yet.
+// Images that cannot be resolved are logged and skipped; their errors are
+// joined into the returned error.
+func (w *Watcher) Check(ctx context.Context, targets []Target) ([]Update, error) {
+	type status struct {
+		digest string
+		behind bool
+		err    error
+	}
+	checked := make(map[string]status)
+	var updates []Update
+	var errs []error
+	for _, t := range targets {
+		st, ok := checked[t.Image]
+		if !ok {
+			st.digest, st.behind, st.err = w.checkImage(ctx, t.Image)
+			checked[t.Image] = st
+			if st.err != nil {
+				w.logger.Warn("failed to check image", logging.Event(logging.EventImageCheckFailed),
+					"image", t.Image, logging.KeyError, st.err)
+				errs = append(errs, st.err)
+			}
+		}
+		if st.behind {
+			updates = append(updates, Update{Target: t, Digest: st.digest})
+		}
+	}
+	return updates, errors.Join(errs...)
+}
+
+// checkImage returns the remote digest of image and whether the local image
+// lacks it.
+func (w *Watcher) checkImage(ctx context.Context, image string) (string, bool, error) {
+	local, err := w.registry.LocalDigests(ctx, image)
+	if err != nil {
+		return "", false, err
+	}
+	if local == nil {
+		w.logger.Debug("image not pulled yet, skipping", "image", image)
+		return "", false, nil
+	}
+	remote, err := w.registry.RemoteDigest(ctx, image)
+	if err != nil {
+		return "", false, err
+	}
+	for _, d := range local {
+		if d == remote {
+			return remote, false, nil
+		}
+	}
+	return remote, true, nil
+}
+
+// Apply refreshes the containers of updates. With the restart action every
+// updated image is pulled and the units using it are try-restarted; with the
+// auto-update action `podman auto-update` is run once, which only refreshes
+// containers labelled with AutoUpdate=registry.
+func (w *Watcher) Apply(ctx context.Context, updates []Update) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	for _, u := range updates {
+		w.logger.Info("image has a new digest", logging.Event(logging.EventImageUpdate),
+			"image", u.Image, logging.KeyUnit, u.Unit, "digest", u.Digest)
+	}
+
+	if w.action == config.ImageWatchAutoUpdate {
+		if err := w.registry.AutoUpdate(ctx); err != nil {
+			return fmt.Errorf("podman auto-update failed: %w", err)
+		}
+		return nil
+	}
+
+	pulled := make(map[string]error)
+	var units []string
+	var errs []error
+	for _, u := range updates {
+		err, ok := pulled[u.Image]
+		if !ok {
+			err = w.registry.Pull(ctx, u.Image)
+			pulled[u.Image] = err
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to pull %s: %w", u.Image, err))
+			}
+		}
+		if err == nil {
+			units = append(units, u.Unit)
+		}
+	}
+	if len(units) > 0 {
+		if err := w.systemd.TryRestartUnits(ctx, units); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restart units: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run checks the container quadlets recorded in paths and applies the
+// configured action to the outdated ones. It returns the updates found.
+func (w *Watcher) Run(ctx context.Context, paths []string) ([]Update, error) {
+	updates, checkErr := w.Check(ctx, Targets(paths))
+	return updates, errors.Join(checkErr, w.Apply(ctx, updates))
+}
+
+// PodmanRegistry implements Registry with podman and skopeo.
+type PodmanRegistry struct {
+	timeout time.Duration
+}
+
+// NewPodmanRegistry returns a registry whose podman and skopeo invocations
+// are each killed after timeout (0 means no limit).
+func NewPodmanRegistry(timeout time.Duration) *PodmanRegistry {
+	return &PodmanRegistry{timeout: timeout}
+}
+
+// LocalDigests returns the RepoDigests of the local image without their
+// repository prefix.
+func (r *PodmanRegistry) LocalDigests(ctx context.Context, image string) ([]string, error) {
+	if _, err := r.run(ctx, "podman", "image", "exists", image); err != nil {
+		if exitCode(err) == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	out, err := r.run(ctx, "podman", "image", "inspect", "--format", "{{json .RepoDigests}}", image)
+	if err != nil {
+		return nil, err
+	}
+	var repoDigests []string
+	if err := json.Unmarshal(bytes.TrimSpace(out), &repoDigests); err != nil {
+		return nil, fmt.Errorf("failed to parse digests of %s: %w", image, err)
+	}
+	digests := []string{}
+	for _, d := range repoDigests {
+		if _, digest, ok := strings.Cut(d, "@"); ok {
+			digests = append(digests, digest)
+		}
+	}
+	return digests, nil
+}
+
+// RemoteDigest asks the registry for the manifest digest of image with
+// `skopeo inspect`, which only fetches the manifest.
+func (r *PodmanRegistry) RemoteDigest(ctx context.Context, image string) (string, error) {
+	out, err := r.run(ctx, "skopeo", "inspect", "--no-tags", "--format", "{{.Digest}}", "docker://"+image)
+	if err != nil {
+		return "", err
+	}
+	digest := strings.TrimSpace(string(out))
+	if digest == "" {
+		return "", fmt.Errorf("registry returned no digest for %s", image)
+	}
+	return digest, nil
+}
+
+// Pull runs `podman pull`.
+func (r *PodmanRegistry) Pull(ctx context.Context, image string) error {
+	_, err := r.run(ctx, "podman", "pull", "--quiet", image)
+	return err
+}
+
+// AutoUpdate runs `podman auto-update`.
+func (r *PodmanRegistry) AutoUpdate(ctx context.Context) error {
+	_, err := r.run(ctx, "podman", "auto-update")
+	return err
+}
+
+// run runs name with args and returns its stdout.
+func (r *PodmanRegistry) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cmd := cmdexec.Command(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmdexec.Err(ctx, r.timeout, cmd.Run()); err != nil {
+		return nil, fmt.Errorf("%s %s: %w: %s", name, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// exitCode returns the exit status wrapped in err, or -1.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,199 @@
+package imagewatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// fakeRegistry serves fixed digests and records pulls.
+type fakeRegistry struct {
+	local      map[string][]string
+	remote     map[string]string
+	remoteErr  error
+	pulled     []string
+	autoUpdate int
+}
+
+func (r *fakeRegistry) LocalDigests(_ context.Context, image string) ([]string, error) {
+	return r.local[image], nil
+}
+
+func (r *fakeRegistry) RemoteDigest(_ context.Context, image string) (string, error) {
+	if r.remoteErr != nil {
+		return "", r.remoteErr
+	}
+	return r.remote[image], nil
+}
+
+func (r *fakeRegistry) Pull(_ context.Context, image string) error {
+	r.pulled = append(r.pulled, image)
+	return nil
+}
+
+func (r *fakeRegistry) AutoUpdate(context.Context) error {
+	r.autoUpdate++
+	return nil
+}
+
+func TestMovingTag(t *testing.T) {
+	tests := map[string]bool{
+		"docker.io/library/nginx:latest":   true,
+		"ghcr.io/org/app":                  true,
+		"quay.io/org/app:1.2":              true,
+		"docker.io/library/nginx@sha256:a": false,
+		"app.image":                        false,
+		"app.build":                        false,
+		"localhost/app:dev":                false,
+		"ghcr.io/org/%i:latest":            false,
+		"":                                 false,
+	}
+	for image, want := range tests {
+		if got := MovingTag(image); got != want {
+			t.Errorf("MovingTag(%q) = %v, want %v", image, got, want)
+		}
+	}
+}
+
+func TestTargets(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"web.container":    "[Container]\nImage=docker.io/library/nginx:latest\n",
+		"api.container":    "[Container]\nImage=ghcr.io/org/api:1\n",
+		"pinned.container": "[Container]\nImage=ghcr.io/org/db@sha256:abc\n",
+		"built.container":  "[Container]\nImage=app.build\n",
+		"data.volume":      "[Volume]\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	want := []Target{
+		{Path: filepath.Join(dir, "api.container"), Unit: "api.service", Image: "ghcr.io/org/api:1"},
+		{Path: filepath.Join(dir, "web.container"), Unit: "web.service", Image: "docker.io/library/nginx:latest"},
+	}
+	if got := Targets(paths); !reflect.DeepEqual(got, want) {
+		t.Errorf("Targets() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWatcher_CheckAndApply(t *testing.T) {
+	targets := []Target{
+		{Unit: "api.service", Image: "ghcr.io/org/api:1"},
+		{Unit: "web.service", Image: "nginx:latest"},
+		{Unit: "web2.service", Image: "nginx:latest"},
+		{Unit: "new.service", Image: "ghcr.io/org/new:latest"},
+	}
+	registry := &fakeRegistry{
+		local: map[string][]string{
+			"ghcr.io/org/api:1": {"sha256:api"},
+			"nginx:latest":      {"sha256:old", "sha256:older"},
+		},
+		remote: map[string]string{
+			"ghcr.io/org/api:1":      "sha256:api",
+			"nginx:latest":           "sha256:new",
+			"ghcr.io/org/new:latest": "sha256:new",
+		},
+	}
+	systemd := &testutil.MockSystemd{}
+	w := NewWatcher(registry, systemd, config.ImageWatchRestart, testutil.TestLogger())
+
+	updates, err := w.Check(context.Background(), targets)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	var units []string
+	for _, u := range updates {
+		units = append(units, u.Unit)
+		if u.Digest != "sha256:new" {
+			t.Errorf("%s digest = %q, want sha256:new", u.Unit, u.Digest)
+		}
+	}
+	if want := []string{"web.service", "web2.service"}; !reflect.DeepEqual(units, want) {
+		t.Fatalf("updated units = %v, want %v", units, want)
+	}
+
+	if err := w.Apply(context.Background(), updates); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if want := []string{"nginx:latest"}; !reflect.DeepEqual(registry.pulled, want) {
+		t.Errorf("pulled = %v, want %v", registry.pulled, want)
+	}
+	if want := []string{"web.service", "web2.service"}; !reflect.DeepEqual(systemd.RestartedUnits, want) {
+		t.Errorf("restarted = %v, want %v", systemd.RestartedUnits, want)
+	}
+}
+
+func TestWatcher_AutoUpdate(t *testing.T) {
+	registry := &fakeRegistry{}
+	systemd := &testutil.MockSystemd{}
+	w := NewWatcher(registry, systemd, config.ImageWatchAutoUpdate, testutil.TestLogger())
+
+	updates := []Update{{Target: Target{Unit: "web.service", Image: "nginx:latest"}, Digest: "sha256:new"}}
+	if err := w.Apply(context.Background(), updates); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if registry.autoUpdate != 1 || len(registry.pulled) != 0 || systemd.RestartCalled {
+		t.Errorf("auto-update runs = %d, pulled = %v, restart called = %v; want only one auto-update", registry.autoUpdate, registry.pulled, systemd.RestartCalled)
+	}
+	if err := w.Apply(context.Background(), nil); err != nil || registry.autoUpdate != 1 {
+		t.Errorf("Apply(nil) ran auto-update or failed: %v", err)
+	}
+}
+
+func TestWatcher_CheckError(t *testing.T) {
+	registry := &fakeRegistry{
+		local:     map[string][]string{"nginx:latest": {"sha256:old"}},
+		remoteErr: errors.New("registry unreachable"),
+	}
+	w := NewWatcher(registry, &testutil.MockSystemd{}, config.ImageWatchRestart, testutil.TestLogger())
+	updates, err := w.Check(context.Background(), []Target{{Unit: "web.service", Image: "nginx:latest"}})
+	if err == nil || len(updates) != 0 {
+		t.Errorf("Check() = %v, %v; want no updates and an error", updates, err)
+	}
+}
+
+func TestPodmanRegistry(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	writeTool := func(name, script string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTool("podman", `case "$1 $2" in
+"image exists") [ "$3" = "nginx:latest" ] || exit 1 ;;
+"image inspect") echo '["docker.io/library/nginx@sha256:aaa","mirror.local/nginx@sha256:bbb"]' ;;
+esac
+`)
+	writeTool("skopeo", `[ "$5" = "docker://nginx:latest" ] && echo sha256:ccc`)
+
+	r := NewPodmanRegistry(0)
+	ctx := context.Background()
+	local, err := r.LocalDigests(ctx, "nginx:latest")
+	if err != nil {
+		t.Fatalf("LocalDigests: %v", err)
+	}
+	if want := []string{"sha256:aaa", "sha256:bbb"}; !reflect.DeepEqual(local, want) {
+		t.Errorf("LocalDigests() = %v, want %v", local, want)
+	}
+	if missing, err := r.LocalDigests(ctx, "missing:latest"); err != nil || missing != nil {
+		t.Errorf("LocalDigests(missing) = %v, %v; want nil, nil", missing, err)
+	}
+	remote, err := r.RemoteDigest(ctx, "nginx:latest")
+	if err != nil || remote != "sha256:ccc" {
+		t.Errorf("RemoteDigest() = %q, %v; want sha256:ccc", remote, err)
+	}
+}
@@ -0,0 +1,57 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UnitManager is the part of the systemd user manager Install and Uninstall
+// use. *systemduser.Client implements it.
+type UnitManager interface {
+	DaemonReload(ctx context.Context) error
+	EnableUnits(ctx context.Context, units []string, now bool) error
+	DisableUnits(ctx context.Context, units []string, now bool) error
+}
+
+// Install writes units into dir, reloads the user manager and enables and
+// starts the units named in enable. It returns the paths written.
+func Install(ctx context.Context, mgr UnitManager, dir string, units []Unit, enable []string, overwrite bool) ([]string, error) {
+	paths, err := WriteUnits(dir, units, overwrite)
+	if err != nil {
+		return paths, err
+	}
+	if err := mgr.DaemonReload(ctx); err != nil {
+		return paths, err
+	}
+	return paths, mgr.EnableUnits(ctx, enable, true)
+}
+
+// Uninstall stops and disables the quadsyncd units found in dir, removes
+// their files and reloads the user manager. It returns the paths removed;
+// units that are not installed are skipped.
+func Uninstall(ctx context.Context, mgr UnitManager, dir string) ([]string, error) {
+	var names, paths []string
+	for _, name := range UnitNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Lstat(path); err == nil {
+			names = append(names, name)
+			paths = append(paths, path)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	if err := mgr.DisableUnits(ctx, names, true); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return paths, mgr.DaemonReload(ctx)
+}
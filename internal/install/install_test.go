@@ -0,0 +1,79 @@
+package install
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeManager records the calls made by Install and Uninstall.
+type fakeManager struct {
+	calls []string
+}
+
+func (m *fakeManager) DaemonReload(context.Context) error {
+	m.calls = append(m.calls, "daemon-reload")
+	return nil
+}
+
+func (m *fakeManager) EnableUnits(_ context.Context, units []string, now bool) error {
+	m.calls = append(m.calls, record("enable", units, now))
+	return nil
+}
+
+func (m *fakeManager) DisableUnits(_ context.Context, units []string, now bool) error {
+	m.calls = append(m.calls, record("disable", units, now))
+	return nil
+}
+
+func record(verb string, units []string, now bool) string {
+	s := verb
+	if now {
+		s += " --now"
+	}
+	for _, u := range units {
+		s += " " + u
+	}
+	return s
+}
+
+func TestInstallUninstall(t *testing.T) {
+	dir := t.TempDir()
+	units, err := SyncUnits(Options{Binary: "/bin/quadsyncd", ConfigPath: "/c.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A unit file of the user's own next to ours must survive uninstall.
+	other := filepath.Join(dir, "other.service")
+	if err := os.WriteFile(other, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &fakeManager{}
+	paths, err := Install(context.Background(), mgr, dir, units, []string{SyncTimerName}, false)
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("Install() = %v, %v", paths, err)
+	}
+	if want := []string{"daemon-reload", "enable --now " + SyncTimerName}; !reflect.DeepEqual(mgr.calls, want) {
+		t.Errorf("install calls = %v, want %v", mgr.calls, want)
+	}
+
+	mgr = &fakeManager{}
+	removed, err := Uninstall(context.Background(), mgr, dir)
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("Uninstall() = %v, %v", removed, err)
+	}
+	if want := []string{"disable --now " + SyncTimerName + " " + SyncServiceName, "daemon-reload"}; !reflect.DeepEqual(mgr.calls, want) {
+		t.Errorf("uninstall calls = %v, want %v", mgr.calls, want)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("unrelated unit removed: %v", err)
+	}
+
+	mgr = &fakeManager{}
+	if removed, err := Uninstall(context.Background(), mgr, dir); err != nil || removed != nil || mgr.calls != nil {
+		t.Errorf("second Uninstall() = %v, %v with calls %v; want a no-op", removed, err, mgr.calls)
+	}
+}
@@ -0,0 +1,205 @@
+// Package install renders the systemd user units that run quadsyncd.
+//
+// The units match the ones shipped under packaging/systemd/user, with the
+// binary and configuration paths filled in for the current installation
+// instead of the ~/.local/bin and ~/.config/quadsyncd defaults.
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Unit names, shared with the packaged units.
+const (
+	SyncServiceName    = "quadsyncd-sync.service"
+	SyncTimerName      = "quadsyncd-sync.timer"
+	WebhookServiceName = "quadsyncd-webhook.service"
+	WebhookSocketName  = "quadsyncd-webhook.socket"
+)
+
+// UnitNames lists every unit this package renders.
+var UnitNames = []string{SyncTimerName, SyncServiceName, WebhookSocketName, WebhookServiceName}
+
+// Options describe the installation the units run.
+type Options struct {
+	// Binary is the absolute path of the quadsyncd executable.
+	Binary string
+	// ConfigPath is the absolute path of the configuration file.
+	ConfigPath string
+	// ListenAddr is the address the webhook socket listens on.
+	ListenAddr string
+}
+
+// Unit is a rendered unit file.
+type Unit struct {
+	Name    string
+	Content []byte
+}
+
+var syncServiceTemplate = template.Must(template.New(SyncServiceName).Funcs(funcs).Parse(`[Unit]
+Description=quadsyncd quadlet sync (rootless)
+Wants=network-online.target
+After=network-online.target
+ConditionPathExists={{path .ConfigPath}}
+
+[Service]
+Type=oneshot
+# Fail with a readable report when a key, token or directory is unusable
+ExecStartPre={{arg .Binary}} config validate --config {{arg .ConfigPath}}
+ExecStart={{arg .Binary}} sync --config {{arg .ConfigPath}}
+WorkingDirectory=%h
+NoNewPrivileges=true
+PrivateTmp=true
+ProtectHome=false
+
+[Install]
+WantedBy=default.target
+`))
+
+var syncTimerTemplate = template.Must(template.New(SyncTimerName).Parse(`[Unit]
+Description=Run quadsyncd sync periodically
+
+[Timer]
+OnBootSec=2m
+OnUnitActiveSec=5m
+Persistent=true
+RandomizedDelaySec=30s
+Unit=` + SyncServiceName + `
+
+[Install]
+WantedBy=timers.target
+`))
+
+var webhookServiceTemplate = template.Must(template.New(WebhookServiceName).Funcs(funcs).Parse(`[Unit]
+Description=quadsyncd webhook listener (rootless)
+Wants=network-online.target
+After=network-online.target
+ConditionPathExists={{path .ConfigPath}}
+
+[Service]
+Type=notify
+# Fail with a readable report when a key, token or directory is unusable
+ExecStartPre={{arg .Binary}} config validate --config {{arg .ConfigPath}}
+ExecStart={{arg .Binary}} serve --config {{arg .ConfigPath}}
+# systemctl --user reload re-reads the config file
+ExecReload=/bin/kill -HUP $MAINPID
+# READY=1 is sent after the initial sync, which may take a while on first clone
+TimeoutStartSec=10min
+# Leave room for serve.shutdown_grace (default 30s) to finish a running sync
+TimeoutStopSec=90s
+WatchdogSec=60s
+WorkingDirectory=%h
+Restart=on-failure
+RestartSec=2s
+NoNewPrivileges=true
+PrivateTmp=true
+
+[Install]
+WantedBy=default.target
+`))
+
+var webhookSocketTemplate = template.Must(template.New(WebhookSocketName).Parse(`[Unit]
+Description=quadsyncd webhook listener socket (rootless)
+PartOf=` + WebhookServiceName + `
+
+[Socket]
+ListenStream={{.ListenAddr}}
+Accept=no
+
+[Install]
+WantedBy=sockets.target
+`))
+
+var funcs = template.FuncMap{"arg": execArg, "path": escapeSpecifiers}
+
+// SyncUnits renders the oneshot sync service and the timer that starts it.
+func SyncUnits(opts Options) ([]Unit, error) {
+	return render(opts, syncServiceTemplate, syncTimerTemplate)
+}
+
+// WebhookUnits renders the webhook service and, with socket, the socket that
+// activates it on opts.ListenAddr.
+func WebhookUnits(opts Options, socket bool) ([]Unit, error) {
+	if !socket {
+		return render(opts, webhookServiceTemplate)
+	}
+	if opts.ListenAddr == "" || strings.ContainsAny(opts.ListenAddr, " \t\n") {
+		return nil, fmt.Errorf("invalid socket listen address %q", opts.ListenAddr)
+	}
+	return render(opts, webhookServiceTemplate, webhookSocketTemplate)
+}
+
+// render executes the templates with opts.
+func render(opts Options, templates ...*template.Template) ([]Unit, error) {
+	if !filepath.IsAbs(opts.Binary) || !filepath.IsAbs(opts.ConfigPath) {
+		return nil, fmt.Errorf("binary and config paths must be absolute: %q, %q", opts.Binary, opts.ConfigPath)
+	}
+	var units []Unit
+	for _, tmpl := range templates {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, opts); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", tmpl.Name(), err)
+		}
+		units = append(units, Unit{Name: tmpl.Name(), Content: buf.Bytes()})
+	}
+	return units, nil
+}
+
+// UserUnitDir returns the directory systemd reads user units from:
+// $XDG_CONFIG_HOME/systemd/user, falling back to ~/.config/systemd/user.
+func UserUnitDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "systemd", "user"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+// WriteUnits writes units into dir, creating it if needed, and returns the
+// paths written. Existing unit files are only replaced with overwrite; without
+// it nothing is written when any of them exists.
+func WriteUnits(dir string, units []Unit, overwrite bool) ([]string, error) {
+	if !overwrite {
+		for _, u := range units {
+			path := filepath.Join(dir, u.Name)
+			if _, err := os.Stat(path); err == nil {
+				return nil, fmt.Errorf("%s already exists", path)
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	var paths []string
+	for _, u := range units {
+		path := filepath.Join(dir, u.Name)
+		if err := os.WriteFile(path, u.Content, 0o644); err != nil {
+			return paths, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// escapeSpecifiers doubles % so systemd does not expand it as a specifier.
+func escapeSpecifiers(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// execArg formats s as one argument of an Exec*= command line, quoting it
+// when it contains whitespace, quotes or backslashes.
+func execArg(s string) string {
+	s = escapeSpecifiers(s)
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,98 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncUnits(t *testing.T) {
+	units, err := SyncUnits(Options{Binary: "/home/u/bin/quadsyncd", ConfigPath: "/home/u/my configs/100%.yaml"})
+	if err != nil {
+		t.Fatalf("SyncUnits() error = %v", err)
+	}
+	if len(units) != 2 || units[0].Name != SyncServiceName || units[1].Name != SyncTimerName {
+		t.Fatalf("units = %+v", units)
+	}
+	service := string(units[0].Content)
+	for _, want := range []string{
+		`ExecStart=/home/u/bin/quadsyncd sync --config "/home/u/my configs/100%%.yaml"`,
+		`ConditionPathExists=/home/u/my configs/100%%.yaml`,
+		`ExecStartPre=/home/u/bin/quadsyncd config validate`,
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service missing %q:\n%s", want, service)
+		}
+	}
+	if !strings.Contains(string(units[1].Content), "Unit="+SyncServiceName) {
+		t.Errorf("timer does not start the service:\n%s", units[1].Content)
+	}
+
+	if _, err := SyncUnits(Options{Binary: "quadsyncd", ConfigPath: "/c.yaml"}); err == nil {
+		t.Error("SyncUnits() accepted a relative binary path")
+	}
+}
+
+func TestExecArg(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/usr/bin/quadsyncd", "/usr/bin/quadsyncd"},
+		{"/a b/c", `"/a b/c"`},
+		{`/a"b`, `"/a\"b"`},
+		{"/a%b", "/a%%b"},
+	}
+	for _, tt := range tests {
+		if got := execArg(tt.in); got != tt.want {
+			t.Errorf("execArg(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteUnits(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "systemd", "user")
+	units := []Unit{{Name: "a.service", Content: []byte("one")}, {Name: "a.timer", Content: []byte("two")}}
+
+	paths, err := WriteUnits(dir, units, false)
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("WriteUnits() = %v, %v", paths, err)
+	}
+
+	units[0].Content = []byte("changed")
+	if _, err := WriteUnits(dir, units, false); err == nil {
+		t.Fatal("WriteUnits() replaced existing units without overwrite")
+	}
+	if data, _ := os.ReadFile(paths[0]); string(data) != "one" {
+		t.Errorf("content = %q after refused write", data)
+	}
+	if _, err := WriteUnits(dir, units, true); err != nil {
+		t.Fatalf("WriteUnits(overwrite) error = %v", err)
+	}
+	if data, _ := os.ReadFile(paths[0]); string(data) != "changed" {
+		t.Errorf("content = %q, want changed", data)
+	}
+}
+
+func TestWebhookUnits(t *testing.T) {
+	opts := Options{Binary: "/bin/quadsyncd", ConfigPath: "/c.yaml", ListenAddr: "127.0.0.1:9000"}
+
+	units, err := WebhookUnits(opts, false)
+	if err != nil || len(units) != 1 || units[0].Name != WebhookServiceName {
+		t.Fatalf("WebhookUnits(no socket) = %+v, %v", units, err)
+	}
+	if !strings.Contains(string(units[0].Content), "ExecStart=/bin/quadsyncd serve --config /c.yaml") {
+		t.Errorf("service:\n%s", units[0].Content)
+	}
+
+	units, err = WebhookUnits(opts, true)
+	if err != nil || len(units) != 2 || units[1].Name != WebhookSocketName {
+		t.Fatalf("WebhookUnits(socket) = %+v, %v", units, err)
+	}
+	if !strings.Contains(string(units[1].Content), "ListenStream=127.0.0.1:9000") {
+		t.Errorf("socket:\n%s", units[1].Content)
+	}
+
+	opts.ListenAddr = ""
+	if _, err := WebhookUnits(opts, true); err == nil {
+		t.Error("WebhookUnits() accepted a socket without a listen address")
+	}
+}
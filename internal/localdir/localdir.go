@@ -0,0 +1,143 @@
+// Package localdir syncs a directory on the host itself, such as a working
+// copy under development or a tree provisioned from removable media. It
+// implements git.Client: the "checkout" is a snapshot copy of the directory
+// and the "commit" a hash of its contents, so plans and history work as for
+// git repositories without any network access.
+package localdir
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/schaermu/quadsyncd/internal/fsutil"
+	"github.com/schaermu/quadsyncd/internal/git"
+)
+
+// Client copies local directories into checkout directories.
+type Client struct {
+	logger *slog.Logger
+}
+
+var _ git.Client = (*Client)(nil)
+
+// NewClient creates a local directory client.
+func NewClient(logger *slog.Logger) *Client {
+	return &Client{logger: logger}
+}
+
+// EnsureCheckout copies the directory src into destDir, replacing its
+// previous contents, and returns the content hash of the copy. The copy is
+// taken each run, so the sync works on a consistent snapshot even while src
+// is being edited. A non-empty ref, as set by rollbacks, must equal the hash
+// of the current contents: past states of a directory cannot be restored.
+// opts are git-specific and ignored.
+func (c *Client) EnsureCheckout(ctx context.Context, src, ref, destDir string, _ git.CheckoutOptions) (string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", fmt.Errorf("source directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("source %s is not a directory", src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destDir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create checkout parent: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(destDir), ".dir-copy-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create copy directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	rev, err := copyTree(ctx, src, tmp)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if ref != "" && ref != rev {
+		return "", fmt.Errorf("directory %s is at %s, not %s; a directory source only syncs its current contents", src, rev, ref)
+	}
+	if err := fsutil.ReplaceDir(tmp, destDir); err != nil {
+		return "", fmt.Errorf("failed to install directory copy: %w", err)
+	}
+	c.logger.Debug("copied source directory", "src", src, "revision", rev)
+	return rev, nil
+}
+
+// copyTree copies the contents of src into the existing directory dst and
+// returns a hash over every copied path, file type and file content. Symlinks
+// are copied as links, so the loader rejects them as it does in git
+// checkouts. A .git directory is skipped.
+func copyTree(ctx context.Context, src, dst string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dst, rel)
+		fmt.Fprintf(h, "%s\x00%s\x00", filepath.ToSlash(rel), d.Type())
+
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, 0755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			_, _ = io.WriteString(h, link)
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(p, target, h)
+		default:
+			return fmt.Errorf("%s is not a regular file, directory or symlink", p)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFile copies the regular file src to dst with its permission bits,
+// feeding the content to h.
+func copyFile(src, dst string, h io.Writer) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.MultiWriter(out, h), in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	_, _ = fmt.Fprint(h, "\x00")
+	return out.Close()
+}
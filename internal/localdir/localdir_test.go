@@ -0,0 +1,96 @@
+package localdir
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/git"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnsureCheckout(t *testing.T) {
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "web.container"), "[Container]\nImage=nginx:1\n")
+	writeFile(t, filepath.Join(src, "hosts", "a", "web.env"), "A=1\n")
+	writeFile(t, filepath.Join(src, ".git", "HEAD"), "ref: refs/heads/main\n")
+	dest := filepath.Join(t.TempDir(), "repos", "x")
+	c := NewClient(testLogger())
+
+	rev, err := c.EnsureCheckout(t.Context(), src, "", dest, git.CheckoutOptions{})
+	if err != nil {
+		t.Fatalf("EnsureCheckout: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dest, "hosts", "a", "web.env")); string(got) != "A=1\n" {
+		t.Errorf("copied web.env = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dest, ".git")); !os.IsNotExist(err) {
+		t.Error(".git was copied")
+	}
+
+	again, err := c.EnsureCheckout(t.Context(), src, "", dest, git.CheckoutOptions{})
+	if err != nil || again != rev {
+		t.Errorf("unchanged directory: rev = %q, %v; want %q", again, err, rev)
+	}
+
+	// Edits and deletions show up in the copy and change the revision.
+	writeFile(t, filepath.Join(src, "web.container"), "[Container]\nImage=nginx:2\n")
+	if err := os.RemoveAll(filepath.Join(src, "hosts")); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := c.EnsureCheckout(t.Context(), src, "", dest, git.CheckoutOptions{})
+	if err != nil {
+		t.Fatalf("EnsureCheckout after edit: %v", err)
+	}
+	if changed == rev {
+		t.Error("revision did not change after an edit")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "hosts")); !os.IsNotExist(err) {
+		t.Error("deleted directory still in the copy")
+	}
+
+	// Only the current contents can be requested by revision.
+	if _, err := c.EnsureCheckout(t.Context(), src, changed, dest, git.CheckoutOptions{}); err != nil {
+		t.Errorf("EnsureCheckout at current revision: %v", err)
+	}
+	if _, err := c.EnsureCheckout(t.Context(), src, rev, dest, git.CheckoutOptions{}); err == nil || !strings.Contains(err.Error(), "current contents") {
+		t.Errorf("EnsureCheckout at old revision error = %v", err)
+	}
+}
+
+func TestEnsureCheckout_Errors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	writeFile(t, file, "x")
+	c := NewClient(testLogger())
+
+	for name, src := range map[string]string{
+		"missing":   filepath.Join(t.TempDir(), "nope"),
+		"not a dir": file,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "dest")
+			if _, err := c.EnsureCheckout(t.Context(), src, "", dest, git.CheckoutOptions{}); err == nil {
+				t.Fatal("EnsureCheckout succeeded")
+			}
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Error("checkout created for a failed copy")
+			}
+		})
+	}
+}
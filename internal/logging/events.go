@@ -0,0 +1,111 @@
+package logging
+
+import "log/slog"
+
+// KeyEvent is the attribute key carrying a stable event name on log records.
+// Messages are for humans and may be reworded at any time; parsers,
+// dashboards and alerts should match on the event name and the attribute
+// keys below instead.
+const KeyEvent = "event"
+
+// Stable attribute keys. Values keep their meaning across releases; new keys
+// may be added but existing ones are never renamed.
+const (
+	KeyRunID       = "run_id"
+	KeyRepo        = "repo"
+	KeyRef         = "ref"
+	KeyCommit      = "commit"
+	KeyDest        = "dest"
+	KeyUnit        = "unit"
+	KeyUnits       = "units"
+	KeyCount       = "count"
+	KeyDryRun      = "dry_run"
+	KeyError       = "error"
+	KeyErrorKind   = "error_kind"
+	KeyWarning     = "warning"
+	KeyReason      = "reason"
+	KeyBackup      = "backup"
+	KeyClient      = "client"
+	KeyDeliveryID  = "delivery_id"
+	KeyGitHubEvent = "github_event" // the provider's event type, for every provider
+	KeyProvider    = "provider"
+)
+
+// Stable event names, grouped by subsystem. Names are lowercase and dotted,
+// from the most general component to the most specific.
+const (
+	EventSyncStarted   = "sync.started"
+	EventSyncCompleted = "sync.completed"
+	EventSyncFailed    = "sync.failed"
+	EventSyncSkipped   = "sync.skipped"
+	EventSyncPaused    = "sync.paused"
+	EventSyncWarning   = "sync.warning"
+	EventSyncWarnings  = "sync.warnings"
+
+	EventRepoFetch      = "repo.fetch"
+	EventRepoFetchRetry = "repo.fetch.retry"
+	EventRepoLoaded     = "repo.loaded"
+	EventRepoAuthFailed = "repo.auth.failed"
+	EventRepoRecloned   = "repo.recloned"
+
+	EventPlanComputed     = "plan.computed"
+	EventQuadletsValidate = "quadlets.validate"
+
+	EventFileAdd          = "file.add"
+	EventFileUpdate       = "file.update"
+	EventFileDelete       = "file.delete"
+	EventFileDriftIgnored = "file.drift.ignored"
+
+	EventSecretPut    = "secret.put"
+	EventSecretRemove = "secret.remove"
+
+	EventSessionCheck        = "session.check"
+	EventPodmanDetected      = "podman.detected"
+	EventDaemonReload        = "systemd.reload"
+	EventUnitRestart         = "unit.restart"
+	EventUnitRestartSkipped  = "unit.restart.skipped"
+	EventUnitRestartFailed   = "unit.restart.failed"
+	EventUnitRestartDeferred = "unit.restart.deferred"
+	EventUnitStart           = "unit.start"
+	EventUnitStartFailed     = "unit.start.failed"
+	EventUnitEnable          = "unit.enable"
+	EventUnitDisable         = "unit.disable"
+	EventUnitHealthCheck     = "unit.health.check"
+	EventUnitUnhealthy       = "unit.unhealthy"
+
+	EventImageUpdate      = "image.update"
+	EventImageCheckFailed = "image.check.failed"
+	EventImageAutoUpdate  = "image.autoupdate"
+	EventImageVerify      = "image.verify"
+
+	EventBackupCreated    = "backup.created"
+	EventBackupPruned     = "backup.pruned"
+	EventRestoreStarted   = "restore.started"
+	EventRestoreCompleted = "restore.completed"
+	EventRollbackStarted  = "rollback.started"
+
+	EventRunCreated = "run.created"
+
+	EventServerStarted  = "server.started"
+	EventServerStopping = "server.stopping"
+
+	EventConfigReloaded     = "config.reloaded"
+	EventConfigReloadFailed = "config.reload.failed"
+
+	EventWebhookReceived = "webhook.received"
+	EventWebhookPing     = "webhook.ping"
+	EventWebhookRejected = "webhook.rejected"
+	EventWebhookIgnored  = "webhook.ignored"
+	EventWebhookAccepted = "webhook.accepted"
+	EventWebhookSync     = "webhook.sync"
+
+	EventSourceChanged = "source.changed"
+)
+
+// Event returns the attribute that tags a log record with the stable event
+// name. Pass it first in the attribute list:
+//
+//	logger.Info("adding file", logging.Event(logging.EventFileAdd), logging.KeyDest, path)
+func Event(name string) slog.Attr {
+	return slog.String(KeyEvent, name)
+}
@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"testing"
+)
+
+func TestEventNames(t *testing.T) {
+	events := []string{
+		EventSyncStarted, EventSyncCompleted, EventSyncFailed, EventSyncSkipped, EventSyncPaused, EventSyncWarning, EventSyncWarnings,
+		EventRepoFetch, EventRepoFetchRetry, EventRepoLoaded, EventRepoAuthFailed, EventRepoRecloned,
+		EventPlanComputed, EventQuadletsValidate,
+		EventFileAdd, EventFileUpdate, EventFileDelete, EventFileDriftIgnored,
+		EventSecretPut, EventSecretRemove,
+		EventSessionCheck, EventPodmanDetected, EventDaemonReload, EventUnitRestart, EventUnitRestartSkipped, EventUnitRestartFailed, EventUnitRestartDeferred,
+		EventUnitStart, EventUnitStartFailed, EventUnitEnable, EventUnitDisable, EventUnitHealthCheck, EventUnitUnhealthy,
+		EventImageUpdate, EventImageCheckFailed, EventImageAutoUpdate, EventImageVerify,
+		EventBackupCreated, EventBackupPruned, EventRestoreStarted, EventRestoreCompleted, EventRollbackStarted,
+		EventRunCreated,
+		EventServerStarted, EventServerStopping,
+		EventConfigReloaded, EventConfigReloadFailed,
+		EventWebhookReceived, EventWebhookPing, EventWebhookRejected, EventWebhookIgnored,
+		EventWebhookAccepted, EventWebhookSync,
+		EventSourceChanged,
+	}
+	valid := regexp.MustCompile(`^[a-z]+(\.[a-z_]+)+$`)
+	seen := make(map[string]bool)
+	for _, name := range events {
+		if !valid.MatchString(name) {
+			t.Errorf("event %q does not match %s", name, valid)
+		}
+		if seen[name] {
+			t.Errorf("event %q is defined twice", name)
+		}
+		seen[name] = true
+	}
+}
+
+func TestEvent(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("adding file", Event(EventFileAdd), KeyDest, "/q/web.container")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("invalid JSON log line: %v", err)
+	}
+	if rec[KeyEvent] != EventFileAdd {
+		t.Errorf("%s = %v, want %s", KeyEvent, rec[KeyEvent], EventFileAdd)
+	}
+	if rec[KeyDest] != "/q/web.container" {
+		t.Errorf("%s = %v", KeyDest, rec[KeyDest])
+	}
+}
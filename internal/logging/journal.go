@@ -0,0 +1,324 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// JournalSocket is the journald native protocol socket.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// journalIdentifier is the SYSLOG_IDENTIFIER of entries sent by
+// JournalHandler, matching what journald derives for stdout logging.
+const journalIdentifier = "quadsyncd"
+
+// Journal fields linking an entry to the units it concerns. journalctl -u
+// matches UNIT and OBJECT_SYSTEMD_UNIT; journalctl --user -u matches the
+// USER_ variants when the entry comes from the same user, so both sets are
+// written.
+var journalUnitFields = []string{"UNIT", "OBJECT_SYSTEMD_UNIT", "USER_UNIT", "OBJECT_SYSTEMD_USER_UNIT"}
+
+// journalFieldPrefix prefixes the journal fields that JournalHandler writes
+// for record attributes with structured fields on, e.g. SYNC_COMMIT.
+const journalFieldPrefix = "SYNC_"
+
+// JournalStreamConnected reports whether f is the stream systemd connected
+// to the journal, as announced by $JOURNAL_STREAM. Output that goes there can
+// be upgraded to the native protocol without losing anything.
+func JournalStreamConnected(f *os.File) bool {
+	stream := os.Getenv("JOURNAL_STREAM")
+	if stream == "" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	id, ok := fileID(info)
+	return ok && stream == id
+}
+
+// JournalHandler is a slog.Handler that sends records to journald over the
+// native protocol. MESSAGE holds the message followed by the attributes in
+// logfmt, so journalctl output reads like the text format. Records carrying a
+// "unit" or "units" attribute also get the journal unit fields, so
+// `journalctl --user -u app.service` shows quadsyncd's actions on app.service
+// next to the unit's own logs.
+type JournalHandler struct {
+	conn       net.Conn
+	level      slog.Leveler
+	attrs      []slog.Attr
+	groups     []string
+	fallback   slog.Handler
+	structured bool
+}
+
+// NewJournalHandler connects to the journald socket at path. Records that
+// cannot be sent (e.g. exceeding the datagram size limit) are passed to
+// fallback instead of being dropped.
+func NewJournalHandler(path string, level slog.Leveler, fallback slog.Handler) (*JournalHandler, error) {
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journal: %w", err)
+	}
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &JournalHandler{conn: conn, level: level, fallback: fallback}, nil
+}
+
+// WithStructuredFields returns a handler that also writes every attribute as
+// a journal field named after its key, upper-cased and prefixed with SYNC_
+// (commit becomes SYNC_COMMIT, run_id SYNC_RUN_ID), so `journalctl
+// SYNC_EVENT=unit.restart.failed` and similar filters work.
+func (h *JournalHandler) WithStructuredFields() *JournalHandler {
+	h2 := *h
+	h2.structured = true
+	return &h2
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *JournalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle encodes the record as a journal entry and sends it.
+func (h *JournalHandler) Handle(ctx context.Context, r slog.Record) error {
+	message, err := h.formatMessage(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	var entry bytes.Buffer
+	writeJournalField(&entry, "MESSAGE", message)
+	writeJournalField(&entry, "PRIORITY", journalPriority(r.Level))
+	writeJournalField(&entry, "SYSLOG_IDENTIFIER", journalIdentifier)
+	for _, unit := range h.units(r) {
+		for _, field := range journalUnitFields {
+			writeJournalField(&entry, field, unit)
+		}
+	}
+	if h.structured {
+		h.writeAttrFields(&entry, r)
+	}
+
+	if _, err := h.conn.Write(entry.Bytes()); err != nil {
+		if h.fallback != nil {
+			return h.fallback.Handle(ctx, r)
+		}
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return nil
+}
+
+// WithAttrs returns a new handler with the given attributes added.
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	newAttrs := make([]slog.Attr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(newAttrs, h.attrs)
+	newAttrs = append(newAttrs, attrs...)
+	h2 := *h
+	h2.attrs = newAttrs
+	if h.fallback != nil {
+		h2.fallback = h.fallback.WithAttrs(attrs)
+	}
+	return &h2
+}
+
+// WithGroup returns a new handler with the given group name added.
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	newGroups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(newGroups, h.groups)
+	newGroups = append(newGroups, name)
+	h2 := *h
+	h2.groups = newGroups
+	if h.fallback != nil {
+		h2.fallback = h.fallback.WithGroup(name)
+	}
+	return &h2
+}
+
+// Close closes the connection to journald.
+func (h *JournalHandler) Close() error {
+	return h.conn.Close()
+}
+
+// formatMessage renders the record message followed by its attributes in the
+// text handler's key=value form.
+func (h *JournalHandler) formatMessage(ctx context.Context, r slog.Record) (string, error) {
+	var buf bytes.Buffer
+	var th slog.Handler = slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	for _, g := range h.groups {
+		th = th.WithGroup(g)
+	}
+	th = th.WithAttrs(h.attrs)
+	if err := th.Handle(ctx, r); err != nil {
+		return "", err
+	}
+	attrs := strings.TrimSpace(buf.String())
+	if attrs == "" {
+		return r.Message, nil
+	}
+	return r.Message + " " + attrs, nil
+}
+
+// units returns the unit names named by top-level KeyUnit and KeyUnits
+// attributes of the handler and the record.
+func (h *JournalHandler) units(r slog.Record) []string {
+	if len(h.groups) > 0 {
+		return nil
+	}
+	var units []string
+	collect := func(a slog.Attr) bool {
+		switch a.Key {
+		case KeyUnit:
+			if s := a.Value.String(); s != "" {
+				units = append(units, s)
+			}
+		case KeyUnits:
+			if list, ok := a.Value.Any().([]string); ok {
+				units = append(units, list...)
+			}
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		collect(a)
+	}
+	r.Attrs(collect)
+	return units
+}
+
+// JournalEntry is a structured entry for WriteJournal. Fields are added to
+// MESSAGE, PRIORITY and SYSLOG_IDENTIFIER; empty values are omitted.
+type JournalEntry struct {
+	Message string
+	Level   slog.Level
+	Fields  map[string]string
+}
+
+// WriteJournal sends entries to the journald socket at path. Unlike
+// JournalHandler, which renders attributes into MESSAGE, the fields are
+// written as journal fields, so `journalctl FIELD=value` can match them.
+func WriteJournal(path string, entries []JournalEntry) error {
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		return fmt.Errorf("failed to connect to journal: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	for _, e := range entries {
+		var entry bytes.Buffer
+		writeJournalField(&entry, "MESSAGE", e.Message)
+		writeJournalField(&entry, "PRIORITY", journalPriority(e.Level))
+		writeJournalField(&entry, "SYSLOG_IDENTIFIER", journalIdentifier)
+		names := make([]string, 0, len(e.Fields))
+		for name, value := range e.Fields {
+			if value != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			writeJournalField(&entry, name, e.Fields[name])
+		}
+		if _, err := conn.Write(entry.Bytes()); err != nil {
+			return fmt.Errorf("failed to write journal entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeAttrFields writes the handler and record attributes as SYNC_ fields.
+// Group names become part of the field name.
+func (h *JournalHandler) writeAttrFields(buf *bytes.Buffer, r slog.Record) {
+	prefix := journalFieldPrefix
+	for _, g := range h.groups {
+		prefix += journalFieldName(g) + "_"
+	}
+	var write func(prefix string, a slog.Attr)
+	write = func(prefix string, a slog.Attr) {
+		v := a.Value.Resolve()
+		if v.Kind() == slog.KindGroup {
+			if a.Key != "" {
+				prefix += journalFieldName(a.Key) + "_"
+			}
+			for _, ga := range v.Group() {
+				write(prefix, ga)
+			}
+			return
+		}
+		name := journalFieldName(a.Key)
+		if name == "" {
+			return
+		}
+		value := v.String()
+		if list, ok := v.Any().([]string); ok {
+			value = strings.Join(list, " ")
+		}
+		writeJournalField(buf, prefix+name, value)
+	}
+	for _, a := range h.attrs {
+		write(prefix, a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		write(prefix, a)
+		return true
+	})
+}
+
+// journalFieldName turns an attribute key into journal field name
+// characters: upper case letters, digits and underscores.
+func journalFieldName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(key) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// journalPriority maps a slog level to a syslog priority.
+func journalPriority(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "3"
+	case level >= slog.LevelWarn:
+		return "4"
+	case level >= slog.LevelInfo:
+		return "6"
+	default:
+		return "7"
+	}
+}
+
+// writeJournalField appends one field in the journal native protocol. Values
+// containing a newline use the length-prefixed binary form.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(name + "=" + value + "\n")
+		return
+	}
+	buf.WriteString(name + "\n")
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
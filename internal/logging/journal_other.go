@@ -0,0 +1,10 @@
+//go:build !unix
+
+package logging
+
+import "os"
+
+// fileID reports no identity: there is no journal outside Unix systems.
+func fileID(os.FileInfo) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,295 @@
+//go:build unix
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// listenJournal starts a fake journald socket and returns its path and the
+// connection datagrams arrive on.
+func listenJournal(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+	// Socket paths are limited to ~108 bytes, so avoid the long t.TempDir.
+	dir, err := os.MkdirTemp("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return path, conn
+}
+
+// readJournalEntry reads one datagram and decodes its fields. Repeated
+// fields keep every value in order.
+func readJournalEntry(t *testing.T, conn *net.UnixConn) map[string][]string {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	data := buf[:n]
+	fields := make(map[string][]string)
+	for len(data) > 0 {
+		nl := bytes.IndexByte(data, '\n')
+		if nl < 0 {
+			t.Fatalf("unterminated field: %q", data)
+		}
+		line := data[:nl]
+		data = data[nl+1:]
+		if eq := bytes.IndexByte(line, '='); eq >= 0 {
+			fields[string(line[:eq])] = append(fields[string(line[:eq])], string(line[eq+1:]))
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[:8])
+		value := string(data[8 : 8+size])
+		data = data[8+size+1:]
+		fields[string(line)] = append(fields[string(line)], value)
+	}
+	return fields
+}
+
+func TestJournalHandler_Fields(t *testing.T) {
+	path, conn := listenJournal(t)
+	h, err := NewJournalHandler(path, slog.LevelDebug, nil)
+	if err != nil {
+		t.Fatalf("NewJournalHandler: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+	logger := slog.New(h).With(KeyRunID, "r1")
+
+	tests := []struct {
+		name      string
+		log       func()
+		message   string
+		priority  string
+		wantUnits []string
+	}{
+		{
+			name:     "plain message",
+			log:      func() { logger.Info("sync started", Event(EventSyncStarted)) },
+			message:  "sync started run_id=r1 event=sync.started",
+			priority: "6",
+		},
+		{
+			name: "single unit",
+			log: func() {
+				logger.Warn("restart failed", KeyUnit, "app.service")
+			},
+			message:   "restart failed run_id=r1 unit=app.service",
+			priority:  "4",
+			wantUnits: []string{"app.service"},
+		},
+		{
+			name: "unit list",
+			log: func() {
+				logger.Info("restarting", KeyUnits, []string{"a.service", "b.service"})
+			},
+			message:   "restarting run_id=r1 units=\"[a.service b.service]\"",
+			priority:  "6",
+			wantUnits: []string{"a.service", "b.service"},
+		},
+		{
+			name:     "multi-line value",
+			log:      func() { logger.Error("failed", KeyError, "line1\nline2") },
+			message:  "failed run_id=r1 error=\"line1\\nline2\"",
+			priority: "3",
+		},
+		{
+			name:     "multi-line message",
+			log:      func() { logger.Debug("a\nb") },
+			message:  "a\nb run_id=r1",
+			priority: "7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.log()
+			fields := readJournalEntry(t, conn)
+			if got := fields["MESSAGE"]; len(got) != 1 || got[0] != tt.message {
+				t.Errorf("MESSAGE = %q, want %q", got, tt.message)
+			}
+			if got := fields["PRIORITY"]; len(got) != 1 || got[0] != tt.priority {
+				t.Errorf("PRIORITY = %q, want %q", got, tt.priority)
+			}
+			if got := fields["SYSLOG_IDENTIFIER"]; len(got) != 1 || got[0] != "quadsyncd" {
+				t.Errorf("SYSLOG_IDENTIFIER = %q", got)
+			}
+			for _, field := range journalUnitFields {
+				if got := fields[field]; !reflect.DeepEqual(got, tt.wantUnits) {
+					t.Errorf("%s = %q, want %q", field, got, tt.wantUnits)
+				}
+			}
+		})
+	}
+}
+
+func TestJournalHandler_StructuredFields(t *testing.T) {
+	path, conn := listenJournal(t)
+	h, err := NewJournalHandler(path, slog.LevelDebug, nil)
+	if err != nil {
+		t.Fatalf("NewJournalHandler: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+	logger := slog.New(h.WithStructuredFields()).With(KeyRunID, "r1")
+
+	logger.Info("restarting", Event(EventUnitRestart), KeyCommit, "abc123", KeyUnits, []string{"a.service", "b.service"},
+		slog.Group("batch", slog.Int("size", 2)))
+	fields := readJournalEntry(t, conn)
+	want := map[string]string{
+		"MESSAGE":           `restarting run_id=r1 event=unit.restart commit=abc123 units="[a.service b.service]" batch.size=2`,
+		"SYNC_RUN_ID":       "r1",
+		"SYNC_EVENT":        "unit.restart",
+		"SYNC_COMMIT":       "abc123",
+		"SYNC_UNITS":        "a.service b.service",
+		"SYNC_BATCH_SIZE":   "2",
+		"PRIORITY":          "6",
+		"SYSLOG_IDENTIFIER": "quadsyncd",
+	}
+	for name, value := range want {
+		if got := fields[name]; len(got) != 1 || got[0] != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if got := fields["UNIT"]; !reflect.DeepEqual(got, []string{"a.service", "b.service"}) {
+		t.Errorf("UNIT = %q, want both units", got)
+	}
+
+	// Without structured fields, attributes only appear in MESSAGE.
+	slog.New(h).Info("plain", KeyCommit, "abc123")
+	if fields := readJournalEntry(t, conn); fields["SYNC_COMMIT"] != nil {
+		t.Errorf("SYNC_COMMIT = %q, want no attribute fields", fields["SYNC_COMMIT"])
+	}
+}
+
+func TestJournalHandler_Level(t *testing.T) {
+	path, _ := listenJournal(t)
+	h, err := NewJournalHandler(path, slog.LevelWarn, nil)
+	if err != nil {
+		t.Fatalf("NewJournalHandler: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+	if h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("info should be disabled at warn level")
+	}
+	if !h.Enabled(context.Background(), slog.LevelError) {
+		t.Error("error should be enabled at warn level")
+	}
+}
+
+func TestJournalHandler_FallbackWhenUnsent(t *testing.T) {
+	path, conn := listenJournal(t)
+	var out bytes.Buffer
+	h, err := NewJournalHandler(path, slog.LevelInfo, slog.NewTextHandler(&out, nil))
+	if err != nil {
+		t.Fatalf("NewJournalHandler: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+
+	// With the listener gone the datagram cannot be delivered.
+	_ = conn.Close()
+	_ = os.Remove(path)
+	slog.New(h).Info("still logged", KeyUnit, "app.service")
+	if !bytes.Contains(out.Bytes(), []byte("still logged")) {
+		t.Errorf("fallback output = %q, want the record", out.String())
+	}
+}
+
+func TestWriteJournal(t *testing.T) {
+	path, conn := listenJournal(t)
+	entries := []JournalEntry{
+		{Message: "audit: add web.container", Level: slog.LevelInfo, Fields: map[string]string{"QUADSYNCD_ACTION": "add", "QUADSYNCD_ERROR": ""}},
+		{Message: "audit: restart_failed web.service", Level: slog.LevelWarn, Fields: map[string]string{"QUADSYNCD_ERROR": "exit 1\nstatus=1"}},
+	}
+	if err := WriteJournal(path, entries); err != nil {
+		t.Fatalf("WriteJournal() error = %v", err)
+	}
+
+	first := readJournalEntry(t, conn)
+	want := map[string][]string{
+		"MESSAGE":           {"audit: add web.container"},
+		"PRIORITY":          {"6"},
+		"SYSLOG_IDENTIFIER": {"quadsyncd"},
+		"QUADSYNCD_ACTION":  {"add"},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("first entry = %v, want %v", first, want)
+	}
+	second := readJournalEntry(t, conn)
+	if got := second["QUADSYNCD_ERROR"]; len(got) != 1 || got[0] != "exit 1\nstatus=1" {
+		t.Errorf("QUADSYNCD_ERROR = %q, want the multi-line value", got)
+	}
+	if got := second["PRIORITY"]; len(got) != 1 || got[0] != "4" {
+		t.Errorf("PRIORITY = %q, want 4", got)
+	}
+}
+
+func TestWriteJournal_NoSocket(t *testing.T) {
+	if err := WriteJournal(filepath.Join(t.TempDir(), "missing"), []JournalEntry{{Message: "x"}}); err == nil {
+		t.Error("expected an error when the journal socket does not exist")
+	}
+}
+
+func TestNewJournalHandler_NoSocket(t *testing.T) {
+	if _, err := NewJournalHandler(filepath.Join(t.TempDir(), "missing"), nil, nil); err == nil {
+		t.Error("expected an error when the journal socket does not exist")
+	}
+}
+
+func TestJournalStreamConnected(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	other, err := os.CreateTemp(t.TempDir(), "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = other.Close() }()
+
+	t.Setenv("JOURNAL_STREAM", "")
+	if JournalStreamConnected(f) {
+		t.Error("no JOURNAL_STREAM should not match")
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JOURNAL_STREAM", journalStreamID(t, info))
+	if !JournalStreamConnected(f) {
+		t.Error("JOURNAL_STREAM naming the file should match")
+	}
+	if JournalStreamConnected(other) {
+		t.Error("a different file should not match")
+	}
+}
+
+// journalStreamID formats info the way systemd sets $JOURNAL_STREAM.
+func journalStreamID(t *testing.T, info os.FileInfo) string {
+	t.Helper()
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Skip("file identity not available on this platform")
+	}
+	return fmt.Sprintf("%d:%d", st.Dev, st.Ino)
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package logging
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileID returns the device and inode numbers of info in the
+// "<device>:<inode>" form $JOURNAL_STREAM uses.
+func fileID(info os.FileInfo) (string, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%d", st.Dev, st.Ino), true
+}
@@ -0,0 +1,130 @@
+package multirepo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Checksum manifest files read from the root of a checkout when
+// checksums.verify is set. They are never synced.
+const (
+	ChecksumFileName      = "MANIFEST.sha256"
+	ChecksumSignatureName = ChecksumFileName + ".sig"
+)
+
+// isChecksumFile reports whether absPath is the checksum manifest or its
+// signature at the root of the checkout repoDir.
+func isChecksumFile(repoDir, absPath string) bool {
+	dir, name := filepath.Split(absPath)
+	return filepath.Clean(dir) == filepath.Clean(repoDir) && (name == ChecksumFileName || name == ChecksumSignatureName)
+}
+
+// ParseChecksums reads a checksum manifest in the format of sha256sum: one
+// "<hex digest>  <path>" line per file, the path relative to the checkout
+// root and optionally prefixed with "*" (binary mode) or "./". Blank lines
+// and lines starting with "#" are ignored. It returns the lowercase digests
+// by slash-separated path.
+func ParseChecksums(data []byte) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		if _, err := hex.DecodeString(sum); !ok || err != nil || len(sum) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("%s line %d: want \"<sha256>  <path>\"", ChecksumFileName, n)
+		}
+		key, err := normalizeMergeKey(filepath.FromSlash(name))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: unsafe path %s: %w", ChecksumFileName, n, name, err)
+		}
+		if prev, dup := sums[key]; dup && prev != strings.ToLower(sum) {
+			return nil, fmt.Errorf("%s line %d: %s is listed twice with different digests", ChecksumFileName, n, key)
+		}
+		sums[key] = strings.ToLower(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ChecksumFileName, err)
+	}
+	return sums, nil
+}
+
+// VerifyChecksums checks the files of rs against the checksum manifest at
+// the root of its checkout. Every file the repository provides must be
+// listed with the SHA-256 of its content in the checkout, and every listed
+// file inside the repository's source directories must exist, so a file
+// removed in transit is not pruned either. All mismatches are reported in
+// one error.
+func VerifyChecksums(rs RepoState) error {
+	data, err := os.ReadFile(filepath.Join(rs.Dir, ChecksumFileName))
+	if err != nil {
+		return fmt.Errorf("checksum verification requires %s: %w", ChecksumFileName, err)
+	}
+	sums, err := ParseChecksums(data)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	provided := make(map[string]bool, len(rs.Files))
+	for _, f := range rs.Files {
+		rel, err := filepath.Rel(rs.Dir, f.AbsPath)
+		if err != nil {
+			return fmt.Errorf("failed to compute relative path for %s: %w", f.AbsPath, err)
+		}
+		key := filepath.ToSlash(rel)
+		if provided[key] {
+			continue
+		}
+		provided[key] = true
+		want, ok := sums[key]
+		if !ok {
+			problems = append(problems, key+" is not listed")
+			continue
+		}
+		content, err := os.ReadFile(f.AbsPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.AbsPath, err)
+		}
+		got := sha256.Sum256(content)
+		if hex.EncodeToString(got[:]) != want {
+			problems = append(problems, key+" does not match its digest")
+		}
+	}
+	for key := range sums {
+		if provided[key] || !withinAny(key, rs.Spec.SourceDirs()) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(rs.Dir, filepath.FromSlash(key))); os.IsNotExist(err) {
+			problems = append(problems, key+" is listed but missing")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%s verification failed: %s", ChecksumFileName, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// withinAny reports whether the slash-separated path key lies in one of
+// dirs, where "." is the checkout root.
+func withinAny(key string, dirs []string) bool {
+	for _, dir := range dirs {
+		if dir == "." || strings.HasPrefix(key, path.Clean(dir)+"/") {
+			return true
+		}
+	}
+	return false
+}
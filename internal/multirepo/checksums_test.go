@@ -0,0 +1,141 @@
+package multirepo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseChecksums(t *testing.T) {
+	web := sha256Hex("web")
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "sha256sum output",
+			data: web + "  quadlets/web.container\n" + strings.ToUpper(web) + " *./quadlets/app.env\n\n# comment\n",
+			want: map[string]string{"quadlets/web.container": web, "quadlets/app.env": web},
+		},
+		{name: "short digest", data: "abc  web.container\n", wantErr: "line 1"},
+		{name: "missing path", data: web + "\n", wantErr: "line 1"},
+		{name: "traversal", data: web + "  ../web.container\n", wantErr: "unsafe path"},
+		{name: "conflicting duplicate", data: web + "  a\n" + sha256Hex("other") + "  a\n", wantErr: "listed twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChecksums([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseChecksums() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseChecksums() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseChecksums() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("ParseChecksums()[%q] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	files := map[string]string{
+		"quadlets/web.container": "[Container]\nImage=nginx\n",
+		"quadlets/app.env":       "A=1\n",
+		"README.md":              "docs\n",
+	}
+	tests := []struct {
+		name     string
+		manifest func(sums map[string]string)
+		noFile   bool
+		wantErr  string
+	}{
+		{name: "all listed"},
+		{name: "no manifest", noFile: true, wantErr: "requires MANIFEST.sha256"},
+		{name: "unlisted file", manifest: func(s map[string]string) { delete(s, "quadlets/app.env") }, wantErr: "quadlets/app.env is not listed"},
+		{name: "tampered file", manifest: func(s map[string]string) { s["quadlets/web.container"] = sha256Hex("old") }, wantErr: "quadlets/web.container does not match"},
+		{name: "removed file", manifest: func(s map[string]string) { s["quadlets/db.container"] = sha256Hex("db") }, wantErr: "quadlets/db.container is listed but missing"},
+		{name: "listed file outside subdir", manifest: func(s map[string]string) { s["other/x"] = sha256Hex("x") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoDir := t.TempDir()
+			sums := make(map[string]string)
+			for name, content := range files {
+				path := filepath.Join(repoDir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				sums[name] = sha256Hex(content)
+			}
+			if tt.manifest != nil {
+				tt.manifest(sums)
+			}
+			if !tt.noFile {
+				var b strings.Builder
+				for name, sum := range sums {
+					b.WriteString(sum + "  " + name + "\n")
+				}
+				if err := os.WriteFile(filepath.Join(repoDir, ChecksumFileName), []byte(b.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			srcDir := filepath.Join(repoDir, "quadlets")
+			repoFiles, err := loadRepoFiles(srcDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = VerifyChecksums(RepoState{Spec: config.RepoSpec{Subdir: "quadlets"}, Dir: repoDir, Files: repoFiles})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyChecksums() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyChecksums() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsChecksumFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/repo/MANIFEST.sha256", true},
+		{"/repo/MANIFEST.sha256.sig", true},
+		{"/repo/quadlets/MANIFEST.sha256", false},
+		{"/repo/web.container", false},
+	}
+	for _, tt := range tests {
+		if got := isChecksumFile("/repo", tt.path); got != tt.want {
+			t.Errorf("isChecksumFile(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
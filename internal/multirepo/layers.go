@@ -0,0 +1,106 @@
+package multirepo
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// loadLayers discovers the files of each layer directory under root and
+// overlays them in order: a file in a later layer replaces the file at the
+// same relative path in earlier ones. The first layer must exist; later ones
+// may be missing, so hosts without overrides need no directory. The layers'
+// manifests are merged the same way, entries keyed by source (files) or
+// name (secrets).
+//
+// A path that is a file in one layer and a directory in another cannot be
+// overlaid and fails the load.
+func loadLayers(root string, layers []string) ([]RepoFile, Manifest, error) {
+	byKey := make(map[string]RepoFile)
+	var manifest Manifest
+	for i, layer := range layers {
+		dir := filepath.Join(root, layer)
+		info, err := os.Stat(dir)
+		switch {
+		case os.IsNotExist(err) && i > 0:
+			continue
+		case err != nil:
+			return nil, Manifest{}, fmt.Errorf("layer %s: %w", layer, err)
+		case !info.IsDir():
+			return nil, Manifest{}, fmt.Errorf("layer %s is not a directory", layer)
+		}
+
+		files, err := loadRepoFiles(dir)
+		if err != nil {
+			return nil, Manifest{}, fmt.Errorf("layer %s: %w", layer, err)
+		}
+		for _, f := range files {
+			f.Layer = layer
+			byKey[f.MergeKey] = f
+		}
+
+		m, err := loadManifest(dir)
+		if err != nil {
+			return nil, Manifest{}, fmt.Errorf("layer %s: %w", layer, err)
+		}
+		manifest = overlayManifest(manifest, m)
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	files := make([]RepoFile, 0, len(keys))
+	for _, k := range keys {
+		for dir := path.Dir(k); dir != "."; dir = path.Dir(dir) {
+			if f, ok := byKey[dir]; ok {
+				return nil, Manifest{}, fmt.Errorf("layer conflict: %s is a file in layer %s and a directory in layer %s",
+					dir, f.Layer, byKey[k].Layer)
+			}
+		}
+		files = append(files, byKey[k])
+	}
+	return files, manifest, nil
+}
+
+// overlayManifest returns base with the entries of top added, replacing file
+// entries with the same source and secrets with the same name.
+func overlayManifest(base, top Manifest) Manifest {
+	out := Manifest{}
+	for _, f := range base.Files {
+		if !manifestHasSource(top, f.Source) {
+			out.Files = append(out.Files, f)
+		}
+	}
+	out.Files = append(out.Files, top.Files...)
+
+	for _, s := range base.Secrets {
+		replaced := false
+		for _, t := range top.Secrets {
+			replaced = replaced || t.Name == s.Name
+		}
+		if !replaced {
+			out.Secrets = append(out.Secrets, s)
+		}
+	}
+	out.Secrets = append(out.Secrets, top.Secrets...)
+	return out
+}
+
+// manifestHasSource reports whether m declares a file for source.
+func manifestHasSource(m Manifest, source string) bool {
+	key, err := normalizeMergeKey(source)
+	if err != nil {
+		return false
+	}
+	for _, f := range m.Files {
+		if k, err := normalizeMergeKey(f.Source); err == nil && k == key {
+			return true
+		}
+	}
+	return false
+}
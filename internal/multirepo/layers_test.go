@@ -0,0 +1,92 @@
+package multirepo
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/git"
+)
+
+func TestLoadRepoState_Layers(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	writeTree(t, repoDir, map[string]string{
+		"base/web.container":      "[Container]\nImage=nginx:1\nEnvironmentFile=web.env\n",
+		"base/web.env":            "PORT=80\n",
+		"base/db.container":       "[Container]\nImage=postgres:16\n",
+		"base/.quadsyncd.yaml":    "files:\n  - source: proxy.conf\n    dest: /etc/base.conf\n",
+		"base/proxy.conf":         "base\n",
+		"hosts/web1/web.env":      "PORT=8080\n",
+		"hosts/web1/cache.volume": "[Volume]\n",
+		"hosts/web1/proxy.conf":   "web1\n",
+		"hosts/web2/db.container": "[Container]\nImage=postgres:17\n",
+	})
+
+	spec := makeSpec("https://example.com/repo", "refs/heads/main", 0)
+	spec.Layers = []string{"base", "hosts/web1", "hosts/missing"}
+	rs, err := LoadRepoState(context.Background(), spec, repoDir, repoDir, git.CheckoutOptions{}, &mockGitClient{commit: "abc"})
+	if err != nil {
+		t.Fatalf("LoadRepoState() error = %v", err)
+	}
+
+	layers := make(map[string]string)
+	byKey := make(map[string]RepoFile)
+	for _, f := range rs.Files {
+		layers[f.MergeKey] = f.Layer
+		byKey[f.MergeKey] = f
+	}
+	want := map[string]string{
+		"web.container": "base",
+		"web.env":       "hosts/web1",
+		"db.container":  "base",
+		"cache.volume":  "hosts/web1",
+		"proxy.conf":    "hosts/web1",
+	}
+	if !reflect.DeepEqual(layers, want) {
+		t.Errorf("file layers = %v, want %v", layers, want)
+	}
+	if got := byKey["web.env"]; !strings.HasPrefix(got.AbsPath, filepath.Join(repoDir, "hosts", "web1")) || !reflect.DeepEqual(got.RestartUnits, []string{"web.service"}) {
+		t.Errorf("web.env = %+v, want the host layer's file restarting web.service", got)
+	}
+	// The base manifest still applies to the overriding file.
+	if got := byKey["proxy.conf"]; got.DestPath != "/etc/base.conf" {
+		t.Errorf("proxy.conf dest = %q, want /etc/base.conf", got.DestPath)
+	}
+}
+
+func TestLoadLayers_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		layers  []string
+		wantErr string
+	}{
+		{
+			name:    "missing base layer",
+			files:   map[string]string{"hosts/web1/web.container": "[Container]\n"},
+			layers:  []string{"base", "hosts/web1"},
+			wantErr: "layer base",
+		},
+		{
+			name: "file and directory",
+			files: map[string]string{
+				"base/conf":            "x\n",
+				"hosts/web1/conf/a.cf": "y\n",
+			},
+			layers:  []string{"base", "hosts/web1"},
+			wantErr: "conf is a file in layer base and a directory in layer hosts/web1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, tt.files)
+			_, _, err := loadLayers(root, tt.layers)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadLayers() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package multirepo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFileName is the repo manifest read from the root of each
+// repository's source directory. Being a dotfile, it is never synced itself.
+const ManifestFileName = ".quadsyncd.yaml"
+
+// Manifest declares repo-level sync metadata.
+type Manifest struct {
+	// Files are managed config files placed outside the quadlet directory,
+	// e.g. reverse-proxy configs that containers bind-mount.
+	Files []ManifestFile `yaml:"files"`
+	// Secrets are encrypted files loaded into podman secrets instead of
+	// being synced.
+	Secrets []ManifestSecret `yaml:"secrets"`
+}
+
+// ManifestFile maps a repo file to an absolute destination on the host and
+// the units to restart when it changes.
+type ManifestFile struct {
+	// Source is relative to the repository source directory.
+	Source string `yaml:"source"`
+	// Dest is an absolute host path; environment variables are expanded.
+	Dest string `yaml:"dest"`
+	// Restart lists systemd units to try-restart when the file changes.
+	Restart []string `yaml:"restart"`
+}
+
+// ManifestSecret maps an encrypted repo file to a podman secret.
+type ManifestSecret struct {
+	// Name is the podman secret name.
+	Name string `yaml:"name"`
+	// Source is relative to the repository source directory.
+	Source string `yaml:"source"`
+	// Format is "age" or "sops"; empty detects it from the extension.
+	Format string `yaml:"format"`
+	// Restart lists systemd units to try-restart when the secret changes, in
+	// addition to the managed quadlets referencing it with Secret=.
+	Restart []string `yaml:"restart"`
+}
+
+// RepoSecret is a podman secret declared in a repository manifest.
+type RepoSecret struct {
+	Name string
+	// Source is the normalised repo-relative path of the encrypted file.
+	Source string
+	// AbsPath is the encrypted file in the checkout.
+	AbsPath      string
+	Format       string
+	RestartUnits []string
+}
+
+// loadManifest reads the manifest from srcDir. A missing manifest yields an
+// empty Manifest.
+func loadManifest(srcDir string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(filepath.Join(srcDir, ManifestFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return m, fmt.Errorf("failed to read %s: %w", ManifestFileName, err)
+	}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("failed to parse %s: %w", ManifestFileName, err)
+	}
+	return m, nil
+}
+
+// applyManifest redirects files declared in m away from the quadlet directory:
+// each declared source gets a DestPath and restart units. Sources that are not
+// present in files are rejected so typos do not go unnoticed.
+func applyManifest(m Manifest, files []RepoFile) ([]RepoFile, error) {
+	if len(m.Files) == 0 {
+		return files, nil
+	}
+
+	bySource := make(map[string]RepoFile, len(files))
+	for _, f := range files {
+		bySource[f.MergeKey] = f
+	}
+
+	declared := make(map[string]bool, len(m.Files))
+	seenDest := make(map[string]bool, len(m.Files))
+	var external []RepoFile
+	for i, mf := range m.Files {
+		label := fmt.Sprintf("%s: files[%d]", ManifestFileName, i)
+
+		key, err := normalizeMergeKey(mf.Source)
+		if err != nil || mf.Source == "" {
+			return nil, fmt.Errorf("%s: invalid source %q", label, mf.Source)
+		}
+		src, ok := bySource[key]
+		if !ok {
+			return nil, fmt.Errorf("%s: source %q not found", label, mf.Source)
+		}
+
+		dest := filepath.Clean(os.ExpandEnv(mf.Dest))
+		if mf.Dest == "" || !filepath.IsAbs(dest) {
+			return nil, fmt.Errorf("%s: dest must be an absolute path: %q", label, mf.Dest)
+		}
+		if seenDest[dest] {
+			return nil, fmt.Errorf("%s: duplicate dest %s", label, dest)
+		}
+		seenDest[dest] = true
+
+		if err := validateRestartUnits(mf.Restart); err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+
+		declared[key] = true
+		external = append(external, RepoFile{
+			MergeKey:     key,
+			AbsPath:      src.AbsPath,
+			DestPath:     dest,
+			RestartUnits: append(append([]string(nil), src.RestartUnits...), mf.Restart...),
+			Layer:        src.Layer,
+		})
+	}
+
+	out := make([]RepoFile, 0, len(files))
+	for _, f := range files {
+		if !declared[f.MergeKey] {
+			out = append(out, f)
+		}
+	}
+	return append(out, external...), nil
+}
+
+// applyManifestSecrets takes the files declared as secrets in m out of files,
+// so encrypted secrets are never copied into the quadlet directory, and
+// returns them as RepoSecrets.
+func applyManifestSecrets(m Manifest, files []RepoFile) ([]RepoFile, []RepoSecret, error) {
+	if len(m.Secrets) == 0 {
+		return files, nil, nil
+	}
+
+	bySource := make(map[string]RepoFile, len(files))
+	for _, f := range files {
+		bySource[f.MergeKey] = f
+	}
+
+	declared := make(map[string]bool, len(m.Secrets))
+	seenName := make(map[string]bool, len(m.Secrets))
+	var out []RepoSecret
+	for i, ms := range m.Secrets {
+		label := fmt.Sprintf("%s: secrets[%d]", ManifestFileName, i)
+
+		if !secrets.ValidName(ms.Name) {
+			return nil, nil, fmt.Errorf("%s: invalid secret name %q", label, ms.Name)
+		}
+		if seenName[ms.Name] {
+			return nil, nil, fmt.Errorf("%s: duplicate secret name %s", label, ms.Name)
+		}
+		seenName[ms.Name] = true
+
+		key, err := normalizeMergeKey(ms.Source)
+		if err != nil || ms.Source == "" {
+			return nil, nil, fmt.Errorf("%s: invalid source %q", label, ms.Source)
+		}
+		src, ok := bySource[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: source %q not found", label, ms.Source)
+		}
+		if src.DestPath != "" {
+			return nil, nil, fmt.Errorf("%s: source %q is also listed under files", label, ms.Source)
+		}
+
+		format := ms.Format
+		switch format {
+		case "":
+			format = secrets.DetectFormat(key)
+		case secrets.FormatAge, secrets.FormatSOPS:
+		default:
+			return nil, nil, fmt.Errorf("%s: format must be %s or %s: %q", label, secrets.FormatAge, secrets.FormatSOPS, ms.Format)
+		}
+		if err := validateRestartUnits(ms.Restart); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", label, err)
+		}
+
+		declared[key] = true
+		out = append(out, RepoSecret{
+			Name:         ms.Name,
+			Source:       key,
+			AbsPath:      src.AbsPath,
+			Format:       format,
+			RestartUnits: ms.Restart,
+		})
+	}
+
+	kept := make([]RepoFile, 0, len(files))
+	for _, f := range files {
+		if !declared[f.MergeKey] {
+			kept = append(kept, f)
+		}
+	}
+	return kept, out, nil
+}
+
+// validateRestartUnits rejects empty unit names and names with slashes or
+// spaces.
+func validateRestartUnits(units []string) error {
+	for _, unit := range units {
+		if strings.TrimSpace(unit) == "" || strings.ContainsAny(unit, "/ ") {
+			return fmt.Errorf("invalid restart unit %q", unit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package multirepo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyManifest(t *testing.T) {
+	files := []RepoFile{
+		{MergeKey: "web.container", AbsPath: "/repo/web.container"},
+		{MergeKey: "caddy/Caddyfile", AbsPath: "/repo/caddy/Caddyfile"},
+	}
+
+	tests := []struct {
+		name     string
+		manifest Manifest
+		want     []RepoFile
+		wantErr  bool
+	}{
+		{
+			name:     "empty manifest",
+			manifest: Manifest{},
+			want:     files,
+		},
+		{
+			name: "redirects declared file",
+			manifest: Manifest{Files: []ManifestFile{
+				{Source: "./caddy/Caddyfile", Dest: "/etc/caddy/Caddyfile", Restart: []string{"caddy.service"}},
+			}},
+			want: []RepoFile{
+				{MergeKey: "web.container", AbsPath: "/repo/web.container"},
+				{MergeKey: "caddy/Caddyfile", AbsPath: "/repo/caddy/Caddyfile", DestPath: "/etc/caddy/Caddyfile", RestartUnits: []string{"caddy.service"}},
+			},
+		},
+		{
+			name:     "unknown source",
+			manifest: Manifest{Files: []ManifestFile{{Source: "missing.conf", Dest: "/etc/missing.conf"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "traversal source",
+			manifest: Manifest{Files: []ManifestFile{{Source: "../secret", Dest: "/etc/secret"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "relative dest",
+			manifest: Manifest{Files: []ManifestFile{{Source: "caddy/Caddyfile", Dest: "caddy/Caddyfile"}}},
+			wantErr:  true,
+		},
+		{
+			name: "duplicate dest",
+			manifest: Manifest{Files: []ManifestFile{
+				{Source: "caddy/Caddyfile", Dest: "/etc/a"},
+				{Source: "web.container", Dest: "/etc/a"},
+			}},
+			wantErr: true,
+		},
+		{
+			name:     "invalid restart unit",
+			manifest: Manifest{Files: []ManifestFile{{Source: "caddy/Caddyfile", Dest: "/etc/a", Restart: []string{"../x"}}}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyManifest(tt.manifest, files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyManifest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyManifestSecrets(t *testing.T) {
+	files := []RepoFile{
+		{MergeKey: "web.container", AbsPath: "/repo/web.container"},
+		{MergeKey: "secrets/db.age", AbsPath: "/repo/secrets/db.age"},
+		{MergeKey: "secrets/api.yaml", AbsPath: "/repo/secrets/api.yaml"},
+		{MergeKey: "caddy/Caddyfile", AbsPath: "/repo/caddy/Caddyfile", DestPath: "/etc/caddy/Caddyfile"},
+	}
+
+	tests := []struct {
+		name        string
+		manifest    Manifest
+		wantFiles   []RepoFile
+		wantSecrets []RepoSecret
+		wantErr     bool
+	}{
+		{
+			name:      "no secrets",
+			manifest:  Manifest{},
+			wantFiles: files,
+		},
+		{
+			name: "secrets are taken out of the synced files",
+			manifest: Manifest{Secrets: []ManifestSecret{
+				{Name: "db-password", Source: "secrets/db.age", Restart: []string{"backup.service"}},
+				{Name: "api-token", Source: "./secrets/api.yaml"},
+			}},
+			wantFiles: []RepoFile{files[0], files[3]},
+			wantSecrets: []RepoSecret{
+				{Name: "db-password", Source: "secrets/db.age", AbsPath: "/repo/secrets/db.age", Format: "age", RestartUnits: []string{"backup.service"}},
+				{Name: "api-token", Source: "secrets/api.yaml", AbsPath: "/repo/secrets/api.yaml", Format: "sops"},
+			},
+		},
+		{
+			name:      "explicit format",
+			manifest:  Manifest{Secrets: []ManifestSecret{{Name: "db", Source: "secrets/api.yaml", Format: "age"}}},
+			wantFiles: []RepoFile{files[0], files[1], files[3]},
+			wantSecrets: []RepoSecret{
+				{Name: "db", Source: "secrets/api.yaml", AbsPath: "/repo/secrets/api.yaml", Format: "age"},
+			},
+		},
+		{
+			name:     "invalid name",
+			manifest: Manifest{Secrets: []ManifestSecret{{Name: "db password", Source: "secrets/db.age"}}},
+			wantErr:  true,
+		},
+		{
+			name: "duplicate name",
+			manifest: Manifest{Secrets: []ManifestSecret{
+				{Name: "db", Source: "secrets/db.age"},
+				{Name: "db", Source: "secrets/api.yaml"},
+			}},
+			wantErr: true,
+		},
+		{
+			name:     "unknown source",
+			manifest: Manifest{Secrets: []ManifestSecret{{Name: "db", Source: "secrets/missing.age"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "source also declared as file",
+			manifest: Manifest{Secrets: []ManifestSecret{{Name: "caddy", Source: "caddy/Caddyfile"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown format",
+			manifest: Manifest{Secrets: []ManifestSecret{{Name: "db", Source: "secrets/db.age", Format: "gpg"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid restart unit",
+			manifest: Manifest{Secrets: []ManifestSecret{{Name: "db", Source: "secrets/db.age", Restart: []string{""}}}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotFiles, gotSecrets, err := applyManifestSecrets(tt.manifest, files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyManifestSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(gotFiles, tt.wantFiles) {
+				t.Errorf("files = %+v, want %+v", gotFiles, tt.wantFiles)
+			}
+			if !reflect.DeepEqual(gotSecrets, tt.wantSecrets) {
+				t.Errorf("secrets = %+v, want %+v", gotSecrets, tt.wantSecrets)
+			}
+		})
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := loadManifest(dir)
+	if err != nil || len(m.Files) != 0 {
+		t.Fatalf("missing manifest: got %+v, %v", m, err)
+	}
+
+	t.Setenv("QUADSYNCD_TEST_ROOT", "/srv")
+	content := "files:\n  - source: app.conf\n    dest: ${QUADSYNCD_TEST_ROOT}/app.conf\n    restart: [app.service]\n"
+	if err := os.WriteFile(filepath.Join(dir, ManifestFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err = loadManifest(dir)
+	if err != nil {
+		t.Fatalf("loadManifest: %v", err)
+	}
+	got, err := applyManifest(m, []RepoFile{{MergeKey: "app.conf", AbsPath: "/r/app.conf"}})
+	if err != nil {
+		t.Fatalf("applyManifest: %v", err)
+	}
+	if got[0].DestPath != "/srv/app.conf" {
+		t.Errorf("DestPath = %q, want env-expanded /srv/app.conf", got[0].DestPath)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ManifestFileName), []byte("files: {"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadManifest(dir); err == nil {
+		t.Error("expected parse error")
+	}
+}
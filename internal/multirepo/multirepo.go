@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -22,13 +23,26 @@ type RepoFile struct {
 	MergeKey string
 	// AbsPath is the absolute filesystem path in the checkout.
 	AbsPath string
+	// DestPath, when set, is the absolute host path declared in the repo
+	// manifest; the file is placed there instead of the quadlet directory.
+	DestPath string
+	// RestartUnits are units to restart when the file changes: those listed
+	// in the repo manifest and those of quadlets referencing the file.
+	RestartUnits []string
+	// Layer is the checkout-relative layer the file was taken from; empty
+	// for repositories without layers.
+	Layer string
 }
 
 // RepoState holds the result of loading a single repository.
 type RepoState struct {
 	Spec   config.RepoSpec
 	Commit string
-	Files  []RepoFile
+	// Dir is the checkout directory.
+	Dir   string
+	Files []RepoFile
+	// Secrets are the podman secrets declared in the repository manifest.
+	Secrets []RepoSecret
 }
 
 // EffectiveItem is a file selected for the effective state after merging.
@@ -43,6 +57,20 @@ type EffectiveItem struct {
 	SourceRef string
 	// SourceSHA is the resolved commit SHA.
 	SourceSHA string
+	// DestPath, RestartUnits and SourceLayer are copied from the winning
+	// RepoFile.
+	DestPath     string
+	RestartUnits []string
+	SourceLayer  string
+}
+
+// key returns the identity used for conflict detection: the destination path
+// for manifest-declared files, the merge key otherwise.
+func (f RepoFile) key() string {
+	if f.DestPath != "" {
+		return f.DestPath
+	}
+	return f.MergeKey
 }
 
 // Conflict records a same-path conflict between two or more repositories.
@@ -59,22 +87,52 @@ type MergeResult struct {
 }
 
 // LoadRepoState checks out a repository and discovers all manageable files in
-// it.  It rejects symlinks and path-unsafe entries.
-func LoadRepoState(ctx context.Context, spec config.RepoSpec, repoDir, srcDir string, gitClient git.Client) (RepoState, error) {
-	commit, err := gitClient.EnsureCheckout(ctx, spec.URL, spec.Ref, repoDir)
+// it.  It rejects symlinks and path-unsafe entries. opts sets the sparse
+// directories and submodule parallelism of the checkout; depth, filter and
+// submodules come from spec. When spec has layers, srcDir is the checkout
+// root they are relative to.
+func LoadRepoState(ctx context.Context, spec config.RepoSpec, repoDir, srcDir string, opts git.CheckoutOptions, gitClient git.Client) (RepoState, error) {
+	opts.Depth = spec.CloneDepth
+	opts.Filter = spec.Filter
+	opts.Submodules = spec.Submodules
+	commit, err := gitClient.EnsureCheckout(ctx, spec.URL, spec.Ref, repoDir, opts)
 	if err != nil {
 		return RepoState{}, fmt.Errorf("repo %s: checkout failed: %w", spec.URL, err)
 	}
 
-	files, err := loadRepoFiles(srcDir)
+	var files []RepoFile
+	var manifest Manifest
+	if len(spec.Layers) == 0 {
+		files, err = loadRepoFiles(srcDir)
+		if err == nil {
+			manifest, err = loadManifest(srcDir)
+		}
+	} else {
+		files, manifest, err = loadLayers(srcDir, spec.Layers)
+	}
+	if err != nil {
+		return RepoState{}, fmt.Errorf("repo %s: %w", spec.URL, err)
+	}
+	files = slices.DeleteFunc(files, func(f RepoFile) bool { return isChecksumFile(repoDir, f.AbsPath) })
+	files, err = resolveReferences(srcDir, files)
+	if err != nil {
+		return RepoState{}, fmt.Errorf("repo %s: %w", spec.URL, err)
+	}
+	files, err = applyManifest(manifest, files)
+	if err != nil {
+		return RepoState{}, fmt.Errorf("repo %s: %w", spec.URL, err)
+	}
+	files, repoSecrets, err := applyManifestSecrets(manifest, files)
 	if err != nil {
 		return RepoState{}, fmt.Errorf("repo %s: %w", spec.URL, err)
 	}
 
 	return RepoState{
-		Spec:   spec,
-		Commit: commit,
-		Files:  files,
+		Spec:    spec,
+		Commit:  commit,
+		Dir:     repoDir,
+		Files:   files,
+		Secrets: repoSecrets,
 	}, nil
 }
 
@@ -161,8 +219,12 @@ func Merge(states []RepoState, conflictMode config.ConflictMode) (MergeResult, e
 				SourceRepo: s.Spec.URL,
 				SourceRef:  s.Spec.Ref,
 				SourceSHA:  s.Commit,
+
+				DestPath:     f.DestPath,
+				RestartUnits: f.RestartUnits,
+				SourceLayer:  f.Layer,
 			}
-			candidates[f.MergeKey] = append(candidates[f.MergeKey], candidate{item: item, rank: rank})
+			candidates[f.key()] = append(candidates[f.key()], candidate{item: item, rank: rank})
 		}
 	}
 
@@ -237,7 +299,7 @@ func detectUnitNameCollisions(items []EffectiveItem) error {
 	var collisions []string
 
 	for _, item := range items {
-		if !quadlet.IsQuadletFile(item.MergeKey) {
+		if item.DestPath != "" || !quadlet.IsQuadletFile(item.MergeKey) {
 			continue
 		}
 		unitName := quadlet.UnitNameFromQuadlet(item.MergeKey)
@@ -18,7 +18,7 @@ type mockGitClient struct {
 	repoSetup func(destDir string)
 }
 
-func (m *mockGitClient) EnsureCheckout(_ context.Context, _, _, destDir string) (string, error) {
+func (m *mockGitClient) EnsureCheckout(_ context.Context, _, _, destDir string, _ git.CheckoutOptions) (string, error) {
 	if m.repoSetup != nil {
 		m.repoSetup(destDir)
 	}
@@ -238,7 +238,7 @@ func TestLoadRepoState_Success(t *testing.T) {
 	}
 
 	spec := makeSpec("https://example.com/repo", "refs/heads/main", 5)
-	rs, err := LoadRepoState(context.Background(), spec, repoDir, srcDir, gitMock)
+	rs, err := LoadRepoState(context.Background(), spec, repoDir, srcDir, git.CheckoutOptions{}, gitMock)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -256,7 +256,7 @@ func TestLoadRepoState_GitError(t *testing.T) {
 	gitMock := &mockGitClient{err: gitErr}
 	spec := makeSpec("https://other.example/repo", "refs/heads/main", 0)
 
-	_, err := LoadRepoState(context.Background(), spec, filepath.Join(tmpDir, "repo"), tmpDir, gitMock)
+	_, err := LoadRepoState(context.Background(), spec, filepath.Join(tmpDir, "repo"), tmpDir, git.CheckoutOptions{}, gitMock)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -281,7 +281,7 @@ func TestLoadRepoState_RejectsSymlinks(t *testing.T) {
 	gitMock := &mockGitClient{commit: "abc"}
 	spec := makeSpec("https://symlink.example/repo", "refs/heads/main", 0)
 
-	_, err := LoadRepoState(context.Background(), spec, repoDir, repoDir, gitMock)
+	_, err := LoadRepoState(context.Background(), spec, repoDir, repoDir, git.CheckoutOptions{}, gitMock)
 	if err == nil {
 		t.Fatal("expected error for symlink, got nil")
 	}
@@ -298,7 +298,7 @@ func TestLoadRepoState_EmptyDir(t *testing.T) {
 	gitMock := &mockGitClient{commit: "abc", repoSetup: func(_ string) {}}
 	spec := makeSpec("https://example.com/repo", "main", 0)
 
-	rs, err := LoadRepoState(context.Background(), spec, repoDir, repoDir, gitMock)
+	rs, err := LoadRepoState(context.Background(), spec, repoDir, repoDir, git.CheckoutOptions{}, gitMock)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
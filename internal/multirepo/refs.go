@@ -0,0 +1,123 @@
+package multirepo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+)
+
+// resolveReferences checks the companion files quadlets in files reference
+// by relative path (see quadlet.LocalFileRefs). Files are installed relative
+// to the quadlet directory, so a reference must resolve inside srcDir to be
+// synced along with the quadlet; a reference leaving srcDir or naming a file
+// that does not exist fails the load instead of installing a unit that
+// cannot start. Referenced hidden files, which discovery skips, are added.
+// Optional ("-"-prefixed) references are added when they exist and ignored
+// otherwise. Every referenced file lists the units of the quadlets referencing
+// it in RestartUnits, so that changing only a Kubernetes YAML or environment
+// file restarts the unit using it. In a layered repository a reference is
+// resolved in the layer of the quadlet and matched by relative path, so a
+// host layer's quadlet may use an environment file of the base layer.
+func resolveReferences(srcDir string, files []RepoFile) ([]RepoFile, error) {
+	known := make(map[string]bool, len(files))
+	for _, f := range files {
+		known[f.MergeKey] = true
+	}
+	users := make(map[string][]string)
+
+	var extra []RepoFile
+	for _, f := range files {
+		if !quadlet.IsQuadletFile(f.AbsPath) {
+			continue
+		}
+		u, err := quadlet.ParseFile(f.AbsPath)
+		if err != nil {
+			// Syntax errors are reported by quadlet itself and by `quadsyncd lint`.
+			continue
+		}
+		layerDir := filepath.Join(srcDir, f.Layer)
+		for _, ref := range u.LocalFileRefs(f.AbsPath) {
+			rel, err := filepath.Rel(layerDir, ref.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute relative path for %s: %w", ref.Path, err)
+			}
+			mergeKey, err := normalizeMergeKey(rel)
+			if err != nil {
+				if ref.Optional {
+					continue
+				}
+				return nil, fmt.Errorf("%s: %s=%s points outside the synced directory and would not be installed; move the file next to the quadlet or sync a higher subdir", f.MergeKey, ref.Key, ref.Value)
+			}
+			if known[mergeKey] {
+				users[mergeKey] = appendUnique(users[mergeKey], quadlet.UnitNameFromQuadlet(f.AbsPath))
+				continue
+			}
+
+			// Discovery skips hidden directories, so a symlinked one on the
+			// way to the file has not been rejected yet and could lead out
+			// of the checkout.
+			if dir, ok := symlinkedDir(layerDir, rel); ok {
+				return nil, fmt.Errorf("symlinks are not allowed in repo sources: %s", dir)
+			}
+			info, err := os.Lstat(ref.Path)
+			switch {
+			case os.IsNotExist(err) && ref.Optional:
+				continue
+			case os.IsNotExist(err):
+				return nil, fmt.Errorf("%s: %s=%s: referenced file not found in the repository", f.MergeKey, ref.Key, ref.Value)
+			case err != nil:
+				return nil, fmt.Errorf("%s: %s=%s: %w", f.MergeKey, ref.Key, ref.Value, err)
+			case info.Mode()&os.ModeSymlink != 0:
+				return nil, fmt.Errorf("symlinks are not allowed in repo sources: %s", ref.Path)
+			case !info.Mode().IsRegular():
+				return nil, fmt.Errorf("%s: %s=%s is not a regular file", f.MergeKey, ref.Key, ref.Value)
+			}
+
+			known[mergeKey] = true
+			users[mergeKey] = appendUnique(users[mergeKey], quadlet.UnitNameFromQuadlet(f.AbsPath))
+			extra = append(extra, RepoFile{MergeKey: mergeKey, AbsPath: ref.Path, Layer: f.Layer})
+		}
+	}
+
+	files = append(files, extra...)
+	for i := range files {
+		for _, unit := range users[files[i].MergeKey] {
+			files[i].RestartUnits = appendUnique(files[i].RestartUnits, unit)
+		}
+	}
+	return files, nil
+}
+
+// symlinkedDir returns the first directory on the way from base to the file
+// rel, a path relative to base, that is a symlink. Missing directories end
+// the search; the file is then reported as not found.
+func symlinkedDir(base, rel string) (string, bool) {
+	dir := base
+	for _, elem := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if elem == "." {
+			continue
+		}
+		dir = filepath.Join(dir, elem)
+		info, err := os.Lstat(dir)
+		if err != nil {
+			return "", false
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// appendUnique appends s to list unless it is already present.
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
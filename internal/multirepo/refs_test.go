@@ -0,0 +1,186 @@
+package multirepo
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files (relative path -> content) under root.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		tree     map[string]string
+		wantKeys []string
+		wantErr  string
+	}{
+		{
+			name: "visible references",
+			tree: map[string]string{
+				"deploy/web.container":   "[Container]\nImage=nginx\nEnvironmentFile=web.env\nEnvironmentFile=/etc/web.env\nEnvironmentFile=%h/web.env\n",
+				"deploy/web.env":         "A=1\n",
+				"deploy/app.kube":        "[Kube]\nYaml=k8s/app.yaml\n",
+				"deploy/k8s/app.yaml":    "kind: Pod\n",
+				"deploy/other/notes.txt": "x\n",
+			},
+			wantKeys: []string{"app.kube", "k8s/app.yaml", "other/notes.txt", "web.container", "web.env"},
+		},
+		{
+			name: "hidden references are added",
+			tree: map[string]string{
+				"deploy/web.container": "[Container]\nImage=nginx\nEnvironmentFile=.env\nEnvironmentFile=-.optional.env\nEnvironmentFile=-missing.env\n",
+				"deploy/.env":          "A=1\n",
+				"deploy/.optional.env": "B=2\n",
+				"deploy/.unused":       "C=3\n",
+			},
+			wantKeys: []string{".env", ".optional.env", "web.container"},
+		},
+		{
+			name: "reference outside subdir",
+			tree: map[string]string{
+				"deploy/web.container": "[Container]\nImage=nginx\nEnvironmentFile=../shared/common.env\n",
+				"shared/common.env":    "A=1\n",
+			},
+			wantErr: "web.container: EnvironmentFile=../shared/common.env points outside the synced directory",
+		},
+		{
+			name: "optional reference outside subdir",
+			tree: map[string]string{
+				"deploy/web.container": "[Container]\nImage=nginx\nEnvironmentFile=-../shared/common.env\n",
+			},
+			wantKeys: []string{"web.container"},
+		},
+		{
+			name: "missing reference",
+			tree: map[string]string{
+				"deploy/app.kube": "[Kube]\nYaml=app.yaml\n",
+			},
+			wantErr: "app.kube: Yaml=app.yaml: referenced file not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, tt.tree)
+			srcDir := filepath.Join(root, "deploy")
+
+			files, err := loadRepoFiles(srcDir)
+			if err != nil {
+				t.Fatalf("loadRepoFiles: %v", err)
+			}
+			files, err = resolveReferences(srcDir, files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveReferences() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveReferences: %v", err)
+			}
+			var keys []string
+			for _, f := range files {
+				keys = append(keys, f.MergeKey)
+			}
+			sort.Strings(keys)
+			if strings.Join(keys, ",") != strings.Join(tt.wantKeys, ",") {
+				t.Errorf("merge keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestResolveReferences_RestartUnits(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"web.container":   "[Container]\nImage=nginx\nEnvironmentFile=common.env\n",
+		"api.container":   "[Container]\nImage=api\nEnvironmentFile=common.env\nEnvironmentFile=.api.env\n",
+		"app.kube":        "[Kube]\nYaml=app.yaml\nConfigMap=app-config.yaml\n",
+		"common.env":      "A=1\n",
+		".api.env":        "B=2\n",
+		"app.yaml":        "kind: Pod\n",
+		"app-config.yaml": "kind: ConfigMap\n",
+	})
+	files, err := loadRepoFiles(root)
+	if err != nil {
+		t.Fatalf("loadRepoFiles: %v", err)
+	}
+	files, err = resolveReferences(root, files)
+	if err != nil {
+		t.Fatalf("resolveReferences: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, f := range files {
+		units := append([]string(nil), f.RestartUnits...)
+		sort.Strings(units)
+		got[f.MergeKey] = strings.Join(units, ",")
+	}
+	want := map[string]string{
+		"web.container":   "",
+		"api.container":   "",
+		"app.kube":        "",
+		"common.env":      "api.service,web.service",
+		".api.env":        "api.service",
+		"app.yaml":        "app.service",
+		"app-config.yaml": "app.service",
+	}
+	for key, units := range want {
+		if got[key] != units {
+			t.Errorf("%s RestartUnits = %q, want %q", key, got[key], units)
+		}
+	}
+}
+
+func TestResolveReferences_RejectsSymlink(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"web.container": "[Container]\nImage=nginx\nEnvironmentFile=.env\n",
+		"real.env":      "A=1\n",
+	})
+	if err := os.Symlink(filepath.Join(root, "real.env"), filepath.Join(root, ".env")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	files, err := loadRepoFiles(root)
+	if err != nil {
+		t.Fatalf("loadRepoFiles: %v", err)
+	}
+	if _, err := resolveReferences(root, files); err == nil || !strings.Contains(err.Error(), "symlinks are not allowed") {
+		t.Errorf("resolveReferences() error = %v, want symlink error", err)
+	}
+}
+
+func TestResolveReferences_RejectsSymlinkedHiddenDir(t *testing.T) {
+	root := t.TempDir()
+	host := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"web.container": "[Container]\nImage=nginx\nEnvironmentFile=.x/secret.env\nEnvironmentFile=-.x/optional.env\n",
+	})
+	writeTree(t, host, map[string]string{"secret.env": "TOKEN=1\n", "optional.env": "B=2\n"})
+	if err := os.Symlink(host, filepath.Join(root, ".x")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	files, err := loadRepoFiles(root)
+	if err != nil {
+		t.Fatalf("loadRepoFiles: %v", err)
+	}
+	if _, err := resolveReferences(root, files); err == nil || !strings.Contains(err.Error(), "symlinks are not allowed") {
+		t.Errorf("resolveReferences() error = %v, want symlink error", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Package oci fetches quadlet trees published as OCI artifacts, e.g. pushed
+// with `oras push`, so hosts that only reach a registry mirror can sync
+// without a git server. It implements git.Client: the "checkout" is the
+// unpacked artifact and the "commit" its manifest digest, so the rest of the
+// sync pipeline treats both backends alike.
+package oci
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+	"github.com/schaermu/quadsyncd/internal/fsutil"
+	"github.com/schaermu/quadsyncd/internal/git"
+)
+
+// DigestFile records the digest of the artifact unpacked in a checkout
+// directory, so an unchanged artifact is not pulled again. Being a dotfile,
+// it is never synced.
+const DigestFile = ".quadsyncd-oci-digest"
+
+// digestPattern matches a sha256 content digest.
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// Options configures the verification of one artifact repository.
+type Options struct {
+	// Digest, when set, is the only manifest digest the tag may resolve to.
+	Digest string
+	// CosignKey, when set, is the public key the artifact's cosign
+	// signature is verified with.
+	CosignKey string
+}
+
+// Client pulls artifacts with the oras CLI and verifies signatures with the
+// cosign CLI. Registry credentials are those of `oras login`.
+type Client struct {
+	opts    Options
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+var _ git.Client = (*Client)(nil)
+
+// NewClient creates a client that bounds each oras and cosign command by
+// timeout (0 means no limit).
+func NewClient(opts Options, timeout time.Duration, logger *slog.Logger) *Client {
+	return &Client{opts: opts, timeout: timeout, logger: logger}
+}
+
+// EnsureCheckout resolves repository at ref, a tag or a sha256 digest, and
+// makes destDir hold exactly the files of that artifact. It returns the
+// manifest digest. The artifact is pulled into a temporary directory and
+// swapped in only once complete; oras checks every blob against its digest
+// while pulling. opts are git-specific and ignored.
+func (c *Client) EnsureCheckout(ctx context.Context, repository, ref, destDir string, _ git.CheckoutOptions) (string, error) {
+	digest, err := c.resolve(ctx, repository, ref)
+	if err != nil {
+		return "", err
+	}
+	if c.opts.Digest != "" && digest != c.opts.Digest {
+		return "", fmt.Errorf("oci artifact %s:%s resolved to %s, but source.digest pins %s", repository, ref, digest, c.opts.Digest)
+	}
+
+	if current, err := os.ReadFile(filepath.Join(destDir, DigestFile)); err == nil && strings.TrimSpace(string(current)) == digest {
+		c.logger.Debug("oci artifact unchanged", "repository", repository, "digest", digest)
+		return digest, nil
+	}
+
+	pinned := repository + "@" + digest
+	if c.opts.CosignKey != "" {
+		if _, err := c.run(ctx, "cosign", "verify", "--key", c.opts.CosignKey, pinned); err != nil {
+			return "", fmt.Errorf("cosign signature verification of %s failed: %w", pinned, err)
+		}
+		c.logger.Info("verified oci artifact signature", "repository", repository, "digest", digest)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destDir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create checkout parent: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(destDir), ".oci-pull-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create pull directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	if _, err := c.run(ctx, "oras", "pull", "--output", tmp, pinned); err != nil {
+		return "", fmt.Errorf("oras pull %s failed: %w", pinned, err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, DigestFile), []byte(digest+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to record artifact digest: %w", err)
+	}
+	if err := fsutil.ReplaceDir(tmp, destDir); err != nil {
+		return "", fmt.Errorf("failed to install pulled artifact: %w", err)
+	}
+	c.logger.Info("pulled oci artifact", "repository", repository, "ref", ref, "digest", digest)
+	return digest, nil
+}
+
+// resolve returns the manifest digest of repository at ref. A ref that is
+// already a digest, as used by rollbacks, is returned as is.
+func (c *Client) resolve(ctx context.Context, repository, ref string) (string, error) {
+	if digestPattern.MatchString(ref) {
+		return ref, nil
+	}
+	out, err := c.run(ctx, "oras", "resolve", repository+":"+ref)
+	if err != nil {
+		return "", fmt.Errorf("oras resolve %s:%s failed: %w", repository, ref, err)
+	}
+	digest := strings.TrimSpace(string(out))
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("oras resolve %s:%s returned %q, not a sha256 digest", repository, ref, digest)
+	}
+	return digest, nil
+}
+
+// run runs name with args under the client's timeout and returns its
+// stdout. The error carries the combined output.
+func (c *Client) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	cmd := cmdexec.Command(ctx, name, args...)
+	out := cmdexec.Capture(cmd)
+	if err := cmdexec.Err(ctx, c.timeout, cmd.Run()); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out.Combined())))
+	}
+	return out.Stdout(), nil
+}
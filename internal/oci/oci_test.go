@@ -0,0 +1,158 @@
+package oci
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/git"
+)
+
+const testDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+// installFakeTools puts fake oras and cosign binaries first in PATH. oras
+// resolves every tag to testDigest and pulls a single web.container; each
+// invocation is appended to calls.log in the returned directory. cosign
+// exits with cosignExit.
+func installFakeTools(t *testing.T, cosignExit int) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls.log")
+	oras := "#!/bin/sh\n" +
+		"echo \"oras $*\" >> " + log + "\n" +
+		"case \"$1\" in\n" +
+		"resolve) echo " + testDigest + " ;;\n" +
+		"pull) printf '[Container]\\nImage=nginx\\n' > \"$3/web.container\" ;;\n" +
+		"esac\n"
+	cosign := "#!/bin/sh\n" +
+		"echo \"cosign $*\" >> " + log + "\n" +
+		"echo 'no matching signatures' >&2\n" +
+		"exit " + strconv.Itoa(cosignExit) + "\n"
+	for name, script := range map[string]string{"oras": oras, "cosign": cosign} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func calls(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "calls.log"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestEnsureCheckout_PullsAndCaches(t *testing.T) {
+	tools := installFakeTools(t, 0)
+	dest := filepath.Join(t.TempDir(), "checkout")
+	c := NewClient(Options{}, 0, testLogger())
+
+	digest, err := c.EnsureCheckout(t.Context(), "registry.example.com/ops/quadlets", "v1", dest, git.CheckoutOptions{})
+	if err != nil {
+		t.Fatalf("EnsureCheckout: %v", err)
+	}
+	if digest != testDigest {
+		t.Errorf("digest = %q, want %q", digest, testDigest)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "web.container")); err != nil {
+		t.Errorf("pulled file missing: %v", err)
+	}
+
+	// A second run with the same digest must not pull again.
+	if _, err := c.EnsureCheckout(t.Context(), "registry.example.com/ops/quadlets", "v1", dest, git.CheckoutOptions{}); err != nil {
+		t.Fatalf("second EnsureCheckout: %v", err)
+	}
+	got := calls(t, tools)
+	want := []string{
+		"oras resolve registry.example.com/ops/quadlets:v1",
+		"oras pull --output",
+		"oras resolve registry.example.com/ops/quadlets:v1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("calls = %q, want %d calls", got, len(want))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("call %d = %q, want prefix %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEnsureCheckout_DigestRefSkipsResolve(t *testing.T) {
+	tools := installFakeTools(t, 0)
+	c := NewClient(Options{}, 0, testLogger())
+
+	if _, err := c.EnsureCheckout(t.Context(), "registry.example.com/q", testDigest, filepath.Join(t.TempDir(), "c"), git.CheckoutOptions{}); err != nil {
+		t.Fatalf("EnsureCheckout: %v", err)
+	}
+	got := calls(t, tools)
+	if len(got) != 1 || !strings.HasSuffix(got[0], "registry.example.com/q@"+testDigest) {
+		t.Errorf("calls = %q, want a single pinned pull", got)
+	}
+}
+
+func TestEnsureCheckout_Verification(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		cosignExit int
+		wantErr    string
+	}{
+		{
+			name:    "digest pin mismatch",
+			opts:    Options{Digest: "sha256:" + strings.Repeat("2", 64)},
+			wantErr: "source.digest pins",
+		},
+		{
+			name: "digest pin match",
+			opts: Options{Digest: testDigest},
+		},
+		{
+			name:       "signature rejected",
+			opts:       Options{CosignKey: "/etc/quadsyncd/cosign.pub"},
+			cosignExit: 1,
+			wantErr:    "cosign signature verification",
+		},
+		{
+			name: "signature verified",
+			opts: Options{CosignKey: "/etc/quadsyncd/cosign.pub"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installFakeTools(t, tt.cosignExit)
+			dest := filepath.Join(t.TempDir(), "checkout")
+			c := NewClient(tt.opts, 0, testLogger())
+
+			_, err := c.EnsureCheckout(t.Context(), "registry.example.com/q", "v1", dest, git.CheckoutOptions{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("EnsureCheckout: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(dest); !os.IsNotExist(statErr) {
+				t.Errorf("checkout created despite failed verification")
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package quadlet
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Version is a Podman release version.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// MinVersion is the first Podman release that ships the quadlet generator.
+var MinVersion = Version{4, 4, 0}
+
+// versionPattern finds the version in `podman --version` output such as
+// "podman version 5.2.1" or in a bare "4.9.4-rhel".
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion extracts the first major.minor[.patch] version from s.
+func ParseVersion(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("no version in %q", s)
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+// Less reports whether v is an older release than o.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// String formats v as major.minor.patch.
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// extSince lists the quadlet file types added after MinVersion with the
+// Podman release that added them.
+var extSince = map[string]Version{
+	".image": {4, 8, 0},
+	".pod":   {5, 0, 0},
+	".build": {5, 2, 0},
+}
+
+// keySince lists quadlet keys added after MinVersion, per section, with the
+// Podman release that added them. Keys missing here are assumed to be as
+// old as their file type; extend the table when a newer key turns out to
+// break older hosts.
+var keySince = map[string]map[string]Version{
+	"Container": {
+		"AutoUpdate":           {4, 7, 0},
+		"ContainersConfModule": {4, 8, 0},
+		"GlobalArgs":           {4, 8, 0},
+		"Pull":                 {4, 8, 0},
+		"Entrypoint":           {5, 0, 0},
+		"GroupAdd":             {5, 0, 0},
+		"Pod":                  {5, 0, 0},
+		"ShmSize":              {5, 0, 0},
+		"AddHost":              {5, 1, 0},
+		"CgroupsMode":          {5, 1, 0},
+		"StartWithPod":         {5, 1, 0},
+		"StopSignal":           {5, 2, 0},
+		"HealthLogDestination": {5, 3, 0},
+		"HealthMaxLogCount":    {5, 3, 0},
+		"HealthMaxLogSize":     {5, 3, 0},
+		"ReloadCmd":            {5, 3, 0},
+		"ReloadSignal":         {5, 3, 0},
+		"ServiceName":          {5, 3, 0},
+	},
+	"Kube": {
+		"AutoUpdate":           {4, 7, 0},
+		"ContainersConfModule": {4, 8, 0},
+		"GlobalArgs":           {4, 8, 0},
+		"KubeDownForce":        {4, 8, 0},
+		"ServiceName":          {5, 3, 0},
+	},
+	"Network": {
+		"ContainersConfModule": {4, 8, 0},
+		"GlobalArgs":           {4, 8, 0},
+		"ServiceName":          {5, 3, 0},
+		"NetworkDeleteOnStop":  {5, 5, 0},
+	},
+	"Volume": {
+		"ContainersConfModule": {4, 8, 0},
+		"GlobalArgs":           {4, 8, 0},
+		"ServiceName":          {5, 3, 0},
+	},
+	"Image": {
+		"ServiceName": {5, 3, 0},
+	},
+	"Pod": {
+		"ServiceName": {5, 3, 0},
+	},
+}
+
+// CheckCompat reports what the quadlet file at path, parsed as u, uses that
+// Podman v does not support yet: its file type or keys from later releases
+// (see keySince). Issues are errors, since the generator rejects or ignores
+// such files.
+func CheckCompat(path string, u *Unit, v Version) []Issue {
+	var issues []Issue
+	add := func(line int, format string, args ...any) {
+		issues = append(issues, Issue{Path: path, Line: line, Severity: SeverityError, Message: fmt.Sprintf(format, args...)})
+	}
+
+	main := MainSection(path)
+	if main == "" {
+		return nil
+	}
+	if v.Less(MinVersion) {
+		add(0, "quadlet requires Podman %s, installed is %s", MinVersion, v)
+		return issues
+	}
+	ext := filepath.Ext(path)
+	if since, ok := extSince[ext]; ok && v.Less(since) {
+		add(0, "%s files require Podman %s, installed is %s", ext, since, v)
+		return issues
+	}
+	for _, s := range u.Sections {
+		keys := keySince[s.Name]
+		for _, e := range s.Entries {
+			if since, ok := keys[e.Key]; ok && v.Less(since) {
+				add(e.Line, "%s= in [%s] requires Podman %s, installed is %s", e.Key, s.Name, since, v)
+			}
+		}
+	}
+	return issues
+}
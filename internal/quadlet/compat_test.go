@@ -0,0 +1,95 @@
+package quadlet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Version
+		wantErr bool
+	}{
+		{in: "podman version 5.2.1", want: Version{5, 2, 1}},
+		{in: "4.9.4-rhel", want: Version{4, 9, 4}},
+		{in: "5.0", want: Version{5, 0, 0}},
+		{in: "podman version 5.4.0-dev\n", want: Version{5, 4, 0}},
+		{in: "podman", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseVersion(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseVersion(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if !(Version{4, 9, 4}).Less(Version{5, 0, 0}) || (Version{5, 0, 0}).Less(Version{5, 0, 0}) || !(Version{5, 1, 0}).Less(Version{5, 1, 2}) {
+		t.Error("Less() orders versions incorrectly")
+	}
+}
+
+func TestCheckCompat(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		data    string
+		version Version
+		want    []string
+	}{
+		{
+			name:    "supported",
+			path:    "web.container",
+			data:    "[Container]\nImage=nginx\nPod=web.pod\n",
+			version: Version{5, 2, 0},
+		},
+		{
+			name:    "newer keys",
+			path:    "web.container",
+			data:    "[Unit]\nDescription=web\n\n[Container]\nImage=nginx\nPod=web.pod\nAddHost=db:10.0.0.2\n",
+			version: Version{4, 9, 3},
+			want: []string{
+				"web.container:6: error: Pod= in [Container] requires Podman 5.0.0, installed is 4.9.3",
+				"web.container:7: error: AddHost= in [Container] requires Podman 5.1.0, installed is 4.9.3",
+			},
+		},
+		{
+			name:    "newer file type",
+			path:    "web.pod",
+			data:    "[Pod]\nServiceName=web\n",
+			version: Version{4, 9, 0},
+			want:    []string{"web.pod: error: .pod files require Podman 5.0.0, installed is 4.9.0"},
+		},
+		{
+			name:    "no quadlet",
+			path:    "web.container",
+			data:    "[Container]\nImage=nginx\n",
+			version: Version{4, 3, 1},
+			want:    []string{"web.container: error: quadlet requires Podman 4.4.0, installed is 4.3.1"},
+		},
+		{
+			name:    "not a quadlet file",
+			path:    "env.conf",
+			data:    "[Container]\nPod=x.pod\n",
+			version: Version{4, 4, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := Parse(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var got []string
+			for _, issue := range CheckCompat(tt.path, u, tt.version) {
+				got = append(got, issue.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckCompat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package quadlet
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Dependencies returns the units the quadlet depends on: units named in
+// Requires= and After= of its [Unit] section, and the .pod, .network,
+// .volume, .image and .build quadlets referenced by Pod=, Network=, Volume=
+// and Image=. Quadlet references are returned as the name of the service
+// generated for them. The result is sorted and free of duplicates.
+func (u *Unit) Dependencies() []string {
+	seen := make(map[string]bool)
+	add := func(ref string) {
+		if ref == "" {
+			return
+		}
+		if IsQuadletFile(ref) {
+			seen[UnitNameFromQuadlet(ref)] = true
+		} else if strings.HasSuffix(ref, ".service") {
+			seen[ref] = true
+		}
+	}
+
+	for _, key := range []string{"Requires", "After"} {
+		for _, value := range u.LookupAll("Unit", key) {
+			for _, unit := range strings.Fields(value) {
+				add(unit)
+			}
+		}
+	}
+	for _, ref := range u.QuadletRefs() {
+		add(ref)
+	}
+
+	deps := make([]string, 0, len(seen))
+	for unit := range seen {
+		deps = append(deps, unit)
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// QuadletRefs returns the names of the .pod, .network, .volume, .image and
+// .build quadlets referenced by Pod=, Network=, Volume= and Image= outside
+// the [Unit] section. The result is sorted and free of duplicates.
+func (u *Unit) QuadletRefs() []string {
+	seen := make(map[string]bool)
+	for _, s := range u.Sections {
+		if s.Name == "Unit" {
+			continue
+		}
+		for _, e := range s.Entries {
+			var ref string
+			switch e.Key {
+			case "Pod", "Image":
+				ref = referencedQuadlet(e.Value, ".pod", ".image", ".build")
+			case "Network", "Volume":
+				// Network=name.network:options and Volume=name.volume:/path[:options]
+				name, _, _ := strings.Cut(e.Value, ":")
+				ref = referencedQuadlet(name, ".network", ".volume")
+			}
+			if ref != "" {
+				seen[ref] = true
+			}
+		}
+	}
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// referencedQuadlet returns ref if it names a quadlet file with one of exts,
+// and "" otherwise.
+func referencedQuadlet(ref string, exts ...string) string {
+	ext := filepath.Ext(ref)
+	for _, e := range exts {
+		if ext == e && !strings.Contains(ref, "/") {
+			return ref
+		}
+	}
+	return ""
+}
+
+// OrderUnits groups units into tiers such that every unit comes after the
+// units it depends on. deps maps a unit to its dependencies; dependencies
+// outside units are ignored. Units within a tier are independent of each
+// other and sorted. Units caught in a dependency cycle are placed together
+// in a final tier.
+func OrderUnits(units []string, deps map[string][]string) [][]string {
+	pending := make(map[string]bool, len(units))
+	for _, unit := range units {
+		pending[unit] = true
+	}
+
+	var tiers [][]string
+	for len(pending) > 0 {
+		var tier []string
+		for unit := range pending {
+			ready := true
+			for _, dep := range deps[unit] {
+				if dep != unit && pending[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				tier = append(tier, unit)
+			}
+		}
+		if len(tier) == 0 {
+			// Cycle: restart the rest together and let systemd sort it out.
+			for unit := range pending {
+				tier = append(tier, unit)
+			}
+		}
+		sort.Strings(tier)
+		for _, unit := range tier {
+			delete(pending, unit)
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers
+}
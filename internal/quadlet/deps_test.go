@@ -0,0 +1,123 @@
+package quadlet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "references",
+			data: `[Unit]
+Description=web
+Requires=db.service cache.container
+After=network-online.target db.service
+
+[Container]
+Image=app.build
+Pod=web.pod
+Network=frontend.network:ip=10.0.0.5
+Network=host
+Volume=data.volume:/data:Z
+Volume=/srv/web:/srv
+`,
+			want: []string{"app-build.service", "cache.service", "data-volume.service", "db.service", "frontend-network.service", "web.service"},
+		},
+		{
+			name: "registry image and comments",
+			data: `[Container]
+# Network=ignored.network
+Image=docker.io/library/nginx:1.25
+Volume = named:/data
+`,
+			want: []string{},
+		},
+		{
+			name: "unit keys outside unit section",
+			data: `[Service]
+After=db.service
+`,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := Parse(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := u.Dependencies(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuadletRefs(t *testing.T) {
+	u, err := Parse(strings.NewReader(`[Unit]
+Requires=db.container
+
+[Container]
+Image=app.build
+Pod=web.pod
+Network=frontend.network:ip=10.0.0.5
+Network=frontend.network
+Network=host
+Volume=data.volume:/data:Z
+Volume=/srv/web:/srv
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []string{"app.build", "data.volume", "frontend.network", "web.pod"}
+	if got := u.QuadletRefs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("QuadletRefs() = %v, want %v", got, want)
+	}
+}
+
+func TestOrderUnits(t *testing.T) {
+	tests := []struct {
+		name  string
+		units []string
+		deps  map[string][]string
+		want  [][]string
+	}{
+		{
+			name:  "independent",
+			units: []string{"b.service", "a.service"},
+			want:  [][]string{{"a.service", "b.service"}},
+		},
+		{
+			name:  "network and pod before container",
+			units: []string{"web.service", "net-network.service", "app.service", "db.service"},
+			deps: map[string][]string{
+				"web.service": {"net-network.service", "app.service", "missing.service"},
+				"app.service": {"net-network.service"},
+				"db.service":  {"db.service"},
+			},
+			want: [][]string{{"db.service", "net-network.service"}, {"app.service"}, {"web.service"}},
+		},
+		{
+			name:  "cycle",
+			units: []string{"a.service", "b.service", "c.service"},
+			deps: map[string][]string{
+				"a.service": {"b.service"},
+				"b.service": {"a.service"},
+			},
+			want: [][]string{{"c.service"}, {"a.service", "b.service"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OrderUnits(tt.units, tt.deps); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OrderUnits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package quadlet
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// DropInDir returns the name of the directory holding the drop-in at path,
+// e.g. "web.container.d" for web.container.d/10-env.conf, when path is a
+// Quadlet drop-in: a .conf file directly in a directory named after a
+// quadlet (web.container.d), a name prefix (web-.container.d) or a quadlet
+// type (container.d), with ".d" appended.
+func DropInDir(path string) (string, bool) {
+	if filepath.Ext(path) != ".conf" {
+		return "", false
+	}
+	dir := filepath.Base(filepath.Dir(path))
+	target, ok := strings.CutSuffix(dir, ".d")
+	if !ok || !(IsQuadletFile(target) || IsQuadletFile("."+target)) {
+		return "", false
+	}
+	return dir, true
+}
+
+// DropInQuadlet returns the name of the quadlet file the drop-in at path
+// belongs to when its directory names a single quadlet, e.g. "web.container"
+// for web.container.d/10-env.conf. Drop-ins for a whole type, a name prefix
+// or a template apply to quadlets that cannot be told from the path alone.
+func DropInQuadlet(path string) (string, bool) {
+	dir, ok := DropInDir(path)
+	if !ok {
+		return "", false
+	}
+	name := strings.TrimSuffix(dir, ".d")
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	if !IsQuadletFile(name) || base == "" || strings.HasSuffix(base, "-") || strings.HasSuffix(base, "@") {
+		return "", false
+	}
+	return name, true
+}
+
+// DropInApplies reports whether Quadlet applies the drop-ins in the
+// directory named dir to the quadlet file named name. Like Quadlet, it
+// matches <name>.d, the template directory of an instance (web@.container.d
+// for web@a.container), every <prefix>-.<type>.d whose prefix the name
+// starts with, and <type>.d.
+func DropInApplies(dir, name string) bool {
+	target, ok := strings.CutSuffix(dir, ".d")
+	ext := filepath.Ext(name)
+	if !ok || ext == "" {
+		return false
+	}
+	if target == name || "."+target == ext {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(target, ext)
+	if !ok || prefix == "" {
+		return false
+	}
+	if template, _, found := strings.Cut(name, "@"); found && prefix == template+"@" {
+		return true
+	}
+	return strings.HasSuffix(prefix, "-") && strings.HasPrefix(name, prefix)
+}
@@ -0,0 +1,56 @@
+package quadlet
+
+import "testing"
+
+func TestDropInQuadlet(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantDir string
+		want    string
+	}{
+		{"web.container.d/10-env.conf", "web.container.d", "web.container"},
+		{"apps/db.volume.d/size.conf", "db.volume.d", "db.volume"},
+		{"web@a.container.d/10-env.conf", "web@a.container.d", "web@a.container"},
+		{"web@.container.d/10-env.conf", "web@.container.d", ""},
+		{"web-.container.d/10-env.conf", "web-.container.d", ""},
+		{"container.d/labels.conf", "container.d", ""},
+		{"web.container.d/notes.txt", "", ""},
+		{"nginx.d/site.conf", "", ""},
+		{"web.container", "", ""},
+	}
+	for _, tt := range tests {
+		dir, _ := DropInDir(tt.path)
+		if dir != tt.wantDir {
+			t.Errorf("DropInDir(%q) = %q, want %q", tt.path, dir, tt.wantDir)
+		}
+		name, ok := DropInQuadlet(tt.path)
+		if name != tt.want || ok != (tt.want != "") {
+			t.Errorf("DropInQuadlet(%q) = %q, %v, want %q", tt.path, name, ok, tt.want)
+		}
+	}
+}
+
+func TestDropInApplies(t *testing.T) {
+	tests := []struct {
+		dir  string
+		name string
+		want bool
+	}{
+		{"web.container.d", "web.container", true},
+		{"web.container.d", "web2.container", false},
+		{"container.d", "web.container", true},
+		{"container.d", "web.volume", false},
+		{"web-.container.d", "web-app.container", true},
+		{"web-.container.d", "web-app-db.container", true},
+		{"web-.container.d", "webapp.container", false},
+		{"web-.container.d", "web-app.volume", false},
+		{"web@.container.d", "web@a.container", true},
+		{"web@.container.d", "web.container", false},
+		{"web.container", "web.container", false},
+	}
+	for _, tt := range tests {
+		if got := DropInApplies(tt.dir, tt.name); got != tt.want {
+			t.Errorf("DropInApplies(%q, %q) = %v, want %v", tt.dir, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package quadlet
+
+import (
+	"bytes"
+	"strings"
+)
+
+// HasInstallTarget reports whether the [Install] section names a unit that
+// pulls this one in with WantedBy= or RequiredBy=. Quadlet units cannot be
+// enabled with systemctl; the generator turns these keys into the links
+// that start them on boot.
+func (u *Unit) HasInstallTarget() bool {
+	return len(u.LookupAll("Install", "WantedBy")) > 0 || len(u.LookupAll("Install", "RequiredBy")) > 0
+}
+
+// EnsureWantedBy returns data with WantedBy=target added when the unit has
+// no install target (see HasInstallTarget). The entry goes at the end of the
+// last [Install] section, so it follows any reset of the list, or into a new
+// [Install] section at the end of the file. It reports whether data changed;
+// data that cannot be parsed is returned unchanged.
+func EnsureWantedBy(data []byte, target string) ([]byte, bool) {
+	u, err := Parse(bytes.NewReader(data))
+	if err != nil || u.HasInstallTarget() {
+		return data, false
+	}
+	entry := "WantedBy=" + target + "\n"
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	// after is the number of lines the entry follows.
+	after := -1
+	for i, s := range u.Sections {
+		if s.Name != "Install" {
+			continue
+		}
+		after = len(lines)
+		if i+1 < len(u.Sections) {
+			after = u.Sections[i+1].Line - 1
+		}
+		for after > s.Line && len(bytes.TrimSpace(lines[after-1])) == 0 {
+			after--
+		}
+	}
+	if after < 0 {
+		lines = append(lines, []byte("\n[Install]\n"))
+		if len(data) == 0 {
+			lines[len(lines)-1] = []byte("[Install]\n")
+		}
+		after = len(lines)
+	}
+
+	var out bytes.Buffer
+	for i, line := range lines {
+		if i == after {
+			out.WriteString(entry)
+		}
+		out.Write(line)
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			out.WriteByte('\n')
+		}
+	}
+	if after == len(lines) {
+		out.WriteString(entry)
+	}
+	return out.Bytes(), true
+}
+
+// IsTemplate reports whether the quadlet at path defines a template unit,
+// e.g. web@.container. Template units only run as named instances.
+func IsTemplate(path string) bool {
+	return strings.Contains(UnitNameFromQuadlet(path), "@.")
+}
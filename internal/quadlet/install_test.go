@@ -0,0 +1,69 @@
+package quadlet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEnsureWantedBy(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "no install section",
+			in:   "[Container]\nImage=nginx\n",
+			want: "[Container]\nImage=nginx\n\n[Install]\nWantedBy=default.target\n",
+		},
+		{
+			name: "no trailing newline",
+			in:   "[Container]\nImage=nginx",
+			want: "[Container]\nImage=nginx\n\n[Install]\nWantedBy=default.target\n",
+		},
+		{
+			name: "install section without target",
+			in:   "[Container]\nImage=nginx\n\n[Install]\nAlias=web2.service\n\n[Service]\nRestart=always\n",
+			want: "[Container]\nImage=nginx\n\n[Install]\nAlias=web2.service\nWantedBy=default.target\n\n[Service]\nRestart=always\n",
+		},
+		{
+			name: "install header on the last line",
+			in:   "[Container]\nImage=nginx\n[Install]",
+			want: "[Container]\nImage=nginx\n[Install]\nWantedBy=default.target\n",
+		},
+		{
+			name: "reset target",
+			in:   "[Container]\nImage=nginx\n[Install]\nWantedBy=multi-user.target\nWantedBy=\n",
+			want: "[Container]\nImage=nginx\n[Install]\nWantedBy=multi-user.target\nWantedBy=\nWantedBy=default.target\n",
+		},
+		{
+			name: "empty file",
+			in:   "",
+			want: "[Install]\nWantedBy=default.target\n",
+		},
+		{
+			name: "wanted by",
+			in:   "[Container]\nImage=nginx\n[Install]\nWantedBy=multi-user.target\n",
+		},
+		{
+			name: "required by",
+			in:   "[Container]\nImage=nginx\n[Install]\nRequiredBy=app.target\n",
+		},
+		{
+			name: "unparsable",
+			in:   "Image=nginx\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := EnsureWantedBy([]byte(tt.in), "default.target")
+			want, wantChanged := tt.want, tt.want != ""
+			if !wantChanged {
+				want = tt.in
+			}
+			if changed != wantChanged || !bytes.Equal(got, []byte(want)) {
+				t.Errorf("EnsureWantedBy() = %q, %v; want %q", got, changed, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package quadlet
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Severity classifies a lint issue.
+type Severity string
+
+// Lint severities. Errors break the generated unit; warnings are likely
+// mistakes such as misspelled keys, which quadlet itself rejects or ignores
+// depending on the Podman version.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found by Lint.
+type Issue struct {
+	Path     string   `json:"path"`
+	Line     int      `json:"line,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// String formats the issue as path:line: severity: message.
+func (i Issue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", i.Path, i.Line, i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Path, i.Severity, i.Message)
+}
+
+// knownKeys lists the keys Podman's quadlet generator accepts in each
+// quadlet section.
+var knownKeys = map[string][]string{
+	"Container": {
+		"AddCapability", "AddDevice", "AddHost", "Annotation", "AppArmor", "AutoUpdate",
+		"CgroupsMode", "ContainerName", "ContainersConfModule", "DNS", "DNSOption", "DNSSearch",
+		"DropCapability", "Entrypoint", "Environment", "EnvironmentFile", "EnvironmentHost",
+		"Exec", "ExposeHostPort", "GIDMap", "GlobalArgs", "Group", "GroupAdd",
+		"HealthCmd", "HealthInterval", "HealthLogDestination", "HealthMaxLogCount",
+		"HealthMaxLogSize", "HealthOnFailure", "HealthRetries", "HealthStartPeriod",
+		"HealthStartupCmd", "HealthStartupInterval", "HealthStartupRetries",
+		"HealthStartupSuccess", "HealthStartupTimeout", "HealthTimeout", "HostName",
+		"HttpProxy", "Image", "IP", "IP6", "Label", "LogDriver", "LogOpt", "Mask", "Memory",
+		"Mount", "Network", "NetworkAlias", "NoNewPrivileges", "Notify", "PidsLimit", "Pod",
+		"PodmanArgs", "PublishPort", "Pull", "ReadOnly", "ReadOnlyTmpfs", "ReloadCmd",
+		"ReloadSignal", "Retry", "RetryDelay", "Rootfs", "RunInit", "SeccompProfile", "Secret",
+		"SecurityLabelDisable", "SecurityLabelFileType", "SecurityLabelLevel",
+		"SecurityLabelNested", "SecurityLabelType", "ServiceName", "ShmSize", "StartWithPod",
+		"StopSignal", "StopTimeout", "SubGIDMap", "SubUIDMap", "Sysctl", "Timezone", "Tmpfs",
+		"UIDMap", "Ulimit", "Unmask", "User", "UserNS", "Volume", "WorkingDir",
+	},
+	"Volume": {
+		"ContainersConfModule", "Copy", "Device", "Driver", "GID", "GlobalArgs", "Group",
+		"Image", "Label", "Options", "PodmanArgs", "ServiceName", "Type", "UID", "User",
+		"VolumeName",
+	},
+	"Network": {
+		"ContainersConfModule", "DisableDNS", "DNS", "Driver", "Gateway", "GlobalArgs",
+		"InterfaceName", "Internal", "IPAMDriver", "IPRange", "IPv6", "Label",
+		"NetworkDeleteOnStop", "NetworkName", "Options", "PodmanArgs", "ServiceName", "Subnet",
+	},
+	"Kube": {
+		"AutoUpdate", "ConfigMap", "ContainersConfModule", "ExitCodePropagation", "GlobalArgs",
+		"KubeDownForce", "LogDriver", "Network", "PodmanArgs", "PublishPort", "ServiceName",
+		"SetWorkingDirectory", "UserNS", "Yaml",
+	},
+	"Image": {
+		"AllTags", "Arch", "AuthFile", "CertDir", "ContainersConfModule", "Creds",
+		"DecryptionKey", "GlobalArgs", "Image", "ImageTag", "OS", "PodmanArgs", "Policy",
+		"Retry", "RetryDelay", "ServiceName", "TLSVerify", "Variant",
+	},
+	"Build": {
+		"Annotation", "Arch", "AuthFile", "BuildArg", "ContainersConfModule", "DNS",
+		"DNSOption", "DNSSearch", "Environment", "File", "ForceRM", "GlobalArgs", "GroupAdd",
+		"IgnoreFile", "ImageTag", "Label", "Network", "PodmanArgs", "Pull", "Retry",
+		"RetryDelay", "Secret", "ServiceName", "SetWorkingDirectory", "Target", "TLSVerify",
+		"Variant", "Volume",
+	},
+	"Pod": {
+		"AddHost", "ContainersConfModule", "DNS", "DNSOption", "DNSSearch", "ExitPolicy",
+		"GIDMap", "GlobalArgs", "HostName", "IP", "IP6", "Label", "Network", "NetworkAlias",
+		"PodmanArgs", "PodName", "PublishPort", "ServiceName", "ShmSize", "SubGIDMap",
+		"SubUIDMap", "UIDMap", "UserNS", "Volume",
+	},
+	"Quadlet": {"DefaultDependencies"},
+}
+
+// knownKeySet indexes knownKeys by section and key.
+var knownKeySet = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(knownKeys))
+	for section, keys := range knownKeys {
+		sets[section] = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			sets[section][key] = true
+		}
+	}
+	return sets
+}()
+
+// requiredKeys lists, per quadlet section, keys of which at least one must
+// be set.
+var requiredKeys = map[string][]string{
+	"Container": {"Image", "Rootfs"},
+	"Kube":      {"Yaml"},
+	"Image":     {"Image"},
+	"Build":     {"ImageTag"},
+}
+
+// LintFile parses the quadlet file at path and checks it with Lint.
+func LintFile(path string) []Issue {
+	u, err := ParseFile(path)
+	if err != nil {
+		msg := strings.TrimPrefix(err.Error(), path+": ")
+		return []Issue{{Path: path, Severity: SeverityError, Message: msg}}
+	}
+	return Lint(path, u)
+}
+
+// Lint checks a parsed quadlet file for problems Podman's generator would
+// only report when the unit is generated: a missing quadlet section, a
+// missing Image= (or other required key), relative file references (see
+// LocalFileRefs) to files that do not exist, and keys the quadlet section
+// does not know.
+func Lint(path string, u *Unit) []Issue {
+	var issues []Issue
+	add := func(line int, sev Severity, format string, args ...any) {
+		issues = append(issues, Issue{Path: path, Line: line, Severity: sev, Message: fmt.Sprintf(format, args...)})
+	}
+
+	main := MainSection(path)
+	if main == "" {
+		add(0, SeverityError, "not a quadlet file")
+		return issues
+	}
+	if !u.HasSection(main) {
+		add(0, SeverityError, "missing [%s] section", main)
+		return issues
+	}
+
+	if required := requiredKeys[main]; len(required) > 0 {
+		found := false
+		for _, key := range required {
+			if v, ok := u.Lookup(main, key); ok && v != "" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add(sectionLine(u, main), SeverityError, "[%s] requires %s=", main, strings.Join(required, "= or "))
+		}
+	}
+
+	for _, s := range u.Sections {
+		keys, quadletSection := knownKeySet[s.Name]
+		for _, e := range s.Entries {
+			if quadletSection && !keys[e.Key] {
+				add(e.Line, SeverityWarning, "unknown key %s in [%s]", e.Key, s.Name)
+			}
+		}
+	}
+	for _, ref := range u.LocalFileRefs(path) {
+		if ref.Optional {
+			continue
+		}
+		if _, err := os.Stat(ref.Path); err != nil {
+			add(ref.Line, SeverityError, "%s=%s: referenced file not found", ref.Key, ref.Value)
+		}
+	}
+	return issues
+}
+
+// sectionLine returns the line of the first section called name.
+func sectionLine(u *Unit, name string) int {
+	for _, s := range u.Sections {
+		if s.Name == name {
+			return s.Line
+		}
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package quadlet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "web.env"), []byte("A=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string // expected "line: severity: message" fragments, in order
+	}{
+		{
+			name:    "valid",
+			file:    "web.container",
+			content: "[Container]\nImage=nginx\nEnvironmentFile=web.env\nEnvironmentFile=-optional.env\nEnvironmentFile=/etc/app.env\n[Service]\nRestart=always\n",
+		},
+		{
+			name:    "missing image",
+			file:    "app.container",
+			content: "[Unit]\nDescription=app\n[Container]\nExec=sleep 1\n",
+			want:    []string{":3: error: [Container] requires Image= or Rootfs="},
+		},
+		{
+			name:    "dangling environment file",
+			file:    "api.container",
+			content: "[Container]\nImage=api\nEnvironmentFile=../shared/api.env\n",
+			want:    []string{":3: error: EnvironmentFile=../shared/api.env: referenced file not found"},
+		},
+		{
+			name:    "unknown key",
+			file:    "data.volume",
+			content: "[Volume]\nVolumeNmae=data\n[X-Custom]\nAnything=ok\n",
+			want:    []string{":2: warning: unknown key VolumeNmae in [Volume]"},
+		},
+		{
+			name:    "missing section",
+			file:    "net.network",
+			content: "[Unit]\nDescription=net\n",
+			want:    []string{": error: missing [Network] section"},
+		},
+		{
+			name:    "syntax error",
+			file:    "bad.kube",
+			content: "[Kube]\nYaml\n",
+			want:    []string{": error: line 2: expected Key=Value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			issues := LintFile(path)
+			if len(issues) != len(tt.want) {
+				t.Fatalf("LintFile() = %v, want %d issue(s)", issues, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got := issues[i].String(); !strings.HasPrefix(got, path) || !strings.Contains(got, want) {
+					t.Errorf("issue %d = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package quadlet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Entry is a Key=Value line of a unit file.
+type Entry struct {
+	Key   string
+	Value string
+	Line  int // line number of the key, starting at 1
+}
+
+// Section is a [Name] section of a unit file with its entries in file order.
+type Section struct {
+	Name    string
+	Line    int
+	Entries []Entry
+}
+
+// Unit is a parsed unit or quadlet file. Sections keep file order; a section
+// name may appear more than once, as systemd merges repeated sections.
+type Unit struct {
+	Sections []Section
+}
+
+// Parse reads a file in systemd's unit file syntax: [Section] headers,
+// Key=Value entries, comment lines starting with # or ;, and values continued
+// on the next line by a trailing backslash.
+func Parse(r io.Reader) (*Unit, error) {
+	u := &Unit{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") || len(line) < 3 {
+				return nil, fmt.Errorf("line %d: invalid section header %q", lineNo, line)
+			}
+			u.Sections = append(u.Sections, Section{Name: line[1 : len(line)-1], Line: lineNo})
+			continue
+		}
+
+		entryLine := lineNo
+		for strings.HasSuffix(line, `\`) && scanner.Scan() {
+			lineNo++
+			next := strings.TrimSpace(scanner.Text())
+			if next != "" && (next[0] == '#' || next[0] == ';') {
+				continue // comments inside a continued value are skipped
+			}
+			line = strings.TrimRight(strings.TrimSuffix(line, `\`), " \t") + " " + next
+		}
+		line = strings.TrimSuffix(line, `\`)
+
+		if len(u.Sections) == 0 {
+			return nil, fmt.Errorf("line %d: assignment outside of a section", entryLine)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected Key=Value, got %q", entryLine, line)
+		}
+		s := &u.Sections[len(u.Sections)-1]
+		s.Entries = append(s.Entries, Entry{Key: key, Value: strings.TrimSpace(value), Line: entryLine})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ParseFile parses the unit file at path.
+func ParseFile(path string) (*Unit, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	u, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return u, nil
+}
+
+// HasSection reports whether the unit has a section called name.
+func (u *Unit) HasSection(name string) bool {
+	for _, s := range u.Sections {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the last value of key in section, following systemd's
+// rule that a later assignment overrides an earlier one.
+func (u *Unit) Lookup(section, key string) (string, bool) {
+	values := u.entries(section, key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1].Value, true
+}
+
+// LookupAll returns every value of the list-valued key in section. An empty
+// assignment resets the list, as it does in systemd.
+func (u *Unit) LookupAll(section, key string) []string {
+	var values []string
+	for _, e := range u.entries(section, key) {
+		if e.Value == "" {
+			values = nil
+			continue
+		}
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+// entries returns the entries for key in every section called section.
+func (u *Unit) entries(section, key string) []Entry {
+	var out []Entry
+	for _, s := range u.Sections {
+		if s.Name != section {
+			continue
+		}
+		for _, e := range s.Entries {
+			if e.Key == key {
+				out = append(out, e)
+			}
+		}
+	}
+	return out
+}
+
+// Image returns the image of a .container or .image quadlet.
+func (u *Unit) Image() (string, bool) {
+	if image, ok := u.Lookup("Container", "Image"); ok {
+		return image, true
+	}
+	return u.Lookup("Image", "Image")
+}
+
+// EnvironmentFiles returns the EnvironmentFile= values of the [Container]
+// and [Service] sections. A leading "-" marks a file systemd ignores when it
+// is missing and is kept.
+func (u *Unit) EnvironmentFiles() []string {
+	return append(u.LookupAll("Container", "EnvironmentFile"), u.LookupAll("Service", "EnvironmentFile")...)
+}
+
+// VolumeRefs returns the source of every Volume= of the [Container], [Pod]
+// and [Build] sections: a host path, a named volume, or a .volume quadlet.
+func (u *Unit) VolumeRefs() []string {
+	var refs []string
+	for _, section := range []string{"Container", "Pod", "Build"} {
+		for _, v := range u.LookupAll(section, "Volume") {
+			src, _, _ := strings.Cut(v, ":")
+			if src != "" {
+				refs = append(refs, src)
+			}
+		}
+	}
+	return refs
+}
+
+// FileRef is a file a quadlet references by a path relative to itself.
+type FileRef struct {
+	Key      string // EnvironmentFile, Yaml or ConfigMap
+	Value    string // as written, including a leading "-"
+	Line     int
+	Optional bool   // "-"-prefixed; systemd ignores the file if it is missing
+	Path     string // the reference resolved against the quadlet's directory
+}
+
+// fileRefKeys lists, per section, the keys whose values are file paths that
+// quadlet resolves relative to the quadlet file.
+var fileRefKeys = map[string][]string{
+	"Container": {"EnvironmentFile"},
+	"Service":   {"EnvironmentFile"},
+	"Kube":      {"Yaml", "ConfigMap"},
+}
+
+// LocalFileRefs returns the relative file references of the quadlet at
+// path: EnvironmentFile= in [Container] and [Service], and Yaml= and
+// ConfigMap= in [Kube]. Absolute paths and paths with % specifiers refer to
+// the host rather than the repository and are left out.
+func (u *Unit) LocalFileRefs(path string) []FileRef {
+	var refs []FileRef
+	for _, s := range u.Sections {
+		for _, key := range fileRefKeys[s.Name] {
+			for _, e := range s.Entries {
+				if e.Key != key {
+					continue
+				}
+				value := strings.TrimPrefix(e.Value, "-")
+				if value == "" || filepath.IsAbs(value) || strings.Contains(value, "%") {
+					continue
+				}
+				refs = append(refs, FileRef{
+					Key:      e.Key,
+					Value:    e.Value,
+					Line:     e.Line,
+					Optional: value != e.Value,
+					Path:     filepath.Join(filepath.Dir(path), value),
+				})
+			}
+		}
+	}
+	return refs
+}
+
+// MainSection returns the quadlet section for the file extension of path,
+// e.g. "Container" for a .container file, or "" if path is not a quadlet.
+func MainSection(path string) string {
+	switch filepath.Ext(path) {
+	case ".container":
+		return "Container"
+	case ".volume":
+		return "Volume"
+	case ".network":
+		return "Network"
+	case ".kube":
+		return "Kube"
+	case ".image":
+		return "Image"
+	case ".build":
+		return "Build"
+	case ".pod":
+		return "Pod"
+	default:
+		return ""
+	}
+}
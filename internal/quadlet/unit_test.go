@@ -0,0 +1,78 @@
+package quadlet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := `# leading comment
+[Unit]
+Description=web \
+  frontend
+
+[Container]
+Image=nginx
+; comment
+EnvironmentFile=web.env
+Volume=data.volume:/data
+Volume=/srv:/srv:Z
+PodmanArgs=--a \
+# skipped
+  --b
+[Container]
+EnvironmentFile=-extra.env
+`
+	u, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(u.Sections) != 3 || u.Sections[1].Name != "Container" || u.Sections[1].Line != 6 {
+		t.Fatalf("sections = %+v", u.Sections)
+	}
+	if v, _ := u.Lookup("Unit", "Description"); v != "web frontend" {
+		t.Errorf("Description = %q", v)
+	}
+	if v, _ := u.Lookup("Container", "PodmanArgs"); v != "--a --b" {
+		t.Errorf("PodmanArgs = %q", v)
+	}
+	if image, ok := u.Image(); !ok || image != "nginx" {
+		t.Errorf("Image() = %q, %v", image, ok)
+	}
+	if got, want := u.EnvironmentFiles(), []string{"web.env", "-extra.env"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvironmentFiles() = %v, want %v", got, want)
+	}
+	if got, want := u.VolumeRefs(), []string{"data.volume", "/srv"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("VolumeRefs() = %v, want %v", got, want)
+	}
+	if _, ok := u.Lookup("Service", "Restart"); ok {
+		t.Error("Lookup of a missing section succeeded")
+	}
+}
+
+func TestLookupAll_EmptyResets(t *testing.T) {
+	u, err := Parse(strings.NewReader("[Container]\nVolume=a:/a\nVolume=\nVolume=b:/b\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := u.LookupAll("Container", "Volume"); !reflect.DeepEqual(got, []string{"b:/b"}) {
+		t.Errorf("LookupAll() = %v, want [b:/b]", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]string{
+		"outside section": "Image=nginx\n",
+		"missing equals":  "[Container]\nImage nginx\n",
+		"bad header":      "[Container\n",
+		"empty key":       "[Container]\n=nginx\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(data)); err == nil || !strings.Contains(err.Error(), "line ") {
+				t.Errorf("Parse() error = %v, want line-numbered error", err)
+			}
+		})
+	}
+}
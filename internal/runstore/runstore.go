@@ -64,6 +64,9 @@ const (
 	TriggerStartup TriggerSource = "startup"
 	// TriggerUI indicates the web UI triggered the run.
 	TriggerUI TriggerSource = "ui"
+	// TriggerWatch indicates a change in a watched source directory
+	// triggered the run.
+	TriggerWatch TriggerSource = "watch"
 )
 
 // RunMeta holds metadata about a sync run.
@@ -75,10 +78,19 @@ type RunMeta struct {
 	EndedAt   *time.Time             `json:"ended_at,omitempty"`
 	Status    RunStatus              `json:"status"`
 	DryRun    bool                   `json:"dry_run"`
-	Revisions map[string]string      `json:"revisions"`         // repo_url -> commit_sha
-	Conflicts []ConflictSummary      `json:"conflicts"`         // serialized conflicts
-	Summary   map[string]interface{} `json:"summary,omitempty"` // counts, best-effort
+	Revisions map[string]string      `json:"revisions"`          // repo_url -> commit_sha
+	Conflicts []ConflictSummary      `json:"conflicts"`          // serialized conflicts
+	Warnings  []WarningSummary       `json:"warnings,omitempty"` // non-fatal issues
+	Summary   map[string]interface{} `json:"summary,omitempty"`  // counts, best-effort
 	Error     string                 `json:"error,omitempty"`
+	ErrorKind string                 `json:"error_kind,omitempty"` // e.g. "auth", "network"
+}
+
+// WarningSummary is the serialized form of a non-fatal sync warning.
+type WarningSummary struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Subject string `json:"subject,omitempty"`
 }
 
 // ConflictSummary is the serialized form of multirepo.Conflict.
@@ -120,6 +132,9 @@ type Plan struct {
 	Requested PlanRequest       `json:"requested"`
 	Conflicts []ConflictSummary `json:"conflicts"`
 	Ops       []PlanOp          `json:"ops"`
+	// UnmanagedFiles lists files in the quadlet dir, relative to it, that
+	// quadsyncd did not write.
+	UnmanagedFiles []string `json:"unmanaged_files,omitempty"`
 }
 
 // ReadWriter abstracts run storage operations for testability.
@@ -0,0 +1,214 @@
+// Package secrets decrypts repository-managed secrets and loads them into
+// podman's secret store.
+//
+// Secret files are encrypted with age or sops and decrypted with the
+// corresponding CLI; plaintext is only ever held in memory and handed to
+// podman on stdin.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+)
+
+// Encryption formats of secret files.
+const (
+	FormatAge  = "age"
+	FormatSOPS = "sops"
+)
+
+// DetectFormat returns the format of a secret file by its extension: ".age"
+// files are age-encrypted, everything else is assumed to be sops-encrypted.
+func DetectFormat(path string) string {
+	if filepath.Ext(path) == ".age" {
+		return FormatAge
+	}
+	return FormatSOPS
+}
+
+// sopsMarkers are the metadata keys sops appends to dotenv, YAML, JSON and
+// INI files respectively.
+var sopsMarkers = [][]byte{
+	[]byte("\nsops_mac="),
+	[]byte("\nsops:"),
+	[]byte(`"sops":`),
+	[]byte("[sops]"),
+}
+
+// IsSOPSEncrypted reports whether data is a file encrypted by sops: it holds
+// sops-encrypted values and sops metadata.
+func IsSOPSEncrypted(data []byte) bool {
+	if !bytes.Contains(data, []byte("ENC[AES256_GCM,")) {
+		return false
+	}
+	for _, marker := range sopsMarkers {
+		if bytes.Contains(data, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// namePattern matches the secret names podman accepts.
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,252}$`)
+
+// ValidName reports whether name can be used as a podman secret name.
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Decrypter decrypts secret files.
+type Decrypter interface {
+	Decrypt(ctx context.Context, format, path string) ([]byte, error)
+}
+
+// Store manages secrets in a secret store.
+type Store interface {
+	// Exists reports whether the secret is present.
+	Exists(ctx context.Context, name string) (bool, error)
+	// Put creates the secret or replaces its value.
+	Put(ctx context.Context, name string, data []byte) error
+	// Remove deletes the secret; a missing secret is not an error.
+	Remove(ctx context.Context, name string) error
+}
+
+// CLIDecrypter decrypts files with the age and sops command line tools.
+type CLIDecrypter struct {
+	ageIdentityFile string
+	timeout         time.Duration
+}
+
+// NewCLIDecrypter returns a decrypter using the given age identity file for
+// age files and, as SOPS_AGE_KEY_FILE, for sops files. Each decryption is
+// killed after timeout (0 means no limit).
+func NewCLIDecrypter(ageIdentityFile string, timeout time.Duration) *CLIDecrypter {
+	return &CLIDecrypter{ageIdentityFile: ageIdentityFile, timeout: timeout}
+}
+
+// Decrypt returns the plaintext of the file at path.
+func (d *CLIDecrypter) Decrypt(ctx context.Context, format, path string) ([]byte, error) {
+	var name string
+	var args, env []string
+	switch format {
+	case FormatAge:
+		if d.ageIdentityFile == "" {
+			return nil, fmt.Errorf("decrypting %s requires secrets.age_identity_file", path)
+		}
+		name, args = "age", []string{"--decrypt", "--identity", d.ageIdentityFile, path}
+	case FormatSOPS:
+		name, args = "sops", []string{"--decrypt", path}
+		if d.ageIdentityFile != "" {
+			env = []string{"SOPS_AGE_KEY_FILE=" + d.ageIdentityFile}
+		}
+	default:
+		return nil, fmt.Errorf("unknown secret format %q", format)
+	}
+
+	ctx, cancel := cmdexec.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	cmd := cmdexec.Command(ctx, name, args...)
+	if env != nil {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmdexec.Err(ctx, d.timeout, cmd.Run()); err != nil {
+		return nil, fmt.Errorf("%s failed to decrypt %s: %w: %s", name, path, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// UnsupportedStore implements Store on hosts without a usable podman
+// secret store, such as macOS and Windows developer machines. It assumes
+// every secret is present so that plans only show the changes the repository
+// asks for; writing fails.
+type UnsupportedStore struct {
+	// GOOS names the host platform in error messages.
+	GOOS string
+}
+
+// Exists reports every secret as present.
+func (s UnsupportedStore) Exists(context.Context, string) (bool, error) { return true, nil }
+
+// Put fails: secrets cannot be stored on this platform.
+func (s UnsupportedStore) Put(_ context.Context, name string, _ []byte) error {
+	return fmt.Errorf("cannot store secret %s: podman secrets are only managed on Linux, not on %s", name, s.GOOS)
+}
+
+// Remove fails: secrets cannot be removed on this platform.
+func (s UnsupportedStore) Remove(_ context.Context, name string) error {
+	return fmt.Errorf("cannot remove secret %s: podman secrets are only managed on Linux, not on %s", name, s.GOOS)
+}
+
+// PodmanStore manages secrets with `podman secret`.
+type PodmanStore struct {
+	timeout time.Duration
+}
+
+// NewPodmanStore returns a store whose podman invocations are each killed
+// after timeout (0 means no limit).
+func NewPodmanStore(timeout time.Duration) *PodmanStore {
+	return &PodmanStore{timeout: timeout}
+}
+
+// Exists reports whether podman has a secret called name.
+func (s *PodmanStore) Exists(ctx context.Context, name string) (bool, error) {
+	_, err := s.podman(ctx, nil, "secret", "exists", name)
+	if err == nil {
+		return true, nil
+	}
+	if exitCode(err) == 1 {
+		return false, nil
+	}
+	return false, err
+}
+
+// Put creates or replaces the secret, passing data on stdin.
+func (s *PodmanStore) Put(ctx context.Context, name string, data []byte) error {
+	_, err := s.podman(ctx, data, "secret", "create", "--replace", name, "-")
+	return err
+}
+
+// Remove deletes the secret if it exists.
+func (s *PodmanStore) Remove(ctx context.Context, name string) error {
+	exists, err := s.Exists(ctx, name)
+	if err != nil || !exists {
+		return err
+	}
+	_, err = s.podman(ctx, nil, "secret", "rm", name)
+	return err
+}
+
+// podman runs podman with args, feeding stdin when non-nil.
+func (s *PodmanStore) podman(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	cmd := cmdexec.Command(ctx, "podman", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	out, err := cmd.CombinedOutput()
+	if err = cmdexec.Err(ctx, s.timeout, err); err != nil {
+		return out, fmt.Errorf("podman %s: %w: %s", strings.Join(args[:2], " "), err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// exitCode returns the exit status wrapped in err, or -1.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
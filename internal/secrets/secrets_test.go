@@ -0,0 +1,176 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTool writes an executable shell script called name to dir.
+func fakeTool(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// withFakeTools prepends a temp directory to PATH and returns it.
+func withFakeTools(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"db-password.age":        FormatAge,
+		"secrets/api.enc.yaml":   FormatSOPS,
+		"token.json":             FormatSOPS,
+		"archive.age.backup.txt": FormatSOPS,
+	}
+	for path, want := range tests {
+		if got := DetectFormat(path); got != want {
+			t.Errorf("DetectFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestIsSOPSEncrypted(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"dotenv", "DB_PASSWORD=ENC[AES256_GCM,data:abc,type:str]\nsops_version=3.8.1\nsops_mac=ENC[AES256_GCM,data:x]\n", true},
+		{"yaml", "password: ENC[AES256_GCM,data:abc,type:str]\nsops:\n    version: 3.8.1\n", true},
+		{"json", `{"password": "ENC[AES256_GCM,data:abc,type:str]", "sops": {"version": "3.8.1"}}`, true},
+		{"ini", "[db]\npassword = ENC[AES256_GCM,data:abc,type:str]\n[sops]\nversion = 3.8.1\n", true},
+		{"plain dotenv", "DB_PASSWORD=hunter2\n", false},
+		{"value without metadata", "NOTE=ENC[AES256_GCM,data:abc]\n", false},
+		{"metadata without values", "sops:\n    version: 3.8.1\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSOPSEncrypted([]byte(tt.data)); got != tt.want {
+				t.Errorf("IsSOPSEncrypted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidName(t *testing.T) {
+	tests := map[string]bool{
+		"db-password":            true,
+		"app.api_token":          true,
+		"":                       false,
+		"-leading-dash":          false,
+		"has space":              false,
+		"slash/name":             false,
+		strings.Repeat("a", 253): true,
+		strings.Repeat("a", 254): false,
+	}
+	for name, want := range tests {
+		if got := ValidName(name); got != want {
+			t.Errorf("ValidName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCLIDecrypter(t *testing.T) {
+	dir := withFakeTools(t)
+	fakeTool(t, dir, "age", `echo "age $*"`)
+	fakeTool(t, dir, "sops", `echo "sops $* key=$SOPS_AGE_KEY_FILE"`)
+
+	d := NewCLIDecrypter("/keys/age.txt", 0)
+	got, err := d.Decrypt(context.Background(), FormatAge, "/repo/db.age")
+	if err != nil {
+		t.Fatalf("Decrypt(age) error = %v", err)
+	}
+	if want := "age --decrypt --identity /keys/age.txt /repo/db.age\n"; string(got) != want {
+		t.Errorf("Decrypt(age) = %q, want %q", got, want)
+	}
+
+	got, err = d.Decrypt(context.Background(), FormatSOPS, "/repo/api.yaml")
+	if err != nil {
+		t.Fatalf("Decrypt(sops) error = %v", err)
+	}
+	if want := "sops --decrypt /repo/api.yaml key=/keys/age.txt\n"; string(got) != want {
+		t.Errorf("Decrypt(sops) = %q, want %q", got, want)
+	}
+}
+
+func TestCLIDecrypter_Errors(t *testing.T) {
+	dir := withFakeTools(t)
+	fakeTool(t, dir, "sops", "echo 'no matching key' >&2\nexit 128")
+
+	if _, err := NewCLIDecrypter("", 0).Decrypt(context.Background(), FormatAge, "/repo/db.age"); err == nil || !strings.Contains(err.Error(), "age_identity_file") {
+		t.Errorf("age without identity: error = %v, want age_identity_file hint", err)
+	}
+	if _, err := NewCLIDecrypter("", 0).Decrypt(context.Background(), "gpg", "/repo/db.gpg"); err == nil {
+		t.Error("unknown format: expected error")
+	}
+	_, err := NewCLIDecrypter("", 0).Decrypt(context.Background(), FormatSOPS, "/repo/api.yaml")
+	if err == nil || !strings.Contains(err.Error(), "no matching key") {
+		t.Errorf("failing sops: error = %v, want stderr in message", err)
+	}
+}
+
+func TestPodmanStore(t *testing.T) {
+	dir := withFakeTools(t)
+	log := filepath.Join(dir, "calls")
+	// "present" exists, everything else does not; create records stdin.
+	fakeTool(t, dir, "podman", `echo "$*" >> `+log+`
+case "$1 $2" in
+"secret exists") [ "$3" = present ] || exit 1 ;;
+"secret create") cat >> `+log+`; echo >> `+log+` ;;
+esac
+`)
+
+	s := NewPodmanStore(0)
+	ctx := context.Background()
+
+	if ok, err := s.Exists(ctx, "present"); err != nil || !ok {
+		t.Errorf("Exists(present) = %v, %v; want true", ok, err)
+	}
+	if ok, err := s.Exists(ctx, "absent"); err != nil || ok {
+		t.Errorf("Exists(absent) = %v, %v; want false", ok, err)
+	}
+	if err := s.Put(ctx, "db", []byte("hunter2")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Remove(ctx, "absent"); err != nil {
+		t.Fatalf("Remove(absent) error = %v", err)
+	}
+	if err := s.Remove(ctx, "present"); err != nil {
+		t.Fatalf("Remove(present) error = %v", err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"secret exists present",
+		"secret exists absent",
+		"secret create --replace db -",
+		"hunter2",
+		"secret exists absent",
+		"secret exists present",
+		"secret rm present",
+	}, "\n") + "\n"
+	if string(data) != want {
+		t.Errorf("podman calls:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestPodmanStore_ExistsError(t *testing.T) {
+	dir := withFakeTools(t)
+	fakeTool(t, dir, "podman", "echo 'cannot connect' >&2\nexit 125")
+
+	if _, err := NewPodmanStore(0).Exists(context.Background(), "db"); err == nil || !strings.Contains(err.Error(), "cannot connect") {
+		t.Errorf("Exists() error = %v, want podman output", err)
+	}
+}
@@ -0,0 +1,73 @@
+// Package selinux restores the default SELinux contexts of files quadsyncd
+// installs.
+//
+// Files are written under a temporary name and renamed into place, so they
+// carry the context inherited from their directory rather than the one the
+// policy assigns to their path. restorecon sets the latter.
+package selinux
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+)
+
+// enforcePath is the selinuxfs file holding the enforcement mode; replaced
+// in tests.
+var enforcePath = "/sys/fs/selinux/enforce"
+
+// Status reports whether SELinux is enabled, i.e. selinuxfs is mounted, and
+// whether it is enforcing.
+func Status() (enabled, enforcing bool) {
+	data, err := os.ReadFile(enforcePath)
+	if err != nil {
+		return false, false
+	}
+	return true, strings.TrimSpace(string(data)) == "1"
+}
+
+// Labeler restores the default SELinux contexts of files.
+type Labeler interface {
+	// Status reports whether SELinux is enabled and enforcing.
+	Status() (enabled, enforcing bool)
+	// Restore resets the contexts of paths to the policy defaults.
+	Restore(ctx context.Context, paths []string) error
+}
+
+// CLILabeler is a Labeler running restorecon.
+type CLILabeler struct {
+	timeout time.Duration
+}
+
+// NewCLILabeler returns a labeler whose restorecon runs are killed after
+// timeout (0 means no limit).
+func NewCLILabeler(timeout time.Duration) *CLILabeler {
+	return &CLILabeler{timeout: timeout}
+}
+
+// Status implements Labeler with the state of the running kernel.
+func (l *CLILabeler) Status() (enabled, enforcing bool) {
+	return Status()
+}
+
+// Restore runs restorecon on paths. Directories are relabeled themselves,
+// not recursively.
+func (l *CLILabeler) Restore(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	ctx, cancel := cmdexec.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	cmd := cmdexec.Command(ctx, "restorecon", append([]string{"--"}, paths...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return cmdexec.Err(ctx, l.timeout, fmt.Errorf("restorecon failed: %w: %s", err, strings.TrimSpace(stderr.String())))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package selinux
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name          string
+		content       string
+		wantEnabled   bool
+		wantEnforcing bool
+	}{
+		{name: "enforcing", content: "1", wantEnabled: true, wantEnforcing: true},
+		{name: "permissive", content: "0", wantEnabled: true},
+		{name: "disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			orig := enforcePath
+			enforcePath = path
+			t.Cleanup(func() { enforcePath = orig })
+
+			enabled, enforcing := Status()
+			if enabled != tt.wantEnabled || enforcing != tt.wantEnforcing {
+				t.Errorf("Status() = %v, %v, want %v, %v", enabled, enforcing, tt.wantEnabled, tt.wantEnforcing)
+			}
+		})
+	}
+}
+
+func TestCLILabeler_Restore(t *testing.T) {
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := "#!/bin/sh\necho \"$*\" > " + argsFile + "\n[ \"$2\" = /fail ] && { echo 'no such file' >&2; exit 255; }\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(binDir, "restorecon"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	l := NewCLILabeler(0)
+	if err := l.Restore(context.Background(), []string{"/q/web.container", "/q/app.env"}); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if data, _ := os.ReadFile(argsFile); strings.TrimSpace(string(data)) != "-- /q/web.container /q/app.env" {
+		t.Errorf("restorecon args = %q", data)
+	}
+	err := l.Restore(context.Background(), []string{"/fail"})
+	if err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("Restore() error = %v, want stderr in the error", err)
+	}
+}
@@ -2,7 +2,6 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -37,7 +36,12 @@ type UnitInfo struct {
 	SourceRepo string `json:"source_repo,omitempty"`
 	SourceRef  string `json:"source_ref,omitempty"`
 	SourceSHA  string `json:"source_sha,omitempty"`
-	Hash       string `json:"hash"`
+	// SourceLayer is the subdir layer the unit came from, if any.
+	SourceLayer string `json:"source_layer,omitempty"`
+	Hash        string `json:"hash"`
+	// ActiveState is the unit's systemd ActiveState, e.g. "active" or
+	// "failed"; empty when systemd could not be asked.
+	ActiveState string `json:"active_state,omitempty"`
 }
 
 // UnitsResponse is the response shape for GET /api/units.
@@ -45,6 +49,40 @@ type UnitsResponse struct {
 	Items []UnitInfo `json:"items"`
 }
 
+// FileInfo describes a single managed file, keyed by its destination path.
+type FileInfo struct {
+	Path        string `json:"path"`
+	SourcePath  string `json:"source_path"`
+	SourceRepo  string `json:"source_repo,omitempty"`
+	SourceRef   string `json:"source_ref,omitempty"`
+	SourceSHA   string `json:"source_sha,omitempty"`
+	SourceLayer string `json:"source_layer,omitempty"`
+	Hash        string `json:"hash"`
+}
+
+// FilesResponse is the response shape for GET /api/files.
+type FilesResponse struct {
+	Items []FileInfo `json:"items"`
+}
+
+// StatusResponse is the response shape for GET /api/status: what
+// `quadsyncd status` reports, for monitoring.
+type StatusResponse struct {
+	Revisions       map[string]string       `json:"revisions"`
+	Refs            map[string]string       `json:"refs,omitempty"`
+	ManagedFiles    int                     `json:"managed_files"`
+	PendingRestarts []string                `json:"pending_restarts,omitempty"`
+	Freeze          *dto.FreezeResponse     `json:"freeze,omitempty"`
+	Pause           *dto.PauseResponse      `json:"pause,omitempty"`
+	LastSync        *quadsyncd.HistoryEntry `json:"last_sync,omitempty"`
+	Sync            dto.SyncWorkerResponse  `json:"sync"`
+}
+
+// HistoryResponse is the response shape for GET /api/history.
+type HistoryResponse struct {
+	Items []quadsyncd.HistoryEntry `json:"items"`
+}
+
 // TimerInfo is the response shape for GET /api/timer.
 type TimerInfo struct {
 	Unit   string `json:"unit"`
@@ -64,6 +102,27 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 		}
 		s.handleOverview(w, r)
 		return
+	case "/api/status":
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		s.handleStatus(w, r)
+		return
+	case "/api/sync":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		s.handleSyncNow(w, r)
+		return
+	case "/api/files":
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		s.handleFiles(w, r)
+		return
 	case "/api/runs":
 		if r.Method != http.MethodGet {
 			writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -78,6 +137,13 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 		}
 		s.handleUnits(w, r)
 		return
+	case "/api/history":
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		s.handleHistory(w, r)
+		return
 	case "/api/timer":
 		if r.Method != http.MethodGet {
 			writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -132,9 +198,9 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleOverview(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	repos := s.cfg.EffectiveRepositories()
+	repos := s.config().EffectiveRepositories()
 
-	state, err := loadSyncState(s.cfg.StateFilePath())
+	state, err := loadSyncState(s.config().StateFilePath())
 	if err != nil {
 		s.logger.Warn("failed to load sync state for overview", "error", err)
 	}
@@ -153,16 +219,117 @@ func (s *Server) handleOverview(w http.ResponseWriter, r *http.Request) {
 		overviewRepos[i] = or
 	}
 
-	resp := dto.OverviewResponse{Repositories: overviewRepos}
+	resp := dto.OverviewResponse{Repositories: overviewRepos, PendingRestarts: state.PendingRestarts}
+
+	if freeze, err := quadsyncd.ReadFreeze(s.config().Paths.StateDir); err != nil {
+		s.logger.Warn("failed to read freeze file for overview", "error", err)
+	} else if freeze != nil {
+		resp.Freeze = &dto.FreezeResponse{Since: freeze.Since.Format(time.RFC3339), Reason: freeze.Reason}
+	}
+	resp.Pause = s.readPause()
+
+	resp.Sync = s.syncWorkerStatus()
 
 	if runs, err := s.store.List(ctx); err == nil && len(runs) > 0 {
 		resp.LastRunID = runs[0].ID
 		resp.LastRunStatus = string(runs[0].Status)
+		resp.LastRunWarnings = len(runs[0].Warnings)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// syncWorkerStatus reports what the sync worker is doing.
+func (s *Server) syncWorkerStatus() dto.SyncWorkerResponse {
+	worker := s.syncSvc.Status()
+	resp := dto.SyncWorkerResponse{Running: worker.Running, Queued: worker.Queued}
+	if worker.Running {
+		resp.Trigger = string(worker.Trigger)
+		resp.StartedAt = worker.StartedAt.Format(time.RFC3339Nano)
+	}
+	return resp
+}
+
+// handleStatus serves GET /api/status.
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	cfg := s.config()
+	state, err := loadSyncState(cfg.StateFilePath())
+	if err != nil {
+		s.logger.Warn("failed to load sync state for status", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to read sync state")
+		return
+	}
+
+	resp := StatusResponse{
+		Revisions:       state.Revisions,
+		Refs:            state.Refs,
+		ManagedFiles:    len(state.ManagedFiles),
+		PendingRestarts: state.PendingRestarts,
+		Sync:            s.syncWorkerStatus(),
+	}
+	if resp.Revisions == nil {
+		resp.Revisions = map[string]string{}
+		if state.Commit != "" {
+			if repos := cfg.EffectiveRepositories(); len(repos) == 1 {
+				resp.Revisions[repos[0].URL] = state.Commit
+			}
+		}
+	}
+
+	if freeze, err := quadsyncd.ReadFreeze(cfg.Paths.StateDir); err != nil {
+		s.logger.Warn("failed to read freeze file for status", "error", err)
+	} else if freeze != nil {
+		resp.Freeze = &dto.FreezeResponse{Since: freeze.Since.Format(time.RFC3339), Reason: freeze.Reason}
+	}
+	resp.Pause = s.readPause()
+
+	if entries, err := quadsyncd.ReadHistory(cfg.Paths.StateDir, 1); err != nil {
+		s.logger.Warn("failed to read sync history for status", "error", err)
+	} else if len(entries) > 0 {
+		resp.LastSync = &entries[0]
 	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// handleSyncNow serves POST /api/sync: it queues a sync like a webhook would
+// and returns without waiting for it. The run shows up under /api/runs with
+// trigger "ui".
+func (s *Server) handleSyncNow(w http.ResponseWriter, _ *http.Request) {
+	if !s.syncSvc.Enqueue(runstore.TriggerUI) {
+		writeJSONError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.syncWorkerStatus())
+}
+
+// handleFiles serves GET /api/files: every managed file, quadlets and
+// companions alike, sorted by path.
+func (s *Server) handleFiles(w http.ResponseWriter, _ *http.Request) {
+	state, err := loadSyncState(s.config().StateFilePath())
+	if err != nil {
+		s.logger.Warn("failed to load sync state for files", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to read sync state")
+		return
+	}
+
+	items := make([]FileInfo, 0, len(state.ManagedFiles))
+	for destPath, mf := range state.ManagedFiles {
+		items = append(items, FileInfo{
+			Path:        destPath,
+			SourcePath:  mf.SourcePath,
+			SourceRepo:  mf.SourceRepo,
+			SourceRef:   mf.SourceRef,
+			SourceSHA:   mf.SourceSHA,
+			SourceLayer: mf.SourceLayer,
+			Hash:        mf.Hash,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+
+	writeJSON(w, http.StatusOK, FilesResponse{Items: items})
+}
+
 // handleRuns serves GET /api/runs?limit=&cursor=.
 func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -320,8 +487,8 @@ func (s *Server) handleRunPlan(w http.ResponseWriter, r *http.Request, id string
 }
 
 // handleUnits serves GET /api/units.
-func (s *Server) handleUnits(w http.ResponseWriter, _ *http.Request) {
-	state, err := loadSyncState(s.cfg.StateFilePath())
+func (s *Server) handleUnits(w http.ResponseWriter, r *http.Request) {
+	state, err := loadSyncState(s.config().StateFilePath())
 	if err != nil {
 		s.logger.Warn("failed to load sync state for units", "error", err)
 	}
@@ -332,19 +499,54 @@ func (s *Server) handleUnits(w http.ResponseWriter, _ *http.Request) {
 			continue
 		}
 		items = append(items, UnitInfo{
-			Name:       quadlet.UnitNameFromQuadlet(destPath),
-			SourcePath: mf.SourcePath,
-			SourceRepo: mf.SourceRepo,
-			SourceRef:  mf.SourceRef,
-			SourceSHA:  mf.SourceSHA,
-			Hash:       mf.Hash,
+			Name:        quadlet.UnitNameFromQuadlet(destPath),
+			SourcePath:  mf.SourcePath,
+			SourceRepo:  mf.SourceRepo,
+			SourceRef:   mf.SourceRef,
+			SourceSHA:   mf.SourceSHA,
+			SourceLayer: mf.SourceLayer,
+			Hash:        mf.Hash,
 		})
 	}
 	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
 
+	// One bound for all units, so a hung systemd does not stall the response.
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+	for i := range items {
+		if status, err := s.systemd.GetUnitStatus(ctx, items[i].Name); err == nil {
+			items[i].ActiveState = status
+		}
+	}
+
 	writeJSON(w, http.StatusOK, UnitsResponse{Items: items})
 }
 
+// handleHistory serves GET /api/history?limit=, newest run first.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if limit > quadsyncd.HistoryLimit {
+		limit = quadsyncd.HistoryLimit
+	}
+
+	entries, err := quadsyncd.ReadHistory(s.config().Paths.StateDir, limit)
+	if err != nil {
+		s.logger.Warn("failed to read sync history", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to read history")
+		return
+	}
+	if entries == nil {
+		entries = []quadsyncd.HistoryEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, HistoryResponse{Items: entries})
+}
+
 // handleTimer serves GET /api/timer.
 func (s *Server) handleTimer(w http.ResponseWriter, r *http.Request) {
 	const timerUnit = "quadsyncd-sync.timer"
@@ -369,12 +571,9 @@ func loadSyncState(stateFilePath string) (quadsyncd.State, error) {
 		}
 		return quadsyncd.State{}, fmt.Errorf("failed to read state file: %w", err)
 	}
-	var state quadsyncd.State
-	if err := json.Unmarshal(data, &state); err != nil {
+	state, err := quadsyncd.ParseState(data)
+	if err != nil {
 		return quadsyncd.State{}, fmt.Errorf("failed to parse state file: %w", err)
 	}
-	if state.ManagedFiles == nil {
-		state.ManagedFiles = make(map[string]quadsyncd.ManagedFile)
-	}
-	return state, nil
+	return *state, nil
 }
@@ -19,6 +19,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/schaermu/quadsyncd/internal/logging"
 	"github.com/schaermu/quadsyncd/internal/runstore"
 )
 
@@ -111,7 +112,7 @@ func (b *Broadcaster) broadcast(ev sseEvent) {
 		case ch <- ev:
 		default:
 			b.logger.Warn("broadcaster: subscriber buffer full, dropping event",
-				"kind", ev.kind, "run_id", ev.payload.RunID)
+				"kind", ev.kind, logging.KeyRunID, ev.payload.RunID)
 		}
 	}
 }
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// webhookTrigger carries the metadata of a single accepted webhook delivery.
+type webhookTrigger struct {
+	DeliveryID string
+	Event      string
+	Ref        string
+	Commit     string
+	// Path is the changed file of a source watch trigger.
+	Path string
+}
+
+// debounceBatch describes the triggers merged into one debounced callback.
+type debounceBatch struct {
+	// Last is the most recent trigger in the batch.
+	Last webhookTrigger
+	// Coalesced is the number of triggers merged into this batch (>= 1).
+	Coalesced int
+	// Waited is the time between the first trigger and the callback firing.
+	Waited time.Duration
+}
+
+// debouncer coalesces bursts of webhook events into a single callback.
+//
+// Every trigger resets the timer, but never beyond maxWait after the first
+// trigger of a batch (when maxWait is positive), so a steady stream of events
+// still produces a callback. Once the timer elapses, the callback runs with a
+// batch describing the merged events and a context that stop cancels once
+// its grace period has passed. A generation counter ensures a timer that
+// already fired but lost the race against a reset or stop never runs a stale
+// callback, and stop prevents any further callbacks from being scheduled.
+type debouncer struct {
+	mu       sync.Mutex
+	delay    time.Duration
+	maxWait  time.Duration
+	timer    *time.Timer
+	gen      uint64
+	stopped  bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	callback func(context.Context, debounceBatch)
+	batch    debounceBatch
+	first    time.Time
+	wg       sync.WaitGroup
+}
+
+// newDebouncer creates a debouncer with the given delay that fires at most
+// maxWait after the first trigger of a batch; 0 means no upper bound.
+func newDebouncer(delay, maxWait time.Duration) *debouncer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &debouncer{delay: delay, maxWait: maxWait, ctx: ctx, cancel: cancel}
+}
+
+// setDelays changes the delay and max wait for triggers from now on.
+func (d *debouncer) setDelays(delay, maxWait time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.delay = delay
+	d.maxWait = maxWait
+}
+
+// trigger records t and (re)schedules callback to run after the debounce
+// delay, or when the batch reaches maxWait if that is sooner. The callback of the latest trigger wins. It reports false when the
+// debouncer has been stopped and the trigger was dropped.
+func (d *debouncer) trigger(t webhookTrigger, callback func(context.Context, debounceBatch)) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return false
+	}
+
+	if d.batch.Coalesced == 0 {
+		d.first = time.Now()
+	}
+	d.batch.Last = t
+	d.batch.Coalesced++
+	d.callback = callback
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	delay := d.delay
+	if d.maxWait > 0 {
+		delay = min(delay, max(d.maxWait-time.Since(d.first), 0))
+	}
+	d.gen++
+	gen := d.gen
+	d.timer = time.AfterFunc(delay, func() { d.fire(gen) })
+	return true
+}
+
+// fire runs the pending callback if gen is still the current generation.
+func (d *debouncer) fire(gen uint64) {
+	d.mu.Lock()
+	if d.stopped || gen != d.gen || d.batch.Coalesced == 0 {
+		d.mu.Unlock()
+		return
+	}
+	cb := d.callback
+	batch := d.batch
+	batch.Waited = time.Since(d.first)
+	d.batch = debounceBatch{}
+	d.callback = nil
+	d.timer = nil
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	defer d.wg.Done()
+	if cb != nil {
+		cb(d.ctx, batch)
+	}
+}
+
+// pending returns the number of triggers waiting for the timer to fire.
+func (d *debouncer) pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.batch.Coalesced
+}
+
+// stop cancels any pending callback and waits up to grace for a callback
+// that is already running, then cancels its context and waits for it to
+// return. Triggers after stop are dropped. It returns the number of pending
+// triggers that were discarded.
+func (d *debouncer) stop(grace time.Duration) int {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return 0
+	}
+	d.stopped = true
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	dropped := d.batch.Coalesced
+	d.batch = debounceBatch{}
+	d.callback = nil
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	if grace > 0 {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+		}
+	}
+	d.cancel()
+	<-done
+	return dropped
+}
@@ -19,6 +19,7 @@ func RunResponseFromMeta(m *runstore.RunMeta) RunResponse {
 		Revisions: m.Revisions,
 		Summary:   m.Summary,
 		Error:     m.Error,
+		ErrorKind: m.ErrorKind,
 	}
 	if m.EndedAt != nil {
 		r.EndedAt = m.EndedAt.Format(time.RFC3339Nano)
@@ -27,6 +28,10 @@ func RunResponseFromMeta(m *runstore.RunMeta) RunResponse {
 	for i, c := range m.Conflicts {
 		r.Conflicts[i] = ConflictResponseFromSummary(c)
 	}
+	r.Warnings = make([]WarningResponse, len(m.Warnings))
+	for i, w := range m.Warnings {
+		r.Warnings[i] = WarningResponse(w)
+	}
 	return r
 }
 
@@ -65,8 +70,9 @@ func PlanResponseFromPlan(p *runstore.Plan) PlanResponse {
 			Ref:     p.Requested.Ref,
 			Commit:  p.Requested.Commit,
 		},
-		Conflicts: conflicts,
-		Ops:       ops,
+		Conflicts:      conflicts,
+		Ops:            ops,
+		UnmanagedFiles: append([]string{}, p.UnmanagedFiles...),
 	}
 }
 
@@ -260,6 +260,9 @@ func TestPlanResponseFromPlan_ConflictsNeverNull(t *testing.T) {
 	if !containsSubstring(b, `"ops":[]`) {
 		t.Errorf("ops should serialize as [] not null; got: %s", string(b))
 	}
+	if !containsSubstring(b, `"unmanaged_files":[]`) {
+		t.Errorf("unmanaged_files should serialize as [] not null; got: %s", string(b))
+	}
 }
 
 // ---- ConflictResponseFromSummary ----
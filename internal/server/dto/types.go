@@ -13,8 +13,10 @@ type RunResponse struct {
 	DryRun    bool                   `json:"dry_run"`
 	Revisions map[string]string      `json:"revisions"`
 	Conflicts []ConflictResponse     `json:"conflicts"`
+	Warnings  []WarningResponse      `json:"warnings"`
 	Summary   map[string]interface{} `json:"summary,omitempty"`
 	Error     string                 `json:"error,omitempty"`
+	ErrorKind string                 `json:"error_kind,omitempty"`
 }
 
 // RunsListResponse wraps paginated run results.
@@ -25,9 +27,10 @@ type RunsListResponse struct {
 
 // PlanResponse is the API representation of a plan.
 type PlanResponse struct {
-	Requested PlanRequestResponse `json:"requested"`
-	Conflicts []ConflictResponse  `json:"conflicts"`
-	Ops       []PlanOpResponse    `json:"ops"`
+	Requested      PlanRequestResponse `json:"requested"`
+	Conflicts      []ConflictResponse  `json:"conflicts"`
+	Ops            []PlanOpResponse    `json:"ops"`
+	UnmanagedFiles []string            `json:"unmanaged_files"`
 }
 
 // PlanOpResponse is the API representation of a plan operation.
@@ -49,6 +52,13 @@ type PlanRequestResponse struct {
 	Commit  string `json:"commit,omitempty"`
 }
 
+// WarningResponse is the API representation of a non-fatal sync warning.
+type WarningResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Subject string `json:"subject,omitempty"`
+}
+
 // ConflictResponse is the API representation of a conflict.
 type ConflictResponse struct {
 	MergeKey string                  `json:"merge_key"`
@@ -76,6 +86,45 @@ type OverviewResponse struct {
 	Repositories  []OverviewRepo `json:"repositories"`
 	LastRunID     string         `json:"last_run_id,omitempty"`
 	LastRunStatus string         `json:"last_run_status,omitempty"`
+	// LastRunWarnings is the number of warnings recorded by the latest run.
+	LastRunWarnings int `json:"last_run_warnings"`
+	// Sync is the state of the server's sync worker.
+	Sync SyncWorkerResponse `json:"sync"`
+	// Freeze is set while syncs are frozen with `quadsyncd freeze`.
+	Freeze *FreezeResponse `json:"freeze,omitempty"`
+	// Pause is set while syncs are paused with `quadsyncd pause` or
+	// POST /-/pause.
+	Pause *PauseResponse `json:"pause,omitempty"`
+	// PendingRestarts are units whose restart waits for a sync outside
+	// sync.windows.
+	PendingRestarts []string `json:"pending_restarts,omitempty"`
+}
+
+// FreezeResponse is the API representation of a sync freeze.
+type FreezeResponse struct {
+	Since  string `json:"since"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PauseResponse is the API representation of a sync pause.
+type PauseResponse struct {
+	Since  string `json:"since"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ResumeResponse is the response of POST /-/resume.
+type ResumeResponse struct {
+	// Resumed is false when syncs were not paused.
+	Resumed bool `json:"resumed"`
+}
+
+// SyncWorkerResponse is the API representation of the sync worker state.
+type SyncWorkerResponse struct {
+	Running   bool   `json:"running"`
+	Trigger   string `json:"trigger,omitempty"`
+	StartedAt string `json:"started_at,omitempty"`
+	// Queued is the number of syncs waiting behind the running one.
+	Queued int `json:"queued"`
 }
 
 // OverviewRepo is the API representation of a tracked repository.
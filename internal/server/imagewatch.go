@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/imagewatch"
+	"github.com/schaermu/quadsyncd/internal/logging"
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+)
+
+// runImageWatch checks the images of the managed container quadlets every
+// image_watch.interval until ctx is cancelled. The first check runs one
+// interval after startup so that it does not race the initial sync.
+func (s *Server) runImageWatch(ctx context.Context) {
+	interval := s.config().ImageWatch.Interval
+	s.logger.Info("image watcher enabled", "interval", interval, "action", s.config().ImageWatch.Action)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkImages(ctx)
+		}
+	}
+}
+
+// checkImages runs one image watch pass over the files recorded in state.
+func (s *Server) checkImages(ctx context.Context) {
+	state, err := quadsyncd.ReadStateFile(s.config().StateFilePath())
+	if err != nil {
+		s.logger.Warn("image watcher failed to read state", logging.KeyError, err)
+		return
+	}
+	paths := make([]string, 0, len(state.ManagedFiles))
+	for path := range state.ManagedFiles {
+		paths = append(paths, path)
+	}
+	updates, err := s.imageWatcher.Run(ctx, paths)
+	if err != nil {
+		s.logger.Warn("image watch pass finished with errors", logging.KeyCount, len(updates), logging.KeyError, err)
+		return
+	}
+	s.logger.Debug("image watch pass finished", logging.KeyCount, len(updates))
+}
+
+// newImageWatcher returns the watcher used when image_watch is enabled.
+func (s *Server) newImageWatcher() *imagewatch.Watcher {
+	registry := imagewatch.NewPodmanRegistry(s.config().Timeouts.Podman)
+	return imagewatch.NewWatcher(registry, s.systemd, s.config().ImageWatch.Action, s.logger)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+)
+
+// ipFilter restricts webhook callers to configured address ranges.
+type ipFilter struct {
+	allowed []netip.Prefix // empty allows every client
+	trusted []netip.Prefix // proxies whose X-Forwarded-For is honoured
+}
+
+// newIPFilter builds an ipFilter from the serve configuration.
+func newIPFilter(cfg config.ServeConfig) (*ipFilter, error) {
+	allowed, err := config.ParseCIDRList(cfg.AllowedCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid serve.allowed_cidrs: %w", err)
+	}
+	trusted, err := config.ParseCIDRList(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid serve.trusted_proxies: %w", err)
+	}
+	return &ipFilter{allowed: allowed, trusted: trusted}, nil
+}
+
+// allow reports whether the request's client address is permitted, along
+// with the address it evaluated.
+func (f *ipFilter) allow(r *http.Request) (netip.Addr, bool) {
+	client := f.clientAddr(r)
+	if len(f.allowed) == 0 {
+		return client, true
+	}
+	return client, client.IsValid() && prefixesContain(f.allowed, client)
+}
+
+// clientAddr returns the address of the client that sent r. When the direct
+// peer is a trusted proxy, X-Forwarded-For is walked from the right and the
+// first address that is not itself a trusted proxy is returned.
+func (f *ipFilter) clientAddr(r *http.Request) netip.Addr {
+	peer := parseAddr(r.RemoteAddr)
+	if len(f.trusted) == 0 || !peer.IsValid() || !prefixesContain(f.trusted, peer) {
+		return peer
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr := parseAddr(strings.TrimSpace(hops[i]))
+		if !addr.IsValid() {
+			// A malformed hop cannot be attributed; stop at the last good one.
+			break
+		}
+		client = addr
+		if !prefixesContain(f.trusted, addr) {
+			break
+		}
+	}
+	return client
+}
+
+// parseAddr parses an "ip" or "ip:port" string, returning the zero Addr on
+// failure. IPv4-mapped IPv6 addresses are unmapped.
+func parseAddr(s string) netip.Addr {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// prefixesContain reports whether addr falls into any of prefixes.
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
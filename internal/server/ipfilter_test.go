@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/runstore"
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestIPFilter_Allow(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    []string
+		trusted    []string
+		remoteAddr string
+		xff        []string
+		wantClient string
+		wantOK     bool
+	}{
+		{
+			name:       "no allowlist accepts everyone",
+			remoteAddr: "203.0.113.9:1234",
+			wantClient: "203.0.113.9",
+			wantOK:     true,
+		},
+		{
+			name:       "direct client inside range",
+			allowed:    []string{"203.0.113.0/24"},
+			remoteAddr: "203.0.113.9:1234",
+			wantClient: "203.0.113.9",
+			wantOK:     true,
+		},
+		{
+			name:       "direct client outside range",
+			allowed:    []string{"203.0.113.0/24"},
+			remoteAddr: "198.51.100.7:1234",
+			wantClient: "198.51.100.7",
+			wantOK:     false,
+		},
+		{
+			name:       "github keyword",
+			allowed:    []string{"github"},
+			remoteAddr: "140.82.115.10:443",
+			wantClient: "140.82.115.10",
+			wantOK:     true,
+		},
+		{
+			name:       "forwarded header ignored without trusted proxies",
+			allowed:    []string{"github"},
+			remoteAddr: "127.0.0.1:5555",
+			xff:        []string{"140.82.115.10"},
+			wantClient: "127.0.0.1",
+			wantOK:     false,
+		},
+		{
+			name:       "forwarded header from trusted proxy",
+			allowed:    []string{"github"},
+			trusted:    []string{"127.0.0.1"},
+			remoteAddr: "127.0.0.1:5555",
+			xff:        []string{"140.82.115.10"},
+			wantClient: "140.82.115.10",
+			wantOK:     true,
+		},
+		{
+			name:       "spoofed leftmost hop is ignored",
+			allowed:    []string{"github"},
+			trusted:    []string{"127.0.0.1", "10.0.0.0/8"},
+			remoteAddr: "127.0.0.1:5555",
+			xff:        []string{"140.82.115.10, 198.51.100.7", "10.1.2.3"},
+			wantClient: "198.51.100.7",
+			wantOK:     false,
+		},
+		{
+			name:       "forwarded header from untrusted peer",
+			allowed:    []string{"github"},
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "198.51.100.7:5555",
+			xff:        []string{"140.82.115.10"},
+			wantClient: "198.51.100.7",
+			wantOK:     false,
+		},
+		{
+			name:       "ipv6 client",
+			allowed:    []string{"2606:50c0::/32"},
+			remoteAddr: "[2606:50c0:1::5]:443",
+			wantClient: "2606:50c0:1::5",
+			wantOK:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newIPFilter(config.ServeConfig{AllowedCIDRs: tt.allowed, TrustedProxies: tt.trusted})
+			if err != nil {
+				t.Fatalf("newIPFilter: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			client, ok := f.allow(req)
+			if ok != tt.wantOK {
+				t.Errorf("allow() = %v, want %v", ok, tt.wantOK)
+			}
+			if client.String() != tt.wantClient {
+				t.Errorf("client = %s, want %s", client, tt.wantClient)
+			}
+		})
+	}
+}
+
+func TestHandleWebhook_DisallowedAddress(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	cfg.Serve.AllowedCIDRs = []string{"github"}
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+
+	// Unsigned on purpose: the address check runs before signature verification.
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(`{}`)))
+	req.RemoteAddr = "198.51.100.7:1234"
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	srv.handleWebhook(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rec.Code)
+	}
+	if !bytes.Contains(rec.Body.Bytes(), []byte("Forbidden")) {
+		t.Errorf("unexpected body: %q", rec.Body.String())
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/config"
 )
 
 // cspPolicy is the Content-Security-Policy applied to all UI and API responses.
@@ -53,19 +55,21 @@ func generateCSRFToken() (string, error) {
 //
 // On GET / the middleware ensures the csrf_token cookie is set (readable by JS,
 // SameSite=Lax, not HttpOnly). For mutating requests (POST/PUT/PATCH/DELETE) on
-// any path except /webhook (which is protected by HMAC-SHA256 signature), the
-// middleware requires the X-CSRF-Token request header to match the cookie value
-// using a constant-time comparison; mismatches are rejected with HTTP 403.
-func csrfMiddleware(next http.Handler) http.Handler {
+// any path except /webhook and webhookPaths (which are protected by the
+// provider's signature), the middleware requires the X-CSRF-Token request
+// header to match the cookie value using a constant-time comparison;
+// mismatches are rejected with HTTP 403.
+func csrfMiddleware(next http.Handler, webhookPaths ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// /webhook has its own HMAC-based authentication; skip CSRF for it.
-		if r.URL.Path == "/webhook" {
+		// Webhooks have their own signature-based authentication; skip CSRF
+		// for them.
+		if r.URL.Path == config.DefaultWebhookPath || sliceContains(webhookPaths, r.URL.Path) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// On GET / ensure the CSRF cookie is present (or non-empty) so the SPA can read it.
-		if r.URL.Path == "/" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		// On GET / and the UI ensure the CSRF cookie is present (or non-empty) so the SPA can read it.
+		if (r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, uiPath)) && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
 			existing, err := r.Cookie(csrfCookieName)
 			if err != nil || existing.Value == "" {
 				token, err := generateCSRFToken()
@@ -107,3 +111,19 @@ func csrfMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireAPIToken guards an API handler with serve.api_token_file. When a
+// token is configured, requests must carry it as
+// "Authorization: Bearer <token>" or hold a Web UI session cookie; anything
+// else is rejected with HTTP 401. Without a token the handler is open, as
+// before the option existed.
+func (s *Server) requireAPIToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="quadsyncd"`)
+			writeJSONError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		next(w, r)
+	}
+}
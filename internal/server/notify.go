@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/schaermu/quadsyncd/internal/systemdproto"
+)
+
+// notify sends sd_notify state to systemd when running as a Type=notify
+// service; it is a no-op otherwise.
+func (s *Server) notify(states ...string) {
+	if _, err := systemdproto.Notify(states...); err != nil {
+		s.logger.Warn("failed to notify systemd", "error", err)
+	}
+}
+
+// notifySyncStatus publishes the outcome of a finished sync as the service
+// status line shown by systemctl status.
+func (s *Server) notifySyncStatus(result *quadsyncd.Result, err error) {
+	s.notify(systemdproto.Status(syncStatusLine(time.Now(), result, err)))
+}
+
+// syncStatusLine summarises a sync run in one line.
+func syncStatusLine(at time.Time, result *quadsyncd.Result, err error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Last sync %s: ", at.Format(time.RFC3339))
+	if err != nil {
+		fmt.Fprintf(&b, "failed: %v", err)
+		return b.String()
+	}
+	b.WriteString("ok")
+	if result == nil {
+		return b.String()
+	}
+
+	urls := make([]string, 0, len(result.Revisions))
+	for url := range result.Revisions {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	revs := make([]string, 0, len(urls))
+	for _, url := range urls {
+		sha := result.Revisions[url]
+		if len(sha) > 12 {
+			sha = sha[:12]
+		}
+		revs = append(revs, sha)
+	}
+	if len(revs) > 0 {
+		fmt.Fprintf(&b, " at %s", strings.Join(revs, ","))
+	}
+	if result.Plan != nil {
+		fmt.Fprintf(&b, " (%d added, %d updated, %d deleted)",
+			len(result.Plan.Add), len(result.Plan.Update), len(result.Plan.Delete))
+	}
+	if n := len(result.RestartFailed); n > 0 {
+		fmt.Fprintf(&b, ", %d restart(s) failed", n)
+	}
+	if n := len(result.UnhealthyUnits); n > 0 {
+		fmt.Fprintf(&b, ", %d unit(s) failed", n)
+	}
+	if n := len(result.AutoUpdated); n > 0 {
+		fmt.Fprintf(&b, ", %d unit(s) auto-updated", n)
+	}
+	if n := len(result.Warnings); n > 0 {
+		fmt.Fprintf(&b, ", %d warning(s)", n)
+	}
+	return b.String()
+}
+
+// runWatchdog sends watchdog keepalives at half the interval systemd
+// requested via WatchdogSec= until ctx is cancelled.
+func (s *Server) runWatchdog(ctx context.Context) {
+	interval, err := systemdproto.WatchdogInterval()
+	if err != nil {
+		s.logger.Warn("ignoring invalid systemd watchdog settings", "error", err)
+		return
+	}
+	if interval == 0 {
+		return
+	}
+
+	s.logger.Info("systemd watchdog enabled", "interval", interval)
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.notify(systemdproto.StateWatchdog)
+		}
+	}
+}
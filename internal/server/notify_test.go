@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/runstore"
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestSyncStatusLine(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		result *quadsyncd.Result
+		err    error
+		want   string
+	}{
+		{
+			name: "failure",
+			err:  errors.New("git fetch failed"),
+			want: "Last sync 2026-03-04T05:06:07Z: failed: git fetch failed",
+		},
+		{
+			name: "success with plan",
+			result: &quadsyncd.Result{
+				Revisions: map[string]string{"b": "0123456789abcdef", "a": "fff"},
+				Plan:      &quadsyncd.Plan{Add: make([]quadsyncd.FileOp, 2), Delete: make([]quadsyncd.FileOp, 1)},
+			},
+			want: "Last sync 2026-03-04T05:06:07Z: ok at fff,0123456789ab (2 added, 0 updated, 1 deleted)",
+		},
+		{
+			name: "success with failed units",
+			result: &quadsyncd.Result{
+				UnhealthyUnits: []string{"web.service"},
+				Warnings:       []quadsyncd.Warning{{Code: quadsyncd.WarnUnitUnhealthy}},
+			},
+			want: "Last sync 2026-03-04T05:06:07Z: ok, 1 unit(s) failed, 1 warning(s)",
+		},
+		{
+			name:   "success with auto-updated units",
+			result: &quadsyncd.Result{AutoUpdated: []string{"db.service", "web.service"}},
+			want:   "Last sync 2026-03-04T05:06:07Z: ok, 2 unit(s) auto-updated",
+		},
+		{
+			name: "success without result",
+			want: "Last sync 2026-03-04T05:06:07Z: ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := syncStatusLine(at, tt.result, tt.err); got != tt.want {
+				t.Errorf("syncStatusLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartWithListener_NotifiesSystemd(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "notify.sock")
+	notifyConn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen notify socket: %v", err)
+	}
+	defer func() { _ = notifyConn.Close() }()
+	t.Setenv("NOTIFY_SOCKET", sockPath)
+	t.Setenv("WATCHDOG_USEC", "")
+
+	cfg, _ := setupTestConfig(t)
+	logger := testutil.TestLogger()
+	if err := os.MkdirAll(cfg.Paths.StateDir, 0755); err != nil {
+		t.Fatalf("failed to create state dir: %v", err)
+	}
+	mockSys := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.StartWithListener(ctx, listener) }()
+
+	// Collect notifications until READY=1 arrives.
+	var got []string
+	buf := make([]byte, 4096)
+	_ = notifyConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for !containsState(got, "READY=1") {
+		n, err := notifyConn.Read(buf)
+		if err != nil {
+			t.Fatalf("read notify socket: %v (got %q)", err, got)
+		}
+		got = append(got, string(buf[:n]))
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("StartWithListener: %v", err)
+	}
+	n, err := notifyConn.Read(buf)
+	if err != nil {
+		t.Fatalf("read notify socket: %v", err)
+	}
+	got = append(got, string(buf[:n]))
+
+	if !containsState(got, "STATUS=Last sync ") {
+		t.Errorf("expected sync status before READY, got %q", got)
+	}
+	if !containsState(got, "STOPPING=1") {
+		t.Errorf("expected STOPPING=1 on shutdown, got %q", got)
+	}
+}
+
+func containsState(msgs []string, prefix string) bool {
+	for _, m := range msgs {
+		for _, line := range strings.Split(m, "\n") {
+			if strings.HasPrefix(line, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
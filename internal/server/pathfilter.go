@@ -0,0 +1,47 @@
+package server
+
+import (
+	"path"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+)
+
+// pushTouchesWatchedPaths reports whether a push that changed the given
+// paths touched any file a matching repository syncs from, i.e. anything
+// under its subdir or layers. When the payload cannot tell (changed is nil)
+// or a repository syncs from its root, it returns true so the sync still
+// runs.
+func pushTouchesWatchedPaths(changed []string, specs []config.RepoSpec) bool {
+	if changed == nil {
+		return true
+	}
+
+	var subdirs []string
+	for _, spec := range specs {
+		for _, sourceDir := range spec.SourceDirs() {
+			dir := normalizeSubdir(sourceDir)
+			if dir == "" {
+				return true
+			}
+			subdirs = append(subdirs, dir)
+		}
+	}
+
+	for _, f := range changed {
+		for _, dir := range subdirs {
+			if f == dir || strings.HasPrefix(f, dir+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeSubdir converts a configured subdir into the slash-separated,
+// root-relative form git hosts use for changed paths. The repository root
+// yields "".
+func normalizeSubdir(subdir string) string {
+	dir := path.Clean("/" + strings.ReplaceAll(subdir, "\\", "/"))
+	return strings.TrimPrefix(dir, "/")
+}
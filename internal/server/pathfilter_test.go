@@ -0,0 +1,92 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+)
+
+func TestPushTouchesWatchedPaths(t *testing.T) {
+	sub := func(dirs ...string) []config.RepoSpec {
+		specs := make([]config.RepoSpec, len(dirs))
+		for i, d := range dirs {
+			specs[i] = config.RepoSpec{Subdir: d}
+		}
+		return specs
+	}
+
+	tests := []struct {
+		name    string
+		changed []string
+		specs   []config.RepoSpec
+		want    bool
+	}{
+		{
+			name:    "change under subdir",
+			changed: []string{"deploy/host1/web.container"},
+			specs:   sub("deploy/host1"),
+			want:    true,
+		},
+		{
+			name:    "change outside subdir",
+			changed: []string{"services/api/main.go", "README.md"},
+			specs:   sub("deploy/host1"),
+			want:    false,
+		},
+		{
+			name:    "sibling with common prefix does not match",
+			changed: []string{"deploy/host10/web.container"},
+			specs:   sub("deploy/host1"),
+			want:    false,
+		},
+		{
+			name:    "removed file counts",
+			changed: []string{"docs/a.md", "deploy/old.volume"},
+			specs:   sub("./deploy/"),
+			want:    true,
+		},
+		{
+			name:    "any matching repo syncing from its root",
+			changed: []string{"services/api/main.go"},
+			specs:   sub("deploy", ""),
+			want:    true,
+		},
+		{
+			name:    "second subdir matches",
+			changed: []string{"edge/caddy.container"},
+			specs:   sub("deploy", "edge"),
+			want:    true,
+		},
+		{
+			name:    "change in a later layer",
+			changed: []string{"hosts/web1/web.env"},
+			specs:   []config.RepoSpec{{Layers: []string{"base", "hosts/web1"}}},
+			want:    true,
+		},
+		{
+			name:    "change in another host's layer",
+			changed: []string{"hosts/web2/web.env"},
+			specs:   []config.RepoSpec{{Layers: []string{"base", "hosts/web1"}}},
+			want:    false,
+		},
+		{
+			name:  "unknown changes",
+			specs: sub("deploy"),
+			want:  true,
+		},
+		{
+			name:    "empty push",
+			changed: []string{},
+			specs:   sub("deploy"),
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pushTouchesWatchedPaths(tt.changed, tt.specs); got != tt.want {
+				t.Errorf("pushTouchesWatchedPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/server/dto"
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+)
+
+// pauseRequest is the optional JSON body of POST /-/pause.
+type pauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handlePause serves POST /-/pause: it pauses syncs like `quadsyncd pause`,
+// so syncs from every trigger do nothing until resumed. A sync already
+// running finishes.
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req pauseRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4*1024)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	pause, err := quadsyncd.SetPause(s.config().Paths.StateDir, req.Reason, time.Now())
+	if err != nil {
+		s.logger.Error("failed to pause syncs", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to pause syncs")
+		return
+	}
+	s.logger.Info("syncs paused", "reason", pause.Reason)
+	writeJSON(w, http.StatusOK, pauseResponse(pause))
+}
+
+// handleResume serves POST /-/resume, lifting a pause.
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	resumed, err := quadsyncd.ClearPause(s.config().Paths.StateDir)
+	if err != nil {
+		s.logger.Error("failed to resume syncs", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to resume syncs")
+		return
+	}
+	if resumed {
+		s.logger.Info("syncs resumed")
+	}
+	writeJSON(w, http.StatusOK, dto.ResumeResponse{Resumed: resumed})
+}
+
+// readPause returns the current pause for the overview and status
+// endpoints, or nil when syncs are not paused or the marker is unreadable.
+func (s *Server) readPause() *dto.PauseResponse {
+	pause, err := quadsyncd.ReadPause(s.config().Paths.StateDir)
+	if err != nil {
+		s.logger.Warn("failed to read pause file", "error", err)
+		return nil
+	}
+	if pause == nil {
+		return nil
+	}
+	return pauseResponse(pause)
+}
+
+func pauseResponse(p *quadsyncd.Pause) *dto.PauseResponse {
+	return &dto.PauseResponse{Since: p.Since.Format(time.RFC3339), Reason: p.Reason}
+}
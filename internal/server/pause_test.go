@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/server/dto"
+)
+
+func TestHandlePauseResume(t *testing.T) {
+	s, _ := newReloadTestServer(t)
+
+	post := func(handler http.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+	overview := func() dto.OverviewResponse {
+		rec := httptest.NewRecorder()
+		s.handleOverview(rec, httptest.NewRequest(http.MethodGet, "/api/overview", nil))
+		var resp dto.OverviewResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode overview: %v", err)
+		}
+		return resp
+	}
+
+	rec := httptest.NewRecorder()
+	s.handlePause(rec, httptest.NewRequest(http.MethodGet, "/-/pause", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /-/pause status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if rec := post(s.handlePause, "/-/pause", "{"); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed body status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = post(s.handlePause, "/-/pause", `{"reason": "incident"}`)
+	var pause dto.PauseResponse
+	if err := json.NewDecoder(rec.Body).Decode(&pause); err != nil || rec.Code != http.StatusOK || pause.Reason != "incident" || pause.Since == "" {
+		t.Fatalf("pause = %d %+v, %v", rec.Code, pause, err)
+	}
+	if got := overview().Pause; got == nil || got.Reason != "incident" {
+		t.Errorf("overview pause = %+v, want the pause", got)
+	}
+
+	// Pausing without a body keeps the start time and clears the reason.
+	rec = post(s.handlePause, "/-/pause", "")
+	var again dto.PauseResponse
+	if err := json.NewDecoder(rec.Body).Decode(&again); err != nil || again.Since != pause.Since || again.Reason != "" {
+		t.Errorf("pause again = %+v, %v; want since %s without reason", again, err, pause.Since)
+	}
+
+	for _, want := range []bool{true, false} {
+		rec = post(s.handleResume, "/-/resume", "")
+		var resume dto.ResumeResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resume); err != nil || resume.Resumed != want {
+			t.Errorf("resume = %+v, %v; want resumed %v", resume, err, want)
+		}
+	}
+	if got := overview().Pause; got != nil {
+		t.Errorf("overview pause = %+v after resume, want none", got)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"math"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// rateLimiterSweepInterval is how often idle client buckets are discarded.
+const rateLimiterSweepInterval = time.Minute
+
+// rateLimiter is a per-client token bucket limiter.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[netip.Addr]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// tokenBucket holds the tokens left for one client.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing perMinute requests per client
+// with the given burst, or nil when perMinute is zero (limiting disabled).
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[netip.Addr]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token for addr. When none is left it returns false and how
+// long the client should wait before retrying.
+func (l *rateLimiter) allow(addr netip.Addr) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[addr]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[addr] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled completely, since they are
+// indistinguishable from a new client. Must be called with l.mu held.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for addr, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, addr)
+		}
+	}
+}
+
+// inFlightLimiter caps the number of concurrently processed requests.
+type inFlightLimiter chan struct{}
+
+// newInFlightLimiter returns a limiter with n slots, or nil when n is zero.
+func newInFlightLimiter(n int) inFlightLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(inFlightLimiter, n)
+}
+
+// tryAcquire takes a slot without blocking. A nil limiter always succeeds.
+func (l inFlightLimiter) tryAcquire() bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release returns a slot taken by tryAcquire.
+func (l inFlightLimiter) release() {
+	if l != nil {
+		<-l
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/runstore"
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestRateLimiter(t *testing.T) {
+	if newRateLimiter(0, 5) != nil {
+		t.Fatal("expected nil limiter when requests_per_minute is 0")
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(60, 2) // one token per second, burst of two
+	l.now = func() time.Time { return now }
+
+	a := netip.MustParseAddr("192.0.2.1")
+	b := netip.MustParseAddr("192.0.2.2")
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow(a); !ok {
+			t.Fatalf("request %d within burst rejected", i+1)
+		}
+	}
+	ok, wait := l.allow(a)
+	if ok {
+		t.Fatal("request beyond burst allowed")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("retry after = %v, want (0, 1s]", wait)
+	}
+
+	// Other clients have their own bucket.
+	if ok, _ := l.allow(b); !ok {
+		t.Error("second client throttled by first client's usage")
+	}
+
+	// Tokens refill over time.
+	now = now.Add(time.Second)
+	if ok, _ := l.allow(a); !ok {
+		t.Error("request after refill rejected")
+	}
+
+	// Idle buckets are swept once full again.
+	now = now.Add(2 * rateLimiterSweepInterval)
+	l.allow(b)
+	if _, exists := l.buckets[a]; exists {
+		t.Error("idle bucket was not swept")
+	}
+}
+
+func TestRateLimiter_BurstDefaultsToRate(t *testing.T) {
+	l := newRateLimiter(3, 0)
+	l.now = func() time.Time { return time.Unix(0, 0) }
+	addr := netip.MustParseAddr("192.0.2.1")
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow(addr); !ok {
+			t.Fatalf("request %d rejected", i+1)
+		}
+	}
+	if ok, _ := l.allow(addr); ok {
+		t.Error("fourth request allowed with burst defaulting to 3")
+	}
+}
+
+func TestInFlightLimiter(t *testing.T) {
+	var unlimited inFlightLimiter
+	if !unlimited.tryAcquire() {
+		t.Error("nil limiter must always acquire")
+	}
+	unlimited.release()
+
+	l := newInFlightLimiter(1)
+	if !l.tryAcquire() {
+		t.Fatal("first acquire failed")
+	}
+	if l.tryAcquire() {
+		t.Fatal("second acquire succeeded with one slot")
+	}
+	l.release()
+	if !l.tryAcquire() {
+		t.Error("acquire after release failed")
+	}
+}
+
+func TestHandleWebhook_RateLimited(t *testing.T) {
+	cfg, secret := setupTestConfig(t)
+	cfg.Serve.RateLimit.RequestsPerMinute = 1
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	defer srv.debounce.stop(0)
+
+	send := func() *httptest.ResponseRecorder {
+		body := []byte(`{"zen":"hi"}`)
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "ping")
+		req.Header.Set("X-Hub-Signature-256", computeSignature(body, secret))
+		rec := httptest.NewRecorder()
+		srv.handleWebhook(rec, req)
+		return rec
+	}
+
+	if rec := send(); rec.Code != http.StatusOK {
+		t.Fatalf("first request: expected 200, got %d", rec.Code)
+	}
+	rec := send()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header on 429")
+	}
+}
+
+func TestHandleWebhook_MaxInFlight(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	cfg.Serve.MaxInFlight = 1
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+
+	// Occupy the only slot as a slow request would.
+	if !srv.inFlight.tryAcquire() {
+		t.Fatal("failed to occupy in-flight slot")
+	}
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.handleWebhook(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 while slot is taken, got %d", rec.Code)
+	}
+
+	srv.inFlight.release()
+	rec = httptest.NewRecorder()
+	srv.handleWebhook(rec, httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(`{}`))))
+	if rec.Code == http.StatusTooManyRequests {
+		t.Error("request rejected after slot was released")
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/logging"
+)
+
+// ReloadResult reports which configuration fields a reload changed. Fields
+// are named by their YAML path, e.g. "serve.debounce".
+type ReloadResult struct {
+	// Applied lists the changed fields that are in effect for the next sync
+	// or webhook delivery.
+	Applied []string `json:"applied"`
+	// RestartRequired lists the changed fields that keep their old value
+	// until the daemon is restarted.
+	RestartRequired []string `json:"restart_required"`
+}
+
+// SetConfigLoader sets the function Reload uses to read the configuration.
+// It must return a validated configuration with defaults applied.
+func (s *Server) SetConfigLoader(load func() (*config.Config, error)) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.loadConfig = load
+}
+
+// Reload re-reads the configuration and applies the settings that can change
+// while serving: the whole sync section and the webhook filters, debounce and
+// sync timeout of the serve section. Other changes are reported in
+// RestartRequired and left as they were. An invalid configuration is rejected
+// and the running one kept.
+func (s *Server) Reload() (*ReloadResult, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if s.loadConfig == nil {
+		return nil, fmt.Errorf("configuration reload is not available")
+	}
+	next, err := s.loadConfig()
+	if err != nil {
+		s.logger.Error("configuration reload failed, keeping the running configuration",
+			logging.Event(logging.EventConfigReloadFailed), logging.KeyError, err)
+		return nil, fmt.Errorf("failed to reload configuration: %w", err)
+	}
+
+	current := s.config()
+	merged := *current
+	merged.Sync = next.Sync
+	merged.Serve.AllowedRefs = next.Serve.AllowedRefs
+	merged.Serve.AllowedEventTypes = next.Serve.AllowedEventTypes
+	merged.Serve.RequireRepoMatch = next.Serve.RequireRepoMatch
+	merged.Serve.MaxPayloadAge = next.Serve.MaxPayloadAge
+	merged.Serve.Debounce = next.Serve.Debounce
+	merged.Serve.DebounceMaxWait = next.Serve.DebounceMaxWait
+	merged.Serve.SyncTimeout = next.Serve.SyncTimeout
+
+	result := &ReloadResult{
+		Applied:         changedFields("", reflect.ValueOf(*current), reflect.ValueOf(merged)),
+		RestartRequired: changedFields("", reflect.ValueOf(merged), reflect.ValueOf(*next)),
+	}
+
+	s.cfgMu.Lock()
+	s.cfg = &merged
+	s.cfgMu.Unlock()
+	s.syncSvc.SetConfig(&merged)
+	s.planSvc.SetConfig(&merged)
+	s.debounce.setDelays(merged.Serve.Debounce, merged.Serve.DebounceMaxWait)
+
+	s.logger.Info("configuration reloaded", logging.Event(logging.EventConfigReloaded),
+		"applied", result.Applied)
+	if len(result.RestartRequired) > 0 {
+		s.logger.Warn("some configuration changes take effect only after a restart",
+			logging.Event(logging.EventConfigReloaded), "restart_required", result.RestartRequired)
+	}
+	return result, nil
+}
+
+// changedFields returns the YAML paths of the fields that differ between a
+// and b, descending into nested structs. Fields without a YAML name are
+// skipped. The result is never nil so it encodes as an empty JSON list.
+func changedFields(prefix string, a, b reflect.Value) []string {
+	changed := []string{}
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fa, fb := a.Field(i), b.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			changed = append(changed, changedFields(prefix+name+".", fa, fb)...)
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			changed = append(changed, prefix+name)
+		}
+	}
+	return changed
+}
+
+// handleReload serves POST /-/reload, which reloads the configuration like
+// SIGHUP does and reports the outcome.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	result, err := s.Reload()
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/runstore"
+	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func newReloadTestServer(t *testing.T) (*Server, *config.Config) {
+	t.Helper()
+	cfg, _ := setupTestConfig(t)
+	logger := testutil.TestLogger()
+	store := runstore.NewStore(cfg.Paths.StateDir, logger)
+	mockGit := &testutil.MockGitClient{}
+	mockSys := &testutil.MockSystemd{Available: true}
+
+	s, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(mockGit), mockSys), mockSys, store, logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	return s, cfg
+}
+
+func TestReload(t *testing.T) {
+	s, cfg := newReloadTestServer(t)
+
+	next := *cfg
+	next.Sync.Restart = config.RestartNone
+	next.Serve.AllowedRefs = []string{"refs/heads/release"}
+	next.Serve.Debounce = 5 * time.Second
+	next.Serve.ListenAddr = "127.0.0.1:9999"
+	next.Paths.StateDir = "/elsewhere"
+	s.SetConfigLoader(func() (*config.Config, error) { return &next, nil })
+
+	result, err := s.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	wantApplied := []string{"sync.restart", "serve.allowed_refs", "serve.debounce"}
+	if !reflect.DeepEqual(result.Applied, wantApplied) {
+		t.Errorf("Applied = %v, want %v", result.Applied, wantApplied)
+	}
+	wantRestart := []string{"paths.state_dir", "serve.listen_addr"}
+	if !reflect.DeepEqual(result.RestartRequired, wantRestart) {
+		t.Errorf("RestartRequired = %v, want %v", result.RestartRequired, wantRestart)
+	}
+
+	got := s.config()
+	if got.Sync.Restart != config.RestartNone || got.Serve.Debounce != 5*time.Second {
+		t.Errorf("reloaded config = %+v, want the new sync and debounce settings", got)
+	}
+	if got.Serve.ListenAddr != cfg.Serve.ListenAddr || got.Paths.StateDir != cfg.Paths.StateDir {
+		t.Errorf("listen_addr/state_dir = %q/%q, want the running values kept", got.Serve.ListenAddr, got.Paths.StateDir)
+	}
+	if s.debounce.delay != 5*time.Second {
+		t.Errorf("debounce delay = %s, want 5s", s.debounce.delay)
+	}
+}
+
+func TestReload_InvalidConfigKeepsRunning(t *testing.T) {
+	s, cfg := newReloadTestServer(t)
+	s.SetConfigLoader(func() (*config.Config, error) { return nil, errors.New("invalid restart policy") })
+
+	if _, err := s.Reload(); err == nil {
+		t.Fatal("Reload() error = nil, want the loader error")
+	}
+	if s.config() != cfg {
+		t.Error("config was replaced after a failed reload")
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	s, cfg := newReloadTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		loader     func() (*config.Config, error)
+		wantStatus int
+	}{
+		{"get not allowed", http.MethodGet, nil, http.StatusMethodNotAllowed},
+		{"no loader", http.MethodPost, nil, http.StatusUnprocessableEntity},
+		{"invalid config", http.MethodPost, func() (*config.Config, error) { return nil, errors.New("bad") }, http.StatusUnprocessableEntity},
+		{"reloaded", http.MethodPost, func() (*config.Config, error) { return cfg, nil }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.SetConfigLoader(tt.loader)
+			rec := httptest.NewRecorder()
+			s.handleReload(rec, httptest.NewRequest(tt.method, "/-/reload", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var result ReloadResult
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(result.Applied) != 0 || len(result.RestartRequired) != 0 {
+				t.Errorf("result = %+v, want no changes for an identical config", result)
+			}
+		})
+	}
+}
+
+func TestHandleReload_RequiresAPIToken(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	tokenFile := filepath.Join(t.TempDir(), "api-token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatalf("WriteFile token: %v", err)
+	}
+	cfg.Serve.APITokenFile = tokenFile
+
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	s, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	defer s.debounce.stop(0)
+	s.SetConfigLoader(func() (*config.Config, error) { return cfg, nil })
+	handler, _ := s.routes()
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"valid token", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/-/reload", nil)
+			req.Header.Set("X-CSRF-Token", "reload")
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "reload"})
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// deliveryEntry is one remembered webhook delivery.
+type deliveryEntry struct {
+	ID     string    `json:"id,omitempty"`
+	SHA256 string    `json:"sha256"`
+	At     time.Time `json:"at"`
+}
+
+// deliveryFile is the on-disk form of a deliveryLog.
+type deliveryFile struct {
+	Deliveries []deliveryEntry `json:"deliveries"`
+}
+
+// deliveryLog remembers the most recent webhook deliveries by their
+// X-GitHub-Delivery ID and by the digest of their body, so a captured
+// request cannot be replayed. The signature only covers the body, so the
+// digest also catches a replay under a made-up delivery ID. The log is
+// persisted after every delivery; a restart does not forget it.
+type deliveryLog struct {
+	mu      sync.Mutex
+	path    string
+	max     int
+	entries []deliveryEntry
+	seen    map[string]bool // delivery IDs and body digests of entries
+}
+
+// loadDeliveryLog reads the log at path, keeping at most max deliveries. A
+// missing file yields an empty log. A log that cannot be read is returned
+// empty along with the error, so the caller may carry on without history.
+func loadDeliveryLog(path string, max int) (*deliveryLog, error) {
+	l := &deliveryLog{path: path, max: max, seen: make(map[string]bool)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return l, fmt.Errorf("failed to read webhook delivery log: %w", err)
+	}
+	var f deliveryFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return l, fmt.Errorf("failed to parse webhook delivery log %s: %w", path, err)
+	}
+	for _, e := range f.Deliveries {
+		l.add(e)
+	}
+	return l, nil
+}
+
+// keys returns the keys e is looked up by.
+func (e deliveryEntry) keys() []string {
+	keys := []string{"sha256:" + e.SHA256}
+	if e.ID != "" {
+		keys = append(keys, "id:"+e.ID)
+	}
+	return keys
+}
+
+// add appends e, evicting the oldest deliveries beyond max.
+func (l *deliveryLog) add(e deliveryEntry) {
+	l.entries = append(l.entries, e)
+	for _, key := range e.keys() {
+		l.seen[key] = true
+	}
+	for len(l.entries) > l.max {
+		for _, key := range l.entries[0].keys() {
+			delete(l.seen, key)
+		}
+		l.entries = l.entries[1:]
+	}
+}
+
+// record reports whether a delivery with id or body was seen before, and
+// otherwise remembers it. An empty id only checks the body. The error
+// reports a failure to persist the log; the delivery is remembered in memory
+// regardless.
+func (l *deliveryLog) record(id string, body []byte, now time.Time) (bool, error) {
+	sum := sha256.Sum256(body)
+	e := deliveryEntry{ID: id, SHA256: hex.EncodeToString(sum[:]), At: now.UTC()}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range e.keys() {
+		if l.seen[key] {
+			return true, nil
+		}
+	}
+	l.add(e)
+	return false, l.save()
+}
+
+// forget removes the delivery with id and body that record remembered, so a
+// redelivery of one that was not processed after all is accepted.
+func (l *deliveryLog) forget(id string, body []byte) error {
+	sum := sha256.Sum256(body)
+	digest := hex.EncodeToString(sum[:])
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, e := range l.entries {
+		if e.ID != id || e.SHA256 != digest {
+			continue
+		}
+		for _, key := range e.keys() {
+			delete(l.seen, key)
+		}
+		l.entries = append(l.entries[:i:i], l.entries[i+1:]...)
+		return l.save()
+	}
+	return nil
+}
+
+// save writes the log atomically. l.mu must be held.
+func (l *deliveryLog) save() error {
+	data, err := json.Marshal(deliveryFile{Deliveries: l.entries})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook delivery log: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write webhook delivery log: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write webhook delivery log: %w", err)
+	}
+	return nil
+}
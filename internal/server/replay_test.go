@@ -0,0 +1,94 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeliveryLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "webhook-deliveries.json")
+	now := time.Now()
+	l, err := loadDeliveryLog(path, 2)
+	if err != nil {
+		t.Fatalf("loadDeliveryLog() error = %v", err)
+	}
+
+	steps := []struct {
+		id      string
+		body    string
+		wantDup bool
+	}{
+		{id: "a", body: "1", wantDup: false},
+		{id: "a", body: "1", wantDup: true},  // verbatim replay
+		{id: "x", body: "1", wantDup: true},  // same body under a new ID
+		{id: "a", body: "2", wantDup: true},  // same ID, other body
+		{id: "", body: "2", wantDup: false},  // no ID, new body
+		{id: "", body: "2", wantDup: true},   // no ID, same body
+		{id: "b", body: "3", wantDup: false}, // evicts delivery a
+		{id: "a", body: "1", wantDup: false}, // forgotten
+	}
+	for i, st := range steps {
+		dup, err := l.record(st.id, []byte(st.body), now)
+		if err != nil {
+			t.Fatalf("step %d: record() error = %v", i, err)
+		}
+		if dup != st.wantDup {
+			t.Errorf("step %d: record(%q, %q) duplicate = %v, want %v", i, st.id, st.body, dup, st.wantDup)
+		}
+	}
+
+	reloaded, err := loadDeliveryLog(path, 2)
+	if err != nil {
+		t.Fatalf("reload error = %v", err)
+	}
+	if dup, _ := reloaded.record("b", []byte("other"), now); !dup {
+		t.Error("delivery b forgotten after reload")
+	}
+	if dup, _ := reloaded.record("c", []byte("2"), now); dup {
+		t.Error("evicted body still remembered after reload")
+	}
+}
+
+func TestDeliveryLog_Forget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook-deliveries.json")
+	now := time.Now()
+	l, err := loadDeliveryLog(path, 10)
+	if err != nil {
+		t.Fatalf("loadDeliveryLog() error = %v", err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if _, err := l.record(id, []byte(id), now); err != nil {
+			t.Fatalf("record(%q) error = %v", id, err)
+		}
+	}
+
+	if err := l.forget("a", []byte("a")); err != nil {
+		t.Fatalf("forget() error = %v", err)
+	}
+	reloaded, err := loadDeliveryLog(path, 10)
+	if err != nil {
+		t.Fatalf("reload error = %v", err)
+	}
+	if dup, _ := reloaded.record("a", []byte("a"), now); dup {
+		t.Error("forgotten delivery a still remembered")
+	}
+	if dup, _ := reloaded.record("b", []byte("b"), now); !dup {
+		t.Error("delivery b forgotten together with a")
+	}
+}
+
+func TestLoadDeliveryLog_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook-deliveries.json")
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := loadDeliveryLog(path, 10)
+	if err == nil {
+		t.Fatal("loadDeliveryLog() error = nil for a corrupt file")
+	}
+	if dup, err := l.record("a", []byte("1"), time.Now()); dup || err != nil {
+		t.Errorf("record() on the fallback log = %v, %v; want a usable empty log", dup, err)
+	}
+}
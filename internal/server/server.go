@@ -3,6 +3,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -11,29 +12,42 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/imagewatch"
+	"github.com/schaermu/quadsyncd/internal/logging"
 	"github.com/schaermu/quadsyncd/internal/runstore"
 	"github.com/schaermu/quadsyncd/internal/service"
 	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
+	"github.com/schaermu/quadsyncd/internal/systemdproto"
 	"github.com/schaermu/quadsyncd/internal/systemduser"
 	"github.com/schaermu/quadsyncd/internal/webui"
 )
 
 // Server implements the webhook HTTP server and Web UI.
 type Server struct {
+	cfgMu           sync.RWMutex // guards cfg, which Reload replaces
 	cfg             *config.Config
+	reloadMu        sync.Mutex                     // serializes Reload
+	loadConfig      func() (*config.Config, error) // nil disables Reload
 	runnerFactory   quadsyncd.RunnerFactory
 	systemd         systemduser.Systemd
 	logger          *slog.Logger
 	store           runstore.ReadWriter
 	broadcaster     *Broadcaster
-	secret          []byte
+	webhooks        map[string]*webhookListener // by path
+	apiToken        []byte                      // required by /api/ when non-empty
 	syncSvc         *service.SyncService
 	planSvc         *service.PlanService
 	debounce        *debouncer
-	uiHandler       http.Handler // serves embedded SPA assets
+	ipFilter        *ipFilter
+	rateLimiter     *rateLimiter        // nil when serve.rate_limit is disabled
+	inFlight        inFlightLimiter     // nil when serve.max_in_flight is 0
+	deliveries      *deliveryLog        // recent deliveries, for replay protection
+	uiHandler       http.Handler        // serves embedded SPA assets
+	imageWatcher    *imagewatch.Watcher // nil when image_watch is disabled
 	skipInitialSync bool
 }
 
@@ -56,12 +70,27 @@ func NewServer(cfg *config.Config, runnerFactory quadsyncd.RunnerFactory, system
 		return nil, fmt.Errorf("runner factory cannot be nil")
 	}
 
-	// Load webhook secret from file; trim surrounding whitespace/newlines.
-	secretData, err := os.ReadFile(cfg.Serve.GitHubWebhookSecretFile)
+	webhooks, secrets, err := newWebhookListeners(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read webhook secret: %w", err)
+		return nil, err
+	}
+
+	var apiToken []byte
+	if cfg.Serve.APITokenFile != "" {
+		tokenData, err := os.ReadFile(cfg.Serve.APITokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API token: %w", err)
+		}
+		apiToken = []byte(strings.TrimSpace(string(tokenData)))
+		if len(apiToken) == 0 {
+			return nil, fmt.Errorf("API token file %s is empty", cfg.Serve.APITokenFile)
+		}
+	}
+
+	filter, err := newIPFilter(cfg.Serve)
+	if err != nil {
+		return nil, err
 	}
-	secret := []byte(strings.TrimSpace(string(secretData)))
 
 	s := &Server{
 		cfg:           cfg,
@@ -69,12 +98,17 @@ func NewServer(cfg *config.Config, runnerFactory quadsyncd.RunnerFactory, system
 		systemd:       systemd,
 		logger:        logger,
 		store:         store,
-		secret:        secret,
+		webhooks:      webhooks,
+		apiToken:      apiToken,
+		ipFilter:      filter,
+		rateLimiter:   newRateLimiter(cfg.Serve.RateLimit.RequestsPerMinute, cfg.Serve.RateLimit.Burst),
+		inFlight:      newInFlightLimiter(cfg.Serve.MaxInFlight),
 	}
 
 	// Initialise service layer.
-	s.syncSvc = service.NewSyncService(cfg, runnerFactory, store, logger, secret)
-	s.planSvc = service.NewPlanService(cfg, runnerFactory, store, logger, secret)
+	s.syncSvc = service.NewSyncService(cfg, runnerFactory, store, logger, secrets...)
+	s.planSvc = service.NewPlanService(cfg, runnerFactory, store, logger, secrets...)
+	s.syncSvc.SetOnComplete(s.notifySyncStatus)
 
 	// Initialise the SSE broadcaster watching the runs directory.
 	runsDir := filepath.Join(cfg.Paths.StateDir, "runs")
@@ -87,12 +121,33 @@ func NewServer(cfg *config.Config, runnerFactory quadsyncd.RunnerFactory, system
 	}
 	s.uiHandler = http.FileServer(http.FS(uiFS))
 
-	// Initialise the webhook debouncer with a 2-second delay.
-	s.debounce = &debouncer{delay: 2 * time.Second}
+	history := cfg.Serve.ReplayHistory
+	if history == 0 {
+		history = config.DefaultReplayHistory
+	}
+	s.deliveries, err = loadDeliveryLog(cfg.DeliveryLogPath(), history)
+	if err != nil {
+		// Starting over only widens the replay window; do not refuse to serve.
+		logger.Warn("starting with an empty webhook delivery log", logging.KeyError, err)
+	}
+
+	// Initialise the webhook debouncer.
+	s.debounce = newDebouncer(cfg.Serve.Debounce, cfg.Serve.DebounceMaxWait)
+
+	if cfg.ImageWatch.Enabled {
+		s.imageWatcher = s.newImageWatcher()
+	}
 
 	return s, nil
 }
 
+// config returns the current configuration.
+func (s *Server) config() *config.Config {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.cfg
+}
+
 // SetSkipInitialSync controls whether the server skips the initial sync on startup.
 func (s *Server) SetSkipInitialSync(skip bool) {
 	s.skipInitialSync = skip
@@ -100,37 +155,160 @@ func (s *Server) SetSkipInitialSync(skip bool) {
 
 // Start binds to the configured address and starts the HTTP server.
 func (s *Server) Start(ctx context.Context) error {
-	listener, err := net.Listen("tcp", s.cfg.Serve.ListenAddr)
+	listener, err := net.Listen("tcp", s.config().Serve.ListenAddr)
 	if err != nil {
-		return fmt.Errorf("failed to bind to %s: %w", s.cfg.Serve.ListenAddr, err)
+		return fmt.Errorf("failed to bind to %s: %w", s.config().Serve.ListenAddr, err)
 	}
-	s.logger.Info("webhook server bound to address", "addr", s.cfg.Serve.ListenAddr)
+	s.logger.Info("webhook server bound to address", "addr", s.config().Serve.ListenAddr, "mode", "bind")
 	return s.StartWithListener(ctx, listener)
 }
 
 // StartWithListener starts the HTTP server using a provided listener (supports
 // systemd socket activation). It performs an initial sync before accepting traffic
-// unless SetSkipInitialSync(true) has been called.
+// unless SetSkipInitialSync(true) has been called. Under a Type=notify unit it
+// reports readiness once serving and sends watchdog keepalives throughout.
 func (s *Server) StartWithListener(ctx context.Context, listener net.Listener) error {
+	// Bind the addresses of serve.listeners before the initial sync, so a
+	// taken port fails the start right away.
+	webhookListeners, err := s.bindWebhookAddrs()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, l := range webhookListeners {
+			_ = l.Close()
+		}
+	}()
+
+	// Keepalives must flow during the initial sync as well.
+	go s.runWatchdog(ctx)
+
+	// Syncs run under a context that outlives a shutdown signal by the grace
+	// period, so a running sync can finish.
+	syncCtx, cancelSyncs := withGrace(ctx, s.config().Serve.ShutdownGrace)
+	defer cancelSyncs()
+	go s.syncSvc.Run(syncCtx)
+	defer s.stopSyncs(cancelSyncs)
+
 	if s.skipInitialSync {
 		s.logger.Info("skipping initial sync (--skip-initial-sync flag set)")
 	} else {
 		s.logger.Info("performing initial sync before starting webhook server")
-		s.syncSvc.TriggerSync(ctx, runstore.TriggerStartup)
+		s.notify(systemdproto.Status("Performing initial sync"))
+		// The sync itself is bounded by syncCtx; wait for it to end.
+		s.syncSvc.TriggerSync(context.WithoutCancel(ctx), runstore.TriggerStartup)
+		if ctx.Err() != nil {
+			s.logger.Info("shutdown requested during initial sync, not starting webhook server", logging.Event(logging.EventServerStopping))
+			return nil
+		}
 	}
 
 	// Start the SSE broadcaster in the background.
 	go s.broadcaster.Run(ctx)
 
+	if s.imageWatcher != nil {
+		go s.runImageWatch(ctx)
+	}
+	if s.config().Serve.WatchSource {
+		go s.runSourceWatch(ctx)
+	}
+
+	handler, webhookHandlers := s.routes()
+	servers := map[net.Listener]*http.Server{listener: newHTTPServer(ctx, handler)}
+	for addr, l := range webhookListeners {
+		servers[l] = newHTTPServer(ctx, webhookHandlers[addr])
+	}
+
+	errCh := make(chan error, len(servers))
+	for l, httpServer := range servers {
+		go func() {
+			s.logger.Info("webhook server starting", logging.Event(logging.EventServerStarted), "addr", l.Addr().String())
+			if err := httpServer.Serve(l); err != nil && err != http.ErrServerClosed {
+				errCh <- err
+			}
+		}()
+	}
+
+	s.notify(systemdproto.StateReady)
+
+	select {
+	case <-ctx.Done():
+		s.logger.Info("shutting down webhook server", logging.Event(logging.EventServerStopping))
+		s.notify(systemdproto.StateStopping)
+		s.stopSyncs(cancelSyncs)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var errs []error
+		for _, httpServer := range servers {
+			errs = append(errs, httpServer.Shutdown(shutdownCtx))
+		}
+		return errors.Join(errs...)
+	case err := <-errCh:
+		return err
+	}
+}
+
+// bindWebhookAddrs binds the listen addresses of serve.listeners that have
+// their own, keyed by address.
+func (s *Server) bindWebhookAddrs() (map[string]net.Listener, error) {
+	bound := make(map[string]net.Listener)
+	for _, l := range s.webhooks {
+		if l.addr == "" || bound[l.addr] != nil {
+			continue
+		}
+		listener, err := net.Listen("tcp", l.addr)
+		if err != nil {
+			for _, b := range bound {
+				_ = b.Close()
+			}
+			return nil, fmt.Errorf("failed to bind to %s: %w", l.addr, err)
+		}
+		s.logger.Info("webhook listener bound to address", "addr", l.addr, "mode", "bind")
+		bound[l.addr] = listener
+	}
+	return bound, nil
+}
+
+// routes returns the handler for serve.listen_addr and, for every listen
+// address of serve.listeners, a handler serving only the webhook paths
+// bound to it.
+func (s *Server) routes() (http.Handler, map[string]http.Handler) {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/webhook", s.handleWebhook)
+	webhookMuxes := make(map[string]*http.ServeMux)
+	var webhookPaths []string
+	for path, l := range s.webhooks {
+		if l.addr != "" {
+			if webhookMuxes[l.addr] == nil {
+				webhookMuxes[l.addr] = http.NewServeMux()
+			}
+			webhookMuxes[l.addr].HandleFunc(path, s.handleWebhook)
+			continue
+		}
+		mux.HandleFunc(path, s.handleWebhook)
+		webhookPaths = append(webhookPaths, path)
+	}
+	mux.HandleFunc("/-/reload", s.requireAPIToken(s.handleReload))
+	mux.HandleFunc("/-/pause", s.requireAPIToken(s.handlePause))
+	mux.HandleFunc("/-/resume", s.requireAPIToken(s.handleResume))
 	mux.HandleFunc("/", s.handleRoot)
+	mux.HandleFunc(uiPath, s.handleUI)
 	mux.HandleFunc("/assets/", s.handleAssets)
-	mux.HandleFunc("/api/plan", s.handlePlan)
-	mux.HandleFunc("/api/", s.handleAPI)
+	mux.HandleFunc("/api/session", s.handleSession)
+	mux.HandleFunc("/api/plan", s.requireAPIToken(s.handlePlan))
+	mux.HandleFunc("/api/", s.requireAPIToken(s.handleAPI))
+
+	webhookHandlers := make(map[string]http.Handler, len(webhookMuxes))
+	for addr, m := range webhookMuxes {
+		webhookHandlers[addr] = securityHeadersMiddleware(m)
+	}
+	return securityHeadersMiddleware(csrfMiddleware(mux, webhookPaths...)), webhookHandlers
+}
 
-	httpServer := &http.Server{
-		Handler:           securityHeadersMiddleware(csrfMiddleware(mux)),
+// newHTTPServer returns an HTTP server for handler whose requests inherit
+// ctx.
+func newHTTPServer(ctx context.Context, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
 		ReadTimeout:       10 * time.Second,
 		ReadHeaderTimeout: 5 * time.Second,
 		// WriteTimeout is left at 30 s here; SSE connections clear their own
@@ -144,22 +322,39 @@ func (s *Server) StartWithListener(ctx context.Context, listener net.Listener) e
 		// drain connections without hitting its timeout.
 		BaseContext: func(_ net.Listener) context.Context { return ctx },
 	}
+}
 
-	errCh := make(chan error, 1)
-	go func() {
-		s.logger.Info("webhook server starting", "addr", listener.Addr().String())
-		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
-			errCh <- err
-		}
-	}()
+// stopSyncs rejects further webhooks, drops queued syncs and waits for the
+// running sync. A sync still running after the shutdown grace period is
+// cancelled with cancel. Calling it again is a no-op.
+func (s *Server) stopSyncs(cancel context.CancelFunc) {
+	if dropped := s.debounce.stop(0); dropped > 0 {
+		s.logger.Info("discarded pending webhook triggers on shutdown", "coalesced", dropped)
+	}
+	s.syncSvc.Close()
 
+	timer := time.NewTimer(s.config().Serve.ShutdownGrace)
+	defer timer.Stop()
 	select {
-	case <-ctx.Done():
-		s.logger.Info("shutting down webhook server")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		return httpServer.Shutdown(shutdownCtx)
-	case err := <-errCh:
-		return err
+	case <-s.syncSvc.Done():
+	case <-timer.C:
+		s.logger.Warn("sync still running after the shutdown grace period, cancelling it")
+		cancel()
+		<-s.syncSvc.Done()
+	}
+}
+
+// withGrace returns a context that is cancelled grace after ctx is done, so
+// work started under it can finish after a shutdown signal. The returned
+// cancel func releases the context early.
+func withGrace(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.AfterFunc(grace, cancel)
+		context.AfterFunc(graceCtx, func() { timer.Stop() })
+	})
+	return graceCtx, func() {
+		stop()
+		cancel()
 	}
 }
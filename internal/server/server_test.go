@@ -7,13 +7,16 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -22,6 +25,7 @@ import (
 	"github.com/schaermu/quadsyncd/internal/runstore"
 	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
 	"github.com/schaermu/quadsyncd/internal/testutil"
+	"github.com/schaermu/quadsyncd/internal/webhook"
 )
 
 func setupTestConfig(t *testing.T) (*config.Config, string) {
@@ -86,8 +90,8 @@ func TestNewServer(t *testing.T) {
 		t.Fatal("expected server to be non-nil")
 	}
 
-	if string(server.secret) != "test-secret-key" {
-		t.Errorf("expected secret to be 'test-secret-key', got %q", string(server.secret))
+	if l := server.webhooks["/webhook"]; l == nil || l.name != "github" {
+		t.Errorf("expected a github listener on /webhook, got %+v", l)
 	}
 }
 
@@ -136,66 +140,6 @@ func TestStart_PerformsInitialSync(t *testing.T) {
 	}
 }
 
-func TestVerifySignature(t *testing.T) {
-	cfg, secret := setupTestConfig(t)
-	logger := testutil.TestLogger()
-
-	mockGit := &testutil.MockGitClient{}
-	mockSys := &testutil.MockSystemd{Available: true}
-
-	server, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(mockGit), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
-	if err != nil {
-		t.Fatalf("NewServer() failed: %v", err)
-	}
-
-	tests := []struct {
-		name      string
-		body      []byte
-		signature string
-		want      bool
-	}{
-		{
-			name:      "valid signature",
-			body:      []byte(`{"ref":"refs/heads/main"}`),
-			signature: computeSignature([]byte(`{"ref":"refs/heads/main"}`), secret),
-			want:      true,
-		},
-		{
-			name:      "invalid signature",
-			body:      []byte(`{"ref":"refs/heads/main"}`),
-			signature: "sha256=invalid",
-			want:      false,
-		},
-		{
-			name:      "missing sha256 prefix",
-			body:      []byte(`{"ref":"refs/heads/main"}`),
-			signature: "notsha256",
-			want:      false,
-		},
-		{
-			name:      "empty signature",
-			body:      []byte(`{"ref":"refs/heads/main"}`),
-			signature: "",
-			want:      false,
-		},
-		{
-			name:      "wrong body",
-			body:      []byte(`{"ref":"refs/heads/other"}`),
-			signature: computeSignature([]byte(`{"ref":"refs/heads/main"}`), secret),
-			want:      false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := server.verifySignature(tt.body, tt.signature)
-			if got != tt.want {
-				t.Errorf("verifySignature() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestIsEventTypeAllowed(t *testing.T) {
 	cfg, _ := setupTestConfig(t)
 	logger := testutil.TestLogger()
@@ -399,7 +343,7 @@ func TestHandleWebhook_InvalidSignature(t *testing.T) {
 
 	body := []byte(`{"ref":"refs/heads/main"}`)
 
-	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-GitHub-Event", "push")
 	req.Header.Set("X-Hub-Signature-256", "sha256=invalid")
@@ -426,7 +370,7 @@ func TestHandleWebhook_DisallowedEventType(t *testing.T) {
 
 	body := []byte(`{"ref":"refs/heads/main"}`)
 
-	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-GitHub-Event", "pull_request")
 	req.Header.Set("X-Hub-Signature-256", computeSignature(body, secret))
@@ -482,14 +426,104 @@ func TestHandleWebhook_DisallowedRef(t *testing.T) {
 	}
 }
 
+func TestHandleWebhook_PingAndDeletion(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    string
+		body     string
+		wantBody string
+	}{
+		{
+			name:     "ping",
+			event:    "ping",
+			body:     `{"zen":"Keep it logically awesome.","hook_id":42,"repository":{"full_name":"test/repo"}}`,
+			wantBody: "pong",
+		},
+		{
+			name:     "delete event",
+			event:    "delete",
+			body:     `{"ref":"main","ref_type":"branch","repository":{"full_name":"test/repo"}}`,
+			wantBody: "Ref deletion does not trigger sync",
+		},
+		{
+			name:     "push with zero after sha",
+			event:    "push",
+			body:     `{"ref":"refs/heads/main","after":"0000000000000000000000000000000000000000","repository":{"full_name":"test/repo"}}`,
+			wantBody: "Ref deleted",
+		},
+		{
+			name:     "push with deleted flag",
+			event:    "push",
+			body:     `{"ref":"refs/heads/main","after":"abc123","deleted":true,"repository":{"full_name":"test/repo"}}`,
+			wantBody: "Ref deleted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, secret := setupTestConfig(t)
+			logger := testutil.TestLogger()
+			mockSys := &testutil.MockSystemd{Available: true}
+			srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+			if err != nil {
+				t.Fatalf("NewServer() failed: %v", err)
+			}
+			defer srv.debounce.stop(0)
+
+			body := []byte(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", tt.event)
+			req.Header.Set("X-Hub-Signature-256", computeSignature(body, secret))
+			rec := httptest.NewRecorder()
+
+			srv.handleWebhook(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected 200, got %d", rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("expected body containing %q, got %q", tt.wantBody, rec.Body.String())
+			}
+			if n := srv.debounce.pending(); n != 0 {
+				t.Errorf("expected no sync to be scheduled, got %d pending triggers", n)
+			}
+		})
+	}
+}
+
+func TestHandleWebhook_PingRequiresSignature(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+
+	body := []byte(`{"zen":"hi","hook_id":1}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "ping")
+	req.Header.Set("X-Hub-Signature-256", computeSignature(body, "wrong-secret"))
+	rec := httptest.NewRecorder()
+
+	srv.handleWebhook(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for unsigned ping, got %d", rec.Code)
+	}
+}
+
 func TestDebouncer(t *testing.T) {
 	var callCount int
 	var mu sync.Mutex
-	d := &debouncer{delay: 50 * time.Millisecond}
+	d := newDebouncer(50*time.Millisecond, 0)
+	defer d.stop(0)
 
 	// Trigger multiple times rapidly
 	for i := 0; i < 5; i++ {
-		d.trigger(func() {
+		d.trigger(webhookTrigger{}, func(context.Context, debounceBatch) {
 			mu.Lock()
 			callCount++
 			mu.Unlock()
@@ -538,7 +572,9 @@ func TestStartWithListener(t *testing.T) {
 		_ = listener.Close()
 	}()
 
-	// Cancel the context immediately so StartWithListener returns after the initial sync
+	// Cancel the context immediately so StartWithListener returns after the
+	// initial sync, which the grace period lets run.
+	cfg.Serve.ShutdownGrace = time.Minute
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
@@ -693,6 +729,8 @@ func TestRepoFullNameFromURL(t *testing.T) {
 		{name: "http", url: "http://github.com/org/repo.git", want: "org/repo"},
 		{name: "empty", url: "", want: ""},
 		{name: "no slash after host", url: "https://github.com", want: ""},
+		{name: "trailing slash", url: "https://github.com/org/repo/", want: "org/repo"},
+		{name: "port", url: "ssh://git@git.example.com:2222/org/repo.git", want: "org/repo"},
 	}
 
 	for _, tt := range tests {
@@ -705,15 +743,15 @@ func TestRepoFullNameFromURL(t *testing.T) {
 	}
 }
 
-func TestMatchesConfiguredRepo(t *testing.T) {
+func TestMatchingRepos(t *testing.T) {
 	cfg, _ := setupTestConfig(t)
 	logger := testutil.TestLogger()
 
 	tests := []struct {
 		name  string
 		repos []config.RepoSpec
-		event GitHubPushEvent
-		want  bool
+		event *webhook.Event
+		want  []string // URLs of the matching repositories
 	}{
 		{
 			name: "single repo matching URL and ref",
@@ -721,7 +759,7 @@ func TestMatchesConfiguredRepo(t *testing.T) {
 				{URL: "https://github.com/org/repo.git", Ref: "refs/heads/main"},
 			},
 			event: makeEvent("org/repo", "https://github.com/org/repo.git", "git@github.com:org/repo.git", "refs/heads/main"),
-			want:  true,
+			want:  []string{"https://github.com/org/repo.git"},
 		},
 		{
 			name: "single repo matching URL wrong ref",
@@ -729,7 +767,6 @@ func TestMatchesConfiguredRepo(t *testing.T) {
 				{URL: "https://github.com/org/repo.git", Ref: "refs/heads/main"},
 			},
 			event: makeEvent("org/repo", "https://github.com/org/repo.git", "git@github.com:org/repo.git", "refs/heads/develop"),
-			want:  false,
 		},
 		{
 			name: "single repo wrong URL",
@@ -737,7 +774,6 @@ func TestMatchesConfiguredRepo(t *testing.T) {
 				{URL: "https://github.com/org/other.git", Ref: "refs/heads/main"},
 			},
 			event: makeEvent("org/repo", "https://github.com/org/repo.git", "git@github.com:org/repo.git", "refs/heads/main"),
-			want:  false,
 		},
 		{
 			name: "multi repo first matches",
@@ -746,7 +782,7 @@ func TestMatchesConfiguredRepo(t *testing.T) {
 				{URL: "git@github.com:org/repo2.git", Ref: "refs/heads/stable"},
 			},
 			event: makeEvent("org/repo1", "https://github.com/org/repo1.git", "git@github.com:org/repo1.git", "refs/heads/main"),
-			want:  true,
+			want:  []string{"https://github.com/org/repo1.git"},
 		},
 		{
 			name: "multi repo second matches via SSH",
@@ -755,7 +791,7 @@ func TestMatchesConfiguredRepo(t *testing.T) {
 				{URL: "git@github.com:org/repo2.git", Ref: "refs/heads/stable"},
 			},
 			event: makeEvent("org/repo2", "https://github.com/org/repo2.git", "git@github.com:org/repo2.git", "refs/heads/stable"),
-			want:  true,
+			want:  []string{"git@github.com:org/repo2.git"},
 		},
 		{
 			name: "multi repo correct repo wrong ref",
@@ -764,13 +800,36 @@ func TestMatchesConfiguredRepo(t *testing.T) {
 				{URL: "git@github.com:org/repo2.git", Ref: "refs/heads/stable"},
 			},
 			event: makeEvent("org/repo2", "https://github.com/org/repo2.git", "git@github.com:org/repo2.git", "refs/heads/main"),
-			want:  false,
+		},
+		{
+			name: "name differs in case",
+			repos: []config.RepoSpec{
+				{URL: "https://github.com/Org/Repo.git", Ref: "refs/heads/main"},
+			},
+			event: makeEvent("org/repo", "", "", "refs/heads/main"),
+			want:  []string{"https://github.com/Org/Repo.git"},
+		},
+		{
+			name: "dir source never matches",
+			repos: []config.RepoSpec{
+				{URL: "/org/repo", Source: config.SourceConfig{Type: config.SourceDir}},
+			},
+			event: makeEvent("org/repo", "", "", ""),
+		},
+		{
+			name: "every matching subdirectory",
+			repos: []config.RepoSpec{
+				{URL: "https://github.com/org/repo.git", Ref: "refs/heads/main", Subdir: "web"},
+				{URL: "git@github.com:org/repo.git", Ref: "refs/heads/main", Subdir: "db"},
+				{URL: "https://github.com/org/repo.git", Ref: "refs/heads/stable", Subdir: "cache"},
+			},
+			event: makeEvent("org/repo", "https://github.com/org/repo.git", "git@github.com:org/repo.git", "refs/heads/main"),
+			want:  []string{"https://github.com/org/repo.git", "git@github.com:org/repo.git"},
 		},
 		{
 			name:  "no repos configured",
 			repos: nil,
 			event: makeEvent("org/repo", "https://github.com/org/repo.git", "git@github.com:org/repo.git", "refs/heads/main"),
-			want:  false,
 		},
 	}
 
@@ -787,9 +846,12 @@ func TestMatchesConfiguredRepo(t *testing.T) {
 				t.Fatalf("NewServer() failed: %v", err)
 			}
 
-			got := server.matchesConfiguredRepo(tt.event)
-			if got != tt.want {
-				t.Errorf("matchesConfiguredRepo() = %v, want %v", got, tt.want)
+			var got []string
+			for _, spec := range server.matchingRepos(tt.event) {
+				got = append(got, spec.URL)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchingRepos() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -840,6 +902,88 @@ func TestHandleWebhook_UnconfiguredRepo(t *testing.T) {
 	}
 }
 
+func TestHandleWebhook_RequireRepoMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		fullName string
+		ref      string
+		wantCode int
+		wantBody string
+	}{
+		{name: "other repository", fullName: "org/repo", ref: "refs/heads/main", wantCode: http.StatusForbidden, wantBody: "Repository not configured"},
+		{name: "missing repository", fullName: "", ref: "refs/heads/main", wantCode: http.StatusForbidden, wantBody: "Repository not configured"},
+		{name: "configured repository, other ref", fullName: "org/other", ref: "refs/heads/dev", wantCode: http.StatusOK, wantBody: "Repository/ref not configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, secret := setupTestConfig(t)
+			cfg.Repository = &config.RepoSpec{URL: "https://github.com/org/other.git", Ref: "refs/heads/main"}
+			cfg.Serve.AllowedRefs = []string{}
+			cfg.Serve.RequireRepoMatch = true
+			logger := testutil.TestLogger()
+			mockSys := &testutil.MockSystemd{Available: true}
+			server, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+			if err != nil {
+				t.Fatalf("NewServer() failed: %v", err)
+			}
+
+			body := []byte(fmt.Sprintf(`{"ref": %q, "after": "abc123", "repository": {"full_name": %q}}`, tt.ref, tt.fullName))
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-Hub-Signature-256", computeSignature(body, secret))
+			rec := httptest.NewRecorder()
+			server.handleWebhook(rec, req)
+
+			if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("response = %d %q, want %d containing %q", rec.Code, rec.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleWebhook_ReplayProtection(t *testing.T) {
+	cfg, secret := setupTestConfig(t)
+	cfg.Serve.MaxPayloadAge = time.Hour
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	server, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	send := func(event, delivery string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-GitHub-Delivery", delivery)
+		req.Header.Set("X-Hub-Signature-256", computeSignature(body, secret))
+		rec := httptest.NewRecorder()
+		server.handleWebhook(rec, req)
+		return rec
+	}
+
+	ping := []byte(`{"zen": "Keep it logically awesome.", "hook_id": 1}`)
+	if rec := send("ping", "d1", ping); rec.Code != http.StatusOK {
+		t.Fatalf("first delivery = %d, want 200", rec.Code)
+	}
+	if rec := send("ping", "d1", ping); rec.Code != http.StatusConflict {
+		t.Errorf("replayed delivery = %d, want 409", rec.Code)
+	}
+	if rec := send("ping", "d2", ping); rec.Code != http.StatusConflict {
+		t.Errorf("replayed body under a new delivery ID = %d, want 409", rec.Code)
+	}
+
+	stale := []byte(fmt.Sprintf(`{"ref": "refs/heads/main", "after": "abc123", "head_commit": {"timestamp": %q}}`,
+		time.Now().Add(-2*time.Hour).Format(time.RFC3339)))
+	if rec := send("push", "d3", stale); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "too old") {
+		t.Errorf("stale push = %d %q, want 403 Payload too old", rec.Code, rec.Body.String())
+	}
+
+	if _, err := os.Stat(cfg.DeliveryLogPath()); err != nil {
+		t.Errorf("delivery log not persisted: %v", err)
+	}
+}
+
 func TestHandleWebhook_MultiRepo_MatchesSecondRepo(t *testing.T) {
 	cfg, secret := setupTestConfig(t)
 	cfg.Repository = nil
@@ -892,18 +1036,256 @@ func TestHandleWebhook_MultiRepo_MatchesSecondRepo(t *testing.T) {
 	}
 }
 
-// makeEvent constructs a GitHubPushEvent for testing.
-func makeEvent(fullName, cloneURL, sshURL, ref string) GitHubPushEvent {
-	var e GitHubPushEvent
-	e.Ref = ref
-	e.After = "abc123"
+func TestHandleWebhook_SkipsPushOutsideSubdir(t *testing.T) {
+	cfg, secret := setupTestConfig(t)
+	cfg.Repository.Subdir = "deploy"
+	logger := testutil.TestLogger()
+
+	mockSys := &testutil.MockSystemd{Available: true}
+	server, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		changed string
+		want    string
+	}{
+		{name: "unrelated change", changed: "services/api/main.go", want: "No watched paths changed"},
+		{name: "watched change", changed: "deploy/web.container", want: "Sync triggered"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{
+				"ref": "refs/heads/main",
+				"after": "abc123",
+				"repository": {"full_name": "test/repo"},
+				"commits": [{"id": "abc123", "modified": ["` + tt.changed + `"]}]
+			}`)
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-Hub-Signature-256", computeSignature(body, secret))
+
+			rec := httptest.NewRecorder()
+			server.handleWebhook(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", rec.Code)
+			}
+			if !bytes.Contains(rec.Body.Bytes(), []byte(tt.want)) {
+				t.Errorf("expected %q, got: %s", tt.want, rec.Body.String())
+			}
+		})
+	}
+	server.debounce.stop(0)
+}
+
+func TestHandleWebhook_Listeners(t *testing.T) {
+	cfg, secret := setupTestConfig(t)
+	tokenPath := filepath.Join(t.TempDir(), "gitlab_token")
+	if err := os.WriteFile(tokenPath, []byte("gitlab-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Repository.URL = "https://gitlab.com/group/repo.git"
+	cfg.Serve.Listeners = []config.WebhookListener{
+		{Path: "/hooks/gitlab", Provider: "gitlab", SecretFile: tokenPath},
+		{Path: "/hooks/ci", Provider: "generic", SecretFile: cfg.Serve.GitHubWebhookSecretFile, Generic: config.GenericWebhook{
+			SignatureHeader: "X-Signature",
+			Ref:             "$.build.branch",
+			Repository:      "$.build.repo",
+		}},
+	}
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	server, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	defer server.debounce.stop(0)
+
+	push := []byte(`{"ref": "refs/heads/main", "after": "abc123", "project": {"path_with_namespace": "group/repo"}}`)
+	ciBuild := []byte(`{"build": {"branch": "main", "repo": "https://gitlab.com/group/repo.git"}}`)
+	tests := []struct {
+		name     string
+		path     string
+		body     []byte // defaults to push
+		headers  map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "gitlab push",
+			path:     "/hooks/gitlab",
+			headers:  map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "gitlab-token"},
+			wantCode: http.StatusOK,
+			wantBody: "Sync triggered",
+		},
+		{
+			name:     "generic build",
+			path:     "/hooks/ci",
+			body:     ciBuild,
+			headers:  map[string]string{"X-Signature": computeSignature(ciBuild, secret)},
+			wantCode: http.StatusOK,
+			wantBody: "Sync triggered",
+		},
+		{
+			name:     "generic build without signature",
+			path:     "/hooks/ci",
+			body:     ciBuild,
+			wantCode: http.StatusForbidden,
+			wantBody: "Invalid signature",
+		},
+		{
+			name:     "github signature on the gitlab path",
+			path:     "/hooks/gitlab",
+			headers:  map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": computeSignature(push, secret)},
+			wantCode: http.StatusForbidden,
+			wantBody: "Invalid signature",
+		},
+		{
+			name:     "gitlab token on the github path",
+			path:     "/webhook",
+			headers:  map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "gitlab-token"},
+			wantCode: http.StatusForbidden,
+			wantBody: "Invalid signature",
+		},
+		{
+			name:     "no listener",
+			path:     "/hooks/bitbucket",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if body == nil {
+				body = push
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			server.handleWebhook(rec, req)
+
+			if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("response = %d %q, want %d containing %q", rec.Code, rec.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRoutes_ListenerAddrs(t *testing.T) {
+	cfg, secret := setupTestConfig(t)
+	repoA := *cfg.Repository
+	repoB := config.RepoSpec{URL: "https://gitea.example.com/org/b.git", Ref: "refs/heads/main"}
+	cfg.Repository = nil
+	cfg.Repositories = []config.RepoSpec{repoA, repoB}
+	cfg.Serve.Listeners = []config.WebhookListener{{
+		Path:       "/hooks/gitea",
+		Provider:   "gitea",
+		SecretFile: cfg.Serve.GitHubWebhookSecretFile,
+		ListenAddr: "127.0.0.1:9797",
+		Repository: repoB.URL,
+	}}
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	server, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	defer server.debounce.stop(0)
+
+	main, webhookHandlers := server.routes()
+	gitea := webhookHandlers["127.0.0.1:9797"]
+	if len(webhookHandlers) != 1 || gitea == nil {
+		t.Fatalf("webhook handlers = %v, want one for 127.0.0.1:9797", webhookHandlers)
+	}
+
+	pushFor := func(repo string) []byte {
+		return []byte(`{"ref": "refs/heads/main", "after": "abc123", "repository": {"full_name": "` + repo + `"}}`)
+	}
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		method   string
+		path     string
+		body     []byte
+		wantCode int
+		wantBody string
+	}{
+		{"bound repository", gitea, http.MethodPost, "/hooks/gitea", pushFor("org/b"), http.StatusOK, "Sync triggered"},
+		{"other repository", gitea, http.MethodPost, "/hooks/gitea", pushFor("test/repo"), http.StatusForbidden, "not bound"},
+		{"listener path on the main address", main, http.MethodPost, "/hooks/gitea", pushFor("org/b"), http.StatusForbidden, "CSRF"},
+		{"github path on the listener address", gitea, http.MethodPost, "/webhook", pushFor("test/repo"), http.StatusNotFound, ""},
+		{"api on the listener address", gitea, http.MethodGet, "/api/overview", nil, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Gitea-Event", "push")
+			req.Header.Set("X-Gitea-Signature", strings.TrimPrefix(computeSignature(tt.body, secret), "sha256="))
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-Hub-Signature-256", computeSignature(tt.body, secret))
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("response = %d %q, want %d containing %q", rec.Code, rec.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNewServer_Listeners(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	secretPath := cfg.Serve.GitHubWebhookSecretFile
+	cfg.Serve.GitHubWebhookSecretFile = ""
+	cfg.Serve.Listeners = []config.WebhookListener{{Path: "/hooks/gitea", Provider: "gitea", SecretFile: secretPath}}
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	newServer := func() (*Server, error) {
+		return NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	}
+
+	server, err := newServer()
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	if len(server.webhooks) != 1 || server.webhooks["/hooks/gitea"] == nil {
+		t.Errorf("webhooks = %v, want only /hooks/gitea", server.webhooks)
+	}
+
+	cfg.Serve.Listeners[0].Provider = "bitbucket"
+	if _, err := newServer(); err == nil || !strings.Contains(err.Error(), "unknown webhook provider") {
+		t.Errorf("NewServer() error = %v, want unknown provider", err)
+	}
+
+	cfg.Serve.Listeners[0].Provider = "generic"
+	cfg.Serve.Listeners[0].Generic = config.GenericWebhook{SignatureHeader: "X-Signature", Ref: "build[", Repository: "$.repo"}
+	if _, err := newServer(); err == nil || !strings.Contains(err.Error(), "invalid ref expression") {
+		t.Errorf("NewServer() error = %v, want invalid ref expression", err)
+	}
+}
+
+// makeEvent constructs a push event for testing.
+func makeEvent(fullName, cloneURL, sshURL, ref string) *webhook.Event {
+	e := &webhook.Event{Kind: webhook.KindPush, Type: "push", Ref: ref, Commit: "abc123"}
 	e.Repository.FullName = fullName
-	e.Repository.CloneURL = cloneURL
-	e.Repository.SSHURL = sshURL
+	for _, u := range []string{cloneURL, sshURL} {
+		if u != "" {
+			e.Repository.URLs = append(e.Repository.URLs, u)
+		}
+	}
 	return e
 }
 
-// TestHandleRoot verifies the root path returns HTML for the Web UI SPA.
+// TestHandleRoot verifies the root path redirects to the Web UI and the UI
+// path serves the SPA.
 func TestHandleRoot(t *testing.T) {
 	cfg, _ := setupTestConfig(t)
 	logger := testutil.TestLogger()
@@ -918,6 +1300,7 @@ func TestHandleRoot(t *testing.T) {
 
 	tests := []struct {
 		name           string
+		handler        http.HandlerFunc
 		method         string
 		path           string
 		expectedStatus int
@@ -925,35 +1308,58 @@ func TestHandleRoot(t *testing.T) {
 		bodyContains   string
 	}{
 		{
-			name:           "GET / returns 200 with HTML",
+			name:           "GET / redirects to the UI",
+			handler:        server.handleRoot,
 			method:         http.MethodGet,
 			path:           "/",
-			expectedStatus: http.StatusOK,
-			checkBody:      true,
-			bodyContains:   "quadsyncd",
+			expectedStatus: http.StatusFound,
 		},
 		{
-			name:           "HEAD / returns 200",
+			name:           "HEAD / redirects to the UI",
+			handler:        server.handleRoot,
 			method:         http.MethodHead,
 			path:           "/",
-			expectedStatus: http.StatusOK,
-			checkBody:      false,
+			expectedStatus: http.StatusFound,
 		},
 		{
 			name:           "POST / returns 405",
+			handler:        server.handleRoot,
 			method:         http.MethodPost,
 			path:           "/",
 			expectedStatus: http.StatusMethodNotAllowed,
-			checkBody:      false,
 		},
 		{
-			name:           "unknown path returns 200 with SPA index",
+			name:           "unknown path returns 404",
+			handler:        server.handleRoot,
 			method:         http.MethodGet,
 			path:           "/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "GET /ui/ returns 200 with HTML",
+			handler:        server.handleUI,
+			method:         http.MethodGet,
+			path:           "/ui/",
+			expectedStatus: http.StatusOK,
+			checkBody:      true,
+			bodyContains:   "quadsyncd",
+		},
+		{
+			name:           "UI subpath returns 200 with SPA index",
+			handler:        server.handleUI,
+			method:         http.MethodGet,
+			path:           "/ui/units",
 			expectedStatus: http.StatusOK,
 			checkBody:      true,
 			bodyContains:   "quadsyncd",
 		},
+		{
+			name:           "POST /ui/ returns 405",
+			handler:        server.handleUI,
+			method:         http.MethodPost,
+			path:           "/ui/",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
 	}
 
 	for _, tt := range tests {
@@ -961,11 +1367,14 @@ func TestHandleRoot(t *testing.T) {
 			req := httptest.NewRequest(tt.method, tt.path, nil)
 			rec := httptest.NewRecorder()
 
-			server.handleRoot(rec, req)
+			tt.handler(rec, req)
 
 			if rec.Code != tt.expectedStatus {
 				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
 			}
+			if tt.expectedStatus == http.StatusFound && rec.Header().Get("Location") != uiPath {
+				t.Errorf("expected redirect to %s, got %q", uiPath, rec.Header().Get("Location"))
+			}
 
 			if tt.checkBody {
 				if !bytes.Contains(rec.Body.Bytes(), []byte(tt.bodyContains)) {
@@ -978,6 +1387,18 @@ func TestHandleRoot(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("disable_ui", func(t *testing.T) {
+		cfg.Serve.DisableUI = true
+		defer func() { cfg.Serve.DisableUI = false }()
+		for _, h := range []http.HandlerFunc{server.handleRoot, server.handleUI, server.handleAssets} {
+			rec := httptest.NewRecorder()
+			h(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("expected 404 with disable_ui, got %d", rec.Code)
+			}
+		}
+	})
 }
 
 // TestHandleAssets verifies the /assets/* path serves embedded static assets.
@@ -1048,16 +1469,16 @@ func TestHandleAPI(t *testing.T) {
 		checkBody      bool
 	}{
 		{
-			name:           "GET /api/status returns 501",
+			name:           "GET /api/config returns 501",
 			method:         http.MethodGet,
-			path:           "/api/status",
+			path:           "/api/config",
 			expectedStatus: http.StatusNotImplemented,
 			checkBody:      true,
 		},
 		{
-			name:           "POST /api/sync returns 501",
+			name:           "POST /api/repos returns 501",
 			method:         http.MethodPost,
-			path:           "/api/sync",
+			path:           "/api/repos",
 			expectedStatus: http.StatusNotImplemented,
 			checkBody:      true,
 		},
@@ -1356,6 +1777,9 @@ func TestHandleOverview(t *testing.T) {
 		if resp.LastRunStatus != string(runstore.RunStatusSuccess) {
 			t.Errorf("expected last_run_status success, got %q", resp.LastRunStatus)
 		}
+		if resp.Sync.Running || resp.Sync.Queued != 0 {
+			t.Errorf("expected an idle sync worker, got %+v", resp.Sync)
+		}
 	})
 
 	t.Run("POST returns 405", func(t *testing.T) {
@@ -1892,7 +2316,7 @@ func TestHandleUnits(t *testing.T) {
 
 		logger := testutil.TestLogger()
 		store := runstore.NewStore(cfg.Paths.StateDir, logger)
-		mockSystemd := &testutil.MockSystemd{Available: true}
+		mockSystemd := &testutil.MockSystemd{Available: true, UnitStatus: map[string]string{"app.service": "failed"}}
 		srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSystemd), mockSystemd, store, logger)
 		if err != nil {
 			t.Fatalf("NewServer: %v", err)
@@ -1912,6 +2336,9 @@ func TestHandleUnits(t *testing.T) {
 		if len(resp.Items) != 1 {
 			t.Fatalf("expected 1 unit, got %d", len(resp.Items))
 		}
+		if resp.Items[0].ActiveState != "failed" {
+			t.Errorf("expected active_state failed, got %q", resp.Items[0].ActiveState)
+		}
 		u := resp.Items[0]
 		if u.Name != "app.service" {
 			t.Errorf("expected unit name app.service, got %q", u.Name)
@@ -2002,9 +2429,72 @@ func TestHandleUnits(t *testing.T) {
 	})
 }
 
-// ---- GET /api/timer ----
+// ---- GET /api/history ----
 
-func TestHandleTimer(t *testing.T) {
+func TestHandleHistory(t *testing.T) {
+	server, _ := setupServerWithRuns(t, nil)
+
+	t.Run("returns empty items when no history", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/history", nil)
+		w := httptest.NewRecorder()
+		server.handleAPI(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		requireJSONContentType(t, w)
+
+		var resp HistoryResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.Items == nil || len(resp.Items) != 0 {
+			t.Errorf("expected empty items, got %v", resp.Items)
+		}
+	})
+
+	t.Run("returns newest entries first up to limit", func(t *testing.T) {
+		start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, commit := range []string{"aaa", "bbb", "ccc"} {
+			entry := quadsyncd.HistoryEntry{
+				StartedAt: start.Add(time.Duration(i) * time.Minute),
+				Commit:    commit,
+				Result:    quadsyncd.HistoryResultSuccess,
+			}
+			if err := quadsyncd.AppendHistory(server.cfg.Paths.StateDir, entry); err != nil {
+				t.Fatalf("AppendHistory: %v", err)
+			}
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/history?limit=2", nil)
+		w := httptest.NewRecorder()
+		server.handleAPI(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp HistoryResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(resp.Items) != 2 || resp.Items[0].Commit != "ccc" || resp.Items[1].Commit != "bbb" {
+			t.Errorf("unexpected items: %+v", resp.Items)
+		}
+	})
+
+	t.Run("POST returns 405", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/history", nil)
+		w := httptest.NewRecorder()
+		server.handleAPI(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected 405, got %d", w.Code)
+		}
+	})
+}
+
+// ---- GET /api/timer ----
+
+func TestHandleTimer(t *testing.T) {
 	server, _ := setupServerWithRuns(t, nil)
 
 	t.Run("returns 200 with timer unit field", func(t *testing.T) {
@@ -2049,13 +2539,14 @@ func TestHandleAPIRouting(t *testing.T) {
 		expectedStatus int
 	}{
 		// Unimplemented paths still return 501
-		{"unknown path", http.MethodGet, "/api/status", http.StatusNotImplemented},
+		{"unknown path", http.MethodGet, "/api/config", http.StatusNotImplemented},
 		{"trailing slash on runs", http.MethodGet, "/api/runs/", http.StatusNotImplemented},
 		{"deep unknown subpath", http.MethodGet, "/api/runs/abc/def/ghi", http.StatusNotImplemented},
 		// Implemented paths with correct method
 		{"overview", http.MethodGet, "/api/overview", http.StatusOK},
 		{"runs list", http.MethodGet, "/api/runs", http.StatusOK},
 		{"units", http.MethodGet, "/api/units", http.StatusOK},
+		{"history", http.MethodGet, "/api/history", http.StatusOK},
 		{"timer", http.MethodGet, "/api/timer", http.StatusOK},
 	}
 
@@ -2367,7 +2858,8 @@ func TestHandleWebhook_BodyTooLarge_SignatureFails(t *testing.T) {
 func TestDebouncer_ConcurrentTriggers(t *testing.T) {
 	var callCount int
 	var mu sync.Mutex
-	d := &debouncer{delay: 80 * time.Millisecond}
+	d := newDebouncer(80*time.Millisecond, 0)
+	defer d.stop(0)
 
 	const goroutines = 20
 	var wg sync.WaitGroup
@@ -2375,7 +2867,7 @@ func TestDebouncer_ConcurrentTriggers(t *testing.T) {
 	for i := 0; i < goroutines; i++ {
 		go func() {
 			defer wg.Done()
-			d.trigger(func() {
+			d.trigger(webhookTrigger{}, func(context.Context, debounceBatch) {
 				mu.Lock()
 				callCount++
 				mu.Unlock()
@@ -2396,6 +2888,180 @@ func TestDebouncer_ConcurrentTriggers(t *testing.T) {
 	}
 }
 
+func TestDebouncer_BatchMetadata(t *testing.T) {
+	d := newDebouncer(50*time.Millisecond, 0)
+	defer d.stop(0)
+
+	got := make(chan debounceBatch, 1)
+	for _, id := range []string{"d-1", "d-2", "d-3"} {
+		d.trigger(webhookTrigger{DeliveryID: id, Commit: "sha-" + id}, func(_ context.Context, b debounceBatch) {
+			got <- b
+		})
+	}
+	if n := d.pending(); n != 3 {
+		t.Errorf("expected 3 pending triggers, got %d", n)
+	}
+
+	select {
+	case b := <-got:
+		if b.Coalesced != 3 {
+			t.Errorf("expected 3 coalesced triggers, got %d", b.Coalesced)
+		}
+		if b.Last.DeliveryID != "d-3" || b.Last.Commit != "sha-d-3" {
+			t.Errorf("expected last trigger d-3, got %+v", b.Last)
+		}
+		if b.Waited < 50*time.Millisecond {
+			t.Errorf("expected wait >= delay, got %v", b.Waited)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("debounced callback never fired")
+	}
+	if n := d.pending(); n != 0 {
+		t.Errorf("expected no pending triggers after firing, got %d", n)
+	}
+}
+
+func TestDebouncer_MaxWait(t *testing.T) {
+	d := newDebouncer(50*time.Millisecond, 120*time.Millisecond)
+	defer d.stop(0)
+
+	got := make(chan debounceBatch, 1)
+	start := time.Now()
+	deadline := start.Add(400 * time.Millisecond)
+	// Trigger faster than the delay for longer than maxWait.
+	for time.Now().Before(deadline) {
+		d.trigger(webhookTrigger{}, func(_ context.Context, b debounceBatch) { got <- b })
+		select {
+		case b := <-got:
+			if b.Waited < 120*time.Millisecond || time.Since(start) > 300*time.Millisecond {
+				t.Errorf("callback fired after %v (batch waited %v), want about maxWait", time.Since(start), b.Waited)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	t.Fatal("continuous triggers never fired the callback")
+}
+
+func TestDebouncer_StopCancelsPending(t *testing.T) {
+	d := newDebouncer(50*time.Millisecond, 0)
+
+	var fired atomic.Bool
+	d.trigger(webhookTrigger{DeliveryID: "a"}, func(context.Context, debounceBatch) { fired.Store(true) })
+	d.trigger(webhookTrigger{DeliveryID: "b"}, func(context.Context, debounceBatch) { fired.Store(true) })
+
+	if dropped := d.stop(0); dropped != 2 {
+		t.Errorf("expected 2 dropped triggers, got %d", dropped)
+	}
+	if d.trigger(webhookTrigger{}, func(context.Context, debounceBatch) { fired.Store(true) }) {
+		t.Error("expected trigger after stop to be rejected")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if fired.Load() {
+		t.Error("callback fired after stop")
+	}
+	if dropped := d.stop(0); dropped != 0 {
+		t.Errorf("expected second stop to be a no-op, got %d", dropped)
+	}
+}
+
+func TestDebouncer_StopCancelsRunningCallback(t *testing.T) {
+	d := newDebouncer(10*time.Millisecond, 0)
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	d.trigger(webhookTrigger{}, func(ctx context.Context, _ debounceBatch) {
+		close(started)
+		<-ctx.Done()
+		done <- ctx.Err()
+	})
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("debounced callback never started")
+	}
+
+	d.stop(0)
+
+	// stop waits for the running callback, so the result must be available.
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	default:
+		t.Fatal("stop returned before the running callback finished")
+	}
+}
+
+func TestDebouncer_StopWaitsForRunningCallback(t *testing.T) {
+	d := newDebouncer(10*time.Millisecond, 0)
+
+	started := make(chan struct{})
+	var cancelled atomic.Bool
+	d.trigger(webhookTrigger{}, func(ctx context.Context, _ debounceBatch) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			cancelled.Store(true)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+	<-started
+
+	d.stop(time.Second)
+	if cancelled.Load() {
+		t.Error("callback was cancelled although it finished within the grace period")
+	}
+}
+
+func TestWithGrace(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := withGrace(parent, 30*time.Millisecond)
+	defer cancel()
+
+	cancelParent()
+	if ctx.Err() != nil {
+		t.Fatal("context was cancelled together with its parent")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("context was not cancelled after the grace period")
+	}
+}
+
+func TestHandleWebhook_RejectedAfterShutdown(t *testing.T) {
+	cfg, secret := setupTestConfig(t)
+	logger := testutil.TestLogger()
+	mockSys := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSys), mockSys, runstore.NewStore(cfg.Paths.StateDir, logger), logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	srv.debounce.stop(0)
+
+	payload := []byte(`{"ref":"refs/heads/main","after":"abc","repository":{"full_name":"test/repo"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-GitHub-Delivery", "shutdown-1")
+	req.Header.Set("X-Hub-Signature-256", computeSignature(payload, secret))
+	rec := httptest.NewRecorder()
+
+	srv.handleWebhook(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after shutdown, got %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	// The redelivery must not be rejected as a replay.
+	if dup, _ := srv.deliveries.record("shutdown-1", payload, time.Now()); dup {
+		t.Error("delivery dropped during shutdown was remembered as processed")
+	}
+}
+
 // ---- API correctness edge cases ----
 
 func TestHandleOverview_SingleRepoCommitFallback(t *testing.T) {
@@ -2442,6 +3108,43 @@ func TestHandleOverview_SingleRepoCommitFallback(t *testing.T) {
 	}
 }
 
+func TestHandleOverview_FreezeAndPendingRestarts(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	if err := os.MkdirAll(cfg.Paths.StateDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	stateContent := `{"managed_files":{},"pending_restarts":["web.service"]}`
+	if err := os.WriteFile(cfg.StateFilePath(), []byte(stateContent), 0644); err != nil {
+		t.Fatalf("WriteFile state: %v", err)
+	}
+	if _, err := quadsyncd.SetFreeze(cfg.Paths.StateDir, "release week", time.Now()); err != nil {
+		t.Fatalf("SetFreeze: %v", err)
+	}
+
+	logger := testutil.TestLogger()
+	store := runstore.NewStore(cfg.Paths.StateDir, logger)
+	mockSystemd := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSystemd), mockSystemd, store, logger)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/overview", nil)
+	w := httptest.NewRecorder()
+	srv.handleAPI(w, req)
+
+	var resp OverviewResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Freeze == nil || resp.Freeze.Reason != "release week" {
+		t.Errorf("freeze = %+v, want reason release week", resp.Freeze)
+	}
+	if len(resp.PendingRestarts) != 1 || resp.PendingRestarts[0] != "web.service" {
+		t.Errorf("pending_restarts = %v, want [web.service]", resp.PendingRestarts)
+	}
+}
+
 func TestHandleUnits_OrderStable(t *testing.T) {
 	// Multiple quadlet files must be returned sorted ascending by Name,
 	// regardless of map iteration order.
@@ -2568,3 +3271,260 @@ func TestHandleRunLogs_LevelCaseInsensitive(t *testing.T) {
 		t.Errorf("unexpected msg %q", msg)
 	}
 }
+
+// ---- GET /api/status and /api/files ----
+
+func TestHandleStatus(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	if err := os.MkdirAll(cfg.Paths.StateDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	stateContent := `{
+"revisions": {"https://github.com/test/repo.git": "deadbeef"},
+"managed_files": {
+"/q/app.container": {"source_path": "app.container", "hash": "abc"},
+"/q/app.env": {"source_path": "app.env", "hash": "def"}
+},
+"pending_restarts": ["app.service"]
+}`
+	if err := os.WriteFile(cfg.StateFilePath(), []byte(stateContent), 0644); err != nil {
+		t.Fatalf("WriteFile state: %v", err)
+	}
+	entry := quadsyncd.HistoryEntry{StartedAt: time.Now(), Commit: "deadbeef", Added: 2, Result: quadsyncd.HistoryResultSuccess}
+	if err := quadsyncd.AppendHistory(cfg.Paths.StateDir, entry); err != nil {
+		t.Fatalf("AppendHistory: %v", err)
+	}
+
+	logger := testutil.TestLogger()
+	store := runstore.NewStore(cfg.Paths.StateDir, logger)
+	mockSystemd := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSystemd), mockSystemd, store, logger)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	t.Run("status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		srv.handleAPI(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		requireJSONContentType(t, w)
+
+		var resp StatusResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.Revisions["https://github.com/test/repo.git"] != "deadbeef" {
+			t.Errorf("revisions = %v", resp.Revisions)
+		}
+		if resp.ManagedFiles != 2 {
+			t.Errorf("managed_files = %d, want 2", resp.ManagedFiles)
+		}
+		if len(resp.PendingRestarts) != 1 {
+			t.Errorf("pending_restarts = %v", resp.PendingRestarts)
+		}
+		if resp.LastSync == nil || resp.LastSync.Commit != "deadbeef" || resp.LastSync.Added != 2 {
+			t.Errorf("last_sync = %+v", resp.LastSync)
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		srv.handleAPI(w, httptest.NewRequest(http.MethodGet, "/api/files", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp FilesResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(resp.Items) != 2 || resp.Items[0].Path != "/q/app.container" || resp.Items[1].Path != "/q/app.env" {
+			t.Errorf("unexpected items: %+v", resp.Items)
+		}
+	})
+
+	t.Run("POST returns 405", func(t *testing.T) {
+		for _, path := range []string{"/api/status", "/api/files"} {
+			w := httptest.NewRecorder()
+			srv.handleAPI(w, httptest.NewRequest(http.MethodPost, path, nil))
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("POST %s: expected 405, got %d", path, w.Code)
+			}
+		}
+	})
+}
+
+func TestRequireAPIToken(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	tokenFile := filepath.Join(t.TempDir(), "api-token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatalf("WriteFile token: %v", err)
+	}
+	cfg.Serve.APITokenFile = tokenFile
+
+	logger := testutil.TestLogger()
+	store := runstore.NewStore(cfg.Paths.StateDir, logger)
+	mockSystemd := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSystemd), mockSystemd, store, logger)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	handler := srv.requireAPIToken(srv.handleAPI)
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"wrong scheme", "Basic czNjcmV0", http.StatusUnauthorized},
+		{"valid token", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header")
+			}
+		})
+	}
+
+	t.Run("empty token file rejected", func(t *testing.T) {
+		if err := os.WriteFile(tokenFile, []byte("\n"), 0600); err != nil {
+			t.Fatalf("WriteFile token: %v", err)
+		}
+		if _, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSystemd), mockSystemd, store, logger); err == nil {
+			t.Error("expected error for empty API token file")
+		}
+	})
+}
+
+func TestHandleSession(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+	tokenFile := filepath.Join(t.TempDir(), "api-token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret"), 0600); err != nil {
+		t.Fatalf("WriteFile token: %v", err)
+	}
+	cfg.Serve.APITokenFile = tokenFile
+
+	logger := testutil.TestLogger()
+	store := runstore.NewStore(cfg.Paths.StateDir, logger)
+	mockSystemd := &testutil.MockSystemd{Available: true}
+	srv, err := NewServer(cfg, quadsyncd.NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), mockSystemd), mockSystemd, store, logger)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	login := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.handleSession(w, httptest.NewRequest(http.MethodPost, "/api/session", strings.NewReader(`{"token":"`+token+`"}`)))
+		return w
+	}
+
+	t.Run("wrong token", func(t *testing.T) {
+		w := login("nope")
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", w.Code)
+		}
+		if len(w.Result().Cookies()) != 0 {
+			t.Error("expected no session cookie")
+		}
+	})
+
+	t.Run("session cookie authorizes the API", func(t *testing.T) {
+		w := login("s3cret")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var session *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == sessionCookieName {
+				session = c
+			}
+		}
+		if session == nil {
+			t.Fatal("expected session cookie")
+		}
+		if !session.HttpOnly || session.SameSite != http.SameSiteStrictMode {
+			t.Errorf("session cookie must be HttpOnly and SameSite=Strict: %+v", session)
+		}
+		if strings.Contains(session.Value, "s3cret") {
+			t.Error("session cookie must not carry the token")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		req.AddCookie(session)
+		rec := httptest.NewRecorder()
+		srv.requireAPIToken(srv.handleAPI)(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected 200 with session cookie, got %d", rec.Code)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/api/session", nil)
+		req.AddCookie(session)
+		rec = httptest.NewRecorder()
+		srv.handleSession(rec, req)
+		var resp SessionResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !resp.Required || !resp.Authenticated {
+			t.Errorf("unexpected session state %+v", resp)
+		}
+	})
+
+	t.Run("forged cookie rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "s3cret"})
+		rec := httptest.NewRecorder()
+		srv.requireAPIToken(srv.handleAPI)(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", rec.Code)
+		}
+	})
+
+	t.Run("logout clears the cookie", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		srv.handleSession(w, httptest.NewRequest(http.MethodDelete, "/api/session", nil))
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != sessionCookieName || cookies[0].MaxAge >= 0 {
+			t.Errorf("expected expired session cookie, got %+v", cookies)
+		}
+	})
+}
+
+func TestHandleSyncNow(t *testing.T) {
+	server, _ := setupServerWithRuns(t, nil)
+
+	w := httptest.NewRecorder()
+	server.handleAPI(w, httptest.NewRequest(http.MethodPost, "/api/sync", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := server.syncSvc.Status().Queued; got != 1 {
+		t.Errorf("expected 1 queued sync, got %d", got)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleAPI(w, httptest.NewRequest(http.MethodGet, "/api/sync", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: expected 405, got %d", w.Code)
+	}
+
+	server.syncSvc.Close()
+	w = httptest.NewRecorder()
+	server.handleAPI(w, httptest.NewRequest(http.MethodPost, "/api/sync", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("after close: expected 503, got %d", w.Code)
+	}
+}
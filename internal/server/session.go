@@ -0,0 +1,102 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// sessionCookieName is the cookie the Web UI authenticates API requests
+// with while serve.api_token_file is set. EventSource cannot send an
+// Authorization header, so /api/events relies on it.
+const sessionCookieName = "quadsyncd_session"
+
+// sessionValue derives the session cookie value from the API token, so the
+// cookie does not carry the token itself and a new token ends all sessions.
+func sessionValue(token []byte) string {
+	mac := hmac.New(sha256.New, token)
+	mac.Write([]byte("quadsyncd web ui session"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sessionRequest is the JSON body accepted by POST /api/session.
+type sessionRequest struct {
+	Token string `json:"token"`
+}
+
+// SessionResponse is the response shape for /api/session.
+type SessionResponse struct {
+	// Required reports whether the API needs a token at all.
+	Required bool `json:"required"`
+	// Authenticated reports whether the request carried a valid token or
+	// session cookie.
+	Authenticated bool `json:"authenticated"`
+}
+
+// authorized reports whether r may use the API: no token is configured, or
+// r sends it as a bearer token or holds a session cookie derived from it.
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.apiToken) == 0 {
+		return true
+	}
+	if got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return subtle.ConstantTimeCompare([]byte(got), s.apiToken) == 1
+	}
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		return subtle.ConstantTimeCompare([]byte(c.Value), []byte(sessionValue(s.apiToken))) == 1
+	}
+	return false
+}
+
+// handleSession serves /api/session for the Web UI. GET reports whether a
+// token is required and present, POST exchanges the API token for a session
+// cookie, and DELETE clears the cookie.
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	secure := r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+	required := len(s.apiToken) > 0
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, SessionResponse{Required: required, Authenticated: s.authorized(r)})
+	case http.MethodPost:
+		var req sessionRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4*1024)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		if !required {
+			writeJSON(w, http.StatusOK, SessionResponse{Authenticated: true})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(req.Token)), s.apiToken) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "invalid API token")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    sessionValue(s.apiToken),
+			Path:     "/api/",
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+			Secure:   secure,
+		})
+		writeJSON(w, http.StatusOK, SessionResponse{Required: true, Authenticated: true})
+	case http.MethodDelete:
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookieName,
+			Path:     "/api/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+			Secure:   secure,
+		})
+		writeJSON(w, http.StatusOK, SessionResponse{Required: required})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
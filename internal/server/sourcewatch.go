@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/runstore"
+)
+
+// sourceWatchEvent is the webhookTrigger.Event of a sync triggered by a
+// change in a watched source directory.
+const sourceWatchEvent = "watch"
+
+// sourceWatcher watches source directories recursively and reports changed
+// paths. Directories created later are watched as they appear; .git
+// directories are never watched.
+type sourceWatcher struct {
+	w *fsnotify.Watcher
+}
+
+// newSourceWatcher starts watching roots. A root that does not exist is
+// skipped with an error in the returned slice; the watcher still covers the
+// others.
+func newSourceWatcher(roots []string) (*sourceWatcher, []error, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, nil, err
+	}
+	sw := &sourceWatcher{w: w}
+	var skipped []error
+	for _, root := range roots {
+		if err := sw.addTree(root); err != nil {
+			skipped = append(skipped, err)
+		}
+	}
+	return sw, skipped, nil
+}
+
+// addTree watches dir and every directory below it except .git.
+func (sw *sourceWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return sw.w.Add(p)
+	})
+}
+
+// relevant reports whether an event on path may change what is synced.
+// Changes inside .git, e.g. from a fetch, do not change the working tree.
+func relevant(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".git" {
+			return false
+		}
+	}
+	return true
+}
+
+// handle processes one event and reports whether it is a relevant change.
+func (sw *sourceWatcher) handle(ev fsnotify.Event) bool {
+	if !relevant(ev.Name) || ev.Op == fsnotify.Chmod {
+		return false
+	}
+	if ev.Op&fsnotify.Create != 0 {
+		if info, err := os.Lstat(ev.Name); err == nil && info.IsDir() {
+			// Files may already exist in a directory created by a move
+			// or an unpack; they are covered by the sync this triggers.
+			_ = sw.addTree(ev.Name)
+		}
+	}
+	return true
+}
+
+// close stops watching.
+func (sw *sourceWatcher) close() error {
+	return sw.w.Close()
+}
+
+// runSourceWatch triggers a debounced sync whenever a file changes in a
+// local directory source or a git checkout, until ctx is cancelled. Syncs
+// share the webhook debouncer and sync queue, so a burst of changes, such
+// as a `git pull`, results in one sync. Changes in git checkouts while a
+// sync runs are ignored: they are the sync's own checkout.
+func (s *Server) runSourceWatch(ctx context.Context) {
+	cfg := s.config()
+	roots := cfg.WatchedSourceDirs()
+	sw, skipped, err := newSourceWatcher(roots)
+	if err != nil {
+		s.logger.Error("source watch unavailable", logging.KeyError, err)
+		return
+	}
+	defer func() { _ = sw.close() }()
+	for _, err := range skipped {
+		s.logger.Warn("not watching source directory", logging.KeyError, err)
+	}
+
+	checkouts := make(map[string]bool)
+	for _, spec := range cfg.EffectiveRepositories() {
+		if spec.UsesGit() {
+			checkouts[cfg.RepoDirForSpec(spec)] = true
+		}
+	}
+	s.logger.Info("source watch enabled", "dirs", roots)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-sw.w.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Warn("source watch error", logging.KeyError, err)
+		case ev, ok := <-sw.w.Events:
+			if !ok {
+				return
+			}
+			if !sw.handle(ev) {
+				continue
+			}
+			if s.syncSvc.Status().Running && underAny(ev.Name, checkouts) {
+				continue
+			}
+			s.logger.Debug("source changed", logging.Event(logging.EventSourceChanged), "path", ev.Name, "op", ev.Op.String())
+			s.debounce.trigger(webhookTrigger{Event: sourceWatchEvent, Path: ev.Name}, s.runWatchSync)
+		}
+	}
+}
+
+// underAny reports whether path lies in one of dirs.
+func underAny(path string, dirs map[string]bool) bool {
+	for dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// runWatchSync queues the sync for a batch of coalesced source changes.
+func (s *Server) runWatchSync(_ context.Context, batch debounceBatch) {
+	s.logger.Info("source changed, syncing",
+		logging.Event(logging.EventSourceChanged),
+		"path", batch.Last.Path,
+		"coalesced", batch.Coalesced,
+		"wait_ms", batch.Waited.Milliseconds())
+	s.syncSvc.Enqueue(runstore.TriggerWatch)
+}
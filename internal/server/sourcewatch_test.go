@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+)
+
+func TestRelevant(t *testing.T) {
+	tests := map[string]bool{
+		"/srv/q/web.container":         true,
+		"/srv/q/.quadsyncd.yaml":       true,
+		"/srv/q/.git/index":            false,
+		"/srv/q/.git":                  false,
+		"/srv/q/sub/.git/refs/heads/a": false,
+		"/srv/q/hosts/web1/app.env":    true,
+		"/srv/q/not.git/web.container": true,
+	}
+	for path, want := range tests {
+		if got := relevant(path); got != want {
+			t.Errorf("relevant(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestUnderAny(t *testing.T) {
+	dirs := map[string]bool{"/state/repos/abc": true}
+	for path, want := range map[string]bool{
+		"/state/repos/abc":                true,
+		"/state/repos/abc/web.container":  true,
+		"/state/repos/abcd/web.container": false,
+		"/srv/q/web.container":            false,
+	} {
+		if got := underAny(path, dirs); got != want {
+			t.Errorf("underAny(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestRunSourceWatch(t *testing.T) {
+	s, cfg := newReloadTestServer(t)
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Repository = &config.RepoSpec{URL: src, Source: config.SourceConfig{Type: config.SourceDir}}
+	s.debounce.setDelays(10*time.Millisecond, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.runSourceWatch(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	queued := func() int { return s.syncSvc.Status().Queued }
+	waitFor := func(cond func() bool) bool {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if cond() {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	// The watch is set up asynchronously; keep touching .git until a real
+	// change would have been seen, then make sure nothing was queued.
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(src, ".git", "FETCH_HEAD"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := queued(); n != 0 {
+		t.Fatalf("change inside .git queued %d syncs", n)
+	}
+
+	if err := os.WriteFile(filepath.Join(src, "web.container"), []byte("[Container]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(func() bool { return queued() == 1 }) {
+		t.Fatal("writing a file did not queue a sync")
+	}
+	s.syncSvc.Close()
+}
+
+func TestSourceWatcher_WatchesNewDirectories(t *testing.T) {
+	root := t.TempDir()
+	sw, skipped, err := newSourceWatcher([]string{root, filepath.Join(root, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sw.close() }()
+	if len(skipped) != 1 {
+		t.Errorf("skipped = %v, want the missing root", skipped)
+	}
+
+	next := func() string {
+		t.Helper()
+		for {
+			select {
+			case ev := <-sw.w.Events:
+				if sw.handle(ev) {
+					return ev.Name
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("no event")
+			}
+		}
+	}
+
+	sub := filepath.Join(root, "hosts")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != sub {
+		t.Fatalf("event for %q, want %q", got, sub)
+	}
+	file := filepath.Join(sub, "web.env")
+	if err := os.WriteFile(file, []byte("A=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != file {
+		t.Errorf("event for %q, want %q", got, file)
+	}
+}
@@ -2,22 +2,36 @@ package server
 
 import "net/http"
 
-// handleRoot serves the Web UI SPA from embedded assets.
-// For the exact root path or any path not matching /webhook, /api/, or
-// /assets/, it serves index.html to support client-side routing.
+// uiPath is where the Web UI SPA is served.
+const uiPath = "/ui/"
+
+// handleRoot redirects the bare root, where the Web UI used to live, to
+// uiPath. Any other path outside the UI, the API and /webhook is not found.
 func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if r.URL.Path != "/" || s.config().Serve.DisableUI {
+		http.NotFound(w, r)
+		return
+	}
+	http.Redirect(w, r, uiPath, http.StatusFound)
+}
 
-	// For the root path, serve index.html directly.
-	if r.URL.Path == "/" {
-		s.uiHandler.ServeHTTP(w, r)
+// handleUI serves the Web UI SPA from embedded assets. Every path under
+// uiPath gets index.html; the SPA routes on the URL fragment.
+func (s *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config().Serve.DisableUI {
+		http.NotFound(w, r)
 		return
 	}
 
-	// Rewrite to "/" so the file server returns index.html for SPA client-side routing.
+	// Rewrite to "/" so the file server returns index.html.
 	// Clone the request to avoid mutating the original.
 	r2 := r.Clone(r.Context())
 	r2.URL.Path = "/"
@@ -30,6 +44,10 @@ func (s *Server) handleAssets(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if s.config().Serve.DisableUI {
+		http.NotFound(w, r)
+		return
+	}
 
 	// Serve directly from the embedded filesystem.
 	s.uiHandler.ServeHTTP(w, r)
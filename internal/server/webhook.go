@@ -2,70 +2,122 @@ package server
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/logging"
 	"github.com/schaermu/quadsyncd/internal/runstore"
+	"github.com/schaermu/quadsyncd/internal/webhook"
 )
 
-// GitHubPushEvent represents the relevant fields from a GitHub push webhook payload.
-type GitHubPushEvent struct {
-	Ref        string `json:"ref"`
-	After      string `json:"after"`
-	Repository struct {
-		FullName string `json:"full_name"`
-		CloneURL string `json:"clone_url"`
-		SSHURL   string `json:"ssh_url"`
-	} `json:"repository"`
+// webhookListener receives the deliveries of one provider on a path.
+type webhookListener struct {
+	path       string
+	name       string // provider name, as configured
+	provider   webhook.Provider
+	addr       string // own listen address; empty for serve.listen_addr
+	repository string // URL of the only repository accepted; empty for any
 }
 
-// debouncer implements debouncing for webhook events.
-type debouncer struct {
-	mu       sync.Mutex
-	timer    *time.Timer
-	delay    time.Duration
-	callback func()
+// accepts reports whether the listener takes deliveries for spec.
+func (l *webhookListener) accepts(spec config.RepoSpec) bool {
+	return l.repository == "" || spec.URL == l.repository
 }
 
-// trigger schedules the callback to run after the debounce delay.
-// Repeated calls within the delay window reset the timer.
-func (d *debouncer) trigger(callback func()) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	d.callback = callback
-
-	if d.timer != nil {
-		d.timer.Stop()
-	}
-
-	d.timer = time.AfterFunc(d.delay, func() {
-		d.mu.Lock()
-		cb := d.callback
-		d.mu.Unlock()
-
-		if cb != nil {
-			cb()
+// newWebhookListeners creates the providers of every configured listener,
+// reading their secrets. Surrounding whitespace is trimmed from secrets.
+func newWebhookListeners(cfg *config.Config) (map[string]*webhookListener, [][]byte, error) {
+	listeners := make(map[string]*webhookListener)
+	var secrets [][]byte
+	for _, l := range cfg.WebhookListeners() {
+		data, err := os.ReadFile(l.SecretFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read webhook secret: %w", err)
 		}
-	})
+		secret := []byte(strings.TrimSpace(string(data)))
+		provider, err := webhook.New(l.Provider, webhook.Options{
+			Secret:          secret,
+			SignatureHeader: l.Generic.SignatureHeader,
+			DeliveryHeader:  l.Generic.DeliveryHeader,
+			Fields: webhook.FieldPaths{
+				Ref:        l.Generic.Ref,
+				Commit:     l.Generic.Commit,
+				Repository: l.Generic.Repository,
+			},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("webhook listener %s: %w", l.Path, err)
+		}
+		addr := l.ListenAddr
+		if addr == cfg.Serve.ListenAddr {
+			addr = ""
+		}
+		listeners[l.Path] = &webhookListener{
+			path:       l.Path,
+			name:       l.Provider,
+			provider:   provider,
+			addr:       addr,
+			repository: l.Repository,
+		}
+		secrets = append(secrets, secret)
+	}
+	return listeners, secrets, nil
 }
 
-// handleWebhook handles incoming GitHub webhook requests.
+// handleWebhook handles deliveries to every webhook listener, dispatching
+// on the request path.
 // Webhook error responses use http.Error (plain text) intentionally.
-// GitHub does not parse JSON error bodies from webhook endpoints,
+// Git hosts do not parse JSON error bodies from webhook endpoints,
 // and plain text is simpler to debug in webhook delivery logs.
 func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	listener, ok := s.webhooks[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Reject callers outside serve.allowed_cidrs before doing any other work.
+	client, ok := s.ipFilter.allow(r)
+	if !ok {
+		s.logger.Warn("rejecting webhook from disallowed address",
+			logging.Event(logging.EventWebhookRejected), logging.KeyReason, "address_not_allowed",
+			logging.KeyClient, client.String(),
+			"remote_addr", r.RemoteAddr)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Shed load before reading the body.
+	if !s.inFlight.tryAcquire() {
+		s.logger.Warn("rejecting webhook: too many requests in flight", logging.Event(logging.EventWebhookRejected), logging.KeyReason, "in_flight", logging.KeyClient, client.String())
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+	defer s.inFlight.release()
+
+	if s.rateLimiter != nil {
+		if allowed, wait := s.rateLimiter.allow(client); !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			s.logger.Warn("rejecting webhook: rate limit exceeded",
+				logging.Event(logging.EventWebhookRejected), logging.KeyReason, "rate_limit",
+				logging.KeyClient, client.String(),
+				"retry_after_s", retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+
 	// Only accept POST requests
 	if r.Method != http.MethodPost {
-		s.logger.Warn("rejecting non-POST request", "method", r.Method)
+		s.logger.Warn("rejecting non-POST request", logging.Event(logging.EventWebhookRejected), logging.KeyReason, "method_not_allowed", "method", r.Method)
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -73,7 +125,7 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	// Check content type
 	contentType := r.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "application/json") {
-		s.logger.Warn("rejecting request with invalid content type", "content_type", contentType)
+		s.logger.Warn("rejecting request with invalid content type", logging.Event(logging.EventWebhookRejected), logging.KeyReason, "invalid_content_type", "content_type", contentType)
 		http.Error(w, "Invalid content type", http.StatusBadRequest)
 		return
 	}
@@ -90,44 +142,128 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// Verify signature
-	signature := r.Header.Get("X-Hub-Signature-256")
-	if !s.verifySignature(body, signature) {
-		s.logger.Warn("rejecting request with invalid signature")
+	if err := listener.provider.VerifyRequest(r, body); err != nil {
+		s.logger.Warn("rejecting request with invalid signature", logging.Event(logging.EventWebhookRejected), logging.KeyReason, "invalid_signature",
+			logging.KeyProvider, listener.name, logging.KeyError, err)
 		http.Error(w, "Invalid signature", http.StatusForbidden)
 		return
 	}
 
-	// Parse event type
-	eventType := r.Header.Get("X-GitHub-Event")
-	s.logger.Info("received webhook", "event", eventType)
+	event, err := listener.provider.ParseEvent(r, body)
+	if err != nil {
+		s.logger.Error("failed to parse webhook payload", logging.Event(logging.EventWebhookRejected), logging.KeyReason, "invalid_payload",
+			logging.KeyProvider, listener.name, "error", err)
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	// Reject replays of a delivery that was already processed.
+	replayed, err := s.deliveries.record(event.DeliveryID, body, time.Now())
+	if err != nil {
+		s.logger.Warn("failed to persist webhook delivery log", logging.KeyError, err)
+	}
+	if replayed {
+		s.logger.Warn("rejecting replayed webhook delivery",
+			logging.Event(logging.EventWebhookRejected), logging.KeyReason, "replayed_delivery",
+			logging.KeyDeliveryID, event.DeliveryID)
+		http.Error(w, "Delivery already processed", http.StatusConflict)
+		return
+	}
+
+	s.logger.Info("received webhook", logging.Event(logging.EventWebhookReceived),
+		logging.KeyProvider, listener.name, logging.KeyGitHubEvent, event.Type)
+
+	// Hosts send a ping when the webhook is created; answer it so the
+	// delivery shows as successful in the repository settings.
+	if event.Kind == webhook.KindPing {
+		s.logger.Info("answering webhook ping",
+			logging.Event(logging.EventWebhookPing),
+			logging.KeyProvider, listener.name,
+			"repo", event.Repository.FullName)
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "pong\n")
+		return
+	}
+
+	// Branch and tag deletions never have anything to sync.
+	if event.Kind == webhook.KindDelete {
+		s.logger.Info("ignoring ref deletion event", logging.Event(logging.EventWebhookIgnored), logging.KeyReason, "ref_deleted")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "Ref deletion does not trigger sync\n")
+		return
+	}
 
 	// Check if event type is allowed
-	if !s.isEventTypeAllowed(eventType) {
-		s.logger.Info("ignoring disallowed event type", "event", eventType)
+	if !s.isEventTypeAllowed(event.Type) && (event.Kind != webhook.KindPush || !s.isEventTypeAllowed("push")) {
+		s.logger.Info("ignoring disallowed event type", logging.Event(logging.EventWebhookIgnored), logging.KeyReason, "event_not_allowed", logging.KeyGitHubEvent, event.Type)
 		w.WriteHeader(http.StatusOK)
 		_, _ = fmt.Fprintf(w, "Event type not configured for sync\n")
 		return
 	}
 
-	// Parse push event
-	var event GitHubPushEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		s.logger.Error("failed to parse webhook payload", "error", err)
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
+	// A captured delivery replayed under a new ID is only as fresh as its
+	// head commit.
+	if maxAge := s.config().Serve.MaxPayloadAge; maxAge > 0 && !event.Timestamp.IsZero() {
+		if age := time.Since(event.Timestamp); age > maxAge {
+			s.logger.Warn("rejecting webhook with stale payload",
+				logging.Event(logging.EventWebhookRejected), logging.KeyReason, "payload_too_old",
+				logging.KeyCommit, event.Commit,
+				"age", age.Round(time.Second).String())
+			http.Error(w, "Payload too old", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Deliveries from repositories that merely share the secret are refused
+	// outright when serve.require_repo_match is set.
+	if s.config().Serve.RequireRepoMatch && !s.isRepositoryConfigured(event) {
+		s.logger.Warn("rejecting webhook for unconfigured repository",
+			logging.Event(logging.EventWebhookRejected), logging.KeyReason, "repository_mismatch",
+			"repo", event.Repository.FullName)
+		http.Error(w, "Repository not configured", http.StatusForbidden)
+		return
+	}
+
+	// A listener bound to one repository only takes its deliveries, so the
+	// listener's secret cannot trigger syncs for another repository.
+	if listener.repository != "" && !repoURLMatchesEvent(listener.repository, event.Repository) {
+		s.logger.Warn("rejecting webhook for repository not bound to listener",
+			logging.Event(logging.EventWebhookRejected), logging.KeyReason, "repository_mismatch",
+			logging.KeyProvider, listener.name,
+			"repo", event.Repository.FullName)
+		http.Error(w, "Repository not bound to this listener", http.StatusForbidden)
+		return
+	}
+
+	// A push that deletes the ref has nothing to check out.
+	if event.Deleted {
+		s.logger.Info("ignoring push that deletes ref",
+			logging.Event(logging.EventWebhookIgnored), logging.KeyReason, "ref_deleted",
+			"ref", event.Ref,
+			"repo", event.Repository.FullName)
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "Ref deleted, skipping sync\n")
 		return
 	}
 
 	// Check if ref is allowed (global filter)
 	if !s.isRefAllowed(event.Ref) {
-		s.logger.Info("ignoring disallowed ref", "ref", event.Ref)
+		s.logger.Info("ignoring disallowed ref", logging.Event(logging.EventWebhookIgnored), logging.KeyReason, "ref_not_allowed", "ref", event.Ref)
 		w.WriteHeader(http.StatusOK)
 		_, _ = fmt.Fprintf(w, "Ref not configured for sync\n")
 		return
 	}
 
 	// Check if the push matches a configured repository and tracked ref
-	if !s.matchesConfiguredRepo(event) {
+	var specs []config.RepoSpec
+	for _, spec := range s.matchingRepos(event) {
+		if listener.accepts(spec) {
+			specs = append(specs, spec)
+		}
+	}
+	if len(specs) == 0 {
 		s.logger.Info("ignoring webhook for unconfigured repository/ref",
+			logging.Event(logging.EventWebhookIgnored), logging.KeyReason, "repository_not_configured",
 			"repo", event.Repository.FullName,
 			"ref", event.Ref)
 		w.WriteHeader(http.StatusOK)
@@ -135,90 +271,124 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Skip pushes that only touch files outside every matching subdir.
+	if !pushTouchesWatchedPaths(event.ChangedPaths, specs) {
+		s.logger.Info("ignoring push without changes to watched paths",
+			logging.Event(logging.EventWebhookIgnored), logging.KeyReason, "no_watched_changes",
+			"repo", event.Repository.FullName,
+			"ref", event.Ref,
+			logging.KeyCommit, event.Commit)
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "No watched paths changed, skipping sync\n")
+		return
+	}
+
 	s.logger.Info("webhook accepted",
-		"event", eventType,
+		logging.Event(logging.EventWebhookAccepted),
+		"delivery_id", event.DeliveryID,
+		logging.KeyProvider, listener.name,
+		logging.KeyGitHubEvent, event.Type,
 		"ref", event.Ref,
-		"commit", event.After,
+		logging.KeyCommit, event.Commit,
 		"repo", event.Repository.FullName)
 
 	// Trigger debounced sync
-	s.debounce.trigger(func() {
-		s.syncSvc.TriggerSync(context.Background(), runstore.TriggerWebhook)
-	})
+	accepted := s.debounce.trigger(webhookTrigger{
+		DeliveryID: event.DeliveryID,
+		Event:      event.Type,
+		Ref:        event.Ref,
+		Commit:     event.Commit,
+	}, s.runDebouncedSync)
+	if !accepted {
+		s.logger.Warn("dropping webhook received during shutdown", logging.Event(logging.EventWebhookRejected), logging.KeyReason, "shutdown")
+		// The host redelivers it to the next instance; it must not be
+		// taken for a replay there.
+		if err := s.deliveries.forget(event.DeliveryID, body); err != nil {
+			s.logger.Warn("failed to persist webhook delivery log", logging.KeyError, err)
+		}
+		http.Error(w, "Server shutting down", http.StatusServiceUnavailable)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	_, _ = fmt.Fprintf(w, "Sync triggered\n")
 }
 
-// verifySignature verifies the GitHub webhook HMAC-SHA256 signature.
-func (s *Server) verifySignature(body []byte, signature string) bool {
-	if signature == "" {
-		return false
-	}
-
-	// GitHub signature format: sha256=<hex>
-	if !strings.HasPrefix(signature, "sha256=") {
-		return false
-	}
-	signature = strings.TrimPrefix(signature, "sha256=")
-
-	// Compute expected signature
-	mac := hmac.New(sha256.New, s.secret)
-	mac.Write(body)
-	expected := hex.EncodeToString(mac.Sum(nil))
-
-	// Constant-time comparison
-	return hmac.Equal([]byte(signature), []byte(expected))
+// runDebouncedSync queues the sync for a batch of coalesced webhook triggers.
+func (s *Server) runDebouncedSync(_ context.Context, batch debounceBatch) {
+	s.logger.Info("debounced webhook sync starting",
+		logging.Event(logging.EventWebhookSync),
+		"delivery_id", batch.Last.DeliveryID,
+		logging.KeyGitHubEvent, batch.Last.Event,
+		"ref", batch.Last.Ref,
+		logging.KeyCommit, batch.Last.Commit,
+		"coalesced", batch.Coalesced,
+		"wait_ms", batch.Waited.Milliseconds())
+	s.syncSvc.EnqueueDelivery(runstore.TriggerWebhook, batch.Last.DeliveryID)
 }
 
 // isEventTypeAllowed checks if the event type is in the allowed list.
 func (s *Server) isEventTypeAllowed(eventType string) bool {
-	return len(s.cfg.Serve.AllowedEventTypes) == 0 || sliceContains(s.cfg.Serve.AllowedEventTypes, eventType)
+	return len(s.config().Serve.AllowedEventTypes) == 0 || sliceContains(s.config().Serve.AllowedEventTypes, eventType)
 }
 
 // isRefAllowed checks if the ref is in the allowed list.
 func (s *Server) isRefAllowed(ref string) bool {
-	return len(s.cfg.Serve.AllowedRefs) == 0 || sliceContains(s.cfg.Serve.AllowedRefs, ref)
+	return len(s.config().Serve.AllowedRefs) == 0 || sliceContains(s.config().Serve.AllowedRefs, ref)
 }
 
-// matchesConfiguredRepo checks if the push event matches at least one configured
-// repository (by URL) with a matching tracked ref.
-func (s *Server) matchesConfiguredRepo(event GitHubPushEvent) bool {
-	repos := s.cfg.EffectiveRepositories()
-	for _, spec := range repos {
-		if repoURLMatchesEvent(spec.URL, event) && spec.Ref == event.Ref {
+// isRepositoryConfigured reports whether the event comes from one of the
+// configured git repositories, whatever the ref.
+func (s *Server) isRepositoryConfigured(event *webhook.Event) bool {
+	for _, spec := range s.config().EffectiveRepositories() {
+		if spec.UsesGit() && repoURLMatchesEvent(spec.URL, event.Repository) {
 			return true
 		}
 	}
 	return false
 }
 
+// matchingRepos returns the configured git repositories whose URL and
+// tracked ref match the push event.
+func (s *Server) matchingRepos(event *webhook.Event) []config.RepoSpec {
+	var matches []config.RepoSpec
+	for _, spec := range s.config().EffectiveRepositories() {
+		if spec.UsesGit() && repoURLMatchesEvent(spec.URL, event.Repository) && spec.Ref == event.Ref {
+			matches = append(matches, spec)
+		}
+	}
+	return matches
+}
+
 // repoURLMatchesEvent reports whether a configured repo URL corresponds to the
-// repository that sent the webhook event.
-func repoURLMatchesEvent(cfgURL string, event GitHubPushEvent) bool {
+// repository that sent the webhook event. Names are compared without regard
+// to case, as GitHub treats them.
+func repoURLMatchesEvent(cfgURL string, repo webhook.Repository) bool {
 	cfgName := repoFullNameFromURL(cfgURL)
 	if cfgName == "" {
 		return false
 	}
-	if cfgName == event.Repository.FullName {
-		return true
-	}
-	if event.Repository.CloneURL != "" && cfgName == repoFullNameFromURL(event.Repository.CloneURL) {
+	if strings.EqualFold(cfgName, repo.FullName) {
 		return true
 	}
-	if event.Repository.SSHURL != "" && cfgName == repoFullNameFromURL(event.Repository.SSHURL) {
-		return true
+	for _, u := range repo.URLs {
+		if strings.EqualFold(cfgName, repoFullNameFromURL(u)) {
+			return true
+		}
 	}
 	return false
 }
 
 // repoFullNameFromURL extracts the "owner/repo" path from a Git remote URL.
 // It supports HTTPS, SSH scheme, and SSH shorthand (git@host:owner/repo) URLs.
+// A trailing slash, a ".git" suffix and a port are dropped.
 func repoFullNameFromURL(rawURL string) string {
+	rawURL = strings.TrimRight(rawURL, "/")
+
 	// Handle SSH shorthand: git@github.com:org/repo.git
 	if strings.HasPrefix(rawURL, "git@") {
 		if idx := strings.Index(rawURL, ":"); idx >= 0 {
-			return strings.TrimSuffix(rawURL[idx+1:], ".git")
+			return strings.TrimSuffix(strings.TrimPrefix(rawURL[idx+1:], "/"), ".git")
 		}
 		return ""
 	}
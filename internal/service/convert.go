@@ -28,3 +28,29 @@ func ConflictSummaryFromSync(c quadsyncd.Conflict) runstore.ConflictSummary {
 		Losers: losers,
 	}
 }
+
+// WarningSummariesFromSync converts sync warnings to their runstore form.
+// It returns nil for an empty slice so the field is omitted when serialized.
+func WarningSummariesFromSync(warnings []quadsyncd.Warning) []runstore.WarningSummary {
+	if len(warnings) == 0 {
+		return nil
+	}
+	out := make([]runstore.WarningSummary, len(warnings))
+	for i, w := range warnings {
+		out[i] = runstore.WarningSummary{
+			Code:    string(w.Code),
+			Message: w.Message,
+			Subject: w.Subject,
+		}
+	}
+	return out
+}
+
+// redactedSecrets converts the secrets to redact from stored run logs.
+func redactedSecrets(secrets [][]byte) []string {
+	out := make([]string, len(secrets))
+	for i, s := range secrets {
+		out[i] = string(s)
+	}
+	return out
+}
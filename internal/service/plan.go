@@ -6,9 +6,11 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/git"
 	"github.com/schaermu/quadsyncd/internal/logging"
 	"github.com/schaermu/quadsyncd/internal/quadlet"
 	"github.com/schaermu/quadsyncd/internal/runstore"
@@ -17,29 +19,46 @@ import (
 
 // PlanService orchestrates dry-run plan execution.
 type PlanService struct {
+	cfgMu         sync.RWMutex // guards cfg
 	cfg           *config.Config
 	runnerFactory quadsyncd.RunnerFactory
 	store         runstore.ReadWriter
 	logger        *slog.Logger
-	secret        []byte
+	secrets       []string // redacted from stored run logs
 }
 
-// NewPlanService creates a new PlanService.
-func NewPlanService(cfg *config.Config, runnerFactory quadsyncd.RunnerFactory, store runstore.ReadWriter, logger *slog.Logger, secret []byte) *PlanService {
+// NewPlanService creates a new PlanService. secrets are redacted from the
+// stored run logs.
+func NewPlanService(cfg *config.Config, runnerFactory quadsyncd.RunnerFactory, store runstore.ReadWriter, logger *slog.Logger, secrets ...[]byte) *PlanService {
 	return &PlanService{
 		cfg:           cfg,
 		runnerFactory: runnerFactory,
 		store:         store,
 		logger:        logger,
-		secret:        secret,
+		secrets:       redactedSecrets(secrets),
 	}
 }
 
+// SetConfig replaces the configuration used by plans that start afterwards.
+func (p *PlanService) SetConfig(cfg *config.Config) {
+	p.cfgMu.Lock()
+	defer p.cfgMu.Unlock()
+	p.cfg = cfg
+}
+
+// config returns the configuration for the next plan.
+func (p *PlanService) config() *config.Config {
+	p.cfgMu.RLock()
+	defer p.cfgMu.RUnlock()
+	return p.cfg
+}
+
 // Execute runs a dry-run plan for the given request and returns the run ID.
 // If setup fails (run record cannot be created), returns ("", err).
 // If the plan engine itself fails, returns (runID, err) – the run record is
 // still created, updated, and accessible via the store.
 func (p *PlanService) Execute(ctx context.Context, req runstore.PlanRequest) (string, error) {
+	cfg := p.config()
 	meta := &runstore.RunMeta{
 		Kind:      runstore.RunKindPlan,
 		Trigger:   runstore.TriggerUI,
@@ -54,7 +73,7 @@ func (p *PlanService) Execute(ctx context.Context, req runstore.PlanRequest) (st
 		p.logger.Error("failed to create plan run record", "error", err)
 		return "", fmt.Errorf("failed to create plan record: %w", err)
 	}
-	p.logger.Info("created plan run record", "run_id", meta.ID)
+	p.logger.Info("created plan run record", logging.Event(logging.EventRunCreated), logging.KeyRunID, meta.ID)
 
 	var ndjsonLevel = slog.LevelInfo
 	if leveler, ok := p.logger.Handler().(interface{ Level() slog.Level }); ok {
@@ -67,19 +86,19 @@ func (p *PlanService) Execute(ctx context.Context, req runstore.PlanRequest) (st
 		Level: ndjsonLevel,
 	})
 
-	redactedNDJSON := logging.NewRedactingHandler(ndjsonHandler, []string{string(p.secret)})
+	redactedNDJSON := logging.NewRedactingHandler(ndjsonHandler, p.secrets)
 	teeHandler := logging.NewTeeHandler(p.logger.Handler(), redactedNDJSON)
 	logger := slog.New(teeHandler)
 
 	workDir, err := p.store.WorkDirForRun(meta.ID)
 	if err != nil {
-		p.logger.Error("failed to resolve workdir for plan run", "run_id", meta.ID, "error", err)
+		p.logger.Error("failed to resolve workdir for plan run", logging.KeyRunID, meta.ID, "error", err)
 		endedAt := time.Now().UTC()
 		meta.EndedAt = &endedAt
 		meta.Status = runstore.RunStatusError
 		meta.Error = fmt.Sprintf("failed to resolve plan workdir: %v", err)
 		if updateErr := p.store.Update(ctx, meta); updateErr != nil {
-			p.logger.Error("failed to persist plan run error state after workdir failure", "run_id", meta.ID, "error", updateErr)
+			p.logger.Error("failed to persist plan run error state after workdir failure", logging.KeyRunID, meta.ID, "error", updateErr)
 		}
 		return meta.ID, fmt.Errorf("failed to resolve plan workdir: %w", err)
 	}
@@ -102,9 +121,9 @@ func (p *PlanService) Execute(ctx context.Context, req runstore.PlanRequest) (st
 	logger.Info("performing plan operation",
 		"repo_url", req.RepoURL,
 		"ref", req.Ref,
-		"commit", req.Commit)
+		logging.KeyCommit, req.Commit)
 
-	engine := p.runnerFactory(p.cfg, logger, true, &planOpts)
+	engine := p.runnerFactory(cfg, logger, true, &planOpts)
 	result, planErr := engine.Run(ctx)
 
 	endedAt := time.Now().UTC()
@@ -113,7 +132,8 @@ func (p *PlanService) Execute(ctx context.Context, req runstore.PlanRequest) (st
 	if planErr != nil {
 		meta.Status = runstore.RunStatusError
 		meta.Error = planErr.Error()
-		logger.Error("plan failed", "error", planErr)
+		meta.ErrorKind = string(git.KindOf(planErr))
+		logger.Error("plan failed", "error", planErr, logging.KeyErrorKind, meta.ErrorKind)
 	} else {
 		meta.Status = runstore.RunStatusSuccess
 		logger.Info("plan completed successfully")
@@ -128,7 +148,7 @@ func (p *PlanService) Execute(ctx context.Context, req runstore.PlanRequest) (st
 	}
 
 	if result != nil && result.Plan != nil {
-		planData := writePlanWithArtifacts(ctx, p.store, meta.ID, result.Plan, meta.Conflicts, p.cfg.Paths.QuadletDir, req, logger)
+		planData := writePlanWithArtifacts(ctx, p.store, meta.ID, result.Plan, meta.Conflicts, cfg.Paths.QuadletDir, req, logger)
 		if err := p.store.WritePlan(ctx, meta.ID, planData); err != nil {
 			logger.Error("failed to persist plan.json", "error", err)
 		}
@@ -157,17 +177,28 @@ func writePlanWithArtifacts(ctx context.Context, store runstore.ReadWriter, runI
 		return filepath.ToSlash(rel)
 	}
 
+	storeArtifact := func(name string, content []byte) string {
+		if err := store.WriteArtifact(ctx, runID, name, content); err != nil {
+			logger.Warn("plan artifact: failed to write artifact", "name", name, "error", err)
+			return ""
+		}
+		return name
+	}
 	writeArtifact := func(name string, path string) string {
 		content, err := os.ReadFile(path)
 		if err != nil {
 			logger.Warn("plan artifact: failed to read file", "path", path, "error", err)
 			return ""
 		}
-		if err := store.WriteArtifact(ctx, runID, name, content); err != nil {
-			logger.Warn("plan artifact: failed to write artifact", "name", name, "error", err)
-			return ""
+		return storeArtifact(name, content)
+	}
+	// writeAfter stores the content op installs, which differs from its
+	// source when substitution changed it.
+	writeAfter := func(name string, op quadsyncd.FileOp) string {
+		if op.Rendered != nil {
+			return storeArtifact(name, op.Rendered)
 		}
-		return name
+		return writeArtifact(name, op.SourcePath)
 	}
 
 	for _, op := range syncPlan.Add {
@@ -182,7 +213,7 @@ func writePlanWithArtifacts(ctx context.Context, store runstore.ReadWriter, runI
 			pOp.Unit = quadlet.UnitNameFromQuadlet(op.DestPath)
 			ext := filepath.Ext(op.DestPath)
 			afterName := fmt.Sprintf("%04d-after%s", idx, ext)
-			pOp.AfterPath = writeArtifact(afterName, op.SourcePath)
+			pOp.AfterPath = writeAfter(afterName, op)
 		}
 		ops = append(ops, pOp)
 		idx++
@@ -204,7 +235,7 @@ func writePlanWithArtifacts(ctx context.Context, store runstore.ReadWriter, runI
 			// "before": current file on disk in quadletDir
 			pOp.BeforePath = writeArtifact(beforeName, op.DestPath)
 			// "after": incoming content from source checkout
-			pOp.AfterPath = writeArtifact(afterName, op.SourcePath)
+			pOp.AfterPath = writeAfter(afterName, op)
 		}
 		ops = append(ops, pOp)
 		idx++
@@ -226,9 +257,15 @@ func writePlanWithArtifacts(ctx context.Context, store runstore.ReadWriter, runI
 		idx++
 	}
 
+	var unmanaged []string
+	for _, path := range syncPlan.Unmanaged {
+		unmanaged = append(unmanaged, relPath(path))
+	}
+
 	return runstore.Plan{
-		Requested: requested,
-		Conflicts: conflicts,
-		Ops:       ops,
+		Requested:      requested,
+		Conflicts:      conflicts,
+		Ops:            ops,
+		UnmanagedFiles: unmanaged,
 	}
 }
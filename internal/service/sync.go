@@ -13,74 +13,249 @@ import (
 	quadsyncd "github.com/schaermu/quadsyncd/internal/sync"
 )
 
-// SyncService orchestrates sync execution with run tracking and single-flight semantics.
+// syncQueueSize bounds how many syncs may wait behind the running one. A
+// sync always applies the latest commit of every repository, so a single
+// queued run covers every trigger that arrives while one is waiting.
+const syncQueueSize = 1
+
+// SyncService orchestrates sync execution with run tracking. Every trigger
+// source enqueues onto one bounded queue that a single worker, started with
+// Run, drains one sync at a time.
 type SyncService struct {
-	cfg           *config.Config
 	runnerFactory quadsyncd.RunnerFactory
 	store         runstore.ReadWriter
 	logger        *slog.Logger
-	secret        []byte
+	secrets       []string // redacted from stored run logs
+
+	// onComplete, when set, is called after every sync run finishes.
+	onComplete func(result *quadsyncd.Result, err error)
+
+	queue   chan syncRequest
+	closing chan struct{} // closed by Close
+	done    chan struct{} // closed when Run returns
+
+	mu      sync.Mutex // guards the fields below
+	cfg     *config.Config
+	closed  bool
+	current *SyncStatus   // the running sync, nil when idle
+	pending int           // queued and running syncs
+	idle    chan struct{} // closed when pending drops to zero
+}
+
+// syncRequest is a queued sync.
+type syncRequest struct {
+	trigger    runstore.TriggerSource
+	deliveryID string // the webhook delivery that queued the sync, if any
+}
 
-	mu      sync.Mutex // guards running and pending
-	running bool       // whether a sync is currently in progress
-	pending bool       // whether another sync is needed after the current one
+// SyncStatus describes the state of the sync worker.
+type SyncStatus struct {
+	// Running reports whether a sync is in progress.
+	Running bool
+	// Trigger and StartedAt describe the running sync.
+	Trigger   runstore.TriggerSource
+	StartedAt time.Time
+	// Queued is the number of syncs waiting behind the running one.
+	Queued int
 }
 
-// NewSyncService creates a new SyncService.
-func NewSyncService(cfg *config.Config, runnerFactory quadsyncd.RunnerFactory, store runstore.ReadWriter, logger *slog.Logger, secret []byte) *SyncService {
+// NewSyncService creates a new SyncService. secrets are redacted from the
+// stored run logs.
+func NewSyncService(cfg *config.Config, runnerFactory quadsyncd.RunnerFactory, store runstore.ReadWriter, logger *slog.Logger, secrets ...[]byte) *SyncService {
 	return &SyncService{
 		cfg:           cfg,
 		runnerFactory: runnerFactory,
 		store:         store,
 		logger:        logger,
-		secret:        secret,
+		secrets:       redactedSecrets(secrets),
+		queue:         make(chan syncRequest, syncQueueSize),
+		closing:       make(chan struct{}),
+		done:          make(chan struct{}),
 	}
 }
 
-// TriggerSync enqueues a sync. Uses single-flight semantics:
-//   - If no sync is running: starts one immediately in the caller's goroutine.
-//   - If a sync is already running: marks pending and returns; the running sync
-//     loop will service the queued request automatically.
-//   - At most one additional run is ever queued; further concurrent calls drop.
+// SetConfig replaces the configuration used by syncs that start afterwards.
+// A running sync keeps the configuration it started with.
+func (s *SyncService) SetConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+}
+
+// config returns the configuration for the next sync.
+func (s *SyncService) config() *config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+// SetOnComplete registers a callback invoked after every sync run with the
+// run's result (nil on early failure) and error.
+func (s *SyncService) SetOnComplete(fn func(result *quadsyncd.Result, err error)) {
+	s.onComplete = fn
+}
+
+// Run is the sync worker. It executes queued syncs one at a time under ctx
+// until ctx is done, or until Close was called and the running sync has
+// finished. It must be called exactly once.
+func (s *SyncService) Run(ctx context.Context) {
+	defer func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		s.drop()
+		close(s.done)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.closing:
+			return
+		case req := <-s.queue:
+			s.mu.Lock()
+			if s.closed {
+				s.finishLocked()
+				s.mu.Unlock()
+				continue
+			}
+			s.current = &SyncStatus{Running: true, Trigger: req.trigger, StartedAt: time.Now().UTC()}
+			s.mu.Unlock()
+
+			s.executeSync(ctx, req)
+
+			s.mu.Lock()
+			s.current = nil
+			s.finishLocked()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Enqueue queues a sync for trigger without waiting for it. When a sync is
+// already queued, the trigger is coalesced into it. It reports false when
+// the service has been closed and the trigger was dropped.
+func (s *SyncService) Enqueue(trigger runstore.TriggerSource) bool {
+	return s.enqueue(syncRequest{trigger: trigger})
+}
+
+// EnqueueDelivery is Enqueue for a sync queued by the webhook delivery
+// deliveryID, which the audit log records. A delivery coalesced into an
+// already queued sync is not recorded.
+func (s *SyncService) EnqueueDelivery(trigger runstore.TriggerSource, deliveryID string) bool {
+	return s.enqueue(syncRequest{trigger: trigger, deliveryID: deliveryID})
+}
+
+func (s *SyncService) enqueue(req syncRequest) bool {
+	trigger := req.trigger
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.logger.Info("sync service is shutting down, dropping sync request", "trigger", trigger)
+		return false
+	}
+	select {
+	case s.queue <- req:
+		if s.pending == 0 {
+			s.idle = make(chan struct{})
+		}
+		s.pending++
+		if s.current != nil {
+			s.logger.Info("sync already in progress, queuing re-run", "trigger", trigger)
+		}
+	default:
+		s.logger.Info("sync already queued, coalescing trigger", "trigger", trigger)
+	}
+	return true
+}
+
+// finishLocked records that a queued sync has run or was dropped. s.mu must
+// be held.
+func (s *SyncService) finishLocked() {
+	s.pending--
+	if s.pending == 0 {
+		close(s.idle)
+	}
+}
+
+// TriggerSync enqueues a sync and waits until the worker is idle, i.e. the
+// sync and any sync queued with it have finished, or until ctx is done.
 func (s *SyncService) TriggerSync(ctx context.Context, trigger runstore.TriggerSource) {
+	if s.Enqueue(trigger) {
+		_ = s.Wait(ctx)
+	}
+}
+
+// Status returns a snapshot of the worker state.
+func (s *SyncService) Status() SyncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var st SyncStatus
+	if s.current != nil {
+		st = *s.current
+	}
+	st.Queued = len(s.queue)
+	return st
+}
+
+// Close stops the service from starting syncs: queued syncs and later
+// triggers are dropped, and Run returns once the running sync has finished.
+// A sync already running is not interrupted; its context is the one passed
+// to Run.
+func (s *SyncService) Close() {
 	s.mu.Lock()
-	if s.running {
-		s.pending = true
+	if s.closed {
 		s.mu.Unlock()
-		s.logger.Info("sync already in progress, queuing pending re-run")
 		return
 	}
-	s.running = true
+	s.closed = true
 	s.mu.Unlock()
+	close(s.closing)
+	s.drop()
+}
 
-	runCtx := ctx
+// drop discards queued syncs.
+func (s *SyncService) drop() {
 	for {
-		s.executeSync(runCtx, trigger)
-
-		// Atomically check whether another sync was requested while we were
-		// running. If not, release the running slot and stop; if yes, clear
-		// the flag and loop to service that one pending request.
-		s.mu.Lock()
-		if !s.pending {
-			s.running = false
+		select {
+		case <-s.queue:
+			s.mu.Lock()
+			s.finishLocked()
 			s.mu.Unlock()
-			break
+		default:
+			return
 		}
-		s.pending = false
-		s.mu.Unlock()
+	}
+}
 
-		// The pending request arrived independently of the original caller;
-		// use a fresh background context so that cancellation of the initial
-		// context (e.g. server shutdown signalled after the first sync was
-		// already queued) does not abort the re-run.
-		runCtx = context.Background()
-		s.logger.Info("re-running sync due to pending request")
+// Done returns a channel that is closed when Run has returned.
+func (s *SyncService) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until no sync is queued or running, or ctx is done, and
+// returns ctx's error in the latter case.
+func (s *SyncService) Wait(ctx context.Context) error {
+	s.mu.Lock()
+	pending, idle := s.pending, s.idle
+	s.mu.Unlock()
+	if pending == 0 {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
 // executeSync performs a single instrumented sync run: creates a run record,
 // sets up tee logging, runs the engine, and persists results.
-func (s *SyncService) executeSync(ctx context.Context, trigger runstore.TriggerSource) {
+func (s *SyncService) executeSync(ctx context.Context, req syncRequest) {
+	trigger := req.trigger
+	actor := quadsyncd.Actor{Trigger: string(trigger), DeliveryID: req.deliveryID}
+	cfg := s.config()
 	meta := &runstore.RunMeta{
 		Kind:      runstore.RunKindSync,
 		Trigger:   trigger,
@@ -95,17 +270,20 @@ func (s *SyncService) executeSync(ctx context.Context, trigger runstore.TriggerS
 	if err := s.store.Create(ctx, meta); err != nil {
 		s.logger.Error("failed to create run record, continuing without instrumentation", "error", err)
 		// Run sync without runstore instrumentation as a best-effort fallback.
-		engine := s.runnerFactory(s.cfg, s.logger, false, nil)
-		_, syncErr := engine.Run(ctx)
+		engine := s.runnerFactory(cfg, s.logger, false, nil)
+		result, syncErr := quadsyncd.RunWithTimeout(quadsyncd.WithActor(ctx, actor), engine, cfg.Serve.SyncTimeout)
 		if syncErr != nil {
-			s.logger.Error("sync failed", "error", syncErr)
+			s.logger.Error("sync failed", logging.Event(logging.EventSyncFailed), "error", syncErr)
 		} else {
 			s.logger.Info("sync completed successfully")
 		}
+		if s.onComplete != nil {
+			s.onComplete(result, syncErr)
+		}
 		return
 	}
 	runRecordCreated = true
-	s.logger.Info("created run record", "run_id", meta.ID)
+	s.logger.Info("created run record", logging.Event(logging.EventRunCreated), logging.KeyRunID, meta.ID)
 
 	// Determine the log level to use for the ndjson file handler.
 	var ndjsonLevel = slog.LevelInfo
@@ -120,15 +298,16 @@ func (s *SyncService) executeSync(ctx context.Context, trigger runstore.TriggerS
 	})
 
 	// Wrap the ndjson handler with secret redaction so known sensitive values
-	// (e.g. the webhook secret) are not written to stored run logs.
-	redactedNDJSON := logging.NewRedactingHandler(ndjsonHandler, []string{string(s.secret)})
+	// (e.g. the webhook secrets) are not written to stored run logs.
+	redactedNDJSON := logging.NewRedactingHandler(ndjsonHandler, s.secrets)
 
 	teeHandler := logging.NewTeeHandler(s.logger.Handler(), redactedNDJSON)
 	logger := slog.New(teeHandler)
 
 	logger.Info("performing sync operation")
-	engine := s.runnerFactory(s.cfg, logger, false, nil)
-	result, syncErr := engine.Run(ctx)
+	engine := s.runnerFactory(cfg, logger, false, nil)
+	actor.RunID = meta.ID
+	result, syncErr := quadsyncd.RunWithTimeout(quadsyncd.WithActor(ctx, actor), engine, cfg.Serve.SyncTimeout)
 
 	endedAt := time.Now().UTC()
 	meta.EndedAt = &endedAt
@@ -136,7 +315,8 @@ func (s *SyncService) executeSync(ctx context.Context, trigger runstore.TriggerS
 	if syncErr != nil {
 		meta.Status = runstore.RunStatusError
 		meta.Error = syncErr.Error()
-		logger.Error("sync failed", "error", syncErr)
+		meta.ErrorKind = quadsyncd.ErrorKind(syncErr)
+		logger.Error("sync failed", logging.Event(logging.EventSyncFailed), "error", syncErr, logging.KeyErrorKind, meta.ErrorKind)
 	} else {
 		meta.Status = runstore.RunStatusSuccess
 		logger.Info("sync completed successfully")
@@ -148,6 +328,7 @@ func (s *SyncService) executeSync(ctx context.Context, trigger runstore.TriggerS
 		for i, c := range result.Conflicts {
 			meta.Conflicts[i] = ConflictSummaryFromSync(c)
 		}
+		meta.Warnings = WarningSummariesFromSync(result.Warnings)
 	}
 
 	if runRecordCreated {
@@ -155,4 +336,8 @@ func (s *SyncService) executeSync(ctx context.Context, trigger runstore.TriggerS
 			logger.Error("failed to update run record", "error", err)
 		}
 	}
+
+	if s.onComplete != nil {
+		s.onComplete(result, syncErr)
+	}
 }
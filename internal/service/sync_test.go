@@ -10,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/schaermu/quadsyncd/internal/config"
 	"github.com/schaermu/quadsyncd/internal/git"
@@ -27,10 +28,12 @@ type mockRunner struct {
 	secretToLog string
 	logger      *slog.Logger
 	called      bool
+	actor       quadsyncd.Actor
 }
 
-func (m *mockRunner) Run(_ context.Context) (*quadsyncd.Result, error) {
+func (m *mockRunner) Run(ctx context.Context) (*quadsyncd.Result, error) {
 	m.called = true
+	m.actor = quadsyncd.ActorFromContext(ctx)
 	if m.secretToLog != "" && m.logger != nil {
 		m.logger.Info("connecting with secret", "token", m.secretToLog)
 	}
@@ -63,7 +66,18 @@ func newMockSyncService(t *testing.T, store *testutil.MockRunStore, factory quad
 		Sync: config.SyncConfig{Restart: config.RestartChanged},
 	}
 	logger := testutil.TestLogger()
-	return NewSyncService(cfg, factory, store, logger, []byte(secret))
+	return startSyncService(t, NewSyncService(cfg, factory, store, logger, []byte(secret)))
+}
+
+// startSyncService runs the worker of svc until the test ends.
+func startSyncService(t *testing.T, svc *SyncService) *SyncService {
+	t.Helper()
+	go svc.Run(context.Background())
+	t.Cleanup(func() {
+		svc.Close()
+		<-svc.Done()
+	})
+	return svc
 }
 
 // slowMockGitClient blocks EnsureCheckout until proceed is closed, allowing
@@ -74,7 +88,7 @@ type slowMockGitClient struct {
 	once    sync.Once
 }
 
-func (m *slowMockGitClient) EnsureCheckout(_ context.Context, _, _, _ string) (string, error) {
+func (m *slowMockGitClient) EnsureCheckout(_ context.Context, _, _, _ string, _ git.CheckoutOptions) (string, error) {
 	m.once.Do(func() { close(m.started) })
 	<-m.proceed
 	return "abc123", nil
@@ -125,12 +139,12 @@ func newTestSyncService(t *testing.T, gitClient git.Client) (*SyncService, *conf
 	)
 
 	svc := NewSyncService(cfg, factory, store, logger, []byte("test-secret"))
-	return svc, cfg
+	return startSyncService(t, svc), cfg
 }
 
-// TestSyncService_SingleFlight verifies that concurrent TriggerSync calls use
-// single-flight semantics: at most one sync runs at a time, at most one
-// additional run is queued, and excess concurrent calls are dropped.
+// TestSyncService_SingleFlight verifies that syncs run one at a time: while
+// a sync is running, at most one further sync is queued and additional
+// triggers are coalesced into it.
 func TestSyncService_SingleFlight(t *testing.T) {
 	syncStarted := make(chan struct{})
 	syncProceed := make(chan struct{})
@@ -139,53 +153,36 @@ func TestSyncService_SingleFlight(t *testing.T) {
 	svc, _ := newTestSyncService(t, slowGit)
 	ctx := context.Background()
 
-	// Start the first sync in a background goroutine; it will block until
-	// syncProceed is closed.
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
-		svc.TriggerSync(ctx, runstore.TriggerWebhook)
-	}()
-
-	// Wait until the first sync has entered the git checkout.
+	// Start the first sync; it blocks until syncProceed is closed.
+	if !svc.Enqueue(runstore.TriggerWebhook) {
+		t.Fatal("Enqueue() rejected the first trigger")
+	}
 	<-syncStarted
 
-	// Fire three more concurrent TriggerSync calls while the first is in-flight.
-	// Only one should queue a pending re-run; the other two should be dropped.
+	// Fire three more concurrent triggers while the first is in flight. One
+	// queues a re-run; the other two are coalesced into it.
 	var wg sync.WaitGroup
 	for i := 0; i < 3; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			svc.TriggerSync(ctx, runstore.TriggerWebhook)
+			svc.Enqueue(runstore.TriggerWebhook)
 		}()
 	}
 	wg.Wait()
 
-	// Exactly one pending sync should have been queued.
-	svc.mu.Lock()
-	pending := svc.pending
-	svc.mu.Unlock()
-
-	if !pending {
-		t.Error("expected pending to be true after concurrent TriggerSync calls")
+	st := svc.Status()
+	if !st.Running || st.Trigger != runstore.TriggerWebhook || st.Queued != 1 {
+		t.Errorf("Status() = %+v, want a running webhook sync and one queued", st)
 	}
 
-	// Allow the first sync to complete; the service should service the pending
-	// re-run automatically and then return.
+	// Let the first sync complete; the worker then runs the queued one.
 	close(syncProceed)
-	<-done
-
-	svc.mu.Lock()
-	stillRunning := svc.running
-	stillPending := svc.pending
-	svc.mu.Unlock()
-
-	if stillRunning {
-		t.Error("expected running to be false after all syncs completed")
+	if err := svc.Wait(ctx); err != nil {
+		t.Fatalf("Wait() = %v", err)
 	}
-	if stillPending {
-		t.Error("expected pending to be false after pending re-run was serviced")
+	if st := svc.Status(); st.Running || st.Queued != 0 {
+		t.Errorf("Status() after Wait = %+v, want idle", st)
 	}
 }
 
@@ -280,6 +277,113 @@ func TestExecuteSync_SyncError(t *testing.T) {
 	}
 }
 
+// TestSyncService_CloseDropsQueuedRun verifies that Close lets the running
+// sync finish but drops the sync queued behind it and later triggers, and
+// that Wait returns once the running sync is done.
+func TestSyncService_CloseDropsQueuedRun(t *testing.T) {
+	syncStarted := make(chan struct{})
+	syncProceed := make(chan struct{})
+	slowGit := &slowMockGitClient{started: syncStarted, proceed: syncProceed}
+	svc, cfg := newTestSyncService(t, slowGit)
+
+	svc.Enqueue(runstore.TriggerWebhook)
+	<-syncStarted
+	svc.Enqueue(runstore.TriggerWebhook) // queued
+
+	svc.Close()
+	if svc.Enqueue(runstore.TriggerWebhook) {
+		t.Error("Enqueue() accepted a trigger after Close")
+	}
+	if st := svc.Status(); !st.Running || st.Queued != 0 {
+		t.Errorf("Status() after Close = %+v, want the running sync only", st)
+	}
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := svc.Wait(waitCtx); err == nil {
+		t.Fatal("Wait() returned while a sync was running")
+	}
+
+	close(syncProceed)
+	if err := svc.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	<-svc.Done()
+
+	runs, err := runstore.NewStore(cfg.Paths.StateDir, testutil.TestLogger()).List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status == runstore.RunStatusRunning {
+		t.Errorf("runs = %+v, want only the running sync, finished", runs)
+	}
+}
+
+// blockingRunner runs until its context is done.
+type blockingRunner struct{}
+
+func (blockingRunner) Run(ctx context.Context) (*quadsyncd.Result, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("git fetch: %w", ctx.Err())
+}
+
+// TestExecuteSync_Timeout verifies that a run exceeding serve.sync_timeout is
+// cancelled and recorded as failed with the timeout error kind.
+func TestExecuteSync_Timeout(t *testing.T) {
+	store := testutil.NewMockRunStore()
+	factory := func(_ *config.Config, _ *slog.Logger, _ bool, _ *quadsyncd.PlanEngineOptions) quadsyncd.Runner {
+		return blockingRunner{}
+	}
+	svc := newMockSyncService(t, store, factory, "secret")
+	svc.cfg.Serve.SyncTimeout = 10 * time.Millisecond
+
+	svc.TriggerSync(context.Background(), runstore.TriggerWebhook)
+
+	runs, err := store.List(context.Background())
+	if err != nil {
+		t.Fatalf("store.List: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(runs))
+	}
+	if runs[0].Status != runstore.RunStatusError || runs[0].ErrorKind != quadsyncd.ErrorKindTimeout {
+		t.Errorf("run status = %q, error kind = %q; want error, %q", runs[0].Status, runs[0].ErrorKind, quadsyncd.ErrorKindTimeout)
+	}
+}
+
+// TestExecuteSync_OnComplete verifies the completion callback receives the
+// run outcome on both the instrumented and the fallback path.
+func TestExecuteSync_OnComplete(t *testing.T) {
+	for _, createFails := range []bool{false, true} {
+		t.Run(fmt.Sprintf("createFails=%t", createFails), func(t *testing.T) {
+			store := testutil.NewMockRunStore()
+			if createFails {
+				store.CreateFunc = func(_ context.Context, _ *runstore.RunMeta) error {
+					return fmt.Errorf("disk full")
+				}
+			}
+			want := &quadsyncd.Result{Revisions: map[string]string{"repo": "sha1"}}
+			wantErr := errors.New("restart failed")
+			mr := &mockRunner{result: want, err: wantErr}
+			svc := newMockSyncService(t, store, newMockRunnerFactory(mr), "secret")
+
+			calls := 0
+			svc.SetOnComplete(func(result *quadsyncd.Result, err error) {
+				calls++
+				if result != want || !errors.Is(err, wantErr) {
+					t.Errorf("onComplete(%v, %v), want (%v, %v)", result, err, want, wantErr)
+				}
+			})
+
+			svc.TriggerSync(context.Background(), runstore.TriggerWebhook)
+
+			if calls != 1 {
+				t.Errorf("onComplete called %d times, want 1", calls)
+			}
+		})
+	}
+}
+
 // TestExecuteSync_StoreCreateFails_FallbackRuns verifies the best-effort
 // fallback: when store.Create fails, sync still executes but without
 // instrumentation (no run record is stored).
@@ -481,3 +585,25 @@ func TestExecuteSync_TriggerSourcePreserved(t *testing.T) {
 		})
 	}
 }
+
+func TestSyncService_EnqueueDeliveryRecordsActor(t *testing.T) {
+	store := testutil.NewMockRunStore()
+	mr := &mockRunner{result: &quadsyncd.Result{}}
+	svc := newMockSyncService(t, store, newMockRunnerFactory(mr), "")
+
+	if !svc.EnqueueDelivery(runstore.TriggerWebhook, "delivery-1") {
+		t.Fatal("EnqueueDelivery() rejected the trigger")
+	}
+	if err := svc.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	runs, err := store.List(context.Background())
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("store.List() = %v, %v; want one run", runs, err)
+	}
+	want := quadsyncd.Actor{Trigger: string(runstore.TriggerWebhook), DeliveryID: "delivery-1", RunID: runs[0].ID}
+	if mr.actor != want {
+		t.Errorf("actor = %+v, want %+v", mr.actor, want)
+	}
+}
@@ -0,0 +1,265 @@
+// Package statebundle packs quadsyncd state into signed, versioned tarballs
+// for audits and for moving state to replacement hardware.
+//
+// A bundle is a gzip-compressed tar archive holding manifest.json, the files
+// it lists and, when signed, manifest.sig: an Ed25519 signature over the
+// exact bytes of manifest.json. The manifest records the SHA-256 of every
+// file, so verifying the signature covers the whole bundle.
+package statebundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// FormatVersion is the bundle format written by Write. Read rejects bundles
+// with a newer format.
+const FormatVersion = 1
+
+const (
+	manifestName  = "manifest.json"
+	signatureName = "manifest.sig"
+)
+
+// maxFileSize bounds a single bundle member, so a corrupt or hostile
+// archive cannot exhaust memory on import.
+const maxFileSize = 256 << 20
+
+// ErrUnsigned is returned by Verify for a bundle without a signature.
+var ErrUnsigned = errors.New("state bundle is not signed")
+
+// Manifest describes a bundle's contents.
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Hostname      string    `json:"hostname,omitempty"`
+	// Version is the quadsyncd version that wrote the bundle.
+	Version string      `json:"version,omitempty"`
+	Files   []FileEntry `json:"files"`
+}
+
+// FileEntry records one bundled file.
+type FileEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Bundle is a bundle read back by Read.
+type Bundle struct {
+	Manifest Manifest
+	// Files maps each manifest entry name to its content.
+	Files map[string][]byte
+
+	manifestRaw []byte
+	signature   []byte
+}
+
+// Signed reports whether the bundle carries a signature.
+func (b *Bundle) Signed() bool {
+	return len(b.signature) > 0
+}
+
+// Write writes a bundle holding files (name to content) to w. When key is
+// non-nil the manifest is signed with it.
+func Write(w io.Writer, files map[string][]byte, meta Manifest, key ed25519.PrivateKey) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name == manifestName || name == signatureName {
+			return fmt.Errorf("reserved bundle file name: %s", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	meta.FormatVersion = FormatVersion
+	meta.Files = nil
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		meta.Files = append(meta.Files, FileEntry{Name: name, Size: int64(len(files[name])), SHA256: hex.EncodeToString(sum[:])})
+	}
+	manifest, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: meta.CreatedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := add(manifestName, manifest); err != nil {
+		return err
+	}
+	if key != nil {
+		if err := add(signatureName, ed25519.Sign(key, manifest)); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if err := add(name, files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	return nil
+}
+
+// Read parses a bundle and checks that its files match the manifest. It
+// does not check the signature; call Verify for that.
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a state bundle: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	b := &Bundle{Files: make(map[string][]byte)}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected entry %s in bundle", hdr.Name)
+		}
+		if hdr.Size > maxFileSize {
+			return nil, fmt.Errorf("bundle entry %s is too large (%d bytes)", hdr.Name, hdr.Size)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxFileSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		switch hdr.Name {
+		case manifestName:
+			b.manifestRaw = data
+		case signatureName:
+			b.signature = data
+		default:
+			if _, dup := b.Files[hdr.Name]; dup {
+				return nil, fmt.Errorf("duplicate entry %s in bundle", hdr.Name)
+			}
+			b.Files[hdr.Name] = data
+		}
+	}
+
+	if b.manifestRaw == nil {
+		return nil, fmt.Errorf("bundle has no %s", manifestName)
+	}
+	if err := json.Unmarshal(b.manifestRaw, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", manifestName, err)
+	}
+	if b.Manifest.FormatVersion < 1 || b.Manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported state bundle format %d (this quadsyncd reads up to %d)", b.Manifest.FormatVersion, FormatVersion)
+	}
+
+	listed := make(map[string]bool)
+	for _, f := range b.Manifest.Files {
+		data, ok := b.Files[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("bundle is missing %s", f.Name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != f.SHA256 || int64(len(data)) != f.Size {
+			return nil, fmt.Errorf("bundle file %s does not match its manifest checksum", f.Name)
+		}
+		listed[f.Name] = true
+	}
+	for name := range b.Files {
+		if !listed[name] {
+			return nil, fmt.Errorf("bundle file %s is not listed in the manifest", name)
+		}
+	}
+	return b, nil
+}
+
+// Verify checks the manifest signature against pub. Together with the
+// checksums Read validated, a nil result means every file is as signed.
+func (b *Bundle) Verify(pub ed25519.PublicKey) error {
+	if !b.Signed() {
+		return ErrUnsigned
+	}
+	if !ed25519.Verify(pub, b.manifestRaw, b.signature) {
+		return fmt.Errorf("state bundle signature does not match the public key")
+	}
+	return nil
+}
+
+// LoadPrivateKey reads a PEM-encoded PKCS #8 Ed25519 private key, as written
+// by `openssl genpkey -algorithm ed25519`.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an Ed25519 key", path)
+	}
+	return ed, nil
+}
+
+// LoadPublicKey reads a PEM-encoded PKIX Ed25519 public key, as written by
+// `openssl pkey -pubout`.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an Ed25519 key", path)
+	}
+	return ed, nil
+}
+
+// readPEM returns the first PEM block in path.
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(bytes.TrimSpace(data))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
+}
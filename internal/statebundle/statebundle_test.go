@@ -0,0 +1,249 @@
+package statebundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func writeBundle(t *testing.T, files map[string][]byte, key ed25519.PrivateKey) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	meta := Manifest{CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Hostname: "host1", Version: "v1.2.3"}
+	if err := Write(&buf, files, meta, key); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// rewriteBundle re-packs a bundle, letting edit change each member.
+func rewriteBundle(t *testing.T, data []byte, edit func(name string, content []byte) []byte) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		var content bytes.Buffer
+		_, _ = content.ReadFrom(tr)
+		newContent := edit(hdr.Name, content.Bytes())
+		if newContent == nil {
+			continue
+		}
+		hdr.Size = int64(len(newContent))
+		_ = tw.WriteHeader(hdr)
+		_, _ = tw.Write(newContent)
+	}
+	_ = tw.Close()
+	_ = gw.Close()
+	return out.Bytes()
+}
+
+func TestWriteRead_Signed(t *testing.T) {
+	pub, priv := testKey(t)
+	files := map[string][]byte{"state.json": []byte(`{"commit":"abc"}`), "config.yaml": []byte("a: 1\n")}
+	b, err := Read(bytes.NewReader(writeBundle(t, files, priv)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !b.Signed() {
+		t.Fatal("bundle should be signed")
+	}
+	if err := b.Verify(pub); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if b.Manifest.FormatVersion != FormatVersion || b.Manifest.Hostname != "host1" || b.Manifest.Version != "v1.2.3" {
+		t.Errorf("manifest = %+v", b.Manifest)
+	}
+	if len(b.Manifest.Files) != 2 || b.Manifest.Files[0].Name != "config.yaml" {
+		t.Errorf("manifest files = %+v, want sorted entries", b.Manifest.Files)
+	}
+	if string(b.Files["state.json"]) != `{"commit":"abc"}` {
+		t.Errorf("state.json = %q", b.Files["state.json"])
+	}
+
+	otherPub, _ := testKey(t)
+	if err := b.Verify(otherPub); err == nil {
+		t.Error("Verify with another key should fail")
+	}
+}
+
+func TestVerify_Unsigned(t *testing.T) {
+	pub, _ := testKey(t)
+	b, err := Read(bytes.NewReader(writeBundle(t, map[string][]byte{"state.json": []byte("{}")}, nil)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if err := b.Verify(pub); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Verify = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestRead_Tampered(t *testing.T) {
+	pub, priv := testKey(t)
+	data := writeBundle(t, map[string][]byte{"state.json": []byte(`{"commit":"abc"}`)}, priv)
+
+	tests := []struct {
+		name    string
+		edit    func(name string, content []byte) []byte
+		wantErr string
+	}{
+		{
+			name: "file content changed",
+			edit: func(name string, c []byte) []byte {
+				if name == "state.json" {
+					return []byte(`{"commit":"evil"}`)
+				}
+				return c
+			},
+			wantErr: "does not match its manifest checksum",
+		},
+		{
+			name: "file removed",
+			edit: func(name string, c []byte) []byte {
+				if name == "state.json" {
+					return nil
+				}
+				return c
+			},
+			wantErr: "missing state.json",
+		},
+		{
+			name: "manifest changed",
+			edit: func(name string, c []byte) []byte {
+				if name == manifestName {
+					return bytes.Replace(c, []byte("host1"), []byte("host2"), 1)
+				}
+				return c
+			},
+			wantErr: "signature does not match",
+		},
+		{
+			name: "future format",
+			edit: func(name string, c []byte) []byte {
+				if name == manifestName {
+					return bytes.Replace(c, []byte(`"format_version": 1`), []byte(`"format_version": 99`), 1)
+				}
+				return c
+			},
+			wantErr: "unsupported state bundle format 99",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Read(bytes.NewReader(rewriteBundle(t, data, tt.edit)))
+			if err == nil {
+				err = b.Verify(pub)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRead_UnlistedFile(t *testing.T) {
+	data := writeBundle(t, map[string][]byte{"state.json": []byte("{}")}, nil)
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	gz, _ := gzip.NewReader(bytes.NewReader(data))
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		var c bytes.Buffer
+		_, _ = c.ReadFrom(tr)
+		_ = tw.WriteHeader(hdr)
+		_, _ = tw.Write(c.Bytes())
+	}
+	_ = tw.WriteHeader(&tar.Header{Name: "extra", Mode: 0600, Size: 1})
+	_, _ = tw.Write([]byte("x"))
+	_ = tw.Close()
+	_ = gw.Close()
+
+	if _, err := Read(&out); err == nil || !strings.Contains(err.Error(), "not listed") {
+		t.Errorf("Read = %v, want unlisted file error", err)
+	}
+}
+
+func TestWrite_ReservedName(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, map[string][]byte{manifestName: nil}, Manifest{}, nil); err == nil {
+		t.Error("expected an error for a reserved file name")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	pub, priv := testKey(t)
+	dir := t.TempDir()
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPath := filepath.Join(dir, "key.pem")
+	pubPath := filepath.Join(dir, "pub.pem")
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gotPriv, err := LoadPrivateKey(privPath)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey: %v", err)
+	}
+	if !gotPriv.Equal(priv) {
+		t.Error("private key mismatch")
+	}
+	gotPub, err := LoadPublicKey(pubPath)
+	if err != nil {
+		t.Fatalf("LoadPublicKey: %v", err)
+	}
+	if !gotPub.Equal(pub) {
+		t.Error("public key mismatch")
+	}
+
+	if _, err := LoadPublicKey(privPath); err == nil {
+		t.Error("loading a private key as public key should fail")
+	}
+	notPEM := filepath.Join(dir, "plain")
+	_ = os.WriteFile(notPEM, []byte("hello"), 0600)
+	if _, err := LoadPrivateKey(notPEM); err == nil || !strings.Contains(err.Error(), "no PEM data") {
+		t.Errorf("LoadPrivateKey(plain) = %v", err)
+	}
+}
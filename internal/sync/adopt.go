@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+)
+
+// AdoptStatus classifies an existing file considered for adoption.
+type AdoptStatus string
+
+// Adoption outcomes for a file found on disk.
+const (
+	// AdoptAdopted marks a file whose content matches the repositories; it
+	// is now recorded as managed.
+	AdoptAdopted AdoptStatus = "adopted"
+	// AdoptConflict marks a file at a repository path whose content differs;
+	// the next sync would overwrite it.
+	AdoptConflict AdoptStatus = "conflict"
+	// AdoptUnmatched marks a quadlet in the quadlet dir that no repository
+	// provides; it stays unmanaged and may duplicate a repository unit
+	// under another name.
+	AdoptUnmatched AdoptStatus = "unmatched"
+)
+
+// AdoptResult is the adoption outcome for one file.
+type AdoptResult struct {
+	Path     string      `json:"path"`
+	Status   AdoptStatus `json:"status"`
+	Expected string      `json:"expected_hash,omitempty"`
+	Actual   string      `json:"actual_hash,omitempty"`
+}
+
+// AdoptReport summarises an adoption pass.
+type AdoptReport struct {
+	// Managed counts repository files already recorded in the state.
+	Managed int `json:"already_managed"`
+	// Missing counts repository files not on disk yet; the next sync adds them.
+	Missing int           `json:"missing"`
+	Results []AdoptResult `json:"results"`
+}
+
+// Count returns the number of results with the given status.
+func (r *AdoptReport) Count(status AdoptStatus) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// String renders a one-line summary of the report.
+func (r *AdoptReport) String() string {
+	return fmt.Sprintf("%d adopted, %d conflicts, %d unmatched, %d already managed, %d missing",
+		r.Count(AdoptAdopted), r.Count(AdoptConflict), r.Count(AdoptUnmatched), r.Managed, r.Missing)
+}
+
+// Adopt records files that already exist at their destination with the
+// content the repositories would install as managed, without touching them.
+// Files whose content differs are reported as conflicts, and quadlets in the
+// quadlet dir that no repository provides as unmatched; neither is recorded.
+// This lets a host with hand-written quadlets switch to quadsyncd without
+// the first sync overwriting or, with sync.prune, deleting them unnoticed.
+// In dry-run mode the state is left unchanged.
+func (e *Engine) Adopt(ctx context.Context) (*AdoptReport, error) {
+	e.warnings = &warningLedger{}
+	defer func() {
+		clear(e.plaintext)
+		e.plaintext = nil
+		e.rendered = nil
+	}()
+
+	if err := os.MkdirAll(e.cfg.Paths.StateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	_, mergeResult, err := e.loadEffective(ctx, e.cfg.EffectiveRepositories())
+	if err != nil {
+		return nil, err
+	}
+	state, err := e.loadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	report := &AdoptReport{Results: []AdoptResult{}}
+	desired := make(map[string]bool, len(mergeResult.Items))
+	var adopted []FileOp
+	for _, item := range mergeResult.Items {
+		dest, err := e.destPath(item)
+		if err != nil {
+			return nil, err
+		}
+		desired[dest] = true
+		if _, ok := state.ManagedFiles[dest]; ok {
+			report.Managed++
+			continue
+		}
+
+		diskHash, err := fileHash(dest)
+		if errors.Is(err, os.ErrNotExist) {
+			report.Missing++
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", dest, err)
+		}
+		hash, _, err := e.sourceHash(ctx, item.AbsPath)
+		if err != nil {
+			return nil, err
+		}
+		if diskHash != hash {
+			report.Results = append(report.Results, AdoptResult{Path: dest, Status: AdoptConflict, Expected: hash, Actual: diskHash})
+			continue
+		}
+		report.Results = append(report.Results, AdoptResult{Path: dest, Status: AdoptAdopted, Expected: hash})
+		adopted = append(adopted, FileOp{
+			SourcePath:   item.AbsPath,
+			DestPath:     dest,
+			Hash:         hash,
+			SourceRepo:   item.SourceRepo,
+			SourceRef:    item.SourceRef,
+			SourceSHA:    item.SourceSHA,
+			SourceLayer:  item.SourceLayer,
+			RestartUnits: item.RestartUnits,
+		})
+	}
+
+	existing, err := quadlet.DiscoverFiles(e.cfg.Paths.QuadletDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to scan quadlet directory: %w", err)
+	}
+	for _, path := range existing {
+		if _, ok := state.ManagedFiles[path]; !ok && !desired[path] {
+			report.Results = append(report.Results, AdoptResult{Path: path, Status: AdoptUnmatched})
+		}
+	}
+	sort.Slice(report.Results, func(i, j int) bool { return report.Results[i].Path < report.Results[j].Path })
+
+	for _, res := range report.Results {
+		switch res.Status {
+		case AdoptAdopted:
+			e.logger.Info("adopting existing file", "dest", res.Path, logging.KeyDryRun, e.dryRun)
+		case AdoptConflict:
+			e.logger.Warn("existing file differs from the repository and was not adopted", "dest", res.Path,
+				"remediation", "bring the file in line with the repository, or let the next sync overwrite it")
+		}
+	}
+
+	if e.dryRun || len(adopted) == 0 {
+		return report, nil
+	}
+	for _, op := range adopted {
+		state.ManagedFiles[op.DestPath] = e.managedFile(op)
+	}
+	if err := e.saveState(state); err != nil {
+		return nil, fmt.Errorf("failed to save state: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,111 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestAdopt(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	stateDir := filepath.Join(tmpDir, "state")
+
+	repoFiles := map[string]string{
+		"web.container": "[Container]\nImage=nginx\n",
+		"db.container":  "[Container]\nImage=postgres:16\n",
+		"new.container": "[Container]\nImage=app\n",
+		"web.env":       "PORT=80\n",
+	}
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			for name, content := range repoFiles {
+				_ = os.WriteFile(filepath.Join(destDir, name), []byte(content), 0644)
+			}
+		},
+	}
+	onDisk := map[string]string{
+		"web.container":    "[Container]\nImage=nginx\n",
+		"web.env":          "PORT=80\n",
+		"db.container":     "[Container]\nImage=postgres:15\n",
+		"old-db.container": "[Container]\nImage=postgres:15\n",
+	}
+	if err := os.MkdirAll(quadletDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range onDisk {
+		if err := os.WriteFile(filepath.Join(quadletDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: stateDir},
+		Sync:       config.SyncConfig{Prune: true},
+	}
+
+	// A dry run reports without recording anything.
+	dry := NewEngine(cfg, gitMock, &testutil.MockSystemd{}, testutil.TestLogger(), true)
+	report, err := dry.Adopt(context.Background())
+	if err != nil {
+		t.Fatalf("Adopt (dry run): %v", err)
+	}
+	if report.Count(AdoptAdopted) != 2 {
+		t.Errorf("dry run adopted = %d, want 2", report.Count(AdoptAdopted))
+	}
+	if _, err := os.Stat(cfg.StateFilePath()); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote state: %v", err)
+	}
+
+	engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{}, testutil.TestLogger(), false)
+	report, err = engine.Adopt(context.Background())
+	if err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+
+	want := map[string]AdoptStatus{
+		filepath.Join(quadletDir, "db.container"):     AdoptConflict,
+		filepath.Join(quadletDir, "old-db.container"): AdoptUnmatched,
+		filepath.Join(quadletDir, "web.container"):    AdoptAdopted,
+		filepath.Join(quadletDir, "web.env"):          AdoptAdopted,
+	}
+	if len(report.Results) != len(want) {
+		t.Fatalf("results = %+v, want %d entries", report.Results, len(want))
+	}
+	for _, res := range report.Results {
+		if want[res.Path] != res.Status {
+			t.Errorf("%s status = %q, want %q", res.Path, res.Status, want[res.Path])
+		}
+	}
+	if report.Missing != 1 || report.Managed != 0 {
+		t.Errorf("missing = %d, managed = %d; want 1, 0", report.Missing, report.Managed)
+	}
+
+	state, err := ReadStateFile(cfg.StateFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.ManagedFiles) != 2 {
+		t.Fatalf("managed files = %v, want web.container and web.env", state.ManagedFiles)
+	}
+	mf := state.ManagedFiles[filepath.Join(quadletDir, "web.container")]
+	if mf.SourcePath != "web.container" || mf.SourceRepo != "file:///test" || mf.SourceSHA != "abc123" {
+		t.Errorf("adopted entry = %+v", mf)
+	}
+
+	// Adopting again only counts the recorded files as managed.
+	report, err = engine.Adopt(context.Background())
+	if err != nil {
+		t.Fatalf("second Adopt: %v", err)
+	}
+	if report.Managed != 2 || report.Count(AdoptAdopted) != 0 {
+		t.Errorf("second pass: %s", report)
+	}
+}
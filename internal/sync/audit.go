@@ -0,0 +1,244 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+)
+
+// Actor describes who started a run, for the audit log.
+type Actor struct {
+	// Trigger is the run's trigger source, e.g. "timer", "cli", "webhook"
+	// or "ui".
+	Trigger string `json:"trigger"`
+	// Command is the CLI command for runs started from the command line,
+	// e.g. "sync" or "rollback".
+	Command string `json:"command,omitempty"`
+	// DeliveryID is the X-GitHub-Delivery of the webhook that queued the
+	// run.
+	DeliveryID string `json:"delivery_id,omitempty"`
+	// RunID is the run record, when one was created.
+	RunID string `json:"run_id,omitempty"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying actor, which the engine records in
+// the audit log.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor, or a zero Actor.
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// Audit actions.
+const (
+	AuditAdd             = "add"
+	AuditUpdate          = "update"
+	AuditDelete          = "delete"
+	AuditSecretCreate    = "secret_create"
+	AuditSecretUpdate    = "secret_update"
+	AuditSecretDelete    = "secret_delete"
+	AuditRestart         = "restart"
+	AuditRestartFailed   = "restart_failed"
+	AuditRestartDeferred = "restart_deferred"
+	AuditStart           = "start"
+)
+
+// AuditEntry is one record of the audit log: a change to a managed file or
+// secret, or the outcome of a unit restart.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Actor  Actor     `json:"actor"`
+	Action string    `json:"action"`
+	// Path is the destination of a file change.
+	Path string `json:"path,omitempty"`
+	// Secret is the podman secret of a secret change.
+	Secret string `json:"secret,omitempty"`
+	// Unit is the unit of a restart outcome.
+	Unit    string `json:"unit,omitempty"`
+	OldHash string `json:"old_hash,omitempty"`
+	NewHash string `json:"new_hash,omitempty"`
+	Repo    string `json:"repo,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AppendAudit appends entries to the audit log at path. The log is only
+// ever appended to; quadsyncd never truncates or rotates it.
+func AppendAudit(path string, entries []AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var data []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// fileAuditEntries returns the audit records of the file and secret changes
+// in plan, which was applied over prev.
+func fileAuditEntries(prev *State, plan *Plan) []AuditEntry {
+	var entries []AuditEntry
+	add := func(action string, op FileOp) {
+		e := AuditEntry{Action: action, Path: op.DestPath, NewHash: op.Hash, Repo: op.SourceRepo, Commit: op.SourceSHA}
+		if old, ok := prev.ManagedFiles[op.DestPath]; ok {
+			e.OldHash = old.Hash
+			if action == AuditDelete {
+				e.Repo, e.Commit = old.SourceRepo, old.SourceSHA
+			}
+		}
+		if action == AuditDelete {
+			e.NewHash = ""
+		}
+		entries = append(entries, e)
+	}
+	for _, op := range plan.Add {
+		add(AuditAdd, op)
+	}
+	for _, op := range plan.Update {
+		add(AuditUpdate, op)
+	}
+	for _, op := range plan.Delete {
+		add(AuditDelete, op)
+	}
+	for _, op := range plan.Secrets {
+		e := AuditEntry{Secret: op.Name, NewHash: op.Hash, Repo: op.SourceRepo, Commit: op.SourceSHA}
+		if old, ok := prev.Secrets[op.Name]; ok {
+			e.OldHash = old.Hash
+		}
+		switch op.Action {
+		case SecretCreate:
+			e.Action = AuditSecretCreate
+		case SecretUpdate:
+			e.Action = AuditSecretUpdate
+		case SecretDelete:
+			e.Action = AuditSecretDelete
+			e.NewHash = ""
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// restartAuditEntries returns the audit records of a restart pass.
+func restartAuditEntries(restarted []string, failed map[string]error, deferred, started []string) []AuditEntry {
+	var entries []AuditEntry
+	for _, unit := range restarted {
+		entries = append(entries, AuditEntry{Action: AuditRestart, Unit: unit})
+	}
+	failedUnits := make([]string, 0, len(failed))
+	for unit := range failed {
+		failedUnits = append(failedUnits, unit)
+	}
+	sort.Strings(failedUnits)
+	for _, unit := range failedUnits {
+		e := AuditEntry{Action: AuditRestartFailed, Unit: unit}
+		if failed[unit] != nil {
+			e.Error = failed[unit].Error()
+		}
+		entries = append(entries, e)
+	}
+	for _, unit := range deferred {
+		entries = append(entries, AuditEntry{Action: AuditRestartDeferred, Unit: unit})
+	}
+	for _, unit := range started {
+		entries = append(entries, AuditEntry{Action: AuditStart, Unit: unit})
+	}
+	return entries
+}
+
+// audit records entries in the audit log when audit.enabled is set,
+// stamping them with the time and the actor from ctx. Failures are warnings:
+// the changes are already made.
+func (e *Engine) audit(ctx context.Context, entries []AuditEntry) {
+	if !e.cfg.Audit.Enabled || len(entries) == 0 {
+		return
+	}
+	actor := ActorFromContext(ctx)
+	now := time.Now().UTC()
+	for i := range entries {
+		entries[i].Time = now
+		entries[i].Actor = actor
+	}
+	path := e.cfg.AuditLogPath()
+	if err := AppendAudit(path, entries); err != nil {
+		e.warn(WarnAuditNotRecorded, path, "failed to record changes in the audit log", "error", err)
+	}
+	if e.cfg.Audit.Journal {
+		if err := sendAuditJournal(entries); err != nil {
+			e.warn(WarnAuditNotRecorded, logging.JournalSocket, "failed to send audit records to the journal", "error", err)
+		}
+	}
+}
+
+// sendAuditJournal sends entries to the systemd journal, one entry each with
+// QUADSYNCD_-prefixed fields.
+func sendAuditJournal(entries []AuditEntry) error {
+	journal := make([]logging.JournalEntry, 0, len(entries))
+	for _, a := range entries {
+		subject := a.Path
+		if a.Secret != "" {
+			subject = "secret " + a.Secret
+		}
+		if a.Unit != "" {
+			subject = a.Unit
+		}
+		level := slog.LevelInfo
+		if a.Action == AuditRestartFailed {
+			level = slog.LevelWarn
+		}
+		journal = append(journal, logging.JournalEntry{
+			Message: fmt.Sprintf("audit: %s %s", a.Action, subject),
+			Level:   level,
+			Fields: map[string]string{
+				"QUADSYNCD_AUDIT":       "1",
+				"QUADSYNCD_ACTION":      a.Action,
+				"QUADSYNCD_TRIGGER":     a.Actor.Trigger,
+				"QUADSYNCD_COMMAND":     a.Actor.Command,
+				"QUADSYNCD_DELIVERY_ID": a.Actor.DeliveryID,
+				"QUADSYNCD_RUN_ID":      a.Actor.RunID,
+				"QUADSYNCD_PATH":        a.Path,
+				"QUADSYNCD_SECRET":      a.Secret,
+				"QUADSYNCD_OLD_HASH":    a.OldHash,
+				"QUADSYNCD_NEW_HASH":    a.NewHash,
+				"QUADSYNCD_REPO":        a.Repo,
+				"QUADSYNCD_COMMIT":      a.Commit,
+				"QUADSYNCD_ERROR":       a.Error,
+				"UNIT":                  a.Unit,
+			},
+		})
+	}
+	return logging.WriteJournal(logging.JournalSocket, journal)
+}
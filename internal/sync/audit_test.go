@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func readAudit(t *testing.T, path string) []AuditEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("decode audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAppendAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "audit.jsonl")
+
+	if err := AppendAudit(path, nil); err != nil {
+		t.Fatalf("AppendAudit(nil) error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("AppendAudit(nil) created the log: %v", err)
+	}
+	if err := AppendAudit(path, []AuditEntry{{Action: AuditAdd, Path: "/q/a.container"}}); err != nil {
+		t.Fatalf("AppendAudit() error = %v", err)
+	}
+	if err := AppendAudit(path, []AuditEntry{{Action: AuditDelete, Path: "/q/b.container"}, {Action: AuditRestart, Unit: "a.service"}}); err != nil {
+		t.Fatalf("AppendAudit() error = %v", err)
+	}
+
+	got := readAudit(t, path)
+	if len(got) != 3 || got[0].Action != AuditAdd || got[1].Action != AuditDelete || got[2].Unit != "a.service" {
+		t.Errorf("audit log = %+v, want the three entries in order", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("audit log mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestFileAuditEntries(t *testing.T) {
+	prev := &State{
+		ManagedFiles: map[string]ManagedFile{
+			"/q/b.container": {Hash: "b1", SourceRepo: "repo", SourceSHA: "old"},
+			"/q/c.container": {Hash: "c1", SourceRepo: "repo", SourceSHA: "old"},
+		},
+		Secrets: map[string]ManagedSecret{
+			"db": {Hash: "s1"},
+		},
+	}
+	plan := &Plan{
+		Add:    []FileOp{{DestPath: "/q/a.container", Hash: "a2", SourceRepo: "repo", SourceSHA: "new"}},
+		Update: []FileOp{{DestPath: "/q/b.container", Hash: "b2", SourceRepo: "repo", SourceSHA: "new"}},
+		Delete: []FileOp{{DestPath: "/q/c.container", Hash: "c1"}},
+		Secrets: []SecretOp{
+			{Name: "db", Action: SecretUpdate, Hash: "s2", SourceRepo: "repo", SourceSHA: "new"},
+			{Name: "api", Action: SecretCreate, Hash: "t2", SourceRepo: "repo", SourceSHA: "new"},
+		},
+	}
+
+	want := []AuditEntry{
+		{Action: AuditAdd, Path: "/q/a.container", NewHash: "a2", Repo: "repo", Commit: "new"},
+		{Action: AuditUpdate, Path: "/q/b.container", OldHash: "b1", NewHash: "b2", Repo: "repo", Commit: "new"},
+		{Action: AuditDelete, Path: "/q/c.container", OldHash: "c1", Repo: "repo", Commit: "old"},
+		{Action: AuditSecretUpdate, Secret: "db", OldHash: "s1", NewHash: "s2", Repo: "repo", Commit: "new"},
+		{Action: AuditSecretCreate, Secret: "api", NewHash: "t2", Repo: "repo", Commit: "new"},
+	}
+	if got := fileAuditEntries(prev, plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("fileAuditEntries() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRestartAuditEntries(t *testing.T) {
+	got := restartAuditEntries(
+		[]string{"a.service"},
+		map[string]error{"c.service": errors.New("boom"), "b.service": errors.New("bang")},
+		[]string{"d.service"},
+		[]string{"e.service"},
+	)
+	want := []AuditEntry{
+		{Action: AuditRestart, Unit: "a.service"},
+		{Action: AuditRestartFailed, Unit: "b.service", Error: "bang"},
+		{Action: AuditRestartFailed, Unit: "c.service", Error: "boom"},
+		{Action: AuditRestartDeferred, Unit: "d.service"},
+		{Action: AuditStart, Unit: "e.service"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restartAuditEntries() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestEngine_Run_Audit(t *testing.T) {
+	repo := map[string]string{"web.container": "[Container]\nImage=nginx:1\n"}
+	cfg, mockGit := newTestRepo(t, repo)
+	cfg.Sync.Restart = config.RestartChanged
+	cfg.Audit.Enabled = true
+	actor := Actor{Trigger: "webhook", DeliveryID: "d-1", RunID: "run-1"}
+	ctx := WithActor(context.Background(), actor)
+
+	if _, err := NewEngine(cfg, mockGit, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	repo["web.container"] = "[Container]\nImage=nginx:2\n"
+	mockGit.CommitHash = "def456"
+	if _, err := NewEngine(cfg, mockGit, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var files []AuditEntry
+	var restarts int
+	for _, e := range readAudit(t, cfg.AuditLogPath()) {
+		if e.Actor != actor || e.Time.IsZero() {
+			t.Errorf("entry %+v: want actor %+v and a time", e, actor)
+		}
+		switch e.Action {
+		case AuditAdd, AuditUpdate:
+			files = append(files, e)
+		case AuditRestart:
+			restarts++
+		}
+	}
+	if len(files) != 2 || files[0].Action != AuditAdd || files[1].Action != AuditUpdate {
+		t.Fatalf("file entries = %+v, want an add then an update", files)
+	}
+	if files[1].OldHash != files[0].NewHash || files[1].NewHash == files[0].NewHash || files[1].Commit != "def456" {
+		t.Errorf("update entry = %+v, want old hash %q and commit def456", files[1], files[0].NewHash)
+	}
+	// restart: changed restarts the unit after each sync.
+	if restarts != 2 {
+		t.Errorf("restart entries = %d, want 2", restarts)
+	}
+}
+
+func TestEngine_Run_AuditDisabled(t *testing.T) {
+	content := "[Container]\nImage=nginx:1\n"
+	cfg, mockGit := newFreezeTestConfig(t, content)
+
+	if _, err := NewEngine(cfg, mockGit, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := os.Stat(cfg.AuditLogPath()); !os.IsNotExist(err) {
+		t.Errorf("audit log written with audit disabled: %v", err)
+	}
+}
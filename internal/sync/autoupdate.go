@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+)
+
+// autoUpdater is implemented by the systemd clients that can run `podman
+// auto-update`. Engines with other clients skip sync.run_auto_update.
+type autoUpdater interface {
+	AutoUpdate(ctx context.Context) ([]systemduser.AutoUpdateReport, error)
+}
+
+// runAutoUpdate runs `podman auto-update` after a successful applied sync
+// when sync.run_auto_update is set. The units it updated are recorded in
+// the result. Updates podman reports as failed or rolled back, and a failing
+// command, are recorded as warnings: the sync itself succeeded.
+func (e *Engine) runAutoUpdate(ctx context.Context, result *Result) {
+	if !e.cfg.Sync.RunAutoUpdate {
+		return
+	}
+	au, ok := e.systemd.(autoUpdater)
+	if !ok {
+		e.logger.Debug("systemd client cannot run podman auto-update, skipping")
+		return
+	}
+
+	e.logger.Info("running podman auto-update")
+	reports, err := au.AutoUpdate(ctx)
+	for _, r := range reports {
+		switch r.Updated {
+		case "true":
+			e.logger.Info("container image auto-updated", logging.Event(logging.EventImageAutoUpdate),
+				logging.KeyUnit, r.Unit, "image", r.Image, "container", r.Container)
+			result.AutoUpdated = append(result.AutoUpdated, r.Unit)
+		case "failed", "rolled back":
+			e.warn(WarnAutoUpdateFailed, r.Unit, fmt.Sprintf("podman auto-update %s", r.Updated),
+				logging.KeyUnit, r.Unit, "image", r.Image, "container", r.Container)
+		}
+	}
+	sort.Strings(result.AutoUpdated)
+	if err != nil {
+		e.warn(WarnAutoUpdateFailed, "", "podman auto-update failed", "error", err)
+	}
+}
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// autoUpdatingSystemd is a mock systemd client that also runs podman
+// auto-update, like the real clients.
+type autoUpdatingSystemd struct {
+	*testutil.MockSystemd
+	reports []systemduser.AutoUpdateReport
+	err     error
+	calls   int
+}
+
+func (s *autoUpdatingSystemd) AutoUpdate(context.Context) ([]systemduser.AutoUpdateReport, error) {
+	s.calls++
+	return s.reports, s.err
+}
+
+func TestRun_AutoUpdate(t *testing.T) {
+	reports := []systemduser.AutoUpdateReport{
+		{Unit: "web.service", Image: "nginx:latest", Policy: "registry", Updated: "true"},
+		{Unit: "db.service", Image: "postgres:16", Policy: "registry", Updated: "rolled back"},
+		{Unit: "cache.service", Image: "redis:7", Policy: "registry", Updated: "true"},
+		{Unit: "proxy.service", Image: "caddy:2", Policy: "registry", Updated: "false"},
+	}
+	tests := []struct {
+		name         string
+		enabled      bool
+		dryRun       bool
+		err          error
+		wantCalls    int
+		wantUpdated  []string
+		wantWarnings int
+	}{
+		{name: "disabled"},
+		{name: "dry run", enabled: true, dryRun: true},
+		{name: "enabled", enabled: true, wantCalls: 1, wantUpdated: []string{"cache.service", "web.service"}, wantWarnings: 1},
+		{name: "command fails", enabled: true, err: errors.New("podman auto-update: exit status 125"),
+			wantCalls: 1, wantUpdated: []string{"cache.service", "web.service"}, wantWarnings: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "[Container]\nImage=nginx:latest\n"
+			cfg, mockGit := newFreezeTestConfig(t, content)
+			cfg.Sync.RunAutoUpdate = tt.enabled
+			systemd := &autoUpdatingSystemd{MockSystemd: &testutil.MockSystemd{Available: true}, reports: reports, err: tt.err}
+
+			result, err := NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), tt.dryRun).Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if systemd.calls != tt.wantCalls {
+				t.Errorf("auto-update ran %d times, want %d", systemd.calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(result.AutoUpdated, tt.wantUpdated) {
+				t.Errorf("AutoUpdated = %v, want %v", result.AutoUpdated, tt.wantUpdated)
+			}
+			if !tt.dryRun {
+				entries, err := ReadHistory(cfg.Paths.StateDir, 1)
+				if err != nil || len(entries) != 1 || !reflect.DeepEqual(entries[0].AutoUpdated, tt.wantUpdated) {
+					t.Errorf("history = %+v, %v; want auto_updated %v", entries, err, tt.wantUpdated)
+				}
+			}
+			var warnings int
+			for _, w := range result.Warnings {
+				if w.Code == WarnAutoUpdateFailed {
+					warnings++
+				}
+			}
+			if warnings != tt.wantWarnings {
+				t.Errorf("auto_update_failed warnings = %d, want %d: %+v", warnings, tt.wantWarnings, result.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+)
+
+// backupMetaFile is the per-backup metadata document.
+const backupMetaFile = "backup.json"
+
+// Backup is a snapshot of the managed file set as of one synced commit. File
+// contents are stored content-addressed under files/<sha256>.
+type Backup struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	State     *State    `json:"state"`
+}
+
+// BackupsDir returns the directory holding all backups for stateDir.
+func BackupsDir(stateDir string) string {
+	return filepath.Join(stateDir, "backups")
+}
+
+// SnapshotID identifies the synced revision a state represents: the commit SHA
+// in single-repo mode, or a short digest of all repo revisions otherwise.
+func SnapshotID(state *State) string {
+	if state.Commit != "" {
+		return state.Commit
+	}
+	if len(state.Revisions) == 0 {
+		return ""
+	}
+	urls := make([]string, 0, len(state.Revisions))
+	for url := range state.Revisions {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	h := sha256.New()
+	for _, url := range urls {
+		_, _ = fmt.Fprintf(h, "%s=%s\n", url, state.Revisions[url])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// backupState copies the on-disk content of every file managed in state into
+// state_dir/backups/<id>/ and prunes old backups beyond the configured
+// retention. Content is hashed as found on disk, so drifted files are
+// restored to what was actually live.
+func (e *Engine) backupState(state *State) error {
+	id := SnapshotID(state)
+	if id == "" || len(state.ManagedFiles) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(BackupsDir(e.cfg.Paths.StateDir), id)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to reset backup %s: %w", id, err)
+	}
+	filesDir := filepath.Join(dir, "files")
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	snapshot := &State{
+		Version:      StateVersion,
+		Commit:       state.Commit,
+		Revisions:    state.Revisions,
+		ManagedFiles: make(map[string]ManagedFile, len(state.ManagedFiles)),
+	}
+	for dest, mf := range state.ManagedFiles {
+		hash, err := fileHash(dest)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Already gone; nothing to preserve.
+				continue
+			}
+			return fmt.Errorf("failed to hash %s for backup: %w", dest, err)
+		}
+		if err := e.copyFile(dest, filepath.Join(filesDir, hash)); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", dest, err)
+		}
+		mf.Hash = hash
+		snapshot.ManagedFiles[dest] = mf
+	}
+
+	data, err := json.MarshalIndent(Backup{ID: id, CreatedAt: time.Now().UTC(), State: snapshot}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, backupMetaFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write backup metadata: %w", err)
+	}
+	e.logger.Info("backed up managed files", logging.Event(logging.EventBackupCreated), logging.KeyBackup, id, "files", len(snapshot.ManagedFiles))
+
+	return e.pruneBackups()
+}
+
+// pruneBackups removes the oldest backups beyond sync.backup_retention.
+func (e *Engine) pruneBackups() error {
+	backups, err := ListBackups(e.cfg.Paths.StateDir)
+	if err != nil {
+		return err
+	}
+	for i := e.cfg.Sync.BackupRetention; i < len(backups); i++ {
+		e.logger.Info("removing old backup", logging.Event(logging.EventBackupPruned), logging.KeyBackup, backups[i].ID)
+		if err := os.RemoveAll(filepath.Join(BackupsDir(e.cfg.Paths.StateDir), backups[i].ID)); err != nil {
+			return fmt.Errorf("failed to remove backup %s: %w", backups[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// ListBackups returns all backups under stateDir, newest first. A missing
+// backups directory yields an empty list.
+func ListBackups(stateDir string) ([]Backup, error) {
+	entries, err := os.ReadDir(BackupsDir(stateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backups directory: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		b, err := readBackup(filepath.Join(BackupsDir(stateDir), entry.Name()))
+		if err != nil {
+			// Half-written backups are skipped rather than failing the listing.
+			continue
+		}
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+func readBackup(dir string) (Backup, error) {
+	var b Backup
+	data, err := os.ReadFile(filepath.Join(dir, backupMetaFile))
+	if err != nil {
+		return b, err
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, err
+	}
+	if b.State == nil {
+		return b, fmt.Errorf("backup %s has no state", dir)
+	}
+	if err := migrateState(b.State); err != nil {
+		return b, fmt.Errorf("backup %s: %w", dir, err)
+	}
+	return b, nil
+}
+
+// findBackup resolves id, which may be an unambiguous prefix (e.g. a short
+// commit SHA), to a backup.
+func findBackup(stateDir, id string) (Backup, error) {
+	backups, err := ListBackups(stateDir)
+	if err != nil {
+		return Backup{}, err
+	}
+	var matches []Backup
+	for _, b := range backups {
+		if b.ID == id {
+			return b, nil
+		}
+		if strings.HasPrefix(b.ID, id) {
+			matches = append(matches, b)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Backup{}, fmt.Errorf("no backup found for %q", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return Backup{}, fmt.Errorf("backup id %q is ambiguous (%d matches)", id, len(matches))
+	}
+}
+
+// Restore rolls the managed files back to the backup identified by id (a
+// commit SHA or unambiguous prefix). The rollback goes through the same
+// stage/validate/apply path as a sync, after which systemd is reloaded and
+// affected units are restarted per the restart policy. The current state is
+// backed up first when backups are enabled, so a restore can be undone.
+func (e *Engine) Restore(ctx context.Context, id string) (*Result, error) {
+	backup, err := findBackup(e.cfg.Paths.StateDir, id)
+	if err != nil {
+		return nil, err
+	}
+	e.logger.Info("restoring backup", logging.Event(logging.EventRestoreStarted), logging.KeyBackup, backup.ID, "created_at", backup.CreatedAt)
+
+	current, err := e.loadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current state: %w", err)
+	}
+
+	filesDir := filepath.Join(BackupsDir(e.cfg.Paths.StateDir), backup.ID, "files")
+	plan := &Plan{Add: []FileOp{}, Update: []FileOp{}, Delete: []FileOp{}}
+	for dest, mf := range backup.State.ManagedFiles {
+		op := FileOp{
+			SourcePath:   filepath.Join(filesDir, mf.Hash),
+			DestPath:     dest,
+			Hash:         mf.Hash,
+			SourceRepo:   mf.SourceRepo,
+			SourceRef:    mf.SourceRef,
+			SourceSHA:    mf.SourceSHA,
+			SourceLayer:  mf.SourceLayer,
+			RestartUnits: mf.RestartUnits,
+		}
+		diskHash, err := fileHash(dest)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			plan.Add = append(plan.Add, op)
+		case err != nil:
+			return nil, fmt.Errorf("failed to hash %s: %w", dest, err)
+		case diskHash != mf.Hash:
+			plan.Update = append(plan.Update, op)
+		}
+	}
+	for dest, mf := range current.ManagedFiles {
+		if _, ok := backup.State.ManagedFiles[dest]; !ok {
+			plan.Delete = append(plan.Delete, FileOp{DestPath: dest, RestartUnits: mf.RestartUnits})
+		}
+	}
+	sort.Slice(plan.Add, func(i, j int) bool { return plan.Add[i].DestPath < plan.Add[j].DestPath })
+	sort.Slice(plan.Update, func(i, j int) bool { return plan.Update[i].DestPath < plan.Update[j].DestPath })
+	sort.Slice(plan.Delete, func(i, j int) bool { return plan.Delete[i].DestPath < plan.Delete[j].DestPath })
+
+	result := &Result{Revisions: backup.State.Revisions, Plan: plan}
+	if len(plan.Add)+len(plan.Update)+len(plan.Delete) == 0 {
+		e.logger.Info("managed files already match backup", logging.KeyBackup, backup.ID)
+		return result, nil
+	}
+	if e.dryRun {
+		e.logPlanDetails(plan)
+		return result, nil
+	}
+
+	if err := e.preflightDestinations(plan); err != nil {
+		return nil, err
+	}
+	if e.cfg.Sync.BackupRetention > 0 && SnapshotID(current) != backup.ID {
+		if err := e.backupState(current); err != nil {
+			return nil, fmt.Errorf("failed to back up current state: %w", err)
+		}
+	}
+
+	if err := e.applyPlan(ctx, plan, nil); err != nil {
+		return nil, err
+	}
+	backup.State.PendingRestarts = current.PendingRestarts
+	if err := e.saveState(backup.State); err != nil {
+		return nil, fmt.Errorf("failed to save state: %w", err)
+	}
+	result.Applied = true
+	e.audit(ctx, fileAuditEntries(current, plan))
+
+	if err := e.systemd.DaemonReload(ctx); err != nil {
+		return nil, fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	e.restartUnits(ctx, plan, backup.State, time.Now(), result)
+
+	e.logger.Info("restore completed", logging.Event(logging.EventRestoreCompleted), logging.KeyBackup, backup.ID)
+	return result, nil
+}
@@ -0,0 +1,206 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestSnapshotID(t *testing.T) {
+	if got := SnapshotID(&State{Commit: "abc123"}); got != "abc123" {
+		t.Errorf("single-repo id = %q, want commit", got)
+	}
+	if got := SnapshotID(&State{}); got != "" {
+		t.Errorf("empty state id = %q, want empty", got)
+	}
+	a := SnapshotID(&State{Revisions: map[string]string{"r1": "a", "r2": "b"}})
+	b := SnapshotID(&State{Revisions: map[string]string{"r2": "b", "r1": "a"}})
+	c := SnapshotID(&State{Revisions: map[string]string{"r1": "a", "r2": "c"}})
+	if a != b || len(a) != 12 {
+		t.Errorf("multi-repo id should be a stable 12-char digest: %q vs %q", a, b)
+	}
+	if a == c {
+		t.Error("different revisions must produce different ids")
+	}
+}
+
+// backupTestRepo lets a test swap the repo content served by the mock client.
+type backupTestRepo struct {
+	files map[string]string
+}
+
+func (r *backupTestRepo) setup(destDir string) {
+	_ = os.RemoveAll(destDir)
+	_ = os.MkdirAll(destDir, 0755)
+	for name, content := range r.files {
+		_ = os.WriteFile(filepath.Join(destDir, name), []byte(content), 0644)
+	}
+}
+
+func TestRun_BackupAndRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	stateDir := filepath.Join(tmpDir, "state")
+
+	repo := &backupTestRepo{files: map[string]string{
+		"web.container": "web v1",
+		"db.container":  "db v1",
+	}}
+	gitMock := &testutil.MockGitClient{CommitHash: "aaaa1111", RepoSetup: repo.setup}
+	sd := &testutil.MockSystemd{Available: true}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: stateDir},
+		Sync:       config.SyncConfig{Prune: true, Restart: config.RestartChanged, BackupRetention: 2},
+	}
+	newEngine := func() *Engine { return NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false) }
+
+	if _, err := newEngine().Run(context.Background()); err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	// Nothing was managed before the first sync, so there is nothing to back up.
+	if backups, _ := ListBackups(stateDir); len(backups) != 0 {
+		t.Fatalf("expected no backups after first sync, got %d", len(backups))
+	}
+
+	repo.files = map[string]string{
+		"web.container": "web v2",
+		"new.container": "new",
+	}
+	gitMock.CommitHash = "bbbb2222"
+	if _, err := newEngine().Run(context.Background()); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+
+	backups, err := ListBackups(stateDir)
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if len(backups) != 1 || backups[0].ID != "aaaa1111" {
+		t.Fatalf("expected one backup for aaaa1111, got %+v", backups)
+	}
+
+	result, err := newEngine().Restore(context.Background(), "aaaa")
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if !result.Applied {
+		t.Error("expected restore to apply changes")
+	}
+
+	assertContent := func(name, want string) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(quadletDir, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q (%v), want %q", name, data, err, want)
+		}
+	}
+	assertContent("web.container", "web v1")
+	assertContent("db.container", "db v1")
+	if _, err := os.Stat(filepath.Join(quadletDir, "new.container")); !os.IsNotExist(err) {
+		t.Error("file added after the backup should be removed on restore")
+	}
+
+	wantUnits := []string{"db.service", "new.service", "web.service"}
+	if !reflect.DeepEqual(result.RestartedUnits, wantUnits) {
+		t.Errorf("RestartedUnits = %v, want %v", result.RestartedUnits, wantUnits)
+	}
+
+	state, err := newEngine().loadState()
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if state.Commit != "aaaa1111" {
+		t.Errorf("state commit = %q, want aaaa1111", state.Commit)
+	}
+
+	// Restoring backed up the pre-restore state, so the rollback can be undone.
+	backups, _ = ListBackups(stateDir)
+	ids := make([]string, len(backups))
+	for i, b := range backups {
+		ids[i] = b.ID
+	}
+	if !reflect.DeepEqual(ids, []string{"bbbb2222", "aaaa1111"}) {
+		t.Errorf("backups after restore = %v, want [bbbb2222 aaaa1111]", ids)
+	}
+}
+
+func TestBackupRetention(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	stateDir := filepath.Join(tmpDir, "state")
+
+	repo := &backupTestRepo{files: map[string]string{"web.container": "v0"}}
+	gitMock := &testutil.MockGitClient{RepoSetup: repo.setup}
+	sd := &testutil.MockSystemd{Available: true}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: stateDir},
+		Sync:       config.SyncConfig{Restart: config.RestartNone, BackupRetention: 2},
+	}
+
+	for i, commit := range []string{"c0", "c1", "c2", "c3"} {
+		repo.files["web.container"] = "v" + commit
+		gitMock.CommitHash = commit
+		if _, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+			t.Fatalf("sync %d: %v", i, err)
+		}
+		// Keep CreatedAt strictly ordered on coarse clocks.
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	backups, err := ListBackups(stateDir)
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	ids := make([]string, len(backups))
+	for i, b := range backups {
+		ids[i] = b.ID
+	}
+	if !reflect.DeepEqual(ids, []string{"c2", "c1"}) {
+		t.Errorf("retained backups = %v, want [c2 c1]", ids)
+	}
+}
+
+func TestFindBackup(t *testing.T) {
+	stateDir := t.TempDir()
+	cfg := &config.Config{Paths: config.PathsConfig{StateDir: stateDir}, Sync: config.SyncConfig{BackupRetention: 5}}
+	engine := &Engine{cfg: cfg, logger: testutil.TestLogger()}
+
+	src := filepath.Join(stateDir, "f")
+	if err := os.WriteFile(src, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, commit := range []string{"abc111", "abc222", "def333"} {
+		if err := engine.backupState(&State{Commit: commit, ManagedFiles: map[string]ManagedFile{src: {}}}); err != nil {
+			t.Fatalf("backupState: %v", err)
+		}
+	}
+
+	tests := []struct {
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{id: "def333", want: "def333"},
+		{id: "def", want: "def333"},
+		{id: "abc", wantErr: true},
+		{id: "zzz", wantErr: true},
+	}
+	for _, tt := range tests {
+		b, err := findBackup(stateDir, tt.id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("findBackup(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && b.ID != tt.want {
+			t.Errorf("findBackup(%q) = %q, want %q", tt.id, b.ID, tt.want)
+		}
+	}
+}
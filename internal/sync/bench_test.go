@@ -0,0 +1,174 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/schaermu/quadsyncd/internal/multirepo"
+	"github.com/schaermu/quadsyncd/internal/synthrepo"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// benchSizes are the repository sizes every benchmark runs at.
+var benchSizes = []int{1000, 10000}
+
+// benchFixture is a generated repository plus an engine configured to sync it.
+type benchFixture struct {
+	engine *Engine
+	repo   string
+	items  []multirepo.EffectiveItem
+}
+
+func newBenchFixture(b *testing.B, files int) *benchFixture {
+	b.Helper()
+	tmp := b.TempDir()
+	repo := filepath.Join(tmp, "repo")
+	if err := synthrepo.Generate(repo, synthrepo.Options{Files: files}); err != nil {
+		b.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///bench", Ref: "main"},
+		Paths: config.PathsConfig{
+			QuadletDir: filepath.Join(tmp, "quadlet"),
+			StateDir:   filepath.Join(tmp, "state"),
+		},
+		Sync: config.SyncConfig{Prune: true, Restart: config.RestartNone},
+	}
+	logger := slog.New(slog.DiscardHandler)
+	engine := NewEngine(cfg, nil, &testutil.MockSystemd{Available: true}, logger, false)
+
+	ctx := context.Background()
+	rs, err := multirepo.LoadRepoState(ctx, *cfg.Repository, repo, repo, git.CheckoutOptions{}, &testutil.MockGitClient{CommitHash: synthrepo.Commit(0)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	merged, err := multirepo.Merge([]multirepo.RepoState{rs}, config.ConflictPreferHighestPriority)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &benchFixture{engine: engine, repo: repo, items: merged.Items}
+}
+
+// freshDirs points the engine at empty quadlet and state dirs.
+func (f *benchFixture) freshDirs(b *testing.B) {
+	b.Helper()
+	tmp := b.TempDir()
+	f.engine.cfg.Paths.QuadletDir = filepath.Join(tmp, "quadlet")
+	f.engine.cfg.Paths.StateDir = filepath.Join(tmp, "state")
+	if err := os.MkdirAll(f.engine.cfg.Paths.QuadletDir, 0755); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkFileHash(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			f := newBenchFixture(b, n)
+			for b.Loop() {
+				for _, item := range f.items {
+					if _, err := fileHash(item.AbsPath); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// benchWorkers are the plan-building worker counts BenchmarkBuildPlan
+// compares: sequential hashing against the default pool.
+var benchWorkers = []struct {
+	name    string
+	workers int
+}{
+	{"sequential", 1},
+	{"parallel", 0},
+}
+
+func BenchmarkBuildPlan(b *testing.B) {
+	empty := &State{ManagedFiles: map[string]ManagedFile{}}
+
+	for _, n := range benchSizes {
+		for _, w := range benchWorkers {
+			b.Run(fmt.Sprintf("fresh/%s/files=%d", w.name, n), func(b *testing.B) {
+				f := newBenchFixture(b, n)
+				f.freshDirs(b)
+				f.engine.planWorkers = w.workers
+				for b.Loop() {
+					if _, err := f.engine.buildPlanFromEffective(context.Background(), empty, f.items); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+
+			// noop re-plans an applied sync, where the stat fast path skips
+			// hashing the installed files; noop-rehash drops the recorded
+			// stats so every installed file is hashed again.
+			for _, rehash := range []bool{false, true} {
+				name := "noop"
+				if rehash {
+					name = "noop-rehash"
+				}
+				b.Run(fmt.Sprintf("%s/%s/files=%d", name, w.name, n), func(b *testing.B) {
+					f := newBenchFixture(b, n)
+					f.freshDirs(b)
+					f.engine.planWorkers = w.workers
+					plan, err := f.engine.buildPlanFromEffective(context.Background(), empty, f.items)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := f.engine.applyPlan(context.Background(), plan, nil); err != nil {
+						b.Fatal(err)
+					}
+					state := f.engine.buildStateFromEffective(empty, plan, nil)
+					if rehash {
+						for dest, mf := range state.ManagedFiles {
+							mf.Size, mf.ModTime = 0, 0
+							state.ManagedFiles[dest] = mf
+						}
+					}
+
+					for b.Loop() {
+						plan, err := f.engine.buildPlanFromEffective(context.Background(), state, f.items)
+						if err != nil {
+							b.Fatal(err)
+						}
+						if len(plan.Add)+len(plan.Update)+len(plan.Delete) != 0 {
+							b.Fatalf("expected empty plan, got %d/%d/%d", len(plan.Add), len(plan.Update), len(plan.Delete))
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkApplyPlan(b *testing.B) {
+	empty := &State{ManagedFiles: map[string]ManagedFile{}}
+
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			f := newBenchFixture(b, n)
+			for b.Loop() {
+				b.StopTimer()
+				f.freshDirs(b)
+				plan, err := f.engine.buildPlanFromEffective(context.Background(), empty, f.items)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+
+				if err := f.engine.applyPlan(context.Background(), plan, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/schaermu/quadsyncd/internal/cosign"
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/multirepo"
+)
+
+// blobVerifier checks detached signatures of files; implemented by
+// cosign.CLI.
+type blobVerifier interface {
+	VerifyBlob(ctx context.Context, key, signature, path string) error
+}
+
+// verifyChecksums checks the files of rs against the checksum manifest in
+// its checkout when checksums.verify is set. With checksums.cosign_key the
+// manifest's signature is verified first, so a manifest rewritten along
+// with the files it lists is rejected too.
+func (e *Engine) verifyChecksums(ctx context.Context, rs multirepo.RepoState) error {
+	c := rs.Spec.Checksums
+	if !c.Verify {
+		return nil
+	}
+	if c.CosignKey != "" {
+		manifest := filepath.Join(rs.Dir, multirepo.ChecksumFileName)
+		signature := filepath.Join(rs.Dir, multirepo.ChecksumSignatureName)
+		if _, err := os.Stat(signature); err != nil {
+			return fmt.Errorf("checksums.cosign_key requires %s: %w", multirepo.ChecksumSignatureName, err)
+		}
+		verifier := e.blobVerifier
+		if verifier == nil {
+			verifier = cosign.NewCLI(e.cfg.Timeouts.Git)
+		}
+		if err := verifier.VerifyBlob(ctx, c.CosignKey, signature, manifest); err != nil {
+			return fmt.Errorf("signature verification of %s failed: %w", multirepo.ChecksumFileName, err)
+		}
+	}
+	if err := multirepo.VerifyChecksums(rs); err != nil {
+		return err
+	}
+	e.logger.Info("verified repository checksums", "repo", rs.Spec.URL, logging.KeyCommit, rs.Commit,
+		"files", len(rs.Files), "signed", c.CosignKey != "")
+	return nil
+}
@@ -0,0 +1,97 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/multirepo"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// fakeBlobVerifier accepts signatures unless err is set.
+type fakeBlobVerifier struct {
+	err      error
+	verified []string
+}
+
+func (v *fakeBlobVerifier) VerifyBlob(_ context.Context, _, _, path string) error {
+	v.verified = append(v.verified, filepath.Base(path))
+	return v.err
+}
+
+func TestRun_VerifiesChecksums(t *testing.T) {
+	const web = "[Container]\nImage=nginx\n[Install]\nWantedBy=default.target\n"
+	sum := sha256.Sum256([]byte(web))
+	manifest := hex.EncodeToString(sum[:]) + "  web.container\n"
+
+	tests := []struct {
+		name      string
+		content   string
+		signature bool
+		verifyErr error
+		wantErr   string
+	}{
+		{name: "valid", content: web, signature: true},
+		{name: "tampered file", content: web + "Exec=sh\n", signature: true, wantErr: "web.container does not match"},
+		{name: "bad signature", content: web, signature: true, verifyErr: errors.New("invalid signature"), wantErr: "signature verification"},
+		{name: "missing signature", content: web, wantErr: "requires MANIFEST.sha256.sig"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			quadletDir := filepath.Join(tmpDir, "quadlet")
+			gitMock := &testutil.MockGitClient{
+				CommitHash: "abc123",
+				RepoSetup: func(destDir string) {
+					_ = os.MkdirAll(destDir, 0755)
+					_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte(tt.content), 0644)
+					_ = os.WriteFile(filepath.Join(destDir, multirepo.ChecksumFileName), []byte(manifest), 0644)
+					if tt.signature {
+						_ = os.WriteFile(filepath.Join(destDir, multirepo.ChecksumSignatureName), []byte("c2ln\n"), 0644)
+					}
+				},
+			}
+			cfg := &config.Config{
+				Repository: &config.RepoSpec{URL: "file:///test", Ref: "main",
+					Checksums: config.ChecksumConfig{Verify: true, CosignKey: "/etc/quadsyncd/cosign.pub"}},
+				Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
+				Sync:  config.SyncConfig{Restart: config.RestartChanged},
+			}
+			engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+			verifier := &fakeBlobVerifier{err: tt.verifyErr}
+			engine.blobVerifier = verifier
+
+			_, err := engine.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, statErr := os.Stat(filepath.Join(quadletDir, "web.container")); !os.IsNotExist(statErr) {
+					t.Errorf("file installed despite failed verification: %v", statErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if len(verifier.verified) != 1 || verifier.verified[0] != multirepo.ChecksumFileName {
+				t.Errorf("verified = %v, want the manifest", verifier.verified)
+			}
+			if _, err := os.Stat(filepath.Join(quadletDir, "web.container")); err != nil {
+				t.Errorf("web.container not installed: %v", err)
+			}
+			for _, name := range []string{multirepo.ChecksumFileName, multirepo.ChecksumSignatureName} {
+				if _, err := os.Stat(filepath.Join(quadletDir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s synced to the quadlet dir: %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	gosync "sync"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+)
+
+// podmanVersioner is implemented by the systemd clients that run Podman's
+// quadlet generator. Engines with other clients, such as the stub used off
+// Linux, skip the compatibility check.
+type podmanVersioner interface {
+	PodmanVersion(ctx context.Context) (string, error)
+}
+
+// detectedPodman caches the installed Podman version for the life of the
+// process, so a long-running server detects it once, at its first sync.
+var detectedPodman struct {
+	mu      gosync.Mutex
+	version *quadlet.Version
+}
+
+// podmanVersion returns the installed Podman version, detecting it with pv
+// on first use. A failed detection is not cached, so a later sync tries
+// again.
+func (e *Engine) podmanVersion(ctx context.Context, pv podmanVersioner) (quadlet.Version, error) {
+	detectedPodman.mu.Lock()
+	defer detectedPodman.mu.Unlock()
+	if detectedPodman.version != nil {
+		return *detectedPodman.version, nil
+	}
+	out, err := pv.PodmanVersion(ctx)
+	if err != nil {
+		return quadlet.Version{}, err
+	}
+	v, err := quadlet.ParseVersion(out)
+	if err != nil {
+		return quadlet.Version{}, fmt.Errorf("podman --version: %w", err)
+	}
+	e.logger.Info("detected podman version", logging.Event(logging.EventPodmanDetected), "version", v.String())
+	detectedPodman.version = &v
+	return v, nil
+}
+
+// checkPodmanCompat checks the quadlets the plan installs against the
+// installed Podman version, per sync.podman_compat. Unsupported file types
+// and keys are recorded as warnings; in fail mode an applying sync fails
+// instead, before anything is changed. When the version cannot be detected
+// the check is skipped.
+func (e *Engine) checkPodmanCompat(ctx context.Context, plan *Plan) error {
+	pv, ok := e.systemd.(podmanVersioner)
+	if !ok || e.cfg.Sync.PodmanCompat == config.PodmanCompatOff {
+		return nil
+	}
+	var ops []FileOp
+	for _, op := range append(append([]FileOp{}, plan.Add...), plan.Update...) {
+		if quadlet.IsQuadletFile(op.DestPath) && !op.Encrypted {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	version, err := e.podmanVersion(ctx, pv)
+	if err != nil {
+		e.logger.Debug("skipping podman compatibility check", "error", err)
+		return nil
+	}
+
+	var issues []quadlet.Issue
+	for _, op := range ops {
+		var u *quadlet.Unit
+		if op.Rendered != nil {
+			u, err = quadlet.Parse(bytes.NewReader(op.Rendered))
+		} else {
+			u, err = quadlet.ParseFile(op.SourcePath)
+		}
+		if err != nil {
+			// Syntax errors are reported by quadlet validation.
+			continue
+		}
+		issues = append(issues, quadlet.CheckCompat(op.DestPath, u, version)...)
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+
+	if e.cfg.Sync.PodmanCompat == config.PodmanCompatFail && !e.dryRun {
+		msgs := make([]string, len(issues))
+		for i, issue := range issues {
+			msgs[i] = issue.String()
+		}
+		return fmt.Errorf("quadlets use features the installed podman does not support (sync.podman_compat: fail): %s", strings.Join(msgs, "; "))
+	}
+	for _, issue := range issues {
+		e.warn(WarnPodmanUnsupported, issue.Path, issue.Message, "line", issue.Line, "podman_version", version.String())
+	}
+	return nil
+}
+
+// CheckPodman reports the installed Podman version for doctor: an error when
+// podman cannot be run, a warning when it predates quadlet. Clients that do
+// not run Podman's generator report nothing.
+func CheckPodman(ctx context.Context, systemd systemduser.Systemd) []config.CheckResult {
+	pv, ok := systemd.(podmanVersioner)
+	if !ok {
+		return nil
+	}
+	r := config.CheckResult{Field: "podman.version", Status: config.CheckOK}
+	out, err := pv.PodmanVersion(ctx)
+	if err != nil {
+		r.Status, r.Detail = config.CheckError, err.Error()
+		return []config.CheckResult{r}
+	}
+	v, err := quadlet.ParseVersion(out)
+	if err != nil {
+		r.Status, r.Detail = config.CheckWarning, err.Error()
+		return []config.CheckResult{r}
+	}
+	r.Detail = "podman " + v.String()
+	if v.Less(quadlet.MinVersion) {
+		r.Status = config.CheckWarning
+		r.Detail = fmt.Sprintf("podman %s predates quadlet; upgrade to %s or newer", v, quadlet.MinVersion)
+	}
+	return []config.CheckResult{r}
+}
@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// versionedSystemd is a mock systemd client that also reports a Podman
+// version, like the real clients.
+type versionedSystemd struct {
+	*testutil.MockSystemd
+	version string
+	err     error
+	calls   int
+}
+
+func (s *versionedSystemd) PodmanVersion(context.Context) (string, error) {
+	s.calls++
+	return s.version, s.err
+}
+
+// resetDetectedPodman forgets the cached Podman version around a test.
+func resetDetectedPodman(t *testing.T) {
+	t.Helper()
+	detectedPodman.version = nil
+	t.Cleanup(func() { detectedPodman.version = nil })
+}
+
+func TestRun_PodmanCompat(t *testing.T) {
+	content := "[Container]\nImage=nginx:1\nPod=web.pod\n"
+	tests := []struct {
+		name         string
+		mode         config.PodmanCompatMode
+		dryRun       bool
+		version      string
+		versionErr   error
+		wantErr      bool
+		wantWarnings int
+	}{
+		{name: "supported", mode: config.PodmanCompatWarn, version: "podman version 5.2.0"},
+		{name: "warn", mode: config.PodmanCompatWarn, version: "podman version 4.9.3", wantWarnings: 1},
+		{name: "fail", mode: config.PodmanCompatFail, version: "podman version 4.9.3", wantErr: true},
+		{name: "fail in dry run", mode: config.PodmanCompatFail, dryRun: true, version: "podman version 4.9.3", wantWarnings: 1},
+		{name: "off", mode: config.PodmanCompatOff, version: "podman version 4.9.3"},
+		{name: "version unknown", mode: config.PodmanCompatFail, versionErr: errors.New("podman: not found")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDetectedPodman(t)
+			cfg, mockGit := newFreezeTestConfig(t, content)
+			cfg.Sync.PodmanCompat = tt.mode
+			systemd := &versionedSystemd{MockSystemd: &testutil.MockSystemd{Available: true}, version: tt.version, err: tt.versionErr}
+
+			result, err := NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), tt.dryRun).Run(context.Background())
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "Pod= in [Container] requires Podman 5.0.0") {
+					t.Fatalf("Run() error = %v, want the unsupported key", err)
+				}
+				if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "web.container")); !os.IsNotExist(err) {
+					t.Errorf("failed sync installed the quadlet: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var warnings []Warning
+			for _, w := range result.Warnings {
+				if w.Code == WarnPodmanUnsupported {
+					warnings = append(warnings, w)
+				}
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Fatalf("podman warnings = %+v, want %d", warnings, tt.wantWarnings)
+			}
+			if len(warnings) > 0 && warnings[0].Subject != filepath.Join(cfg.Paths.QuadletDir, "web.container") {
+				t.Errorf("warning subject = %q, want the quadlet", warnings[0].Subject)
+			}
+			if tt.mode == config.PodmanCompatOff && systemd.calls != 0 {
+				t.Error("podman version detected with podman_compat off")
+			}
+		})
+	}
+}
+
+func TestPodmanVersion_Cached(t *testing.T) {
+	resetDetectedPodman(t)
+	systemd := &versionedSystemd{MockSystemd: &testutil.MockSystemd{}, err: errors.New("podman: not found")}
+	e := NewEngine(&config.Config{}, nil, systemd, testutil.TestLogger(), false)
+
+	if _, err := e.podmanVersion(context.Background(), systemd); err == nil {
+		t.Fatal("podmanVersion() succeeded without podman")
+	}
+	systemd.version, systemd.err = "podman version 5.1.2", nil
+	for range 2 {
+		v, err := e.podmanVersion(context.Background(), systemd)
+		if err != nil || v.String() != "5.1.2" {
+			t.Fatalf("podmanVersion() = %v, %v; want 5.1.2", v, err)
+		}
+	}
+	if systemd.calls != 2 {
+		t.Errorf("podman --version ran %d times, want 2 (failed detection is retried, success cached)", systemd.calls)
+	}
+}
+
+func TestCheckPodman(t *testing.T) {
+	if got := CheckPodman(context.Background(), &testutil.MockSystemd{}); got != nil {
+		t.Errorf("CheckPodman(no generator) = %+v, want nothing", got)
+	}
+	tests := []struct {
+		version string
+		err     error
+		want    config.CheckStatus
+	}{
+		{version: "podman version 5.2.1", want: config.CheckOK},
+		{version: "podman version 4.3.1", want: config.CheckWarning},
+		{err: errors.New("podman: not found"), want: config.CheckError},
+	}
+	for _, tt := range tests {
+		got := CheckPodman(context.Background(), &versionedSystemd{MockSystemd: &testutil.MockSystemd{}, version: tt.version, err: tt.err})
+		if len(got) != 1 || got[0].Status != tt.want {
+			t.Errorf("CheckPodman(%q, %v) = %+v, want %s", tt.version, tt.err, got, tt.want)
+		}
+	}
+}
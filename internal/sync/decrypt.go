@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/schaermu/quadsyncd/internal/secrets"
+)
+
+// sourceHash returns the hash of the content installing path would write and
+// whether path is sops-encrypted. Encrypted files are decrypted with
+// sync.age_identity_file and hashed by their plaintext, which is kept in
+// memory for staging so the decrypted content never touches the checkout.
+// With substitution enabled the content is hashed after substitution, which
+// is kept in memory the same way when it changed the file.
+func (e *Engine) sourceHash(ctx context.Context, path string) (string, bool, error) {
+	e.prepareSourceHashing()
+	src, err := e.hashSource(ctx, path)
+	if err != nil {
+		return "", false, err
+	}
+	e.keepSource(path, src)
+	return src.hash, src.encrypted, nil
+}
+
+// sourceContent is the result of hashSource.
+type sourceContent struct {
+	hash      string
+	encrypted bool
+	// rendered is the substituted content of a plain source, when
+	// substitution changed it.
+	rendered []byte
+	// plain is the decrypted content of an encrypted source.
+	plain []byte
+}
+
+// prepareSourceHashing sets up the engine state hashSource reads, so that
+// hashSource can then run concurrently.
+func (e *Engine) prepareSourceHashing() {
+	e.substitutionVars()
+	if e.fileDecrypter == nil {
+		e.fileDecrypter = secrets.NewCLIDecrypter(e.cfg.Sync.AgeIdentityFile, e.cfg.Timeouts.Podman)
+	}
+}
+
+// hashSource is sourceHash without keeping the content. It does not modify
+// the engine and is safe to call concurrently after prepareSourceHashing.
+func (e *Engine) hashSource(ctx context.Context, path string) (sourceContent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sourceContent{}, fmt.Errorf("failed to compute hash for %s: %w", path, err)
+	}
+	if !secrets.IsSOPSEncrypted(data) {
+		data, changed, err := e.substitute(path, data)
+		if err != nil {
+			return sourceContent{}, err
+		}
+		if installed, ok := e.ensureWantedBy(path, data); ok {
+			data, changed = installed, true
+		}
+		sum := sha256.Sum256(data)
+		src := sourceContent{hash: hex.EncodeToString(sum[:])}
+		if changed {
+			src.rendered = data
+		}
+		return src, nil
+	}
+
+	plain, err := e.fileDecrypter.Decrypt(ctx, secrets.FormatSOPS, path)
+	if err != nil {
+		return sourceContent{}, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	plain, _, err = e.substitute(path, plain)
+	if err != nil {
+		return sourceContent{}, err
+	}
+	plain, _ = e.ensureWantedBy(path, plain)
+	sum := sha256.Sum256(plain)
+	return sourceContent{hash: hex.EncodeToString(sum[:]), encrypted: true, plain: plain}, nil
+}
+
+// keepSource keeps the rendered or decrypted content of the source at path
+// for staging.
+func (e *Engine) keepSource(path string, src sourceContent) {
+	if src.rendered != nil {
+		if e.rendered == nil {
+			e.rendered = make(map[string][]byte)
+		}
+		e.rendered[path] = src.rendered
+	}
+	if src.plain != nil {
+		if e.plaintext == nil {
+			e.plaintext = make(map[string][]byte)
+		}
+		e.plaintext[path] = src.plain
+	}
+}
+
+// stageDecrypted writes the plaintext of the encrypted source src to dst,
+// readable only by the owner.
+func (e *Engine) stageDecrypted(src, dst string) error {
+	plain, ok := e.plaintext[src]
+	if !ok {
+		return fmt.Errorf("%s was not decrypted while planning", src)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	// Remove a mirrored copy first so WriteFile creates dst with mode 0600.
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.WriteFile(dst, plain, 0600)
+}
@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+)
+
+// dropInUnits returns the units of the installed quadlets that drop-ins in
+// ops apply to by type, name prefix or template, e.g. every .container
+// quadlet for container.d/10-labels.conf. Drop-ins for a single quadlet are
+// attributed by quadletUnitsFromOps.
+func (e *Engine) dropInUnits(ops []FileOp) []string {
+	dirs := make(map[string]bool)
+	for _, op := range ops {
+		if _, single := quadlet.DropInQuadlet(op.DestPath); single {
+			continue
+		}
+		if dir, ok := quadlet.DropInDir(op.DestPath); ok {
+			dirs[dir] = true
+		}
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	files, err := quadlet.DiscoverFiles(e.cfg.Paths.QuadletDir)
+	if err != nil {
+		e.logger.Warn("failed to list quadlets for drop-in changes", "error", err)
+		return nil
+	}
+	var units []string
+	for _, path := range files {
+		name := filepath.Base(path)
+		if strings.Contains(name, "@.") {
+			continue
+		}
+		for dir := range dirs {
+			if quadlet.DropInApplies(dir, name) {
+				units = append(units, quadlet.UnitNameFromQuadlet(name))
+				break
+			}
+		}
+	}
+	return units
+}
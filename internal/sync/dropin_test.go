@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestRun_DropInRestartsParent(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	files := map[string]string{
+		"web.container":               "[Container]\nImage=nginx\n[Install]\nWantedBy=default.target\n",
+		"api.container":               "[Container]\nImage=api\n[Install]\nWantedBy=default.target\n",
+		"db.volume":                   "[Volume]\n",
+		"web.container.d/10-env.conf": "[Container]\nEnvironment=A=1\n",
+	}
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.RemoveAll(destDir)
+			for name, content := range files {
+				_ = os.MkdirAll(filepath.Join(destDir, filepath.Dir(name)), 0755)
+				_ = os.WriteFile(filepath.Join(destDir, name), []byte(content), 0644)
+			}
+		},
+	}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Prune: true, Restart: config.RestartChanged},
+	}
+	run := func(commit string) []string {
+		t.Helper()
+		gitMock.CommitHash = commit
+		result, err := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run(%s): %v", commit, err)
+		}
+		return result.RestartedUnits
+	}
+
+	run("c1")
+	if _, err := os.Stat(filepath.Join(quadletDir, "web.container.d", "10-env.conf")); err != nil {
+		t.Fatalf("drop-in not synced: %v", err)
+	}
+
+	// Changing only the drop-in restarts the unit of its quadlet.
+	files["web.container.d/10-env.conf"] = "[Container]\nEnvironment=A=2\n"
+	if got := run("c2"); !reflect.DeepEqual(got, []string{"web.service"}) {
+		t.Errorf("restarted after drop-in change = %v, want [web.service]", got)
+	}
+
+	// A drop-in for the container type restarts every container unit.
+	files["container.d/labels.conf"] = "[Container]\nLabel=team=ops\n"
+	if got := run("c3"); !reflect.DeepEqual(got, []string{"api.service", "web.service"}) {
+		t.Errorf("restarted after type drop-in = %v, want [api.service web.service]", got)
+	}
+
+	// Removing a drop-in restarts the unit it applied to.
+	delete(files, "web.container.d/10-env.conf")
+	if got := run("c4"); !reflect.DeepEqual(got, []string{"web.service"}) {
+		t.Errorf("restarted after drop-in removal = %v, want [web.service]", got)
+	}
+}
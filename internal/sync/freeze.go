@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// freezeFile is the name of the freeze marker in the state directory.
+const freezeFile = "freeze.json"
+
+// Freeze records that syncs are paused by `quadsyncd freeze`. While it is
+// set, syncs still fetch and plan, so pending changes are reported, but
+// nothing is applied.
+type Freeze struct {
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// FreezeFilePath returns the path of the freeze marker for stateDir. It is
+// kept next to state.json rather than in it, so restores and adopts that
+// rewrite the state do not lift a freeze.
+func FreezeFilePath(stateDir string) string {
+	return filepath.Join(stateDir, freezeFile)
+}
+
+// ReadFreeze returns the freeze recorded in stateDir, or nil when syncs are
+// not frozen.
+func ReadFreeze(stateDir string) (*Freeze, error) {
+	m, err := readMarker(stateDir, freezeFile)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return (*Freeze)(m), nil
+}
+
+// SetFreeze freezes syncs in stateDir. An existing freeze keeps its start
+// time and gets the new reason.
+func SetFreeze(stateDir, reason string, now time.Time) (*Freeze, error) {
+	m, err := writeMarker(stateDir, freezeFile, reason, now)
+	if err != nil {
+		return nil, err
+	}
+	return (*Freeze)(m), nil
+}
+
+// ClearFreeze lifts a freeze in stateDir and reports whether one was set.
+func ClearFreeze(stateDir string) (bool, error) {
+	return clearMarker(stateDir, freezeFile)
+}
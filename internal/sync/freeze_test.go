@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestFreezeFile(t *testing.T) {
+	stateDir := filepath.Join(t.TempDir(), "state")
+
+	if f, err := ReadFreeze(stateDir); err != nil || f != nil {
+		t.Fatalf("ReadFreeze() = %v, %v; want not frozen", f, err)
+	}
+	since := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	if _, err := SetFreeze(stateDir, "release", since); err != nil {
+		t.Fatalf("SetFreeze() error = %v", err)
+	}
+	f, err := SetFreeze(stateDir, "still releasing", since.Add(time.Hour))
+	if err != nil || !f.Since.Equal(since) || f.Reason != "still releasing" {
+		t.Errorf("SetFreeze() again = %+v, %v; want original start and new reason", f, err)
+	}
+	if f, err := ReadFreeze(stateDir); err != nil || f == nil || f.Reason != "still releasing" {
+		t.Errorf("ReadFreeze() = %+v, %v", f, err)
+	}
+
+	if cleared, err := ClearFreeze(stateDir); err != nil || !cleared {
+		t.Errorf("ClearFreeze() = %v, %v; want cleared", cleared, err)
+	}
+	if cleared, err := ClearFreeze(stateDir); err != nil || cleared {
+		t.Errorf("ClearFreeze() again = %v, %v; want nothing to clear", cleared, err)
+	}
+}
+
+// newFreezeTestConfig returns a config and git mock for a single-repo sync of
+// web.container with content, restarting changed units.
+func newFreezeTestConfig(t *testing.T, content string) (*config.Config, *testutil.MockGitClient) {
+	t.Helper()
+	cfg, mockGit := newTestRepo(t, map[string]string{"web.container": content})
+	cfg.Sync.Restart = config.RestartChanged
+	return cfg, mockGit
+}
+
+func TestEngine_Run_Frozen(t *testing.T) {
+	content := "[Container]\nImage=nginx:1\n[Install]\nWantedBy=default.target\n"
+	cfg, mockGit := newFreezeTestConfig(t, content)
+	if _, err := SetFreeze(cfg.Paths.StateDir, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	systemd := &testutil.MockSystemd{Available: true}
+	result, err := NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !result.Frozen || result.Applied || len(result.Plan.Add) != 1 {
+		t.Errorf("result frozen=%v applied=%v add=%d; want frozen with one pending add", result.Frozen, result.Applied, len(result.Plan.Add))
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != WarnSyncFrozen {
+		t.Errorf("warnings = %+v, want %s", result.Warnings, WarnSyncFrozen)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "web.container")); !os.IsNotExist(err) {
+		t.Errorf("frozen sync wrote the quadlet: %v", err)
+	}
+	if systemd.ReloadCalled {
+		t.Error("frozen sync reloaded systemd")
+	}
+	if entries, _ := ReadHistory(cfg.Paths.StateDir, 1); len(entries) != 1 || entries[0].Result != HistoryResultFrozen {
+		t.Errorf("history = %+v, want a frozen entry", entries)
+	}
+}
+
+func TestEngine_Run_WindowDefersRestarts(t *testing.T) {
+	files := map[string]string{"web.container": "[Container]\nImage=nginx:1\n"}
+	cfg, mockGit := newTestRepo(t, files)
+	cfg.Sync.Restart = config.RestartChanged
+	always, err := config.ParseWindow("* 00:00-24:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First sync installs the unit; the next one changes it inside a window.
+	if _, err := NewEngine(cfg, mockGit, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	files["web.container"] = "[Container]\nImage=nginx:2\n"
+	cfg.Sync.Windows = []config.Window{always}
+	systemd := &testutil.MockSystemd{Available: true}
+	result, err := NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !result.Applied || systemd.RestartCalled || !reflect.DeepEqual(result.DeferredUnits, []string{"web.service"}) {
+		t.Errorf("applied=%v restarted=%v deferred=%v; want applied with web.service deferred", result.Applied, systemd.RestartCalled, result.DeferredUnits)
+	}
+	state, err := ReadStateFile(cfg.StateFilePath())
+	if err != nil || !reflect.DeepEqual(state.PendingRestarts, []string{"web.service"}) {
+		t.Fatalf("pending restarts = %v, %v", state.PendingRestarts, err)
+	}
+
+	// Outside the window, a sync without changes restarts the deferred unit.
+	cfg.Sync.Windows = nil
+	systemd = &testutil.MockSystemd{Available: true}
+	result, err = NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.RestartedUnits, []string{"web.service"}) || len(result.DeferredUnits) != 0 {
+		t.Errorf("restarted=%v deferred=%v; want web.service restarted", result.RestartedUnits, result.DeferredUnits)
+	}
+	if state, _ := ReadStateFile(cfg.StateFilePath()); len(state.PendingRestarts) != 0 {
+		t.Errorf("pending restarts = %v after restart", state.PendingRestarts)
+	}
+}
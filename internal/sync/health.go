@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+)
+
+// ErrUnitsUnhealthy is returned (wrapped) when sync.health_check.fail is set
+// and a restarted or started unit failed within the health check window.
+var ErrUnitsUnhealthy = errors.New("units failed after sync")
+
+// defaultHealthInterval is how often units are polled during the health
+// check window.
+const defaultHealthInterval = time.Second
+
+// verifyUnitHealth runs the post-restart health check configured by
+// sync.health_check on the units the run restarted or started and records
+// failed units in result. It returns an error wrapping ErrUnitsUnhealthy
+// only when sync.health_check.fail is set.
+func (e *Engine) verifyUnitHealth(ctx context.Context, result *Result) error {
+	hc := e.cfg.Sync.HealthCheck
+	units := mergeUnits(result.RestartedUnits, result.StartedUnits)
+	if hc.Window <= 0 || len(units) == 0 {
+		return nil
+	}
+
+	e.logger.Info("checking unit health", logging.Event(logging.EventUnitHealthCheck), "count", len(units), "units", units, "window", hc.Window)
+	result.UnhealthyUnits = e.pollUnitHealth(ctx, units, hc.Window)
+	if len(result.UnhealthyUnits) == 0 {
+		return nil
+	}
+
+	if hc.Fail {
+		e.logger.Error("units failed after sync", logging.Event(logging.EventUnitUnhealthy), "units", result.UnhealthyUnits)
+		return fmt.Errorf("%w: %s", ErrUnitsUnhealthy, strings.Join(result.UnhealthyUnits, ", "))
+	}
+	e.warn(WarnUnitUnhealthy, strings.Join(result.UnhealthyUnits, ","), "units failed after sync", "units", result.UnhealthyUnits)
+	return nil
+}
+
+// pollUnitHealth polls units with systemctl --user is-active until window
+// has elapsed and returns the sorted units seen in the failed state. A unit
+// stops being polled once it has failed; units whose state cannot be read
+// are retried on the next poll. Polling stops early when ctx is cancelled.
+func (e *Engine) pollUnitHealth(ctx context.Context, units []string, window time.Duration) []string {
+	interval := e.healthInterval
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+	if interval > window {
+		interval = window
+	}
+	deadline := time.Now().Add(window)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pending := units
+	var failed []string
+	for {
+		var next []string
+		for _, unit := range pending {
+			status, err := e.systemd.GetUnitStatus(ctx, unit)
+			if err != nil {
+				e.logger.Debug("failed to read unit state", "unit", unit, "error", err)
+			}
+			if status == "failed" {
+				failed = append(failed, unit)
+				continue
+			}
+			next = append(next, unit)
+		}
+		pending = next
+		if len(pending) == 0 || !time.Now().Before(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			e.logger.Warn("unit health check interrupted", "error", ctx.Err())
+			sort.Strings(failed)
+			return failed
+		case <-ticker.C:
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// healthEngine returns an engine that syncs web.container and db.container
+// with sync.start_new, so both units are health checked.
+func healthEngine(t *testing.T, sd *testutil.MockSystemd, hc config.HealthCheckConfig) *Engine {
+	t.Helper()
+	cfg, mg := newTestRepo(t, map[string]string{
+		"web.container": "[Container]\n[Install]\nWantedBy=default.target\n",
+		"db.container":  "[Container]\n[Install]\nWantedBy=default.target\n",
+	})
+	cfg.Sync = config.SyncConfig{Restart: config.RestartChanged, StartNew: true, HealthCheck: hc}
+	e := NewEngine(cfg, mg, sd, testutil.TestLogger(), false)
+	e.healthInterval = 5 * time.Millisecond
+	return e
+}
+
+func TestRun_HealthCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        map[string]string
+		hc            config.HealthCheckConfig
+		wantUnhealthy []string
+		wantErr       bool
+		wantWarning   bool
+	}{
+		{
+			name:   "disabled",
+			status: map[string]string{"web.service": "failed"},
+		},
+		{
+			name:   "all active",
+			status: map[string]string{"web.service": "active", "db.service": "active"},
+			hc:     config.HealthCheckConfig{Window: 30 * time.Millisecond},
+		},
+		{
+			name:          "failed unit warns",
+			status:        map[string]string{"web.service": "failed", "db.service": "active"},
+			hc:            config.HealthCheckConfig{Window: 30 * time.Millisecond},
+			wantUnhealthy: []string{"web.service"},
+			wantWarning:   true,
+		},
+		{
+			name:          "failed unit fails sync",
+			status:        map[string]string{"web.service": "failed", "db.service": "failed"},
+			hc:            config.HealthCheckConfig{Window: time.Hour, Fail: true},
+			wantUnhealthy: []string{"db.service", "web.service"},
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd := &testutil.MockSystemd{Available: true, UnitStatus: tt.status}
+			result, err := healthEngine(t, sd, tt.hc).Run(context.Background())
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnitsUnhealthy) {
+					t.Fatalf("Run error = %v, want ErrUnitsUnhealthy", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if result == nil {
+				t.Fatal("Run returned no result")
+			}
+			if !reflect.DeepEqual(result.UnhealthyUnits, tt.wantUnhealthy) {
+				t.Errorf("UnhealthyUnits = %v, want %v", result.UnhealthyUnits, tt.wantUnhealthy)
+			}
+			gotWarning := len(result.Warnings) == 1 && result.Warnings[0].Code == WarnUnitUnhealthy
+			if gotWarning != tt.wantWarning {
+				t.Errorf("Warnings = %+v, want unit_unhealthy warning: %v", result.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestPollUnitHealth_StopsOnCancel(t *testing.T) {
+	sd := &testutil.MockSystemd{Available: true, UnitStatus: map[string]string{"web.service": "active"}}
+	e := healthEngine(t, sd, config.HealthCheckConfig{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if failed := e.pollUnitHealth(ctx, []string{"web.service"}, time.Hour); failed != nil {
+		t.Errorf("failed = %v, want none", failed)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("poll did not stop on cancellation (took %s)", elapsed)
+	}
+}
@@ -0,0 +1,193 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HistoryLimit is the number of sync runs kept in history.jsonl; older
+// entries are dropped as new runs are appended.
+const HistoryLimit = 200
+
+// History results.
+const (
+	HistoryResultSuccess = "success"
+	HistoryResultError   = "error"
+	// HistoryResultFrozen marks runs that found syncs frozen; their counts
+	// are the pending changes that were not applied.
+	HistoryResultFrozen = "frozen"
+	// HistoryResultSkipped marks runs that stopped early because nothing
+	// changed since the last sync.
+	HistoryResultSkipped = "skipped"
+	// HistoryResultPaused marks runs that did nothing because syncs are
+	// paused.
+	HistoryResultPaused = "paused"
+)
+
+// HistoryEntry records the outcome of one applied (non dry-run) sync run.
+type HistoryEntry struct {
+	StartedAt  time.Time         `json:"started_at"`
+	DurationMS int64             `json:"duration_ms"`
+	Commit     string            `json:"commit,omitempty"`
+	Revisions  map[string]string `json:"revisions,omitempty"`
+	Ref        string            `json:"ref,omitempty"` // ref given to sync --ref, when set
+	Added      int               `json:"added"`
+	Updated    int               `json:"updated"`
+	Deleted    int               `json:"deleted"`
+	Restarted  int               `json:"restarted"`
+	// RestartFailed lists the units whose restart failed.
+	RestartFailed []string `json:"restart_failed,omitempty"`
+	Unhealthy     []string `json:"unhealthy,omitempty"`
+	// AutoUpdated lists the units podman auto-update updated after the sync.
+	AutoUpdated []string `json:"auto_updated,omitempty"`
+	Warnings    int      `json:"warnings,omitempty"`
+	Result      string   `json:"result"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// Duration returns the run's wall-clock duration.
+func (h HistoryEntry) Duration() time.Duration {
+	return time.Duration(h.DurationMS) * time.Millisecond
+}
+
+// HistoryFilePath returns the path of the sync history log for stateDir.
+func HistoryFilePath(stateDir string) string {
+	return filepath.Join(stateDir, "history.jsonl")
+}
+
+// newHistoryEntry summarises a finished run. result may be nil when err is set.
+func newHistoryEntry(start time.Time, result *Result, err error) HistoryEntry {
+	entry := HistoryEntry{
+		StartedAt:  start.UTC(),
+		DurationMS: time.Since(start).Milliseconds(),
+		Result:     HistoryResultSuccess,
+	}
+	if err != nil {
+		entry.Result = HistoryResultError
+		entry.Error = err.Error()
+	}
+	if result == nil {
+		return entry
+	}
+	switch {
+	case err != nil:
+	case result.Paused:
+		entry.Result = HistoryResultPaused
+	case result.Frozen:
+		entry.Result = HistoryResultFrozen
+	case result.Skipped:
+		entry.Result = HistoryResultSkipped
+	}
+	if len(result.Revisions) == 1 {
+		for _, sha := range result.Revisions {
+			entry.Commit = sha
+		}
+	} else if len(result.Revisions) > 1 {
+		entry.Revisions = result.Revisions
+	}
+	if result.Plan != nil {
+		entry.Added = len(result.Plan.Add)
+		entry.Updated = len(result.Plan.Update)
+		entry.Deleted = len(result.Plan.Delete)
+	}
+	entry.Restarted = len(result.RestartedUnits)
+	entry.RestartFailed = result.RestartFailed
+	entry.Unhealthy = result.UnhealthyUnits
+	entry.AutoUpdated = result.AutoUpdated
+	return entry
+}
+
+// AppendHistory appends entry to the history log in stateDir, keeping at most
+// HistoryLimit entries. The file is rewritten atomically.
+func AppendHistory(stateDir string, entry HistoryEntry) error {
+	entries, err := readHistoryFile(HistoryFilePath(stateDir))
+	if err != nil {
+		return err
+	}
+	entries = append(entries, entry)
+	if len(entries) > HistoryLimit {
+		entries = entries[len(entries)-HistoryLimit:]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(stateDir, ".history-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("failed to create history file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), HistoryFilePath(stateDir)); err != nil {
+		return fmt.Errorf("failed to replace history file: %w", err)
+	}
+	return nil
+}
+
+// ReadHistory returns up to limit history entries from stateDir, newest first.
+// A limit of zero or less returns all entries. A missing log yields no entries.
+func ReadHistory(stateDir string, limit int) ([]HistoryEntry, error) {
+	entries, err := readHistoryFile(HistoryFilePath(stateDir))
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// readHistoryFile parses history.jsonl in file order. Lines that fail to
+// parse (e.g. a torn write from an older version) are skipped.
+func readHistoryFile(path string) ([]HistoryEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return entries, nil
+}
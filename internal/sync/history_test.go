@@ -0,0 +1,193 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestAppendAndReadHistory(t *testing.T) {
+	stateDir := t.TempDir()
+
+	entries, err := ReadHistory(stateDir, 0)
+	if err != nil {
+		t.Fatalf("ReadHistory on missing file: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no entries, got %d", len(entries))
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < HistoryLimit+5; i++ {
+		entry := HistoryEntry{
+			StartedAt: start.Add(time.Duration(i) * time.Minute),
+			Commit:    fmt.Sprintf("c%03d", i),
+			Result:    HistoryResultSuccess,
+		}
+		if err := AppendHistory(stateDir, entry); err != nil {
+			t.Fatalf("AppendHistory #%d: %v", i, err)
+		}
+	}
+
+	all, err := ReadHistory(stateDir, 0)
+	if err != nil {
+		t.Fatalf("ReadHistory: %v", err)
+	}
+	if len(all) != HistoryLimit {
+		t.Fatalf("expected %d entries after trimming, got %d", HistoryLimit, len(all))
+	}
+	if got, want := all[0].Commit, fmt.Sprintf("c%03d", HistoryLimit+4); got != want {
+		t.Errorf("newest entry = %s, want %s", got, want)
+	}
+	if got, want := all[len(all)-1].Commit, "c005"; got != want {
+		t.Errorf("oldest kept entry = %s, want %s", got, want)
+	}
+
+	limited, err := ReadHistory(stateDir, 3)
+	if err != nil {
+		t.Fatalf("ReadHistory limited: %v", err)
+	}
+	if len(limited) != 3 || limited[0].Commit != all[0].Commit {
+		t.Errorf("limited read = %+v", limited)
+	}
+}
+
+func TestReadHistory_SkipsCorruptLines(t *testing.T) {
+	stateDir := t.TempDir()
+	content := `{"started_at":"2026-01-01T00:00:00Z","commit":"aaa","result":"success"}
+{"started_at":
+{"started_at":"2026-01-01T00:01:00Z","commit":"bbb","result":"error","error":"boom"}
+`
+	if err := os.WriteFile(HistoryFilePath(stateDir), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadHistory(stateDir, 0)
+	if err != nil {
+		t.Fatalf("ReadHistory: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Commit != "bbb" || entries[0].Error != "boom" {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestNewHistoryEntry(t *testing.T) {
+	start := time.Now().Add(-1500 * time.Millisecond)
+
+	tests := []struct {
+		name       string
+		result     *Result
+		err        error
+		wantResult string
+		wantCommit string
+		wantRepos  int
+		wantAdded  int
+	}{
+		{
+			name:       "error without result",
+			err:        errors.New("clone failed"),
+			wantResult: HistoryResultError,
+		},
+		{
+			name: "single repo success",
+			result: &Result{
+				Revisions:      map[string]string{"file:///a": "abc"},
+				Plan:           &Plan{Add: []FileOp{{}, {}}},
+				RestartedUnits: []string{"a.service"},
+			},
+			wantResult: HistoryResultSuccess,
+			wantCommit: "abc",
+			wantAdded:  2,
+		},
+		{
+			name: "multi repo success",
+			result: &Result{
+				Revisions: map[string]string{"file:///a": "abc", "file:///b": "def"},
+				Plan:      &Plan{},
+			},
+			wantResult: HistoryResultSuccess,
+			wantRepos:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := newHistoryEntry(start, tt.result, tt.err)
+			if entry.Result != tt.wantResult {
+				t.Errorf("Result = %q, want %q", entry.Result, tt.wantResult)
+			}
+			if entry.Commit != tt.wantCommit {
+				t.Errorf("Commit = %q, want %q", entry.Commit, tt.wantCommit)
+			}
+			if len(entry.Revisions) != tt.wantRepos {
+				t.Errorf("Revisions = %v, want %d entries", entry.Revisions, tt.wantRepos)
+			}
+			if entry.Added != tt.wantAdded {
+				t.Errorf("Added = %d, want %d", entry.Added, tt.wantAdded)
+			}
+			if tt.err != nil && entry.Error != tt.err.Error() {
+				t.Errorf("Error = %q, want %q", entry.Error, tt.err.Error())
+			}
+			if entry.Duration() < time.Second {
+				t.Errorf("Duration = %v, want >= 1s", entry.Duration())
+			}
+		})
+	}
+}
+
+func TestRun_RecordsHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	stateDir := filepath.Join(tmpDir, "state")
+
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "app.container"), []byte("[Container]\nImage=alpine\n"), 0644)
+		},
+	}
+	sd := &testutil.MockSystemd{Available: true}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: stateDir},
+		Sync:       config.SyncConfig{Prune: true, Restart: config.RestartNone},
+	}
+
+	// Dry runs are not recorded.
+	if _, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), true).Run(context.Background()); err != nil {
+		t.Fatalf("dry-run: %v", err)
+	}
+	if _, err := os.Stat(HistoryFilePath(stateDir)); !os.IsNotExist(err) {
+		t.Fatalf("dry-run should not write history, stat err = %v", err)
+	}
+
+	if _, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	gitMock.Err = errors.New("fetch failed")
+	if _, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).Run(context.Background()); err == nil {
+		t.Fatal("expected error from git failure")
+	}
+
+	entries, err := ReadHistory(stateDir, 0)
+	if err != nil {
+		t.Fatalf("ReadHistory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 history entries, got %d", len(entries))
+	}
+	if entries[0].Result != HistoryResultError || entries[0].Error == "" {
+		t.Errorf("newest entry should record the failure: %+v", entries[0])
+	}
+	if entries[1].Result != HistoryResultSuccess || entries[1].Commit != "abc123" || entries[1].Added != 1 {
+		t.Errorf("first entry = %+v", entries[1])
+	}
+}
@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/schaermu/quadsyncd/internal/multirepo"
+)
+
+// sourceDiff describes the checkout of one repository for an incremental
+// plan.
+type sourceDiff struct {
+	// dir is the checkout directory.
+	dir string
+	// changed holds the repository-relative paths git reports as changed
+	// since the revision recorded in the state. It is nil when that is
+	// unknown, and every file is then hashed.
+	changed map[string]bool
+}
+
+// sourceDiffs lists, per repository URL, the files changed between the
+// revision the previous sync recorded and the one just checked out, using
+// git diff on the checkout. Repositories without a recorded revision, or
+// whose diff fails (e.g. the old commit is outside a shallow clone), get no
+// change list and are planned by hashing every file. With substitution or
+// sync.ensure_wantedby enabled the installed content also depends on the
+// config and host facts, so no repository gets a change list.
+func (e *Engine) sourceDiffs(ctx context.Context, prevState *State, repoStates []multirepo.RepoState) map[string]*sourceDiff {
+	diffs := make(map[string]*sourceDiff, len(repoStates))
+	for _, rs := range repoStates {
+		if rs.Spec.IsOCI() || rs.Spec.IsDir() || rs.Dir == "" {
+			continue
+		}
+		d := &sourceDiff{dir: rs.Dir}
+		diffs[rs.Spec.URL] = d
+		if e.cfg.Substitution.Enabled || e.cfg.Sync.EnsureWantedBy != "" {
+			continue
+		}
+
+		from := prevState.Revisions[rs.Spec.URL]
+		if from == "" && len(repoStates) == 1 {
+			from = prevState.Commit
+		}
+		differ, ok := e.repoClient(rs.Spec).(git.Differ)
+		switch {
+		case !ok || from == "" || rs.Commit == "":
+			continue
+		case from == rs.Commit:
+			d.changed = map[string]bool{}
+			continue
+		}
+		paths, err := differ.ChangedFiles(ctx, rs.Dir, from, rs.Commit)
+		if err != nil {
+			e.logger.Info("cannot list files changed since the last sync, hashing all files",
+				"repo", rs.Spec.URL, "from", from, "to", rs.Commit, "error", err)
+			continue
+		}
+		d.changed = make(map[string]bool, len(paths))
+		for _, p := range paths {
+			d.changed[p] = true
+		}
+		e.logger.Debug("listed files changed since the last sync",
+			"repo", rs.Spec.URL, "from", from, "to", rs.Commit, "count", len(paths))
+	}
+	return diffs
+}
+
+// sourceFile returns the repository-relative path of the checkout file
+// absPath, or "" when it lies outside the checkout.
+func (d *sourceDiff) sourceFile(absPath string) string {
+	rel, err := filepath.Rel(d.dir, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// unchanged reports whether git lists neither file nor one of its parent
+// directories (a submodule) as changed.
+func (d *sourceDiff) unchanged(file string) bool {
+	if d.changed == nil || file == "" {
+		return false
+	}
+	for p := file; p != "." && p != "/"; p = path.Dir(p) {
+		if d.changed[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// reusableHash returns the hash the state records for destPath when item is
+// the verbatim source installed there by the last sync and git reports it
+// unchanged since, so that reading and hashing it again can be skipped.
+func (e *Engine) reusableHash(prevState *State, destPath string, item multirepo.EffectiveItem) (string, bool) {
+	d := e.diffs[item.SourceRepo]
+	if d == nil {
+		return "", false
+	}
+	prev, ok := prevState.ManagedFiles[destPath]
+	if !ok || prev.SourceFile == "" || prev.SourceRepo != item.SourceRepo || prev.Hash == "" {
+		return "", false
+	}
+	file := d.sourceFile(item.AbsPath)
+	if file != prev.SourceFile || !d.unchanged(file) {
+		return "", false
+	}
+	return prev.Hash, true
+}
@@ -0,0 +1,202 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// diffGitClient is a MockGitClient that also lists changed files.
+type diffGitClient struct {
+	testutil.MockGitClient
+	changed []string
+	err     error
+	from    string
+}
+
+func (c *diffGitClient) ChangedFiles(_ context.Context, _, from, _ string) ([]string, error) {
+	c.from = from
+	return c.changed, c.err
+}
+
+// incrementalTestEngine returns an engine syncing files, which the returned
+// client writes into the checkout on every run.
+func incrementalTestEngine(t *testing.T, files map[string]string) (*config.Config, *diffGitClient) {
+	t.Helper()
+	cfg, mg := newTestRepo(t, files)
+	client := &diffGitClient{MockGitClient: *mg}
+	client.CommitHash = "c1"
+	return cfg, client
+}
+
+func updatedNames(plan *Plan) []string {
+	var names []string
+	for _, op := range plan.Update {
+		names = append(names, filepath.Base(op.DestPath))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRun_IncrementalPlan(t *testing.T) {
+	tests := []struct {
+		name         string
+		changed      []string
+		diffErr      error
+		substitution bool
+		want         []string
+	}{
+		{
+			name:    "files git reports unchanged are not hashed",
+			changed: []string{"b.container"},
+			want:    []string{"b.container"},
+		},
+		{
+			name:    "changed submodule covers its files",
+			changed: []string{"mod"},
+			want:    []string{"mod/c.container"},
+		},
+		{
+			name:    "failed diff hashes every file",
+			diffErr: errors.New("bad object"),
+			want:    []string{"a.container", "b.container", "mod/c.container"},
+		},
+		{
+			name:         "substitution hashes every file",
+			changed:      []string{"b.container"},
+			substitution: true,
+			want:         []string{"a.container", "b.container", "mod/c.container"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{
+				"a.container":     "[Container]\nImage=a:1\n",
+				"b.container":     "[Container]\nImage=b:1\n",
+				"mod/c.container": "[Container]\nImage=c:1\n",
+			}
+			cfg, client := incrementalTestEngine(t, files)
+			cfg.Substitution.Enabled = tt.substitution
+			ms := &testutil.MockSystemd{Available: true}
+			if _, err := NewEngine(cfg, client, ms, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+				t.Fatalf("first Run: %v", err)
+			}
+
+			// Every source changes in the checkout, but git only reports
+			// some of them, so only those are read again.
+			for name := range files {
+				files[name] += "# v2\n"
+			}
+			client.CommitHash = "c2"
+			client.changed, client.err = tt.changed, tt.diffErr
+			result, err := NewEngine(cfg, client, ms, testutil.TestLogger(), false).Run(context.Background())
+			if err != nil {
+				t.Fatalf("second Run: %v", err)
+			}
+			if !tt.substitution && client.from != "c1" {
+				t.Errorf("diffed from %q, want the recorded revision c1", client.from)
+			}
+			got := updatedNames(result.Plan)
+			if len(got) != len(tt.want) {
+				t.Fatalf("updated = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if filepath.Base(tt.want[i]) != got[i] {
+					t.Errorf("updated = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_IncrementalPlanRecordsSourceFile(t *testing.T) {
+	files := map[string]string{
+		"a.container": "[Container]\nImage=a:1\n",
+		"b.container": "[Container]\nImage=${QS_OS}\n",
+	}
+	cfg, client := incrementalTestEngine(t, files)
+	cfg.Substitution.Enabled = true
+	engine := NewEngine(cfg, client, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	state, err := engine.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	quadletDir := cfg.Paths.QuadletDir
+	if got := state.ManagedFiles[filepath.Join(quadletDir, "a.container")].SourceFile; got != "a.container" {
+		t.Errorf("a.container SourceFile = %q, want a.container", got)
+	}
+	// Rendered content depends on more than the source, so it is never reused.
+	if got := state.ManagedFiles[filepath.Join(quadletDir, "b.container")].SourceFile; got != "" {
+		t.Errorf("b.container SourceFile = %q, want none for a substituted file", got)
+	}
+}
+
+func TestRun_IncrementalPlanWithGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote := t.TempDir()
+	runGit := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", remote}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(remote, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit("init", "-q", "-b", "main")
+	runGit("config", "user.email", "test@test.com")
+	runGit("config", "user.name", "Test")
+	write("a.container", "[Container]\nImage=a:1\n")
+	write("b.container", "[Container]\nImage=b:1\n")
+	runGit("add", ".")
+	runGit("commit", "-q", "-m", "initial")
+
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: remote, Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Prune: true, Restart: config.RestartNone},
+	}
+	client := git.NewShellClient("", "", testutil.TestLogger())
+	ms := &testutil.MockSystemd{Available: true}
+	if _, err := NewEngine(cfg, client, ms, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	write("b.container", "[Container]\nImage=b:2\n")
+	runGit("rm", "-q", "a.container")
+	write("c.container", "[Container]\nImage=c:1\n")
+	runGit("add", ".")
+	runGit("commit", "-q", "-m", "update")
+
+	result, err := NewEngine(cfg, client, ms, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	plan := result.Plan
+	if len(plan.Add) != 1 || filepath.Base(plan.Add[0].DestPath) != "c.container" ||
+		len(plan.Update) != 1 || filepath.Base(plan.Update[0].DestPath) != "b.container" ||
+		len(plan.Delete) != 1 || filepath.Base(plan.Delete[0].DestPath) != "a.container" {
+		t.Errorf("plan add=%v update=%v delete=%v; want c added, b updated, a deleted", plan.Add, plan.Update, plan.Delete)
+	}
+	got, err := os.ReadFile(filepath.Join(cfg.Paths.QuadletDir, "b.container"))
+	if err != nil || string(got) != "[Container]\nImage=b:2\n" {
+		t.Errorf("b.container = %q, %v; want the new content", got, err)
+	}
+}
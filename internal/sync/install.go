@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+)
+
+// ensureWantedBy adds WantedBy=<sync.ensure_wantedby> to data, the content
+// of the .container quadlet at path, when it declares no install target.
+// Template quadlets are left alone. It reports whether data changed.
+func (e *Engine) ensureWantedBy(path string, data []byte) ([]byte, bool) {
+	target := e.cfg.Sync.EnsureWantedBy
+	if target == "" || !strings.HasSuffix(path, ".container") || quadlet.IsTemplate(path) {
+		return data, false
+	}
+	return quadlet.EnsureWantedBy(data, target)
+}
+
+// checkInstallTargets warns about the .container quadlets the plan adds or
+// updates that no unit pulls in through WantedBy= or RequiredBy=. Quadlet
+// units cannot be enabled with systemctl, so such units do not start on
+// boot. Template quadlets and files that cannot be parsed (validation
+// reports those) are skipped.
+func (e *Engine) checkInstallTargets(plan *Plan) {
+	for _, op := range append(append([]FileOp{}, plan.Add...), plan.Update...) {
+		if !strings.HasSuffix(op.DestPath, ".container") || quadlet.IsTemplate(op.DestPath) || op.Encrypted {
+			continue
+		}
+		var u *quadlet.Unit
+		var err error
+		if op.Rendered != nil {
+			u, err = quadlet.Parse(bytes.NewReader(op.Rendered))
+		} else {
+			u, err = quadlet.ParseFile(op.SourcePath)
+		}
+		if err != nil || u.HasInstallTarget() {
+			continue
+		}
+		e.warn(WarnWantedByMissing, op.DestPath, "quadlet has no WantedBy= in [Install], its unit does not start on boot",
+			"remediation", "add [Install] WantedBy=default.target or set sync.ensure_wantedby")
+	}
+}
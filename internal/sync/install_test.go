@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestRun_EnsureWantedBy(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		target       string
+		want         string
+		wantWarnings int
+	}{
+		{
+			name:         "missing install warns",
+			content:      "[Container]\nImage=nginx:1\n",
+			want:         "[Container]\nImage=nginx:1\n",
+			wantWarnings: 1,
+		},
+		{
+			name:    "present install",
+			content: "[Container]\nImage=nginx:1\n[Install]\nWantedBy=multi-user.target\n",
+			target:  "default.target",
+			want:    "[Container]\nImage=nginx:1\n[Install]\nWantedBy=multi-user.target\n",
+		},
+		{
+			name:    "injected",
+			content: "[Container]\nImage=nginx:1\n",
+			target:  "default.target",
+			want:    "[Container]\nImage=nginx:1\n\n[Install]\nWantedBy=default.target\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, mockGit := newFreezeTestConfig(t, tt.content)
+			cfg.Sync.EnsureWantedBy = tt.target
+			engine := NewEngine(cfg, mockGit, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+
+			result, err := engine.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var warnings int
+			for _, w := range result.Warnings {
+				if w.Code == WarnWantedByMissing {
+					warnings++
+				}
+			}
+			if warnings != tt.wantWarnings {
+				t.Errorf("wantedby_missing warnings = %d, want %d: %+v", warnings, tt.wantWarnings, result.Warnings)
+			}
+			got, err := os.ReadFile(filepath.Join(cfg.Paths.QuadletDir, "web.container"))
+			if err != nil || string(got) != tt.want {
+				t.Fatalf("installed %q, %v; want %q", got, err, tt.want)
+			}
+
+			// The state records the installed content, so a forced
+			// second run sees neither drift nor an update.
+			engine.force = true
+			result, err = engine.Run(context.Background())
+			if err != nil {
+				t.Fatalf("second Run() error = %v", err)
+			}
+			if len(result.Plan.Update) != 0 || len(result.Warnings) != 0 {
+				t.Errorf("second run updates %d files, warnings %+v; want none", len(result.Plan.Update), result.Warnings)
+			}
+		})
+	}
+}
+
+func TestEnsureWantedBy_SkipsTemplatesAndOtherTypes(t *testing.T) {
+	cfg, _ := newFreezeTestConfig(t, "")
+	cfg.Sync.EnsureWantedBy = "default.target"
+	e := NewEngine(cfg, nil, nil, testutil.TestLogger(), false)
+	for _, path := range []string{"/q/web@.container", "/q/app.network", "/q/app.kube"} {
+		if _, changed := e.ensureWantedBy(path, []byte("[Container]\nImage=nginx\n")); changed {
+			t.Errorf("ensureWantedBy(%s) changed the file", path)
+		}
+	}
+	if _, changed := e.ensureWantedBy("/q/web.container", []byte("[Container]\nImage=nginx\n")); !changed {
+		t.Error("ensureWantedBy(web.container) did not add WantedBy=")
+	}
+}
@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// marker is the content of a marker file such as freeze.json or pause.json
+// in the state directory. Freeze and Pause share its layout.
+type marker struct {
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// readMarker returns the marker stored as name in stateDir, or nil when the
+// file does not exist.
+func readMarker(stateDir, name string) (*marker, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	var m marker
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return &m, nil
+}
+
+// writeMarker stores a marker as name in stateDir. An existing marker keeps
+// its start time and gets the new reason.
+func writeMarker(stateDir, name, reason string, now time.Time) (*marker, error) {
+	m, err := readMarker(stateDir, name)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		m = &marker{Since: now.UTC()}
+	}
+	m.Reason = reason
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, name), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return m, nil
+}
+
+// clearMarker removes the marker stored as name in stateDir and reports
+// whether there was one.
+func clearMarker(stateDir, name string) (bool, error) {
+	err := os.Remove(filepath.Join(stateDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to remove %s: %w", name, err)
+	}
+	return true, nil
+}
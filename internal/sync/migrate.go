@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StateVersion is the schema version of the state files this build writes.
+//
+// A change to State that older files do not satisfy as they are (a new
+// field derived from existing ones, a renamed or restructured field) bumps
+// it and appends the conversion from the previous version to
+// stateMigrations. Fields a migration reads but the current schema no longer
+// uses stay on State, like the legacy Commit, so older files still decode.
+const StateVersion = 1
+
+// stateMigrations[v] converts a state of schema version v to version v+1.
+var stateMigrations = []func(*State) error{
+	// Version 0 files were written before the schema was versioned and
+	// have the layout of version 1.
+	func(*State) error { return nil },
+}
+
+// ErrStateTooNew is returned for state files written by a newer quadsyncd.
+// Their fields may not survive a round trip through this build, so they are
+// neither read nor overwritten.
+var ErrStateTooNew = errors.New("state file was written by a newer quadsyncd")
+
+// migrateState upgrades state, as read from disk, to StateVersion in place.
+func migrateState(state *State) error {
+	if state.Version > StateVersion {
+		return fmt.Errorf("%w: schema version %d, this build supports up to %d", ErrStateTooNew, state.Version, StateVersion)
+	}
+	if state.Version < 0 {
+		return fmt.Errorf("invalid state schema version %d", state.Version)
+	}
+	for v := state.Version; v < StateVersion; v++ {
+		if err := stateMigrations[v](state); err != nil {
+			return fmt.Errorf("failed to migrate state from schema version %d to %d: %w", v, v+1, err)
+		}
+	}
+	state.Version = StateVersion
+	if state.ManagedFiles == nil {
+		state.ManagedFiles = make(map[string]ManagedFile)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestParseState(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantErr   error
+		wantFiles int
+	}{
+		{
+			name:      "unversioned",
+			data:      `{"commit":"abc123","managed_files":{"/q/app.container":{"source_path":"app.container","hash":"h1"}}}`,
+			wantFiles: 1,
+		},
+		{name: "unversioned without managed files", data: `{"commit":"abc123"}`},
+		{
+			name:      "current",
+			data:      `{"version":1,"managed_files":{"/q/app.container":{"source_path":"app.container","hash":"h1"}}}`,
+			wantFiles: 1,
+		},
+		{name: "newer", data: `{"version":99,"managed_files":{}}`, wantErr: ErrStateTooNew},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := ParseState([]byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseState() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseState() error = %v", err)
+			}
+			if state.Version != StateVersion {
+				t.Errorf("Version = %d, want %d", state.Version, StateVersion)
+			}
+			if state.ManagedFiles == nil || len(state.ManagedFiles) != tt.wantFiles {
+				t.Errorf("ManagedFiles = %v, want %d entries", state.ManagedFiles, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestParseState_RejectsNegativeVersion(t *testing.T) {
+	if _, err := ParseState([]byte(`{"version":-1}`)); err == nil {
+		t.Fatal("ParseState() error = nil, want an invalid version error")
+	}
+}
+
+func TestStateMigrationsCoverEveryVersion(t *testing.T) {
+	if len(stateMigrations) != StateVersion {
+		t.Fatalf("len(stateMigrations) = %d, want one per version below StateVersion (%d)", len(stateMigrations), StateVersion)
+	}
+}
+
+func TestRun_WritesStateVersion(t *testing.T) {
+	engine, _ := warningTestEngine(t, &testutil.MockSystemd{Available: true})
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	data, err := os.ReadFile(engine.cfg.StateFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.Version != StateVersion {
+		t.Errorf("state.json version = %d, want %d", raw.Version, StateVersion)
+	}
+}
+
+func TestRun_MigratesUnversionedState(t *testing.T) {
+	engine, quadletDir := warningTestEngine(t, &testutil.MockSystemd{Available: true})
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// Rewrite the state as a release from before versioning did.
+	state, err := engine.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Version = 0
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(engine.cfg.StateFilePath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.force = true
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n := len(result.Plan.Add) + len(result.Plan.Update); n != 0 {
+		t.Errorf("plan installs %d files, want none after migrating the state", n)
+	}
+	for _, w := range result.Warnings {
+		if w.Code == WarnStateUnreadable {
+			t.Errorf("unexpected warning %+v", w)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, "app.container")); err != nil {
+		t.Errorf("app.container: %v", err)
+	}
+}
+
+func TestRun_RefusesNewerState(t *testing.T) {
+	engine, quadletDir := warningTestEngine(t, &testutil.MockSystemd{Available: true})
+	newer := []byte(`{"version":99,"managed_files":{}}`)
+	if err := os.MkdirAll(engine.cfg.Paths.StateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(engine.cfg.StateFilePath(), newer, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := engine.Run(context.Background())
+	if !errors.Is(err, ErrStateTooNew) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrStateTooNew)
+	}
+	if data, _ := os.ReadFile(engine.cfg.StateFilePath()); string(data) != string(newer) {
+		t.Errorf("state file was rewritten: %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, "app.container")); !os.IsNotExist(err) {
+		t.Errorf("app.container was installed: %v", err)
+	}
+}
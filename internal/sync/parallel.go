@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"context"
+	"runtime"
+	gosync "sync"
+	"sync/atomic"
+)
+
+// minHashWorkers is the least number of goroutines hashing files while a
+// plan is built. Hashing is mostly waiting on storage (SD cards, network
+// disks), so even single-core hosts gain from a few reads in flight.
+const minHashWorkers = 4
+
+// hashWorkers returns how many files plan building hashes concurrently.
+func (e *Engine) hashWorkers() int {
+	if e.planWorkers > 0 {
+		return e.planWorkers
+	}
+	return max(runtime.GOMAXPROCS(0), minHashWorkers)
+}
+
+// forEachParallel calls fn for every index in [0, n) on at most workers
+// goroutines. After the first error no further calls start, and that error
+// is returned; a done ctx stops the loop the same way.
+func forEachParallel(ctx context.Context, n, workers int, fn func(ctx context.Context, i int) error) error {
+	workers = min(workers, n)
+	if workers <= 1 {
+		for i := range n {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(ctx, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		next     atomic.Int64
+		once     gosync.Once
+		firstErr error
+		wg       gosync.WaitGroup
+	)
+	for range workers {
+		wg.Go(func() {
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= n || ctx.Err() != nil {
+					return
+				}
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return context.Cause(ctx)
+}
@@ -0,0 +1,185 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestForEachParallel(t *testing.T) {
+	for _, workers := range []int{1, 4, 100} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			seen := make([]atomic.Int32, 50)
+			err := forEachParallel(context.Background(), len(seen), workers, func(_ context.Context, i int) error {
+				seen[i].Add(1)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("forEachParallel() error = %v", err)
+			}
+			for i := range seen {
+				if n := seen[i].Load(); n != 1 {
+					t.Errorf("index %d visited %d times, want 1", i, n)
+				}
+			}
+		})
+	}
+}
+
+func TestForEachParallel_Error(t *testing.T) {
+	boom := errors.New("boom")
+	var calls atomic.Int32
+	err := forEachParallel(context.Background(), 1000, 4, func(ctx context.Context, i int) error {
+		calls.Add(1)
+		if i == 3 {
+			return boom
+		}
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("forEachParallel() error = %v, want %v", err, boom)
+	}
+	if calls.Load() == 1000 {
+		t.Error("forEachParallel() kept going after an error")
+	}
+}
+
+func TestForEachParallel_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, workers := range []int{1, 4} {
+		err := forEachParallel(ctx, 10, workers, func(context.Context, int) error { return nil })
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("workers=%d: error = %v, want context.Canceled", workers, err)
+		}
+	}
+}
+
+func TestBuildPlan_ParallelMatchesSequential(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	quadletDir := filepath.Join(tmpDir, "quadlets")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	prev := &State{ManagedFiles: map[string]ManagedFile{}}
+	for i := range 40 {
+		name := fmt.Sprintf("app%02d.container", i)
+		if err := os.WriteFile(filepath.Join(srcDir, name), fmt.Appendf(nil, "[Container]\nImage=app:%d\n", i), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Every third file is managed with an outdated hash.
+		if i%3 == 0 {
+			prev.ManagedFiles[filepath.Join(quadletDir, name)] = ManagedFile{Hash: "old"}
+		}
+	}
+	prev.ManagedFiles[filepath.Join(quadletDir, "gone.container")] = ManagedFile{Hash: "gone"}
+
+	cfg := &config.Config{
+		Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
+		Sync:  config.SyncConfig{Prune: true},
+	}
+	sequential := NewEngine(cfg, nil, nil, testutil.TestLogger(), false)
+	sequential.planWorkers = 1
+	parallel := NewEngine(cfg, nil, nil, testutil.TestLogger(), false)
+	parallel.planWorkers = 8
+
+	want := buildPlanFromDir(t, sequential, srcDir, prev)
+	got := buildPlanFromDir(t, parallel, srcDir, prev)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parallel plan differs from sequential plan:\n%+v\n%+v", got, want)
+	}
+	if len(got.Add) != 26 || len(got.Update) != 14 || len(got.Delete) != 1 {
+		t.Errorf("plan add/update/delete = %d/%d/%d, want 26/14/1", len(got.Add), len(got.Update), len(got.Delete))
+	}
+}
+
+func TestRun_StatFastPath(t *testing.T) {
+	ms := &testutil.MockSystemd{Available: true}
+	engine, quadletDir := warningTestEngine(t, ms)
+	dest := filepath.Join(quadletDir, "app.container")
+
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	state, err := engine.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mf := state.ManagedFiles[dest]; !mf.statMatches(info) {
+		t.Fatalf("state entry %+v does not record the stat of the installed file", mf)
+	}
+
+	// Same size and mtime: trusted without hashing, so no drift is seen.
+	if err := os.WriteFile(dest, []byte("[Container]\nImage=xyz\n[Install]\nWantedBy=default.target\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(dest, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("warnings = %+v, want none for a file with the recorded stat", result.Warnings)
+	}
+
+	// A new mtime makes the plan hash the file and find the drift.
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(dest, later, later); err != nil {
+		t.Fatal(err)
+	}
+	result, err = engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("third Run: %v", err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != WarnDriftIgnored {
+		t.Errorf("warnings = %+v, want %s", result.Warnings, WarnDriftIgnored)
+	}
+}
+
+func TestRun_RecordsStatOfVerifiedFiles(t *testing.T) {
+	ms := &testutil.MockSystemd{Available: true}
+	engine, quadletDir := warningTestEngine(t, ms)
+	dest := filepath.Join(quadletDir, "app.container")
+
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	// Drop the stat, as in a state written before it was recorded.
+	state, err := engine.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf := state.ManagedFiles[dest]
+	mf.Size, mf.ModTime = 0, 0
+	state.ManagedFiles[dest] = mf
+	if err := engine.saveState(state); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	state, err = engine.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.ManagedFiles[dest].ModTime == 0 {
+		t.Errorf("state entry %+v: want the stat recorded after the file was verified", state.ManagedFiles[dest])
+	}
+}
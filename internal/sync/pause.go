@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// pauseFile is the name of the pause marker in the state directory.
+const pauseFile = "pause.json"
+
+// Pause records that syncs are paused by `quadsyncd pause` or POST
+// /-/pause. Unlike a Freeze, a paused sync does nothing at all: it neither
+// fetches nor plans, so no git host or registry is contacted while an
+// incident is being handled.
+type Pause struct {
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// PauseFilePath returns the path of the pause marker for stateDir. Like the
+// freeze marker it is kept next to state.json rather than in it.
+func PauseFilePath(stateDir string) string {
+	return filepath.Join(stateDir, pauseFile)
+}
+
+// ReadPause returns the pause recorded in stateDir, or nil when syncs are
+// not paused.
+func ReadPause(stateDir string) (*Pause, error) {
+	m, err := readMarker(stateDir, pauseFile)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return (*Pause)(m), nil
+}
+
+// SetPause pauses syncs in stateDir. An existing pause keeps its start time
+// and gets the new reason.
+func SetPause(stateDir, reason string, now time.Time) (*Pause, error) {
+	m, err := writeMarker(stateDir, pauseFile, reason, now)
+	if err != nil {
+		return nil, err
+	}
+	return (*Pause)(m), nil
+}
+
+// ClearPause resumes syncs in stateDir and reports whether they were
+// paused.
+func ClearPause(stateDir string) (bool, error) {
+	return clearMarker(stateDir, pauseFile)
+}
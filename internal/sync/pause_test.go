@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestPauseFile(t *testing.T) {
+	stateDir := filepath.Join(t.TempDir(), "state")
+
+	if p, err := ReadPause(stateDir); err != nil || p != nil {
+		t.Fatalf("ReadPause() = %v, %v; want not paused", p, err)
+	}
+	since := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	if _, err := SetPause(stateDir, "incident", since); err != nil {
+		t.Fatalf("SetPause() error = %v", err)
+	}
+	p, err := SetPause(stateDir, "still investigating", since.Add(time.Hour))
+	if err != nil || !p.Since.Equal(since) || p.Reason != "still investigating" {
+		t.Errorf("SetPause() again = %+v, %v; want original start and new reason", p, err)
+	}
+
+	if resumed, err := ClearPause(stateDir); err != nil || !resumed {
+		t.Errorf("ClearPause() = %v, %v; want resumed", resumed, err)
+	}
+	if resumed, err := ClearPause(stateDir); err != nil || resumed {
+		t.Errorf("ClearPause() again = %v, %v; want nothing to resume", resumed, err)
+	}
+}
+
+func TestEngine_Run_Paused(t *testing.T) {
+	content := "[Container]\nImage=nginx:1\n"
+	cfg, mockGit := newFreezeTestConfig(t, content)
+	if _, err := SetPause(cfg.Paths.StateDir, "incident", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	systemd := &testutil.MockSystemd{Available: true}
+	result, err := NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !result.Paused || result.Applied {
+		t.Errorf("result paused=%v applied=%v; want paused", result.Paused, result.Applied)
+	}
+	if mockGit.Called {
+		t.Error("paused sync fetched the repository")
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "web.container")); !os.IsNotExist(err) {
+		t.Errorf("paused sync wrote the quadlet: %v", err)
+	}
+	if systemd.ReloadCalled {
+		t.Error("paused sync reloaded systemd")
+	}
+	if entries, _ := ReadHistory(cfg.Paths.StateDir, 1); len(entries) != 1 || entries[0].Result != HistoryResultPaused {
+		t.Errorf("history = %+v, want a paused entry", entries)
+	}
+
+	// Dry runs still plan.
+	result, err = NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), true).Run(context.Background())
+	if err != nil {
+		t.Fatalf("dry Run() error = %v", err)
+	}
+	if result.Paused || len(result.Plan.Add) != 1 {
+		t.Errorf("dry run paused=%v add=%d; want a plan with one add", result.Paused, len(result.Plan.Add))
+	}
+}
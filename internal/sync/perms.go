@@ -0,0 +1,138 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+)
+
+// filePolicy is the resolved sync.file_mode, sync.dir_mode and sync.owner
+// applied to installed files and the directories created for them.
+type filePolicy struct {
+	fileMode os.FileMode // 0 keeps the source mode
+	dirMode  os.FileMode // 0 uses 0755 minus the umask
+	uid, gid int         // -1 leaves the ID unchanged
+}
+
+// newFilePolicy resolves the ownership settings of cfg against the local
+// user and group databases.
+func newFilePolicy(cfg config.SyncConfig) (*filePolicy, error) {
+	p := &filePolicy{
+		fileMode: cfg.FileMode.Perm(),
+		dirMode:  cfg.DirMode.Perm(),
+		uid:      -1,
+		gid:      -1,
+	}
+	if cfg.Owner == "" {
+		return p, nil
+	}
+	userName, groupName, err := config.SplitOwner(cfg.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if p.uid, err = lookupID(userName, func(name string) (string, error) {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		return u.Uid, nil
+	}); err != nil {
+		return nil, fmt.Errorf("sync.owner: unknown user %q: %w", userName, err)
+	}
+	if groupName != "" {
+		if p.gid, err = lookupID(groupName, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		}); err != nil {
+			return nil, fmt.Errorf("sync.owner: unknown group %q: %w", groupName, err)
+		}
+	}
+	return p, nil
+}
+
+// lookupID returns name as a number if it is one, and resolves it with
+// lookup otherwise.
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil && id >= 0 {
+		return id, nil
+	}
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// chowns reports whether the policy changes ownership.
+func (p *filePolicy) chowns() bool {
+	return p.uid >= 0 || p.gid >= 0
+}
+
+// mkdirAll creates dir and any missing parents, giving each directory it
+// creates the policy's mode and owner.
+func (p *filePolicy) mkdirAll(dir string) error {
+	var missing []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		if p.dirMode != 0 {
+			if err := os.Chmod(missing[i], p.dirMode); err != nil {
+				return err
+			}
+		}
+		if p.chowns() {
+			if err := os.Lchown(missing[i], p.uid, p.gid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply sets the policy's mode and owner on f. keepMode keeps f's current
+// mode, for files that must stay private such as decrypted secrets.
+func (p *filePolicy) apply(f *os.File, keepMode bool) error {
+	if p.fileMode != 0 && !keepMode {
+		if err := f.Chmod(p.fileMode); err != nil {
+			return err
+		}
+	}
+	if p.chowns() {
+		if err := f.Chown(p.uid, p.gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSourceMode rejects a source file that would be installed group- or
+// world-writable, for sync.strict_permissions without sync.file_mode.
+func checkSourceMode(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if mode := info.Mode().Perm(); mode&0022 != 0 {
+		return fmt.Errorf("%s has mode %04o, which is group- or world-writable; set sync.file_mode or fix the checkout umask (sync.strict_permissions)", path, mode)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// permRepoSetup writes a group-writable quadlet and a companion file in a
+// subdirectory, as a checkout made under umask 002 would.
+func permRepoSetup(destDir string) {
+	_ = os.MkdirAll(filepath.Join(destDir, "web"), 0755)
+	_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Container]\nImage=nginx\n"), 0664)
+	_ = os.WriteFile(filepath.Join(destDir, "web", "app.env"), []byte("A=1\n"), 0664)
+	_ = os.Chmod(filepath.Join(destDir, "web.container"), 0664)
+	_ = os.Chmod(filepath.Join(destDir, "web", "app.env"), 0664)
+}
+
+func TestRun_FilePolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync: config.SyncConfig{
+			Restart:  config.RestartNone,
+			FileMode: 0640,
+			DirMode:  0750,
+			Owner:    strconv.Itoa(os.Getuid()) + ":" + strconv.Itoa(os.Getgid()),
+		},
+	}
+	gitMock := &testutil.MockGitClient{CommitHash: "abc123", RepoSetup: permRepoSetup}
+
+	if _, err := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for path, want := range map[string]os.FileMode{
+		cfg.Paths.QuadletDir:                                  0750,
+		filepath.Join(cfg.Paths.QuadletDir, "web"):            0750,
+		filepath.Join(cfg.Paths.QuadletDir, "web.container"):  0640,
+		filepath.Join(cfg.Paths.QuadletDir, "web", "app.env"): 0640,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %04o, want %04o", path, got, want)
+		}
+	}
+}
+
+func TestRun_StrictPermissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileMode config.Mode
+		wantErr  string
+	}{
+		{name: "group-writable source rejected", wantErr: "group- or world-writable"},
+		{name: "file_mode overrides source mode", fileMode: 0644},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			cfg := &config.Config{
+				Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+				Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+				Sync:       config.SyncConfig{Restart: config.RestartNone, StrictPermissions: true, FileMode: tt.fileMode},
+			}
+			gitMock := &testutil.MockGitClient{CommitHash: "abc123", RepoSetup: permRepoSetup}
+
+			_, err := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run error = %v, want %q", err, tt.wantErr)
+				}
+				if _, statErr := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "web.container")); !os.IsNotExist(statErr) {
+					t.Error("nothing should be installed when a source is rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewFilePolicy_Owner(t *testing.T) {
+	tests := []struct {
+		owner   string
+		wantUID int
+		wantGID int
+		wantErr bool
+	}{
+		{owner: "", wantUID: -1, wantGID: -1},
+		{owner: "1000", wantUID: 1000, wantGID: -1},
+		{owner: "1000:100", wantUID: 1000, wantGID: 100},
+		{owner: "no-such-user-quadsyncd", wantErr: true},
+		{owner: "1000:no-such-group-quadsyncd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.owner, func(t *testing.T) {
+			p, err := newFilePolicy(config.SyncConfig{Owner: tt.owner})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newFilePolicy(%q) error = %v, wantErr %v", tt.owner, err, tt.wantErr)
+			}
+			if !tt.wantErr && (p.uid != tt.wantUID || p.gid != tt.wantGID) {
+				t.Errorf("newFilePolicy(%q) = %d:%d, want %d:%d", tt.owner, p.uid, p.gid, tt.wantUID, tt.wantGID)
+			}
+		})
+	}
+}
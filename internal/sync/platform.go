@@ -0,0 +1,27 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// hostOS is the platform quadsyncd runs on; tests override it.
+var hostOS = runtime.GOOS
+
+// ErrApplyUnsupported is returned (wrapped) by syncs that would apply
+// changes on a platform other than Linux.
+var ErrApplyUnsupported = errors.New("applying a sync requires Linux with systemd")
+
+// applySupported reports whether syncs can apply changes on this host.
+// Elsewhere, e.g. on a developer's macOS or Windows machine, the systemd
+// client and secret store are stubs and only dry runs and plans work.
+func applySupported() bool {
+	return hostOS == "linux"
+}
+
+// unsupportedApplyError explains how to use quadsyncd on hosts that cannot
+// apply syncs.
+func unsupportedApplyError() error {
+	return fmt.Errorf("%w, not %s; use `quadsyncd sync --dry-run` or `quadsyncd plan` to check a repository here", ErrApplyUnsupported, hostOS)
+}
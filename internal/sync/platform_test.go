@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/secrets"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func setHostOS(t *testing.T, goos string) {
+	t.Helper()
+	prev := hostOS
+	hostOS = goos
+	t.Cleanup(func() { hostOS = prev })
+}
+
+func TestNewSystemdClient_NonLinux(t *testing.T) {
+	setHostOS(t, "darwin")
+	for _, backend := range []config.SystemdBackend{config.SystemdShell, config.SystemdDBus} {
+		cfg := &config.Config{Systemd: config.SystemdConfig{Backend: backend}}
+		if _, ok := NewSystemdClient(cfg, testutil.TestLogger()).(*systemduser.Unsupported); !ok {
+			t.Errorf("backend %s: NewSystemdClient() is not the unsupported stub", backend)
+		}
+	}
+}
+
+func TestEngine_Run_NonLinux(t *testing.T) {
+	setHostOS(t, "windows")
+	content := "[Container]\nImage=nginx:1\n"
+	cfg, mockGit := newFreezeTestConfig(t, content)
+	cfg.Secrets.Enabled = true
+
+	_, err := NewEngine(cfg, mockGit, NewSystemdClient(cfg, testutil.TestLogger()), testutil.TestLogger(), false).Run(context.Background())
+	if !errors.Is(err, ErrApplyUnsupported) {
+		t.Fatalf("Run() error = %v, want ErrApplyUnsupported", err)
+	}
+	if mockGit.Called {
+		t.Error("refused sync fetched the repository")
+	}
+
+	engine := NewEngine(cfg, mockGit, NewSystemdClient(cfg, testutil.TestLogger()), testutil.TestLogger(), false)
+	result, err := engine.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(result.Plan.Add) != 1 {
+		t.Errorf("plan adds %d files, want 1", len(result.Plan.Add))
+	}
+	engine.defaultSecretStore()
+	if _, ok := engine.secretStore.(secrets.UnsupportedStore); !ok {
+		t.Errorf("secret store = %T, want the unsupported stub", engine.secretStore)
+	}
+}
@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// DestNotWritableError reports that a directory the plan writes to cannot be
+// written. It is returned before any file is changed.
+type DestNotWritableError struct {
+	Dir  string
+	Err  error
+	Hint string
+}
+
+// Error implements error.
+func (e *DestNotWritableError) Error() string {
+	return fmt.Sprintf("destination %s is not writable: %v (hint: %s)", e.Dir, e.Err, e.Hint)
+}
+
+// Unwrap returns the underlying filesystem error.
+func (e *DestNotWritableError) Unwrap() error {
+	return e.Err
+}
+
+// preflightDestinations checks that every directory plan writes to is
+// writable, so a read-only mount or wrong owner fails the sync before the
+// first file changes rather than halfway through the apply. With
+// sync.preflight_write_probe it also creates and removes a probe file, which
+// catches denials access(2) does not report (e.g. SELinux, immutable dirs).
+func (e *Engine) preflightDestinations(plan *Plan) error {
+	for _, dir := range planDestDirs(plan) {
+		target := nearestExistingDir(dir)
+		err := checkDirWritable(target)
+		if err == nil && e.cfg.Sync.PreflightWriteProbe {
+			err = probeDirWrite(target)
+		}
+		if err != nil {
+			return &DestNotWritableError{Dir: target, Err: err, Hint: writeHint(target, err)}
+		}
+	}
+	return nil
+}
+
+// planDestDirs returns the sorted set of directories plan adds, updates or
+// deletes files in.
+func planDestDirs(plan *Plan) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, ops := range [][]FileOp{plan.Add, plan.Update, plan.Delete} {
+		for _, op := range ops {
+			dir := filepath.Dir(op.DestPath)
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// nearestExistingDir returns dir or its closest existing ancestor; that is
+// where missing directories would be created.
+func nearestExistingDir(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// probeDirWrite creates and removes a temporary file in dir.
+func probeDirWrite(dir string) error {
+	f, err := os.CreateTemp(dir, ".quadsyncd-probe-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
+
+// writeHint suggests a fix for a write failure on dir.
+func writeHint(dir string, err error) string {
+	switch {
+	case errors.Is(err, syscall.EROFS):
+		return "the filesystem is mounted read-only; remount it read-write or point paths.quadlet_dir at a writable location"
+	case errors.Is(err, fs.ErrPermission):
+		if uid, ok := ownerUID(dir); ok && uid != os.Getuid() {
+			return fmt.Sprintf("it is owned by uid %d but quadsyncd runs as uid %d; run quadsyncd as the owning user or fix ownership with chown", uid, os.Getuid())
+		}
+		return fmt.Sprintf("check its permissions, e.g. chmod u+w %s", dir)
+	default:
+		return "check that the directory exists and is writable by the user running quadsyncd"
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package sync
+
+// checkDirWritable falls back to probing dir with a temporary file where
+// access(2) is not available.
+func checkDirWritable(dir string) error {
+	return probeDirWrite(dir)
+}
+
+// ownerUID reports no owner: the platform has no uids.
+func ownerUID(string) (int, bool) {
+	return 0, false
+}
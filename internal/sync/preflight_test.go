@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestPlanDestDirs(t *testing.T) {
+	plan := &Plan{
+		Add:    []FileOp{{DestPath: "/q/web.container"}, {DestPath: "/etc/caddy/Caddyfile"}},
+		Update: []FileOp{{DestPath: "/q/app.env"}},
+		Delete: []FileOp{{DestPath: "/q/sub/old.volume"}},
+	}
+	want := []string{"/etc/caddy", "/q", "/q/sub"}
+	if got := planDestDirs(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("planDestDirs() = %v, want %v", got, want)
+	}
+}
+
+func TestNearestExistingDir(t *testing.T) {
+	root := t.TempDir()
+	if got := nearestExistingDir(filepath.Join(root, "a", "b", "c")); got != root {
+		t.Errorf("nearestExistingDir() = %q, want %q", got, root)
+	}
+	if got := nearestExistingDir(root); got != root {
+		t.Errorf("nearestExistingDir(existing) = %q, want %q", got, root)
+	}
+}
+
+func TestWriteHint(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "read-only filesystem", err: &os.PathError{Op: "access", Path: dir, Err: syscall.EROFS}, want: "mounted read-only"},
+		{name: "permission denied", err: &os.PathError{Op: "access", Path: dir, Err: syscall.EACCES}, want: "chmod u+w"},
+		{name: "other", err: errors.New("boom"), want: "writable by the user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writeHint(dir, tt.err); !strings.Contains(got, tt.want) {
+				t.Errorf("writeHint() = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreflightDestinations_WritableDir(t *testing.T) {
+	engine, quadletDir := warningTestEngine(t, &testutil.MockSystemd{Available: true})
+	engine.cfg.Sync.PreflightWriteProbe = true
+	plan := &Plan{Add: []FileOp{{DestPath: filepath.Join(quadletDir, "web.container")}}}
+
+	if err := engine.preflightDestinations(plan); err != nil {
+		t.Fatalf("preflightDestinations() = %v, want nil", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(quadletDir))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".quadsyncd-probe-") {
+			t.Errorf("probe file %s was not removed", e.Name())
+		}
+	}
+}
+
+func TestRun_ReadOnlyQuadletDir(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("permission checks do not apply to root")
+	}
+	ms := &testutil.MockSystemd{Available: true}
+	engine, quadletDir := warningTestEngine(t, ms)
+	if err := os.MkdirAll(quadletDir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(quadletDir, 0755) })
+
+	_, err := engine.Run(context.Background())
+	var nwErr *DestNotWritableError
+	if !errors.As(err, &nwErr) {
+		t.Fatalf("Run() error = %v, want DestNotWritableError", err)
+	}
+	if nwErr.Dir != quadletDir || !strings.Contains(nwErr.Hint, "chmod") {
+		t.Errorf("unexpected error details: %+v", nwErr)
+	}
+	if ms.ValidateCalled {
+		t.Error("nothing should be staged or validated for an unwritable destination")
+	}
+
+	// A dry run reports the problem as a warning instead.
+	engine.dryRun = true
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("dry-run Run() = %v", err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != WarnDestNotWritable {
+		t.Errorf("warnings = %+v, want one %s", result.Warnings, WarnDestNotWritable)
+	}
+}
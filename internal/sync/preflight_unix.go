@@ -0,0 +1,33 @@
+//go:build unix
+
+package sync
+
+import (
+	"os"
+	"syscall"
+)
+
+// accessWriteOK is W_OK for access(2); the value is the same on Linux and
+// macOS but syscall does not export it.
+const accessWriteOK = 0x2
+
+// checkDirWritable asks the kernel whether the current user may write to dir.
+func checkDirWritable(dir string) error {
+	if err := syscall.Access(dir, accessWriteOK); err != nil {
+		return &os.PathError{Op: "access", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// ownerUID returns the owning uid of path where the platform exposes it.
+func ownerUID(path string) (int, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(st.Uid), true
+}
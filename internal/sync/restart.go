@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+)
+
+// RestartCoordinator is shared by every engine created from one RunnerFactory
+// so overlapping syncs do not restart the same unit twice in quick
+// succession. A restart that began after a caller's daemon-reload already
+// picked up that caller's changes, so the caller's request for the same unit
+// is dropped.
+type RestartCoordinator struct {
+	systemd systemduser.Systemd
+
+	mu      gosync.Mutex
+	started map[string]time.Time // unit -> when its last restart was issued
+}
+
+// NewRestartCoordinator creates a coordinator issuing restarts through systemd.
+func NewRestartCoordinator(systemd systemduser.Systemd) *RestartCoordinator {
+	return &RestartCoordinator{
+		systemd: systemd,
+		started: make(map[string]time.Time),
+	}
+}
+
+// TryRestartUnits restarts the given units, whose new definitions became
+// visible to systemd at reloadedAt. Units that another caller started
+// restarting after reloadedAt are skipped. It returns the units that were
+// actually restarted and the ones that were skipped.
+func (c *RestartCoordinator) TryRestartUnits(ctx context.Context, units []string, reloadedAt time.Time) (restarted, skipped []string, err error) {
+	c.mu.Lock()
+	now := time.Now()
+	for _, unit := range units {
+		if last, ok := c.started[unit]; ok && last.After(reloadedAt) {
+			skipped = append(skipped, unit)
+			continue
+		}
+		c.started[unit] = now
+		restarted = append(restarted, unit)
+	}
+	c.mu.Unlock()
+
+	if len(restarted) == 0 {
+		return nil, skipped, nil
+	}
+
+	if err := c.systemd.TryRestartUnits(ctx, restarted); err != nil {
+		// A failed restart must not suppress a later caller's retry.
+		c.mu.Lock()
+		for unit := range restartFailures(err, restarted) {
+			if c.started[unit].Equal(now) {
+				delete(c.started, unit)
+			}
+		}
+		c.mu.Unlock()
+		return restarted, skipped, err
+	}
+	return restarted, skipped, nil
+}
+
+// restartFailures maps the units of a restart that failed with err to their
+// error. An error the systemd client did not attribute to single units
+// counts as a failure of all of them.
+func restartFailures(err error, units []string) map[string]error {
+	if err == nil {
+		return nil
+	}
+	failed := make(map[string]error)
+	if ues := systemduser.UnitErrors(err); len(ues) > 0 {
+		for _, ue := range ues {
+			failed[ue.Unit] = ue.Err
+		}
+		return failed
+	}
+	for _, unit := range units {
+		failed[unit] = err
+	}
+	return failed
+}
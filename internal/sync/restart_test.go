@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestRestartCoordinator_SkipsUnitsRestartedAfterReload(t *testing.T) {
+	sd := &testutil.MockSystemd{Available: true}
+	c := NewRestartCoordinator(sd)
+
+	// Both callers reloaded before either restarted: the second one's change
+	// is already live when the first restart runs.
+	reloadA := time.Now()
+	reloadB := time.Now()
+
+	restarted, skipped, err := c.TryRestartUnits(context.Background(), []string{"pod.service", "web.service"}, reloadA)
+	if err != nil {
+		t.Fatalf("first restart: %v", err)
+	}
+	if !reflect.DeepEqual(restarted, []string{"pod.service", "web.service"}) || len(skipped) != 0 {
+		t.Fatalf("first restart: restarted=%v skipped=%v", restarted, skipped)
+	}
+
+	restarted, skipped, err = c.TryRestartUnits(context.Background(), []string{"db.service", "pod.service"}, reloadB)
+	if err != nil {
+		t.Fatalf("second restart: %v", err)
+	}
+	if !reflect.DeepEqual(restarted, []string{"db.service"}) {
+		t.Errorf("second restart: restarted=%v, want [db.service]", restarted)
+	}
+	if !reflect.DeepEqual(skipped, []string{"pod.service"}) {
+		t.Errorf("second restart: skipped=%v, want [pod.service]", skipped)
+	}
+	if !reflect.DeepEqual(sd.RestartedUnits, []string{"db.service"}) {
+		t.Errorf("systemd restarted %v, want [db.service]", sd.RestartedUnits)
+	}
+}
+
+func TestRestartCoordinator_RestartsAgainAfterLaterReload(t *testing.T) {
+	sd := &testutil.MockSystemd{Available: true}
+	c := NewRestartCoordinator(sd)
+
+	if _, _, err := c.TryRestartUnits(context.Background(), []string{"pod.service"}, time.Now()); err != nil {
+		t.Fatalf("first restart: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	restarted, skipped, err := c.TryRestartUnits(context.Background(), []string{"pod.service"}, time.Now())
+	if err != nil {
+		t.Fatalf("second restart: %v", err)
+	}
+	if len(restarted) != 1 || len(skipped) != 0 {
+		t.Errorf("a reload after the last restart must restart again: restarted=%v skipped=%v", restarted, skipped)
+	}
+}
+
+func TestRestartCoordinator_FailedRestartIsNotRemembered(t *testing.T) {
+	sd := &testutil.MockSystemd{Available: true, RestartErr: errors.New("boom")}
+	c := NewRestartCoordinator(sd)
+
+	reloadedAt := time.Now()
+	if _, _, err := c.TryRestartUnits(context.Background(), []string{"pod.service"}, reloadedAt); err == nil {
+		t.Fatal("expected restart error")
+	}
+
+	sd.RestartErr = nil
+	restarted, skipped, err := c.TryRestartUnits(context.Background(), []string{"pod.service"}, reloadedAt)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if len(restarted) != 1 || len(skipped) != 0 {
+		t.Errorf("failed restart must not suppress retry: restarted=%v skipped=%v", restarted, skipped)
+	}
+}
+
+func TestRestartCoordinator_OnlyFailedUnitsAreRetried(t *testing.T) {
+	sd := &testutil.MockSystemd{Available: true, RestartFailures: map[string]error{"db.service": errors.New("job failed")}}
+	c := NewRestartCoordinator(sd)
+
+	reloadedAt := time.Now()
+	if _, _, err := c.TryRestartUnits(context.Background(), []string{"web.service", "db.service"}, reloadedAt); err == nil {
+		t.Fatal("expected restart error")
+	}
+
+	sd.RestartFailures = nil
+	restarted, skipped, err := c.TryRestartUnits(context.Background(), []string{"web.service", "db.service"}, reloadedAt)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if len(restarted) != 1 || restarted[0] != "db.service" || len(skipped) != 1 || skipped[0] != "web.service" {
+		t.Errorf("retry restarted=%v skipped=%v, want only db.service restarted", restarted, skipped)
+	}
+}
@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+)
+
+// Rollback syncs the repositories back to the commits of an earlier
+// successful run from the sync history. With to empty that is the newest
+// successful run whose commits differ from the last applied sync; otherwise
+// it is the run that synced commit to (a full SHA or unambiguous prefix).
+//
+// The commits are checked out and go through the normal plan, apply and
+// restart path. Like sync --ref this affects only this run: the next sync
+// returns to the configured refs.
+func (e *Engine) Rollback(ctx context.Context, to string) (*Result, error) {
+	entries, err := ReadHistory(e.cfg.Paths.StateDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	state, err := e.loadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current state: %w", err)
+	}
+
+	target, err := e.rollbackTarget(entries, state.Revisions, to)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]SpecOverride, len(target))
+	for _, spec := range e.cfg.EffectiveRepositories() {
+		if sha, ok := target[spec.URL]; ok {
+			overrides[spec.URL] = SpecOverride{Commit: sha}
+		}
+	}
+	if len(overrides) == 0 {
+		return nil, fmt.Errorf("none of the repositories synced by that run are configured")
+	}
+	e.specOverrides = overrides
+
+	e.logger.Info("rolling back", logging.Event(logging.EventRollbackStarted), "revisions", target)
+	return e.Run(ctx)
+}
+
+// rollbackTarget picks the revisions to roll back to from entries (newest
+// first), as described for Rollback.
+func (e *Engine) rollbackTarget(entries []HistoryEntry, current map[string]string, to string) (map[string]string, error) {
+	var matches []map[string]string
+	for _, entry := range entries {
+		if entry.Result != HistoryResultSuccess {
+			continue
+		}
+		revs := e.historyRevisions(entry)
+		if len(revs) == 0 {
+			continue
+		}
+		if to == "" {
+			if !maps.Equal(revs, current) {
+				return revs, nil
+			}
+			continue
+		}
+		for _, sha := range revs {
+			if strings.HasPrefix(sha, to) && !containsRevisions(matches, revs) {
+				matches = append(matches, revs)
+				break
+			}
+		}
+	}
+
+	switch {
+	case to == "":
+		return nil, fmt.Errorf("no earlier successful sync with different commits in the history")
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no successful sync of commit %q in the history", to)
+	case len(matches) > 1:
+		return nil, fmt.Errorf("commit %q is ambiguous (%d matching runs)", to, len(matches))
+	}
+	return matches[0], nil
+}
+
+// historyRevisions returns the commits entry synced per repository URL.
+// Single-repository entries only record the commit, which is attributed to
+// the single configured repository.
+func (e *Engine) historyRevisions(entry HistoryEntry) map[string]string {
+	if len(entry.Revisions) > 0 {
+		return entry.Revisions
+	}
+	repos := e.cfg.EffectiveRepositories()
+	if entry.Commit == "" || len(repos) != 1 {
+		return nil
+	}
+	return map[string]string{repos[0].URL: entry.Commit}
+}
+
+// containsRevisions reports whether list holds a map equal to revs.
+func containsRevisions(list []map[string]string, revs map[string]string) bool {
+	for _, m := range list {
+		if maps.Equal(m, revs) {
+			return true
+		}
+	}
+	return false
+}
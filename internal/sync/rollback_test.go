@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	const url = "https://github.com/test/repo.git"
+	engine := &Engine{cfg: &config.Config{Repository: &config.RepoSpec{URL: url}}}
+	entries := []HistoryEntry{ // newest first
+		{Commit: "cccc3333", Result: HistoryResultSuccess},
+		{Commit: "cccc3333", Result: HistoryResultSuccess},
+		{Commit: "dead0000", Result: HistoryResultError},
+		{Commit: "bbbb2222", Result: HistoryResultSuccess},
+		{Commit: "bbbb1111", Result: HistoryResultSuccess},
+	}
+	current := map[string]string{url: "cccc3333"}
+
+	tests := []struct {
+		name    string
+		to      string
+		want    string
+		wantErr string
+	}{
+		{"previous commit", "", "bbbb2222", ""},
+		{"explicit prefix", "bbbb1", "bbbb1111", ""},
+		{"failed run is not a target", "dead", "", "no successful sync"},
+		{"ambiguous prefix", "bbbb", "", "ambiguous"},
+		{"unknown commit", "ffff", "", "no successful sync"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.rollbackTarget(entries, current, tt.to)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("rollbackTarget() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rollbackTarget() error = %v", err)
+			}
+			if want := map[string]string{url: tt.want}; !reflect.DeepEqual(got, want) {
+				t.Errorf("rollbackTarget() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	if _, err := engine.rollbackTarget(entries[:2], current, ""); err == nil {
+		t.Error("rollbackTarget() found a target when every run synced the current commit")
+	}
+}
+
+func TestEngine_Rollback(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlets")
+	stateDir := filepath.Join(tmpDir, "state")
+	if err := os.MkdirAll(quadletDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const url = "https://github.com/test/repo.git"
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: url, Ref: "refs/heads/main"},
+		Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: stateDir},
+		Sync:       config.SyncConfig{Restart: config.RestartNone},
+	}
+
+	var usedRef string
+	mockGit := &testutil.MockGitClient{
+		RepoSetup: func(destDir string) {
+			if err := os.MkdirAll(destDir, 0755); err != nil {
+				t.Fatalf("repoSetup MkdirAll: %v", err)
+			}
+		},
+	}
+	factory := func(_ config.AuthConfig) git.Client {
+		return &capturingGitClient{inner: mockGit, usedRef: &usedRef}
+	}
+	run := func(commit string, rollback bool) *Result {
+		t.Helper()
+		mockGit.CommitHash = commit
+		engine := NewEngineWithFactory(cfg, factory, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+		var result *Result
+		var err error
+		if rollback {
+			result, err = engine.Rollback(context.Background(), "")
+		} else {
+			result, err = engine.Run(context.Background())
+		}
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		return result
+	}
+
+	run("aaaa1111", false)
+	run("bbbb2222", false)
+	result := run("aaaa1111", true)
+	if usedRef != "aaaa1111" || result.Refs[url] != "aaaa1111" {
+		t.Errorf("rollback checked out %q, result refs %v, want aaaa1111", usedRef, result.Refs)
+	}
+
+	entries, err := ReadHistory(stateDir, 1)
+	if err != nil || len(entries) != 1 || entries[0].Ref != "aaaa1111" {
+		t.Errorf("history = %+v, %v; want rollback recorded with ref aaaa1111", entries, err)
+	}
+}
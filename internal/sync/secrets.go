@@ -0,0 +1,242 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/multirepo"
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+	"github.com/schaermu/quadsyncd/internal/secrets"
+)
+
+// secretItem is a secret declared in the manifest of a loaded repository.
+type secretItem struct {
+	multirepo.RepoSecret
+	SourceRepo string
+	SourceSHA  string
+}
+
+// collectSecrets returns the secrets declared across repoStates, sorted by
+// name. Unlike files, secrets have no priority-based override, so a name
+// declared by two repositories is an error.
+func collectSecrets(repoStates []multirepo.RepoState) ([]secretItem, error) {
+	owner := make(map[string]string)
+	var items []secretItem
+	for _, rs := range repoStates {
+		for _, s := range rs.Secrets {
+			if prev, dup := owner[s.Name]; dup {
+				return nil, fmt.Errorf("secret %s is declared by both %s and %s", s.Name, prev, rs.Spec.URL)
+			}
+			owner[s.Name] = rs.Spec.URL
+			items = append(items, secretItem{RepoSecret: s, SourceRepo: rs.Spec.URL, SourceSHA: rs.Commit})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+// secretUsers maps secret names to the units whose quadlet files reference
+// them with a Secret= line, so changing a secret restarts its consumers
+// without listing them in the manifest.
+func secretUsers(items []multirepo.EffectiveItem) (map[string][]string, error) {
+	users := make(map[string][]string)
+	for _, item := range items {
+		if item.DestPath != "" || !quadlet.IsQuadletFile(item.MergeKey) {
+			continue
+		}
+		names, err := secretRefs(item.AbsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", item.AbsPath, err)
+		}
+		unit := quadlet.UnitNameFromQuadlet(item.MergeKey)
+		for _, name := range names {
+			users[name] = append(users[name], unit)
+		}
+	}
+	return users, nil
+}
+
+// secretRefs returns the secret names referenced by Secret= lines in the
+// quadlet file at path. The name is the first comma-separated field.
+func secretRefs(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || strings.TrimSpace(key) != "Secret" {
+			continue
+		}
+		name, _, _ := strings.Cut(value, ",")
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// defaultSecretStore sets the podman secret store unless one is injected.
+// Off Linux a stub store lets plans include secrets.
+func (e *Engine) defaultSecretStore() {
+	if e.secretStore != nil {
+		return
+	}
+	if !applySupported() {
+		e.secretStore = secrets.UnsupportedStore{GOOS: hostOS}
+		return
+	}
+	e.secretStore = secrets.NewPodmanStore(e.cfg.Timeouts.Podman)
+}
+
+// buildSecretOps computes the secret operations of a sync: secrets that are
+// new, whose encrypted content changed, or that are missing from the store
+// are (re)created; with sync.prune, secrets no longer declared are removed.
+// When secrets are disabled, declared secrets are skipped with a warning and
+// previously managed ones are left alone.
+func (e *Engine) buildSecretOps(ctx context.Context, prevState *State, items []secretItem, effective []multirepo.EffectiveItem) ([]SecretOp, error) {
+	if !e.cfg.Secrets.Enabled {
+		for _, item := range items {
+			e.warn(WarnSecretsDisabled, item.Name, "repository declares a secret but secrets are disabled",
+				"secret", item.Name,
+				"repo", item.SourceRepo,
+				"remediation", "set secrets.enabled: true or remove the secret from "+multirepo.ManifestFileName)
+		}
+		return nil, nil
+	}
+	e.defaultSecretStore()
+	if e.decrypter == nil {
+		e.decrypter = secrets.NewCLIDecrypter(e.cfg.Secrets.AgeIdentityFile, e.cfg.Timeouts.Podman)
+	}
+
+	users, err := secretUsers(effective)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []SecretOp
+	declared := make(map[string]bool, len(items))
+	for _, item := range items {
+		declared[item.Name] = true
+		hash, err := fileHash(item.AbsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute hash for %s: %w", item.AbsPath, err)
+		}
+
+		op := SecretOp{
+			Name:         item.Name,
+			Source:       item.Source,
+			SourcePath:   item.AbsPath,
+			Format:       item.Format,
+			Hash:         hash,
+			RestartUnits: mergeUnits(item.RestartUnits, users[item.Name]),
+			SourceRepo:   item.SourceRepo,
+			SourceSHA:    item.SourceSHA,
+		}
+
+		prev, managed := prevState.Secrets[item.Name]
+		exists, err := e.secretStore.Exists(ctx, item.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check secret %s: %w", item.Name, err)
+		}
+		switch {
+		case !exists:
+			op.Action = SecretCreate
+		case !managed || prev.Hash != hash:
+			op.Action = SecretUpdate
+		default:
+			continue
+		}
+		ops = append(ops, op)
+	}
+
+	if e.cfg.Sync.Prune {
+		names := make([]string, 0, len(prevState.Secrets))
+		for name := range prevState.Secrets {
+			if !declared[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ops = append(ops, SecretOp{
+				Name:         name,
+				Action:       SecretDelete,
+				RestartUnits: prevState.Secrets[name].RestartUnits,
+			})
+		}
+	}
+	return ops, nil
+}
+
+// decryptSecrets decrypts the secrets ops create or update, keyed by name.
+// It runs before anything is applied so a missing key or a corrupt file
+// fails the sync without side effects.
+func (e *Engine) decryptSecrets(ctx context.Context, ops []SecretOp) (map[string][]byte, error) {
+	plain := make(map[string][]byte)
+	for _, op := range ops {
+		if op.Action == SecretDelete {
+			continue
+		}
+		data, err := e.decrypter.Decrypt(ctx, op.Format, op.SourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", op.Name, err)
+		}
+		plain[op.Name] = data
+	}
+	return plain, nil
+}
+
+// putSecrets loads the decrypted secrets into the store.
+func (e *Engine) putSecrets(ctx context.Context, ops []SecretOp, plain map[string][]byte) error {
+	for _, op := range ops {
+		if op.Action == SecretDelete {
+			continue
+		}
+		e.logger.Info("loading secret", logging.Event(logging.EventSecretPut), "secret", op.Name, "action", string(op.Action))
+		if err := e.secretStore.Put(ctx, op.Name, plain[op.Name]); err != nil {
+			return fmt.Errorf("failed to load secret %s: %w", op.Name, err)
+		}
+	}
+	return nil
+}
+
+// removeSecrets deletes pruned secrets from the store. It runs after the
+// plan is applied, so quadlets that used a secret are gone first.
+func (e *Engine) removeSecrets(ctx context.Context, ops []SecretOp) error {
+	for _, op := range ops {
+		if op.Action != SecretDelete {
+			continue
+		}
+		e.logger.Info("removing secret", logging.Event(logging.EventSecretRemove), "secret", op.Name)
+		if err := e.secretStore.Remove(ctx, op.Name); err != nil {
+			return fmt.Errorf("failed to remove secret %s: %w", op.Name, err)
+		}
+	}
+	return nil
+}
+
+// mergeUnits returns the sorted union of a and b.
+func mergeUnits(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, unit := range append(append([]string{}, a...), b...) {
+		if !seen[unit] {
+			seen[unit] = true
+			out = append(out, unit)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,313 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/multirepo"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// fakeSecretStore is an in-memory secrets.Store.
+type fakeSecretStore struct {
+	data    map[string][]byte
+	puts    []string
+	removes []string
+}
+
+func (s *fakeSecretStore) Exists(_ context.Context, name string) (bool, error) {
+	_, ok := s.data[name]
+	return ok, nil
+}
+
+func (s *fakeSecretStore) Put(_ context.Context, name string, data []byte) error {
+	if s.data == nil {
+		s.data = make(map[string][]byte)
+	}
+	s.data[name] = append([]byte(nil), data...)
+	s.puts = append(s.puts, name)
+	return nil
+}
+
+func (s *fakeSecretStore) Remove(_ context.Context, name string) error {
+	delete(s.data, name)
+	s.removes = append(s.removes, name)
+	return nil
+}
+
+// fakeDecrypter "decrypts" a file by prefixing its content with the format.
+type fakeDecrypter struct {
+	err error
+}
+
+func (d fakeDecrypter) Decrypt(_ context.Context, format, path string) ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(format+":"), data...), nil
+}
+
+// secretRepoSetup writes a repo with web.container using the db secret and
+// a manifest declaring it.
+func secretRepoSetup(ciphertext string) func(string) {
+	return func(destDir string) {
+		_ = os.MkdirAll(filepath.Join(destDir, "secrets"), 0755)
+		_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Container]\nImage=nginx\nSecret=db,type=env,target=DB_PASSWORD\n[Install]\nWantedBy=default.target\n"), 0644)
+		_ = os.WriteFile(filepath.Join(destDir, "secrets", "db.age"), []byte(ciphertext), 0644)
+		_ = os.WriteFile(filepath.Join(destDir, multirepo.ManifestFileName),
+			[]byte("secrets:\n  - name: db\n    source: secrets/db.age\n    restart: [backup.service]\n"), 0644)
+	}
+}
+
+func secretTestConfig(tmpDir string, enabled bool) *config.Config {
+	return &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Prune: true, Restart: config.RestartChanged},
+		Secrets:    config.SecretsConfig{Enabled: enabled},
+	}
+}
+
+func TestRun_Secrets(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := secretTestConfig(tmpDir, true)
+	gitMock := &testutil.MockGitClient{CommitHash: "abc123", RepoSetup: secretRepoSetup("v1")}
+	store := &fakeSecretStore{}
+	run := func() *Result {
+		t.Helper()
+		engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+		engine.secretStore = store
+		engine.decrypter = fakeDecrypter{}
+		result, err := engine.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return result
+	}
+
+	result := run()
+	if got := string(store.data["db"]); got != "age:v1" {
+		t.Errorf("secret db = %q, want decrypted content", got)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "secrets", "db.age")); !os.IsNotExist(err) {
+		t.Errorf("encrypted secret must not be synced into the quadlet dir, stat err = %v", err)
+	}
+	if want := []string{"backup.service", "web.service"}; !reflect.DeepEqual(result.RestartedUnits, want) {
+		t.Errorf("RestartedUnits = %v, want %v", result.RestartedUnits, want)
+	}
+	state, err := ReadStateFile(cfg.StateFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, ok := state.Secrets["db"]
+	if !ok || ms.SourcePath != "secrets/db.age" || ms.SourceSHA != "abc123" {
+		t.Errorf("state secret = %+v, %v", ms, ok)
+	}
+
+	// Unchanged ciphertext: nothing to do.
+	result = run()
+	if len(store.puts) != 1 || len(result.Plan.Secrets) != 0 {
+		t.Errorf("unchanged secret was reloaded: puts = %v, plan = %+v", store.puts, result.Plan.Secrets)
+	}
+
+	// Changed ciphertext updates the secret and restarts its users.
+	gitMock.CommitHash = "def456"
+	gitMock.RepoSetup = secretRepoSetup("v2")
+	result = run()
+	if got := string(store.data["db"]); got != "age:v2" {
+		t.Errorf("secret db = %q after update", got)
+	}
+	if want := []string{"backup.service", "web.service"}; !reflect.DeepEqual(result.RestartedUnits, want) {
+		t.Errorf("RestartedUnits after update = %v, want %v", result.RestartedUnits, want)
+	}
+
+	// Removing the declaration prunes the secret.
+	gitMock.CommitHash = "789abc"
+	gitMock.RepoSetup = func(destDir string) {
+		_ = os.Remove(filepath.Join(destDir, multirepo.ManifestFileName))
+		_ = os.RemoveAll(filepath.Join(destDir, "secrets"))
+	}
+	run()
+	if !reflect.DeepEqual(store.removes, []string{"db"}) {
+		t.Errorf("removed secrets = %v, want [db]", store.removes)
+	}
+	if state, err = ReadStateFile(cfg.StateFilePath()); err != nil || len(state.Secrets) != 0 {
+		t.Errorf("state secrets after prune = %v, %v", state.Secrets, err)
+	}
+}
+
+func TestRun_SecretRecreatedWhenMissing(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := secretTestConfig(tmpDir, true)
+	gitMock := &testutil.MockGitClient{CommitHash: "abc123", RepoSetup: secretRepoSetup("v1")}
+	store := &fakeSecretStore{}
+	for i := 0; i < 2; i++ {
+		engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+		engine.secretStore = store
+		engine.decrypter = fakeDecrypter{}
+		if _, err := engine.Run(context.Background()); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		// Simulate `podman secret rm` behind quadsyncd's back.
+		delete(store.data, "db")
+	}
+	if !reflect.DeepEqual(store.puts, []string{"db", "db"}) {
+		t.Errorf("puts = %v, want the secret recreated", store.puts)
+	}
+}
+
+func TestRun_SecretsDisabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := secretTestConfig(tmpDir, false)
+	gitMock := &testutil.MockGitClient{CommitHash: "abc123", RepoSetup: secretRepoSetup("v1")}
+	store := &fakeSecretStore{}
+
+	engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+	engine.secretStore = store
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(store.puts) != 0 {
+		t.Errorf("secrets loaded while disabled: %v", store.puts)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != WarnSecretsDisabled || result.Warnings[0].Subject != "db" {
+		t.Errorf("warnings = %+v, want one %s for db", result.Warnings, WarnSecretsDisabled)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "secrets", "db.age")); !os.IsNotExist(err) {
+		t.Errorf("encrypted secret must not be synced into the quadlet dir, stat err = %v", err)
+	}
+}
+
+func TestRun_SecretDecryptFailureAppliesNothing(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := secretTestConfig(tmpDir, true)
+	gitMock := &testutil.MockGitClient{CommitHash: "abc123", RepoSetup: secretRepoSetup("v1")}
+	store := &fakeSecretStore{}
+
+	engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+	engine.secretStore = store
+	engine.decrypter = fakeDecrypter{err: errors.New("no identity matched")}
+	_, err := engine.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to decrypt secret db") {
+		t.Fatalf("Run error = %v, want decrypt failure", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "web.container")); !os.IsNotExist(err) {
+		t.Errorf("quadlet written despite decrypt failure, stat err = %v", err)
+	}
+	if len(store.puts) != 0 {
+		t.Errorf("secrets loaded despite decrypt failure: %v", store.puts)
+	}
+}
+
+func TestCollectSecrets_DuplicateAcrossRepos(t *testing.T) {
+	states := []multirepo.RepoState{
+		{Spec: config.RepoSpec{URL: "https://a"}, Secrets: []multirepo.RepoSecret{{Name: "db"}}},
+		{Spec: config.RepoSpec{URL: "https://b"}, Secrets: []multirepo.RepoSecret{{Name: "db"}}},
+	}
+	if _, err := collectSecrets(states); err == nil || !strings.Contains(err.Error(), "https://a and https://b") {
+		t.Errorf("collectSecrets() error = %v, want duplicate error", err)
+	}
+}
+
+func TestSecretRefs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.container")
+	content := "[Container]\nImage=app\nSecret=db,type=env,target=DB\n  Secret = spaced\nSecret=tls-cert\n# Secret=commented\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := secretRefs(path)
+	if err != nil {
+		t.Fatalf("secretRefs() error = %v", err)
+	}
+	if want := []string{"db", "spaced", "tls-cert"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("secretRefs() = %v, want %v", got, want)
+	}
+}
+
+func TestRun_SOPSEncryptedCompanionFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := secretTestConfig(tmpDir, false)
+	ciphertext := "DB_PASSWORD=ENC[AES256_GCM,data:abc,type:str]\nsops_version=3.8.1\nsops_mac=ENC[AES256_GCM,data:x]\n"
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Container]\nImage=nginx\nEnvironmentFile=app.env\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "app.env"), []byte(ciphertext), 0644)
+		},
+	}
+	run := func(dryRun bool) *Result {
+		t.Helper()
+		engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), dryRun)
+		engine.fileDecrypter = fakeDecrypter{}
+		result, err := engine.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return result
+	}
+
+	result := run(true)
+	if len(result.Plan.Add) != 2 {
+		t.Fatalf("dry-run plan = %+v, want 2 adds", result.Plan.Add)
+	}
+
+	run(false)
+	dest := filepath.Join(cfg.Paths.QuadletDir, "app.env")
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := "sops:" + ciphertext
+	if string(data) != plaintext {
+		t.Errorf("app.env = %q, want decrypted content", data)
+	}
+	if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("app.env mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	state, err := ReadStateFile(cfg.StateFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := fileHash(dest); state.ManagedFiles[dest].Hash != want {
+		t.Errorf("state hash = %s, want hash of decrypted content %s", state.ManagedFiles[dest].Hash, want)
+	}
+
+	// An unchanged file produces no update.
+	result = run(false)
+	if len(result.Plan.Add)+len(result.Plan.Update) != 0 {
+		t.Errorf("second run plan = %+v, want no changes", result.Plan)
+	}
+}
+
+func TestRun_SecretsNotLoadedWhenValidationFails(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := secretTestConfig(tmpDir, true)
+	gitMock := &testutil.MockGitClient{CommitHash: "abc123", RepoSetup: secretRepoSetup("v1")}
+	store := &fakeSecretStore{}
+
+	engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true, ValidateErr: errors.New("unsupported key Secret")}, testutil.TestLogger(), false)
+	engine.secretStore = store
+	engine.decrypter = fakeDecrypter{}
+	_, err := engine.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to validate quadlet definitions") {
+		t.Fatalf("Run error = %v, want validation failure", err)
+	}
+	if len(store.puts) != 0 {
+		t.Errorf("secrets loaded despite validation failure: %v", store.puts)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "web.container")); !os.IsNotExist(err) {
+		t.Errorf("quadlet written despite validation failure, stat err = %v", err)
+	}
+}
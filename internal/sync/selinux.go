@@ -0,0 +1,37 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/selinux"
+)
+
+// restoreContexts relabels the files the plan added or updated with
+// restorecon: with sync.selinux_restorecon auto when SELinux is enforcing,
+// with always whenever it is enabled. A failure is recorded as a warning;
+// the files are installed either way.
+func (e *Engine) restoreContexts(ctx context.Context, plan *Plan) {
+	mode := e.cfg.Sync.SELinuxRestorecon
+	if mode == config.SELinuxNever || len(plan.Add)+len(plan.Update) == 0 {
+		return
+	}
+	if e.labeler == nil {
+		e.labeler = selinux.NewCLILabeler(e.cfg.Timeouts.Podman)
+	}
+	enabled, enforcing := e.labeler.Status()
+	if !enabled || (mode != config.SELinuxAlways && !enforcing) {
+		return
+	}
+
+	paths := make([]string, 0, len(plan.Add)+len(plan.Update))
+	for _, op := range append(append([]FileOp{}, plan.Add...), plan.Update...) {
+		paths = append(paths, op.DestPath)
+	}
+	e.logger.Debug("restoring SELinux contexts", "files", len(paths))
+	if err := e.labeler.Restore(ctx, paths); err != nil {
+		e.warn(WarnRestoreconFailed, e.cfg.Paths.QuadletDir, "restoring SELinux contexts failed",
+			"error", err,
+			"remediation", "install restorecon (policycoreutils) or set sync.selinux_restorecon: never")
+	}
+}
@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// fakeLabeler records the paths restorecon would be run on.
+type fakeLabeler struct {
+	enabled, enforcing bool
+	err                error
+	restored           []string
+}
+
+func (l *fakeLabeler) Status() (bool, bool) { return l.enabled, l.enforcing }
+
+func (l *fakeLabeler) Restore(_ context.Context, paths []string) error {
+	l.restored = append(l.restored, paths...)
+	return l.err
+}
+
+func TestRun_RestoresSELinuxContexts(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         config.SELinuxMode
+		labeler      fakeLabeler
+		wantRestored bool
+	}{
+		{name: "auto enforcing", mode: config.SELinuxAuto, labeler: fakeLabeler{enabled: true, enforcing: true}, wantRestored: true},
+		{name: "auto permissive", mode: config.SELinuxAuto, labeler: fakeLabeler{enabled: true}},
+		{name: "always permissive", mode: config.SELinuxAlways, labeler: fakeLabeler{enabled: true}, wantRestored: true},
+		{name: "always disabled", mode: config.SELinuxAlways},
+		{name: "never enforcing", mode: config.SELinuxNever, labeler: fakeLabeler{enabled: true, enforcing: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, quadletDir := warningTestEngine(t, &testutil.MockSystemd{Available: true})
+			engine.cfg.Sync.SELinuxRestorecon = tt.mode
+			labeler := tt.labeler
+			engine.labeler = &labeler
+
+			result, err := engine.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			var want []string
+			if tt.wantRestored {
+				want = []string{filepath.Join(quadletDir, "app.container")}
+			}
+			if !reflect.DeepEqual(labeler.restored, want) {
+				t.Errorf("restored = %v, want %v", labeler.restored, want)
+			}
+			if len(result.Warnings) != 0 {
+				t.Errorf("warnings = %+v", result.Warnings)
+			}
+		})
+	}
+}
+
+func TestRun_WarnsWhenRestoreconFails(t *testing.T) {
+	engine, _ := warningTestEngine(t, &testutil.MockSystemd{Available: true})
+	engine.labeler = &fakeLabeler{enabled: true, enforcing: true, err: errors.New("restorecon: executable file not found")}
+
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != WarnRestoreconFailed {
+		t.Fatalf("warnings = %+v, want one %s", result.Warnings, WarnRestoreconFailed)
+	}
+}
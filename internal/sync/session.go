@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+)
+
+// Probes of the user session, replaced in tests.
+var (
+	currentUser   = systemduser.CurrentUser
+	lingerEnabled = systemduser.LingerEnabled
+)
+
+// lingerWarned limits the lingering warning to once per process, so a
+// long-running server does not repeat it for every sync.
+var lingerWarned gosync.Once
+
+// CheckSession diagnoses the systemd user session quadsyncd depends on: that
+// XDG_RUNTIME_DIR points at the user's runtime directory, that the user bus
+// socket exists, that lingering keeps the user manager running without a
+// login, and that the manager answers. Each result carries the fix in its
+// detail. It changes nothing.
+func CheckSession(ctx context.Context, systemd systemduser.Systemd, timeout time.Duration) []config.CheckResult {
+	results := checkSessionEnvironment(ctx, timeout)
+
+	manager := config.CheckResult{Field: "session.user_manager", Status: config.CheckOK}
+	if available, err := systemd.IsAvailable(ctx); err != nil || !available {
+		manager.Status = config.CheckError
+		manager.Detail = "systemd user manager not reachable"
+		if err != nil {
+			manager.Detail += ": " + err.Error()
+		}
+	}
+	return append(results, manager)
+}
+
+// checkSessionEnvironment runs the checks of CheckSession that do not talk
+// to the user manager.
+func checkSessionEnvironment(ctx context.Context, timeout time.Duration) []config.CheckResult {
+	runtime := checkRuntimeDir()
+	return []config.CheckResult{runtime, checkUserBus(runtime.Path), checkLinger(ctx, timeout)}
+}
+
+// sessionUser returns the current user name for hints, or "$USER".
+func sessionUser() string {
+	if username, err := currentUser(); err == nil {
+		return username
+	}
+	return "$USER"
+}
+
+// checkRuntimeDir checks that XDG_RUNTIME_DIR is set and exists.
+func checkRuntimeDir() config.CheckResult {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	r := config.CheckResult{Field: "session.xdg_runtime_dir", Path: dir, Status: config.CheckOK}
+	if dir == "" {
+		username := sessionUser()
+		r.Status = config.CheckError
+		r.Detail = fmt.Sprintf("XDG_RUNTIME_DIR is not set, so systemctl --user cannot find the user manager; "+
+			"log in as %s directly or with `machinectl shell %s@` instead of su or sudo, or set XDG_RUNTIME_DIR=/run/user/%d", username, username, os.Getuid())
+		return r
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		username := sessionUser()
+		r.Status = config.CheckError
+		r.Detail = fmt.Sprintf("%s does not exist, so the user manager is not running; "+
+			"log in as %s or run `loginctl enable-linger %s`", dir, username, username)
+	}
+	return r
+}
+
+// checkUserBus checks that the session bus socket in runtimeDir exists,
+// unless DBUS_SESSION_BUS_ADDRESS points elsewhere.
+func checkUserBus(runtimeDir string) config.CheckResult {
+	r := config.CheckResult{Field: "session.bus", Status: config.CheckOK}
+	if addr := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); addr != "" {
+		r.Path = addr
+		return r
+	}
+	if runtimeDir == "" {
+		r.Status = config.CheckWarning
+		r.Detail = "cannot locate the user bus without XDG_RUNTIME_DIR"
+		return r
+	}
+	r.Path = filepath.Join(runtimeDir, "bus")
+	if _, err := os.Stat(r.Path); err != nil {
+		r.Status = config.CheckWarning
+		r.Detail = "the user bus socket does not exist; systemctl --user and systemd.backend dbus need it, " +
+			"check that dbus or dbus-broker is installed for user sessions"
+	}
+	return r
+}
+
+// checkLinger checks that lingering is enabled for the current user.
+func checkLinger(ctx context.Context, timeout time.Duration) config.CheckResult {
+	r := config.CheckResult{Field: "session.linger", Status: config.CheckOK}
+	username, err := currentUser()
+	enabled := false
+	if err == nil {
+		enabled, err = lingerEnabled(ctx, username, timeout)
+	}
+	switch {
+	case err != nil:
+		r.Status = config.CheckWarning
+		r.Detail = "could not check lingering: " + err.Error()
+	case !enabled:
+		r.Status = config.CheckWarning
+		r.Detail = fmt.Sprintf("lingering is disabled, so the user manager, its timers and quadsyncd stop when %s logs out; "+
+			"run `loginctl enable-linger %s`", username, username)
+	}
+	return r
+}
+
+// preflight checks the user session before a sync applies changes. When the
+// user manager is not reachable the error names the likely cause and its
+// fix; disabled lingering is logged as a warning once per process.
+func (e *Engine) preflight(ctx context.Context) error {
+	available, err := e.systemd.IsAvailable(ctx)
+	if err == nil && available {
+		lingerWarned.Do(func() {
+			if r := checkLinger(ctx, e.cfg.Timeouts.Systemctl); r.Status != config.CheckOK {
+				e.logger.Warn("user session check failed", logging.Event(logging.EventSessionCheck), "check", r.Field, "detail", r.Detail)
+			}
+		})
+		return nil
+	}
+
+	var causes []string
+	for _, r := range checkSessionEnvironment(ctx, e.cfg.Timeouts.Systemctl) {
+		if r.Status != config.CheckOK {
+			causes = append(causes, r.Detail)
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("systemctl --user is not accessible")
+	}
+	if len(causes) == 0 {
+		return fmt.Errorf("systemd user session not available: %w", err)
+	}
+	return fmt.Errorf("systemd user session not available: %w (%s; run `quadsyncd doctor` for details)", err, strings.Join(causes, "; "))
+}
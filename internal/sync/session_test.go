@@ -0,0 +1,129 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// stubSession replaces the user session probes for the duration of a test.
+func stubSession(t *testing.T, linger bool, lingerErr error) {
+	t.Helper()
+	origUser, origLinger := currentUser, lingerEnabled
+	currentUser = func() (string, error) { return "alice", nil }
+	lingerEnabled = func(context.Context, string, time.Duration) (bool, error) { return linger, lingerErr }
+	t.Cleanup(func() { currentUser, lingerEnabled = origUser, origLinger })
+}
+
+func sessionStatuses(results []config.CheckResult) map[string]config.CheckResult {
+	out := make(map[string]config.CheckResult, len(results))
+	for _, r := range results {
+		out[r.Field] = r
+	}
+	return out
+}
+
+func TestCheckSession(t *testing.T) {
+	runtimeDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(runtimeDir, "bus"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		runtimeDir string
+		linger     bool
+		lingerErr  error
+		available  bool
+		want       map[string]config.CheckStatus
+		wantDetail map[string]string
+	}{
+		{
+			name:       "healthy session",
+			runtimeDir: runtimeDir,
+			linger:     true,
+			available:  true,
+			want: map[string]config.CheckStatus{
+				"session.xdg_runtime_dir": config.CheckOK,
+				"session.bus":             config.CheckOK,
+				"session.linger":          config.CheckOK,
+				"session.user_manager":    config.CheckOK,
+			},
+		},
+		{
+			name:      "su without runtime dir",
+			linger:    false,
+			available: false,
+			want: map[string]config.CheckStatus{
+				"session.xdg_runtime_dir": config.CheckError,
+				"session.bus":             config.CheckWarning,
+				"session.linger":          config.CheckWarning,
+				"session.user_manager":    config.CheckError,
+			},
+			wantDetail: map[string]string{
+				"session.xdg_runtime_dir": "machinectl shell alice@",
+				"session.linger":          "loginctl enable-linger alice",
+			},
+		},
+		{
+			name:       "runtime dir missing",
+			runtimeDir: filepath.Join(runtimeDir, "gone"),
+			lingerErr:  errors.New("loginctl not found"),
+			want: map[string]config.CheckStatus{
+				"session.xdg_runtime_dir": config.CheckError,
+				"session.bus":             config.CheckWarning,
+				"session.linger":          config.CheckWarning,
+				"session.user_manager":    config.CheckError,
+			},
+			wantDetail: map[string]string{
+				"session.xdg_runtime_dir": "does not exist",
+				"session.linger":          "loginctl not found",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSession(t, tt.linger, tt.lingerErr)
+			t.Setenv("XDG_RUNTIME_DIR", tt.runtimeDir)
+			t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+
+			got := sessionStatuses(CheckSession(context.Background(), &testutil.MockSystemd{Available: tt.available}, 0))
+			for field, status := range tt.want {
+				if got[field].Status != status {
+					t.Errorf("%s status = %s (%s), want %s", field, got[field].Status, got[field].Detail, status)
+				}
+			}
+			for field, detail := range tt.wantDetail {
+				if !strings.Contains(got[field].Detail, detail) {
+					t.Errorf("%s detail = %q, want it to contain %q", field, got[field].Detail, detail)
+				}
+			}
+		})
+	}
+}
+
+func TestPreflight_ExplainsUnavailableSession(t *testing.T) {
+	stubSession(t, false, nil)
+	t.Setenv("XDG_RUNTIME_DIR", "")
+
+	content := "[Container]\nImage=nginx:1\n"
+	cfg, mockGit := newFreezeTestConfig(t, content)
+	e := New(cfg, WithGitClient(mockGit), WithSystemd(&testutil.MockSystemd{}), WithLogger(testutil.TestLogger()))
+
+	_, err := e.Run(context.Background())
+	if err == nil {
+		t.Fatal("Run() succeeded without a user session")
+	}
+	for _, want := range []string{"systemd user session not available", "XDG_RUNTIME_DIR is not set", "loginctl enable-linger alice", "quadsyncd doctor"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Run() error = %v, want it to contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/cosign"
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+)
+
+// imageVerifier checks the signatures of container images; implemented by
+// cosign.CLI.
+type imageVerifier interface {
+	VerifyImage(ctx context.Context, image string, signer cosign.Signer) error
+}
+
+// verifyImageSignatures checks the images referenced by Image= in the staged
+// quadlets and drop-ins the plan adds or updates against
+// image_signatures. An image passes when it is signed by the configured key
+// or by any of the configured identities. With mode enforce an image that
+// does not pass fails the sync before any file is installed; with warn it
+// is recorded as a warning. References to .image and .build quadlets and
+// excluded images are skipped, as are files that cannot be parsed
+// (validation reports those).
+func (e *Engine) verifyImageSignatures(ctx context.Context, st *staging, plan *Plan) error {
+	policy := e.cfg.ImageSignatures
+	if !policy.Enabled() {
+		return nil
+	}
+	files := make(map[string][]string)
+	for _, op := range append(append([]FileOp{}, plan.Add...), plan.Update...) {
+		if _, dropIn := quadlet.DropInDir(op.DestPath); !dropIn && !quadlet.IsQuadletFile(op.DestPath) {
+			continue
+		}
+		u, err := quadlet.ParseFile(st.files[op.DestPath])
+		if err != nil {
+			continue
+		}
+		image, ok := u.Image()
+		if !ok || image == "" || strings.HasSuffix(image, ".image") || strings.HasSuffix(image, ".build") || policy.Excluded(image) {
+			continue
+		}
+		files[image] = append(files[image], op.DestPath)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	images := make([]string, 0, len(files))
+	for image := range files {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	verifier := e.imageVerifier
+	if verifier == nil {
+		verifier = cosign.NewCLI(e.cfg.Timeouts.Git)
+	}
+	var unsigned []string
+	for _, image := range images {
+		err := verifyImage(ctx, verifier, image, imageSigners(policy))
+		if err == nil {
+			e.logger.Info("verified image signature", logging.Event(logging.EventImageVerify), "image", image, "files", files[image])
+			continue
+		}
+		if policy.Mode == config.ImageSignaturesEnforce {
+			unsigned = append(unsigned, fmt.Sprintf("%s (%s): %v", image, strings.Join(files[image], ", "), err))
+			continue
+		}
+		e.warn(WarnImageUnverified, image, "image signature verification failed",
+			"files", files[image],
+			"error", err,
+			"remediation", "sign the image, pin a signed digest or add it to image_signatures.exclude")
+	}
+	if len(unsigned) > 0 {
+		return fmt.Errorf("refusing to roll out images without a valid signature (image_signatures.mode: enforce): %s", strings.Join(unsigned, "; "))
+	}
+	return nil
+}
+
+// imageSigners returns the signers policy accepts.
+func imageSigners(policy config.ImageSignaturesConfig) []cosign.Signer {
+	if policy.CosignKey != "" {
+		return []cosign.Signer{{Key: policy.CosignKey}}
+	}
+	signers := make([]cosign.Signer, len(policy.Identities))
+	for i, id := range policy.Identities {
+		signers[i] = cosign.Signer{Issuer: id.Issuer, Subject: id.Subject, SubjectRegexp: id.SubjectRegexp}
+	}
+	return signers
+}
+
+// verifyImage returns nil when image is signed by one of signers, and the
+// errors of all attempts otherwise.
+func verifyImage(ctx context.Context, verifier imageVerifier, image string, signers []cosign.Signer) error {
+	var errs []error
+	for _, signer := range signers {
+		err := verifier.VerifyImage(ctx, image, signer)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
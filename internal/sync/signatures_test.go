@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/cosign"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// fakeImageVerifier accepts the images in signed and records every call.
+type fakeImageVerifier struct {
+	signed map[string]bool
+	calls  []string
+}
+
+func (v *fakeImageVerifier) VerifyImage(_ context.Context, image string, signer cosign.Signer) error {
+	v.calls = append(v.calls, image+" "+signer.Key+signer.Subject)
+	if !v.signed[image] {
+		return errors.New("no matching signatures")
+	}
+	return nil
+}
+
+// imageSignatureEngine returns an engine syncing quadlets that reference a
+// signed image, an unsigned one through a drop-in, a .build quadlet and a
+// localhost image.
+func imageSignatureEngine(t *testing.T, mode config.ImageSignatureMode) (*Engine, string) {
+	t.Helper()
+	cfg, mg := newTestRepo(t, map[string]string{
+		"web.container":               "[Container]\nImage=ghcr.io/acme/web:1.0\n",
+		"web.container.d/image.conf":  "[Container]\nImage=ghcr.io/acme/web:1.0\n",
+		"api.container":               "[Container]\nImage=ghcr.io/acme/api:1.0\n",
+		"api.container.d/10-dev.conf": "[Container]\nImage=ghcr.io/acme/api:dev\n",
+		"tool.container":              "[Container]\nImage=tool.build\n",
+		"tool.build":                  "[Build]\nImageTag=localhost/tool\n",
+		"local.container":             "[Container]\nImage=localhost/dev:latest\n",
+	})
+	cfg.Sync.SELinuxRestorecon = config.SELinuxNever
+	cfg.ImageSignatures = config.ImageSignaturesConfig{
+		Mode:      mode,
+		CosignKey: "/etc/quadsyncd/cosign.pub",
+		Exclude:   []string{"localhost/*"},
+	}
+	return NewEngine(cfg, mg, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false), cfg.Paths.QuadletDir
+}
+
+func TestRun_VerifiesImageSignatures(t *testing.T) {
+	engine, quadletDir := imageSignatureEngine(t, config.ImageSignaturesWarn)
+	verifier := &fakeImageVerifier{signed: map[string]bool{"ghcr.io/acme/web:1.0": true, "ghcr.io/acme/api:1.0": true}}
+	engine.imageVerifier = verifier
+
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	wantCalls := []string{
+		"ghcr.io/acme/api:1.0 /etc/quadsyncd/cosign.pub",
+		"ghcr.io/acme/api:dev /etc/quadsyncd/cosign.pub",
+		"ghcr.io/acme/web:1.0 /etc/quadsyncd/cosign.pub",
+	}
+	if !reflect.DeepEqual(verifier.calls, wantCalls) {
+		t.Errorf("verified = %v, want %v", verifier.calls, wantCalls)
+	}
+	var unverified []string
+	for _, w := range result.Warnings {
+		if w.Code == WarnImageUnverified {
+			unverified = append(unverified, w.Subject)
+		}
+	}
+	if want := []string{"ghcr.io/acme/api:dev"}; !reflect.DeepEqual(unverified, want) {
+		t.Errorf("%s warnings = %v, want %v", WarnImageUnverified, unverified, want)
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, "api.container.d", "10-dev.conf")); err != nil {
+		t.Errorf("warn mode should install the drop-in: %v", err)
+	}
+}
+
+func TestRun_EnforcedImageSignaturesBlockRollout(t *testing.T) {
+	engine, quadletDir := imageSignatureEngine(t, config.ImageSignaturesEnforce)
+	engine.imageVerifier = &fakeImageVerifier{signed: map[string]bool{"ghcr.io/acme/web:1.0": true, "ghcr.io/acme/api:1.0": true}}
+
+	_, err := engine.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "ghcr.io/acme/api:dev") || !strings.Contains(err.Error(), "10-dev.conf") {
+		t.Fatalf("Run() error = %v, want the unsigned image and its file", err)
+	}
+	if entries, _ := os.ReadDir(quadletDir); len(entries) != 0 {
+		t.Errorf("quadlet dir = %v, want nothing installed", entries)
+	}
+}
+
+func TestRun_ImageSignaturesKeyless(t *testing.T) {
+	engine, _ := imageSignatureEngine(t, config.ImageSignaturesEnforce)
+	engine.cfg.ImageSignatures.CosignKey = ""
+	engine.cfg.ImageSignatures.Exclude = []string{"localhost/*", "ghcr.io/acme/api:*"}
+	engine.cfg.ImageSignatures.Identities = []config.SignerIdentity{
+		{Issuer: "https://accounts.google.com", Subject: "ops@acme.dev"},
+		{Issuer: "https://accounts.google.com", Subject: "ci@acme.dev"},
+	}
+	verifier := &fakeImageVerifier{signed: map[string]bool{"ghcr.io/acme/web:1.0": true}}
+	engine.imageVerifier = verifier
+
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// The first identity passes, so the second is not tried.
+	if want := []string{"ghcr.io/acme/web:1.0 ops@acme.dev"}; !reflect.DeepEqual(verifier.calls, want) {
+		t.Errorf("verified = %v, want %v", verifier.calls, want)
+	}
+}
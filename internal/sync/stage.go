@@ -0,0 +1,143 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// staging is a scratch copy of the post-sync file set. The quadlet generator
+// validates QuadletDir before any live file is touched, and the commit phase
+// installs files from here so what was validated is exactly what lands.
+type staging struct {
+	// Dir is the staging root under the state directory.
+	Dir string
+	// QuadletDir mirrors the live quadlet dir with the plan applied.
+	QuadletDir string
+	// files maps each Add/Update DestPath to its staged copy.
+	files map[string]string
+}
+
+// cleanup removes the staging directory.
+func (s *staging) cleanup() {
+	_ = os.RemoveAll(s.Dir)
+}
+
+// stagePlan copies the current quadlet dir into a fresh staging directory and
+// applies plan to the copy. Staged content is checked against the planned
+// hash so a checkout that changed underneath the plan is caught here.
+func (e *Engine) stagePlan(plan *Plan) (*staging, error) {
+	if err := os.MkdirAll(e.cfg.Paths.StateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(e.cfg.Paths.StateDir, "staging-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	st := &staging{
+		Dir:        dir,
+		QuadletDir: filepath.Join(dir, "quadlets"),
+		files:      make(map[string]string),
+	}
+	if err := e.stageWithPlan(st, plan); err != nil {
+		st.cleanup()
+		return nil, err
+	}
+	return st, nil
+}
+
+func (e *Engine) stageWithPlan(st *staging, plan *Plan) error {
+	if err := os.MkdirAll(st.QuadletDir, 0755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := e.mirrorQuadletDir(st.QuadletDir); err != nil {
+		return fmt.Errorf("failed to stage quadlet directory: %w", err)
+	}
+
+	for i, op := range append(append([]FileOp{}, plan.Add...), plan.Update...) {
+		staged := e.stagedPath(st, op.DestPath)
+		if staged == "" {
+			// Manifest-declared file outside the quadlet dir.
+			staged = filepath.Join(st.Dir, "external", strconv.Itoa(i))
+		}
+		stage := e.copyFile
+		if op.Encrypted {
+			stage = e.stageDecrypted
+		} else if op.Rendered != nil {
+			stage = func(src, dst string) error { return stageRendered(src, dst, op.Rendered) }
+		}
+		if err := stage(op.SourcePath, staged); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", op.DestPath, err)
+		}
+		if op.Hash != "" {
+			hash, err := fileHash(staged)
+			if err != nil {
+				return fmt.Errorf("failed to hash staged %s: %w", op.DestPath, err)
+			}
+			if hash != op.Hash {
+				return fmt.Errorf("staged %s does not match planned content (source changed during sync)", op.DestPath)
+			}
+		}
+		st.files[op.DestPath] = staged
+	}
+
+	for _, op := range plan.Delete {
+		staged := e.stagedPath(st, op.DestPath)
+		if staged == "" {
+			continue
+		}
+		if err := os.Remove(staged); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to stage deletion of %s: %w", op.DestPath, err)
+		}
+	}
+	return nil
+}
+
+// stagedPath maps a live path inside the quadlet dir to its staged location,
+// or returns "" for paths outside the quadlet dir.
+func (e *Engine) stagedPath(st *staging, dest string) string {
+	rel, err := filepath.Rel(e.cfg.Paths.QuadletDir, dest)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.Join(st.QuadletDir, rel)
+}
+
+// mirrorQuadletDir copies the non-hidden regular files of the live quadlet
+// dir into dst so the generator sees unmanaged files alongside staged ones.
+func (e *Engine) mirrorQuadletDir(dst string) error {
+	src := e.cfg.Paths.QuadletDir
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == src {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return e.copyFile(path, target)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestStagePlan(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	for _, d := range []string{srcDir, filepath.Join(quadletDir, ".hidden")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(quadletDir, "unmanaged.container"), "unmanaged")
+	write(filepath.Join(quadletDir, "old.container"), "old")
+	write(filepath.Join(quadletDir, ".hidden", "x"), "hidden")
+	write(filepath.Join(srcDir, "new.container"), "new")
+
+	hash, err := fileHash(filepath.Join(srcDir, "new.container"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")}}
+	engine := &Engine{cfg: cfg, logger: testutil.TestLogger()}
+	plan := &Plan{
+		Add:    []FileOp{{SourcePath: filepath.Join(srcDir, "new.container"), DestPath: filepath.Join(quadletDir, "new.container"), Hash: hash}},
+		Delete: []FileOp{{DestPath: filepath.Join(quadletDir, "old.container")}},
+	}
+
+	st, err := engine.stagePlan(plan)
+	if err != nil {
+		t.Fatalf("stagePlan: %v", err)
+	}
+	defer st.cleanup()
+
+	if !strings.HasPrefix(st.QuadletDir, cfg.Paths.StateDir) {
+		t.Errorf("staging dir %s should live under the state dir", st.QuadletDir)
+	}
+	if data, err := os.ReadFile(filepath.Join(st.QuadletDir, "new.container")); err != nil || string(data) != "new" {
+		t.Errorf("staged add: %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(st.QuadletDir, "unmanaged.container")); err != nil || string(data) != "unmanaged" {
+		t.Errorf("unmanaged file should be mirrored: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(st.QuadletDir, "old.container")); !os.IsNotExist(err) {
+		t.Error("deleted file should be absent from staging")
+	}
+	if _, err := os.Stat(filepath.Join(st.QuadletDir, ".hidden")); !os.IsNotExist(err) {
+		t.Error("hidden entries should not be mirrored")
+	}
+	// The live dir is untouched by staging.
+	if _, err := os.Stat(filepath.Join(quadletDir, "new.container")); !os.IsNotExist(err) {
+		t.Error("staging must not write to the live quadlet dir")
+	}
+
+	st.cleanup()
+	if _, err := os.Stat(st.Dir); !os.IsNotExist(err) {
+		t.Error("cleanup should remove the staging dir")
+	}
+}
+
+func TestStagePlan_HashMismatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "app.container")
+	if err := os.WriteFile(src, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Paths: config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "q"), StateDir: filepath.Join(tmpDir, "s")}}
+	engine := &Engine{cfg: cfg, logger: testutil.TestLogger()}
+	plan := &Plan{Add: []FileOp{{SourcePath: src, DestPath: filepath.Join(tmpDir, "q", "app.container"), Hash: "deadbeef"}}}
+
+	if _, err := engine.stagePlan(plan); err == nil || !strings.Contains(err.Error(), "does not match planned content") {
+		t.Fatalf("expected hash mismatch error, got %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(tmpDir, "s", "staging-*")); len(matches) != 0 {
+		t.Errorf("staging dir not cleaned up on failure: %v", matches)
+	}
+}
@@ -1,14 +1,48 @@
 package sync
 
+import "os"
+
 // State tracks the current managed quadlet files
 type State struct {
+	// Version is the schema version the state was written with; files
+	// from before versioning have none. See StateVersion.
+	Version int `json:"version,omitempty"`
+
 	// Commit is the single-repo commit SHA (legacy; kept for backward compat).
 	Commit string `json:"commit,omitempty"`
 
 	// Revisions tracks the last-synced commit SHA per repository URL.
 	Revisions map[string]string `json:"revisions,omitempty"`
 
+	// Refs records the ref or commit checked out per repository URL by the
+	// last applied sync; it differs from the configured ref after sync --ref.
+	Refs map[string]string `json:"refs,omitempty"`
+
+	// ConfigHash fingerprints the configuration of the last applied sync.
+	// A run whose revisions, config and files all match skips planning.
+	ConfigHash string `json:"config_hash,omitempty"`
+
 	ManagedFiles map[string]ManagedFile `json:"managed_files"`
+
+	// PendingRestarts are units whose restart a sync inside one of
+	// sync.windows deferred; the next sync outside them restarts them.
+	PendingRestarts []string `json:"pending_restarts,omitempty"`
+
+	// Secrets tracks the podman secrets loaded from repository manifests,
+	// keyed by secret name.
+	Secrets map[string]ManagedSecret `json:"secrets,omitempty"`
+}
+
+// ManagedSecret represents a podman secret under management.
+type ManagedSecret struct {
+	SourcePath string `json:"source_path"` // repo-relative path of the encrypted file
+	Hash       string `json:"hash"`        // SHA256 hash of the encrypted content
+
+	SourceRepo string `json:"source_repo,omitempty"`
+	SourceSHA  string `json:"source_sha,omitempty"`
+
+	// RestartUnits are restarted when the secret changes or is removed.
+	RestartUnits []string `json:"restart_units,omitempty"`
 }
 
 // ManagedFile represents a quadlet file under management
@@ -20,6 +54,38 @@ type ManagedFile struct {
 	SourceRepo string `json:"source_repo,omitempty"` // repository URL
 	SourceRef  string `json:"source_ref,omitempty"`  // configured ref
 	SourceSHA  string `json:"source_sha,omitempty"`  // resolved commit SHA
+	// SourceLayer is the subdir layer the file came from, for repositories
+	// syncing several layers.
+	SourceLayer string `json:"source_layer,omitempty"`
+	// SourceFile is the repository-relative path of the source in a git
+	// checkout, recorded when the file was installed verbatim (neither
+	// substituted nor decrypted). The next plan reuses Hash for such a file
+	// when git reports it unchanged.
+	SourceFile string `json:"source_file,omitempty"`
+
+	// RestartUnits are restarted when a manifest-declared file outside the
+	// quadlet dir changes or is pruned.
+	RestartUnits []string `json:"restart_units,omitempty"`
+
+	// Size and ModTime (Unix nanoseconds) of the installed file when it was
+	// last known to hold Hash. While both still match, plan building skips
+	// re-hashing the file.
+	Size    int64 `json:"size,omitempty"`
+	ModTime int64 `json:"mtime,omitempty"`
+}
+
+// statMatches reports whether info has the size and modification time
+// recorded for the file, i.e. whether its content can be taken to be
+// unchanged without hashing it.
+func (mf ManagedFile) statMatches(info os.FileInfo) bool {
+	return mf.ModTime != 0 && info.Mode().IsRegular() &&
+		info.Size() == mf.Size && info.ModTime().UnixNano() == mf.ModTime
+}
+
+// setStat records the size and modification time of info.
+func (mf *ManagedFile) setStat(info os.FileInfo) {
+	mf.Size = info.Size()
+	mf.ModTime = info.ModTime().UnixNano()
 }
 
 // Plan represents the sync operations to perform
@@ -27,16 +93,76 @@ type Plan struct {
 	Add    []FileOp
 	Update []FileOp
 	Delete []FileOp
+
+	// Secrets lists podman secrets to create, update or remove.
+	Secrets []SecretOp
+
+	// Unmanaged lists files in the quadlet dir that quadsyncd neither
+	// manages nor installs, e.g. hand-written quadlets or files of another
+	// instance sharing the directory. They are only deleted with
+	// sync.prune_scope all and --allow-unmanaged-delete.
+	Unmanaged []string
+
+	// kept records what building the plan learned about unchanged managed
+	// files, for the new state.
+	kept map[string]keptFile
+}
+
+// keptFile is what plan building learned about an unchanged managed file.
+type keptFile struct {
+	// stat is set when the installed file was hashed and found intact.
+	stat os.FileInfo
+	// sourceFile is the current ManagedFile.SourceFile.
+	sourceFile string
 }
 
 // FileOp represents a file operation
 type FileOp struct {
 	SourcePath string // absolute path in checkout
-	DestPath   string // absolute path in quadlet dir
+	DestPath   string // absolute path in quadlet dir (or manifest dest)
 	Hash       string // content hash
 
+	// RestartUnits are extra units to restart for manifest-declared files.
+	RestartUnits []string
+
+	// Encrypted marks a sops-encrypted source; Hash is that of the
+	// decrypted content, which is what gets installed.
+	Encrypted bool
+	// Rendered is the content after substitution when it differs from the
+	// source; Hash is that of Rendered, which is what gets installed.
+	Rendered []byte
+
 	// Provenance (populated by buildPlanFromEffective; empty in legacy path)
+	SourceRepo  string
+	SourceRef   string
+	SourceSHA   string
+	SourceLayer string
+	// SourceFile is the repository-relative path of a source installed
+	// verbatim; see ManagedFile.SourceFile.
+	SourceFile string
+}
+
+// SecretAction is what a SecretOp does to a podman secret.
+type SecretAction string
+
+const (
+	SecretCreate SecretAction = "create"
+	SecretUpdate SecretAction = "update"
+	SecretDelete SecretAction = "delete"
+)
+
+// SecretOp represents a podman secret operation
+type SecretOp struct {
+	Name       string
+	Action     SecretAction
+	Source     string // repo-relative path of the encrypted file
+	SourcePath string // absolute path of the encrypted file in checkout
+	Format     string // secrets.FormatAge or secrets.FormatSOPS
+	Hash       string // hash of the encrypted content
+
+	// RestartUnits are the units using the secret.
+	RestartUnits []string
+
 	SourceRepo string
-	SourceRef  string
 	SourceSHA  string
 }
@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+)
+
+// tokenPattern matches a ${NAME} substitution token.
+var tokenPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// hostFacts returns the host facts available to substitution; replaced in
+// tests.
+var hostFacts = func() map[string]string {
+	facts := map[string]string{
+		config.HostFactPrefix + "ARCH": runtime.GOARCH,
+		config.HostFactPrefix + "OS":   runtime.GOOS,
+		config.HostFactPrefix + "UID":  strconv.Itoa(os.Getuid()),
+	}
+	if host, err := os.Hostname(); err == nil {
+		facts[config.HostFactPrefix+"HOSTNAME"] = host
+	}
+	if u, err := user.Current(); err == nil {
+		facts[config.HostFactPrefix+"USER"] = u.Username
+	}
+	return facts
+}
+
+// substitutionVars returns the host facts and substitution.vars, or nil when
+// substitution is disabled. They are computed once per engine.
+func (e *Engine) substitutionVars() map[string]string {
+	if !e.cfg.Substitution.Enabled {
+		return nil
+	}
+	if e.substVars == nil {
+		e.substVars = hostFacts()
+		maps.Copy(e.substVars, e.cfg.Substitution.Vars)
+	}
+	return e.substVars
+}
+
+// substitute replaces the ${NAME} tokens in data naming a host fact or a
+// substitution variable. Other tokens, such as systemd's own ${VAR}
+// references in Exec lines, are left alone; an unknown name with the
+// reserved QS_ prefix is an error so a misspelled fact is not installed
+// verbatim. It reports whether data changed.
+func (e *Engine) substitute(path string, data []byte) ([]byte, bool, error) {
+	vars := e.substitutionVars()
+	if vars == nil || !bytes.Contains(data, []byte("${")) {
+		return data, false, nil
+	}
+	var unknown []string
+	out := tokenPattern.ReplaceAllFunc(data, func(token []byte) []byte {
+		name := string(token[2 : len(token)-1])
+		if value, ok := vars[name]; ok {
+			return []byte(value)
+		}
+		if strings.HasPrefix(name, config.HostFactPrefix) {
+			unknown = append(unknown, name)
+		}
+		return token
+	})
+	if len(unknown) > 0 {
+		return nil, false, fmt.Errorf("%s: unknown host fact %s", path, strings.Join(unknown, ", "))
+	}
+	if out == nil {
+		out = []byte{}
+	}
+	return out, !bytes.Equal(out, data), nil
+}
+
+// stageRendered writes data, the substituted content of src, to dst with the
+// mode of src.
+func stageRendered(src, dst string, data []byte) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
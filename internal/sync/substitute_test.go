@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func stubHostFacts(t *testing.T) {
+	t.Helper()
+	orig := hostFacts
+	hostFacts = func() map[string]string {
+		return map[string]string{"QS_HOSTNAME": "web1", "QS_ARCH": "arm64"}
+	}
+	t.Cleanup(func() { hostFacts = orig })
+}
+
+func TestEngine_Substitute(t *testing.T) {
+	stubHostFacts(t)
+	tests := []struct {
+		name    string
+		enabled bool
+		in      string
+		want    string
+		wantErr string
+	}{
+		{name: "disabled", in: "Image=app:${QS_ARCH}\n", want: "Image=app:${QS_ARCH}\n"},
+		{name: "facts and vars", enabled: true, in: "Image=app:${TAG}-${QS_ARCH}\nHostName=${QS_HOSTNAME}\n", want: "Image=app:1.2-arm64\nHostName=web1\n"},
+		{name: "unknown names are kept", enabled: true, in: "Exec=sh -c 'echo ${HOME} $TAG'\n", want: "Exec=sh -c 'echo ${HOME} $TAG'\n"},
+		{name: "unknown fact", enabled: true, in: "HostName=${QS_HOSTNMAE}\n", wantErr: "unknown host fact QS_HOSTNMAE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Substitution: config.SubstitutionConfig{Enabled: tt.enabled, Vars: map[string]string{"TAG": "1.2"}}}
+			e := &Engine{cfg: cfg}
+			got, changed, err := e.substitute("web.container", []byte(tt.in))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("substitute() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("substitute() error = %v", err)
+			}
+			if string(got) != tt.want || changed != (tt.want != tt.in) {
+				t.Errorf("substitute() = %q, %v; want %q", got, changed, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_Run_Substitution(t *testing.T) {
+	stubHostFacts(t)
+	content := "[Container]\nImage=nginx:${TAG}\nHostName=${QS_HOSTNAME}\n"
+	cfg, mockGit := newFreezeTestConfig(t, content)
+	cfg.Substitution = config.SubstitutionConfig{Enabled: true, Vars: map[string]string{"TAG": "1.27"}}
+
+	systemd := &testutil.MockSystemd{Available: true}
+	if _, err := NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	dest := filepath.Join(cfg.Paths.QuadletDir, "web.container")
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[Container]\nImage=nginx:1.27\nHostName=web1\n"; string(got) != want {
+		t.Errorf("installed %q, want %q", got, want)
+	}
+
+	// Changing a variable changes the planned content, so the file is updated.
+	cfg.Substitution.Vars["TAG"] = "1.28"
+	result, err := NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if len(result.Plan.Update) != 1 || !strings.Contains(string(result.Plan.Update[0].Rendered), "nginx:1.28") {
+		t.Fatalf("second Run() plan = %+v, want one update rendered with TAG 1.28", result.Plan.Update)
+	}
+	if got, _ := os.ReadFile(dest); !strings.Contains(string(got), "nginx:1.28") {
+		t.Errorf("installed %q after changing TAG", got)
+	}
+}
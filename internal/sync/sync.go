@@ -5,18 +5,27 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	gosync "sync"
+	"time"
 
 	"github.com/schaermu/quadsyncd/internal/config"
 	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/schaermu/quadsyncd/internal/localdir"
+	"github.com/schaermu/quadsyncd/internal/logging"
 	"github.com/schaermu/quadsyncd/internal/multirepo"
 	"github.com/schaermu/quadsyncd/internal/quadlet"
+	"github.com/schaermu/quadsyncd/internal/secrets"
+	"github.com/schaermu/quadsyncd/internal/selinux"
 	"github.com/schaermu/quadsyncd/internal/systemduser"
 )
 
@@ -40,13 +49,17 @@ type RunnerFactory func(
 ) Runner
 
 // NewRunnerFactory returns a RunnerFactory that creates Engine instances
-// using the given git client factory and systemd client.
+// using the given git client factory and systemd client. All sync engines it
+// creates share one RestartCoordinator.
 func NewRunnerFactory(gitFactory GitClientFactory, systemd systemduser.Systemd) RunnerFactory {
+	restarts := NewRestartCoordinator(systemd)
 	return func(cfg *config.Config, logger *slog.Logger, dryRun bool, opts *PlanEngineOptions) Runner {
 		if opts != nil {
 			return NewEngineWithPlanOptions(cfg, gitFactory, systemd, logger, *opts)
 		}
-		return NewEngineWithFactory(cfg, gitFactory, systemd, logger, dryRun)
+		e := NewEngineWithFactory(cfg, gitFactory, systemd, logger, dryRun)
+		e.restarts = restarts
+		return e
 	}
 }
 
@@ -55,9 +68,32 @@ var _ Runner = (*Engine)(nil)
 
 // Result contains the outcome of a sync operation.
 type Result struct {
-	Revisions map[string]string // repo_url -> commit_sha
-	Conflicts []Conflict        // same-path conflicts encountered
-	Plan      *Plan             // computed plan (always populated, even in dry-run)
+	Revisions      map[string]string // repo_url -> commit_sha
+	Refs           map[string]string // repo_url -> ref checked out (differs from the config with an override)
+	Conflicts      []Conflict        // same-path conflicts encountered
+	Plan           *Plan             // computed plan (always populated, even in dry-run)
+	Applied        bool              // whether the plan was written to the quadlet dir
+	Frozen         bool              // whether applying was skipped because syncs are frozen
+	Paused         bool              // whether the run did nothing because syncs are paused
+	Skipped        bool              // whether the run stopped early because nothing changed since the last sync
+	RestartedUnits []string          // units try-restarted successfully (sorted)
+	RestartFailed  []string          // units whose try-restart failed (sorted)
+	DeferredUnits  []string          // units whose restart waits for a sync outside sync.windows (sorted)
+	StartedUnits   []string          // new units passed to start with sync.start_new (sorted)
+	UnhealthyUnits []string          // restarted or started units that failed within sync.health_check.window (sorted)
+	AutoUpdated    []string          // units podman auto-update updated with sync.run_auto_update (sorted)
+	Durations      PhaseDurations    // wall-clock time spent per phase
+	Warnings       []Warning         // non-fatal issues, in the order they occurred
+}
+
+// PhaseDurations records how long each phase of a sync took. Phases that did
+// not run (e.g. apply in dry-run mode) are left at zero.
+type PhaseDurations struct {
+	Fetch   time.Duration
+	Plan    time.Duration
+	Apply   time.Duration
+	Reload  time.Duration
+	Restart time.Duration
 }
 
 // Conflict captures a same-path conflict resolved during merge.
@@ -77,7 +113,8 @@ type ConflictLoser struct {
 }
 
 // SpecOverride allows overriding the ref and/or commit for a specific repository URL.
-// Used by plan mode to checkout a specific ref/commit without affecting the live checkout.
+// Used by plan mode to checkout a specific ref/commit without affecting the live
+// checkout, and by sync --ref to apply one in the live checkout for a single run.
 type SpecOverride struct {
 	// Ref overrides the configured ref for this repository.
 	Ref string
@@ -85,6 +122,30 @@ type SpecOverride struct {
 	Commit string
 }
 
+// ref returns the ref or commit to check out, or "" when o overrides nothing.
+func (o SpecOverride) ref() string {
+	if o.Commit != "" {
+		return o.Commit
+	}
+	return o.Ref
+}
+
+// overrideLabel describes the overrides of the run for the history log: the
+// overriding ref of a single repository, or url=ref pairs for several.
+func (e *Engine) overrideLabel() string {
+	if repos := e.cfg.EffectiveRepositories(); len(repos) == 1 {
+		return e.specOverrides[repos[0].URL].ref()
+	}
+	var labels []string
+	for url, o := range e.specOverrides {
+		if ref := o.ref(); ref != "" {
+			labels = append(labels, url+"="+ref)
+		}
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ", ")
+}
+
 // PlanEngineOptions configures plan-specific engine behaviour.
 type PlanEngineOptions struct {
 	// WorkDir, when non-empty, directs all repo checkouts to isolated subdirectories
@@ -109,6 +170,22 @@ type Engine struct {
 	workDirOverride string                  // isolated checkout root for plan mode
 	specOverrides   map[string]SpecOverride // per-repo ref/commit overrides
 	repoFilter      string                  // if set, only plan this repo URL
+	restarts        *RestartCoordinator     // deduplicates restarts across engines
+	warnings        *warningLedger          // non-fatal issues of the current run
+	secretStore     secrets.Store           // podman secrets; defaulted when secrets are enabled
+	decrypter       secrets.Decrypter       // age/sops; defaulted when secrets are enabled
+	fileDecrypter   secrets.Decrypter       // sops-encrypted files; defaulted on first use
+	labeler         selinux.Labeler         // restorecon; defaulted on first use
+	blobVerifier    blobVerifier            // checksum manifest signatures; cosign when nil
+	imageVerifier   imageVerifier           // image signatures; cosign when nil
+	plaintext       map[string][]byte       // decrypted sources of the current plan, by source path
+	rendered        map[string][]byte       // substituted plain sources of the current plan, by source path; copied to FileOp.Rendered
+	substVars       map[string]string       // host facts and substitution.vars; computed on first use
+	healthInterval  time.Duration           // poll interval of the unit health check; 0 uses defaultHealthInterval
+	ociFactory      OCIClientFactory        // clients for source.type oci; defaulted on first use
+	planWorkers     int                     // files hashed concurrently while planning; 0 uses hashWorkers' default
+	diffs           map[string]*sourceDiff  // per-repo git changes since the last sync, for the current plan
+	force           bool                    // build and apply a plan even when nothing changed since the last sync
 }
 
 // NewEngine creates a new sync engine using a single git client for all repos.
@@ -149,8 +226,61 @@ func NewEngineWithPlanOptions(cfg *config.Config, factory GitClientFactory, syst
 	}
 }
 
+// SetSpecOverrides replaces the per-repo ref/commit overrides applied before
+// checkout. On a live engine the override is checked out in the live checkout
+// and recorded in the state; the next run without it returns to the
+// configured ref.
+func (e *Engine) SetSpecOverrides(overrides map[string]SpecOverride) {
+	e.specOverrides = overrides
+}
+
 // Run executes the complete sync process and returns structured results.
+// Applied (non dry-run) runs are recorded in the sync history log.
 func (e *Engine) Run(ctx context.Context) (*Result, error) {
+	start := time.Now()
+	e.warnings = &warningLedger{}
+	result, err := e.run(ctx)
+	clear(e.plaintext)
+	e.plaintext = nil
+	e.rendered = nil
+	e.diffs = nil
+	if result != nil {
+		result.Warnings = e.warnings.list()
+	}
+	if !e.dryRun {
+		entry := newHistoryEntry(start, result, err)
+		entry.Warnings = len(e.warnings.list())
+		entry.Ref = e.overrideLabel()
+		if herr := AppendHistory(e.cfg.Paths.StateDir, entry); herr != nil {
+			e.warn(WarnHistoryNotRecorded, HistoryFilePath(e.cfg.Paths.StateDir),
+				"failed to record sync history", "error", herr)
+		}
+	}
+	if result != nil {
+		result.Warnings = e.warnings.list()
+		e.logWarningSummary(result.Warnings)
+	}
+	return result, err
+}
+
+func (e *Engine) run(ctx context.Context) (*Result, error) {
+	// A pause turns every applying sync into a no-op; dry runs and plans
+	// change nothing and still work, also off Linux.
+	if !e.dryRun {
+		if !applySupported() {
+			return nil, unsupportedApplyError()
+		}
+		pause, err := ReadPause(e.cfg.Paths.StateDir)
+		if err != nil {
+			return nil, err
+		}
+		if pause != nil {
+			e.logger.Info("syncs are paused, skipping", logging.Event(logging.EventSyncPaused),
+				"since", pause.Since, "reason", pause.Reason)
+			return &Result{Conflicts: []Conflict{}, Plan: &Plan{}, Paused: true}, nil
+		}
+	}
+
 	repos := e.cfg.EffectiveRepositories()
 
 	// Apply repo filter: if set, restrict to the matching URL only.
@@ -167,9 +297,9 @@ func (e *Engine) Run(ctx context.Context) (*Result, error) {
 		}
 	}
 
-	e.logger.Info("starting sync",
+	e.logger.Info("starting sync", logging.Event(logging.EventSyncStarted),
 		"repo_count", len(repos),
-		"dry_run", e.dryRun)
+		logging.KeyDryRun, e.dryRun)
 
 	// Ensure state directory exists
 	if err := os.MkdirAll(e.cfg.Paths.StateDir, 0755); err != nil {
@@ -177,73 +307,85 @@ func (e *Engine) Run(ctx context.Context) (*Result, error) {
 	}
 
 	// Load all repo states (fail-fast: if any repo fails, nothing is applied)
-	repoStates, err := e.loadAllRepoStates(ctx, repos)
+	var durations PhaseDurations
+	phaseStart := time.Now()
+	repoStates, mergeResult, err := e.loadEffective(ctx, repos)
 	if err != nil {
 		return nil, err
 	}
+	durations.Fetch = time.Since(phaseStart)
+	phaseStart = time.Now()
 
-	for _, rs := range repoStates {
-		e.logger.Info("repository loaded",
-			"repo", rs.Spec.URL,
-			"ref", rs.Spec.Ref,
-			"commit", rs.Commit,
-			"files", len(rs.Files))
-	}
-
-	// Merge repo states into effective state
-	conflictMode := e.cfg.Sync.ConflictHandling
-	if conflictMode == "" {
-		conflictMode = config.ConflictPreferHighestPriority
+	// Load previous state
+	prevState, err := e.loadState()
+	if errors.Is(err, ErrStateTooNew) {
+		// Syncing from scratch would reinstall every file and overwrite
+		// state the newer version relies on.
+		return nil, fmt.Errorf("failed to load previous state: %w", err)
 	}
-	mergeResult, err := multirepo.Merge(repoStates, conflictMode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to merge repository states: %w", err)
+		e.warn(WarnStateUnreadable, e.cfg.StateFilePath(), "failed to load previous state (will treat as fresh sync)", "error", err)
+		prevState = &State{ManagedFiles: make(map[string]ManagedFile)}
 	}
 
-	// Warn on same-path conflicts in prefer mode
-	for _, c := range mergeResult.Conflicts {
-		loserRepos := make([]string, len(c.Losers))
-		for i, l := range c.Losers {
-			loserRepos[i] = fmt.Sprintf("%s@%s", l.SourceRepo, l.SourceRef)
-		}
-		e.logger.Warn("same-path conflict resolved by priority",
-			"path", c.MergeKey,
-			"winner_repo", c.Winner.SourceRepo,
-			"winner_ref", c.Winner.SourceRef,
-			"losers", strings.Join(loserRepos, ", "),
-			"remediation", "adjust priorities or remove duplicate definitions")
+	// Nothing to do when the last sync already installed these revisions
+	// and nobody touched its files since.
+	if reason := e.syncNeeded(ctx, prevState, repoStates); reason != "" {
+		e.logger.Debug("building sync plan", logging.KeyReason, reason)
+	} else {
+		e.logger.Info("nothing changed since the last sync, skipping", logging.Event(logging.EventSyncSkipped))
+		return e.skippedResult(repoStates, durations), nil
 	}
 
-	e.logger.Info("merge complete",
-		"total_files", len(mergeResult.Items),
-		"conflicts", len(mergeResult.Conflicts))
-
-	// Load previous state
-	prevState, err := e.loadState()
+	// Build sync plan from effective items, skipping sources git reports
+	// unchanged since the last sync.
+	e.diffs = e.sourceDiffs(ctx, prevState, repoStates)
+	plan, err := e.buildPlanFromEffective(ctx, prevState, mergeResult.Items)
 	if err != nil {
-		e.logger.Warn("failed to load previous state (will treat as fresh sync)", "error", err)
-		prevState = &State{ManagedFiles: make(map[string]ManagedFile)}
+		return nil, fmt.Errorf("failed to build sync plan: %w", err)
 	}
-
-	// Build sync plan from effective items
-	plan, err := e.buildPlanFromEffective(prevState, mergeResult.Items)
+	secretItems, err := collectSecrets(repoStates)
 	if err != nil {
+		return nil, fmt.Errorf("failed to merge repository states: %w", err)
+	}
+	if plan.Secrets, err = e.buildSecretOps(ctx, prevState, secretItems, mergeResult.Items); err != nil {
 		return nil, fmt.Errorf("failed to build sync plan: %w", err)
 	}
 
-	e.logger.Info("sync plan",
+	durations.Plan = time.Since(phaseStart)
+
+	e.logger.Info("sync plan", logging.Event(logging.EventPlanComputed),
 		"add", len(plan.Add),
 		"update", len(plan.Update),
-		"delete", len(plan.Delete))
+		"delete", len(plan.Delete),
+		"secrets", len(plan.Secrets))
+
+	// Catch read-only or foreign-owned destinations before touching anything.
+	// A dry run only reports the problem so the plan can still be inspected.
+	if err := e.preflightDestinations(plan); err != nil {
+		var nwErr *DestNotWritableError
+		if !e.dryRun || !errors.As(err, &nwErr) {
+			return nil, err
+		}
+		e.warn(WarnDestNotWritable, nwErr.Dir, "destination is not writable, applying this plan would fail",
+			"error", nwErr.Err, "hint", nwErr.Hint)
+	}
+	if err := e.checkPodmanCompat(ctx, plan); err != nil {
+		return nil, err
+	}
+	e.checkInstallTargets(plan)
 
 	// Build result with revisions and conflicts
 	result := &Result{
 		Revisions: make(map[string]string),
+		Refs:      make(map[string]string),
 		Conflicts: make([]Conflict, 0, len(mergeResult.Conflicts)),
 		Plan:      plan,
+		Durations: durations,
 	}
 	for _, rs := range repoStates {
 		result.Revisions[rs.Spec.URL] = rs.Commit
+		result.Refs[rs.Spec.URL] = rs.Spec.Ref
 	}
 	for _, c := range mergeResult.Conflicts {
 		losers := make([]ConflictLoser, len(c.Losers))
@@ -269,21 +411,61 @@ func (e *Engine) Run(ctx context.Context) (*Result, error) {
 		return result, nil
 	}
 
+	// A freeze keeps the plan for reporting but applies nothing.
+	freeze, err := ReadFreeze(e.cfg.Paths.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	if freeze != nil {
+		result.Frozen = true
+		if pending := len(plan.Add) + len(plan.Update) + len(plan.Delete) + len(plan.Secrets); pending > 0 {
+			e.logPlanDetails(plan)
+			e.warn(WarnSyncFrozen, "", fmt.Sprintf("syncs are frozen, %d pending changes not applied", pending),
+				"since", freeze.Since, "reason", freeze.Reason)
+		} else {
+			e.logger.Info("syncs are frozen, no changes pending", "since", freeze.Since, "reason", freeze.Reason)
+		}
+		return result, nil
+	}
+
 	// Check systemd availability
-	available, err := e.systemd.IsAvailable(ctx)
-	if err != nil || !available {
-		return nil, fmt.Errorf("systemd user session not available: %w", err)
+	if err := e.preflight(ctx); err != nil {
+		return nil, err
 	}
 
-	// Apply plan
-	if err := e.applyPlan(plan); err != nil {
-		return nil, fmt.Errorf("failed to apply sync plan: %w", err)
+	// From here until systemd has reloaded, cancellation would leave files,
+	// state and the loaded units out of step. Abort now if the run is already
+	// cancelled, and otherwise finish the sequence; every command in it is
+	// still bounded by its per-command timeout.
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("sync aborted before applying changes: %w", err)
 	}
+	applyCtx := context.WithoutCancel(ctx)
 
-	// Validate quadlet definitions
-	e.logger.Info("validating quadlet definitions", "quadlet_dir", e.cfg.Paths.QuadletDir)
-	if err := e.systemd.ValidateQuadlets(ctx, e.cfg.Paths.QuadletDir); err != nil {
-		return nil, fmt.Errorf("failed to validate quadlet definitions: %w", err)
+	// Keep the outgoing file set so `quadsyncd restore` can roll back to it.
+	phaseStart = time.Now()
+	if e.cfg.Sync.BackupRetention > 0 && len(plan.Update)+len(plan.Delete)+len(plan.Add) > 0 {
+		if err := e.backupState(prevState); err != nil {
+			return nil, fmt.Errorf("failed to back up managed files: %w", err)
+		}
+	}
+
+	// Secrets are decrypted before anything changes, so a missing key fails
+	// the sync without side effects; applyPlan loads them once the files
+	// passed validation.
+	plaintext, err := e.decryptSecrets(applyCtx, plan.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stage, validate and apply plan
+	err = e.applyPlan(applyCtx, plan, plaintext)
+	clear(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.removeSecrets(applyCtx, plan.Secrets); err != nil {
+		return nil, err
 	}
 
 	// Save new state
@@ -291,75 +473,224 @@ func (e *Engine) Run(ctx context.Context) (*Result, error) {
 	if err := e.saveState(newState); err != nil {
 		return nil, fmt.Errorf("failed to save state: %w", err)
 	}
-
-	// Reload systemd
-	e.logger.Info("reloading systemd daemon")
-	if err := e.systemd.DaemonReload(ctx); err != nil {
-		return nil, fmt.Errorf("failed to reload systemd: %w", err)
+	result.Applied = true
+	e.audit(applyCtx, fileAuditEntries(prevState, plan))
+	result.Durations.Apply = time.Since(phaseStart)
+
+	// Reload systemd, unless no file changed and the generator would
+	// produce the same units again.
+	phaseStart = time.Now()
+	if len(plan.Add)+len(plan.Update)+len(plan.Delete) > 0 || e.cfg.Sync.AlwaysReload {
+		e.logger.Info("reloading systemd daemon", logging.Event(logging.EventDaemonReload))
+		if err := e.systemd.DaemonReload(applyCtx); err != nil {
+			return nil, fmt.Errorf("failed to reload systemd: %w", err)
+		}
+	} else {
+		e.logger.Debug("no files changed, skipping systemd daemon reload")
 	}
+	reloadedAt := time.Now()
+	result.Durations.Reload = reloadedAt.Sub(phaseStart)
+	e.enableNewUnits(applyCtx, plan)
 
 	// Handle restarts based on policy
-	if err := e.handleRestarts(ctx, plan, newState); err != nil {
-		e.logger.Warn("restart operations had issues", "error", err)
+	phaseStart = time.Now()
+	e.restartUnits(ctx, plan, newState, reloadedAt, result)
+	result.DeferredUnits = newState.PendingRestarts
+	if e.cfg.Sync.StartNew {
+		result.StartedUnits = e.startNewUnits(ctx, plan)
+		e.audit(ctx, restartAuditEntries(nil, nil, nil, result.StartedUnits))
+	}
+	result.Durations.Restart = time.Since(phaseStart)
+
+	if err := e.verifyUnitHealth(ctx, result); err != nil {
+		return result, err
 	}
+	e.runAutoUpdate(ctx, result)
 
-	e.logger.Info("sync completed successfully")
+	e.logger.Info("sync completed successfully", logging.Event(logging.EventSyncCompleted))
 	return result, nil
 }
 
-// loadAllRepoStates loads all repositories fail-fast.
-// If any repo fails to load, the function returns immediately.
+// loadEffective loads repos and merges them into the effective file set,
+// warning about same-path conflicts resolved by priority.
+func (e *Engine) loadEffective(ctx context.Context, repos []config.RepoSpec) ([]multirepo.RepoState, multirepo.MergeResult, error) {
+	repoStates, err := e.loadAllRepoStates(ctx, repos)
+	if err != nil {
+		return nil, multirepo.MergeResult{}, err
+	}
+
+	for _, rs := range repoStates {
+		e.logger.Info("repository loaded", logging.Event(logging.EventRepoLoaded),
+			"repo", rs.Spec.URL,
+			"ref", rs.Spec.Ref,
+			logging.KeyCommit, rs.Commit,
+			"files", len(rs.Files))
+	}
+
+	// Merge repo states into effective state
+	conflictMode := e.cfg.Sync.ConflictHandling
+	if conflictMode == "" {
+		conflictMode = config.ConflictPreferHighestPriority
+	}
+	mergeResult, err := multirepo.Merge(repoStates, conflictMode)
+	if err != nil {
+		return nil, multirepo.MergeResult{}, fmt.Errorf("failed to merge repository states: %w", err)
+	}
+
+	// Warn on same-path conflicts in prefer mode
+	for _, c := range mergeResult.Conflicts {
+		loserRepos := make([]string, len(c.Losers))
+		for i, l := range c.Losers {
+			loserRepos[i] = fmt.Sprintf("%s@%s", l.SourceRepo, l.SourceRef)
+		}
+		e.warn(WarnConflictResolved, c.MergeKey, "same-path conflict resolved by priority",
+			"path", c.MergeKey,
+			"winner_repo", c.Winner.SourceRepo,
+			"winner_ref", c.Winner.SourceRef,
+			"losers", strings.Join(loserRepos, ", "),
+			"remediation", "adjust priorities or remove duplicate definitions")
+	}
+
+	e.logger.Info("merge complete",
+		"total_files", len(mergeResult.Items),
+		"conflicts", len(mergeResult.Conflicts))
+
+	return repoStates, mergeResult, nil
+}
+
+// loadAllRepoStates loads all repositories, at most sync.max_parallel at a
+// time. Every repository is loaded even when another fails, so that one run
+// reports all failures; they are joined in config order and nothing is
+// applied. States keep config order.
 func (e *Engine) loadAllRepoStates(ctx context.Context, repos []config.RepoSpec) ([]multirepo.RepoState, error) {
-	states := make([]multirepo.RepoState, 0, len(repos))
-
-	for _, spec := range repos {
-		// Apply per-repo spec overrides (plan mode: ref/commit override).
-		if e.specOverrides != nil {
-			if override, ok := e.specOverrides[spec.URL]; ok {
-				if override.Commit != "" {
-					spec.Ref = override.Commit
-				} else if override.Ref != "" {
-					spec.Ref = override.Ref
+	states := make([]multirepo.RepoState, len(repos))
+	errs := make([]error, len(repos))
+
+	parallel := e.cfg.SyncParallelism()
+	if parallel == 1 || len(repos) <= 1 {
+		for i, spec := range repos {
+			states[i], errs[i] = e.loadRepoState(ctx, spec)
+		}
+	} else {
+		var wg gosync.WaitGroup
+		sem := make(chan struct{}, parallel)
+		for i, spec := range repos {
+			wg.Go(func() {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					errs[i] = ctx.Err()
+					return
 				}
-			}
+				states[i], errs[i] = e.loadRepoState(ctx, spec)
+			})
 		}
+		wg.Wait()
+	}
 
-		var gitClient git.Client
-		if e.gitFactory != nil {
-			gitClient = e.gitFactory(e.cfg.AuthForSpec(spec))
-		} else {
-			gitClient = e.git
-		}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
 
-		// Use isolated workdir when set (plan mode), otherwise use live state dirs.
-		var repoDir, srcDir string
-		if e.workDirOverride != "" {
-			repoDir = filepath.Join(e.workDirOverride, "repos", config.RepoID(spec.URL))
-			if spec.Subdir != "" {
-				srcDir = filepath.Join(repoDir, spec.Subdir)
-			} else {
-				srcDir = repoDir
-			}
+// loadRepoState fetches a single repository and reads its quadlet files.
+func (e *Engine) loadRepoState(ctx context.Context, spec config.RepoSpec) (multirepo.RepoState, error) {
+	// Apply per-repo spec overrides (plan mode and sync --ref).
+	if ref := e.specOverrides[spec.URL].ref(); ref != "" {
+		spec.Ref = ref
+	}
+
+	if !spec.IsOCI() && !spec.IsDir() {
+		e.warnTokenExpiry(spec, e.cfg.AuthForSpec(spec), time.Now())
+	}
+	gitClient := e.repoClient(spec)
+
+	// Use isolated workdir when set (plan mode), otherwise use live state dirs.
+	var repoDir, srcDir string
+	if e.workDirOverride != "" {
+		repoDir = filepath.Join(e.workDirOverride, "repos", config.RepoID(spec.URL))
+		if spec.Subdir != "" {
+			srcDir = filepath.Join(repoDir, spec.Subdir)
 		} else {
-			repoDir = e.cfg.RepoDirForSpec(spec)
-			srcDir = e.cfg.QuadletSourceDirForSpec(spec)
+			srcDir = repoDir
 		}
+	} else {
+		repoDir = e.cfg.RepoDirForSpec(spec)
+		srcDir = e.cfg.QuadletSourceDirForSpec(spec)
+	}
 
-		e.logger.Info("fetching repository", "repo", spec.URL, "ref", spec.Ref, "dest", repoDir)
+	e.logger.Info("fetching repository", logging.Event(logging.EventRepoFetch), "repo", spec.URL, "ref", spec.Ref, "dest", repoDir)
 
-		rs, err := multirepo.LoadRepoState(ctx, spec, repoDir, srcDir, gitClient)
-		if err != nil {
-			return nil, err
+	rs, err := multirepo.LoadRepoState(ctx, spec, repoDir, srcDir, git.CheckoutOptions{
+		SparseDirs:          e.cfg.SparseDirsForSpec(spec),
+		SubmoduleJobs:       e.cfg.SyncParallelism(),
+		MaintenanceInterval: e.cfg.GitMaintenance.Interval,
+		MaxSize:             int64(e.cfg.GitMaintenance.MaxSizeMB) << 20,
+		KeepCorrupt:         e.cfg.GitMaintenance.KeepCorrupt,
+	}, gitClient)
+	if err != nil {
+		if git.KindOf(err) == git.FailureAuth {
+			e.logger.Error("repository rejected credentials", logging.Event(logging.EventRepoAuthFailed),
+				"repo", spec.URL,
+				logging.KeyErrorKind, git.FailureAuth,
+				"remediation", "check that the configured key or token is valid and has not expired")
 		}
-		states = append(states, rs)
+		return multirepo.RepoState{}, err
+	}
+	if err := e.verifyChecksums(ctx, rs); err != nil {
+		return multirepo.RepoState{}, fmt.Errorf("repo %s: %w", spec.URL, err)
 	}
+	return rs, nil
+}
 
-	return states, nil
+// repoClient returns the client that checks out spec's source.
+func (e *Engine) repoClient(spec config.RepoSpec) git.Client {
+	switch {
+	case spec.IsOCI():
+		factory := e.ociFactory
+		if factory == nil {
+			factory = NewOCIClientFactory(e.cfg, e.logger)
+		}
+		return factory(spec)
+	case spec.IsDir():
+		return localdir.NewClient(e.logger)
+	case e.gitFactory != nil:
+		return e.gitFactory(e.cfg.AuthForSpec(spec))
+	default:
+		return e.git
+	}
+}
+
+// warnTokenExpiry logs a warning when the HTTPS token used for spec has a
+// recorded expiry that has already passed or falls within the warning window.
+func (e *Engine) warnTokenExpiry(spec config.RepoSpec, auth config.AuthConfig, now time.Time) {
+	expiry, ok := auth.TokenExpiry()
+	if !ok {
+		return
+	}
+	remaining := expiry.Sub(now)
+	switch {
+	case remaining <= 0:
+		e.warn(WarnTokenExpired, spec.URL, "https token has expired",
+			"repo", spec.URL,
+			"expires_at", expiry.Format(time.RFC3339),
+			"remediation", "rotate the token and update auth.https_token_expires_at")
+	case remaining <= config.TokenExpiryWarningWindow:
+		e.warn(WarnTokenExpiring, spec.URL, "https token expires soon",
+			"repo", spec.URL,
+			"expires_at", expiry.Format(time.RFC3339),
+			"days_left", int(remaining.Hours()/24))
+	}
 }
 
 // buildPlanFromEffective computes the diff between the effective items (from
 // multi-repo merge) and the previously managed state.
-func (e *Engine) buildPlanFromEffective(prevState *State, items []multirepo.EffectiveItem) (*Plan, error) {
+func (e *Engine) buildPlanFromEffective(ctx context.Context, prevState *State, items []multirepo.EffectiveItem) (*Plan, error) {
 	plan := &Plan{
 		Add:    make([]FileOp, 0),
 		Update: make([]FileOp, 0),
@@ -369,49 +700,49 @@ func (e *Engine) buildPlanFromEffective(prevState *State, items []multirepo.Effe
 	// Build map of desired dest paths
 	desiredFiles := make(map[string]multirepo.EffectiveItem)
 	for _, item := range items {
-		destPath := filepath.Join(e.cfg.Paths.QuadletDir, filepath.FromSlash(item.MergeKey))
+		destPath, err := e.destPath(item)
+		if err != nil {
+			return nil, err
+		}
+		if other, dup := desiredFiles[destPath]; dup {
+			return nil, fmt.Errorf("%s and %s are both installed to %s", other.MergeKey, item.MergeKey, destPath)
+		}
 		desiredFiles[destPath] = item
 	}
 
-	// Compute add / update
-	for destPath, item := range desiredFiles {
-		hash, err := fileHash(item.AbsPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to compute hash for %s: %w", item.AbsPath, err)
-		}
-
-		op := FileOp{
-			SourcePath: item.AbsPath,
-			DestPath:   destPath,
-			Hash:       hash,
-			SourceRepo: item.SourceRepo,
-			SourceRef:  item.SourceRef,
-			SourceSHA:  item.SourceSHA,
-		}
-
-		if e.dryRun {
-			// Drift-aware: compare desired content against actual on-disk content
-			// rather than the cached state hash.  This correctly shows "update" even
-			// when the file was manually modified (drifted) between syncs.
-			diskHash, diskErr := fileHash(destPath)
-			if diskErr != nil {
-				if os.IsNotExist(diskErr) {
-					// File absent on disk – treat as add.
-					plan.Add = append(plan.Add, op)
-				} else {
-					return nil, fmt.Errorf("failed to compute hash for on-disk file %s: %w", destPath, diskErr)
-				}
-			} else if diskHash != hash {
-				plan.Update = append(plan.Update, op)
-			}
-			// else: on-disk content already matches desired – no operation needed.
-		} else {
-			prev, exists := prevState.ManagedFiles[destPath]
-			if !exists {
-				plan.Add = append(plan.Add, op)
-			} else if prev.Hash != hash {
-				plan.Update = append(plan.Update, op)
+	// Hash sources and check destinations concurrently, then merge the
+	// results in dest order so warnings and errors come out deterministically.
+	dests := slices.Sorted(maps.Keys(desiredFiles))
+	checks := make([]fileCheck, len(dests))
+	e.prepareSourceHashing()
+	err := forEachParallel(ctx, len(dests), e.hashWorkers(), func(ctx context.Context, i int) error {
+		c, err := e.checkFile(ctx, prevState, dests[i], desiredFiles[dests[i]])
+		checks[i] = c
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range checks {
+		e.keepSource(c.op.SourcePath, c.source)
+		switch c.change {
+		case changeAdd:
+			plan.Add = append(plan.Add, c.op)
+		case changeUpdate:
+			plan.Update = append(plan.Update, c.op)
+		}
+		if c.drifted {
+			// Unchanged in the repo, so the plan leaves it alone even
+			// though the file on disk no longer matches.
+			e.warn(WarnDriftIgnored, c.op.DestPath, "managed file differs from synced content and was left unchanged",
+				"dest", c.op.DestPath,
+				"remediation", "run quadsyncd verify, then restore the file or change it in the repository")
+		}
+		if c.change == changeNone && !e.dryRun {
+			if plan.kept == nil {
+				plan.kept = make(map[string]keptFile)
 			}
+			plan.kept[c.op.DestPath] = keptFile{stat: c.verified, sourceFile: c.op.SourceFile}
 		}
 	}
 
@@ -427,8 +758,28 @@ func (e *Engine) buildPlanFromEffective(prevState *State, items []multirepo.Effe
 						continue
 					}
 				}
-				plan.Delete = append(plan.Delete, FileOp{DestPath: destPath})
+				plan.Delete = append(plan.Delete, FileOp{
+					DestPath:     destPath,
+					RestartUnits: prevState.ManagedFiles[destPath].RestartUnits,
+				})
+			}
+		}
+	}
+
+	unmanaged, err := e.unmanagedFiles(prevState, desiredFiles)
+	if err != nil {
+		return nil, err
+	}
+	plan.Unmanaged = unmanaged
+	if len(unmanaged) > 0 && e.cfg.Sync.Prune && e.cfg.Sync.PruneScope == config.PruneAll {
+		if e.cfg.Sync.AllowUnmanagedDelete {
+			for _, path := range unmanaged {
+				plan.Delete = append(plan.Delete, FileOp{DestPath: path})
 			}
+		} else {
+			e.warn(WarnUnmanagedKept, e.cfg.Paths.QuadletDir, "unmanaged files were not pruned",
+				"count", len(unmanaged),
+				"remediation", "pass --allow-unmanaged-delete to let sync.prune_scope all delete them")
 		}
 	}
 
@@ -440,39 +791,255 @@ func (e *Engine) buildPlanFromEffective(prevState *State, items []multirepo.Effe
 	return plan, nil
 }
 
-// applyPlan executes the sync plan
-func (e *Engine) applyPlan(plan *Plan) error {
-	if err := os.MkdirAll(e.cfg.Paths.QuadletDir, 0755); err != nil {
+// Plan changes of a desired file found by checkFile.
+const (
+	changeNone = iota
+	changeAdd
+	changeUpdate
+)
+
+// fileCheck is the outcome of checking one desired file against the state
+// and the disk.
+type fileCheck struct {
+	op     FileOp
+	source sourceContent
+	change int
+	// drifted marks an unchanged managed file whose content on disk no
+	// longer matches the state.
+	drifted bool
+	// verified is the stat of an unchanged managed file whose content was
+	// hashed and matched, so the state can record it for the next plan.
+	verified os.FileInfo
+}
+
+// checkFile hashes the source of the desired file at destPath and decides
+// how the plan treats it. Destinations whose size and mtime still match the
+// state are trusted without hashing. It is safe to call concurrently after
+// prepareSourceHashing.
+func (e *Engine) checkFile(ctx context.Context, prevState *State, destPath string, item multirepo.EffectiveItem) (fileCheck, error) {
+	var src sourceContent
+	if hash, ok := e.reusableHash(prevState, destPath, item); ok {
+		src.hash = hash
+	} else {
+		var err error
+		if src, err = e.hashSource(ctx, item.AbsPath); err != nil {
+			return fileCheck{}, err
+		}
+	}
+	hash := src.hash
+	if e.cfg.Sync.StrictPermissions && e.cfg.Sync.FileMode == 0 && !src.encrypted {
+		if err := checkSourceMode(item.AbsPath); err != nil {
+			return fileCheck{}, err
+		}
+	}
+
+	c := fileCheck{source: src, op: FileOp{
+		SourcePath: item.AbsPath,
+		DestPath:   destPath,
+		Hash:       hash,
+		Encrypted:  src.encrypted,
+		Rendered:   src.rendered,
+		SourceRepo: item.SourceRepo,
+		SourceRef:  item.SourceRef,
+		SourceSHA:  item.SourceSHA,
+
+		SourceLayer:  item.SourceLayer,
+		RestartUnits: item.RestartUnits,
+	}}
+	if d := e.diffs[item.SourceRepo]; d != nil && !src.encrypted && src.rendered == nil {
+		c.op.SourceFile = d.sourceFile(item.AbsPath)
+	}
+	prev, exists := prevState.ManagedFiles[destPath]
+
+	if e.dryRun {
+		// Drift-aware: compare desired content against actual on-disk content
+		// rather than the cached state hash.  This correctly shows "update" even
+		// when the file was manually modified (drifted) between syncs.
+		info, statErr := os.Stat(destPath)
+		if os.IsNotExist(statErr) {
+			// File absent on disk – treat as add.
+			c.change = changeAdd
+			return c, nil
+		}
+		if statErr == nil && exists && prev.Hash == hash && prev.statMatches(info) {
+			return c, nil
+		}
+		diskHash, diskErr := fileHash(destPath)
+		if diskErr != nil {
+			if os.IsNotExist(diskErr) {
+				c.change = changeAdd
+				return c, nil
+			}
+			return fileCheck{}, fmt.Errorf("failed to compute hash for on-disk file %s: %w", destPath, diskErr)
+		}
+		if diskHash != hash {
+			c.change = changeUpdate
+		}
+		// else: on-disk content already matches desired – no operation needed.
+		return c, nil
+	}
+
+	switch {
+	case !exists:
+		c.change = changeAdd
+	case prev.Hash != hash:
+		c.change = changeUpdate
+	default:
+		info, statErr := os.Stat(destPath)
+		if statErr == nil && prev.statMatches(info) {
+			return c, nil
+		}
+		if diskHash, diskErr := fileHash(destPath); diskErr != nil || diskHash != hash {
+			c.drifted = true
+		} else if statErr == nil {
+			c.verified = info
+		}
+	}
+	return c, nil
+}
+
+// unmanagedFiles returns the files in the quadlet dir, sorted, that are
+// neither recorded in prevState nor desired. Hidden files and directories
+// and the state dir are skipped, like in repository checkouts.
+func (e *Engine) unmanagedFiles(prevState *State, desired map[string]multirepo.EffectiveItem) ([]string, error) {
+	files, err := quadlet.DiscoverAllFiles(e.cfg.Paths.QuadletDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan quadlet directory: %w", err)
+	}
+	stateDir := filepath.Clean(e.cfg.Paths.StateDir) + string(filepath.Separator)
+	var unmanaged []string
+	for _, path := range files {
+		if _, ok := prevState.ManagedFiles[path]; ok {
+			continue
+		}
+		if _, ok := desired[path]; ok {
+			continue
+		}
+		if strings.HasPrefix(path, stateDir) {
+			continue
+		}
+		unmanaged = append(unmanaged, path)
+	}
+	sort.Strings(unmanaged)
+	return unmanaged, nil
+}
+
+// destPath returns where item is installed: at its manifest dest, which must
+// lie under sync.allowed_dest_roots, below the dest of the first matching
+// paths.routes entry, in paths.unit_dir for plain units, or below the
+// quadlet dir.
+func (e *Engine) destPath(item multirepo.EffectiveItem) (string, error) {
+	if item.DestPath == "" {
+		if dest, ok := e.cfg.RouteDest(item.MergeKey); ok {
+			return dest, nil
+		}
+		if dest, ok, err := e.unitDest(item.MergeKey); ok || err != nil {
+			return dest, err
+		}
+		return filepath.Join(e.cfg.Paths.QuadletDir, filepath.FromSlash(item.MergeKey)), nil
+	}
+	if !e.cfg.DestAllowed(item.DestPath) {
+		return "", fmt.Errorf("manifest dest %s (from %s) is outside sync.allowed_dest_roots", item.DestPath, item.SourceRepo)
+	}
+	return item.DestPath, nil
+}
+
+// applyPlan executes the sync plan transactionally: every file is first
+// staged into a scratch copy of the quadlet dir, the staged set is validated
+// with the quadlet generator, and only then are the decrypted secrets of the
+// plan loaded and live files replaced via per-file atomic renames. A failure
+// before the commit phase leaves the live quadlet dir and the secrets
+// untouched.
+func (e *Engine) applyPlan(ctx context.Context, plan *Plan, secrets map[string][]byte) error {
+	st, err := e.stagePlan(plan)
+	if err != nil {
+		return fmt.Errorf("failed to stage sync plan: %w", err)
+	}
+	defer st.cleanup()
+
+	validateDir, err := e.validationDir(st, plan)
+	if err != nil {
+		return fmt.Errorf("failed to stage quadlets for validation: %w", err)
+	}
+	if validateDir == "" {
+		e.logger.Debug("no changed quadlets to validate")
+	} else {
+		e.logger.Info("validating quadlet definitions", logging.Event(logging.EventQuadletsValidate),
+			"quadlet_dir", validateDir, "scope", e.cfg.Sync.ValidationScope)
+		if err := e.systemd.ValidateQuadlets(ctx, validateDir); err != nil {
+			if !errors.Is(err, systemduser.ErrValidationSkipped) {
+				return fmt.Errorf("failed to validate quadlet definitions: %w", err)
+			}
+			e.warn(WarnValidationSkipped, e.cfg.Paths.QuadletDir, "quadlet validation skipped", "reason", err)
+		}
+	}
+	if err := e.verifyImageSignatures(ctx, st, plan); err != nil {
+		return err
+	}
+
+	// Load secrets before the files, so new or updated quadlets find them
+	// when they start.
+	if err := e.putSecrets(ctx, plan.Secrets, secrets); err != nil {
+		return err
+	}
+
+	policy, err := newFilePolicy(e.cfg.Sync)
+	if err != nil {
+		return err
+	}
+	if err := policy.mkdirAll(e.cfg.Paths.QuadletDir); err != nil {
 		return fmt.Errorf("failed to create quadlet directory: %w", err)
 	}
 
 	for _, op := range plan.Add {
-		e.logger.Info("adding file", "dest", op.DestPath)
-		if err := e.copyFile(op.SourcePath, op.DestPath); err != nil {
+		e.logger.Info("adding file", append([]any{logging.Event(logging.EventFileAdd), "dest", op.DestPath}, unitAttrs(op)...)...)
+		if err := e.copyFileWithPolicy(st.files[op.DestPath], op.DestPath, policy, op.Encrypted); err != nil {
 			return fmt.Errorf("failed to add file %s: %w", op.DestPath, err)
 		}
 	}
 
 	for _, op := range plan.Update {
-		e.logger.Info("updating file", "dest", op.DestPath)
-		if err := e.copyFile(op.SourcePath, op.DestPath); err != nil {
+		e.logger.Info("updating file", append([]any{logging.Event(logging.EventFileUpdate), "dest", op.DestPath}, unitAttrs(op)...)...)
+		if err := e.copyFileWithPolicy(st.files[op.DestPath], op.DestPath, policy, op.Encrypted); err != nil {
 			return fmt.Errorf("failed to update file %s: %w", op.DestPath, err)
 		}
 	}
 
+	e.restoreContexts(ctx, plan)
+
+	e.disableRemovedUnits(ctx, plan)
 	for _, op := range plan.Delete {
-		e.logger.Info("deleting file", "dest", op.DestPath)
+		if !e.cfg.PruneAllowed(op.DestPath) {
+			e.warn(WarnPruneRefused, op.DestPath, "refusing to delete file outside the quadlet directory",
+				"dest", op.DestPath,
+				"remediation", "check for symlinked directories under paths.quadlet_dir and remove the file by hand if it should go")
+			continue
+		}
+		e.logger.Info("deleting file", append([]any{logging.Event(logging.EventFileDelete), "dest", op.DestPath}, unitAttrs(op)...)...)
 		if err := os.Remove(op.DestPath); err != nil && !os.IsNotExist(err) {
 			return fmt.Errorf("failed to delete file %s: %w", op.DestPath, err)
 		}
+		if err := syncDir(filepath.Dir(op.DestPath)); err != nil {
+			return fmt.Errorf("failed to sync directory of %s: %w", op.DestPath, err)
+		}
 	}
 
 	return nil
 }
 
-// copyFile copies a file from src to dst with atomic write
+// copyFile copies a file from src to dst with an atomic, fsynced write
 func (e *Engine) copyFile(src, dst string) error {
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+	return e.copyFileWithPolicy(src, dst, &filePolicy{uid: -1, gid: -1}, false)
+}
+
+// copyFileWithPolicy is copyFile for installing live files: missing parent
+// directories and dst get the mode and owner of policy. With keepMode, dst
+// keeps the mode of src.
+func (e *Engine) copyFileWithPolicy(src, dst string, policy *filePolicy, keepMode bool) error {
+	if err := policy.mkdirAll(filepath.Dir(dst)); err != nil {
 		return err
 	}
 
@@ -508,42 +1075,255 @@ func (e *Engine) copyFile(src, dst string) error {
 		_ = tmpFile.Close()
 		return err
 	}
+	if err := policy.apply(tmpFile, keepMode); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
 
 	if err := tmpFile.Close(); err != nil {
 		return err
 	}
 
-	return os.Rename(tmpPath, dst)
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(dst))
+}
+
+// syncDir fsyncs a directory so renames and removals within it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	return d.Sync()
 }
 
-// handleRestarts restarts units based on the configured policy
-func (e *Engine) handleRestarts(ctx context.Context, plan *Plan, state *State) error {
+// restartUnits runs handleRestarts and records its outcome in result, with a
+// restart_failed warning per unit that did not restart.
+func (e *Engine) restartUnits(ctx context.Context, plan *Plan, state *State, reloadedAt time.Time, result *Result) {
+	restarted, failed, err := e.handleRestarts(ctx, plan, state, reloadedAt)
+	if err != nil {
+		e.warn(WarnRestartFailed, "", "restart operations had issues", "error", err)
+	}
+	result.RestartedUnits = restarted
+	result.RestartFailed = slices.Sorted(maps.Keys(failed))
+	e.audit(ctx, restartAuditEntries(restarted, failed, state.PendingRestarts, nil))
+	for _, unit := range result.RestartFailed {
+		e.warn(WarnRestartFailed, unit, "unit restart failed", "unit", unit, "error", failed[unit])
+	}
+	if len(restarted) > 0 || len(failed) > 0 {
+		e.logger.Info("restarts finished", "restarted", restarted, "failed", result.RestartFailed)
+	}
+}
+
+// handleRestarts restarts units based on the configured policy and returns
+// the sorted units systemd restarted and the error of each unit whose
+// restart failed. Units another engine already restarted since reloadedAt
+// are skipped. Restarts deferred by an
+// earlier sync are included; inside one of sync.windows the units are
+// recorded in state.PendingRestarts instead of being restarted.
+func (e *Engine) handleRestarts(ctx context.Context, plan *Plan, state *State, reloadedAt time.Time) ([]string, map[string]error, error) {
+	var units []string
+	var msg string
 	switch e.cfg.Sync.Restart {
 	case config.RestartNone:
 		e.logger.Info("restart policy: none, skipping restarts")
-		return nil
+		return nil, nil, e.setPendingRestarts(state, nil)
 
 	case config.RestartChanged:
-		units := e.affectedUnits(plan)
+		units = mergeUnits(e.affectedUnits(plan), state.PendingRestarts)
 		if len(units) == 0 {
 			e.logger.Info("no units affected by changes")
-			return nil
+			return nil, nil, nil
 		}
-		e.logger.Info("restarting affected units", "count", len(units), "units", units)
-		return e.systemd.TryRestartUnits(ctx, units)
+		msg = "restarting affected units"
 
 	case config.RestartAllManaged:
-		units := e.allManagedUnits(state)
+		units = mergeUnits(e.allManagedUnits(state), state.PendingRestarts)
 		if len(units) == 0 {
 			e.logger.Info("no managed units to restart")
-			return nil
+			return nil, nil, nil
 		}
-		e.logger.Info("restarting all managed units", "count", len(units))
-		return e.systemd.TryRestartUnits(ctx, units)
+		msg = "restarting all managed units"
 
 	default:
-		return fmt.Errorf("unknown restart policy: %s", e.cfg.Sync.Restart)
+		return nil, nil, fmt.Errorf("unknown restart policy: %s", e.cfg.Sync.Restart)
+	}
+
+	if window, ok := e.cfg.Sync.ActiveWindow(time.Now()); ok {
+		e.logger.Info("deferring restarts until a sync outside the window", logging.Event(logging.EventUnitRestartDeferred),
+			"window", window.String(), "count", len(units), "units", units)
+		return nil, nil, e.setPendingRestarts(state, units)
+	}
+	if len(state.PendingRestarts) > 0 {
+		e.logger.Info("including deferred restarts", "units", state.PendingRestarts)
+	}
+	e.logger.Info(msg, logging.Event(logging.EventUnitRestart), "count", len(units), "units", units)
+	if err := e.setPendingRestarts(state, nil); err != nil {
+		return nil, nil, err
+	}
+
+	if e.restarts == nil {
+		e.restarts = NewRestartCoordinator(e.systemd)
+	}
+	tiers := e.restartTiers(units, state)
+	if len(tiers) > 1 {
+		e.logger.Info("restarting units in dependency order", "order", tiers)
+	}
+	batches := restartBatches(tiers, e.cfg.Sync.RestartBatchSize)
+	var restarted, skipped []string
+	failed := make(map[string]error)
+	for i, batch := range batches {
+		if len(batches) > 1 {
+			e.logger.Info("restarting batch", "batch", i+1, "of", len(batches), "units", batch)
+		}
+		r, s, err := e.restarts.TryRestartUnits(ctx, batch, reloadedAt)
+		batchFailed := restartFailures(err, r)
+		for _, unit := range r {
+			if unitErr, ok := batchFailed[unit]; ok {
+				failed[unit] = unitErr
+			} else {
+				restarted = append(restarted, unit)
+			}
+		}
+		skipped = append(skipped, s...)
+		if i == len(batches)-1 {
+			break
+		}
+		if halt := e.waitBetweenBatches(ctx, r); halt != "" {
+			var remaining []string
+			for _, b := range batches[i+1:] {
+				remaining = append(remaining, b...)
+			}
+			e.warn(WarnRestartHalted, strings.Join(remaining, ","), "restarts halted, remaining units not restarted",
+				"reason", halt, "units", remaining)
+			break
+		}
+	}
+	if len(skipped) > 0 {
+		e.logger.Info("skipping units already restarted by a concurrent sync", logging.Event(logging.EventUnitRestartSkipped), "units", skipped)
+	}
+	sort.Strings(restarted)
+	return restarted, failed, nil
+}
+
+// restartBatches splits every dependency tier into batches of at most size
+// units, keeping the tier order. A size of 0 restarts each tier at once.
+func restartBatches(tiers [][]string, size int) [][]string {
+	if size <= 0 {
+		return tiers
+	}
+	var batches [][]string
+	for _, tier := range tiers {
+		for len(tier) > size {
+			batches = append(batches, tier[:size])
+			tier = tier[size:]
+		}
+		batches = append(batches, tier)
+	}
+	return batches
+}
+
+// waitBetweenBatches waits sync.restart_batch_delay after a batch. With
+// sync.restart_batch_health_check the units just restarted are polled during
+// the delay. It returns why further batches must not be restarted, or "".
+func (e *Engine) waitBetweenBatches(ctx context.Context, restarted []string) string {
+	delay := e.cfg.Sync.RestartBatchDelay
+	if delay <= 0 {
+		return ""
+	}
+	if e.cfg.Sync.RestartBatchHealthCheck && len(restarted) > 0 {
+		if failed := e.pollUnitHealth(ctx, restarted, delay); len(failed) > 0 {
+			return "units failed after restart: " + strings.Join(failed, ", ")
+		}
+	} else {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// setPendingRestarts records units as the restarts deferred to a later sync
+// and saves state when that changes it.
+func (e *Engine) setPendingRestarts(state *State, units []string) error {
+	if slices.Equal(state.PendingRestarts, units) {
+		return nil
+	}
+	state.PendingRestarts = units
+	if err := e.saveState(state); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// restartTiers groups units into batches restarted one after another, so
+// that pods, networks, volumes and units named in Requires= or After= of an
+// installed quadlet are restarted before the units that use them.
+func (e *Engine) restartTiers(units []string, state *State) [][]string {
+	paths := make(map[string]string)
+	for destPath := range state.ManagedFiles {
+		if quadlet.IsQuadletFile(destPath) {
+			paths[quadlet.UnitNameFromQuadlet(destPath)] = destPath
+		}
+	}
+	deps := make(map[string][]string)
+	for _, unit := range units {
+		path, ok := paths[unit]
+		if !ok {
+			continue
+		}
+		u, err := quadlet.ParseFile(path)
+		if err != nil {
+			e.logger.Debug("cannot read quadlet for restart ordering", "unit", unit, "error", err)
+			continue
+		}
+		deps[unit] = u.Dependencies()
+	}
+	return quadlet.OrderUnits(units, deps)
+}
+
+// startNewUnits starts the units of quadlets the plan added and returns the
+// sorted list of units it asked systemd to start. Template units cannot be
+// started without an instance name and are skipped. A failure is recorded
+// as a warning rather than failing the sync.
+func (e *Engine) startNewUnits(ctx context.Context, plan *Plan) []string {
+	var units []string
+	for _, op := range plan.Add {
+		if !quadlet.IsQuadletFile(op.DestPath) {
+			continue
+		}
+		unit := quadlet.UnitNameFromQuadlet(op.DestPath)
+		if strings.Contains(unit, "@.") {
+			continue
+		}
+		units = append(units, unit)
+	}
+	units = append(units, e.plainUnits(plan.Add, true)...)
+	if len(units) == 0 {
+		return nil
 	}
+	units = mergeUnits(units, nil)
+	e.logger.Info("starting new units", logging.Event(logging.EventUnitStart), "count", len(units), "units", units)
+	if err := e.systemd.StartUnits(ctx, units); err != nil {
+		e.warn(WarnStartFailed, strings.Join(units, ","), "starting new units had issues", "error", err, "units", units)
+	}
+	return units
 }
 
 // affectedUnits returns unit names affected by the plan (added, updated, or deleted).
@@ -552,15 +1332,31 @@ func (e *Engine) affectedUnits(plan *Plan) []string {
 	ops = append(ops, plan.Add...)
 	ops = append(ops, plan.Update...)
 	ops = append(ops, plan.Delete...)
-	return quadletUnitsFromOps(ops)
+	units := quadletUnitsFromOps(ops)
+	units = append(units, e.dropInUnits(ops)...)
+	units = append(units, e.plainUnits(ops[:len(plan.Add)+len(plan.Update)], true)...)
+	for _, op := range plan.Secrets {
+		units = append(units, op.RestartUnits...)
+	}
+	return mergeUnits(units, nil)
 }
 
 // allManagedUnits returns every unit tracked in state (not just changed ones).
 func (e *Engine) allManagedUnits(state *State) []string {
 	units := make(map[string]bool)
-	for destPath := range state.ManagedFiles {
+	for destPath, mf := range state.ManagedFiles {
 		if quadlet.IsQuadletFile(destPath) {
 			units[quadlet.UnitNameFromQuadlet(destPath)] = true
+		} else if unit, ok := e.plainUnit(destPath); ok && !strings.Contains(unit, "@.") {
+			units[unit] = true
+		}
+		for _, unit := range mf.RestartUnits {
+			units[unit] = true
+		}
+	}
+	for _, ms := range state.Secrets {
+		for _, unit := range ms.RestartUnits {
+			units[unit] = true
 		}
 	}
 
@@ -571,12 +1367,19 @@ func (e *Engine) allManagedUnits(state *State) []string {
 	return result
 }
 
-// quadletUnitsFromOps extracts unique systemd unit names from file operations.
+// quadletUnitsFromOps extracts unique systemd unit names from file operations,
+// including the units of drop-ins for a single quadlet and the restart units
+// of manifest-declared files.
 func quadletUnitsFromOps(ops []FileOp) []string {
 	units := make(map[string]bool)
 	for _, op := range ops {
 		if quadlet.IsQuadletFile(op.DestPath) {
 			units[quadlet.UnitNameFromQuadlet(op.DestPath)] = true
+		} else if name, ok := quadlet.DropInQuadlet(op.DestPath); ok {
+			units[quadlet.UnitNameFromQuadlet(name)] = true
+		}
+		for _, unit := range op.RestartUnits {
+			units[unit] = true
 		}
 	}
 
@@ -587,29 +1390,52 @@ func quadletUnitsFromOps(ops []FileOp) []string {
 	return result
 }
 
+// unitAttrs returns a "units" log attribute naming the units op affects, or
+// nothing when it affects none. Journal output uses it to attach the entry to
+// those units.
+func unitAttrs(op FileOp) []any {
+	units := quadletUnitsFromOps([]FileOp{op})
+	if len(units) == 0 {
+		return nil
+	}
+	sort.Strings(units)
+	return []any{logging.KeyUnits, units}
+}
+
 // logPlanDetails logs detailed plan information for dry-run
 func (e *Engine) logPlanDetails(plan *Plan) {
 	for _, op := range plan.Add {
-		e.logger.Info("[dry-run] would add", "dest", op.DestPath, "source", op.SourcePath)
+		e.logger.Info("[dry-run] would add", append([]any{logging.Event(logging.EventFileAdd), logging.KeyDryRun, true, "dest", op.DestPath, "source", op.SourcePath}, unitAttrs(op)...)...)
 	}
 	for _, op := range plan.Update {
-		e.logger.Info("[dry-run] would update", "dest", op.DestPath, "source", op.SourcePath)
+		e.logger.Info("[dry-run] would update", append([]any{logging.Event(logging.EventFileUpdate), logging.KeyDryRun, true, "dest", op.DestPath, "source", op.SourcePath}, unitAttrs(op)...)...)
 	}
 	for _, op := range plan.Delete {
-		e.logger.Info("[dry-run] would delete", "dest", op.DestPath)
+		e.logger.Info("[dry-run] would delete", append([]any{logging.Event(logging.EventFileDelete), logging.KeyDryRun, true, "dest", op.DestPath}, unitAttrs(op)...)...)
+	}
+	for _, op := range plan.Secrets {
+		event := logging.EventSecretPut
+		if op.Action == SecretDelete {
+			event = logging.EventSecretRemove
+		}
+		e.logger.Info("[dry-run] would "+string(op.Action)+" secret", logging.Event(event), logging.KeyDryRun, true, "secret", op.Name, "source", op.SourcePath)
 	}
 }
 
 // buildStateFromEffective creates a new State from the applied plan with provenance.
 func (e *Engine) buildStateFromEffective(prevState *State, plan *Plan, repoStates []multirepo.RepoState) *State {
 	state := &State{
+		Version:      StateVersion,
 		Revisions:    make(map[string]string),
+		Refs:         make(map[string]string),
 		ManagedFiles: make(map[string]ManagedFile),
 	}
 
 	for _, rs := range repoStates {
 		state.Revisions[rs.Spec.URL] = rs.Commit
+		state.Refs[rs.Spec.URL] = rs.Spec.Ref
 	}
+	state.ConfigHash = e.configHash()
 	// For single-repo backward compat, also set the top-level Commit field.
 	if len(repoStates) == 1 {
 		state.Commit = repoStates[0].Commit
@@ -619,54 +1445,92 @@ func (e *Engine) buildStateFromEffective(prevState *State, plan *Plan, repoState
 		for k, v := range prevState.ManagedFiles {
 			state.ManagedFiles[k] = v
 		}
+		state.PendingRestarts = prevState.PendingRestarts
 	}
 
 	for _, op := range plan.Delete {
 		delete(state.ManagedFiles, op.DestPath)
 	}
+	for dest, k := range plan.kept {
+		if mf, ok := state.ManagedFiles[dest]; ok {
+			if k.stat != nil {
+				mf.setStat(k.stat)
+			}
+			mf.SourceFile = k.sourceFile
+			state.ManagedFiles[dest] = mf
+		}
+	}
 
 	for _, op := range append(plan.Add, plan.Update...) {
-		relPath, err := filepath.Rel(e.cfg.Paths.QuadletDir, op.DestPath)
-		if err != nil {
-			e.logger.Error("failed to compute relative path for managed file",
-				"quadletDir", e.cfg.Paths.QuadletDir,
-				"destPath", op.DestPath,
-				"error", err,
-			)
-			relPath = op.DestPath
+		state.ManagedFiles[op.DestPath] = e.managedFile(op)
+	}
+
+	if prevState != nil && len(prevState.Secrets) > 0 {
+		state.Secrets = make(map[string]ManagedSecret, len(prevState.Secrets))
+		for k, v := range prevState.Secrets {
+			state.Secrets[k] = v
+		}
+	}
+	for _, op := range plan.Secrets {
+		if op.Action == SecretDelete {
+			delete(state.Secrets, op.Name)
+			continue
+		}
+		if state.Secrets == nil {
+			state.Secrets = make(map[string]ManagedSecret)
 		}
-		state.ManagedFiles[op.DestPath] = ManagedFile{
-			SourcePath: filepath.ToSlash(relPath),
-			Hash:       op.Hash,
-			SourceRepo: op.SourceRepo,
-			SourceRef:  op.SourceRef,
-			SourceSHA:  op.SourceSHA,
+		state.Secrets[op.Name] = ManagedSecret{
+			SourcePath:   op.Source,
+			Hash:         op.Hash,
+			SourceRepo:   op.SourceRepo,
+			SourceSHA:    op.SourceSHA,
+			RestartUnits: op.RestartUnits,
 		}
 	}
 
 	return state
 }
 
-// loadState loads the previous state from disk
-func (e *Engine) loadState() (*State, error) {
-	data, err := os.ReadFile(e.cfg.StateFilePath())
+// managedFile returns the state entry recording that op was installed.
+func (e *Engine) managedFile(op FileOp) ManagedFile {
+	relPath, err := filepath.Rel(e.cfg.Paths.QuadletDir, op.DestPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return &State{ManagedFiles: make(map[string]ManagedFile)}, nil
-		}
-		return nil, err
+		e.logger.Error("failed to compute relative path for managed file",
+			"quadletDir", e.cfg.Paths.QuadletDir,
+			"destPath", op.DestPath,
+			"error", err,
+		)
+		relPath = op.DestPath
 	}
-
-	var state State
-	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, err
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		// Manifest-declared file outside the quadlet dir.
+		relPath = op.DestPath
 	}
+	mf := ManagedFile{
+		SourcePath:   filepath.ToSlash(relPath),
+		Hash:         op.Hash,
+		SourceRepo:   op.SourceRepo,
+		SourceRef:    op.SourceRef,
+		SourceSHA:    op.SourceSHA,
+		SourceLayer:  op.SourceLayer,
+		SourceFile:   op.SourceFile,
+		RestartUnits: op.RestartUnits,
+	}
+	// Called once op is installed, so the file on disk holds op.Hash.
+	if info, err := os.Stat(op.DestPath); err == nil {
+		mf.setStat(info)
+	}
+	return mf
+}
 
-	return &state, nil
+// loadState loads the previous state from disk
+func (e *Engine) loadState() (*State, error) {
+	return ReadStateFile(e.cfg.StateFilePath())
 }
 
-// saveState persists the state to disk
+// saveState persists the state to disk, stamped with StateVersion.
 func (e *Engine) saveState(state *State) error {
+	state.Version = StateVersion
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return err
@@ -1,21 +1,63 @@
 package sync
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	gosync "sync"
 	"testing"
+	"time"
 
 	"github.com/schaermu/quadsyncd/internal/config"
 	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/schaermu/quadsyncd/internal/logging"
 	"github.com/schaermu/quadsyncd/internal/multirepo"
 	"github.com/schaermu/quadsyncd/internal/quadlet"
 	"github.com/schaermu/quadsyncd/internal/testutil"
 )
 
+// newTestRepo returns a config for a single-repository sync into a fresh
+// temporary directory, with restarts disabled, and a git mock whose
+// checkout holds exactly files on every run. Tests change files between
+// runs to simulate a new commit.
+func newTestRepo(t *testing.T, files map[string]string) (*config.Config, *testutil.MockGitClient) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Restart: config.RestartNone},
+	}
+	mockGit := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			if err := os.RemoveAll(destDir); err != nil {
+				t.Fatalf("RepoSetup: RemoveAll: %v", err)
+			}
+			if err := os.MkdirAll(destDir, 0755); err != nil {
+				t.Fatalf("RepoSetup: MkdirAll: %v", err)
+			}
+			for name, content := range files {
+				path := filepath.Join(destDir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("RepoSetup: MkdirAll: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("RepoSetup: WriteFile: %v", err)
+				}
+			}
+		},
+	}
+	return cfg, mockGit
+}
+
 // buildPlanFromDir is a test helper that discovers files in srcDir and calls
 // buildPlanFromEffective — replacing the removed single-repo buildPlan method.
 func buildPlanFromDir(t *testing.T, engine *Engine, srcDir string, prevState *State) *Plan {
@@ -35,7 +77,7 @@ func buildPlanFromDir(t *testing.T, engine *Engine, srcDir string, prevState *St
 			AbsPath:  absPath,
 		})
 	}
-	plan, err := engine.buildPlanFromEffective(prevState, items)
+	plan, err := engine.buildPlanFromEffective(context.Background(), prevState, items)
 	if err != nil {
 		t.Fatalf("buildPlanFromDir: buildPlanFromEffective: %v", err)
 	}
@@ -311,9 +353,9 @@ func TestApplyPlan(t *testing.T) {
 	}
 
 	cfg := &config.Config{
-		Paths: config.PathsConfig{QuadletDir: quadletDir},
+		Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
 	}
-	engine := &Engine{cfg: cfg, logger: testutil.TestLogger()}
+	engine := &Engine{cfg: cfg, systemd: &testutil.MockSystemd{}, logger: testutil.TestLogger()}
 
 	plan := &Plan{
 		Add:    []FileOp{{SourcePath: addSrc, DestPath: filepath.Join(quadletDir, "new.container")}},
@@ -321,7 +363,7 @@ func TestApplyPlan(t *testing.T) {
 		Delete: []FileOp{{DestPath: delDst}},
 	}
 
-	if err := engine.applyPlan(plan); err != nil {
+	if err := engine.applyPlan(context.Background(), plan, nil); err != nil {
 		t.Fatalf("applyPlan: %v", err)
 	}
 
@@ -353,9 +395,9 @@ func TestApplyPlan_Delete(t *testing.T) {
 	}
 
 	cfg := &config.Config{
-		Paths: config.PathsConfig{QuadletDir: quadletDir},
+		Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
 	}
-	engine := &Engine{cfg: cfg, logger: testutil.TestLogger()}
+	engine := &Engine{cfg: cfg, systemd: &testutil.MockSystemd{}, logger: testutil.TestLogger()}
 
 	plan := &Plan{
 		Add:    []FileOp{},
@@ -366,7 +408,7 @@ func TestApplyPlan_Delete(t *testing.T) {
 		},
 	}
 
-	if err := engine.applyPlan(plan); err != nil {
+	if err := engine.applyPlan(context.Background(), plan, nil); err != nil {
 		t.Fatalf("applyPlan delete: %v", err)
 	}
 
@@ -375,6 +417,49 @@ func TestApplyPlan_Delete(t *testing.T) {
 	}
 }
 
+func TestApplyPlan_DeleteRefusedOutsideQuadletDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	outside := filepath.Join(tmpDir, "outside")
+	for _, dir := range []string{quadletDir, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A symlinked subdirectory leads out of the quadlet dir.
+	if err := os.Symlink(outside, filepath.Join(quadletDir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	victim := filepath.Join(outside, "important.conf")
+	if err := os.WriteFile(victim, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
+	}
+	engine := &Engine{cfg: cfg, systemd: &testutil.MockSystemd{}, logger: testutil.TestLogger()}
+
+	plan := &Plan{
+		Add:    []FileOp{},
+		Update: []FileOp{},
+		Delete: []FileOp{
+			{DestPath: filepath.Join(quadletDir, "linked", "important.conf")},
+			{DestPath: filepath.Join(tmpDir, "state.json")},
+		},
+	}
+	if err := engine.applyPlan(context.Background(), plan, nil); err != nil {
+		t.Fatalf("applyPlan: %v", err)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("file behind symlinked directory was deleted: %v", err)
+	}
+	warnings := engine.warnings.list()
+	if len(warnings) != 2 || warnings[0].Code != WarnPruneRefused {
+		t.Errorf("warnings = %+v, want two %s", warnings, WarnPruneRefused)
+	}
+}
+
 func TestHandleRestarts(t *testing.T) {
 	plan := &Plan{
 		Add:    []FileOp{{DestPath: "/q/app.container", Hash: "a"}},
@@ -430,7 +515,7 @@ func TestHandleRestarts(t *testing.T) {
 			}
 			engine := &Engine{cfg: cfg, systemd: sd, logger: testutil.TestLogger()}
 
-			err := engine.handleRestarts(context.Background(), plan, state)
+			_, _, err := engine.handleRestarts(context.Background(), plan, state, time.Now())
 			if tc.wantErr {
 				if err == nil {
 					t.Fatal("expected error")
@@ -454,7 +539,7 @@ func TestHandleRestarts(t *testing.T) {
 }
 
 func TestAffectedUnits(t *testing.T) {
-	engine := &Engine{logger: testutil.TestLogger()}
+	engine := &Engine{cfg: &config.Config{}, logger: testutil.TestLogger()}
 	plan := &Plan{
 		Add:    []FileOp{{DestPath: "/q/app.container"}},
 		Update: []FileOp{{DestPath: "/q/db.volume"}, {DestPath: "/q/app.env"}},
@@ -569,7 +654,7 @@ func TestSaveAndLoadState(t *testing.T) {
 			t.Errorf("missing key %q", k)
 			continue
 		}
-		if got != v {
+		if !reflect.DeepEqual(got, v) {
 			t.Errorf("key %q: got %+v, want %+v", k, got, v)
 		}
 	}
@@ -616,6 +701,30 @@ func TestRun_DryRun(t *testing.T) {
 	}
 }
 
+func TestRun_SparseCheckoutOfSubdir(t *testing.T) {
+	tmpDir := t.TempDir()
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(filepath.Join(destDir, "deploy"), 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "deploy", "app.container"), []byte("[Container]\nImage=alpine\n"), 0644)
+		},
+	}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main", Subdir: "deploy/"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Restart: config.RestartNone},
+	}
+
+	engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), true)
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := gitMock.Opts.SparseDirs; len(got) != 1 || got[0] != "deploy" {
+		t.Errorf("SparseDirs = %v, want [deploy]", got)
+	}
+}
+
 func TestRun_FullSync(t *testing.T) {
 	tmpDir := t.TempDir()
 	quadletDir := filepath.Join(tmpDir, "quadlet")
@@ -638,9 +747,16 @@ func TestRun_FullSync(t *testing.T) {
 
 	engine := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false)
 
-	if _, err := engine.Run(context.Background()); err != nil {
+	result, err := engine.Run(context.Background())
+	if err != nil {
 		t.Fatalf("Run full sync: %v", err)
 	}
+	if !result.Applied {
+		t.Error("expected result.Applied to be true")
+	}
+	if len(result.RestartedUnits) != 1 || result.RestartedUnits[0] != "web.service" {
+		t.Errorf("expected RestartedUnits [web.service], got %v", result.RestartedUnits)
+	}
 
 	// File should be copied
 	data, err := os.ReadFile(filepath.Join(quadletDir, "web.container"))
@@ -664,6 +780,156 @@ func TestRun_FullSync(t *testing.T) {
 	}
 }
 
+func TestRun_ManifestFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	stateDir := filepath.Join(tmpDir, "state")
+	etcDir := filepath.Join(tmpDir, "etc")
+	caddyDest := filepath.Join(etcDir, "caddy", "Caddyfile")
+
+	manifest := "files:\n  - source: caddy/Caddyfile\n    dest: " + caddyDest + "\n    restart: [caddy.service]\n"
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(filepath.Join(destDir, "caddy"), 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Container]\nImage=nginx\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "caddy", "Caddyfile"), []byte(":80\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, multirepo.ManifestFileName), []byte(manifest), 0644)
+		},
+	}
+	sd := &testutil.MockSystemd{Available: true}
+
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: stateDir},
+		Sync:       config.SyncConfig{Prune: true, Restart: config.RestartChanged, AllowedDestRoots: []string{etcDir}},
+	}
+
+	result, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if data, err := os.ReadFile(caddyDest); err != nil || string(data) != ":80\n" {
+		t.Errorf("manifest file not placed at dest: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, "caddy", "Caddyfile")); !os.IsNotExist(err) {
+		t.Errorf("manifest file should not be copied into quadlet dir, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, multirepo.ManifestFileName)); !os.IsNotExist(err) {
+		t.Errorf("manifest itself should not be synced, stat err = %v", err)
+	}
+	wantUnits := []string{"caddy.service", "web.service"}
+	if !reflect.DeepEqual(result.RestartedUnits, wantUnits) {
+		t.Errorf("RestartedUnits = %v, want %v", result.RestartedUnits, wantUnits)
+	}
+
+	state, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).loadState()
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	mf, ok := state.ManagedFiles[caddyDest]
+	if !ok {
+		t.Fatalf("manifest file missing from state: %v", state.ManagedFiles)
+	}
+	if mf.SourcePath != caddyDest || !reflect.DeepEqual(mf.RestartUnits, []string{"caddy.service"}) {
+		t.Errorf("managed file = %+v", mf)
+	}
+
+	// Dropping the manifest entry prunes the file and restarts its unit.
+	gitMock.CommitHash = "def456"
+	gitMock.RepoSetup = func(destDir string) {
+		_ = os.Remove(filepath.Join(destDir, multirepo.ManifestFileName))
+		_ = os.RemoveAll(filepath.Join(destDir, "caddy"))
+	}
+	result, err = NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if _, err := os.Stat(caddyDest); !os.IsNotExist(err) {
+		t.Errorf("pruned manifest file still exists, stat err = %v", err)
+	}
+	if !reflect.DeepEqual(result.RestartedUnits, []string{"caddy.service"}) {
+		t.Errorf("RestartedUnits after prune = %v, want [caddy.service]", result.RestartedUnits)
+	}
+}
+
+func TestRun_ManifestDestOutsideAllowedRoots(t *testing.T) {
+	tmpDir := t.TempDir()
+	manifest := "files:\n  - source: app.conf\n    dest: " + filepath.Join(tmpDir, "elsewhere", "app.conf") + "\n"
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "app.conf"), []byte("x\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, multirepo.ManifestFileName), []byte(manifest), 0644)
+		},
+	}
+	sd := &testutil.MockSystemd{Available: true}
+
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "q"), StateDir: filepath.Join(tmpDir, "s")},
+		Sync:       config.SyncConfig{Restart: config.RestartChanged, AllowedDestRoots: []string{filepath.Join(tmpDir, "etc")}},
+	}
+
+	_, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "allowed_dest_roots") {
+		t.Fatalf("expected allowed_dest_roots error, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(tmpDir, "elsewhere")); !os.IsNotExist(statErr) {
+		t.Error("nothing should be written outside allowed roots")
+	}
+}
+
+func TestRun_Routes(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	nginxDir := filepath.Join(tmpDir, "nginx")
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(filepath.Join(destDir, "nginx", "sites"), 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Container]\nImage=nginx\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "nginx", "sites", "app.conf"), []byte("server {}\n"), 0644)
+		},
+	}
+	sd := &testutil.MockSystemd{Available: true}
+
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state"),
+			Routes: []config.RouteConfig{{Pattern: "nginx/*/*.conf", Dest: nginxDir}}},
+		Sync: config.SyncConfig{Prune: true, Restart: config.RestartChanged},
+	}
+
+	if _, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	routed := filepath.Join(nginxDir, "sites", "app.conf")
+	if data, err := os.ReadFile(routed); err != nil || string(data) != "server {}\n" {
+		t.Errorf("routed file not installed: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, "nginx")); !os.IsNotExist(err) {
+		t.Errorf("routed file should not be copied into quadlet dir, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, "web.container")); err != nil {
+		t.Errorf("unrouted quadlet not installed: %v", err)
+	}
+
+	// Removing the file prunes it from the route's dest.
+	gitMock.CommitHash = "def456"
+	gitMock.RepoSetup = func(destDir string) {
+		_ = os.RemoveAll(filepath.Join(destDir, "nginx"))
+	}
+	if _, err := NewEngine(cfg, gitMock, sd, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if _, err := os.Stat(routed); !os.IsNotExist(err) {
+		t.Errorf("pruned routed file still exists, stat err = %v", err)
+	}
+}
+
 func TestRun_GitError(t *testing.T) {
 	tmpDir := t.TempDir()
 	gitMock := &testutil.MockGitClient{Err: errors.New("clone failed")}
@@ -816,7 +1082,7 @@ func TestHandleRestarts_ChangedNoQuadletChanges(t *testing.T) {
 		Add: []FileOp{{DestPath: "/quadlet/myapp.env", SourcePath: "/src/myapp.env"}},
 	}
 	state := &State{ManagedFiles: map[string]ManagedFile{}}
-	err := engine.handleRestarts(context.Background(), plan, state)
+	_, _, err := engine.handleRestarts(context.Background(), plan, state, time.Now())
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -837,7 +1103,7 @@ func TestHandleRestarts_AllManagedNoQuadletFiles(t *testing.T) {
 			"/quadlet/app.env": {SourcePath: "app.env", Hash: "abc"},
 		},
 	}
-	err := engine.handleRestarts(context.Background(), plan, state)
+	_, _, err := engine.handleRestarts(context.Background(), plan, state, time.Now())
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -846,6 +1112,77 @@ func TestHandleRestarts_AllManagedNoQuadletFiles(t *testing.T) {
 	}
 }
 
+func TestRestartBatches(t *testing.T) {
+	tiers := [][]string{{"a", "b", "c"}, {"d"}}
+	tests := []struct {
+		size int
+		want [][]string
+	}{
+		{0, tiers},
+		{2, [][]string{{"a", "b"}, {"c"}, {"d"}}},
+		{5, tiers},
+	}
+	for _, tt := range tests {
+		if got := restartBatches(tiers, tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("restartBatches(size %d) = %v, want %v", tt.size, got, tt.want)
+		}
+	}
+}
+
+// TestRun_RestartBatchHealthCheckHalts verifies that a unit failing after
+// its batch stops the remaining batches from being restarted.
+func TestRun_RestartBatchHealthCheckHalts(t *testing.T) {
+	tmpDir := t.TempDir()
+	mg := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			for _, name := range []string{"a", "b", "c"} {
+				_ = os.WriteFile(filepath.Join(destDir, name+".container"), []byte("[Container]\nImage=alpine\n"), 0644)
+			}
+		},
+	}
+	sd := &testutil.MockSystemd{Available: true, UnitStatus: map[string]string{"a.service": "failed"}}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync: config.SyncConfig{
+			Restart:                 config.RestartChanged,
+			RestartBatchSize:        1,
+			RestartBatchDelay:       20 * time.Millisecond,
+			RestartBatchHealthCheck: true,
+		},
+	}
+
+	engine := NewEngine(cfg, mg, sd, testutil.TestLogger(), false)
+	engine.healthInterval = 5 * time.Millisecond
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := [][]string{{"a.service"}}; !reflect.DeepEqual(sd.RestartBatches, want) {
+		t.Errorf("restart batches = %v, want %v", sd.RestartBatches, want)
+	}
+	var halted bool
+	for _, w := range result.Warnings {
+		halted = halted || (w.Code == WarnRestartHalted && w.Subject == "b.service,c.service")
+	}
+	if !halted {
+		t.Errorf("warnings = %+v, want %s for b and c", result.Warnings, WarnRestartHalted)
+	}
+
+	// Without the health check every batch is restarted after the delay.
+	cfg.Sync.RestartBatchHealthCheck = false
+	_ = os.RemoveAll(filepath.Join(tmpDir, "state"))
+	sd = &testutil.MockSystemd{Available: true}
+	if _, err := NewEngine(cfg, mg, sd, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(sd.RestartBatches) != 3 {
+		t.Errorf("restart batches = %v, want 3 batches", sd.RestartBatches)
+	}
+}
+
 // TestRun_RecoversFromCorruptedState verifies that the sync engine treats a
 // corrupted state file as a fresh sync rather than a fatal error.
 func TestRun_RecoversFromCorruptedState(t *testing.T) {
@@ -880,6 +1217,83 @@ func TestRun_RecoversFromCorruptedState(t *testing.T) {
 	}
 }
 
+// TestRun_RestartDependencyOrder verifies that networks, volumes and units
+// referenced by a changed container are restarted before it.
+func TestRun_RestartDependencyOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	mg := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Unit]\nAfter=db.service\n[Container]\nNetwork=app.network\nVolume=data.volume:/data\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "db.container"), []byte("[Container]\nNetwork=app.network\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "app.network"), []byte("[Network]\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "data.volume"), []byte("[Volume]\n"), 0644)
+		},
+	}
+	sd := &testutil.MockSystemd{Available: true}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Restart: config.RestartChanged},
+	}
+
+	result, err := NewEngine(cfg, mg, sd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	wantBatches := [][]string{
+		{"app-network.service", "data-volume.service"},
+		{"db.service"},
+		{"web.service"},
+	}
+	if !reflect.DeepEqual(sd.RestartBatches, wantBatches) {
+		t.Errorf("restart batches = %v, want %v", sd.RestartBatches, wantBatches)
+	}
+	wantUnits := []string{"app-network.service", "data-volume.service", "db.service", "web.service"}
+	if !reflect.DeepEqual(result.RestartedUnits, wantUnits) {
+		t.Errorf("RestartedUnits = %v, want %v", result.RestartedUnits, wantUnits)
+	}
+}
+
+// TestRun_KubeYamlChangeRestartsUnit verifies that changing only the
+// Kubernetes YAML of a .kube quadlet restarts its unit.
+func TestRun_KubeYamlChangeRestartsUnit(t *testing.T) {
+	tmpDir := t.TempDir()
+	yaml := "kind: Pod\nmetadata:\n  name: app\n"
+	mg := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(filepath.Join(destDir, "k8s"), 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "app.kube"), []byte("[Kube]\nYaml=k8s/app.yaml\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "k8s", "app.yaml"), []byte(yaml), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Container]\nImage=nginx\n"), 0644)
+		},
+	}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Restart: config.RestartChanged},
+	}
+	if _, err := NewEngine(cfg, mg, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(context.Background()); err != nil {
+		t.Fatalf("initial Run: %v", err)
+	}
+
+	yaml += "spec: {}\n"
+	mg.CommitHash = "def456"
+	sd := &testutil.MockSystemd{Available: true}
+	result, err := NewEngine(cfg, mg, sd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Plan.Update) != 1 || !strings.HasSuffix(result.Plan.Update[0].DestPath, "app.yaml") {
+		t.Fatalf("Update = %+v, want only app.yaml", result.Plan.Update)
+	}
+	if !reflect.DeepEqual(result.RestartedUnits, []string{"app.service"}) {
+		t.Errorf("RestartedUnits = %v, want [app.service]", result.RestartedUnits)
+	}
+}
+
 // TestRun_HandleRestartsError verifies that restart failures are treated as
 // non-fatal warnings (the sync still succeeds). This is by design: the files
 // have already been synced and the daemon reloaded, so a restart failure should
@@ -911,6 +1325,127 @@ func TestRun_HandleRestartsError(t *testing.T) {
 	}
 }
 
+// TestRun_RestartFailedPerUnit verifies that a restart failing for one unit
+// is reported for that unit only, in the result, warnings and history.
+func TestRun_RestartFailedPerUnit(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Restart: config.RestartChanged},
+	}
+	mg := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "app.container"), []byte("[Container]\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "db.container"), []byte("[Container]\n"), 0644)
+		},
+	}
+	ms := &testutil.MockSystemd{Available: true, RestartFailures: map[string]error{"db.service": errors.New("job failed")}}
+
+	result, err := NewEngine(cfg, mg, ms, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.RestartedUnits, []string{"app.service"}) {
+		t.Errorf("RestartedUnits = %v, want [app.service]", result.RestartedUnits)
+	}
+	if !reflect.DeepEqual(result.RestartFailed, []string{"db.service"}) {
+		t.Errorf("RestartFailed = %v, want [db.service]", result.RestartFailed)
+	}
+	var subjects []string
+	for _, w := range result.Warnings {
+		if w.Code == WarnRestartFailed {
+			subjects = append(subjects, w.Subject)
+		}
+	}
+	if !reflect.DeepEqual(subjects, []string{"db.service"}) {
+		t.Errorf("restart_failed warnings = %v, want one for db.service", subjects)
+	}
+
+	entries, err := ReadHistory(cfg.Paths.StateDir, 1)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ReadHistory() = %v, %v", entries, err)
+	}
+	if entries[0].Restarted != 1 || !reflect.DeepEqual(entries[0].RestartFailed, []string{"db.service"}) {
+		t.Errorf("history entry = %+v, want 1 restarted and db.service failed", entries[0])
+	}
+}
+
+// TestRun_StartNew verifies that sync.start_new starts the units of added
+// quadlets, skipping templates, and leaves updated ones to the restart policy.
+func TestRun_StartNew(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "[Container]\nImage=nginx\n"
+	mg := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte(content), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "data.volume"), []byte("[Volume]\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "worker@.container"), []byte("[Container]\n"), 0644)
+			_ = os.WriteFile(filepath.Join(destDir, "web.env"), []byte("A=1\n"), 0644)
+		},
+	}
+	sd := &testutil.MockSystemd{Available: true}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Restart: config.RestartChanged, StartNew: true},
+	}
+
+	result, err := NewEngine(cfg, mg, sd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []string{"data-volume.service", "web.service"}
+	if !reflect.DeepEqual(result.StartedUnits, want) {
+		t.Errorf("StartedUnits = %v, want %v", result.StartedUnits, want)
+	}
+	if !reflect.DeepEqual(sd.StartedUnits, want) {
+		t.Errorf("started %v, want %v", sd.StartedUnits, want)
+	}
+
+	// An update is not an add: only the restart policy applies.
+	content = "[Container]\nImage=nginx:latest\n"
+	sd = &testutil.MockSystemd{Available: true}
+	result, err = NewEngine(cfg, mg, sd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if sd.StartCalled || result.StartedUnits != nil {
+		t.Errorf("updated units were started: %v", sd.StartedUnits)
+	}
+}
+
+// TestRun_StartNewError verifies that a failing start is a warning, not a
+// failed sync.
+func TestRun_StartNewError(t *testing.T) {
+	tmpDir := t.TempDir()
+	mg := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "app.container"), []byte("[Container]\n[Install]\nWantedBy=default.target\n"), 0644)
+		},
+	}
+	sd := &testutil.MockSystemd{Available: true, StartErr: fmt.Errorf("unit failed")}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlet"), StateDir: filepath.Join(tmpDir, "state")},
+		Sync:       config.SyncConfig{Restart: config.RestartNone, StartNew: true},
+	}
+
+	result, err := NewEngine(cfg, mg, sd, testutil.TestLogger(), false).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run should not fail due to start error, got: %v", err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != WarnStartFailed {
+		t.Errorf("Warnings = %+v, want one %s", result.Warnings, WarnStartFailed)
+	}
+}
+
 func TestRun_DaemonReloadError(t *testing.T) {
 	tmpDir := t.TempDir()
 	stateDir := filepath.Join(tmpDir, "state")
@@ -1045,6 +1580,17 @@ func TestRun_ValidateQuadletsError(t *testing.T) {
 	if _, err := os.Stat(cfg.StateFilePath()); !os.IsNotExist(err) {
 		t.Error("state file should not be saved when validation fails")
 	}
+	// Validation runs against the staged set, so the live dir is untouched.
+	if ms.ValidatedDir == quadletDir {
+		t.Error("ValidateQuadlets should run against the staging directory, not the live quadlet dir")
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, "app.container")); !os.IsNotExist(err) {
+		t.Error("live quadlet dir should not be modified when validation fails")
+	}
+	// The staging directory is cleaned up.
+	if matches, _ := filepath.Glob(filepath.Join(stateDir, "staging-*")); len(matches) != 0 {
+		t.Errorf("staging directories left behind: %v", matches)
+	}
 }
 
 func TestRun_ValidateQuadletsCalled(t *testing.T) {
@@ -1291,6 +1837,162 @@ func TestRun_MultiRepo_FailFast_OneRepoErrors(t *testing.T) {
 	}
 }
 
+func TestRun_MultiRepo_MaxParallel(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "q")
+
+	const repoCount = 6
+	handlers := make(map[string]*testutil.MockGitClient, repoCount)
+	var repos []config.RepoSpec
+	var (
+		mu       gosync.Mutex
+		inFlight int
+		peak     int
+	)
+	for i := 0; i < repoCount; i++ {
+		url := fmt.Sprintf("git@github.com:org/repo%d.git", i)
+		name := fmt.Sprintf("app%d.container", i)
+		repos = append(repos, config.RepoSpec{URL: url, Ref: "main", Priority: i})
+		handlers[url] = &testutil.MockGitClient{
+			CommitHash: fmt.Sprintf("sha%d", i),
+			RepoSetup: func(destDir string) {
+				mu.Lock()
+				inFlight++
+				peak = max(peak, inFlight)
+				mu.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				_ = os.MkdirAll(destDir, 0755)
+				_ = os.WriteFile(filepath.Join(destDir, name), []byte("[Container]\n"), 0644)
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			},
+		}
+	}
+
+	cfg := &config.Config{
+		Repositories: repos,
+		Paths:        config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "s")},
+		Sync:         config.SyncConfig{Restart: config.RestartNone, MaxParallel: 3},
+	}
+	mc := &testutil.MultiMockGitClient{Handlers: handlers}
+	factory := func(auth config.AuthConfig) git.Client { return mc }
+	engine := NewEngineWithFactory(cfg, factory, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if peak < 2 || peak > 3 {
+		t.Errorf("peak concurrent fetches = %d, want between 2 and 3", peak)
+	}
+	if len(result.Revisions) != repoCount {
+		t.Errorf("expected %d revisions, got %d", repoCount, len(result.Revisions))
+	}
+	for i := 0; i < repoCount; i++ {
+		if _, err := os.Stat(filepath.Join(quadletDir, fmt.Sprintf("app%d.container", i))); err != nil {
+			t.Errorf("app%d.container not synced: %v", i, err)
+		}
+	}
+}
+
+func TestRun_MultiRepo_MaxParallel_FailFast(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	url1 := "git@github.com:org/good-repo.git"
+	url2 := "git@github.com:org/bad-repo.git"
+	cfg := &config.Config{
+		Repositories: []config.RepoSpec{
+			{URL: url1, Ref: "main", Priority: 10},
+			{URL: url2, Ref: "main", Priority: 5},
+		},
+		Paths: config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "q"), StateDir: filepath.Join(tmpDir, "s")},
+		Sync:  config.SyncConfig{Restart: config.RestartNone, MaxParallel: 2},
+	}
+	mc := &testutil.MultiMockGitClient{Handlers: map[string]*testutil.MockGitClient{
+		url1: {
+			CommitHash: "sha1",
+			RepoSetup: func(destDir string) {
+				_ = os.MkdirAll(destDir, 0755)
+				_ = os.WriteFile(filepath.Join(destDir, "app.container"), []byte("[Container]\n"), 0644)
+			},
+		},
+		url2: {Err: errors.New("clone failed")},
+	}}
+	factory := func(auth config.AuthConfig) git.Client { return mc }
+	engine := NewEngineWithFactory(cfg, factory, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+
+	_, err := engine.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "clone failed") {
+		t.Fatalf("expected clone failure, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(tmpDir, "q", "app.container")); !os.IsNotExist(statErr) {
+		t.Error("no files should be written when a repo load fails")
+	}
+}
+
+func TestRun_MultiRepo_ReportsEveryFailedRepo(t *testing.T) {
+	good := "git@github.com:org/good-repo.git"
+	bad1 := "git@github.com:org/bad-one.git"
+	bad2 := "git@github.com:org/bad-two.git"
+	for _, maxParallel := range []int{0, 3} {
+		t.Run(fmt.Sprintf("max_parallel=%d", maxParallel), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			cfg := &config.Config{
+				Repositories: []config.RepoSpec{
+					{URL: bad1, Ref: "main", Priority: 10},
+					{URL: good, Ref: "main", Priority: 5},
+					{URL: bad2, Ref: "main", Priority: 1},
+				},
+				Paths: config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "q"), StateDir: filepath.Join(tmpDir, "s")},
+				Sync:  config.SyncConfig{Restart: config.RestartNone, MaxParallel: maxParallel},
+			}
+			var goodFetched bool
+			mc := &testutil.MultiMockGitClient{Handlers: map[string]*testutil.MockGitClient{
+				good: {
+					CommitHash: "sha1",
+					RepoSetup: func(destDir string) {
+						goodFetched = true
+						_ = os.MkdirAll(destDir, 0755)
+						_ = os.WriteFile(filepath.Join(destDir, "app.container"), []byte("[Container]\n"), 0644)
+					},
+				},
+				bad1: {Err: errors.New("clone failed")},
+				bad2: {Err: errors.New("auth failed")},
+			}}
+			factory := func(auth config.AuthConfig) git.Client { return mc }
+			engine := NewEngineWithFactory(cfg, factory, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+
+			_, err := engine.Run(context.Background())
+			if err == nil {
+				t.Fatal("expected an error when repos fail")
+			}
+			msg := err.Error()
+			if !strings.Contains(msg, bad1) || !strings.Contains(msg, bad2) || strings.Index(msg, bad1) > strings.Index(msg, bad2) {
+				t.Errorf("error = %q, want both failed repos in config order", msg)
+			}
+			if !goodFetched {
+				t.Error("a failing repo kept the other repos from being fetched")
+			}
+			if _, statErr := os.Stat(filepath.Join(tmpDir, "q", "app.container")); !os.IsNotExist(statErr) {
+				t.Error("no files should be written when a repo load fails")
+			}
+		})
+	}
+}
+
+func TestNewRunnerFactory_SharesRestartCoordinator(t *testing.T) {
+	cfg := &config.Config{Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"}}
+	sd := &testutil.MockSystemd{Available: true}
+	factory := NewRunnerFactory(testutil.MockGitFactory(&testutil.MockGitClient{}), sd)
+
+	a := factory(cfg, testutil.TestLogger(), false, nil).(*Engine)
+	b := factory(cfg, testutil.TestLogger(), false, nil).(*Engine)
+	if a.restarts == nil || a.restarts != b.restarts {
+		t.Error("engines from one factory must share a restart coordinator")
+	}
+}
+
 func TestBuildStateFromEffective_ProvenanceRecorded(t *testing.T) {
 	tmpDir := t.TempDir()
 	quadletDir := filepath.Join(tmpDir, "q")
@@ -1530,6 +2232,72 @@ func TestNewEngineWithPlanOptions_SpecOverride_Ref(t *testing.T) {
 	}
 }
 
+func TestEngine_SetSpecOverrides_RecordsRef(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlets")
+	stateDir := filepath.Join(tmpDir, "state")
+	if err := os.MkdirAll(quadletDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const repoURL = "https://github.com/test/repo.git"
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: repoURL, Ref: "refs/heads/main"},
+		Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: stateDir},
+		Sync:       config.SyncConfig{Restart: config.RestartNone},
+	}
+
+	var usedRef string
+	mockGit := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			if err := os.MkdirAll(destDir, 0755); err != nil {
+				t.Fatalf("repoSetup MkdirAll: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(destDir, "app.container"), []byte("[Container]\nImage=alpine\n"), 0644); err != nil {
+				t.Fatalf("repoSetup WriteFile: %v", err)
+			}
+		},
+	}
+	factory := func(_ config.AuthConfig) git.Client {
+		return &capturingGitClient{inner: mockGit, usedRef: &usedRef}
+	}
+
+	engine := NewEngineWithFactory(cfg, factory, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+	engine.SetSpecOverrides(map[string]SpecOverride{repoURL: {Ref: "v1.2.0"}})
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if usedRef != "v1.2.0" || result.Refs[repoURL] != "v1.2.0" {
+		t.Errorf("checked out %q, result refs %v, want v1.2.0", usedRef, result.Refs)
+	}
+
+	state, err := engine.loadState()
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if state.Refs[repoURL] != "v1.2.0" || state.Commit != "abc123" {
+		t.Errorf("state refs = %v, commit = %q", state.Refs, state.Commit)
+	}
+	entries, err := ReadHistory(stateDir, 0)
+	if err != nil || len(entries) != 1 || entries[0].Ref != "v1.2.0" {
+		t.Errorf("history = %+v, %v; want ref v1.2.0", entries, err)
+	}
+
+	// Without the override the next run returns to the configured ref.
+	engine = NewEngineWithFactory(cfg, factory, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if usedRef != "refs/heads/main" {
+		t.Errorf("ref = %q, want refs/heads/main", usedRef)
+	}
+	if entries, _ := ReadHistory(stateDir, 1); len(entries) != 1 || entries[0].Ref != "" {
+		t.Errorf("history = %+v, want no ref", entries)
+	}
+}
+
 func TestNewEngineWithPlanOptions_RepoFilter(t *testing.T) {
 	tmpDir := t.TempDir()
 	quadletDir := filepath.Join(tmpDir, "quadlets")
@@ -1773,7 +2541,7 @@ func TestBuildPlan_DryRun_DiskHashReadError(t *testing.T) {
 		})
 	}
 
-	_, planErr := engine.buildPlanFromEffective(prevState, items)
+	_, planErr := engine.buildPlanFromEffective(context.Background(), prevState, items)
 	if planErr == nil {
 		t.Fatal("expected error for unreadable dest file, got nil")
 	}
@@ -1839,7 +2607,8 @@ func TestBuildPlan_DeterministicOrdering(t *testing.T) {
 }
 
 // TestApplyPlan_CopyFailureMidway verifies that applyPlan returns an error
-// when a copy fails mid-execution, and that the already-copied files remain.
+// when a copy fails mid-execution, and that staging keeps the live quadlet dir
+// untouched.
 func TestApplyPlan_CopyFailureMidway(t *testing.T) {
 	tmpDir := t.TempDir()
 	srcDir := filepath.Join(tmpDir, "src")
@@ -1855,9 +2624,9 @@ func TestApplyPlan_CopyFailureMidway(t *testing.T) {
 	}
 
 	cfg := &config.Config{
-		Paths: config.PathsConfig{QuadletDir: quadletDir},
+		Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
 	}
-	engine := &Engine{cfg: cfg, logger: testutil.TestLogger()}
+	engine := &Engine{cfg: cfg, systemd: &testutil.MockSystemd{}, logger: testutil.TestLogger()}
 
 	plan := &Plan{
 		Add: []FileOp{
@@ -1869,14 +2638,14 @@ func TestApplyPlan_CopyFailureMidway(t *testing.T) {
 		Delete: []FileOp{},
 	}
 
-	err := engine.applyPlan(plan)
+	err := engine.applyPlan(context.Background(), plan, nil)
 	if err == nil {
 		t.Fatal("expected error when copy fails midway, got nil")
 	}
 
-	// The first file was copied before the failure – it must still exist.
-	if _, statErr := os.Stat(filepath.Join(quadletDir, "good.container")); os.IsNotExist(statErr) {
-		t.Error("already-copied file should remain on disk after midway failure")
+	// The failure happens while staging, so nothing reaches the live dir.
+	if _, statErr := os.Stat(filepath.Join(quadletDir, "good.container")); !os.IsNotExist(statErr) {
+		t.Error("no file should be installed when staging fails midway")
 	}
 }
 
@@ -1900,9 +2669,9 @@ func TestApplyPlan_DeleteFailureOnDirectory(t *testing.T) {
 	}
 
 	cfg := &config.Config{
-		Paths: config.PathsConfig{QuadletDir: quadletDir},
+		Paths: config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
 	}
-	engine := &Engine{cfg: cfg, logger: testutil.TestLogger()}
+	engine := &Engine{cfg: cfg, systemd: &testutil.MockSystemd{}, logger: testutil.TestLogger()}
 
 	plan := &Plan{
 		Add:    []FileOp{},
@@ -1910,7 +2679,7 @@ func TestApplyPlan_DeleteFailureOnDirectory(t *testing.T) {
 		Delete: []FileOp{{DestPath: targetDir}},
 	}
 
-	if err := engine.applyPlan(plan); err == nil {
+	if err := engine.applyPlan(context.Background(), plan, nil); err == nil {
 		t.Fatal("expected error when deleting non-empty directory, got nil")
 	}
 }
@@ -1921,9 +2690,9 @@ type capturingGitClient struct {
 	usedRef *string
 }
 
-func (c *capturingGitClient) EnsureCheckout(ctx context.Context, url, ref, destDir string) (string, error) {
+func (c *capturingGitClient) EnsureCheckout(ctx context.Context, url, ref, destDir string, opts git.CheckoutOptions) (string, error) {
 	*c.usedRef = ref
-	return c.inner.EnsureCheckout(ctx, url, ref, destDir)
+	return c.inner.EnsureCheckout(ctx, url, ref, destDir, opts)
 }
 
 // trackingURLGitClient records the URL passed to EnsureCheckout.
@@ -1931,10 +2700,227 @@ type trackingURLGitClient struct {
 	urls *[]string
 }
 
-func (c *trackingURLGitClient) EnsureCheckout(_ context.Context, url, _, destDir string) (string, error) {
+func (c *trackingURLGitClient) EnsureCheckout(_ context.Context, url, _, destDir string, _ git.CheckoutOptions) (string, error) {
 	*c.urls = append(*c.urls, url)
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return "", err
 	}
 	return "sha", nil
 }
+
+func TestWarnTokenExpiry(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	spec := config.RepoSpec{URL: "https://github.com/org/repo.git"}
+
+	for _, tc := range []struct {
+		name      string
+		expiresAt string
+		wantMsg   string
+	}{
+		{name: "unset", expiresAt: "", wantMsg: ""},
+		{name: "far future", expiresAt: "2030-06-01", wantMsg: ""},
+		{name: "within window", expiresAt: "2030-01-08", wantMsg: "https token expires soon"},
+		{name: "expired", expiresAt: "2029-12-31", wantMsg: "https token has expired"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			engine := &Engine{logger: slog.New(slog.NewTextHandler(&buf, nil))}
+			auth := config.AuthConfig{HTTPSTokenFile: "/token", HTTPSTokenExpiresAt: tc.expiresAt}
+
+			engine.warnTokenExpiry(spec, auth, now)
+
+			out := buf.String()
+			if tc.wantMsg == "" {
+				if out != "" {
+					t.Errorf("expected no warning, got %q", out)
+				}
+				return
+			}
+			if !strings.Contains(out, tc.wantMsg) || !strings.Contains(out, "level=WARN") {
+				t.Errorf("expected WARN %q, got %q", tc.wantMsg, out)
+			}
+		})
+	}
+}
+
+// TestRun_LogsStableEvents verifies that the engine tags its log records with
+// the stable event names downstream parsers rely on.
+func TestRun_LogsStableEvents(t *testing.T) {
+	engine, _ := warningTestEngine(t, &testutil.MockSystemd{Available: true})
+	var buf bytes.Buffer
+	engine.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got := make(map[string]bool)
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var rec map[string]any
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if ev, ok := rec[logging.KeyEvent].(string); ok {
+			got[ev] = true
+		}
+		// File changes name the units they affect for journal correlation.
+		if rec[logging.KeyEvent] == logging.EventFileAdd {
+			if units, _ := rec[logging.KeyUnits].([]any); len(units) != 1 || units[0] != "app.service" {
+				t.Errorf("file.add units = %v, want [app.service]", rec[logging.KeyUnits])
+			}
+		}
+	}
+	for _, want := range []string{
+		logging.EventSyncStarted,
+		logging.EventRepoFetch,
+		logging.EventPlanComputed,
+		logging.EventFileAdd,
+		logging.EventDaemonReload,
+		logging.EventSyncCompleted,
+	} {
+		if !got[want] {
+			t.Errorf("missing event %q in logs; got %v", want, got)
+		}
+	}
+}
+
+func TestRun_PruneScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		scope       config.PruneScope
+		allow       bool
+		wantDeleted bool
+		wantWarning bool
+	}{
+		{name: "managed keeps unmanaged files", scope: config.PruneManaged},
+		{name: "all without confirmation warns", scope: config.PruneAll, wantWarning: true},
+		{name: "all with confirmation deletes", scope: config.PruneAll, allow: true, wantDeleted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			quadletDir := filepath.Join(tmpDir, "quadlet")
+			stateDir := filepath.Join(tmpDir, "state")
+			if err := os.MkdirAll(filepath.Join(quadletDir, ".hidden"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			handWritten := filepath.Join(quadletDir, "hand.container")
+			hidden := filepath.Join(quadletDir, ".hidden", "other.container")
+			for _, p := range []string{handWritten, hidden} {
+				if err := os.WriteFile(p, []byte("[Container]\nImage=busybox\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			gitMock := &testutil.MockGitClient{
+				CommitHash: "abc123",
+				RepoSetup: func(destDir string) {
+					_ = os.MkdirAll(destDir, 0755)
+					_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Container]\nImage=nginx\n"), 0644)
+				},
+			}
+			cfg := &config.Config{
+				Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+				Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: stateDir},
+				Sync: config.SyncConfig{
+					Prune:                true,
+					PruneScope:           tt.scope,
+					AllowUnmanagedDelete: tt.allow,
+					Restart:              config.RestartNone,
+				},
+			}
+			engine := NewEngine(cfg, gitMock, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false)
+			result, err := engine.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Plan.Unmanaged, []string{handWritten}) {
+				t.Errorf("Unmanaged = %v, want [%s]", result.Plan.Unmanaged, handWritten)
+			}
+			_, statErr := os.Stat(handWritten)
+			if deleted := os.IsNotExist(statErr); deleted != tt.wantDeleted {
+				t.Errorf("hand-written file deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if _, err := os.Stat(hidden); err != nil {
+				t.Errorf("hidden file touched: %v", err)
+			}
+			warned := false
+			for _, w := range result.Warnings {
+				warned = warned || w.Code == WarnUnmanagedKept
+			}
+			if warned != tt.wantWarning {
+				t.Errorf("unmanaged_kept warning = %v, want %v", warned, tt.wantWarning)
+			}
+		})
+	}
+}
+
+// TestRun_CancelledBeforeApply verifies that a run cancelled before it starts
+// applying returns without writing files or reloading systemd.
+func TestRun_CancelledBeforeApply(t *testing.T) {
+	tmpDir := t.TempDir()
+	quadletDir := filepath.Join(tmpDir, "quadlet")
+	gitMock := &testutil.MockGitClient{
+		CommitHash: "abc123",
+		RepoSetup: func(destDir string) {
+			_ = os.MkdirAll(destDir, 0755)
+			_ = os.WriteFile(filepath.Join(destDir, "web.container"), []byte("[Container]\nImage=nginx\n"), 0644)
+		},
+	}
+	cfg := &config.Config{
+		Repository: &config.RepoSpec{URL: "file:///test", Ref: "main"},
+		Paths:      config.PathsConfig{QuadletDir: quadletDir, StateDir: filepath.Join(tmpDir, "state")},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	systemd := &testutil.MockSystemd{Available: true}
+	_, err := NewEngine(cfg, gitMock, systemd, testutil.TestLogger(), false).Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(quadletDir, "web.container")); !os.IsNotExist(err) {
+		t.Errorf("cancelled run wrote files: %v", err)
+	}
+	if systemd.ReloadCalled {
+		t.Error("cancelled run reloaded systemd")
+	}
+}
+
+func TestRun_ReloadOnlyWhenFilesChanged(t *testing.T) {
+	tests := []struct {
+		name         string
+		alwaysReload bool
+		wantReload   bool
+	}{
+		{"empty plan skips reload", false, false},
+		{"always_reload reloads on an empty plan", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := &testutil.MockSystemd{Available: true}
+			engine, _ := warningTestEngine(t, ms)
+			engine.cfg.Sync.AlwaysReload = tt.alwaysReload
+			if _, err := engine.Run(context.Background()); err != nil {
+				t.Fatalf("first Run: %v", err)
+			}
+			if !ms.ReloadCalled {
+				t.Fatal("first Run added files without reloading systemd")
+			}
+
+			ms.ReloadCalled = false
+			engine.force = true
+			result, err := engine.Run(context.Background())
+			if err != nil {
+				t.Fatalf("second Run: %v", err)
+			}
+			if !result.Applied {
+				t.Fatal("forced Run did not apply its empty plan")
+			}
+			if ms.ReloadCalled != tt.wantReload {
+				t.Errorf("reloaded = %v, want %v", ms.ReloadCalled, tt.wantReload)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/schaermu/quadsyncd/internal/oci"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+)
+
+// Syncer is the engine surface for programs that embed quadsyncd instead of
+// running the binary, e.g. a fleet controller driving many hosts.
+type Syncer interface {
+	// Plan fetches the repositories and computes the changes a sync would
+	// make without touching files, secrets or units.
+	Plan(ctx context.Context) (*Result, error)
+	// Apply runs a full sync: plan, apply, daemon-reload and restarts.
+	Apply(ctx context.Context) (*Result, error)
+	// Status reports what the last applied sync left on disk.
+	Status() (*Status, error)
+}
+
+// Compile-time check that *Engine satisfies Syncer.
+var _ Syncer = (*Engine)(nil)
+
+// Status describes the state left by the last applied sync.
+type Status struct {
+	Revisions       map[string]string // repo_url -> last synced commit
+	Refs            map[string]string // repo_url -> ref checked out, when overridden
+	ManagedFiles    int               // number of files quadsyncd manages
+	PendingRestarts []string          // restarts deferred by sync.windows
+	Freeze          *Freeze           // nil unless syncs are frozen
+	Pause           *Pause            // nil unless syncs are paused
+	LastRun         *HistoryEntry     // newest history entry; nil before the first sync
+}
+
+// Option configures an Engine built by New.
+type Option func(*Engine)
+
+// WithLogger sets the logger. The default discards all output.
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *Engine) { e.logger = logger }
+}
+
+// WithGitClient uses one git client for every repository, ignoring per-repo
+// auth overrides.
+func WithGitClient(client git.Client) Option {
+	return func(e *Engine) { e.git = client }
+}
+
+// WithGitClientFactory sets the factory producing a git client per auth
+// configuration. The default is NewGitClientFactory.
+func WithGitClientFactory(factory GitClientFactory) Option {
+	return func(e *Engine) { e.gitFactory = factory }
+}
+
+// WithSystemd sets the systemd client. The default is NewSystemdClient.
+func WithSystemd(systemd systemduser.Systemd) Option {
+	return func(e *Engine) { e.systemd = systemd }
+}
+
+// WithDryRun makes Apply behave like Plan.
+func WithDryRun(dryRun bool) Option {
+	return func(e *Engine) { e.dryRun = dryRun }
+}
+
+// WithForce makes Apply build and apply a plan even when nothing changed
+// since the last sync.
+func WithForce(force bool) Option {
+	return func(e *Engine) { e.force = force }
+}
+
+// WithSpecOverrides checks out another ref or commit per repository URL
+// instead of the configured one.
+func WithSpecOverrides(overrides map[string]SpecOverride) Option {
+	return func(e *Engine) { e.specOverrides = overrides }
+}
+
+// WithRestartCoordinator shares restart deduplication with other engines
+// restarting units of the same user session.
+func WithRestartCoordinator(restarts *RestartCoordinator) Option {
+	return func(e *Engine) { e.restarts = restarts }
+}
+
+// WithOCIClientFactory sets how repositories with source.type oci are
+// fetched.
+func WithOCIClientFactory(factory OCIClientFactory) Option {
+	return func(e *Engine) { e.ociFactory = factory }
+}
+
+// New creates a sync engine for cfg. Without options it uses the shell git
+// client, the configured systemd backend and a discarding logger, the same
+// clients the quadsyncd binary uses.
+func New(cfg *config.Config, opts ...Option) *Engine {
+	e := &Engine{cfg: cfg}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.logger == nil {
+		e.logger = slog.New(slog.DiscardHandler)
+	}
+	if e.git == nil && e.gitFactory == nil {
+		e.gitFactory = NewGitClientFactory(cfg, e.logger)
+	}
+	if e.systemd == nil {
+		e.systemd = NewSystemdClient(cfg, e.logger)
+	}
+	return e
+}
+
+// NewGitClientFactory returns a factory producing shell git clients for the
+// effective auth configuration of each repository, bounded by the configured
+// git timeout and retried after network errors.
+func NewGitClientFactory(cfg *config.Config, logger *slog.Logger) GitClientFactory {
+	return func(auth config.AuthConfig) git.Client {
+		client := git.NewShellClientWithTimeout(git.AuthOptions{
+			SSHKeyFile:               auth.SSHKeyFile,
+			SSHKnownHostsFile:        auth.SSHKnownHostsFile,
+			SSHStrictHostKeyChecking: auth.SSHStrictHostKeyChecking,
+			HTTPSTokenFile:           auth.TokenFile(),
+			HTTPSTokenCommand:        auth.HTTPSTokenCommand,
+			HTTPSUsername:            auth.HTTPSUsername,
+		}, cfg.Timeouts.Git, logger)
+		return git.NewRetryClient(client, git.RetryPolicy{
+			Attempts:  cfg.GitRetry.Attempts,
+			BaseDelay: cfg.GitRetry.BaseDelay,
+			MaxDelay:  cfg.GitRetry.MaxDelay,
+		}, logger)
+	}
+}
+
+// OCIClientFactory creates the client fetching an OCI artifact repository.
+// The returned client's "commit" is the artifact's manifest digest.
+type OCIClientFactory func(spec config.RepoSpec) git.Client
+
+// NewOCIClientFactory returns a factory producing oras-based clients that
+// enforce each repository's source.digest and source.cosign_key, bounded by
+// the configured git timeout.
+func NewOCIClientFactory(cfg *config.Config, logger *slog.Logger) OCIClientFactory {
+	return func(spec config.RepoSpec) git.Client {
+		return oci.NewClient(oci.Options{
+			Digest:    spec.Source.Digest,
+			CosignKey: spec.Source.CosignKey,
+		}, cfg.Timeouts.Git, logger)
+	}
+}
+
+// NewSystemdClient returns the client for systemd.backend, bounded by the
+// configured systemctl and podman timeouts. Off Linux it returns a stub that
+// supports dry runs only.
+func NewSystemdClient(cfg *config.Config, logger *slog.Logger) systemduser.Systemd {
+	if !applySupported() {
+		return systemduser.NewUnsupported(hostOS)
+	}
+	if cfg.Systemd.Backend == config.SystemdDBus {
+		return systemduser.NewDBusClient(logger, cfg.Timeouts.Systemctl, cfg.Timeouts.Podman)
+	}
+	return systemduser.NewClientWithTimeouts(logger, cfg.Timeouts.Systemctl, cfg.Timeouts.Podman)
+}
+
+// Plan runs the engine in dry-run mode. It is not recorded in the history.
+func (e *Engine) Plan(ctx context.Context) (*Result, error) {
+	plan := *e
+	plan.dryRun = true
+	return plan.Run(ctx)
+}
+
+// Apply runs a sync; it is Run under the Syncer name.
+func (e *Engine) Apply(ctx context.Context) (*Result, error) {
+	return e.Run(ctx)
+}
+
+// Status reads the state, freeze, pause and history files of the state
+// directory.
+func (e *Engine) Status() (*Status, error) {
+	state, err := e.loadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	freeze, err := ReadFreeze(e.cfg.Paths.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	pause, err := ReadPause(e.cfg.Paths.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ReadHistory(e.cfg.Paths.StateDir, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Revisions:       state.Revisions,
+		Refs:            state.Refs,
+		ManagedFiles:    len(state.ManagedFiles),
+		PendingRestarts: state.PendingRestarts,
+		Freeze:          freeze,
+		Pause:           pause,
+	}
+	if len(entries) > 0 {
+		status.LastRun = &entries[0]
+	}
+	return status, nil
+}
@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/git"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestNew_Syncer(t *testing.T) {
+	content := "[Container]\nImage=nginx:1\n"
+	cfg, mockGit := newFreezeTestConfig(t, content)
+	systemd := &testutil.MockSystemd{Available: true}
+	var s Syncer = New(cfg, WithGitClient(mockGit), WithSystemd(systemd), WithLogger(testutil.TestLogger()))
+	ctx := context.Background()
+
+	status, err := s.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.ManagedFiles != 0 || status.LastRun != nil {
+		t.Errorf("Status() before the first sync = %+v, want empty", status)
+	}
+
+	result, err := s.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(result.Plan.Add) != 1 {
+		t.Errorf("Plan() adds %d files, want 1", len(result.Plan.Add))
+	}
+	target := filepath.Join(cfg.Paths.QuadletDir, "web.container")
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("Plan() wrote %s", target)
+	}
+	if entries, _ := ReadHistory(cfg.Paths.StateDir, 0); len(entries) != 0 {
+		t.Errorf("Plan() recorded %d history entries, want none", len(entries))
+	}
+
+	if _, err := s.Apply(ctx); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("Apply() did not write %s: %v", target, err)
+	}
+	if !systemd.ReloadCalled {
+		t.Error("Apply() did not reload systemd")
+	}
+
+	if _, err := SetFreeze(cfg.Paths.StateDir, "release", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetPause(cfg.Paths.StateDir, "incident", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	status, err = s.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.ManagedFiles != 1 || status.Revisions[cfg.Repository.URL] != "abc123" {
+		t.Errorf("Status() = %+v, want one managed file at abc123", status)
+	}
+	if status.LastRun == nil || status.LastRun.Result != HistoryResultSuccess {
+		t.Errorf("Status().LastRun = %+v, want the successful apply", status.LastRun)
+	}
+	if status.Freeze == nil || status.Freeze.Reason != "release" {
+		t.Errorf("Status().Freeze = %+v, want the freeze", status.Freeze)
+	}
+	if status.Pause == nil || status.Pause.Reason != "incident" {
+		t.Errorf("Status().Pause = %+v, want the pause", status.Pause)
+	}
+}
+
+func TestNew_Defaults(t *testing.T) {
+	content := ""
+	cfg, _ := newFreezeTestConfig(t, content)
+	e := New(cfg)
+	if e.logger == nil || e.gitFactory == nil || e.systemd == nil {
+		t.Errorf("New() left defaults unset: logger=%v gitFactory=%v systemd=%v", e.logger, e.gitFactory != nil, e.systemd)
+	}
+	if e.dryRun {
+		t.Error("New() defaults to dry-run")
+	}
+}
+
+func TestNew_OCISourceUsesOCIClient(t *testing.T) {
+	content := "[Container]\nImage=nginx:1\n"
+	cfg, ociClient := newFreezeTestConfig(t, content)
+	cfg.Repository = &config.RepoSpec{
+		URL:    "registry.example.com/ops/quadlets",
+		Ref:    "v1",
+		Source: config.SourceConfig{Type: config.SourceOCI},
+	}
+	ociClient.CommitHash = "sha256:" + strings.Repeat("a", 64)
+	gitClient := &testutil.MockGitClient{Err: errors.New("git must not be used")}
+
+	var gotSpec config.RepoSpec
+	e := New(cfg,
+		WithGitClient(gitClient),
+		WithOCIClientFactory(func(spec config.RepoSpec) git.Client {
+			gotSpec = spec
+			return ociClient
+		}),
+		WithSystemd(&testutil.MockSystemd{Available: true}),
+		WithLogger(testutil.TestLogger()),
+	)
+	result, err := e.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if gitClient.Called {
+		t.Error("Plan() fetched an OCI source with git")
+	}
+	if gotSpec.URL != cfg.Repository.URL || len(result.Plan.Add) != 1 {
+		t.Errorf("Plan() spec=%q adds=%d; want the OCI repository and one add", gotSpec.URL, len(result.Plan.Add))
+	}
+	if result.Plan.Add[0].SourceSHA != ociClient.CommitHash {
+		t.Errorf("SourceSHA = %q, want the manifest digest", result.Plan.Add[0].SourceSHA)
+	}
+}
+
+func TestNew_DirSource(t *testing.T) {
+	content := ""
+	cfg, _ := newFreezeTestConfig(t, content)
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "web.container"), []byte("[Container]\nImage=nginx:1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Repository = &config.RepoSpec{URL: src, Source: config.SourceConfig{Type: config.SourceDir}}
+	gitClient := &testutil.MockGitClient{Err: errors.New("git must not be used")}
+
+	s := New(cfg, WithGitClient(gitClient), WithSystemd(&testutil.MockSystemd{Available: true}), WithLogger(testutil.TestLogger()))
+	result, err := s.Apply(context.Background())
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if gitClient.Called || len(result.Plan.Add) != 1 {
+		t.Fatalf("Apply() git=%v adds=%d; want the directory's one file without git", gitClient.Called, len(result.Plan.Add))
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "web.container")); err != nil {
+		t.Errorf("Apply() did not install web.container: %v", err)
+	}
+
+	// The directory is rescanned on every run.
+	if err := os.Remove(filepath.Join(src, "web.container")); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sync.Prune = true
+	result, err = s.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(result.Plan.Delete) != 1 {
+		t.Errorf("Plan() after removing the file deletes %d files, want 1", len(result.Plan.Delete))
+	}
+}
@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+	"github.com/schaermu/quadsyncd/internal/git"
+)
+
+// ErrSyncTimeout is returned (wrapped) when a sync run exceeded sync.timeout
+// or serve.sync_timeout.
+var ErrSyncTimeout = errors.New("sync timed out")
+
+// ErrorKindTimeout is the run error kind recorded for timed-out runs.
+const ErrorKindTimeout = "timeout"
+
+// RunWithTimeout runs r under a deadline of timeout; 0 means no limit. When
+// the deadline expires, the commands the run is waiting on are killed and the
+// returned error wraps ErrSyncTimeout. Cancellation of ctx itself is passed
+// through unchanged.
+func RunWithTimeout(ctx context.Context, r Runner, timeout time.Duration) (*Result, error) {
+	runCtx, cancel := cmdexec.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := r.Run(runCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s: %w", ErrSyncTimeout, timeout, err)
+	}
+	return result, err
+}
+
+// ErrorKind classifies a failed run for the run history: ErrorKindTimeout
+// for runs that exceeded their timeout, otherwise the git failure kind, or
+// the empty string.
+func ErrorKind(err error) string {
+	if errors.Is(err, ErrSyncTimeout) {
+		return ErrorKindTimeout
+	}
+	return string(git.KindOf(err))
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/git"
+)
+
+// runnerFunc adapts a function to Runner.
+type runnerFunc func(ctx context.Context) (*Result, error)
+
+func (f runnerFunc) Run(ctx context.Context) (*Result, error) { return f(ctx) }
+
+func TestRunWithTimeout(t *testing.T) {
+	blocking := runnerFunc(func(ctx context.Context) (*Result, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	_, err := RunWithTimeout(context.Background(), blocking, 10*time.Millisecond)
+	if !errors.Is(err, ErrSyncTimeout) || ErrorKind(err) != ErrorKindTimeout {
+		t.Errorf("RunWithTimeout() error = %v, want ErrSyncTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunWithTimeout(ctx, blocking, time.Hour); errors.Is(err, ErrSyncTimeout) || !errors.Is(err, context.Canceled) {
+		t.Errorf("RunWithTimeout(cancelled) error = %v, want context.Canceled without ErrSyncTimeout", err)
+	}
+
+	quick := runnerFunc(func(ctx context.Context) (*Result, error) {
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("timeout 0 set a deadline")
+		}
+		return &Result{}, nil
+	})
+	if _, err := RunWithTimeout(context.Background(), quick, 0); err != nil {
+		t.Errorf("RunWithTimeout(no limit) error = %v", err)
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	netErr := &git.CommandError{Op: "fetch", Kind: git.FailureNetwork, Err: errors.New("unreachable")}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("boom"), ""},
+		{netErr, "network"},
+		{errors.Join(ErrSyncTimeout, netErr), ErrorKindTimeout},
+	}
+	for _, tt := range tests {
+		if got := ErrorKind(tt.err); got != tt.want {
+			t.Errorf("ErrorKind(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
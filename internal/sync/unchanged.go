@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/multirepo"
+)
+
+// configHash fingerprints the configuration a sync runs with, including
+// the host facts substitution would use, so that a changed config is never
+// mistaken for a no-op. It returns "" when the config cannot be encoded,
+// which never matches a recorded hash.
+func (e *Engine) configHash() string {
+	input := struct {
+		Config *config.Config
+		Vars   map[string]string `json:",omitempty"`
+	}{Config: e.cfg}
+	if e.cfg.Substitution.Enabled {
+		input.Vars = e.substitutionVars()
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// syncNeeded returns why the live run must build and apply a plan, or ""
+// when the last applied sync already installed exactly what it would: every
+// repository is at the revision and ref recorded in prevState, the config is
+// the one that sync ran with, no restart is pending, every managed file
+// still has the size and modification time recorded for it and every managed
+// secret is still loaded. Such a run is skipped without planning, validating
+// or reloading systemd.
+func (e *Engine) syncNeeded(ctx context.Context, prevState *State, repoStates []multirepo.RepoState) string {
+	switch {
+	case e.force:
+		return "forced"
+	case e.dryRun:
+		return "dry run"
+	case len(prevState.Revisions) != len(repoStates):
+		return "repositories changed"
+	case prevState.ConfigHash == "" || prevState.ConfigHash != e.configHash():
+		return "config changed"
+	case len(prevState.PendingRestarts) > 0:
+		return "restarts pending"
+	case e.cfg.Sync.PruneScope == config.PruneAll && e.cfg.Sync.AllowUnmanagedDelete:
+		// Unmanaged files are not recorded, so new ones would go unnoticed.
+		return "unmanaged files may be pruned"
+	}
+	for _, rs := range repoStates {
+		prev, ok := prevState.Revisions[rs.Spec.URL]
+		if !ok || rs.Commit == "" || prev != rs.Commit {
+			return fmt.Sprintf("%s is at a new revision", rs.Spec.URL)
+		}
+		if prevState.Refs[rs.Spec.URL] != rs.Spec.Ref {
+			return fmt.Sprintf("%s is at a new ref", rs.Spec.URL)
+		}
+	}
+	for dest, mf := range prevState.ManagedFiles {
+		info, err := os.Stat(dest)
+		if err != nil || !mf.statMatches(info) {
+			return fmt.Sprintf("%s may have drifted", dest)
+		}
+	}
+	if e.cfg.Secrets.Enabled && len(prevState.Secrets) > 0 {
+		e.defaultSecretStore()
+		for name := range prevState.Secrets {
+			if exists, err := e.secretStore.Exists(ctx, name); err != nil || !exists {
+				return fmt.Sprintf("secret %s may be missing", name)
+			}
+		}
+	}
+	return ""
+}
+
+// skippedResult is the result of a run that found nothing to do.
+func (e *Engine) skippedResult(repoStates []multirepo.RepoState, durations PhaseDurations) *Result {
+	result := &Result{
+		Revisions: make(map[string]string, len(repoStates)),
+		Refs:      make(map[string]string, len(repoStates)),
+		Conflicts: []Conflict{},
+		Plan:      &Plan{},
+		Skipped:   true,
+		Durations: durations,
+	}
+	for _, rs := range repoStates {
+		result.Revisions[rs.Spec.URL] = rs.Commit
+		result.Refs[rs.Spec.URL] = rs.Spec.Ref
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestRun_SkipsWhenNothingChanged(t *testing.T) {
+	ms := &testutil.MockSystemd{Available: true}
+	engine, _ := warningTestEngine(t, ms)
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	ms.ReloadCalled, ms.ValidateCalled = false, false
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if !result.Skipped || result.Applied {
+		t.Errorf("Skipped = %v, Applied = %v; want a skipped run", result.Skipped, result.Applied)
+	}
+	if ms.ReloadCalled || ms.ValidateCalled {
+		t.Error("a skipped run validated quadlets or reloaded systemd")
+	}
+	if result.Revisions["file:///test"] != "abc123" {
+		t.Errorf("Revisions = %v, want the current commit", result.Revisions)
+	}
+	history, err := ReadHistory(engine.cfg.Paths.StateDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := history[0]; last.Result != HistoryResultSkipped {
+		t.Errorf("history result = %q, want %q", last.Result, HistoryResultSkipped)
+	}
+}
+
+func TestRun_SyncsWhenSomethingChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, e *Engine, dest string)
+	}{
+		{"forced", func(t *testing.T, e *Engine, _ string) {
+			e.force = true
+		}},
+		{"new commit", func(t *testing.T, e *Engine, _ string) {
+			e.git.(*testutil.MockGitClient).CommitHash = "def456"
+		}},
+		{"new ref", func(t *testing.T, e *Engine, _ string) {
+			e.cfg.Repository.Ref = "release"
+		}},
+		{"config changed", func(t *testing.T, e *Engine, _ string) {
+			e.cfg.Sync.Prune = true
+		}},
+		{"file touched", func(t *testing.T, e *Engine, dest string) {
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(dest, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"file removed", func(t *testing.T, e *Engine, dest string) {
+			if err := os.Remove(dest); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"restart pending", func(t *testing.T, e *Engine, _ string) {
+			state, err := e.loadState()
+			if err != nil {
+				t.Fatal(err)
+			}
+			state.PendingRestarts = []string{"app.service"}
+			if err := e.saveState(state); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := &testutil.MockSystemd{Available: true}
+			engine, quadletDir := warningTestEngine(t, ms)
+			// Whole-dir validation runs on every applied sync, also when no
+			// quadlet changed, so it tells a full sync from a skipped one.
+			engine.cfg.Sync.ValidationScope = config.ValidateAll
+			if _, err := engine.Run(context.Background()); err != nil {
+				t.Fatalf("first Run: %v", err)
+			}
+
+			tt.change(t, engine, filepath.Join(quadletDir, "app.container"))
+			ms.ValidateCalled = false
+			result, err := engine.Run(context.Background())
+			if err != nil {
+				t.Fatalf("second Run: %v", err)
+			}
+			if result.Skipped || !ms.ValidateCalled {
+				t.Errorf("Skipped = %v, validated = %v; want a full sync", result.Skipped, ms.ValidateCalled)
+			}
+		})
+	}
+}
+
+func TestRun_DryRunNeverSkips(t *testing.T) {
+	ms := &testutil.MockSystemd{Available: true}
+	engine, _ := warningTestEngine(t, ms)
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	engine.dryRun = true
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("dry Run: %v", err)
+	}
+	if result.Skipped {
+		t.Error("dry run was skipped, want a plan")
+	}
+}
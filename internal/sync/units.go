@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/install"
+	"github.com/schaermu/quadsyncd/internal/logging"
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+)
+
+// unitEnabler is implemented by the systemd clients that can enable and
+// disable unit files. Engines with other clients leave plain units as they
+// are.
+type unitEnabler interface {
+	EnableUnits(ctx context.Context, units []string, now bool) error
+	DisableUnits(ctx context.Context, units []string, now bool) error
+}
+
+// unitDest returns where the repository file mergeKey is installed when it
+// is a plain systemd unit, i.e. its extension is in sync.unit_extensions:
+// directly in paths.unit_dir, which systemd does not search recursively.
+func (e *Engine) unitDest(mergeKey string) (string, bool, error) {
+	if !slices.Contains(e.cfg.Sync.UnitExtensions, path.Ext(mergeKey)) {
+		return "", false, nil
+	}
+	name := path.Base(mergeKey)
+	if slices.Contains(install.UnitNames, name) {
+		return "", false, fmt.Errorf("%s would replace quadsyncd's own unit %s", mergeKey, name)
+	}
+	return filepath.Join(e.cfg.Paths.UnitDir, name), true, nil
+}
+
+// plainUnit returns the unit defined by the file installed at dest when it
+// is a plain systemd unit managed with sync.unit_extensions.
+func (e *Engine) plainUnit(dest string) (string, bool) {
+	if len(e.cfg.Sync.UnitExtensions) == 0 || filepath.Dir(dest) != filepath.Clean(e.cfg.Paths.UnitDir) {
+		return "", false
+	}
+	name := filepath.Base(dest)
+	return name, slices.Contains(e.cfg.Sync.UnitExtensions, filepath.Ext(name))
+}
+
+// plainUnits returns the plain units defined by the files of ops. Template
+// units, which only run as named instances, are skipped with skipTemplates.
+func (e *Engine) plainUnits(ops []FileOp, skipTemplates bool) []string {
+	var units []string
+	for _, op := range ops {
+		unit, ok := e.plainUnit(op.DestPath)
+		if ok && !(skipTemplates && strings.Contains(unit, "@.")) {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+// enableNewUnits enables the plain units the plan added that have an
+// [Install] section, so that their WantedBy= and similar links are created
+// as `systemctl --user enable` would. Updated units are not enabled again,
+// so a unit disabled by hand stays disabled. A failure is recorded as a
+// warning.
+func (e *Engine) enableNewUnits(ctx context.Context, plan *Plan) {
+	enabler, ok := e.systemd.(unitEnabler)
+	if !ok {
+		return
+	}
+	var units []string
+	for _, op := range plan.Add {
+		unit, ok := e.plainUnit(op.DestPath)
+		if !ok || strings.Contains(unit, "@.") {
+			continue
+		}
+		u, err := quadlet.ParseFile(op.DestPath)
+		if err == nil && u.HasSection("Install") {
+			units = append(units, unit)
+		}
+	}
+	if len(units) == 0 {
+		return
+	}
+	e.logger.Info("enabling new units", logging.Event(logging.EventUnitEnable), "count", len(units), logging.KeyUnits, units)
+	if err := enabler.EnableUnits(ctx, units, false); err != nil {
+		e.warn(WarnEnableFailed, strings.Join(units, ","), "enabling new units failed", "error", err, logging.KeyUnits, units)
+	}
+}
+
+// disableRemovedUnits stops and disables the plain units the plan deletes
+// while their files still exist, so no enablement links are left behind and
+// a removed timer stops firing. A failure is recorded as a warning.
+func (e *Engine) disableRemovedUnits(ctx context.Context, plan *Plan) {
+	enabler, ok := e.systemd.(unitEnabler)
+	if !ok {
+		return
+	}
+	var units []string
+	for _, op := range plan.Delete {
+		if unit, ok := e.plainUnit(op.DestPath); ok && !strings.Contains(unit, "@.") && e.cfg.PruneAllowed(op.DestPath) {
+			units = append(units, unit)
+		}
+	}
+	if len(units) == 0 {
+		return
+	}
+	e.logger.Info("disabling removed units", logging.Event(logging.EventUnitDisable), "count", len(units), logging.KeyUnits, units)
+	if err := enabler.DisableUnits(ctx, units, true); err != nil {
+		e.warn(WarnEnableFailed, strings.Join(units, ","), "disabling removed units failed", "error", err, logging.KeyUnits, units)
+	}
+}
@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// enablingSystemd is a mock systemd client that also enables and disables
+// unit files, like the real clients.
+type enablingSystemd struct {
+	*testutil.MockSystemd
+	enabled  []string
+	disabled []string
+}
+
+func (s *enablingSystemd) EnableUnits(_ context.Context, units []string, _ bool) error {
+	s.enabled = append(s.enabled, units...)
+	return nil
+}
+
+func (s *enablingSystemd) DisableUnits(_ context.Context, units []string, now bool) error {
+	if !now {
+		return nil
+	}
+	s.disabled = append(s.disabled, units...)
+	return nil
+}
+
+// newUnitTestConfig returns a config managing .service and .timer units and
+// a git mock whose checkout holds files.
+func newUnitTestConfig(t *testing.T, files map[string]string) (*config.Config, *testutil.MockGitClient) {
+	t.Helper()
+	cfg, mockGit := newTestRepo(t, files)
+	cfg.Paths.UnitDir = filepath.Join(t.TempDir(), "units")
+	cfg.Sync = config.SyncConfig{Restart: config.RestartChanged, Prune: true, UnitExtensions: []string{".service", ".timer"}}
+	return cfg, mockGit
+}
+
+func TestRun_PlainUnits(t *testing.T) {
+	files := map[string]string{
+		"web.container":         "[Container]\nImage=nginx\n[Install]\nWantedBy=default.target\n",
+		"backup/backup.service": "[Service]\nType=oneshot\nExecStart=/usr/bin/true\n",
+		"backup/backup.timer":   "[Timer]\nOnCalendar=daily\n[Install]\nWantedBy=timers.target\n",
+	}
+	cfg, mockGit := newUnitTestConfig(t, files)
+	systemd := &enablingSystemd{MockSystemd: &testutil.MockSystemd{Available: true}}
+	engine := NewEngine(cfg, mockGit, systemd, testutil.TestLogger(), false)
+
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, name := range []string{"backup.service", "backup.timer"} {
+		if _, err := os.Stat(filepath.Join(cfg.Paths.UnitDir, name)); err != nil {
+			t.Errorf("%s not installed to the unit dir: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.QuadletDir, "backup", "backup.timer")); !os.IsNotExist(err) {
+		t.Errorf("backup.timer installed to the quadlet dir: %v", err)
+	}
+	if !reflect.DeepEqual(systemd.enabled, []string{"backup.timer"}) {
+		t.Errorf("enabled = %v, want the unit with an [Install] section", systemd.enabled)
+	}
+	restarted := slices.Sorted(slices.Values(systemd.RestartedUnits))
+	if !reflect.DeepEqual(restarted, []string{"backup.service", "backup.timer", "web.service"}) {
+		t.Errorf("restarted = %v", restarted)
+	}
+
+	// Removing the timer disables and stops it before its file goes, and
+	// does not try to restart it.
+	delete(files, "backup/backup.timer")
+	mockGit.CommitHash = "def456"
+	systemd.RestartedUnits = nil
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if !reflect.DeepEqual(systemd.disabled, []string{"backup.timer"}) {
+		t.Errorf("disabled = %v, want backup.timer", systemd.disabled)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Paths.UnitDir, "backup.timer")); !os.IsNotExist(err) {
+		t.Errorf("removed timer still installed: %v", err)
+	}
+	if slices.Contains(systemd.RestartedUnits, "backup.timer") {
+		t.Errorf("removed timer restarted: %v", systemd.RestartedUnits)
+	}
+}
+
+func TestRun_PlainUnitConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "same name in two directories",
+			files:   map[string]string{"a/backup.service": "[Service]\n", "b/backup.service": "[Service]\n"},
+			wantErr: "are both installed to",
+		},
+		{
+			name:    "quadsyncd unit",
+			files:   map[string]string{"quadsyncd-sync.timer": "[Timer]\n"},
+			wantErr: "would replace quadsyncd's own unit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, mockGit := newUnitTestConfig(t, tt.files)
+			_, err := NewEngine(cfg, mockGit, &testutil.MockSystemd{Available: true}, testutil.TestLogger(), false).Run(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Run() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package sync
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+)
+
+// validationDir returns the directory the quadlet generator validates
+// before the plan is applied. With sync.validation_scope all it is the whole
+// staged quadlet dir. Otherwise it is a copy under the staging root holding
+// only the staged quadlets the plan touches (see validationSet) and the
+// drop-in directories next to them, or "" when the plan touches no quadlet.
+func (e *Engine) validationDir(st *staging, plan *Plan) (string, error) {
+	if e.cfg.Sync.ValidationScope == config.ValidateAll {
+		return st.QuadletDir, nil
+	}
+	files, err := e.validationSet(st, plan)
+	if err != nil || len(files) == 0 {
+		return "", err
+	}
+
+	dir := filepath.Join(st.Dir, "validate")
+	dropIns := make(map[string]bool)
+	for _, rel := range files {
+		if err := e.copyFile(filepath.Join(st.QuadletDir, rel), filepath.Join(dir, rel)); err != nil {
+			return "", err
+		}
+		dropIns[filepath.Dir(rel)] = true
+	}
+	for parent := range dropIns {
+		entries, err := os.ReadDir(filepath.Join(st.QuadletDir, parent))
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && strings.HasSuffix(entry.Name(), ".d") {
+				if err := e.copyTree(filepath.Join(st.QuadletDir, parent, entry.Name()), filepath.Join(dir, parent, entry.Name())); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	return dir, nil
+}
+
+// validationSet lists the staged quadlets, relative to the staged quadlet
+// dir, that changed mode validates: those the plan adds or updates or whose
+// drop-ins it adds or updates, those referencing a quadlet the plan deletes,
+// and every quadlet they reference in turn. The result is sorted.
+func (e *Engine) validationSet(st *staging, plan *Plan) ([]string, error) {
+	byName := make(map[string][]string)
+	err := filepath.WalkDir(st.QuadletDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !quadlet.IsQuadletFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(st.QuadletDir, path)
+		if err != nil {
+			return err
+		}
+		byName[d.Name()] = append(byName[d.Name()], rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	var queue []string
+	add := func(rel string) {
+		if !selected[rel] {
+			selected[rel] = true
+			queue = append(queue, rel)
+		}
+	}
+
+	for _, op := range append(append([]FileOp{}, plan.Add...), plan.Update...) {
+		staged := e.stagedPath(st, op.DestPath)
+		if staged == "" {
+			continue
+		}
+		rel, err := filepath.Rel(st.QuadletDir, staged)
+		if err != nil {
+			return nil, err
+		}
+		if quadlet.IsQuadletFile(rel) {
+			add(rel)
+			continue
+		}
+		// A drop-in in <name>.d changes the quadlet <name>; one in a
+		// <type>.d or <prefix>-.<type>.d directory, e.g. container.d,
+		// changes every quadlet it applies to.
+		dir, ok := quadlet.DropInDir(rel)
+		if !ok {
+			continue
+		}
+		for name, rels := range byName {
+			if quadlet.DropInApplies(dir, name) {
+				for _, r := range rels {
+					add(r)
+				}
+			}
+		}
+	}
+
+	deleted := make(map[string]bool)
+	for _, op := range plan.Delete {
+		if quadlet.IsQuadletFile(op.DestPath) {
+			deleted[filepath.Base(op.DestPath)] = true
+		}
+	}
+
+	refs := make(map[string][]string)
+	for _, rels := range byName {
+		for _, rel := range rels {
+			u, err := quadlet.ParseFile(filepath.Join(st.QuadletDir, rel))
+			if err != nil {
+				// Left to the generator, which reports the syntax error
+				// when the file is validated.
+				continue
+			}
+			refs[rel] = u.QuadletRefs()
+			for _, ref := range refs[rel] {
+				if deleted[ref] {
+					add(rel)
+				}
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		rel := queue[0]
+		queue = queue[1:]
+		for _, ref := range refs[rel] {
+			for _, r := range byName[ref] {
+				add(r)
+			}
+		}
+	}
+
+	files := make([]string, 0, len(selected))
+	for rel := range selected {
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// copyTree copies the regular files under src to dst.
+func (e *Engine) copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return e.copyFile(path, filepath.Join(dst, rel))
+	})
+}
@@ -0,0 +1,129 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/config"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+// newValidationTestStaging returns an engine and a staging whose quadlet dir
+// holds a small set of quadlets, drop-ins and an unrelated broken file.
+func newValidationTestStaging(t *testing.T) (*Engine, *staging) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Paths: config.PathsConfig{QuadletDir: filepath.Join(tmpDir, "quadlets"), StateDir: filepath.Join(tmpDir, "state")},
+	}
+	st := &staging{Dir: filepath.Join(tmpDir, "staging"), QuadletDir: filepath.Join(tmpDir, "staging", "quadlets")}
+	files := map[string]string{
+		"web.container":            "[Container]\nImage=nginx\nNetwork=app.network\nVolume=data.volume:/data\n",
+		"app.network":              "[Network]\n",
+		"data.volume":              "[Volume]\n",
+		"broken.container":         "not a unit\n",
+		"db.container":             "[Container]\nImage=postgres\nPod=old.pod\n",
+		"web.container.d/env.conf": "[Container]\nEnvironment=A=1\n",
+		"container.d/log.conf":     "[Container]\nLogDriver=journald\n",
+		"sub/api.container":        "[Container]\nImage=api\nNetwork=app.network\n",
+		"README.md":                "docs\n",
+		"other.network.d/mtu.conf": "[Network]\nOptions=mtu=1400\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(st.QuadletDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewEngine(cfg, &testutil.MockGitClient{}, &testutil.MockSystemd{}, testutil.TestLogger(), false), st
+}
+
+func TestValidationSet(t *testing.T) {
+	op := func(e *Engine, rel string) FileOp {
+		return FileOp{DestPath: filepath.Join(e.cfg.Paths.QuadletDir, rel)}
+	}
+	tests := []struct {
+		name string
+		plan func(e *Engine) *Plan
+		want []string
+	}{
+		{
+			name: "added quadlet and its references",
+			plan: func(e *Engine) *Plan { return &Plan{Add: []FileOp{op(e, "web.container")}} },
+			want: []string{"app.network", "data.volume", "web.container"},
+		},
+		{
+			name: "updated drop-in",
+			plan: func(e *Engine) *Plan { return &Plan{Update: []FileOp{op(e, "web.container.d/env.conf")}} },
+			want: []string{"app.network", "data.volume", "web.container"},
+		},
+		{
+			name: "updated type drop-in",
+			plan: func(e *Engine) *Plan { return &Plan{Update: []FileOp{op(e, "container.d/log.conf")}} },
+			want: []string{"app.network", "broken.container", "data.volume", "db.container", "sub/api.container", "web.container"},
+		},
+		{
+			name: "deleted quadlet still referenced",
+			plan: func(e *Engine) *Plan { return &Plan{Delete: []FileOp{op(e, "old.pod")}} },
+			want: []string{"db.container"},
+		},
+		{
+			name: "no quadlet changed",
+			plan: func(e *Engine) *Plan {
+				return &Plan{Update: []FileOp{op(e, "README.md")}, Add: []FileOp{{DestPath: "/etc/containers/x.conf"}}}
+			},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, st := newValidationTestStaging(t)
+			got, err := e.validationSet(st, tt.plan(e))
+			if err != nil {
+				t.Fatalf("validationSet() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validationSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationDir(t *testing.T) {
+	e, st := newValidationTestStaging(t)
+	plan := &Plan{Update: []FileOp{{DestPath: filepath.Join(e.cfg.Paths.QuadletDir, "web.container")}}}
+
+	dir, err := e.validationDir(st, plan)
+	if err != nil {
+		t.Fatalf("validationDir() error = %v", err)
+	}
+	var got []string
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			got = append(got, rel)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"app.network", "container.d/log.conf", "data.volume", "other.network.d/mtu.conf", "web.container", "web.container.d/env.conf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("validated files = %v, want %v", got, want)
+	}
+
+	if dir, err := e.validationDir(st, &Plan{}); err != nil || dir != "" {
+		t.Errorf("validationDir(empty plan) = %q, %v; want nothing to validate", dir, err)
+	}
+
+	e.cfg.Sync.ValidationScope = config.ValidateAll
+	if dir, err := e.validationDir(st, &Plan{}); err != nil || dir != st.QuadletDir {
+		t.Errorf("validationDir(all) = %q, %v; want the staged quadlet dir", dir, err)
+	}
+}
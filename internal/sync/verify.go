@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/schaermu/quadsyncd/internal/quadlet"
+)
+
+// VerifyStatus classifies the on-disk state of a managed file.
+type VerifyStatus string
+
+// Verification outcomes for a managed file.
+const (
+	VerifyOK         VerifyStatus = "ok"
+	VerifyModified   VerifyStatus = "modified"
+	VerifyMissing    VerifyStatus = "missing"
+	VerifyUnreadable VerifyStatus = "unreadable"
+)
+
+// VerifyResult is the verification outcome for one managed file.
+type VerifyResult struct {
+	Path     string       `json:"path"`
+	Status   VerifyStatus `json:"status"`
+	Expected string       `json:"expected_hash"`
+	Actual   string       `json:"actual_hash,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// VerifyReport summarises a verification pass over the recorded state.
+type VerifyReport struct {
+	Checked int            `json:"checked"`
+	Results []VerifyResult `json:"results"`
+}
+
+// Failures returns the results whose status is not VerifyOK.
+func (r *VerifyReport) Failures() []VerifyResult {
+	var out []VerifyResult
+	for _, res := range r.Results {
+		if res.Status != VerifyOK {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// ReadStateFile loads a state file and migrates it to StateVersion. A
+// missing file yields an empty state.
+func ReadStateFile(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &State{Version: StateVersion, ManagedFiles: make(map[string]ManagedFile)}, nil
+		}
+		return nil, err
+	}
+	return ParseState(data)
+}
+
+// ParseState decodes the content of a state file and migrates it to
+// StateVersion. It fails with ErrStateTooNew for files of a newer schema.
+func ParseState(data []byte) (*State, error) {
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if err := migrateState(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// VerifyState re-hashes every file recorded in state and compares it with the
+// recorded hash. When units is non-empty, only files belonging to those units
+// (by quadlet name or manifest restart_units) are checked. Results are sorted
+// by path.
+func VerifyState(state *State, units []string) *VerifyReport {
+	report := &VerifyReport{Results: []VerifyResult{}}
+	for path, mf := range state.ManagedFiles {
+		if len(units) > 0 && !managedFileBelongsTo(path, mf, units) {
+			continue
+		}
+		report.Checked++
+		report.Results = append(report.Results, verifyFile(path, mf.Hash))
+	}
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].Path < report.Results[j].Path
+	})
+	return report
+}
+
+// verifyFile hashes path and compares it with expected.
+func verifyFile(path, expected string) VerifyResult {
+	res := VerifyResult{Path: path, Expected: expected}
+	actual, err := fileHash(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		res.Status = VerifyMissing
+	case err != nil:
+		res.Status = VerifyUnreadable
+		res.Error = err.Error()
+	case actual != expected:
+		res.Status = VerifyModified
+		res.Actual = actual
+	default:
+		res.Status = VerifyOK
+		res.Actual = actual
+	}
+	return res
+}
+
+// managedFileBelongsTo reports whether a managed file defines, is a drop-in
+// for, or is restarted with one of units.
+func managedFileBelongsTo(path string, mf ManagedFile, units []string) bool {
+	if quadlet.IsQuadletFile(path) && slices.Contains(units, quadlet.UnitNameFromQuadlet(path)) {
+		return true
+	}
+	if name, ok := quadlet.DropInQuadlet(path); ok && slices.Contains(units, quadlet.UnitNameFromQuadlet(name)) {
+		return true
+	}
+	for _, u := range mf.RestartUnits {
+		if slices.Contains(units, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders a one-line summary of the report.
+func (r *VerifyReport) String() string {
+	return fmt.Sprintf("%d files checked, %d failed", r.Checked, len(r.Failures()))
+}
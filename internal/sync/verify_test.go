@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyState(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) (string, string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		h, err := fileHash(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p, h
+	}
+
+	okPath, okHash := write("ok.container", "[Container]\nImage=a\n")
+	modPath, modHash := write("web.container", "[Container]\nImage=b\n")
+	if err := os.WriteFile(modPath, []byte("[Container]\nImage=evil\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfgPath, cfgHash := write("Caddyfile", "example.com\n")
+	missingPath := filepath.Join(dir, "gone.volume")
+
+	state := &State{ManagedFiles: map[string]ManagedFile{
+		okPath:      {Hash: okHash},
+		modPath:     {Hash: modHash},
+		missingPath: {Hash: "deadbeef"},
+		cfgPath:     {Hash: cfgHash, RestartUnits: []string{"caddy.service"}},
+	}}
+
+	report := VerifyState(state, nil)
+	if report.Checked != 4 {
+		t.Errorf("Checked = %d, want 4", report.Checked)
+	}
+	want := map[string]VerifyStatus{
+		okPath:      VerifyOK,
+		modPath:     VerifyModified,
+		missingPath: VerifyMissing,
+		cfgPath:     VerifyOK,
+	}
+	for _, res := range report.Results {
+		if res.Status != want[res.Path] {
+			t.Errorf("%s: status %s, want %s", res.Path, res.Status, want[res.Path])
+		}
+	}
+	if n := len(report.Failures()); n != 2 {
+		t.Errorf("Failures() = %d, want 2", n)
+	}
+	for i := 1; i < len(report.Results); i++ {
+		if report.Results[i-1].Path > report.Results[i].Path {
+			t.Errorf("results not sorted by path: %v", report.Results)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		units []string
+		want  []string
+	}{
+		{"quadlet unit", []string{"web.service"}, []string{modPath}},
+		{"manifest restart unit", []string{"caddy.service"}, []string{cfgPath}},
+		{"volume unit", []string{"gone-volume.service"}, []string{missingPath}},
+		{"unknown unit", []string{"nope.service"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := VerifyState(state, tt.units)
+			var got []string
+			for _, res := range r.Results {
+				got = append(got, res.Path)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("paths = %v, want %v", got, tt.want)
+			}
+			if r.Checked != len(tt.want) {
+				t.Errorf("Checked = %d, want %d", r.Checked, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestReadStateFile(t *testing.T) {
+	dir := t.TempDir()
+
+	state, err := ReadStateFile(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if state.ManagedFiles == nil || len(state.ManagedFiles) != 0 {
+		t.Errorf("expected empty managed files, got %v", state.ManagedFiles)
+	}
+
+	p := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(p, []byte(`{"commit":"abc","managed_files":null}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err = ReadStateFile(p)
+	if err != nil {
+		t.Fatalf("ReadStateFile: %v", err)
+	}
+	if state.Commit != "abc" || state.ManagedFiles == nil {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	if err := os.WriteFile(p, []byte(`{not json`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadStateFile(p); err == nil {
+		t.Error("expected error for corrupt state")
+	}
+}
@@ -0,0 +1,111 @@
+package sync
+
+import (
+	gosync "sync"
+
+	"github.com/schaermu/quadsyncd/internal/logging"
+)
+
+// WarningCode identifies the kind of a non-fatal sync warning. Codes are
+// stable and safe to match on; messages are for humans.
+type WarningCode string
+
+// Warning codes recorded in the warning ledger.
+const (
+	WarnConflictResolved   WarningCode = "conflict_resolved"
+	WarnStateUnreadable    WarningCode = "state_unreadable"
+	WarnTokenExpired       WarningCode = "token_expired"
+	WarnTokenExpiring      WarningCode = "token_expiring"
+	WarnValidationSkipped  WarningCode = "validation_skipped"
+	WarnDriftIgnored       WarningCode = "drift_ignored"
+	WarnRestartFailed      WarningCode = "restart_failed"
+	WarnDestNotWritable    WarningCode = "dest_not_writable"
+	WarnHistoryNotRecorded WarningCode = "history_not_recorded"
+	WarnPruneRefused       WarningCode = "prune_refused"
+	WarnSecretsDisabled    WarningCode = "secrets_disabled"
+	WarnStartFailed        WarningCode = "start_failed"
+	WarnUnitUnhealthy      WarningCode = "unit_unhealthy"
+	WarnUnmanagedKept      WarningCode = "unmanaged_kept"
+	WarnSyncFrozen         WarningCode = "sync_frozen"
+	WarnRestartHalted      WarningCode = "restart_halted"
+	WarnAuditNotRecorded   WarningCode = "audit_not_recorded"
+	WarnPodmanUnsupported  WarningCode = "podman_unsupported"
+	WarnAutoUpdateFailed   WarningCode = "auto_update_failed"
+	WarnWantedByMissing    WarningCode = "wantedby_missing"
+	WarnEnableFailed       WarningCode = "enable_failed"
+	WarnRestoreconFailed   WarningCode = "restorecon_failed"
+	WarnImageUnverified    WarningCode = "image_unverified"
+)
+
+// event returns the stable log event name for warnings with this code.
+// Warnings about a specific file or unit use that subsystem's event; the
+// rest share sync.warning and are told apart by the warning attribute.
+func (c WarningCode) event() string {
+	switch c {
+	case WarnDriftIgnored:
+		return logging.EventFileDriftIgnored
+	case WarnRestartFailed:
+		return logging.EventUnitRestartFailed
+	case WarnStartFailed:
+		return logging.EventUnitStartFailed
+	case WarnUnitUnhealthy:
+		return logging.EventUnitUnhealthy
+	default:
+		return logging.EventSyncWarning
+	}
+}
+
+// Warning is a non-fatal issue encountered during a sync run.
+type Warning struct {
+	Code    WarningCode `json:"code"`
+	Message string      `json:"message"`
+	// Subject names what the warning is about (a path, unit or repo URL).
+	Subject string `json:"subject,omitempty"`
+}
+
+// warningLedger collects warnings during a run. It is safe for concurrent use.
+type warningLedger struct {
+	mu    gosync.Mutex
+	items []Warning
+}
+
+// add records w.
+func (l *warningLedger) add(w Warning) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = append(l.items, w)
+}
+
+// list returns a copy of the recorded warnings in the order they were added.
+func (l *warningLedger) list() []Warning {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Warning(nil), l.items...)
+}
+
+// warn logs msg at warn level and records it in the run's warning ledger.
+// attrs are passed to the logger only.
+func (e *Engine) warn(code WarningCode, subject, msg string, attrs ...any) {
+	e.logger.Warn(msg, append([]any{logging.Event(code.event()), logging.KeyWarning, string(code)}, attrs...)...)
+	if e.warnings == nil {
+		e.warnings = &warningLedger{}
+	}
+	e.warnings.add(Warning{Code: code, Message: msg, Subject: subject})
+}
+
+// logWarningSummary logs one line summarising the run's warnings, so they
+// are visible together at the end of a sync instead of only inline.
+func (e *Engine) logWarningSummary(warnings []Warning) {
+	if len(warnings) == 0 {
+		return
+	}
+	seen := make(map[WarningCode]bool)
+	var codes []string
+	for _, w := range warnings {
+		if !seen[w.Code] {
+			seen[w.Code] = true
+			codes = append(codes, string(w.Code))
+		}
+	}
+	e.logger.Warn("sync finished with warnings", logging.Event(logging.EventSyncWarnings), "warnings", len(warnings), "codes", codes)
+}
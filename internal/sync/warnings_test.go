@@ -0,0 +1,101 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	gosync "sync"
+	"testing"
+
+	"github.com/schaermu/quadsyncd/internal/systemduser"
+	"github.com/schaermu/quadsyncd/internal/testutil"
+)
+
+func TestWarningLedger_ConcurrentAdd(t *testing.T) {
+	var l warningLedger
+	var wg gosync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.add(Warning{Code: WarnRestartFailed})
+		}()
+	}
+	wg.Wait()
+
+	got := l.list()
+	if len(got) != 50 {
+		t.Fatalf("len(list) = %d, want 50", len(got))
+	}
+	// list returns a copy; mutating it must not affect the ledger.
+	got[0].Code = WarnDriftIgnored
+	if l.list()[0].Code != WarnRestartFailed {
+		t.Error("list should return a copy of the recorded warnings")
+	}
+}
+
+// warningTestEngine returns an engine syncing app.container with ms, and
+// the quadlet directory it installs into.
+func warningTestEngine(t *testing.T, ms *testutil.MockSystemd) (*Engine, string) {
+	t.Helper()
+	cfg, mg := newTestRepo(t, map[string]string{"app.container": "[Container]\nImage=app\n[Install]\nWantedBy=default.target\n"})
+	return NewEngine(cfg, mg, ms, testutil.TestLogger(), false), cfg.Paths.QuadletDir
+}
+
+func TestRun_WarnsWhenValidationSkipped(t *testing.T) {
+	ms := &testutil.MockSystemd{
+		Available:   true,
+		ValidateErr: fmt.Errorf("%w: generator not found", systemduser.ErrValidationSkipped),
+	}
+	engine, _ := warningTestEngine(t, ms)
+
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != WarnValidationSkipped {
+		t.Fatalf("warnings = %+v, want one %s", result.Warnings, WarnValidationSkipped)
+	}
+	if !ms.ReloadCalled {
+		t.Error("a skipped validation should not stop the sync")
+	}
+}
+
+func TestRun_WarnsOnIgnoredDrift(t *testing.T) {
+	ms := &testutil.MockSystemd{Available: true}
+	engine, quadletDir := warningTestEngine(t, ms)
+
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if len(result.Warnings) != 0 {
+		t.Fatalf("first run should not warn, got %+v", result.Warnings)
+	}
+
+	dest := filepath.Join(quadletDir, "app.container")
+	if err := os.WriteFile(dest, []byte("[Container]\nImage=edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Warnings) != 1 {
+		t.Fatalf("warnings = %+v, want one", result.Warnings)
+	}
+	if w := result.Warnings[0]; w.Code != WarnDriftIgnored || w.Subject != dest {
+		t.Errorf("warning = %+v, want %s for %s", w, WarnDriftIgnored, dest)
+	}
+
+	// The warning count is recorded in history.
+	entries, err := ReadHistory(engine.cfg.Paths.StateDir, 0)
+	if err != nil {
+		t.Fatalf("ReadHistory: %v", err)
+	}
+	if len(entries) == 0 || entries[0].Warnings != 1 {
+		t.Errorf("latest history entry should record 1 warning: %+v", entries)
+	}
+}
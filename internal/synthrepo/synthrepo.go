@@ -0,0 +1,94 @@
+// Package synthrepo generates synthetic quadlet repositories of arbitrary
+// size for benchmarks and performance checks.
+package synthrepo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// filesPerDir is how many files are grouped into each stack-NNN directory.
+const filesPerDir = 100
+
+// Options controls the generated repository.
+type Options struct {
+	// Files is the total number of files to write.
+	Files int
+	// Revision is embedded into every ChurnEvery-th file, so bumping it
+	// changes that fraction of the repository and leaves the rest untouched.
+	Revision int
+	// ChurnEvery selects which files change between revisions; 0 means 10
+	// (10% of files).
+	ChurnEvery int
+}
+
+// kinds is the rotation of file types generated. Companion .env files are
+// included because they are synced but never become units.
+var kinds = []string{".container", ".volume", ".network", ".env"}
+
+// Generate writes a repository of opts.Files files under dir, grouped into
+// stack-NNN subdirectories. Basenames are unique across the repository so the
+// result never trips unit-name collision checks. Generating the same options
+// twice yields identical content.
+func Generate(dir string, opts Options) error {
+	churn := opts.ChurnEvery
+	if churn <= 0 {
+		churn = 10
+	}
+
+	for i := 0; i < opts.Files; i++ {
+		stack := filepath.Join(dir, fmt.Sprintf("stack-%03d", i/filesPerDir))
+		if i%filesPerDir == 0 {
+			if err := os.MkdirAll(stack, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", stack, err)
+			}
+		}
+
+		ext := kinds[i%len(kinds)]
+		rev := 0
+		if i%churn == 0 {
+			rev = opts.Revision
+		}
+		path := filepath.Join(stack, fmt.Sprintf("svc-%05d%s", i, ext))
+		if err := os.WriteFile(path, content(i, ext, rev), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Commit returns a stable fake commit SHA for a revision.
+func Commit(revision int) string {
+	return fmt.Sprintf("%040x", revision+1)
+}
+
+// content renders a plausible file body of a few hundred bytes.
+func content(i int, ext string, rev int) []byte {
+	switch ext {
+	case ".container":
+		return fmt.Appendf(nil, `[Unit]
+Description=Synthetic service %[1]d (revision %[2]d)
+
+[Container]
+Image=registry.example.com/app/svc-%[1]d:1.%[2]d
+ContainerName=svc-%[1]d
+EnvironmentFile=%%h/.config/containers/systemd/svc-%[1]d.env
+PublishPort=127.0.0.1:%[3]d:8080
+Volume=svc-%[1]d.volume:/data
+Network=svc-%[1]d.network
+
+[Service]
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`, i, rev, 10000+i%50000)
+	case ".volume":
+		return fmt.Appendf(nil, "[Volume]\nVolumeName=svc-%d-data\nLabel=revision=%d\n", i, rev)
+	case ".network":
+		return fmt.Appendf(nil, "[Network]\nNetworkName=svc-%d\nSubnet=10.%d.%d.0/24\nLabel=revision=%d\n", i, (i/256)%256, i%256, rev)
+	default:
+		return fmt.Appendf(nil, "APP_NAME=svc-%d\nAPP_REVISION=%d\nLOG_LEVEL=info\n", i, rev)
+	}
+}
@@ -0,0 +1,62 @@
+package synthrepo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	if err := Generate(dir, Options{Files: 250}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	if len(files) != 250 {
+		t.Fatalf("generated %d files, want 250", len(files))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stack-002", "svc-00200.container")); err != nil {
+		t.Errorf("expected file in third stack dir: %v", err)
+	}
+}
+
+func TestGenerate_RevisionChurn(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	if err := Generate(a, Options{Files: 100, Revision: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate(b, Options{Files: 100, Revision: 1, ChurnEvery: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := 0
+	for i := 0; i < 100; i++ {
+		name := filepath.Join("stack-000", fmt.Sprintf("svc-%05d%s", i, kinds[i%len(kinds)]))
+		da, err := os.ReadFile(filepath.Join(a, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := os.ReadFile(filepath.Join(b, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(da, db) {
+			changed++
+		}
+	}
+	if changed != 25 {
+		t.Errorf("changed %d files between revisions, want 25", changed)
+	}
+}
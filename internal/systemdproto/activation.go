@@ -1,4 +1,6 @@
-package activation
+// Package systemdproto implements the client side of the systemd service
+// protocols quadsyncd uses: socket activation and sd_notify.
+package systemdproto
 
 import (
 	"fmt"
@@ -1,4 +1,4 @@
-package activation
+package systemdproto
 
 import (
 	"fmt"
@@ -0,0 +1,79 @@
+package systemdproto
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Common sd_notify state assignments.
+const (
+	StateReady    = "READY=1"
+	StateStopping = "STOPPING=1"
+	StateWatchdog = "WATCHDOG=1"
+)
+
+// Status returns a STATUS= assignment carrying a free-form status line that
+// systemctl status displays. Newlines are replaced since each assignment must
+// fit on one line.
+func Status(msg string) string {
+	return "STATUS=" + strings.ReplaceAll(msg, "\n", " ")
+}
+
+// Notify sends the given state assignments to the service manager over
+// NOTIFY_SOCKET. It reports false without error when the process is not run
+// with a notification socket (e.g. not Type=notify or started by hand).
+func Notify(states ...string) (bool, error) {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return false, nil
+	}
+	// A leading @ denotes a socket in the abstract namespace.
+	if strings.HasPrefix(socketPath, "@") {
+		socketPath = "\x00" + socketPath[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, fmt.Errorf("failed to send notification: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog timeout requested by the service
+// manager via WATCHDOG_USEC, or zero when the watchdog is not enabled for
+// this process. Keepalives should be sent at half this interval.
+func WatchdogInterval() (time.Duration, error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == "" {
+		return 0, nil
+	}
+
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q: %w", pidStr, err)
+		}
+		if pid != os.Getpid() {
+			// Watchdog is for a different process
+			return 0, nil
+		}
+	}
+
+	usec, err := strconv.ParseInt(usecStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q: %w", usecStr, err)
+	}
+	if usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q: must be positive", usecStr)
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
@@ -0,0 +1,100 @@
+package systemdproto
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listenNotifySocket creates a datagram socket and points NOTIFY_SOCKET at it.
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func TestNotify_NoSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	sent, err := Notify(StateReady)
+	if err != nil {
+		t.Fatalf("Notify() unexpected error: %v", err)
+	}
+	if sent {
+		t.Error("expected sent=false without NOTIFY_SOCKET")
+	}
+}
+
+func TestNotify_SendsStates(t *testing.T) {
+	conn := listenNotifySocket(t)
+
+	sent, err := Notify(StateReady, Status("last sync ok\nabc"))
+	if err != nil {
+		t.Fatalf("Notify() unexpected error: %v", err)
+	}
+	if !sent {
+		t.Fatal("expected sent=true")
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got, want := string(buf[:n]), "READY=1\nSTATUS=last sync ok abc"; got != want {
+		t.Errorf("datagram = %q, want %q", got, want)
+	}
+}
+
+func TestNotify_MissingSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+
+	if _, err := Notify(StateReady); err == nil {
+		t.Error("expected error for unreachable notify socket")
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name    string
+		usec    string
+		pid     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "disabled", usec: "", want: 0},
+		{name: "enabled", usec: "30000000", want: 30 * time.Second},
+		{name: "enabled for this pid", usec: "1000000", pid: pid, want: time.Second},
+		{name: "other pid", usec: "1000000", pid: "99999999", want: 0},
+		{name: "invalid usec", usec: "soon", wantErr: true},
+		{name: "zero usec", usec: "0", wantErr: true},
+		{name: "invalid pid", usec: "1000000", pid: "x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+
+			got, err := WatchdogInterval()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WatchdogInterval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("WatchdogInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package systemduser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	gosync "sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
+)
+
+const (
+	systemdDest      = "org.freedesktop.systemd1"
+	systemdPath      = dbus.ObjectPath("/org/freedesktop/systemd1")
+	managerInterface = "org.freedesktop.systemd1.Manager"
+	unitInterface    = "org.freedesktop.systemd1.Unit"
+	errNoSuchUnit    = "org.freedesktop.systemd1.NoSuchUnit"
+
+	// jobDone is the JobRemoved result of a job that completed successfully.
+	jobDone = "done"
+)
+
+// busConn is the part of *dbus.Conn the DBusClient uses.
+type busConn interface {
+	Object(dest string, path dbus.ObjectPath) dbus.BusObject
+	Signal(ch chan<- *dbus.Signal)
+	RemoveSignal(ch chan<- *dbus.Signal)
+	Connected() bool
+	Close() error
+}
+
+// DBusClient implements Systemd by calling the systemd user manager
+// (org.freedesktop.systemd1) on the session bus instead of running
+// systemctl. Restarts and starts wait for their jobs to finish and fail with
+// the job result of each unit that did not come up. Quadlet validation still
+// runs the podman generator.
+type DBusClient struct {
+	logger    *slog.Logger
+	timeout   time.Duration
+	generator *Client
+	dial      func(ctx context.Context) (busConn, error)
+
+	mu   gosync.Mutex
+	conn busConn
+}
+
+// NewDBusClient creates a D-Bus systemd client that bounds each operation,
+// including waiting for its jobs, by callTimeout and each quadlet generator
+// run by generatorTimeout (0 means no limit). The bus is connected on first
+// use.
+func NewDBusClient(logger *slog.Logger, callTimeout, generatorTimeout time.Duration) *DBusClient {
+	return &DBusClient{
+		logger:    logger,
+		timeout:   callTimeout,
+		generator: NewClientWithTimeouts(logger, callTimeout, generatorTimeout),
+		dial:      dialSessionBus,
+	}
+}
+
+// dialSessionBus opens a private connection to the user's session bus and
+// subscribes to the manager's job signals.
+func dialSessionBus(ctx context.Context) (busConn, error) {
+	conn, err := dbus.SessionBusPrivateNoAutoStartup(dbus.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Auth(nil); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := conn.AddMatchSignalContext(ctx, dbus.WithMatchInterface(managerInterface), dbus.WithMatchMember("JobRemoved")); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	// systemd only emits job signals to clients that subscribed.
+	if err := conn.Object(systemdDest, systemdPath).CallWithContext(ctx, managerInterface+".Subscribe", 0).Err; err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// connect returns the bus connection, dialing again after it was lost.
+func (c *DBusClient) connect(ctx context.Context) (busConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil && c.conn.Connected() {
+		return c.conn, nil
+	}
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the systemd user manager over D-Bus: %w", err)
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// Close closes the bus connection, if one is open.
+func (c *DBusClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// call invokes a manager method and stores its reply in out.
+func (c *DBusClient) call(ctx context.Context, method string, out []any, args ...any) error {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Object(systemdDest, systemdPath).CallWithContext(ctx, managerInterface+"."+method, 0, args...).Store(out...)
+}
+
+// DaemonReload reloads the user manager configuration. The call returns once
+// the reload, including the quadlet generator, has finished.
+func (c *DBusClient) DaemonReload(ctx context.Context) error {
+	ctx, cancel := cmdexec.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if err := c.call(ctx, "Reload", nil); err != nil {
+		return fmt.Errorf("systemd daemon-reload failed: %w", cmdexec.Err(ctx, c.timeout, err))
+	}
+	return nil
+}
+
+// TryRestartUnits restarts the units that are running and waits for the
+// restart jobs to finish.
+func (c *DBusClient) TryRestartUnits(ctx context.Context, units []string) error {
+	return c.runJobs(ctx, "TryRestartUnit", units)
+}
+
+// StartUnits starts the units and waits for the start jobs to finish; units
+// already running are left as they are.
+func (c *DBusClient) StartUnits(ctx context.Context, units []string) error {
+	return c.runJobs(ctx, "StartUnit", units)
+}
+
+// RestartUnits restarts the units, starting those that are not running, and
+// waits for the restart jobs to finish.
+func (c *DBusClient) RestartUnits(ctx context.Context, units []string) error {
+	return c.runJobs(ctx, "RestartUnit", units)
+}
+
+// runJobs queues a job per unit with method and waits until systemd reports
+// each of them removed. Units whose job could not be queued or did not end
+// with "done" are reported as UnitErrors in the returned error.
+func (c *DBusClient) runJobs(ctx context.Context, method string, units []string) error {
+	if len(units) == 0 {
+		return nil
+	}
+	ctx, cancel := cmdexec.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	// Listen before queueing so no JobRemoved signal is missed.
+	signals := make(chan *dbus.Signal, 64)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	manager := conn.Object(systemdDest, systemdPath)
+	pending := make(map[dbus.ObjectPath]string, len(units))
+	var errs []error
+	for _, unit := range units {
+		var job dbus.ObjectPath
+		if err := manager.CallWithContext(ctx, managerInterface+"."+method, 0, unit, "replace").Store(&job); err != nil {
+			errs = append(errs, &UnitError{Unit: unit, Err: err})
+			continue
+		}
+		pending[job] = unit
+	}
+
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			for _, unit := range pending {
+				errs = append(errs, &UnitError{Unit: unit, Err: cmdexec.Err(ctx, c.timeout, ctx.Err())})
+			}
+			clear(pending)
+		case sig, ok := <-signals:
+			if !ok {
+				return fmt.Errorf("systemd %s failed: D-Bus connection closed while waiting for jobs", method)
+			}
+			job, result, ok := jobRemoved(sig)
+			if !ok {
+				continue
+			}
+			unit, mine := pending[job]
+			if !mine {
+				continue
+			}
+			delete(pending, job)
+			c.logger.Debug("systemd job finished", "method", method, "unit", unit, "result", result)
+			if result != jobDone {
+				errs = append(errs, &UnitError{Unit: unit, Err: fmt.Errorf("job %s", result)})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("systemd %s failed: %w", method, errors.Join(errs...))
+	}
+	return nil
+}
+
+// jobRemoved decodes a Manager.JobRemoved(id, job, unit, result) signal.
+func jobRemoved(sig *dbus.Signal) (dbus.ObjectPath, string, bool) {
+	if sig == nil || sig.Name != managerInterface+".JobRemoved" || len(sig.Body) < 4 {
+		return "", "", false
+	}
+	job, ok1 := sig.Body[1].(dbus.ObjectPath)
+	result, ok2 := sig.Body[3].(string)
+	return job, result, ok1 && ok2
+}
+
+// IsAvailable checks that the user manager answers on the session bus.
+func (c *DBusClient) IsAvailable(ctx context.Context) (bool, error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return false, err
+	}
+	var version dbus.Variant
+	err = conn.Object(systemdDest, systemdPath).CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, managerInterface, "Version").Store(&version)
+	if err != nil {
+		return false, fmt.Errorf("systemd user manager not available over D-Bus: %w", cmdexec.Err(ctx, c.timeout, err))
+	}
+	return true, nil
+}
+
+// ValidateQuadlets runs the podman quadlet generator like Client does.
+func (c *DBusClient) ValidateQuadlets(ctx context.Context, quadletDir string) error {
+	return c.generator.ValidateQuadlets(ctx, quadletDir)
+}
+
+// PodmanVersion returns the podman version like Client does.
+func (c *DBusClient) PodmanVersion(ctx context.Context) (string, error) {
+	return c.generator.PodmanVersion(ctx)
+}
+
+// EnableUnits enables the unit files with systemctl like Client does;
+// with now they are also started.
+func (c *DBusClient) EnableUnits(ctx context.Context, units []string, now bool) error {
+	return c.generator.EnableUnits(ctx, units, now)
+}
+
+// DisableUnits disables the unit files with systemctl like Client does;
+// with now they are also stopped.
+func (c *DBusClient) DisableUnits(ctx context.Context, units []string, now bool) error {
+	return c.generator.DisableUnits(ctx, units, now)
+}
+
+// AutoUpdate runs `podman auto-update` like Client does.
+func (c *DBusClient) AutoUpdate(ctx context.Context) ([]AutoUpdateReport, error) {
+	return c.generator.AutoUpdate(ctx)
+}
+
+// GetUnitStatus returns the ActiveState of a unit. Units systemd has not
+// loaded are reported as "inactive", as systemctl is-active does.
+func (c *DBusClient) GetUnitStatus(ctx context.Context, unit string) (string, error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var path dbus.ObjectPath
+	if err := c.call(ctx, "GetUnit", []any{&path}, unit); err != nil {
+		var dbusErr dbus.Error
+		if errors.As(err, &dbusErr) && dbusErr.Name == errNoSuchUnit {
+			return "inactive", nil
+		}
+		return "", fmt.Errorf("systemd GetUnit %s: %w", unit, cmdexec.Err(ctx, c.timeout, err))
+	}
+
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	var state dbus.Variant
+	err = conn.Object(systemdDest, path).CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, unitInterface, "ActiveState").Store(&state)
+	if err != nil {
+		return "", fmt.Errorf("systemd ActiveState of %s: %w", unit, cmdexec.Err(ctx, c.timeout, err))
+	}
+	status, ok := state.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("systemd ActiveState of %s: unexpected type %s", unit, state.Signature())
+	}
+	return status, nil
+}
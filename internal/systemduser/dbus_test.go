@@ -0,0 +1,168 @@
+package systemduser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeBus answers systemd manager calls. Queued jobs finish with the result
+// set in jobResults (default "done"); units without one never finish when
+// hang is set.
+type fakeBus struct {
+	jobResults map[string]string
+	hang       bool
+	queueErr   map[string]error
+	states     map[string]string
+	calls      []string
+
+	signals chan<- *dbus.Signal
+	nextJob int
+}
+
+func (b *fakeBus) Object(dest string, path dbus.ObjectPath) dbus.BusObject {
+	return &fakeObject{bus: b, path: path}
+}
+func (b *fakeBus) Signal(ch chan<- *dbus.Signal)       { b.signals = ch }
+func (b *fakeBus) RemoveSignal(ch chan<- *dbus.Signal) { b.signals = nil }
+func (b *fakeBus) Connected() bool                     { return true }
+func (b *fakeBus) Close() error                        { return nil }
+
+type fakeObject struct {
+	dbus.BusObject
+	bus  *fakeBus
+	path dbus.ObjectPath
+}
+
+func (o *fakeObject) CallWithContext(ctx context.Context, method string, flags dbus.Flags, args ...any) *dbus.Call {
+	b := o.bus
+	b.calls = append(b.calls, method)
+	member := strings.TrimPrefix(method, managerInterface+".")
+	switch member {
+	case "Reload":
+		return &dbus.Call{}
+	case "TryRestartUnit", "StartUnit", "RestartUnit":
+		unit := args[0].(string)
+		if err := b.queueErr[unit]; err != nil {
+			return &dbus.Call{Err: err}
+		}
+		b.nextJob++
+		job := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/systemd1/job/%d", b.nextJob))
+		result, ok := b.jobResults[unit]
+		if !ok && !b.hang {
+			result, ok = jobDone, true
+		}
+		if ok {
+			// An unrelated job first, as other clients' jobs share the bus.
+			b.signals <- &dbus.Signal{Name: managerInterface + ".JobRemoved", Body: []any{uint32(99), dbus.ObjectPath("/other"), "other.service", "failed"}}
+			b.signals <- &dbus.Signal{Name: managerInterface + ".JobRemoved", Body: []any{uint32(b.nextJob), job, unit, result}}
+		}
+		return &dbus.Call{Body: []any{job}}
+	case "GetUnit":
+		unit := args[0].(string)
+		if _, ok := b.states[unit]; !ok {
+			return &dbus.Call{Err: dbus.Error{Name: errNoSuchUnit, Body: []any{"Unit " + unit + " not loaded."}}}
+		}
+		return &dbus.Call{Body: []any{dbus.ObjectPath("/unit/" + unit)}}
+	case "org.freedesktop.DBus.Properties.Get":
+		if args[1] == "Version" {
+			return &dbus.Call{Body: []any{dbus.MakeVariant("256")}}
+		}
+		unit := strings.TrimPrefix(string(o.path), "/unit/")
+		return &dbus.Call{Body: []any{dbus.MakeVariant(b.states[unit])}}
+	}
+	return &dbus.Call{Err: errors.New("unexpected method " + method)}
+}
+
+func newFakeDBusClient(bus *fakeBus, timeout time.Duration) *DBusClient {
+	c := NewDBusClient(testLogger(), timeout, 0)
+	c.dial = func(context.Context) (busConn, error) { return bus, nil }
+	return c
+}
+
+func TestDBusClient_RunJobs(t *testing.T) {
+	tests := []struct {
+		name    string
+		bus     *fakeBus
+		wantErr []string
+	}{
+		{name: "all done", bus: &fakeBus{}},
+		{
+			name:    "failed job",
+			bus:     &fakeBus{jobResults: map[string]string{"b.service": "failed"}},
+			wantErr: []string{"b.service: job failed"},
+		},
+		{
+			name:    "queue error",
+			bus:     &fakeBus{queueErr: map[string]error{"a.service": errors.New("access denied")}},
+			wantErr: []string{"a.service: access denied"},
+		},
+		{
+			name:    "job never finishes",
+			bus:     &fakeBus{hang: true, jobResults: map[string]string{"a.service": "done"}},
+			wantErr: []string{"b.service:", "timed out"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeDBusClient(tt.bus, 50*time.Millisecond)
+			err := c.TryRestartUnits(context.Background(), []string{"a.service", "b.service"})
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("TryRestartUnits() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("TryRestartUnits() succeeded, want error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("TryRestartUnits() error = %v, want it to contain %q", err, want)
+				}
+			}
+			if strings.Contains(err.Error(), "other.service") {
+				t.Errorf("TryRestartUnits() error = %v, reports a job it did not queue", err)
+			}
+		})
+	}
+}
+
+func TestDBusClient_GetUnitStatus(t *testing.T) {
+	c := newFakeDBusClient(&fakeBus{states: map[string]string{"web.service": "active"}}, 0)
+	ctx := context.Background()
+
+	if got, err := c.GetUnitStatus(ctx, "web.service"); err != nil || got != "active" {
+		t.Errorf("GetUnitStatus(web.service) = %q, %v; want active", got, err)
+	}
+	if got, err := c.GetUnitStatus(ctx, "gone.service"); err != nil || got != "inactive" {
+		t.Errorf("GetUnitStatus(gone.service) = %q, %v; want inactive", got, err)
+	}
+}
+
+func TestDBusClient_ReloadAndAvailable(t *testing.T) {
+	bus := &fakeBus{}
+	c := newFakeDBusClient(bus, 0)
+	ctx := context.Background()
+
+	if err := c.DaemonReload(ctx); err != nil {
+		t.Errorf("DaemonReload() error = %v", err)
+	}
+	if ok, err := c.IsAvailable(ctx); !ok || err != nil {
+		t.Errorf("IsAvailable() = %v, %v; want true", ok, err)
+	}
+	if len(bus.calls) != 2 || bus.calls[0] != managerInterface+".Reload" {
+		t.Errorf("calls = %v", bus.calls)
+	}
+
+	c.dial = func(context.Context) (busConn, error) { return nil, errors.New("no bus") }
+	_ = c.Close()
+	if ok, err := c.IsAvailable(ctx); ok || err == nil {
+		t.Errorf("IsAvailable() without a bus = %v, %v; want false with error", ok, err)
+	}
+}
@@ -0,0 +1,40 @@
+package systemduser
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// CurrentUser returns the name of the user quadsyncd runs as.
+func CurrentUser() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the current user: %w", err)
+	}
+	return u.Username, nil
+}
+
+// LingerEnabled reports whether systemd keeps the user manager of username
+// running without a login session, as shown by
+// `loginctl show-user <username> --property=Linger`. A user that is neither
+// logged in nor lingering is reported as not lingering.
+func LingerEnabled(ctx context.Context, username string, timeout time.Duration) (bool, error) {
+	stdout, combined, err := run(ctx, timeout, nil, "loginctl", "show-user", username, "--property=Linger", "--value")
+	if err != nil {
+		if strings.Contains(string(combined), "not logged in or lingering") {
+			return false, nil
+		}
+		return false, fmt.Errorf("loginctl show-user %s: %w: %s", username, err, strings.TrimSpace(string(combined)))
+	}
+	switch value := strings.TrimSpace(string(stdout)); value {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("loginctl show-user %s: unexpected Linger value %q", username, value)
+	}
+}
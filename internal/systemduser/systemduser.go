@@ -1,13 +1,19 @@
 package systemduser
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
 	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
 )
 
 // Systemd provides operations for interacting with systemd user units
@@ -16,63 +22,190 @@ type Systemd interface {
 	DaemonReload(ctx context.Context) error
 	// TryRestartUnits attempts to restart the specified units
 	TryRestartUnits(ctx context.Context, units []string) error
+	// StartUnits starts the specified units; units already running are left
+	// as they are
+	StartUnits(ctx context.Context, units []string) error
 	// IsAvailable checks if systemctl --user is accessible
 	IsAvailable(ctx context.Context) (bool, error)
 	// ValidateQuadlets runs the podman quadlet generator in dry-run mode to
 	// validate that the quadlet files can be converted into systemd units.
 	// quadletDir is the directory containing the quadlet files to validate.
+	// An error wrapping ErrValidationSkipped means validation could not run.
 	ValidateQuadlets(ctx context.Context, quadletDir string) error
 	// GetUnitStatus returns the active state of a systemd user unit.
 	// Returns "active", "inactive", "failed", etc. on a best-effort basis.
 	GetUnitStatus(ctx context.Context, unit string) (string, error)
 }
 
+// ErrValidationSkipped is returned (wrapped) by ValidateQuadlets when the
+// quadlet generator is unavailable and validation did not run.
+var ErrValidationSkipped = errors.New("quadlet validation skipped")
+
+// UnitError reports that the job of a single unit did not succeed. Restart
+// errors wrap one UnitError per failed unit, joined.
+type UnitError struct {
+	Unit string
+	Err  error
+}
+
+func (e *UnitError) Error() string { return e.Unit + ": " + e.Err.Error() }
+
+func (e *UnitError) Unwrap() error { return e.Err }
+
+// UnitErrors returns every UnitError in err's tree, in order. It returns
+// nil when err cannot be attributed to single units, e.g. when systemd was
+// unreachable.
+func UnitErrors(err error) []*UnitError {
+	var out []*UnitError
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case *UnitError:
+			out = append(out, e)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return out
+}
+
 // Client implements Systemd by shelling out to systemctl --user
 type Client struct {
-	logger *slog.Logger
+	logger           *slog.Logger
+	systemctlTimeout time.Duration
+	generatorTimeout time.Duration
 }
 
-// NewClient creates a new systemd client
+// NewClient creates a new systemd client whose commands are only bounded by
+// the caller's context.
 func NewClient(logger *slog.Logger) *Client {
-	return &Client{logger: logger}
+	return NewClientWithTimeouts(logger, 0, 0)
+}
+
+// NewClientWithTimeouts creates a new systemd client that kills each
+// systemctl invocation after systemctlTimeout and each quadlet generator run
+// after generatorTimeout (0 means no per-command limit).
+func NewClientWithTimeouts(logger *slog.Logger, systemctlTimeout, generatorTimeout time.Duration) *Client {
+	return &Client{
+		logger:           logger,
+		systemctlTimeout: systemctlTimeout,
+		generatorTimeout: generatorTimeout,
+	}
+}
+
+// run executes name with args, killing it after timeout. It returns stdout
+// and the combined output; a command killed by the timeout yields an error
+// wrapping cmdexec.ErrTimeout.
+func run(ctx context.Context, timeout time.Duration, env []string, name string, args ...string) (stdout, combined []byte, err error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := cmdexec.Command(ctx, name, args...)
+	cmd.Env = env
+	out := cmdexec.Capture(cmd)
+	err = cmdexec.Err(ctx, timeout, cmd.Run())
+	return out.Stdout(), out.Combined(), err
+}
+
+// systemctl runs systemctl with args under the client's systemctl timeout.
+func (c *Client) systemctl(ctx context.Context, args ...string) (stdout, combined []byte, err error) {
+	return run(ctx, c.systemctlTimeout, nil, "systemctl", args...)
 }
 
 // DaemonReload reloads systemd user daemon configuration
 func (c *Client) DaemonReload(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "systemctl", "--user", "daemon-reload")
-	output, err := cmd.CombinedOutput()
+	_, output, err := c.systemctl(ctx, "--user", "daemon-reload")
 	if err != nil {
 		return fmt.Errorf("systemctl daemon-reload failed: %w: %s", err, string(output))
 	}
 	return nil
 }
 
-// TryRestartUnits attempts to restart the specified units
-// Uses try-restart to avoid errors if units don't exist or aren't running
+// TryRestartUnits attempts to restart the specified units. Each unit is
+// try-restarted by its own systemctl call, all running at once, so a failure
+// is reported as a UnitError for the unit it belongs to. try-restart leaves
+// units that are not running alone.
 func (c *Client) TryRestartUnits(ctx context.Context, units []string) error {
 	if len(units) == 0 {
 		return nil
 	}
 
-	args := append([]string{"--user", "try-restart"}, units...)
-	cmd := exec.CommandContext(ctx, "systemctl", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
+	errs := make([]error, len(units))
+	var wg gosync.WaitGroup
+	for i, unit := range units {
+		wg.Go(func() {
+			_, output, err := c.systemctl(ctx, "--user", "try-restart", unit)
+			if err != nil {
+				errs[i] = &UnitError{Unit: unit, Err: fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))}
+			}
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
 		// try-restart can fail for various non-critical reasons
 		// Log but don't fail the entire sync
-		return fmt.Errorf("systemctl try-restart had issues (may be non-fatal): %w: %s", err, string(output))
+		return fmt.Errorf("systemctl try-restart had issues (may be non-fatal): %w", err)
+	}
+	return nil
+}
+
+// StartUnits starts the specified units. Unlike try-restart this also
+// starts units that are not running, such as those of newly added quadlets.
+func (c *Client) StartUnits(ctx context.Context, units []string) error {
+	if len(units) == 0 {
+		return nil
+	}
+
+	args := append([]string{"--user", "start"}, units...)
+	_, output, err := c.systemctl(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("systemctl start failed: %w: %s", err, string(output))
+	}
+	return nil
+}
+
+// EnableUnits enables the specified units; with now they are also started.
+func (c *Client) EnableUnits(ctx context.Context, units []string, now bool) error {
+	return c.unitFileCommand(ctx, "enable", units, now)
+}
+
+// DisableUnits disables the specified units; with now they are also stopped.
+func (c *Client) DisableUnits(ctx context.Context, units []string, now bool) error {
+	return c.unitFileCommand(ctx, "disable", units, now)
+}
+
+// unitFileCommand runs systemctl --user verb [--now] units.
+func (c *Client) unitFileCommand(ctx context.Context, verb string, units []string, now bool) error {
+	if len(units) == 0 {
+		return nil
+	}
+
+	args := []string{"--user", verb}
+	if now {
+		args = append(args, "--now")
+	}
+	_, output, err := c.systemctl(ctx, append(args, units...)...)
+	if err != nil {
+		return fmt.Errorf("systemctl %s failed: %w: %s", verb, err, string(output))
 	}
 	return nil
 }
 
 // IsAvailable checks if systemctl --user is accessible
 func (c *Client) IsAvailable(ctx context.Context) (bool, error) {
-	cmd := exec.CommandContext(ctx, "systemctl", "--user", "status")
-	err := cmd.Run()
+	_, _, err := c.systemctl(ctx, "--user", "status")
 
 	// systemctl status returns non-zero for degraded systems, but it's still available
 	// We only care if the command can run at all
 	if err != nil {
+		if errors.Is(err, cmdexec.ErrTimeout) {
+			return false, fmt.Errorf("systemctl --user status: %w", err)
+		}
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			// Exit codes 1-3 are normal for systemctl status
 			if exitErr.ExitCode() <= 3 {
@@ -101,22 +234,123 @@ func (c *Client) quadletGeneratorPath() string {
 
 // ValidateQuadlets runs the podman quadlet generator in dry-run mode to
 // validate that the quadlet files in quadletDir can be converted into systemd
-// units. If the generator binary is not present, validation is skipped with a
-// warning. It reports any generator errors in the returned error.
+// units. The generator is pointed at quadletDir via QUADLET_UNIT_DIRS so a
+// staged copy can be validated before it replaces the live directory. If the
+// generator binary is not present, it returns an error wrapping
+// ErrValidationSkipped so callers can proceed. It reports any generator
+// errors in the returned error.
 func (c *Client) ValidateQuadlets(ctx context.Context, quadletDir string) error {
+	_, err := c.GenerateUnits(ctx, quadletDir)
+	return err
+}
+
+// GenerateUnits runs the podman quadlet generator in dry-run mode over the
+// quadlet files in quadletDir and returns the units it would write, in the
+// order it printed them. Errors are those of ValidateQuadlets.
+func (c *Client) GenerateUnits(ctx context.Context, quadletDir string) ([]GeneratedUnit, error) {
 	generatorPath := c.quadletGeneratorPath()
 	if _, err := os.Stat(generatorPath); err != nil {
-		c.logger.Warn("podman-system-generator not found, skipping quadlet validation",
-			"path", generatorPath,
-			"quadlet_dir", quadletDir)
-		return nil
+		return nil, fmt.Errorf("%w: podman-system-generator not found at %s", ErrValidationSkipped, generatorPath)
 	}
-	cmd := exec.CommandContext(ctx, generatorPath, "--user", "--dryrun")
-	output, err := cmd.CombinedOutput()
+	env := append(os.Environ(), "QUADLET_UNIT_DIRS="+quadletDir)
+	stdout, output, err := run(ctx, c.generatorTimeout, env, generatorPath, "--user", "--dryrun")
 	if err != nil {
-		return fmt.Errorf("podman-system-generator --dryrun (path %s): %w: %s", generatorPath, err, strings.TrimSpace(string(output)))
+		return nil, fmt.Errorf("podman-system-generator --dryrun (path %s): %w: %s", generatorPath, err, strings.TrimSpace(string(output)))
 	}
-	return nil
+	return ParseGeneratorOutput(stdout), nil
+}
+
+// PodmanVersion returns the output of `podman --version`, e.g. "podman
+// version 5.2.1". The quadlet generator ships with podman, so this is also
+// its version. The command is bounded by the generator timeout.
+func (c *Client) PodmanVersion(ctx context.Context) (string, error) {
+	stdout, output, err := run(ctx, c.generatorTimeout, nil, "podman", "--version")
+	if err != nil {
+		return "", fmt.Errorf("podman --version: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// AutoUpdateReport is one container `podman auto-update` considered, as
+// printed with --format json.
+type AutoUpdateReport struct {
+	Unit      string `json:"Unit"`
+	Container string `json:"Container"`
+	Image     string `json:"Image"`
+	Policy    string `json:"Policy"`
+	// Updated is "true", "false", "failed", "rolled back" or, in dry-run
+	// mode, "pending".
+	Updated string `json:"Updated"`
+}
+
+// AutoUpdate runs `podman auto-update`, which pulls newer images for the
+// containers labelled io.containers.autoupdate and restarts their units,
+// rolling back those that fail to start. Rootless podman only considers the
+// containers of the calling user. The command is bounded by the generator
+// timeout. Podman prints its reports before failing, so they are returned
+// along with the error when they could be parsed.
+func (c *Client) AutoUpdate(ctx context.Context) ([]AutoUpdateReport, error) {
+	ctx, cancel := cmdexec.WithTimeout(ctx, c.generatorTimeout)
+	defer cancel()
+
+	cmd := cmdexec.Command(ctx, "podman", "auto-update", "--format", "json")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmdexec.Err(ctx, c.generatorTimeout, cmd.Run())
+
+	var reports []AutoUpdateReport
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, &reports); err != nil && runErr == nil {
+			return nil, fmt.Errorf("podman auto-update: parsing output: %w", err)
+		}
+	}
+	if runErr != nil {
+		return reports, fmt.Errorf("podman auto-update: %w: %s", runErr, strings.TrimSpace(stderr.String()))
+	}
+	return reports, nil
+}
+
+// GeneratedUnit is a systemd unit file the quadlet generator produced.
+type GeneratedUnit struct {
+	Name    string
+	Content string
+}
+
+// SourcePath returns the quadlet file the unit was generated from, as the
+// generator records it in SourcePath=, or "" when it is not set.
+func (u GeneratedUnit) SourcePath() string {
+	for _, line := range strings.Split(u.Content, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "SourcePath="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// ParseGeneratorOutput splits the dry-run output of the quadlet generator,
+// where each unit follows a "---<name>---" line, into its units. Output
+// before the first unit is ignored.
+func ParseGeneratorOutput(out []byte) []GeneratedUnit {
+	var units []GeneratedUnit
+	var content strings.Builder
+	flush := func() {
+		if len(units) > 0 {
+			units[len(units)-1].Content = strings.TrimRight(content.String(), "\n") + "\n"
+		}
+		content.Reset()
+	}
+	for _, line := range strings.SplitAfter(string(out), "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if len(trimmed) > 6 && strings.HasPrefix(trimmed, "---") && strings.HasSuffix(trimmed, "---") {
+			flush()
+			units = append(units, GeneratedUnit{Name: trimmed[3 : len(trimmed)-3]})
+			continue
+		}
+		content.WriteString(line)
+	}
+	flush()
+	return units
 }
 
 // RestartUnits restarts the specified units (harder than try-restart)
@@ -126,8 +360,7 @@ func (c *Client) RestartUnits(ctx context.Context, units []string) error {
 	}
 
 	args := append([]string{"--user", "restart"}, units...)
-	cmd := exec.CommandContext(ctx, "systemctl", args...)
-	output, err := cmd.CombinedOutput()
+	_, output, err := c.systemctl(ctx, args...)
 	if err != nil {
 		return fmt.Errorf("systemctl restart failed: %w: %s", err, string(output))
 	}
@@ -139,13 +372,12 @@ func (c *Client) RestartUnits(ctx context.Context, units []string) error {
 // failed units and are not treated as errors. Genuine failures (binary not
 // found, context cancelled, permission errors) are propagated.
 func (c *Client) GetUnitStatus(ctx context.Context, unit string) (string, error) {
-	cmd := exec.CommandContext(ctx, "systemctl", "--user", "is-active", unit)
-	output, err := cmd.Output()
+	output, _, err := c.systemctl(ctx, "--user", "is-active", unit)
 	status := strings.TrimSpace(string(output))
 
 	if err != nil {
 		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
+		if errors.As(err, &exitErr) && !errors.Is(err, cmdexec.ErrTimeout) {
 			// is-active returns non-zero for inactive/failed units; not an error.
 			return status, nil
 		}
@@ -9,10 +9,17 @@ package systemduser
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/schaermu/quadsyncd/internal/cmdexec"
 )
 
 // writeFakeBinary writes a shell script to dir/<name> that saves its arguments
@@ -79,32 +86,96 @@ func TestSystemd_DaemonReload_UsesUserScope(t *testing.T) {
 	}
 }
 
-// TestSystemd_TryRestartUnits_BuildsArgs verifies that TryRestartUnits passes
-// --user try-restart followed by each unit name.
-func TestSystemd_TryRestartUnits_BuildsArgs(t *testing.T) {
+// TestSystemd_TryRestartUnits_PerUnit verifies that TryRestartUnits runs
+// "systemctl --user try-restart <unit>" once per unit and attributes a
+// failure to the unit whose call failed.
+func TestSystemd_TryRestartUnits_PerUnit(t *testing.T) {
+	binDir := t.TempDir()
+	callsFile := filepath.Join(binDir, "calls.txt")
+	script := "#!/bin/sh\n" +
+		"echo \"$*\" >> " + callsFile + "\n" +
+		"if [ \"$3\" = db.service ]; then echo 'Job for db.service failed.' >&2; exit 1; fi\n" +
+		"exit 0\n"
+	if err := os.WriteFile(filepath.Join(binDir, "systemctl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prependToPATH(t, binDir)
+
+	c := NewClient(testLogger())
+	err := c.TryRestartUnits(context.Background(), []string{"app.service", "db.service"})
+	if err == nil {
+		t.Fatal("TryRestartUnits succeeded, want the db.service failure")
+	}
+	if ues := UnitErrors(err); len(ues) != 1 || ues[0].Unit != "db.service" {
+		t.Errorf("UnitErrors(%v) = %v; want only db.service", err, ues)
+	}
+	if !strings.Contains(err.Error(), "Job for db.service failed.") {
+		t.Errorf("error = %v, want systemctl output", err)
+	}
+
+	data, err := os.ReadFile(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	sort.Strings(calls)
+	want := []string{"--user try-restart app.service", "--user try-restart db.service"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+// TestSystemd_StartUnits_BuildsArgs verifies that StartUnits passes
+// --user start followed by each unit name.
+func TestSystemd_StartUnits_BuildsArgs(t *testing.T) {
 	binDir := t.TempDir()
 	writeFakeBinary(t, binDir, "systemctl")
 	prependToPATH(t, binDir)
 
 	c := NewClient(testLogger())
-	units := []string{"app.service", "db.service"}
-	if err := c.TryRestartUnits(context.Background(), units); err != nil {
-		t.Fatalf("TryRestartUnits: %v", err)
+	if err := c.StartUnits(context.Background(), []string{"app.service"}); err != nil {
+		t.Fatalf("StartUnits: %v", err)
 	}
 
 	args := readCapturedArgs(binDir)
-	if args == nil {
-		t.Fatal("systemctl was never called")
+	want := []string{"--user", "start", "app.service"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
 	}
+}
 
-	want := []string{"--user", "try-restart", "app.service", "db.service"}
-	if len(args) != len(want) {
-		t.Fatalf("args = %v, want %v", args, want)
+func TestSystemd_EnableDisableUnits_BuildsArgs(t *testing.T) {
+	binDir := t.TempDir()
+	writeFakeBinary(t, binDir, "systemctl")
+	prependToPATH(t, binDir)
+	c := NewClient(testLogger())
+
+	if err := c.EnableUnits(context.Background(), []string{"a.timer"}, true); err != nil {
+		t.Fatalf("EnableUnits: %v", err)
 	}
-	for i, a := range want {
-		if args[i] != a {
-			t.Errorf("arg[%d] = %q, want %q", i, args[i], a)
-		}
+	if args, want := readCapturedArgs(binDir), []string{"--user", "enable", "--now", "a.timer"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+
+	if err := c.DisableUnits(context.Background(), []string{"a.timer", "a.service"}, false); err != nil {
+		t.Fatalf("DisableUnits: %v", err)
+	}
+	if args, want := readCapturedArgs(binDir), []string{"--user", "disable", "a.timer", "a.service"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+// TestSystemd_ValidateQuadlets_MissingGenerator verifies that a missing
+// generator is reported as ErrValidationSkipped rather than success.
+func TestSystemd_ValidateQuadlets_MissingGenerator(t *testing.T) {
+	if _, err := os.Stat(podmanSystemGeneratorFallback); err == nil {
+		t.Skip("podman-system-generator is installed on this host")
+	}
+	t.Setenv("PATH", t.TempDir())
+
+	err := NewClient(testLogger()).ValidateQuadlets(context.Background(), t.TempDir())
+	if !errors.Is(err, ErrValidationSkipped) {
+		t.Fatalf("err = %v, want ErrValidationSkipped", err)
 	}
 }
 
@@ -139,6 +210,114 @@ func TestSystemd_ValidateQuadlets_UsesQuadletDir(t *testing.T) {
 	}
 }
 
+// TestSystemd_ValidateQuadlets_SetsUnitDirs verifies that the generator is
+// restricted to the given directory via QUADLET_UNIT_DIRS.
+func TestSystemd_ValidateQuadlets_SetsUnitDirs(t *testing.T) {
+	binDir := t.TempDir()
+	envFile := filepath.Join(binDir, "env.txt")
+	script := "#!/bin/sh\nprintf '%s' \"$QUADLET_UNIT_DIRS\" > " + envFile + "\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(binDir, "podman-system-generator"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prependToPATH(t, binDir)
+
+	quadletDir := t.TempDir()
+	if err := NewClient(testLogger()).ValidateQuadlets(context.Background(), quadletDir); err != nil {
+		t.Fatalf("ValidateQuadlets: %v", err)
+	}
+
+	got, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatalf("generator was never called: %v", err)
+	}
+	if string(got) != quadletDir {
+		t.Errorf("QUADLET_UNIT_DIRS = %q, want %q", got, quadletDir)
+	}
+}
+
+// TestSystemd_GenerateUnits_ParsesOutput verifies that the units printed by
+// the generator are returned, while its log output on stderr is not.
+func TestSystemd_GenerateUnits_ParsesOutput(t *testing.T) {
+	binDir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"echo 'quadlet-generator[1]: Loading source unit file web.container' >&2\n" +
+		"printf -- '---web.service---\\n[Unit]\\nSourcePath=%s/web.container\\n\\n[Service]\\nExecStart=/usr/bin/podman run nginx\\n\\n' \"$QUADLET_UNIT_DIRS\"\n" +
+		"printf -- '---app-network.service---\\n[Unit]\\nSourcePath=%s/app.network\\n' \"$QUADLET_UNIT_DIRS\"\n"
+	if err := os.WriteFile(filepath.Join(binDir, "podman-system-generator"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prependToPATH(t, binDir)
+
+	quadletDir := t.TempDir()
+	units, err := NewClient(testLogger()).GenerateUnits(context.Background(), quadletDir)
+	if err != nil {
+		t.Fatalf("GenerateUnits: %v", err)
+	}
+	want := []GeneratedUnit{
+		{Name: "web.service", Content: "[Unit]\nSourcePath=" + quadletDir + "/web.container\n\n[Service]\nExecStart=/usr/bin/podman run nginx\n"},
+		{Name: "app-network.service", Content: "[Unit]\nSourcePath=" + quadletDir + "/app.network\n"},
+	}
+	if !reflect.DeepEqual(units, want) {
+		t.Errorf("GenerateUnits = %#v, want %#v", units, want)
+	}
+	if got := units[1].SourcePath(); got != filepath.Join(quadletDir, "app.network") {
+		t.Errorf("SourcePath() = %q", got)
+	}
+}
+
+// TestSystemd_PodmanVersion verifies that the trimmed output of podman
+// --version is returned.
+func TestSystemd_PodmanVersion(t *testing.T) {
+	binDir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = --version ] || exit 1\necho 'podman version 5.2.1'\n"
+	if err := os.WriteFile(filepath.Join(binDir, "podman"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prependToPATH(t, binDir)
+
+	got, err := NewDBusClient(testLogger(), 0, 0).PodmanVersion(context.Background())
+	if err != nil || got != "podman version 5.2.1" {
+		t.Errorf("PodmanVersion() = %q, %v", got, err)
+	}
+}
+
+// TestSystemd_AutoUpdate verifies that podman auto-update is run with JSON
+// output and that its reports are returned, also when it fails.
+func TestSystemd_AutoUpdate(t *testing.T) {
+	const reports = `[{"Unit":"web.service","Container":"0123 (systemd-web)","Image":"docker.io/library/nginx:latest","Policy":"registry","Updated":"true"},` +
+		`{"Unit":"db.service","Container":"4567 (systemd-db)","Image":"docker.io/library/postgres:16","Policy":"registry","Updated":"failed"}]`
+	tests := []struct {
+		name    string
+		exit    int
+		wantErr bool
+	}{
+		{name: "success"},
+		{name: "failure", exit: 125, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binDir := t.TempDir()
+			script := "#!/bin/sh\n[ \"$*\" = 'auto-update --format json' ] || exit 2\n" +
+				"echo '" + reports + "'\necho 'pull failed' >&2\nexit " + strconv.Itoa(tt.exit) + "\n"
+			if err := os.WriteFile(filepath.Join(binDir, "podman"), []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			prependToPATH(t, binDir)
+
+			got, err := NewDBusClient(testLogger(), 0, 0).AutoUpdate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AutoUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "pull failed") {
+				t.Errorf("error %q does not include stderr", err)
+			}
+			if len(got) != 2 || got[0].Unit != "web.service" || got[0].Updated != "true" || got[1].Updated != "failed" {
+				t.Errorf("AutoUpdate() = %+v", got)
+			}
+		})
+	}
+}
+
 // TestSystemd_GetUnitStatus_ParsesActive verifies that GetUnitStatus returns
 // the trimmed stdout of the fake binary and does not surface a non-zero exit
 // as an error (is-active exits non-zero for inactive units).
@@ -206,3 +385,91 @@ func TestSystemd_GetUnitStatus_MissingBinaryReturnsError(t *testing.T) {
 		t.Errorf("error should contain context about the command, got: %v", err)
 	}
 }
+
+// TestSystemd_Timeouts verifies that hung systemctl and generator processes
+// are killed once the configured timeout expires.
+func TestSystemd_Timeouts(t *testing.T) {
+	binDir := t.TempDir()
+	hang := []byte("#!/bin/sh\nsleep 30\n")
+	for _, name := range []string{"systemctl", "podman-system-generator"} {
+		if err := os.WriteFile(filepath.Join(binDir, name), hang, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prependToPATH(t, binDir)
+
+	c := NewClientWithTimeouts(testLogger(), 100*time.Millisecond, 100*time.Millisecond)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"DaemonReload", func() error { return c.DaemonReload(ctx) }},
+		{"RestartUnits", func() error { return c.RestartUnits(ctx, []string{"app.service"}) }},
+		{"IsAvailable", func() error {
+			ok, err := c.IsAvailable(ctx)
+			if ok {
+				t.Error("IsAvailable should report false for a hung systemctl")
+			}
+			return err
+		}},
+		{"GetUnitStatus", func() error {
+			_, err := c.GetUnitStatus(ctx, "app.service")
+			return err
+		}},
+		{"ValidateQuadlets", func() error { return c.ValidateQuadlets(ctx, t.TempDir()) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			err := tt.call()
+			if !errors.Is(err, cmdexec.ErrTimeout) {
+				t.Fatalf("error = %v, want ErrTimeout", err)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("call took %s, the timeout did not kill the command", elapsed)
+			}
+		})
+	}
+}
+
+// TestLingerEnabled verifies that LingerEnabled asks loginctl for the Linger
+// property and parses its answer.
+func TestLingerEnabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    bool
+		wantErr bool
+	}{
+		{name: "enabled", script: "echo yes", want: true},
+		{name: "disabled", script: "echo no", want: false},
+		{name: "not logged in", script: "echo 'Failed to get user: User ID 1000 is not logged in or lingering' >&2; exit 1", want: false},
+		{name: "loginctl fails", script: "echo 'Failed to connect to bus' >&2; exit 1", wantErr: true},
+		{name: "unexpected value", script: "echo maybe", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binDir := t.TempDir()
+			script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + filepath.Join(binDir, "args.txt") + "\n" + tt.script + "\n"
+			if err := os.WriteFile(filepath.Join(binDir, "loginctl"), []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			prependToPATH(t, binDir)
+
+			got, err := LingerEnabled(context.Background(), "alice", 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LingerEnabled() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LingerEnabled() = %v, want %v", got, tt.want)
+			}
+			data, _ := os.ReadFile(filepath.Join(binDir, "args.txt"))
+			want := "show-user\nalice\n--property=Linger\n--value\n"
+			if string(data) != want {
+				t.Errorf("loginctl args = %q, want %q", data, want)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@ package systemduser
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -34,3 +37,42 @@ func TestRestartUnits_Empty(t *testing.T) {
 		t.Fatalf("RestartUnits with empty slice returned error: %v", err)
 	}
 }
+
+func TestUnitErrors(t *testing.T) {
+	err := fmt.Errorf("restart: %w", errors.Join(
+		&UnitError{Unit: "a.service", Err: errors.New("job failed")},
+		errors.New("unrelated"),
+		fmt.Errorf("wrapped: %w", &UnitError{Unit: "b.service", Err: errors.New("job timeout")}),
+	))
+	var units []string
+	for _, ue := range UnitErrors(err) {
+		units = append(units, ue.Unit)
+	}
+	if !reflect.DeepEqual(units, []string{"a.service", "b.service"}) {
+		t.Errorf("UnitErrors() units = %v", units)
+	}
+	if got := UnitErrors(errors.New("bus closed")); got != nil {
+		t.Errorf("UnitErrors() without unit errors = %v", got)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	ctx := context.Background()
+	u := NewUnsupported("darwin")
+
+	if err := u.ValidateQuadlets(ctx, t.TempDir()); !errors.Is(err, ErrValidationSkipped) {
+		t.Errorf("ValidateQuadlets() error = %v, want ErrValidationSkipped", err)
+	}
+	if available, err := u.IsAvailable(ctx); available || !errors.Is(err, ErrUnsupported) {
+		t.Errorf("IsAvailable() = %v, %v; want false, ErrUnsupported", available, err)
+	}
+	for name, err := range map[string]error{
+		"DaemonReload":    u.DaemonReload(ctx),
+		"TryRestartUnits": u.TryRestartUnits(ctx, []string{"web.service"}),
+		"StartUnits":      u.StartUnits(ctx, []string{"web.service"}),
+	} {
+		if !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s() error = %v, want ErrUnsupported", name, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package systemduser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupported is returned (wrapped) by Unsupported for every operation
+// that would change units.
+var ErrUnsupported = errors.New("systemd user units are only available on Linux")
+
+// Unsupported implements Systemd on hosts without systemd, such as macOS
+// and Windows developer machines. It lets dry runs and plans work there:
+// nothing is reachable, validation is skipped and every change fails.
+type Unsupported struct {
+	// GOOS names the host platform in error messages.
+	GOOS string
+}
+
+// NewUnsupported returns a client for the platform goos.
+func NewUnsupported(goos string) *Unsupported {
+	return &Unsupported{GOOS: goos}
+}
+
+func (u *Unsupported) err() error {
+	return fmt.Errorf("%w, not on %s", ErrUnsupported, u.GOOS)
+}
+
+// DaemonReload fails with ErrUnsupported.
+func (u *Unsupported) DaemonReload(context.Context) error { return u.err() }
+
+// TryRestartUnits fails with ErrUnsupported.
+func (u *Unsupported) TryRestartUnits(context.Context, []string) error { return u.err() }
+
+// StartUnits fails with ErrUnsupported.
+func (u *Unsupported) StartUnits(context.Context, []string) error { return u.err() }
+
+// IsAvailable reports false along with ErrUnsupported.
+func (u *Unsupported) IsAvailable(context.Context) (bool, error) { return false, u.err() }
+
+// ValidateQuadlets reports that validation was skipped: there is no quadlet
+// generator to run.
+func (u *Unsupported) ValidateQuadlets(context.Context, string) error {
+	return fmt.Errorf("%w: podman-system-generator is not available on %s", ErrValidationSkipped, u.GOOS)
+}
+
+// GetUnitStatus fails with ErrUnsupported.
+func (u *Unsupported) GetUnitStatus(context.Context, string) (string, error) { return "", u.err() }
@@ -3,6 +3,7 @@ package testutil
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
@@ -11,6 +12,7 @@ import (
 	"github.com/schaermu/quadsyncd/internal/config"
 	"github.com/schaermu/quadsyncd/internal/git"
 	"github.com/schaermu/quadsyncd/internal/runstore"
+	"github.com/schaermu/quadsyncd/internal/systemduser"
 )
 
 // MockGitClient implements git.Client for testing.
@@ -18,11 +20,13 @@ type MockGitClient struct {
 	CommitHash string
 	Err        error
 	Called     bool
+	Opts       git.CheckoutOptions
 	RepoSetup  func(destDir string)
 }
 
-func (m *MockGitClient) EnsureCheckout(_ context.Context, _, _, destDir string) (string, error) {
+func (m *MockGitClient) EnsureCheckout(_ context.Context, _, _, destDir string, opts git.CheckoutOptions) (string, error) {
 	m.Called = true
+	m.Opts = opts
 	if m.RepoSetup != nil {
 		m.RepoSetup(destDir)
 	}
@@ -35,11 +39,22 @@ type MockSystemd struct {
 	AvailableErr   error
 	ReloadErr      error
 	RestartErr     error
+	StartErr       error
 	ValidateErr    error
 	ReloadCalled   bool
 	RestartCalled  bool
 	ValidateCalled bool
+	ValidatedDir   string
 	RestartedUnits []string
+	RestartBatches [][]string // units of every TryRestartUnits call, in order
+	// RestartFailures fails the restart of the listed units with a
+	// systemduser.UnitError each; the other units restart.
+	RestartFailures map[string]error
+	StartCalled     bool
+	StartedUnits    []string
+	// UnitStatus overrides the state GetUnitStatus reports per unit;
+	// units not listed are "inactive".
+	UnitStatus map[string]string
 }
 
 func (m *MockSystemd) IsAvailable(_ context.Context) (bool, error) {
@@ -54,15 +69,35 @@ func (m *MockSystemd) DaemonReload(_ context.Context) error {
 func (m *MockSystemd) TryRestartUnits(_ context.Context, units []string) error {
 	m.RestartCalled = true
 	m.RestartedUnits = units
-	return m.RestartErr
+	m.RestartBatches = append(m.RestartBatches, units)
+	if m.RestartErr != nil {
+		return m.RestartErr
+	}
+	var errs []error
+	for _, unit := range units {
+		if err, ok := m.RestartFailures[unit]; ok {
+			errs = append(errs, &systemduser.UnitError{Unit: unit, Err: err})
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (m *MockSystemd) ValidateQuadlets(_ context.Context, _ string) error {
+func (m *MockSystemd) StartUnits(_ context.Context, units []string) error {
+	m.StartCalled = true
+	m.StartedUnits = units
+	return m.StartErr
+}
+
+func (m *MockSystemd) ValidateQuadlets(_ context.Context, quadletDir string) error {
 	m.ValidateCalled = true
+	m.ValidatedDir = quadletDir
 	return m.ValidateErr
 }
 
-func (m *MockSystemd) GetUnitStatus(_ context.Context, _ string) (string, error) {
+func (m *MockSystemd) GetUnitStatus(_ context.Context, unit string) (string, error) {
+	if status, ok := m.UnitStatus[unit]; ok {
+		return status, nil
+	}
 	return "inactive", nil
 }
 
@@ -71,9 +106,9 @@ type MultiMockGitClient struct {
 	Handlers map[string]*MockGitClient
 }
 
-func (m *MultiMockGitClient) EnsureCheckout(ctx context.Context, url, ref, destDir string) (string, error) {
+func (m *MultiMockGitClient) EnsureCheckout(ctx context.Context, url, ref, destDir string, opts git.CheckoutOptions) (string, error) {
 	if h, ok := m.Handlers[url]; ok {
-		return h.EnsureCheckout(ctx, url, ref, destDir)
+		return h.EnsureCheckout(ctx, url, ref, destDir, opts)
 	}
 	return "", fmt.Errorf("no handler for URL %q", url)
 }
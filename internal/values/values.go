@@ -0,0 +1,57 @@
+// Package values loads and layers YAML values files for quadlet templating.
+package values
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values is a tree of resolved values keyed by string.
+type Values map[string]any
+
+// Load reads files in order and deep-merges each one over the previous
+// layers, so later files (e.g. host-specific) override earlier ones (e.g.
+// common defaults). An empty file list yields an empty, non-nil Values.
+func Load(files []string) (Values, error) {
+	out := Values{}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file: %w", err)
+		}
+		var layer map[string]any
+		if err := yaml.Unmarshal(data, &layer); err != nil {
+			return nil, fmt.Errorf("failed to parse values file %s: %w", path, err)
+		}
+		Merge(out, layer)
+	}
+	return out, nil
+}
+
+// Merge deep-merges src into dst in place using Helm semantics: nested maps
+// are merged key by key, while scalars and lists from src replace the value
+// in dst. A null value in src removes the key from dst.
+func Merge(dst, src map[string]any) {
+	for k, sv := range src {
+		if sv == nil {
+			delete(dst, k)
+			continue
+		}
+		srcMap, srcIsMap := sv.(map[string]any)
+		dstMap, dstIsMap := dst[k].(map[string]any)
+		if srcIsMap && dstIsMap {
+			Merge(dstMap, srcMap)
+			continue
+		}
+		if srcIsMap {
+			// Copy so later layers never mutate a previously loaded layer.
+			cp := make(map[string]any, len(srcMap))
+			Merge(cp, srcMap)
+			dst[k] = cp
+			continue
+		}
+		dst[k] = sv
+	}
+}
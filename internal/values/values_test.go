@@ -0,0 +1,110 @@
+package values
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  map[string]any
+		src  map[string]any
+		want map[string]any
+	}{
+		{
+			name: "scalar override",
+			dst:  map[string]any{"replicas": 1, "image": "nginx"},
+			src:  map[string]any{"replicas": 3},
+			want: map[string]any{"replicas": 3, "image": "nginx"},
+		},
+		{
+			name: "nested maps merge",
+			dst:  map[string]any{"web": map[string]any{"port": 80, "tag": "1.0"}},
+			src:  map[string]any{"web": map[string]any{"tag": "1.1"}},
+			want: map[string]any{"web": map[string]any{"port": 80, "tag": "1.1"}},
+		},
+		{
+			name: "lists replace",
+			dst:  map[string]any{"args": []any{"a", "b"}},
+			src:  map[string]any{"args": []any{"c"}},
+			want: map[string]any{"args": []any{"c"}},
+		},
+		{
+			name: "map replaces scalar",
+			dst:  map[string]any{"db": "sqlite"},
+			src:  map[string]any{"db": map[string]any{"host": "pg"}},
+			want: map[string]any{"db": map[string]any{"host": "pg"}},
+		},
+		{
+			name: "null removes key",
+			dst:  map[string]any{"debug": true, "name": "x"},
+			src:  map[string]any{"debug": nil},
+			want: map[string]any{"name": "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Merge(tt.dst, tt.src)
+			if !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("Merge() = %v, want %v", tt.dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_Layering(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	common := write("common.yaml", "env: dev\nweb:\n  image: nginx\n  tag: \"1.0\"\n  port: 8080\n")
+	prod := write("prod.yaml", "env: prod\nweb:\n  tag: \"1.1\"\n")
+	host := write("web01.yaml", "web:\n  port: 9090\n")
+
+	got, err := Load([]string{common, prod, host})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Values{
+		"env": "prod",
+		"web": map[string]any{"image": "nginx", "tag": "1.1", "port": 9090},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %v, want %v", got, want)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(bad, []byte("- not\n- a map\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load([]string{filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if _, err := Load([]string{bad}); err == nil {
+		t.Error("expected error for non-mapping file")
+	}
+}
+
+func TestLoad_Empty(t *testing.T) {
+	got, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load(nil) error = %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Errorf("Load(nil) = %v, want empty map", got)
+	}
+}
@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("generic", newGenericProvider)
+}
+
+// genericEventType is the Type of every generic delivery. The generic
+// provider has no notion of event types; each delivery asks for a sync.
+const genericEventType = "push"
+
+// genericProvider accepts any JSON payload signed with an HMAC-SHA256 of
+// the body in a configured header, so that CI systems without a dedicated
+// provider can trigger syncs.
+type genericProvider struct {
+	secret          []byte
+	signatureHeader string
+	deliveryHeader  string
+	ref             fieldPath
+	commit          fieldPath // nil when not configured
+	repository      fieldPath
+}
+
+func newGenericProvider(opts Options) (Provider, error) {
+	if opts.SignatureHeader == "" {
+		return nil, errors.New("generic provider needs a signature header")
+	}
+	p := &genericProvider{
+		secret:          opts.Secret,
+		signatureHeader: opts.SignatureHeader,
+		deliveryHeader:  opts.DeliveryHeader,
+	}
+	for _, f := range []struct {
+		name string
+		expr string
+		dst  *fieldPath
+		opt  bool
+	}{
+		{"ref", opts.Fields.Ref, &p.ref, false},
+		{"commit", opts.Fields.Commit, &p.commit, true},
+		{"repository", opts.Fields.Repository, &p.repository, false},
+	} {
+		if f.expr == "" {
+			if f.opt {
+				continue
+			}
+			return nil, fmt.Errorf("generic provider needs a %s expression", f.name)
+		}
+		path, err := parseFieldPath(f.expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s expression %q: %w", f.name, f.expr, err)
+		}
+		*f.dst = path
+	}
+	return p, nil
+}
+
+// VerifyRequest checks the hex HMAC in the signature header. A "sha256="
+// prefix, as GitHub and several CI systems send it, is accepted.
+func (p *genericProvider) VerifyRequest(r *http.Request, body []byte) error {
+	signature := r.Header.Get(p.signatureHeader)
+	if signature == "" {
+		return fmt.Errorf("missing %s header", p.signatureHeader)
+	}
+	if !validHMACSHA256(p.secret, body, strings.TrimPrefix(signature, "sha256=")) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// ParseEvent extracts ref, commit and repository with the configured
+// expressions. A ref without a refs/ prefix is taken as a branch name. The
+// payload does not tell which files changed, so every delivery is treated
+// as possibly touching watched paths.
+func (p *genericProvider) ParseEvent(r *http.Request, body []byte) (*Event, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	event := &Event{Kind: KindPush, Type: genericEventType}
+	if p.deliveryHeader != "" {
+		event.DeliveryID = r.Header.Get(p.deliveryHeader)
+	}
+
+	ref, ok := p.ref.lookup(doc)
+	if !ok || ref == "" {
+		return nil, fmt.Errorf("no ref at %s", p.ref)
+	}
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+	event.Ref = ref
+
+	repo, ok := p.repository.lookup(doc)
+	if !ok || repo == "" {
+		return nil, fmt.Errorf("no repository at %s", p.repository)
+	}
+	// The value may be an owner/name path or a clone URL; the server
+	// matches either against the configured repositories.
+	event.Repository = Repository{FullName: repo, URLs: []string{repo}}
+
+	if p.commit != nil {
+		event.Commit, _ = p.commit.lookup(doc)
+	}
+	return event, nil
+}
+
+// fieldPath is a parsed JSONPath-style expression: the object keys
+// (string) and array indexes (int) leading from the document root to a
+// value.
+type fieldPath []any
+
+// parseFieldPath parses expressions such as $.build.ref, build.ref,
+// commits[0].id and repo["full name"]. The leading $ is optional.
+func parseFieldPath(expr string) (fieldPath, error) {
+	rest := strings.TrimPrefix(expr, "$")
+	var path fieldPath
+	for first := rest == expr; rest != ""; first = false {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errors.New("unterminated [")
+			}
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				path = append(path, inner[1:len(inner)-1])
+			} else if idx, err := strconv.Atoi(inner); err == nil && idx >= 0 {
+				path = append(path, idx)
+			} else {
+				return nil, fmt.Errorf("invalid index [%s]", inner)
+			}
+			rest = rest[end+1:]
+		case rest[0] == '.' || first:
+			if rest[0] == '.' {
+				rest = rest[1:]
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, errors.New("empty key")
+			}
+			path = append(path, rest[:end])
+			rest = rest[end:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+	}
+	if len(path) == 0 {
+		return nil, errors.New("empty expression")
+	}
+	return path, nil
+}
+
+// lookup returns the string or number at the path in a document decoded
+// with json.Decoder.UseNumber.
+func (p fieldPath) lookup(doc any) (string, bool) {
+	v := doc
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return "", false
+			}
+			if v, ok = obj[step]; !ok {
+				return "", false
+			}
+		case int:
+			arr, ok := v.([]any)
+			if !ok || step >= len(arr) {
+				return "", false
+			}
+			v = arr[step]
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	}
+	return "", false
+}
+
+// String formats the path as an expression, for error messages.
+func (p fieldPath) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			if strings.ContainsAny(step, ".[]'\" ") {
+				fmt.Fprintf(&b, "[%q]", step)
+			} else {
+				b.WriteString("." + step)
+			}
+		case int:
+			fmt.Fprintf(&b, "[%d]", step)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    fieldPath
+		wantErr bool
+	}{
+		{expr: "$.ref", want: fieldPath{"ref"}},
+		{expr: "build.ref", want: fieldPath{"build", "ref"}},
+		{expr: "$.commits[0].id", want: fieldPath{"commits", 0, "id"}},
+		{expr: `$["repo name"].url`, want: fieldPath{"repo name", "url"}},
+		{expr: "$['a.b']", want: fieldPath{"a.b"}},
+		{expr: "[1]", want: fieldPath{1}},
+		{expr: "", wantErr: true},
+		{expr: "$", wantErr: true},
+		{expr: "$ref", wantErr: true},
+		{expr: "a..b", wantErr: true},
+		{expr: "a[", wantErr: true},
+		{expr: "a[-1]", wantErr: true},
+		{expr: "a[x]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parseFieldPath(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFieldPath(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFieldPath(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGeneric(t *testing.T) {
+	valid := Options{
+		SignatureHeader: "X-Signature",
+		Fields:          FieldPaths{Ref: "$.ref", Repository: "$.repo"},
+	}
+	tests := []struct {
+		name    string
+		mod     func(*Options)
+		wantErr string
+	}{
+		{name: "valid", mod: func(*Options) {}},
+		{name: "no signature header", mod: func(o *Options) { o.SignatureHeader = "" }, wantErr: "signature header"},
+		{name: "no ref", mod: func(o *Options) { o.Fields.Ref = "" }, wantErr: "ref expression"},
+		{name: "no repository", mod: func(o *Options) { o.Fields.Repository = "" }, wantErr: "repository expression"},
+		{name: "invalid commit", mod: func(o *Options) { o.Fields.Commit = "a[" }, wantErr: "invalid commit expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.mod(&opts)
+			_, err := New("generic", opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGeneric(t *testing.T) {
+	p, err := New("generic", Options{
+		Secret:          []byte("s3cret"),
+		SignatureHeader: "X-Woodpecker-Signature",
+		DeliveryHeader:  "X-Request-Id",
+		Fields: FieldPaths{
+			Ref:        "$.build.branch",
+			Commit:     "$.build.commits[0].sha",
+			Repository: "$.repo.clone_url",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"build":{"branch":"main","commits":[{"sha":"abc123"}]},"repo":{"clone_url":"https://ci.example.com/org/repo.git"}}`)
+	for _, tc := range []struct {
+		name    string
+		sig     string
+		wantErr bool
+	}{
+		{"hex", hmacHex(body, "s3cret"), false},
+		{"sha256 prefix", "sha256=" + hmacHex(body, "s3cret"), false},
+		{"wrong secret", hmacHex(body, "other"), true},
+		{"missing", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.VerifyRequest(newRequest(string(body), map[string]string{"X-Woodpecker-Signature": tc.sig}), body)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("VerifyRequest() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    Event
+		wantErr bool
+	}{
+		{
+			name: "branch name",
+			body: string(body),
+			want: Event{
+				Kind: KindPush, Type: "push", DeliveryID: "d1", Ref: "refs/heads/main", Commit: "abc123",
+				Repository: Repository{FullName: "https://ci.example.com/org/repo.git", URLs: []string{"https://ci.example.com/org/repo.git"}},
+			},
+		},
+		{
+			name: "full ref without commit",
+			body: `{"build":{"branch":"refs/tags/v1"},"repo":{"clone_url":"org/repo"}}`,
+			want: Event{
+				Kind: KindPush, Type: "push", DeliveryID: "d1", Ref: "refs/tags/v1",
+				Repository: Repository{FullName: "org/repo", URLs: []string{"org/repo"}},
+			},
+		},
+		{name: "missing ref", body: `{"repo":{"clone_url":"org/repo"}}`, wantErr: true},
+		{name: "missing repository", body: `{"build":{"branch":"main"}}`, wantErr: true},
+		{name: "ref is an object", body: `{"build":{"branch":{}},"repo":{"clone_url":"org/repo"}}`, wantErr: true},
+		{name: "not JSON", body: `branch=main`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(tt.body, map[string]string{"X-Request-Id": "d1"})
+			got, err := p.ParseEvent(req, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseEvent() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
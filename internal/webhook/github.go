@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("github", func(opts Options) (Provider, error) {
+		return &githubProvider{secret: opts.Secret}, nil
+	})
+	Register("gitea", func(opts Options) (Provider, error) {
+		return &giteaProvider{secret: opts.Secret}, nil
+	})
+}
+
+// githubMaxPushCommits is the most commits GitHub lists in a push payload.
+// A payload at the limit may be truncated, so its file lists are incomplete.
+const githubMaxPushCommits = 2048
+
+// githubPush represents the relevant fields of a GitHub push payload. Gitea
+// and Forgejo send the same shape.
+type githubPush struct {
+	Ref        string `json:"ref"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+	Deleted    bool   `json:"deleted"`
+	Created    bool   `json:"created"`
+	Forced     bool   `json:"forced"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+	} `json:"repository"`
+	Commits []githubCommit `json:"commits"`
+	// TotalCommits is sent by Gitea, which lists only the latest commits.
+	TotalCommits int `json:"total_commits"`
+	HeadCommit   *struct {
+		Timestamp time.Time `json:"timestamp"`
+	} `json:"head_commit"`
+}
+
+// githubCommit lists the paths a pushed commit changed, relative to the
+// repository root.
+type githubCommit struct {
+	ID       string   `json:"id"`
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// githubProvider verifies deliveries by their X-Hub-Signature-256 header.
+type githubProvider struct {
+	secret []byte
+}
+
+// VerifyRequest checks the "sha256=<hex>" HMAC in X-Hub-Signature-256.
+func (p *githubProvider) VerifyRequest(r *http.Request, body []byte) error {
+	return p.verifySignature(body, r.Header.Get("X-Hub-Signature-256"))
+}
+
+// verifySignature checks a GitHub "sha256=<hex>" signature of body.
+func (p *githubProvider) verifySignature(body []byte, signature string) error {
+	if signature == "" {
+		return errors.New("missing X-Hub-Signature-256 header")
+	}
+	hexSig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok || !validHMACSHA256(p.secret, body, hexSig) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// ParseEvent decodes ping, delete and push deliveries. Every other event
+// type is decoded like a push but has KindOther, so that
+// serve.allowed_event_types decides whether it is considered.
+func (p *githubProvider) ParseEvent(r *http.Request, body []byte) (*Event, error) {
+	return parseGitHubShaped(r.Header.Get("X-GitHub-Event"), r.Header.Get("X-GitHub-Delivery"), body)
+}
+
+// giteaProvider verifies deliveries by their X-Gitea-Signature header, the
+// hex HMAC-SHA256 of the body without a prefix. Forgejo sends the same
+// header.
+type giteaProvider struct {
+	secret []byte
+}
+
+// VerifyRequest checks the HMAC in X-Gitea-Signature.
+func (p *giteaProvider) VerifyRequest(r *http.Request, body []byte) error {
+	signature := r.Header.Get("X-Gitea-Signature")
+	if signature == "" {
+		return errors.New("missing X-Gitea-Signature header")
+	}
+	if !validHMACSHA256(p.secret, body, signature) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// ParseEvent decodes delete and push deliveries like GitHub's.
+func (p *giteaProvider) ParseEvent(r *http.Request, body []byte) (*Event, error) {
+	return parseGitHubShaped(r.Header.Get("X-Gitea-Event"), r.Header.Get("X-Gitea-Delivery"), body)
+}
+
+// parseGitHubShaped decodes a delivery in the GitHub payload format.
+func parseGitHubShaped(eventType, deliveryID string, body []byte) (*Event, error) {
+	event := &Event{Type: eventType, DeliveryID: deliveryID}
+	switch eventType {
+	case "ping":
+		event.Kind = KindPing
+		var ping struct {
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+		}
+		_ = json.Unmarshal(body, &ping)
+		event.Repository.FullName = ping.Repository.FullName
+		return event, nil
+	case "delete":
+		event.Kind = KindDelete
+		return event, nil
+	}
+
+	var push githubPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return nil, fmt.Errorf("invalid push payload: %w", err)
+	}
+	event.Kind = KindPush
+	if eventType != "push" {
+		event.Kind = KindOther
+	}
+	event.Ref = push.Ref
+	event.Commit = push.After
+	event.Deleted = push.Deleted || push.After == zeroSHA
+	event.Repository.FullName = push.Repository.FullName
+	for _, u := range []string{push.Repository.CloneURL, push.Repository.SSHURL} {
+		if u != "" {
+			event.Repository.URLs = append(event.Repository.URLs, u)
+		}
+	}
+	event.ChangedPaths = githubChangedPaths(push)
+	if push.HeadCommit != nil {
+		event.Timestamp = push.HeadCommit.Timestamp
+	}
+	return event, nil
+}
+
+// githubChangedPaths lists the paths a push changed, or nil when the
+// payload cannot tell: no commit list, a new or force-pushed ref, or a
+// truncated commit list.
+func githubChangedPaths(push githubPush) []string {
+	if push.Created || push.Forced || push.Before == zeroSHA ||
+		len(push.Commits) == 0 || len(push.Commits) >= githubMaxPushCommits ||
+		push.TotalCommits > len(push.Commits) {
+		return nil
+	}
+	paths := []string{}
+	for _, c := range push.Commits {
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			paths = append(paths, files...)
+		}
+	}
+	return paths
+}
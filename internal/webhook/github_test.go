@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGitHubVerifySignature(t *testing.T) {
+	const secret = "test-secret-key"
+	p := &githubProvider{secret: []byte(secret)}
+	body := []byte(`{"ref":"refs/heads/main"}`)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "valid signature", body: body, signature: "sha256=" + hmacHex(body, secret), want: true},
+		{name: "invalid signature", body: body, signature: "sha256=invalid"},
+		{name: "missing sha256 prefix", body: body, signature: "notsha256"},
+		{name: "empty signature", body: body, signature: ""},
+		{name: "wrong body", body: []byte(`{"ref":"refs/heads/other"}`), signature: "sha256=" + hmacHex(body, secret)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.VerifyRequest(newRequest("", map[string]string{"X-Hub-Signature-256": tt.signature}), tt.body)
+			if (err == nil) != tt.want {
+				t.Errorf("VerifyRequest() error = %v, want valid %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubParseEvent(t *testing.T) {
+	p := mustNew(t, "github", "s")
+	tests := []struct {
+		name  string
+		event string
+		body  string
+		want  Event
+	}{
+		{
+			name:  "ping",
+			event: "ping",
+			body:  `{"zen":"Keep it logically awesome.","hook_id":42,"repository":{"full_name":"org/repo"}}`,
+			want:  Event{Kind: KindPing, Type: "ping", DeliveryID: "d1", Repository: Repository{FullName: "org/repo"}},
+		},
+		{
+			name:  "delete",
+			event: "delete",
+			body:  `{"ref":"main","ref_type":"branch"}`,
+			want:  Event{Kind: KindDelete, Type: "delete", DeliveryID: "d1"},
+		},
+		{
+			name:  "push",
+			event: "push",
+			body: `{"ref":"refs/heads/main","before":"a1","after":"b2",
+				"repository":{"full_name":"org/repo","clone_url":"https://github.com/org/repo.git","ssh_url":"git@github.com:org/repo.git"},
+				"commits":[{"id":"b2","added":["a.container"],"modified":["b.container"],"removed":["c.volume"]}],
+				"head_commit":{"timestamp":"2026-01-02T03:04:05Z"}}`,
+			want: Event{
+				Kind: KindPush, Type: "push", DeliveryID: "d1", Ref: "refs/heads/main", Commit: "b2",
+				Repository:   Repository{FullName: "org/repo", URLs: []string{"https://github.com/org/repo.git", "git@github.com:org/repo.git"}},
+				ChangedPaths: []string{"a.container", "b.container", "c.volume"},
+				Timestamp:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+		},
+		{
+			name:  "other event",
+			event: "release",
+			body:  `{"ref":"refs/tags/v1","after":"b2"}`,
+			want:  Event{Kind: KindOther, Type: "release", DeliveryID: "d1", Ref: "refs/tags/v1", Commit: "b2"},
+		},
+		{
+			name:  "push deleting the ref",
+			event: "push",
+			body:  `{"ref":"refs/heads/main","after":"0000000000000000000000000000000000000000"}`,
+			want:  Event{Kind: KindPush, Type: "push", DeliveryID: "d1", Ref: "refs/heads/main", Commit: zeroSHA, Deleted: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(tt.body, map[string]string{"X-GitHub-Event": tt.event, "X-GitHub-Delivery": "d1"})
+			got, err := p.ParseEvent(req, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("ParseEvent() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseEvent() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	if _, err := p.ParseEvent(newRequest("", map[string]string{"X-GitHub-Event": "push"}), []byte("not json")); err == nil {
+		t.Error("ParseEvent(invalid JSON) succeeded")
+	}
+}
+
+func TestGitHubChangedPaths(t *testing.T) {
+	commits := func(paths ...string) []githubCommit {
+		return []githubCommit{{ID: "c1", Modified: paths}}
+	}
+	tests := []struct {
+		name string
+		push githubPush
+		want []string
+	}{
+		{name: "listed changes", push: githubPush{Commits: commits("deploy/web.container")}, want: []string{"deploy/web.container"}},
+		{name: "no commit list", push: githubPush{}},
+		{name: "new ref", push: githubPush{Created: true, Commits: commits("docs/a.md")}},
+		{name: "new ref without created flag", push: githubPush{Before: zeroSHA, Commits: commits("docs/a.md")}},
+		{name: "force push", push: githubPush{Forced: true, Commits: commits("docs/a.md")}},
+		{name: "truncated commit list", push: githubPush{Commits: make([]githubCommit, githubMaxPushCommits)}},
+		{name: "gitea lists only the latest commits", push: githubPush{TotalCommits: 12, Commits: commits("docs/a.md")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := githubChangedPaths(tt.push); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("githubChangedPaths() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitea(t *testing.T) {
+	p := mustNew(t, "gitea", "s3cret")
+	body := `{"ref":"refs/heads/main","before":"a1","after":"b2","repository":{"full_name":"org/repo"},
+		"commits":[{"id":"b2","modified":["web.container"]}],"total_commits":1}`
+
+	for _, tc := range []struct {
+		name      string
+		signature string
+		wantErr   bool
+	}{
+		{"valid", hmacHex([]byte(body), "s3cret"), false},
+		{"github style prefix", "sha256=" + hmacHex([]byte(body), "s3cret"), true},
+		{"wrong secret", hmacHex([]byte(body), "other"), true},
+		{"missing", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.VerifyRequest(newRequest(body, map[string]string{"X-Gitea-Signature": tc.signature}), []byte(body))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("VerifyRequest() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	req := newRequest(body, map[string]string{"X-Gitea-Event": "push", "X-Gitea-Delivery": "g1"})
+	event, err := p.ParseEvent(req, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Kind != KindPush || event.DeliveryID != "g1" || event.Ref != "refs/heads/main" || event.Commit != "b2" ||
+		!reflect.DeepEqual(event.ChangedPaths, []string{"web.container"}) {
+		t.Errorf("ParseEvent() = %+v", *event)
+	}
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func init() {
+	Register("gitlab", func(opts Options) (Provider, error) {
+		return &gitlabProvider{secret: opts.Secret}, nil
+	})
+}
+
+// GitLab event types that carry a push payload.
+const (
+	gitlabEventPush    = "Push Hook"
+	gitlabEventTagPush = "Tag Push Hook"
+)
+
+// gitlabPush represents the relevant fields of a GitLab push or tag push
+// payload.
+type gitlabPush struct {
+	Ref         string `json:"ref"`
+	Before      string `json:"before"`
+	After       string `json:"after"`
+	CheckoutSHA string `json:"checkout_sha"`
+	Project     struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		GitHTTPURL        string `json:"git_http_url"`
+		GitSSHURL         string `json:"git_ssh_url"`
+	} `json:"project"`
+	Commits []struct {
+		ID        string    `json:"id"`
+		Timestamp time.Time `json:"timestamp"`
+		Added     []string  `json:"added"`
+		Modified  []string  `json:"modified"`
+		Removed   []string  `json:"removed"`
+	} `json:"commits"`
+	// TotalCommitsCount counts every pushed commit; the payload lists at
+	// most 20 of them.
+	TotalCommitsCount int `json:"total_commits_count"`
+}
+
+// gitlabProvider verifies deliveries by their X-Gitlab-Token header, which
+// carries the secret itself rather than a signature.
+type gitlabProvider struct {
+	secret []byte
+}
+
+// VerifyRequest compares X-Gitlab-Token with the secret in constant time.
+func (p *gitlabProvider) VerifyRequest(r *http.Request, _ []byte) error {
+	token := r.Header.Get("X-Gitlab-Token")
+	if token == "" {
+		return errors.New("missing X-Gitlab-Token header")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), p.secret) != 1 {
+		return errors.New("token mismatch")
+	}
+	return nil
+}
+
+// ParseEvent decodes push and tag push deliveries. GitLab has no ping
+// event; its "Test" button sends a push. Other event types are returned
+// as KindOther without a ref, so they never match a repository.
+func (p *gitlabProvider) ParseEvent(r *http.Request, body []byte) (*Event, error) {
+	event := &Event{
+		Kind:       KindOther,
+		Type:       r.Header.Get("X-Gitlab-Event"),
+		DeliveryID: r.Header.Get("X-Gitlab-Event-UUID"),
+	}
+	if event.Type != gitlabEventPush && event.Type != gitlabEventTagPush {
+		return event, nil
+	}
+	event.Kind = KindPush
+
+	var push gitlabPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return nil, fmt.Errorf("invalid push payload: %w", err)
+	}
+	event.Ref = push.Ref
+	event.Commit = push.After
+	if push.CheckoutSHA != "" {
+		event.Commit = push.CheckoutSHA
+	}
+	event.Deleted = push.After == zeroSHA
+	event.Repository.FullName = push.Project.PathWithNamespace
+	for _, u := range []string{push.Project.GitHTTPURL, push.Project.GitSSHURL} {
+		if u != "" {
+			event.Repository.URLs = append(event.Repository.URLs, u)
+		}
+	}
+
+	complete := push.Before != zeroSHA && len(push.Commits) > 0 && push.TotalCommitsCount <= len(push.Commits)
+	if complete {
+		event.ChangedPaths = []string{}
+	}
+	for _, c := range push.Commits {
+		if complete {
+			for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+				event.ChangedPaths = append(event.ChangedPaths, files...)
+			}
+		}
+		if c.ID == event.Commit {
+			event.Timestamp = c.Timestamp
+		}
+	}
+	return event, nil
+}
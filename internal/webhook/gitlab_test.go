@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGitLab(t *testing.T) {
+	p := mustNew(t, "gitlab", "t0ken")
+
+	for _, tc := range []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", "t0ken", false},
+		{"wrong", "t0ken2", true},
+		{"missing", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.VerifyRequest(newRequest("{}", map[string]string{"X-Gitlab-Token": tc.token}), []byte("{}"))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("VerifyRequest() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	tests := []struct {
+		name  string
+		event string
+		body  string
+		want  Event
+	}{
+		{
+			name:  "push",
+			event: "Push Hook",
+			body: `{"object_kind":"push","ref":"refs/heads/main","before":"a1","after":"b2","checkout_sha":"b2",
+				"project":{"path_with_namespace":"group/sub/repo","git_http_url":"https://gitlab.com/group/sub/repo.git","git_ssh_url":"git@gitlab.com:group/sub/repo.git"},
+				"commits":[{"id":"b1","timestamp":"2026-01-02T03:00:00Z","added":["a.container"]},{"id":"b2","timestamp":"2026-01-02T03:04:05Z","removed":["c.volume"]}],
+				"total_commits_count":2}`,
+			want: Event{
+				Kind: KindPush, Type: "Push Hook", DeliveryID: "u1", Ref: "refs/heads/main", Commit: "b2",
+				Repository:   Repository{FullName: "group/sub/repo", URLs: []string{"https://gitlab.com/group/sub/repo.git", "git@gitlab.com:group/sub/repo.git"}},
+				ChangedPaths: []string{"a.container", "c.volume"},
+				Timestamp:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+		},
+		{
+			name:  "more commits than listed",
+			event: "Push Hook",
+			body:  `{"ref":"refs/heads/main","before":"a1","after":"b2","commits":[{"id":"b2","added":["a.container"]}],"total_commits_count":40}`,
+			want:  Event{Kind: KindPush, Type: "Push Hook", DeliveryID: "u1", Ref: "refs/heads/main", Commit: "b2"},
+		},
+		{
+			name:  "branch deleted",
+			event: "Push Hook",
+			body:  `{"ref":"refs/heads/old","before":"a1","after":"0000000000000000000000000000000000000000","checkout_sha":null}`,
+			want:  Event{Kind: KindPush, Type: "Push Hook", DeliveryID: "u1", Ref: "refs/heads/old", Commit: zeroSHA, Deleted: true},
+		},
+		{
+			name:  "other event",
+			event: "Merge Request Hook",
+			body:  `{"object_kind":"merge_request"}`,
+			want:  Event{Kind: KindOther, Type: "Merge Request Hook", DeliveryID: "u1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(tt.body, map[string]string{"X-Gitlab-Event": tt.event, "X-Gitlab-Event-UUID": "u1"})
+			got, err := p.ParseEvent(req, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("ParseEvent() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseEvent() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
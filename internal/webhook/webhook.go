@@ -0,0 +1,166 @@
+// Package webhook decodes webhook deliveries from git hosting services.
+//
+// Each service signs and shapes its deliveries differently. A Provider
+// authenticates a delivery and translates it into a provider-neutral Event,
+// so that the server only deals with transport concerns (client filters,
+// rate limits, replay protection) and the decision whether an event
+// triggers a sync. Providers are registered by name; a server listener
+// selects one with serve.listeners[].provider. The generic provider maps
+// arbitrary JSON payloads, such as those of CI systems, with configured
+// expressions.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind classifies a delivery by what the server does with it.
+type Kind string
+
+const (
+	// KindPush is a push to a ref, the only kind that may trigger a sync.
+	KindPush Kind = "push"
+	// KindPing checks that the hook is set up; it is answered but never
+	// syncs.
+	KindPing Kind = "ping"
+	// KindDelete reports a deleted branch or tag, which has nothing to
+	// sync.
+	KindDelete Kind = "delete"
+	// KindOther is any other event. It is considered only when its Type is
+	// listed in serve.allowed_event_types.
+	KindOther Kind = "other"
+)
+
+// zeroSHA is the commit git hosts send for the missing side of a push that
+// creates or deletes a ref.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// Event is a delivery in provider-neutral form.
+type Event struct {
+	Kind Kind
+	// Type is the provider's name for the event, e.g. "push" or
+	// "Push Hook". serve.allowed_event_types is matched against it, and
+	// "push" there matches every KindPush event.
+	Type string
+	// DeliveryID identifies the delivery for replay protection; empty when
+	// the provider sends none.
+	DeliveryID string
+	// Ref is the full ref that was pushed, e.g. refs/heads/main.
+	Ref string
+	// Commit is the commit Ref points to after the push.
+	Commit string
+	// Deleted is set for a push that deletes Ref.
+	Deleted bool
+	// Repository identifies the repository the event comes from.
+	Repository Repository
+	// ChangedPaths lists the repository-relative paths the push added,
+	// modified or removed. It is nil when the payload cannot tell, e.g.
+	// for a new or force-pushed ref or a truncated commit list.
+	ChangedPaths []string
+	// Timestamp is the time of the head commit; zero when unknown.
+	Timestamp time.Time
+}
+
+// Repository identifies the repository that sent an event.
+type Repository struct {
+	// FullName is the "owner/name" path of the repository on its host.
+	FullName string
+	// URLs are the clone URLs the payload lists for the repository.
+	URLs []string
+}
+
+// Provider authenticates and decodes the deliveries of one hosting service.
+// Implementations must be safe for concurrent use.
+type Provider interface {
+	// VerifyRequest reports an error unless the delivery was signed with
+	// the secret the provider was created with. body is the complete
+	// request body; the request body itself has already been read.
+	VerifyRequest(r *http.Request, body []byte) error
+	// ParseEvent decodes a verified delivery.
+	ParseEvent(r *http.Request, body []byte) (*Event, error)
+}
+
+// Options configures a provider created by a Factory.
+type Options struct {
+	// Secret is the shared secret deliveries are verified with.
+	Secret []byte
+	// SignatureHeader names the header carrying the HMAC-SHA256 of the
+	// body. Used by the generic provider.
+	SignatureHeader string
+	// DeliveryHeader names the header carrying a unique delivery ID, if
+	// any. Used by the generic provider.
+	DeliveryHeader string
+	// Fields locates the event fields in the payload. Used by the generic
+	// provider.
+	Fields FieldPaths
+}
+
+// FieldPaths holds JSONPath-style expressions such as $.build.ref or
+// repo["full name"], each locating one event field in a JSON payload.
+type FieldPaths struct {
+	Ref        string
+	Commit     string
+	Repository string
+}
+
+// Factory creates a provider from options.
+type Factory func(opts Options) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under name. It panics when name is
+// already registered, as two providers claiming one name is a programming
+// error.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("webhook: provider registered twice: " + name)
+	}
+	registry[name] = f
+}
+
+// New creates the provider registered under name.
+func New(name string, opts Options) (Provider, error) {
+	registryMu.RLock()
+	f, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown webhook provider %q (known: %v)", name, Names())
+	}
+	return f(opts)
+}
+
+// Names returns the registered provider names in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validHMACSHA256 reports whether signature is the hex-encoded HMAC-SHA256
+// of body under secret, comparing in constant time.
+func validHMACSHA256(secret, body []byte, signature string) bool {
+	if signature == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
@@ -0,0 +1,18 @@
+package webhook
+
+import "testing"
+
+func FuzzVerifySignature(f *testing.F) {
+	f.Add([]byte("body"), "sha256=abc123")
+	f.Add([]byte(""), "")
+	f.Add([]byte("body"), "invalid-prefix")
+	f.Add([]byte("body"), "sha256=")
+	f.Add([]byte("payload"), "sha256=deadbeef")
+	f.Add([]byte{0, 1, 2, 3}, "sha256=0000")
+
+	p := &githubProvider{secret: []byte("test-secret")}
+	f.Fuzz(func(_ *testing.T, body []byte, signature string) {
+		// Should never panic regardless of input.
+		_ = p.verifySignature(body, signature)
+	})
+}
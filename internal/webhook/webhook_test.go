@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func hmacHex(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newRequest(body string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func mustNew(t *testing.T, name, secret string) Provider {
+	t.Helper()
+	p, err := New(name, Options{Secret: []byte(secret)})
+	if err != nil {
+		t.Fatalf("New(%q): %v", name, err)
+	}
+	return p
+}
+
+func TestRegistry(t *testing.T) {
+	if got, want := Names(), []string{"generic", "gitea", "github", "gitlab"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+	if _, err := New("bitbucket", Options{}); err == nil || !strings.Contains(err.Error(), "unknown webhook provider") {
+		t.Errorf("New(unknown) error = %v, want unknown provider", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	Register("github", nil)
+}
@@ -8,6 +8,10 @@ import {
   fetchUnits,
   fetchTimer,
   triggerPlan,
+  triggerSync,
+  fetchStatus,
+  login,
+  onUnauthorized,
 } from "./api";
 
 // Helper to build a minimal Response-like object
@@ -154,4 +158,58 @@ describe("api client", () => {
       );
     });
   });
+
+  describe("fetchStatus", () => {
+    it("GETs /api/status", async () => {
+      const payload = { revisions: {}, managed_files: 0, sync: { running: false, queued: 0 } };
+      vi.spyOn(globalThis, "fetch").mockResolvedValue(mockResponse(payload));
+      const result = await fetchStatus();
+      expect(fetch).toHaveBeenCalledWith("/api/status", undefined);
+      expect(result).toEqual(payload);
+    });
+
+    it("notifies unauthorized listeners on 401", async () => {
+      vi.spyOn(globalThis, "fetch").mockResolvedValue(mockResponse("unauthorized", false, 401));
+      const cb = vi.fn();
+      const off = onUnauthorized(cb);
+      await expect(fetchStatus()).rejects.toThrow("API 401");
+      off();
+      expect(cb).toHaveBeenCalledOnce();
+    });
+  });
+
+  describe("triggerSync", () => {
+    it("POSTs to /api/sync with CSRF token from cookie", async () => {
+      Object.defineProperty(document, "cookie", {
+        writable: true,
+        value: "csrf_token=test-token-123",
+      });
+      vi.spyOn(globalThis, "fetch").mockResolvedValue(mockResponse({ running: false, queued: 1 }));
+      const result = await triggerSync();
+      expect(fetch).toHaveBeenCalledWith(
+        "/api/sync",
+        expect.objectContaining({
+          method: "POST",
+          headers: expect.objectContaining({ "X-CSRF-Token": "test-token-123" }),
+        }),
+      );
+      expect(result.queued).toBe(1);
+    });
+  });
+
+  describe("login", () => {
+    it("POSTs the token to /api/session", async () => {
+      vi.spyOn(globalThis, "fetch").mockResolvedValue(
+        mockResponse({ required: true, authenticated: true }),
+      );
+      await login("s3cret");
+      expect(fetch).toHaveBeenCalledWith(
+        "/api/session",
+        expect.objectContaining({
+          method: "POST",
+          body: JSON.stringify({ token: "s3cret" }),
+        }),
+      );
+    });
+  });
 });
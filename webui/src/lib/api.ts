@@ -10,20 +10,29 @@ export interface OverviewResponse {
   repositories: OverviewRepo[];
   last_run_id?: string;
   last_run_status?: string;
+  last_run_warnings: number;
 }
 
 export interface RunMeta {
   id: string;
   kind: "sync" | "plan";
-  trigger: "timer" | "cli" | "webhook" | "startup" | "ui";
+  trigger: "timer" | "cli" | "webhook" | "startup" | "ui" | "watch";
   started_at: string;
   ended_at?: string;
   status: "running" | "success" | "error";
   dry_run: boolean;
   revisions: Record<string, string>;
   conflicts: ConflictSummary[];
+  warnings: WarningSummary[];
   summary?: Record<string, unknown>;
   error?: string;
+  error_kind?: "auth" | "network" | "unknown";
+}
+
+export interface WarningSummary {
+  code: string;
+  message: string;
+  subject?: string;
 }
 
 export interface ConflictSummary {
@@ -80,6 +89,7 @@ export interface UnitInfo {
   source_ref?: string;
   source_sha?: string;
   hash: string;
+  active_state?: string;
 }
 
 export interface UnitsResponse {
@@ -91,6 +101,49 @@ export interface TimerInfo {
   active: boolean;
 }
 
+export interface SyncWorker {
+  running: boolean;
+  trigger?: string;
+  started_at?: string;
+  queued: number;
+}
+
+export interface HistoryEntry {
+  started_at: string;
+  duration_ms: number;
+  commit?: string;
+  revisions?: Record<string, string>;
+  ref?: string;
+  added: number;
+  updated: number;
+  deleted: number;
+  restarted: number;
+  restart_failed?: string[];
+  unhealthy?: string[];
+  warnings?: number;
+  result: string;
+  error?: string;
+}
+
+export interface HistoryResponse {
+  items: HistoryEntry[];
+}
+
+export interface StatusResponse {
+  revisions: Record<string, string>;
+  refs?: Record<string, string>;
+  managed_files: number;
+  pending_restarts?: string[];
+  freeze?: { since: string; reason?: string };
+  last_sync?: HistoryEntry;
+  sync: SyncWorker;
+}
+
+export interface SessionResponse {
+  required: boolean;
+  authenticated: boolean;
+}
+
 export interface PlanTriggerResponse {
   run_id: string;
   status?: string;
@@ -102,8 +155,24 @@ function getCsrfToken(): string {
   return match ? decodeURIComponent(match[1]) : "";
 }
 
+let unauthorizedListeners: Array<() => void> = [];
+
+/**
+ * Registers a callback run whenever the API rejects a request for a missing
+ * or invalid token. Returns an unsubscribe function.
+ */
+export function onUnauthorized(cb: () => void): () => void {
+  unauthorizedListeners.push(cb);
+  return () => {
+    unauthorizedListeners = unauthorizedListeners.filter((l) => l !== cb);
+  };
+}
+
 async function apiFetch<T>(path: string, init?: RequestInit): Promise<T> {
   const resp = await fetch(path, init);
+  if (resp.status === 401) {
+    unauthorizedListeners.forEach((cb) => cb());
+  }
   if (!resp.ok) {
     const body = await resp.text();
     throw new Error(`API ${resp.status}: ${body}`);
@@ -173,3 +242,40 @@ export function triggerPlan(): Promise<PlanTriggerResponse> {
     },
   });
 }
+
+export function fetchStatus(): Promise<StatusResponse> {
+  return apiFetch("/api/status");
+}
+
+export function fetchHistory(limit = 20): Promise<HistoryResponse> {
+  return apiFetch(`/api/history?limit=${limit}`);
+}
+
+export function triggerSync(): Promise<SyncWorker> {
+  return apiFetch("/api/sync", {
+    method: "POST",
+    headers: { "X-CSRF-Token": getCsrfToken() },
+  });
+}
+
+export function fetchSession(): Promise<SessionResponse> {
+  return apiFetch("/api/session");
+}
+
+export function login(token: string): Promise<SessionResponse> {
+  return apiFetch("/api/session", {
+    method: "POST",
+    headers: {
+      "Content-Type": "application/json",
+      "X-CSRF-Token": getCsrfToken(),
+    },
+    body: JSON.stringify({ token }),
+  });
+}
+
+export function logout(): Promise<SessionResponse> {
+  return apiFetch("/api/session", {
+    method: "DELETE",
+    headers: { "X-CSRF-Token": getCsrfToken() },
+  });
+}
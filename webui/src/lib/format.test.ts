@@ -106,6 +106,16 @@ describe("statusColor", () => {
     expect(statusColor("running")).toBe("badge-info");
   });
 
+  it("colors systemd unit states", () => {
+    expect(statusColor("active")).toBe("badge-success");
+    expect(statusColor("failed")).toBe("badge-error");
+    expect(statusColor("activating")).toBe("badge-info");
+  });
+
+  it("returns badge-warning for frozen syncs", () => {
+    expect(statusColor("frozen")).toBe("badge-warning");
+  });
+
   it("returns badge-neutral for unknown status", () => {
     expect(statusColor("pending")).toBe("badge-neutral");
     expect(statusColor("")).toBe("badge-neutral");
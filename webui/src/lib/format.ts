@@ -42,11 +42,17 @@ export function shortSha(sha: string | undefined): string {
 export function statusColor(status: string): string {
   switch (status) {
     case "success":
+    case "active":
       return "badge-success";
     case "error":
+    case "failed":
       return "badge-error";
     case "running":
+    case "activating":
+    case "reloading":
       return "badge-info";
+    case "frozen":
+      return "badge-warning";
     default:
       return "badge-neutral";
   }